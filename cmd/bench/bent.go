@@ -6,17 +6,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"text/template"
+
+	bentlib "golang.org/x/benchmarks/cmd/internal/bent"
 )
 
-// TODO(prattmic): refactor bent to export Todo so we can directly build this
-// in Go.
 var configurationTmpl = template.Must(template.New("configuration").Parse(`
 {{- range . -}}
 [[Configurations]]
@@ -63,11 +63,19 @@ func removeAllIncludingReadonly(dir string) error {
 	return os.RemoveAll(dir)
 }
 
-func bent(tcs []*toolchain, pgo bool) (err error) {
+func bent(tcs []*toolchain, pgo bool) error {
 	if pgo {
 		log.Printf("Skipping bent benchmarks (PGO not supported)")
 		return nil
 	}
+	return bentOn(tcs, "")
+}
+
+// bentOn runs bent under each toolchain, in a temporary directory that it
+// makes the current directory while bent runs. Bent prints its progress and
+// results to standard output. If cpus isn't empty, bent runs the benchmarks
+// in a cpuset of those CPUs.
+func bentOn(tcs []*toolchain, cpus string) (err error) {
 	dir, err := os.MkdirTemp("", "bent")
 	if err != nil {
 		return fmt.Errorf("error creating temporary directory: %w", err)
@@ -82,24 +90,23 @@ func bent(tcs []*toolchain, pgo bool) (err error) {
 	}()
 	log.Printf("Bent temporary directory: %s", dir)
 
-	log.Printf("Building bent...")
-
-	// Build bent itself. Just pick any toolchain we have; it doesn't matter which.
-	// We're not benchmarking bent itself, it's just a driver.
-	bentPath := filepath.Join(dir, "bent")
-	if err := tcs[0].BuildPackage("golang.org/x/benchmarks/cmd/bent", bentPath); err != nil {
-		return fmt.Errorf("building bent: %w", err)
+	// bent.Run works in the current directory.
+	if err := os.Chdir(dir); err != nil {
+		return err
 	}
+	defer func() {
+		if r := os.Chdir(workDir); r != nil && err == nil {
+			err = r
+		}
+	}()
 
 	log.Printf("Initializing bent...")
 
 	// Initialize scratch dir for bent.
-	cmd := exec.Command(bentPath, "-I")
-	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error running bent -I: %w", err)
+	opts := bentlib.DefaultOptions()
+	opts.Initialize = true
+	if _, err := bentlib.Run(context.Background(), opts); err != nil {
+		return fmt.Errorf("error initializing bent: %w", err)
 	}
 
 	confFile := filepath.Join(dir, "configurations.toml")
@@ -112,18 +119,18 @@ func bent(tcs []*toolchain, pgo bool) (err error) {
 	// Finally we can actually run the benchmarks.
 	// N.B. bent prints the "toolchain" tag to indicate which toolchain is being used.
 	// It's passed to bent via the TOML configuration.
-	cmd = exec.Command(bentPath,
-		"-N", "10",
-		"-C", confFile,
-		"-B", filepath.Join(dir, "benchmarks-50.toml"),
-		"-report-build-time=false", // We only run builds once, which won't yield statistically significant results.
-		"-v",
-	)
-	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	opts = bentlib.DefaultOptions()
+	opts.N = 10
+	opts.ConfFile = confFile
+	opts.ReportBuildTime = false // We only run builds once, which won't yield statistically significant results.
+	opts.Verbose = 1
+	opts.Cpuset = cpus
+	res, err := bentlib.Run(context.Background(), opts)
+	if err != nil {
 		return fmt.Errorf("error running bent: %w", err)
 	}
+	if res.MaxRC > 0 {
+		return fmt.Errorf("bent runs failed with status %d", res.MaxRC)
+	}
 	return nil
 }
@@ -0,0 +1,145 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultConfigFile is where bench looks for a machine configuration file
+// if -config is not given. It need not exist.
+const defaultConfigFile = "/etc/go-bench.toml"
+
+const configHelp = `
+The machine configuration file is TOML and describes how benchmarks should
+be run on this particular machine. All fields are optional.
+
+        suites: which benchmark suites to run, out of "gotest", "bent" and
+                "sweet" (default all of them)
+          cpus: CPUs reserved for benchmarking, in the list format accepted
+                by taskset -c (for example "2-7"); the benchmarks are pinned
+                to these CPUs
+          perf: whether to collect perf profiles for Sweet benchmarks
+    perf-flags: additional flags to pass to perf record, if perf is true
+   profile-dir: directory in which to keep Sweet results and profiles
+                (default a temporary directory that is deleted afterwards)
+        upload: URLs to POST the complete benchmark output to once all
+                suites have run
+      parallel: whether to run the gotest and bent suites at the same
+                time, each on half of the CPUs (cpus, or all online CPUs),
+                with no cores shared between the halves, when there are
+                at least 8 of them and enough memory is
+                available; bent confines its half with a cgroup cpuset, so
+                bench must run as root (Linux only). Benchmarks that use
+                every CPU report results for half as many, so compare them
+                only with results from parallel runs
+  parallel-gib: memory, in GiB, that must be available to run the gotest
+                and bent suites at the same time (default 16)
+
+For example:
+
+suites = ["sweet"]
+cpus = "4-15"
+perf = true
+perf-flags = "-e cycles"
+profile-dir = "/var/lib/go-bench/profiles"
+upload = ["https://perf.example.com/upload"]
+`
+
+// machineConfig is the machine-specific configuration for bench.
+type machineConfig struct {
+	Suites     []string `toml:"suites"`
+	CPUs       string   `toml:"cpus"`
+	Perf       bool     `toml:"perf"`
+	PerfFlags  string   `toml:"perf-flags"`
+	ProfileDir string   `toml:"profile-dir"`
+	Upload     []string `toml:"upload"`
+
+	Parallel    bool `toml:"parallel"`
+	ParallelGiB int  `toml:"parallel-gib"`
+}
+
+var allSuites = []string{"gotest", "bent", "sweet"}
+
+// loadMachineConfig reads the machine configuration from file. If file is
+// empty, it reads defaultConfigFile if it exists, and otherwise returns an
+// empty configuration.
+func loadMachineConfig(file string) (*machineConfig, error) {
+	cfg := new(machineConfig)
+	explicit := file != ""
+	if !explicit {
+		file = defaultConfigFile
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	md, err := toml.Decode(string(b), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	if undec := md.Undecoded(); len(undec) != 0 {
+		return nil, fmt.Errorf("parsing %s: unknown fields %v", file, undec)
+	}
+	for _, s := range cfg.Suites {
+		if !contains(allSuites, s) {
+			return nil, fmt.Errorf("parsing %s: unknown suite %q, want one of %v", file, s, allSuites)
+		}
+	}
+	if cfg.ParallelGiB < 0 {
+		return nil, fmt.Errorf("parsing %s: parallel-gib must not be negative", file)
+	}
+	if cfg.PerfFlags != "" && !cfg.Perf {
+		return nil, fmt.Errorf("parsing %s: perf-flags given but perf is not enabled", file)
+	}
+	return cfg, nil
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// runSuite reports whether the named suite should run.
+func (c *machineConfig) runSuite(name string) bool {
+	return len(c.Suites) == 0 || contains(c.Suites, name)
+}
+
+// pinnedEnv is set in the environment of bench once it has re-executed
+// itself under taskset, so that it doesn't do so again.
+const pinnedEnv = "GO_BENCH_PINNED"
+
+// pinCPUs re-executes bench pinned to the CPUs in c.CPUs, if any. All the
+// benchmarks bench runs inherit the CPU affinity. It returns only if no
+// pinning is necessary or if there is an error.
+func (c *machineConfig) pinCPUs() error {
+	if c.CPUs == "" || os.Getenv(pinnedEnv) != "" {
+		return nil
+	}
+	taskset, err := exec.LookPath("taskset")
+	if err != nil {
+		return fmt.Errorf("pinning to CPUs %s: %w", c.CPUs, err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("pinning to CPUs %s: %w", c.CPUs, err)
+	}
+	args := append([]string{"taskset", "-c", c.CPUs, self}, os.Args[1:]...)
+	env := append(os.Environ(), pinnedEnv+"=1")
+	return syscall.Exec(taskset, args, env)
+}
@@ -6,7 +6,9 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"path/filepath"
 )
 
@@ -15,10 +17,16 @@ func goTest(tcs []*toolchain, pgo bool) error {
 		log.Printf("Skipping Go test benchmarks (PGO not supported)")
 		return nil
 	}
+	return goTestOn(tcs, os.Stdout, "")
+}
+
+// goTestOn runs the Go test benchmarks under each toolchain, writing their
+// results to w. If cpus isn't empty, they're pinned to those CPUs.
+func goTestOn(tcs []*toolchain, w io.Writer, cpus string) error {
 	for _, tc := range tcs {
 		log.Printf("Running Go test benchmarks for %s", tc.Name)
-		fmt.Printf("toolchain: %s\n", tc.Name)
-		err := tc.Do("", "test", "-v", "-run=none", "-short", "-bench=.", "-count=6", "golang.org/x/benchmarks/...")
+		fmt.Fprintf(w, "toolchain: %s\n", tc.Name)
+		err := tc.run(w, cpus, "test", "-v", "-run=none", "-short", "-bench=.", "-count=6", "golang.org/x/benchmarks/...")
 		if err != nil {
 			return fmt.Errorf("error running gotest with toolchain %s: %w", tc.Name, err)
 		}
@@ -6,12 +6,19 @@
 // benchmarks in x/benchmarks.
 //
 // Benchmarks are run against the toolchain in GOROOT, and optionally an
-// additional baseline toolchain in BENCH_BASELINE_GOROOT.
+// additional baseline toolchain in BENCH_BASELINE_GOROOT. With -race, a
+// subset of the Go test benchmarks is also run built with the race detector,
+// so that regressions in its overhead are visible.
+//
+// Machine-specific settings, such as which suites to run and which CPUs to
+// run them on, are read from /etc/go-bench.toml or the file named by -config.
+// Run "bench -help-config" for a description of its format.
 package main
 
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -19,12 +26,15 @@ import (
 	"strings"
 	"time"
 
+	bentlib "golang.org/x/benchmarks/cmd/internal/bent"
 	"golang.org/x/benchmarks/sweet/common"
+	sweetlog "golang.org/x/benchmarks/sweet/common/log"
 )
 
 var (
 	wait              = flag.Bool("wait", true, "wait for system idle before starting benchmarking")
 	pgo               = flag.Bool("pgo", false, "run the benchmarks to collect profiles and rebuild them with PGO enabled before measuring")
+	race              = flag.Bool("race", false, "additionally run a subset of the Go test benchmarks built with -race, tagged \"race: on\"")
 	gorootExperiment  = flag.String("goroot", "", "GOROOT to test (default $GOROOT or 'go env GOROOT')")
 	gorootBaseline    = flag.String("goroot-baseline", "", "baseline GOROOT to test against (optional) (default $BENCH_BASELINE_GOROOT)")
 	branch            = flag.String("branch", "", "branch of the commits we're testing against (default $BENCH_BRANCH or unknown)")
@@ -32,8 +42,15 @@ var (
 	subRepoExperiment = flag.String("subrepo", "", "Sub-repo dir to test (default $BENCH_SUBREPO_PATH)")
 	subRepoBaseline   = flag.String("subrepo-baseline", "", "Sub-repo baseline to test against (default $BENCH_SUBREPO_BASELINE_PATH)")
 	builderName       = flag.String("builder", "", "The name of the CI builder the benchmarks were produced on (default $GO_BUILDER_NAME)")
+	configFile        = flag.String("config", "", "machine configuration file (default "+defaultConfigFile+", if it exists)")
+	helpConfig        = flag.Bool("help-config", false, "print a description of the machine configuration file format and exit")
 )
 
+// workDir is the directory bench started in. Commands that depend on the
+// current directory run there explicitly, since bentOn changes it while
+// bent runs.
+var workDir string
+
 func determineGOROOT() (string, error) {
 	g, ok := os.LookupEnv("GOROOT")
 	if ok {
@@ -66,39 +83,78 @@ func toolchainFromGOROOT(name, goroot string) *toolchain {
 	}
 }
 
-func run(tcs []*toolchain, pgo bool) error {
+// run runs the go command with args like tc.Do, but writes its standard
+// output to w, and if cpus isn't empty, pins it to those CPUs with taskset.
+func (tc *toolchain) run(w io.Writer, cpus string, args ...string) error {
+	argv := append([]string{tc.Tool}, args...)
+	if cpus != "" {
+		argv = append([]string{"taskset", "-c", cpus}, argv...)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = workDir
+	cmd.Env = tc.Env.Collapse()
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	sweetlog.TraceCommand(cmd, false)
+	return cmd.Run()
+}
+
+func run(tcs []*toolchain, pgo, race bool, mcfg *machineConfig) error {
 	// Because each of the functions below is responsible for running
 	// benchmarks under each toolchain itself, it is also responsible
 	// for ensuring that the benchmark tag "toolchain" is printed.
 	pass := true
 
-	if err := goTest(tcs, pgo); err != nil {
+	var gotestErr, bentErr error
+	gotestCPUs, bentCPUs, parallel := "", "", false
+	if !pgo && mcfg.runSuite("gotest") && mcfg.runSuite("bent") {
+		gotestCPUs, bentCPUs, parallel = parallelCPUs(mcfg)
+	}
+	if parallel {
+		gotestErr, bentErr = goTestAndBent(tcs, gotestCPUs, bentCPUs)
+	} else {
+		if mcfg.runSuite("gotest") {
+			gotestErr = goTest(tcs, pgo)
+		}
+		if mcfg.runSuite("bent") {
+			bentErr = bent(tcs, pgo)
+		}
+	}
+	if gotestErr != nil {
 		pass = false
-		log.Printf("Error running Go tests: %v", err)
+		log.Printf("Error running Go tests: %v", gotestErr)
 	}
-	if err := bent(tcs, pgo); err != nil {
+	if bentErr != nil {
 		pass = false
-		log.Printf("Error running bent: %v", err)
-	}
-	if os.Getenv("GO_BUILDER_NAME") != "" {
-		// On a builder, clean the Go cache in between bent and Sweet.
-		// The build cache can end up using a large portion of the builder's
-		// disk space (~60%), making Sweet run out and fail. Generally speaking
-		// we don't need the build cache because we're going to be doing every
-		// build exactly once from scratch (excluding build benchmarks, which
-		// arrange for a cacheless build their own way). However, we don't want
-		// to do this on a regular development machine because we might want to
-		// run benchmarks with the same toolchain again.
-		//
-		// Note that we only need to clean the cache with one toolchain because
-		// the build cache is shared.
-		if err := cleanGoCache(tcs[0]); err != nil {
-			return fmt.Errorf("failed to clean Go cache: %w", err)
+		log.Printf("Error running bent: %v", bentErr)
+	}
+	if mcfg.runSuite("sweet") {
+		if os.Getenv("GO_BUILDER_NAME") != "" {
+			// On a builder, clean the Go cache in between bent and Sweet.
+			// The build cache can end up using a large portion of the builder's
+			// disk space (~60%), making Sweet run out and fail. Generally speaking
+			// we don't need the build cache because we're going to be doing every
+			// build exactly once from scratch (excluding build benchmarks, which
+			// arrange for a cacheless build their own way). However, we don't want
+			// to do this on a regular development machine because we might want to
+			// run benchmarks with the same toolchain again.
+			//
+			// Note that we only need to clean the cache with one toolchain because
+			// the build cache is shared.
+			if err := cleanGoCache(tcs[0]); err != nil {
+				return fmt.Errorf("failed to clean Go cache: %w", err)
+			}
+		}
+		if err := sweet(tcs, pgo, mcfg); err != nil {
+			pass = false
+			log.Printf("Error running sweet: %v", err)
 		}
 	}
-	if err := sweet(tcs, pgo); err != nil {
-		pass = false
-		log.Printf("Error running sweet: %v", err)
+	if race {
+		if err := goTestRace(tcs, pgo); err != nil {
+			pass = false
+			log.Printf("Error running race-enabled Go tests: %v", err)
+		}
 	}
 	if !pass {
 		return fmt.Errorf("benchmarks failed")
@@ -107,19 +163,38 @@ func run(tcs []*toolchain, pgo bool) error {
 }
 
 func cleanGoCache(tc *toolchain) error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-	if err := tc.Go.Do(wd, "clean", "-cache"); err != nil {
+	if err := tc.Go.Do(workDir, "clean", "-cache"); err != nil {
 		return fmt.Errorf("toolchain %s: %w", tc.Name, err)
 	}
 	return nil
 }
 
 func main() {
+	// bent runs parts of itself through this executable.
+	bentlib.HandleInternal()
+
 	flag.Parse()
 
+	if *helpConfig {
+		fmt.Print(configHelp)
+		return
+	}
+	var err error
+	workDir, err = os.Getwd()
+	if err != nil {
+		log.Fatalf("Failed to get the current directory: %v", err)
+	}
+	mcfg, err := loadMachineConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load machine configuration: %v", err)
+	}
+	if err := mcfg.pinCPUs(); err != nil {
+		log.Fatalf("Failed to pin benchmarks to CPUs: %v", err)
+	}
+	if mcfg.CPUs != "" {
+		log.Printf("Benchmarks pinned to CPUs %s", mcfg.CPUs)
+	}
+
 	if *wait {
 		// We may be on a freshly booted VM. Wait for boot tasks to
 		// complete before continuing.
@@ -131,7 +206,6 @@ func main() {
 	// Find the toolchain under test.
 	gorootExperiment := *gorootExperiment
 	if gorootExperiment == "" {
-		var err error
 		gorootExperiment, err = determineGOROOT()
 		if err != nil {
 			log.Fatalf("Unable to determine GOROOT: %v", err)
@@ -148,6 +222,15 @@ func main() {
 		toolchains = append(toolchains, toolchainFromGOROOT("baseline", gorootBaseline))
 	}
 
+	// Save everything printed from here on, if it's to be uploaded.
+	var stopCapture func() []byte
+	if len(mcfg.Upload) != 0 {
+		stopCapture, err = captureStdout()
+		if err != nil {
+			log.Fatalf("Failed to capture results for upload: %v", err)
+		}
+	}
+
 	// Determine the repository we are testing. Defaults to 'go' because
 	// old versions of the coordinator don't specify the repository, but
 	// also only test go.
@@ -191,17 +274,29 @@ func main() {
 
 	fmt.Printf("runstamp: %s\n", time.Now().In(time.UTC).Format(time.RFC3339Nano))
 
+	var benchErr error
 	if repository != "go" {
 		toolchain := toolchainFromGOROOT("baseline", gorootBaseline)
-		if err := goTestSubrepo(toolchain, repository, subRepoBaseline, subRepoExperiment); err != nil {
-			log.Printf("Error running subrepo tests: %v", err)
-			log.Print("FAIL")
-			os.Exit(1)
+		benchErr = goTestSubrepo(toolchain, repository, subRepoBaseline, subRepoExperiment)
+		if benchErr != nil {
+			log.Printf("Error running subrepo tests: %v", benchErr)
 		}
-		return
+	} else {
+		// Run benchmarks against the toolchains.
+		benchErr = run(toolchains, *pgo, *race, mcfg)
 	}
-	// Run benchmarks against the toolchains.
-	if err := run(toolchains, *pgo); err != nil {
+
+	// Upload whatever results we have, even if some benchmarks failed.
+	if stopCapture != nil {
+		results := stopCapture()
+		for _, url := range mcfg.Upload {
+			if err := upload(url, results); err != nil {
+				log.Printf("Error uploading results: %v", err)
+			}
+		}
+	}
+
+	if benchErr != nil {
 		log.Print("FAIL")
 		os.Exit(1)
 	}
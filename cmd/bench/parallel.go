@@ -0,0 +1,137 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+
+	bentlib "golang.org/x/benchmarks/cmd/internal/bent"
+)
+
+const (
+	// minParallelCPUs is the fewest CPUs each suite gets when the Go test
+	// benchmarks and bent run at the same time. With fewer, running them
+	// one after the other is better.
+	minParallelCPUs = 4
+
+	// defaultParallelGiB is the memory that must be available for
+	// the Go test benchmarks and bent to run at the same time, if the
+	// machine configuration doesn't say.
+	defaultParallelGiB = 16
+)
+
+// parallelCPUs returns disjoint sets of CPUs, on separate cores, for the
+// Go test benchmarks and bent to run on at the same time, if the machine
+// configuration asks for it and the machine has the CPUs and memory.
+// Otherwise it returns ok == false, having logged why not.
+func parallelCPUs(mcfg *machineConfig) (gotestCPUs, bentCPUs string, ok bool) {
+	if !mcfg.Parallel {
+		return "", "", false
+	}
+	serial := func(format string, args ...any) (string, string, bool) {
+		log.Printf("Running the Go test benchmarks and bent one after the other: "+format, args...)
+		return "", "", false
+	}
+	// bent puts its runs in a cgroup cpuset of their own, which it
+	// needs to be root to create.
+	if os.Geteuid() != 0 {
+		return serial("bent needs to run as root to use a cpuset")
+	}
+	spec := mcfg.CPUs
+	if spec == "" {
+		var err error
+		if spec, err = onlineCPUs(); err != nil {
+			return serial("%v", err)
+		}
+	}
+	want := mcfg.ParallelGiB
+	if want == 0 {
+		want = defaultParallelGiB
+	}
+	avail, err := availableMemory()
+	if err != nil {
+		return serial("%v", err)
+	}
+	if avail < uint64(want)<<30 {
+		return serial("%.1f GiB of memory available is less than %d GiB", float64(avail)/(1<<30), want)
+	}
+	gotestCPUs, bentCPUs, err = splitCPUs(spec, threadSiblings)
+	if err != nil {
+		return serial("%v", err)
+	}
+	return gotestCPUs, bentCPUs, true
+}
+
+// splitCPUs splits the CPUs in spec, in the kernel's list format, into two
+// halves, keeping the hardware threads of each core, as given by siblings,
+// on the same side, so that the two suites don't share any cores.
+func splitCPUs(spec string, siblings func(cpu int) ([]int, error)) (string, string, error) {
+	cpus, err := bentlib.ParseCPUList(spec)
+	if err != nil {
+		return "", "", err
+	}
+	// Group the CPUs by core, in order of their first CPU.
+	var cores [][]int
+	seen := make(map[int]bool)
+	for _, c := range cpus {
+		if seen[c] {
+			continue
+		}
+		sib, err := siblings(c)
+		if err != nil {
+			return "", "", err
+		}
+		core := []int{c}
+		for _, s := range sib {
+			if s != c && slices.Contains(cpus, s) {
+				core = append(core, s)
+			}
+		}
+		for _, s := range core {
+			seen[s] = true
+		}
+		cores = append(cores, core)
+	}
+	var first, second []int
+	for _, core := range cores {
+		if len(first) < len(cpus)/2 {
+			first = append(first, core...)
+		} else {
+			second = append(second, core...)
+		}
+	}
+	if len(first) < minParallelCPUs || len(second) < minParallelCPUs {
+		return "", "", fmt.Errorf("%d CPUs on %d cores don't split into two sets of %d", len(cpus), len(cores), minParallelCPUs)
+	}
+	slices.Sort(first)
+	slices.Sort(second)
+	return bentlib.FormatCPUList(first), bentlib.FormatCPUList(second), nil
+}
+
+// goTestAndBent runs the Go test benchmarks and bent at the same time,
+// each on its own CPUs. Bent writes to standard output as it runs, and the
+// output of the Go test benchmarks follows once both are done, so that the
+// output is the same as if they'd run one after the other.
+func goTestAndBent(tcs []*toolchain, gotestCPUs, bentCPUs string) (gotestErr, bentErr error) {
+	log.Printf("Running the Go test benchmarks on CPUs %s and bent on CPUs %s", gotestCPUs, bentCPUs)
+	var gotestOut bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		gotestErr = goTestOn(tcs, &gotestOut, gotestCPUs)
+	}()
+	bentErr = bentOn(tcs, bentCPUs)
+	wg.Wait()
+	if _, err := os.Stdout.Write(gotestOut.Bytes()); err != nil && gotestErr == nil {
+		gotestErr = fmt.Errorf("writing Go test benchmark output: %w", err)
+	}
+	return gotestErr, bentErr
+}
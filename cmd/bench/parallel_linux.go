@@ -0,0 +1,64 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	bentlib "golang.org/x/benchmarks/cmd/internal/bent"
+)
+
+// onlineCPUs returns the online CPUs, in the kernel's list format.
+func onlineCPUs() (string, error) {
+	b, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return "", fmt.Errorf("error reading online CPUs: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// threadSiblings returns the CPUs that are hardware threads of the same
+// core as cpu, including cpu itself.
+func threadSiblings(cpu int) ([]int, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/topology/thread_siblings_list", cpu))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the topology of CPU %d: %w", cpu, err)
+	}
+	return bentlib.ParseCPUList(string(b))
+}
+
+// availableMemory returns the kernel's estimate of how much memory, in
+// bytes, is available for starting new programs without swapping.
+func availableMemory() (uint64, error) {
+	b, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("error reading /proc/meminfo: %w", err)
+	}
+	return parseMemAvailable(b)
+}
+
+// parseMemAvailable returns MemAvailable, in bytes, from the contents of
+// /proc/meminfo.
+func parseMemAvailable(meminfo []byte) (uint64, error) {
+	s := bufio.NewScanner(bytes.NewReader(meminfo))
+	for s.Scan() {
+		// For example, "MemAvailable:   12345678 kB".
+		f := strings.Fields(s.Text())
+		if len(f) != 3 || f[0] != "MemAvailable:" || f[2] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("malformed MemAvailable %q: %v", f[1], err)
+		}
+		return kb << 10, nil
+	}
+	return 0, fmt.Errorf("no MemAvailable in /proc/meminfo")
+}
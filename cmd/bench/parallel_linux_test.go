@@ -0,0 +1,35 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestAvailableMemory(t *testing.T) {
+	if avail, err := availableMemory(); err != nil || avail == 0 {
+		t.Errorf("availableMemory() = %d, %v; want some memory", avail, err)
+	}
+
+	for _, tc := range []struct {
+		meminfo string
+		want    uint64
+		err     bool
+	}{
+		{"MemTotal:       32768000 kB\nMemFree:         1024000 kB\nMemAvailable:   16384000 kB\nBuffers:          102400 kB\n", 16384000 << 10, false},
+		{"MemAvailable:          0 kB\n", 0, false},
+		{"MemTotal:       32768000 kB\nMemFree:         1024000 kB\n", 0, true},
+		{"MemAvailable:   lots kB\n", 0, true},
+	} {
+		got, err := parseMemAvailable([]byte(tc.meminfo))
+		if tc.err {
+			if err == nil {
+				t.Errorf("parseMemAvailable(%q) = %d, want error", tc.meminfo, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("parseMemAvailable(%q) = %d, %v; want %d", tc.meminfo, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errParallelLinuxOnly = errors.New("running suites in parallel is only implemented for Linux")
+
+func onlineCPUs() (string, error) {
+	return "", errParallelLinuxOnly
+}
+
+func availableMemory() (uint64, error) {
+	return 0, errParallelLinuxOnly
+}
+
+func threadSiblings(cpu int) ([]int, error) {
+	return nil, errParallelLinuxOnly
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParallelCPUs(t *testing.T) {
+	if _, _, ok := parallelCPUs(&machineConfig{}); ok {
+		t.Errorf("parallelCPUs without parallel in the machine configuration returned ok")
+	}
+}
+
+func TestSplitCPUs(t *testing.T) {
+	// smt returns the siblings of a machine with n CPUs and two hardware
+	// threads per core, numbered as Linux usually does: cpu i's sibling
+	// is cpu i+n/2.
+	smt := func(n int) func(int) ([]int, error) {
+		return func(cpu int) ([]int, error) {
+			if cpu >= n {
+				return nil, fmt.Errorf("no CPU %d", cpu)
+			}
+			return []int{cpu % (n / 2), cpu%(n/2) + n/2}, nil
+		}
+	}
+	noSMT := func(cpu int) ([]int, error) { return []int{cpu}, nil }
+	adjacent := func(cpu int) ([]int, error) { return []int{cpu &^ 1, cpu | 1}, nil }
+
+	for _, tc := range []struct {
+		spec          string
+		siblings      func(int) ([]int, error)
+		first, second string
+		err           bool
+	}{
+		{"0-7", noSMT, "0-3", "4-7", false},
+		{"0-15", smt(16), "0-3,8-11", "4-7,12-15", false},
+		{"0-15", adjacent, "0-7", "8-15", false},
+		{"2-15", smt(16), "2-5,10-13", "6-9,14-15", false},
+		{"1-7,9-15", smt(16), "1-4,9-12", "5-7,13-15", false},
+		{"0-19", smt(20), "0-4,10-14", "5-9,15-19", false},
+		{"0-6", noSMT, "", "", true},
+		{"0-31", smt(16), "", "", true},
+		{"bogus", noSMT, "", "", true},
+	} {
+		first, second, err := splitCPUs(tc.spec, tc.siblings)
+		if tc.err {
+			if err == nil {
+				t.Errorf("splitCPUs(%q) = %q, %q; want error", tc.spec, first, second)
+			}
+			continue
+		}
+		if err != nil || first != tc.first || second != tc.second {
+			t.Errorf("splitCPUs(%q) = %q, %q, %v; want %q, %q", tc.spec, first, second, err, tc.first, tc.second)
+		}
+	}
+}
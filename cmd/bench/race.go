@@ -0,0 +1,45 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// raceBenchmarks are the packages of Go test benchmarks that are run again
+// built with -race. They're a small subset, chosen to exercise the race
+// detector's instrumentation of memory accesses, synchronization and
+// goroutine creation without running for too long at its slowdown.
+var raceBenchmarks = []string{
+	"golang.org/x/benchmarks/json",
+	"golang.org/x/benchmarks/http",
+	"golang.org/x/benchmarks/garbage",
+}
+
+// goTestRace runs raceBenchmarks built with -race under each toolchain.
+// Their results are tagged "race: on", so that regressions in the race
+// detector's overhead show up separately from the results of the same
+// benchmarks without it.
+func goTestRace(tcs []*toolchain, pgo bool) error {
+	if pgo {
+		log.Printf("Skipping race-enabled Go test benchmarks (PGO not supported)")
+		return nil
+	}
+	// This runs after the other suites, so reset the tags they may have
+	// left set. The go test output sets its own pkg tag.
+	fmt.Printf("shortname:\n")
+	fmt.Printf("pgo: off\n")
+	fmt.Printf("race: on\n")
+	for _, tc := range tcs {
+		log.Printf("Running race-enabled Go test benchmarks for %s", tc.Name)
+		fmt.Printf("toolchain: %s\n", tc.Name)
+		args := append([]string{"test", "-race", "-v", "-run=none", "-short", "-bench=.", "-count=6"}, raceBenchmarks...)
+		if err := tc.Do("", args...); err != nil {
+			return fmt.Errorf("error running race-enabled gotest with toolchain %s: %w", tc.Name, err)
+		}
+	}
+	return nil
+}
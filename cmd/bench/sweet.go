@@ -12,17 +12,23 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
 )
 
-func writeSweetConfiguration(filename string, tcs []*toolchain) error {
+func writeSweetConfiguration(filename string, tcs []*toolchain, mcfg *machineConfig) error {
 	var cfg common.ConfigFile
 	for _, tc := range tcs {
-		cfg.Configs = append(cfg.Configs, &common.Config{
+		c := &common.Config{
 			Name:   tc.Name,
 			GoRoot: tc.GOROOT(),
-		})
+		}
+		if mcfg.Perf {
+			c.Diagnostics.Set(diagnostics.Config{Type: diagnostics.Perf, Flags: mcfg.PerfFlags})
+		}
+		cfg.Configs = append(cfg.Configs, c)
 	}
 	f, err := os.Create(filename)
 	if err != nil {
@@ -39,7 +45,7 @@ func writeSweetConfiguration(filename string, tcs []*toolchain) error {
 	return nil
 }
 
-func sweet(tcs []*toolchain, pgo bool) (err error) {
+func sweet(tcs []*toolchain, pgo bool, mcfg *machineConfig) (err error) {
 	tmpDir, err := os.MkdirTemp("", "go-sweet")
 	if err != nil {
 		return fmt.Errorf("error creating temporary directory: %w", err)
@@ -94,7 +100,7 @@ func sweet(tcs []*toolchain, pgo bool) (err error) {
 	}
 
 	confFile := filepath.Join(tmpDir, "config.toml")
-	if err := writeSweetConfiguration(confFile, tcs); err != nil {
+	if err := writeSweetConfiguration(confFile, tcs, mcfg); err != nil {
 		return fmt.Errorf("error writing configuration: %w", err)
 	}
 
@@ -102,6 +108,12 @@ func sweet(tcs []*toolchain, pgo bool) (err error) {
 
 	// Finally we can actually run the benchmarks.
 	resultsDir := filepath.Join(tmpDir, "results")
+	if mcfg.ProfileDir != "" {
+		// Keep the results, and any profiles collected alongside them,
+		// in a separate directory for each run.
+		resultsDir = filepath.Join(mcfg.ProfileDir, time.Now().UTC().Format("20060102T150405Z"))
+		log.Printf("Sweet results directory: %s", resultsDir)
+	}
 	workDir := filepath.Join(tmpDir, "work")
 	sweetRunArgs := []string{
 		"run",
@@ -158,6 +170,12 @@ func sweet(tcs []*toolchain, pgo bool) (err error) {
 		if err != nil {
 			return fmt.Errorf("searching for results for %s in %s: %v", tc.Name, resultsDir, err)
 		}
+		// Include build time and binary size results.
+		buildMatches, err := filepath.Glob(filepath.Join(resultsDir, "*", fmt.Sprintf("%s.build.txt", tc.Name)))
+		if err != nil {
+			return fmt.Errorf("searching for build results for %s in %s: %v", tc.Name, resultsDir, err)
+		}
+		matches = append(matches, buildMatches...)
 		fmt.Printf("toolchain: %s\n", tc.Name)
 		for _, match := range matches {
 			if err := dumpResults(match); err != nil {
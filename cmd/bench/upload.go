@@ -0,0 +1,52 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// captureStdout arranges for everything written to standard output, both by
+// bench and by the benchmarks it runs, to also be saved in memory. The
+// returned function restores standard output and returns what was written.
+func captureStdout() (func() []byte, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(io.MultiWriter(orig, &buf), r)
+	}()
+	return func() []byte {
+		w.Close()
+		<-done
+		r.Close()
+		os.Stdout = orig
+		return buf.Bytes()
+	}, nil
+}
+
+// upload POSTs the benchmark output in results to url.
+func upload(url string, results []byte) error {
+	resp, err := http.Post(url, "text/plain; charset=utf-8", bytes.NewReader(results))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("uploading to %s: %s: %s", url, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
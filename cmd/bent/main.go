@@ -0,0 +1,96 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Bent gets, builds and runs benchmarks from a variety of repositories in
+// a variety of configurations; see README.md. It is a command-line
+// interface to golang.org/x/benchmarks/cmd/internal/bent.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/benchmarks/cmd/internal/bent"
+)
+
+func main() {
+	bent.HandleInternal()
+
+	opts := bent.DefaultOptions()
+	opts.AddFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr,
+			`
+%s obtains the benchmarks/tests listed in %s and
+compiles and runs them according to the flags and environment
+variables supplied in %s.
+
+Specifying "-a" will pass "-a" to test compilations, but normally this
+should not be needed and only slows down builds; -a with a number that
+is not 1 can be used for benchmarking builds of the tests themselves.
+(Don't forget to specify "all=..." for GCFLAGS if you want those
+applied to the entire build.)
+
+Both of these files can be changed with the -B and -C flags; the full
+suite of benchmarks in benchmarks-all.toml is somewhat time-consuming.
+Suites.toml contains the known benchmarks, their versions, and certain
+necessary build or run flags.
+
+Running with the -l flag will list all the available tests and
+benchmarks for the given benchmark and configuration files.
+
+-catalog=markdown or -catalog=json prints a catalog of all the known
+benchmarks, built into bent, with their repos, versions, benchmark
+regexps for each of the benchmark files, and tags.
+
+By default benchmarks are run, not tests.  -T runs tests instead.
+
+To run tests or benchmnarks in a docker sandbox, specify -sandbox; if
+the host OS is not linux this will exclude some benchmarks that cannot
+be cross-compiled.
+
+-R and -G help with timing noise studies; -R builds a binary for each
+index (builds can be parameterised by BENT_I) and -G groups benchmark
+runs together so that they experience most-similar platform noise
+(i.e., at a nearby time).  -R > 0 will set each configurations blank
+LdFlags to "-randlayout=0x${BENT_K}a${BENT_I}".  Bent supplies BENT_I,
+setting BENT_K allows runs with different sets of random link orders
+
+On Linux, -cpuset runs the benchmarks in a dedicated cgroup cpuset,
+away from CPU 0 with -cpuset=auto, and records it with the results.
+Run as root, -isolate also moves IRQs off those CPUs and sets their
+cpufreq governor to performance until the run is done.
+
+All the test binaries will appear in the subdirectory 'testbin', and
+test (benchmark) output will appear in the subdirectory 'bench' with
+the suffix '.stdout'.  The test output is grouped by configuration to
+allow easy benchmark comparisons with benchstat.  Other benchmarking
+results will also appear in 'bench'.
+`, os.Args[0], opts.BenchFile,
+			opts.ConfFile)
+	}
+
+	flag.Parse()
+
+	for i, arg := range flag.Args() {
+		if i == 0 && (arg == "-" || arg == "--") {
+			continue
+		}
+		opts.Args = append(opts.Args, arg)
+	}
+
+	res, err := bent.Run(context.Background(), opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(bent.ExitCode(err))
+	}
+	if res.MaxRC > 0 {
+		os.Exit(res.MaxRC)
+	}
+}
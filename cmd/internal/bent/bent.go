@@ -2,14 +2,19 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+// Package bent gets, builds and runs benchmarks from a variety of
+// repositories in a variety of configurations, collecting their results;
+// see cmd/bent for the details. Run does everything the bent command does,
+// so that other programs can run benchmarks with bent without running it
+// as a command.
+package bent
 
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"embed"
 	"errors"
-	"flag"
 	"fmt"
 	"io/fs"
 	"math/rand"
@@ -21,6 +26,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/benchmarks/sweet/common/fileutil"
@@ -45,10 +51,14 @@ type Benchmark struct {
 	// e.g. benchmark may run as ConfigWrapper ConfigArg BenchWrapper BenchArg ActualBenchmark
 	NotSandboxed bool     // True if this benchmark cannot or should not be run in a container.
 	Disabled     bool     // True if this benchmark is temporarily disabled.
-	RunDir       string   // Parent directory of testdata.
+	RunDir       string   // Parent directory of testdata; each unsandboxed run gets a fresh copy of it (see newRunDir).
 	ExtraFiles   []string // other directories expected for running tests/benchmarks
 	BuildDir     string   // Location of go.mod for this benchmark; download here, go test -c here.
 	Version      string   // To pin a benchmark at a version.
+	Timeout      string   // Kill runs that take longer than this, e.g. "30m" ("0" means never); default is derived from earlier runs.
+	Warmup       int      // Number of unrecorded runs of the test binary before the first measured run in each configuration.
+	timeout      time.Duration
+	runFiles     []string // Testdata and ExtraFiles copied into RunDir, relative to it.
 }
 
 type Suite struct {
@@ -63,26 +73,48 @@ type Todo struct {
 
 var verbose counterFlag
 
-var benchFile = "benchmarks-50.toml" // default list of benchmarks
-var confFile = "configurations.toml" // default list of configurations
-var suiteFile = "suites.toml"        // default list of suites
-var container = ""
-var N = 1 // benchmark repeat count
-var R = 0 // randomized build/benchmark repeat count
-var groupRuns = false
-var list = false
-var initialize = false
-var test = false
-var force = false
-var requireSandbox = false
-var getOnly = false
-var runContainer = ""       // if nonempty, skip builds and use existing named container (or binaries if -U )
-var wikiTable = false       // emit the tests in a form usable in a wiki table
+// The settings of the current Run; see Options for what they mean, and
+// DefaultOptions for their defaults.
+
+var benchFile string          // list of benchmarks
+var confFile string           // list of configurations
+var suiteFile = "suites.toml" // default list of suites
+var container string          // Docker container for sandboxed runs.
+var N int                     // benchmark repeat count
+var R int                     // randomized build/benchmark repeat count
+var groupRuns bool
+var list bool
+var initialize bool
+var test bool
+var force bool
+var requireSandbox bool
+var getOnly bool
+var runContainer string     // if nonempty, skip builds and use existing named container (or binaries if -U )
+var rebuild bool            // if true, build even if build inputs are unchanged since the last run
+var catalogFormat string    // if "markdown" or "json", print the catalog of benchmarks in that format and exit
 var explicitAll counterFlag // Include "-a" on "go test -c" test build ; repeating flag causes multiple rebuilds, useful for build benchmarking.
-var shuffle = 2             // Dimensionality of (build) shuffling; 0 = none, 1 = per-benchmark, configuration ordering, 2 = bench, config pairs, 3 = across repetitions.
-var reportBuildTime = true
-var experiment = false    // Don't reset go.mod, for testing purposes
-var minGoVersion = "1.22" // This is the release the toolchain started caring about versions of Go that are too new.
+var shuffle int             // Dimensionality of (build) shuffling; 0 = none, 1 = per-benchmark, configuration ordering, 2 = bench, config pairs, 3 = across repetitions.
+var reportBuildTime bool
+var experiment bool          // Don't reset go.mod, for testing purposes
+var minGoVersion string      // This is the release the toolchain started caring about versions of Go that are too new.
+var sweeps sweepFlag         // Run-time environment variables to sweep across, expanding each configuration.
+var flagSweeps flagSweepFlag // Build flags to sweep across, expanding each configuration.
+
+var extraFiles []string      // Additional files of suites and benchmarks, merged with the standard ones.
+var runTimeout time.Duration // Default per-run timeout; 0 derives it from earlier runs, negative disables it.
+var timeBudget time.Duration // If positive, skip benchmarks so the runs are estimated to fit in this much time.
+var changedPkgs []string     // If nonempty, packages changed by a CL; only run the benchmarks that depend on them.
+var exportFormat string      // If "csv" or "tsv", also write all the results of the run to one file in that format.
+var baselineRelease string   // If nonempty, a Go release to add a configuration for, to compare the others against.
+var publishDest string       // If nonempty, a directory or gs:// URL to publish the results of the run to.
+var cpusetSpec string        // If nonempty, the CPUs to run benchmarks on, in a cpuset of their own.
+var unshareSpec string       // If nonempty, the namespaces each unsandboxed run gets of its own, e.g. "net,pid".
+var isolate bool             // If true, move IRQs off the -cpuset CPUs and set their governor to performance for the run.
+var keepRunDirs bool         // If true, leave the working directory of each unsandboxed run behind, for debugging.
+var cpusetParent string
+var runCpuset *cpuset         // The cpuset benchmarks run in, if any.
+var runIsolation *isolation   // What -isolate changed, to restore after the run.
+var runNamespaces *namespaces // The namespaces each unsandboxed run gets, if any.
 
 //go:embed scripts/*
 var scripts embed.FS
@@ -115,7 +147,12 @@ type triple struct {
 }
 
 // To disambiguate repeated test runs in the same directory.
-var runstamp = strings.Replace(strings.Replace(time.Now().UTC().Format("2006-01-02T15:04:05"), "-", "", -1), ":", "", -1)
+var runstamp string
+
+// newRunstamp returns a runstamp for a run starting now.
+func newRunstamp() string {
+	return strings.Replace(strings.Replace(time.Now().UTC().Format("2006-01-02T15:04:05"), "-", "", -1), ":", "", -1)
+}
 
 func cleanup(gopath string) {
 	bin := path.Join(gopath, "bin")
@@ -125,160 +162,104 @@ func cleanup(gopath string) {
 	os.RemoveAll(bin)
 }
 
-func main() {
-
-	var benchmarksString, configurationsString, stampLog string
-
-	flag.IntVar(&N, "N", N, "benchmark/test repeat count")
-	flag.IntVar(&R, "R", R, "randomize binary layouts to reduce alignment artifacts (subsumes and is incompatible with -a, -N)")
-	flag.BoolVar(&groupRuns, "G", groupRuns, "group runs by benchmark (give them similar platform noise)")
-
-	flag.Var(&explicitAll, "a", "add '-a' flag to 'go test -c' to demand full recompile. Repeat or assign a value for repeat builds for benchmarking")
-	flag.IntVar(&shuffle, "s", shuffle, "dimensionality of (build) shuffling (0-3), 0 = none, 1 = per-benchmark, configuration ordering, 2 = bench, config pairs, 3 = across repetitions.")
-
-	flag.StringVar(&benchmarksString, "b", "", "comma-separated list of test/benchmark names (default is all)")
-	flag.StringVar(&benchFile, "B", benchFile, "name of file containing benchmarks to run")
-
-	flag.StringVar(&configurationsString, "c", "", "comma-separated list of test/benchmark configurations (default is all)")
-	flag.StringVar(&confFile, "C", confFile, "name of file describing configurations")
-
-	flag.BoolVar(&requireSandbox, "sandbox", requireSandbox, "require Docker sandbox to run tests/benchmarks (& exclude unsandboxable tests/benchmarks)")
-
-	flag.BoolVar(&getOnly, "g", getOnly, "get tests/benchmarks and dependencies, do not build or run")
-	flag.StringVar(&runContainer, "r", runContainer, "skip get and build, go directly to run, using specified container (any non-empty string will do for unsandboxed execution)")
-
-	flag.StringVar(&stampLog, "L", stampLog, "name of log file to which runstamps are appended")
-
-	flag.BoolVar(&list, "l", list, "list available benchmarks and configurations, then exit")
-	flag.BoolVar(&force, "f", force, "force run past some of the consistency checks (gopath/{pkg,bin} in particular)")
-	flag.BoolVar(&initialize, "I", initialize, "initialize a directory for running tests ((re)creates Dockerfile, (re)copies in benchmark and configuration files)")
-	flag.BoolVar(&test, "T", test, "run tests instead of benchmarks")
-
-	flag.BoolVar(&wikiTable, "W", wikiTable, "print benchmark info for a wiki table")
-	flag.BoolVar(&experiment, "X", experiment, "for experimental changes to 3rd party software, do not reset build/*/go.mod")
-
-	flag.BoolVar(&reportBuildTime, "report-build-time", reportBuildTime, "report build real/CPU time as benchmark results")
-
-	flag.Var(&verbose, "v", "print commands and other information (more -v = print more details)")
-
-	flag.StringVar(&minGoVersion, "m", minGoVersion, "minimum Go version across all toolchains used for benchmarking")
+// runMu serializes Runs, which keep their settings in package state.
+var runMu sync.Mutex
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr,
-			`
-%s obtains the benchmarks/tests listed in %s and
-compiles and runs them according to the flags and environment
-variables supplied in %s.
-
-Specifying "-a" will pass "-a" to test compilations, but normally this
-should not be needed and only slows down builds; -a with a number that
-is not 1 can be used for benchmarking builds of the tests themselves.
-(Don't forget to specify "all=..." for GCFLAGS if you want those
-applied to the entire build.)
-
-Both of these files can be changed with the -B and -C flags; the full
-suite of benchmarks in benchmarks-all.toml is somewhat time-consuming.
-Suites.toml contains the known benchmarks, their versions, and certain
-necessary build or run flags.
-
-Running with the -l flag will list all the available tests and
-benchmarks for the given benchmark and configuration files.
-
-By default benchmarks are run, not tests.  -T runs tests instead.
-
-To run tests or benchmnarks in a docker sandbox, specify -sandbox; if
-the host OS is not linux this will exclude some benchmarks that cannot
-be cross-compiled.
+// Run gets, builds and runs benchmarks as opts says, in the current
+// directory, as the bent command does, and returns where their results
+// are. It prints progress and diagnostics to standard output, as the
+// command does. If ctx is done, Run stops before starting another run of
+// a benchmark; runs already started are allowed to finish.
+//
+// Calls to Run are serialized. Outside a sandbox, Run uses the current
+// executable to run some parts of itself, so programs that call it must
+// call HandleInternal first thing in main.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	if err := opts.apply(); err != nil {
+		return nil, err
+	}
+	return run(ctx, opts)
+}
 
--R and -G help with timing noise studies; -R builds a binary for each
-index (builds can be parameterised by BENT_I) and -G groups benchmark
-runs together so that they experience most-similar platform noise
-(i.e., at a nearby time).  -R > 0 will set each configurations blank
-LdFlags to "-randlayout=0x${BENT_K}a${BENT_I}".  Bent supplies BENT_I,
-setting BENT_K allows runs with different sets of random link orders
+// run is Run, once the settings in opts are in place.
+func run(ctx context.Context, opts Options) (*Result, error) {
+	res := &Result{Runstamp: runstamp}
 
-All the test binaries will appear in the subdirectory 'testbin', and
-test (benchmark) output will appear in the subdirectory 'bench' with
-the suffix '.stdout'.  The test output is grouped by configuration to
-allow easy benchmark comparisons with benchstat.  Other benchmarking
-results will also appear in 'bench'.
-`, os.Args[0], benchFile,
-			confFile)
+	if catalogFormat != "" {
+		entries, err := catalog(configs)
+		if err != nil {
+			return nil, err
+		}
+		return res, writeCatalog(os.Stdout, entries, catalogFormat)
 	}
 
-	flag.Parse()
-
-	if R > 0 {
-		if R > 1000000 {
-			fmt.Println("R must be less than or equal to 1,000,000 (1e6)")
-			os.Exit(1)
-		}
-		if explicitAll != 0 {
-			fmt.Println("Warning: -R overrides -a, using -R value")
+	if postProcess != "" {
+		// Find out now, not after hours of runs, if it can't be run.
+		args := strings.Fields(postProcess)
+		if len(args) == 0 {
+			return nil, errors.New("-post-process command is empty")
 		}
-		if N != 1 {
-			fmt.Println("Warning: -R overrides -N, using -R value")
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return nil, fmt.Errorf("Cannot post-process results, %v", err)
 		}
-		if explicitAll < 0 {
-			// preserve the sign; this used to be a thing, it might be again
-			explicitAll = -counterFlag(R)
-		} else {
-			explicitAll = counterFlag(R)
-		}
-		N = R
 	}
-
-	if N > 1000000 {
-		fmt.Println("N must be less than or equal to 1,000,000 (1e6)")
-		os.Exit(1)
+	if publishDest != "" {
+		if err := checkPublishDest(publishDest); err != nil {
+			return nil, fmt.Errorf("Cannot publish results, %v", err)
+		}
 	}
 
 	if requireSandbox {
 		_, errDocker := exec.LookPath("docker")
 		if errDocker != nil {
-			fmt.Println("Sandboxing benchmarks requires the docker command")
-			os.Exit(1)
+			return nil, errors.New("Sandboxing benchmarks requires the docker command")
 		}
 	}
 
 	// Make sure our filesystem is in good shape.
 	if err := checkAndSetUpFileSystem(initialize); err != nil {
-		fmt.Printf("%v", err)
-		os.Exit(1)
+		return nil, err
+	}
+	if initialize {
+		return res, nil
 	}
 
 	var err error
 	// Create any directories we need.
 	dirs, err = createDirectories()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	todo := &Todo{}
 	blobB, err := os.ReadFile(benchFile)
 	if err != nil {
-		fmt.Printf("There was an error opening or reading file %s: %v\n", benchFile, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("There was an error opening or reading file %s: %v", benchFile, err)
 	}
 	blobC, err := os.ReadFile(confFile)
 	if err != nil {
-		fmt.Printf("There was an error opening or reading file %s: %v\n", confFile, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("There was an error opening or reading file %s: %v", confFile, err)
 	}
 	blobS, err := os.ReadFile(suiteFile)
 	if err != nil {
-		fmt.Printf("There was an error opening or reading file %s: %v\n", suiteFile, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("There was an error opening or reading file %s: %v", suiteFile, err)
 	}
 	blob := append(blobB, blobC...)
 	blob = append(blob, blobS...)
 	err = toml.Unmarshal(blob, todo)
 	if err != nil {
-		fmt.Printf("There was an error unmarshalling %s: %v\n", string(blob), err)
-		os.Exit(1)
+		return nil, fmt.Errorf("There was an error unmarshalling %s: %v", string(blob), err)
+	}
+
+	// Merge in any private suites and benchmarks.
+	extensions, err := extensionFiles(extraFiles)
+	if err == nil {
+		err = mergeExtensions(todo, extensions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("There was an error adding suites and benchmarks: %v", err)
 	}
 
 	// Copy defaults for benchmarks from suites.
@@ -305,13 +286,16 @@ results will also appear in 'bench'.
 		b := &todo.Benchmarks[i]
 		s := suites[b.Name]
 		if s == nil {
-			fmt.Printf("Benchmark %s appearing in %s is not listed in %s\n", b.Name, benchFile, suiteFile)
-			os.Exit(1)
+			return nil, fmt.Errorf("Benchmark %s appearing in %s is not listed in %s", b.Name, benchFile, suiteFile)
 		}
 		update(&b.Repo, s.Repo)
 		update(&b.Version, s.Version)
 		update(&b.Tests, s.Tests)
 		update(&b.Benchmarks, s.Benchmarks)
+		update(&b.Timeout, s.Timeout)
+		if b.Warmup == 0 {
+			b.Warmup = s.Warmup
+		}
 
 		b.Disabled = s.Disabled || b.Disabled
 		b.NotSandboxed = s.NotSandboxed || b.NotSandboxed
@@ -319,28 +303,29 @@ results will also appear in 'bench'.
 		updateFlags(&b.ExtraFiles, s.ExtraFiles)
 		updateFlags(&b.BuildFlags, s.BuildFlags)
 		updateFlags(&b.GcEnv, s.GcEnv)
-	}
 
-	var moreArgs []string
-	if flag.NArg() > 0 {
-		for i, arg := range flag.Args() {
-			if i == 0 && (arg == "-" || arg == "--") {
-				continue
+		if b.Timeout != "" {
+			b.timeout, err = time.ParseDuration(b.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("Benchmark %s has an invalid Timeout: %v", b.Name, err)
 			}
-			moreArgs = append(moreArgs, arg)
 		}
 	}
 
-	benchmarks := csToSet(benchmarksString)
-	configurations := csToSet(configurationsString)
+	moreArgs := opts.Args
+	benchmarks := toSet(opts.Benchmarks)
+	configurations := toSet(opts.Configurations)
 
-	if wikiTable {
-		for _, bench := range todo.Benchmarks {
-			s := bench.Benchmarks
-			s = strings.Replace(s, "|", "\\|", -1)
-			fmt.Printf(" | %s | | `%s` | `%s` | |\n", bench.Name, bench.Repo, s)
+	if baselineRelease != "" {
+		if !releaseRE.MatchString(baselineRelease) {
+			return nil, fmt.Errorf("-baseline-release=%s is not a Go release, such as go1.22.5", baselineRelease)
+		}
+		// Put the baseline first, so that it is listed first in results.
+		baseline := Configuration{Name: baselineRelease, Release: baselineRelease}
+		todo.Configurations = append([]Configuration{baseline}, todo.Configurations...)
+		if configurations != nil {
+			configurations[baselineRelease] = true
 		}
-		return
 	}
 
 	// Normalize configuration goroot names by ensuring they end in '/'
@@ -351,8 +336,7 @@ results will also appear in 'bench'.
 		trial := &todo.Configurations[i]
 		trial.Name = os.ExpandEnv(trial.Name)
 		if duplicates[trial.Name] {
-			fmt.Printf("Saw duplicate configuration %s at index %d\n", trial.Name, i)
-			os.Exit(1)
+			return nil, fmt.Errorf("Saw duplicate configuration %s at index %d", trial.Name, i)
 		}
 		duplicates[trial.Name] = true
 		if configurations != nil {
@@ -362,6 +346,11 @@ results will also appear in 'bench'.
 				configurations[trial.Name] = false
 			}
 		}
+		trial.Ref = os.ExpandEnv(trial.Ref)
+		trial.Release = os.ExpandEnv(trial.Release)
+		if trial.Root != "" && (trial.Ref != "" || trial.Release != "") || trial.Ref != "" && trial.Release != "" {
+			return nil, fmt.Errorf("Configuration %s has more than one of Root, Ref and Release, it should have at most one", trial.Name)
+		}
 		if root := trial.Root; len(root) != 0 {
 			// TODO(jfaller): I don't think we need this "/" anymore... investigate.
 			trial.Root = os.ExpandEnv(root) + "/"
@@ -377,13 +366,24 @@ results will also appear in 'bench'.
 		if R > 0 && trial.LdFlags == "" {
 			trial.LdFlags = "-randlayout=0x${BENT_K}a${BENT_I}"
 		}
+		for _, g := range trial.Godebug {
+			if !validGodebug(g) {
+				return nil, fmt.Errorf("Configuration %s has Godebug setting %q, want name=value[,name=value...]", trial.Name, g)
+			}
+			name := trial.godebugName(g)
+			if duplicates[name] {
+				return nil, fmt.Errorf("Godebug setting %q of configuration %s duplicates configuration name %s", g, trial.Name, name)
+			}
+			duplicates[name] = true
+		}
 	}
 	for b, v := range configurations {
 		if v {
-			fmt.Printf("Configuration %s listed after -c does not appear in %s\n", b, confFile)
-			os.Exit(1)
+			return nil, fmt.Errorf("Configuration %s listed after -c does not appear in %s", b, confFile)
 		}
 	}
+	todo.Configurations = expandFlagSweeps(todo.Configurations, flagSweeps)
+	todo.Configurations = expandSweeps(todo.Configurations, sweeps)
 
 	// Normalize benchmark names by removing any trailing '/'.
 	// Normalize Test and Benchmark specs by replacing missing value with something that won't match anything.
@@ -392,8 +392,7 @@ results will also appear in 'bench'.
 	for i, bench := range todo.Benchmarks {
 
 		if duplicates[bench.Name] {
-			fmt.Printf("Saw duplicate benchmark %s at index %d\n", bench.Name, i)
-			os.Exit(1)
+			return nil, fmt.Errorf("Saw duplicate benchmark %s at index %d", bench.Name, i)
 		}
 		duplicates[bench.Name] = true
 
@@ -441,17 +440,40 @@ results will also appear in 'bench'.
 	}
 	for b, v := range benchmarks {
 		if v {
-			fmt.Printf("Benchmark %s listed after -b does not appear in %s\n", b, benchFile)
-			os.Exit(1)
+			return nil, fmt.Errorf("Benchmark %s listed after -b does not appear in %s", b, benchFile)
+		}
+	}
+
+	quarantine, err := readQuarantine(quarantineFile)
+	if err != nil {
+		return nil, fmt.Errorf("There was an error reading the quarantine list: %v", err)
+	}
+	printQuarantine(applyQuarantine(todo, quarantine, time.Now(), benchmarks))
+
+	loadRunHistory()
+
+	// Run the core benchmarks first, so that they are done even if the
+	// rest overrun.
+	core := toSet(opts.Core)
+	for b := range core {
+		if !slices.ContainsFunc(todo.Benchmarks, func(x Benchmark) bool { return x.Name == b }) {
+			return nil, fmt.Errorf("Benchmark %s listed after -core does not appear in %s", b, benchFile)
 		}
 	}
+	slices.SortStableFunc(todo.Benchmarks, func(a, b Benchmark) int {
+		return cmpCore(core, a, b)
+	})
+	if len(changedPkgs) == 0 {
+		// Otherwise, the budget is only applied to the benchmarks
+		// -changed-pkgs selects, once they have been fetched.
+		fitTimeBudget(todo, core)
+	}
 
 	// If more verbose, print the normalized configuration.
 	if verbose > 1 {
 		buf := new(bytes.Buffer)
 		if err := toml.NewEncoder(buf).Encode(todo); err != nil {
-			fmt.Printf("There was an error encoding %v: %v\n", todo, err)
-			os.Exit(1)
+			return nil, fmt.Errorf("There was an error encoding %v: %v", todo, err)
 		}
 		fmt.Println(buf.String())
 	}
@@ -471,19 +493,21 @@ results will also appear in 'bench'.
 			if x.Root != "" {
 				s += " (goroot=" + x.Root + ")"
 			}
+			if x.Ref != "" {
+				s += " (ref=" + x.Ref + ")"
+			}
 			if x.Disabled {
 				s += " (disabled)"
 			}
 			fmt.Printf("   %s\n", s)
 		}
-		return
+		return res, nil
 	}
 
-	if stampLog != "" {
-		f, err := os.OpenFile(stampLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.ModePerm)
+	if opts.StampLog != "" {
+		f, err := os.OpenFile(opts.StampLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.ModePerm)
 		if err != nil {
-			fmt.Printf("There was an error opening %s for output, error %v\n", stampLog, err)
-			os.Exit(2)
+			return nil, exitErrorf(2, "There was an error opening %s for output, error %v", opts.StampLog, err)
 		}
 		fmt.Fprintf(f, "%s\t%v\n", runstamp, os.Args)
 		f.Close()
@@ -511,6 +535,29 @@ results will also appear in 'bench'.
 	}
 	defaultEnv = append(defaultEnv, "ROOT="+envRoot)
 
+	// Build the Go roots for configurations that ask for a Ref, and
+	// download those for configurations that ask for a Release.
+	for i := range todo.Configurations {
+		config := &todo.Configurations[i]
+		if config.Disabled {
+			continue
+		}
+		if config.Ref != "" {
+			root, err := goRootForRef(config.Ref)
+			if err != nil {
+				return nil, fmt.Errorf("Could not build Go root for configuration %s from Ref %s: %v", config.Name, config.Ref, err)
+			}
+			config.Root = root + "/"
+		}
+		if config.Release != "" {
+			root, err := goRootForRelease(config.Release)
+			if err != nil {
+				return nil, fmt.Errorf("Could not download Go root for configuration %s from Release %s: %v", config.Name, config.Release, err)
+			}
+			config.Root = root + "/"
+		}
+	}
+
 	var needSandbox bool    // true if any benchmark needs a sandbox
 	var needNotSandbox bool // true if any benchmark needs to be not sandboxed
 
@@ -521,12 +568,11 @@ results will also appear in 'bench'.
 	for i, config := range todo.Configurations {
 		if !config.Disabled { // Don't overwrite if something was disabled.
 			s := config.thingBenchName("stdout")
-			f, err := os.OpenFile(s, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+			out, err := createBenchOutput(s)
 			if err != nil {
-				fmt.Printf("There was an error opening %s for output, error %v\n", s, err)
-				os.Exit(2)
+				return nil, exitErrorf(2, "There was an error opening %s for output, error %v", s, err)
 			}
-			todo.Configurations[i].benchWriter = f
+			todo.Configurations[i].benchWriter = out
 		}
 	}
 
@@ -557,7 +603,22 @@ results will also appear in 'bench'.
 		bench.BuildDir = path.Join(dirs.build, bench.Name)
 	}
 
+	// If nothing that affects the binaries has changed since the last
+	// successful build, reuse them as if -r had been given.
+	if runContainer == "" && !rebuild && !getOnly && explicitAll == 0 {
+		if st := reusableBuild(todo); st != nil {
+			fmt.Println("Build inputs unchanged since the last run, reusing binaries (use -rebuild to force a build)")
+			runContainer = st.Container
+			if runContainer == "" {
+				runContainer = "unchanged" // any non-empty string will do when not sandboxed.
+			}
+		}
+	}
+
 	if runContainer == "" { // If not reusing binaries/container...
+		// Until this build completes, the binaries don't match any recorded state.
+		forgetBuildState()
+
 		if verbose == 0 {
 			fmt.Print("Go getting")
 		}
@@ -572,8 +633,7 @@ results will also appear in 'bench'.
 
 			// Use a separate go.mod for each benchmark, otherwise there can be conflicts.
 			if err := mkdirAsNeeded(bench.BuildDir); err != nil {
-				fmt.Printf("Couldn't create build subdirectory %s, error=%v", bench.BuildDir, err)
-				os.Exit(2)
+				return nil, exitErrorf(2, "Couldn't create build subdirectory %s, error=%v", bench.BuildDir, err)
 			}
 
 			getFiles := true
@@ -590,19 +650,16 @@ results will also appear in 'bench'.
 				goModPath := filepath.Join(bench.BuildDir, "go.mod")
 				f, err := os.Create(goModPath)
 				if err != nil {
-					fmt.Printf("Error creating go.mod: %v", err)
-					os.Exit(2)
+					return nil, exitErrorf(2, "Error creating go.mod: %v", err)
 				}
 				goMod := fmt.Sprintf(goMod, minGoVersion)
 				_, err = fmt.Fprintln(f, goMod)
 				if err != nil {
-					fmt.Printf("Error writing go.mod: %v", err)
 					f.Close()
-					os.Exit(2)
+					return nil, exitErrorf(2, "Error writing go.mod: %v", err)
 				}
 				if err := f.Close(); err != nil {
-					fmt.Printf("Error closing go.mod: %v", err)
-					os.Exit(2)
+					return nil, exitErrorf(2, "Error closing go.mod: %v", err)
 				}
 				if verbose > 0 {
 					fmt.Printf("(cd %s; cat <<EOF > %s\n%s\nEOF)\n", bench.BuildDir, "go.mod", goMod)
@@ -641,8 +698,13 @@ results will also appear in 'bench'.
 			fmt.Println()
 		}
 
+		if len(changedPkgs) > 0 {
+			selectAffected(todo, changedPkgs)
+			fitTimeBudget(todo, core)
+		}
+
 		if getOnly {
-			return
+			return res, nil
 		}
 
 		// Create build-related benchmark files
@@ -707,7 +769,7 @@ results will also appear in 'bench'.
 				}
 				cmd.Args = append(cmd.Args, "std")
 
-				s, _ := config.runBinary("", cmd, true)
+				s, _ := runBinary(config.benchWriter, "", cmd, true, 0)
 				if s != "" {
 					fmt.Println("Error running go install std, ", s)
 					config.Disabled = true
@@ -842,16 +904,12 @@ results will also appear in 'bench'.
 			output, err := cmd.Output()
 			if err != nil {
 				ee := err.(*exec.ExitError)
-				fmt.Printf("There was an error running 'docker build', stderr = %s\n", ee.Stderr)
-				os.Exit(2)
-				return
+				return nil, exitErrorf(2, "There was an error running 'docker build', stderr = %s", ee.Stderr)
 			}
 			// Docker prints stuff AFTER the container, thanks, Docker.
 			sc := bufio.NewScanner(bytes.NewReader(output))
 			if !sc.Scan() {
-				fmt.Printf("Could not scan line from '%s'\n", string(output))
-				os.Exit(2)
-				return
+				return nil, exitErrorf(2, "Could not scan line from '%s'", string(output))
 			}
 			container = strings.TrimSpace(sc.Text())
 			if verbose == 0 {
@@ -859,11 +917,35 @@ results will also appear in 'bench'.
 			}
 			fmt.Printf("Container for sandboxed bench/test runs is %s\n", container)
 		}
+
+		if len(getAndBuildFailures) == 0 {
+			saveBuildState(todo, container)
+		}
 	} else {
 		container = runContainer
 		if getOnly { // -r -g is a bit of a no-op, but that's what it implies.
-			return
+			return res, nil
+		}
+		if len(changedPkgs) > 0 {
+			selectAffected(todo, changedPkgs)
+			fitTimeBudget(todo, core)
+		}
+	}
+
+	// Now that the binaries are built, add the Godebug sub-configurations
+	// that run them.
+	todo.Configurations = expandGodebugs(todo.Configurations)
+	for i := range todo.Configurations {
+		config := &todo.Configurations[i]
+		if config.binConfig == "" || config.Disabled {
+			continue
 		}
+		s := config.thingBenchName("stdout")
+		out, err := createBenchOutput(s)
+		if err != nil {
+			return nil, exitErrorf(2, "There was an error opening %s for output, error %v", s, err)
+		}
+		config.benchWriter = out
 	}
 
 	// Initialize RunDir for benchmarks.
@@ -901,6 +983,10 @@ benchmarks_loop:
 			rundir = rundir[len(dirs.wd):]
 		}
 		bench.RunDir = bench.BuildDir
+		bench.runFiles = nil
+		if err := scrubRunDirs(bench); err != nil {
+			fmt.Printf("Error removing old run directories of %s, %v\n", bench.Name, err)
+		}
 
 		copy := func(subdir string, failIfMissing bool) bool {
 			// as necessary, make a copy of subdir
@@ -935,6 +1021,7 @@ benchmarks_loop:
 					todo.Benchmarks[i].Disabled = true
 					return true
 				}
+				bench.runFiles = append(bench.runFiles, subdir)
 				return false
 			}
 			if failIfMissing {
@@ -987,7 +1074,65 @@ benchmarks_loop:
 		fmt.Println()
 	}
 
-	var runs []*Run
+	// Check the namespaces before changing anything that would need undoing.
+	if unshareSpec != "" {
+		ns, err := parseNamespaces(unshareSpec)
+		if err == nil {
+			err = ns.check()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not set up namespaces, %v", err)
+		}
+		runNamespaces = &ns
+	}
+
+	if cpusetSpec != "" {
+		// A partition root's CPUs can't be used by any other cgroup,
+		// including the containers of sandboxed benchmarks.
+		exclusive := !slices.ContainsFunc(todo.Benchmarks, func(b Benchmark) bool {
+			return !b.Disabled && !b.NotSandboxed
+		})
+		var err error
+		runCpuset, err = newCpuset(cpusetParent, cpusetSpec, exclusive)
+		if err != nil {
+			return nil, fmt.Errorf("Could not set up cpuset, %v", err)
+		}
+		defer runCpuset.remove()
+	}
+
+	if isolate {
+		var err error
+		runIsolation, err = isolateCPUs(runCpuset.cpus())
+		if err != nil {
+			return nil, fmt.Errorf("Could not isolate CPUs, %v", err)
+		}
+		defer runIsolation.restore()
+	}
+	if runCpuset != nil || runIsolation != nil {
+		var stop func()
+		ctx, stop = cancelOnSignal(ctx)
+		defer stop()
+	}
+
+	// Record the machine's state ahead of the results to help with later triage of noisy runs.
+	ms := snapshotMachineState()
+	if runCpuset != nil {
+		runCpuset.record(ms)
+	}
+	if runIsolation != nil {
+		runIsolation.record(ms)
+	}
+	machine := ms.String()
+	if verbose > 0 {
+		fmt.Print(machine)
+	}
+	for _, config := range todo.Configurations {
+		if !config.Disabled {
+			fmt.Fprint(config.benchWriter, machine)
+		}
+	}
+
+	var runs []*benchRun
 
 	// N repetitions for each configurationm, run all the benchmarks.
 	// TODO randomize the benchmarks and configurations, like for builds.
@@ -1005,21 +1150,21 @@ benchmarks_loop:
 					continue
 				}
 
-				runs = append(runs, &Run{c, b, i})
+				runs = append(runs, &benchRun{c, b, i})
 			}
 		}
 	}
 
 	rand.Shuffle(len(runs), func(i, j int) { runs[i], runs[j] = runs[j], runs[i] })
 
-	benchTogether := func(r, s *Run) int {
+	benchTogether := func(r, s *benchRun) int {
 		if r.b.Name == s.b.Name {
 			return r.i>>1 - s.i>>1 // small numbers will not overflow.
 		}
 		return strings.Compare(r.b.Name, s.b.Name)
 	}
 
-	nTogether := func(r, s *Run) int {
+	nTogether := func(r, s *benchRun) int {
 		return r.i - s.i // small numbers will not overflow.
 	}
 
@@ -1037,8 +1182,31 @@ benchmarks_loop:
 		}
 	}
 
+	type warmKey struct {
+		c *Configuration
+		b *Benchmark
+	}
+	warmedUp := make(map[warmKey]bool)
+	// Count the runs left for each configuration, to post-process its
+	// output as soon as they're done.
+	runsLeft := make(map[*Configuration]int)
+	for _, r := range runs {
+		runsLeft[r.c]++
+	}
 	for _, r := range runs {
-		s, rc := benchOne(r.c, r.b, r.i, moreArgs)
+		if ctx.Err() != nil {
+			fmt.Printf("Stopping before %s, %v\n", r, context.Cause(ctx))
+			break
+		}
+		if k := (warmKey{r.c, r.b}); r.b.Warmup > 0 && !warmedUp[k] {
+			warmedUp[k] = true
+			for w := 0; w < r.b.Warmup; w++ {
+				if s, _ := benchOne(r.c, r.b, r.i, moreArgs, true); s != "" {
+					fmt.Printf("Warmup run failed: %s\n", s)
+				}
+			}
+		}
+		s, rc := benchOne(r.c, r.b, r.i, moreArgs, false)
 
 		if s != "" {
 			fmt.Println(s)
@@ -1047,29 +1215,80 @@ benchmarks_loop:
 		if rc > maxrc {
 			maxrc = rc
 		}
+
+		if runsLeft[r.c]--; runsLeft[r.c] == 0 && postProcess != "" {
+			if err := postProcessResults(postProcess, r.c.Name, r.c.thingBenchName("stdout")); err != nil {
+				fmt.Printf("Post-processing the results of %s failed, %v\n", r.c.Name, err)
+			}
+		}
 	}
 
-	if maxrc > 0 {
-		os.Exit(maxrc)
+	saveRunHistory()
+
+	res.Outputs = make(map[string]string)
+	for _, c := range todo.Configurations {
+		if !c.Disabled {
+			res.Outputs[c.Name] = path.Join(dirs.wd, c.thingBenchName("stdout"))
+		}
 	}
+	res.Failures, res.BuildFailures, res.MaxRC = failures, getAndBuildFailures, maxrc
+	if err := ctx.Err(); err != nil {
+		return res, context.Cause(ctx)
+	}
+
+	var extra []string // Files to publish along with the results.
+	if maxCV > 0 && N > 1 {
+		if name, err := reportUnstable(todo.Configurations); err != nil {
+			fmt.Printf("There was an error checking the variation of results, %v\n", err)
+		} else {
+			extra = append(extra, name)
+		}
+	}
+
+	if exportFormat != "" {
+		if name, err := exportResults(todo.Configurations, exportFormat); err != nil {
+			fmt.Printf("There was an error exporting results, %v\n", err)
+		} else {
+			fmt.Printf("Results exported to %s\n", name)
+			extra = append(extra, name)
+		}
+	}
+
+	if publishDest != "" {
+		if manifest, err := publishResults(publishDest, todo, ms, extra); err != nil {
+			fmt.Printf("There was an error publishing results, %v\n", err)
+		} else {
+			fmt.Printf("Results published to %s\n", manifest)
+			res.Manifest = manifest
+		}
+	}
+
+	for _, name := range extra {
+		if !filepath.IsAbs(name) {
+			name = path.Join(dirs.wd, name)
+		}
+		res.Extra = append(res.Extra, name)
+	}
+	return res, nil
 }
 
-type Run struct {
+type benchRun struct {
 	c *Configuration
 	b *Benchmark
 	i int
 }
 
-func (r *Run) String() string {
+func (r *benchRun) String() string {
 	return r.c.Name + "-" + r.b.Name + "-" + strconv.FormatInt(int64(r.i), 10)
 }
 
 // benchOne runs a single benchmarks b in configuration c at iteration i, applying moreArgs to the run.
 // it returns the output and the return code.
+// If warmup is true, the run's output is discarded rather than recorded.
 //
 // if either the configuration or benchmark is disabled, return with empty output and no change (0)
 // to the return code.
-func benchOne(c *Configuration, b *Benchmark, i int, moreArgs []string) (s string, rc int) {
+func benchOne(c *Configuration, b *Benchmark, i int, moreArgs []string, warmup bool) (s string, rc int) {
 	if c.Disabled || b.Disabled {
 		return
 	}
@@ -1077,6 +1296,25 @@ func benchOne(c *Configuration, b *Benchmark, i int, moreArgs []string) (s strin
 	root := c.Root
 
 	testBinaryName := c.benchName(b, i, R > 0)
+	timeout := runTimeoutFor(b)
+	start := time.Now()
+	defer func() {
+		if s == "" && rc == 0 && !warmup {
+			recordRun(b, time.Since(start))
+		}
+	}()
+
+	// Collect the run's output on its own, so that it ends up in one
+	// piece in the configuration's output.
+	out, err := c.benchWriter.newRun()
+	if err != nil {
+		return fmt.Sprintf("Error creating output file for run, %v", err), 1
+	}
+	defer func() {
+		if err := out.commit(); err != nil {
+			fmt.Printf("Error writing output of run, %v\n", err)
+		}
+	}()
 
 	runEnv := []string{}
 	runEnv = append(runEnv, "BENT_CONFIG="+c.Name)
@@ -1088,69 +1326,150 @@ func benchOne(c *Configuration, b *Benchmark, i int, moreArgs []string) (s strin
 	if b.NotSandboxed {
 		wrapperPrefix = dirs.wd + "/"
 	}
-	wrapperFor := func(s []string) string {
-		x := ""
-		if len(s) > 0 {
-			// If not an explicit path, then make it an explicit path
-			x = s[0]
-			if x[0] != '/' {
-				x = wrapperPrefix + x
+	wrapperFor := func(s []string) []string {
+		if len(s) == 0 {
+			return nil
+		}
+		if b.NotSandboxed {
+			// Outside the sandbox, bent can be its own wrapper.
+			if cmd := builtinCommand(s[0]); cmd != nil {
+				return append(cmd, s[1:]...)
 			}
 		}
-		return x
+		// If not an explicit path, then make it an explicit path
+		x := s[0]
+		if x[0] != '/' {
+			x = wrapperPrefix + x
+		}
+		return append([]string{x}, s[1:]...)
 	}
 
 	crw := c.RunWrapper
 	if c.PgoGen != "" {
 		// We want to generate pprof file for using pgo
-		crw = append(crw, wrapperFor([]string{"cpuprofile"}))
+		crw = append(crw[:len(crw):len(crw)], wrapperFor([]string{"cpuprofile"})...)
 	}
 
-	configWrapper := wrapperFor(crw)
-	benchWrapper := wrapperFor(b.RunWrapper)
-
 	var wrappersAndBin []string
-
-	if configWrapper != "" {
-		wrappersAndBin = append(wrappersAndBin, configWrapper)
-		wrappersAndBin = append(wrappersAndBin, crw[1:]...)
-	}
-	if benchWrapper != "" {
-		wrappersAndBin = append(wrappersAndBin, benchWrapper)
-		wrappersAndBin = append(wrappersAndBin, b.RunWrapper[1:]...)
-	}
+	wrappersAndBin = append(wrappersAndBin, wrapperFor(crw)...)
+	wrappersAndBin = append(wrappersAndBin, wrapperFor(b.RunWrapper)...)
 
 	if b.NotSandboxed {
 		bin := path.Join(dirs.wd, dirs.testBinDir, testBinaryName)
-		wrappersAndBin = append(wrappersAndBin, bin)
 
-		cmd := exec.Command(wrappersAndBin[0], wrappersAndBin[1:]...)
-		cmd.Args = append(cmd.Args, "-test.run="+b.Tests)
-		cmd.Args = append(cmd.Args, "-test.bench="+b.Benchmarks)
+		// command returns the command that runs the test binary with args,
+		// under the run wrappers if wrapped is set, and under perf stat
+		// writing to perfOut if that is not empty. The command runs in a
+		// fresh run directory, which cleanup removes.
+		command := func(wrapped bool, perfOut string, args ...string) (cmd *exec.Cmd, cleanup func(), err error) {
+			var line []string
+			if wrapped {
+				line = append(line, wrappersAndBin...)
+			}
+			if perfOut != "" {
+				// Count hardware events for the binary alone, inside any wrappers.
+				line = append(line, perfStatArgs(perfOut)...)
+			}
+			line = append(line, bin)
+			cmd = exec.Command(line[0], line[1:]...)
+			cmd.Args = append(cmd.Args, args...)
 
-		cmd.Dir = b.RunDir
-		cmd.Env = DefaultEnv()
-		if root != "" {
-			cmd.Env = replaceEnv(cmd.Env, "GOROOT", root)
-		}
-		cmd.Env = append(cmd.Env, "BENT_DIR="+dirs.wd)
-		cmd.Env = append(cmd.Env, "BENT_PROFILES="+path.Join(dirs.wd, c.thingBenchName("profiles")))
-		if c.PgoGen != "" {
-			// We want to generate pprof file for using pgo
-			cmd.Env = append(cmd.Env, "BENT_PGO="+path.Join(dirs.wd, c.PgoGen))
+			// Tests may write to their testdata, so each run gets a fresh
+			// copy of it, to keep one run from contaminating the next.
+			dir, cleanup, err := newRunDir(b, testBinaryName)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			cmd.Dir = dir
+			cmd.Env = DefaultEnv()
+			if root != "" {
+				cmd.Env = replaceEnv(cmd.Env, "GOROOT", root)
+			}
+			cmd.Env = append(cmd.Env, "BENT_DIR="+dirs.wd)
+			cmd.Env = append(cmd.Env, "BENT_PROFILES="+path.Join(dirs.wd, c.thingBenchName("profiles")))
+			if c.PgoGen != "" {
+				// We want to generate pprof file for using pgo
+				cmd.Env = append(cmd.Env, "BENT_PGO="+path.Join(dirs.wd, c.PgoGen))
+			}
+
+			cmd.Env = append(cmd.Env, runEnv...)
+			cmd.Env = append(cmd.Env, sliceExpandEnv(c.RunEnv, cmd.Env)...)
+			cmd.Env = append(cmd.Env, c.sweepEnv...)
+			cmd.Env = c.withGodebug(cmd.Env)
+
+			cmd.Args = append(cmd.Args, c.RunFlags...)
+			cmd.Args = append(cmd.Args, moreArgs...)
+			cmd.Args = sliceExpandEnv(cmd.Args, cmd.Env)
+			if runCpuset != nil {
+				runCpuset.apply(cmd)
+			}
+			if runNamespaces != nil {
+				runNamespaces.apply(cmd)
+			}
+			return cmd, cleanup, nil
 		}
 
-		cmd.Env = append(cmd.Env, runEnv...)
-		cmd.Env = append(cmd.Env, sliceExpandEnv(c.RunEnv, cmd.Env)...)
+		// run runs the tests and benchmarks matching the given patterns.
+		run := func(tests, benchmarks, perfOut string, timeout time.Duration) (string, int) {
+			cmd, cleanup, err := command(true, perfOut, "-test.run="+tests, "-test.bench="+benchmarks)
+			if err != nil {
+				return fmt.Sprintf("Error creating run directory, %v", err), 1
+			}
+			defer cleanup()
+			oom := countOOMKills(runCpuset)
+			s, rc := c.runBench(out, dirs.wd, b, cmd, timeout, warmup)
+			return annotateOOM(out, s, rc, runCpuset, oom, cmd.ProcessState, warmup)
+		}
 
-		cmd.Args = append(cmd.Args, c.RunFlags...)
-		cmd.Args = append(cmd.Args, moreArgs...)
-		cmd.Args = sliceExpandEnv(cmd.Args, cmd.Env)
+		if !c.PerfStat || warmup || !perfStatAvailable() {
+			return run(b.Tests, b.Benchmarks, "", timeout)
+		}
 
-		c.say("\n") // force a newline, there may have been loggy-gunk before this.
-		c.say("shortname: " + b.Name + "\n")
-		c.say("toolchain: " + c.Name + "\n")
-		s, rc = c.runBinary(dirs.wd, cmd, false)
+		// perf stat counts the whole binary, so to attribute its counts
+		// to benchmarks, each benchmark gets a run of its own.
+		top, sub, _ := strings.Cut(b.Benchmarks, "/")
+		cmd, cleanup, err := command(false, "", "-test.list="+top)
+		if err != nil {
+			return fmt.Sprintf("Error creating run directory, %v", err), 1
+		}
+		list, err := cmd.Output()
+		cleanup()
+		if err != nil {
+			return fmt.Sprintf("Error listing benchmarks of %s, %v", testBinaryName, err), 1
+		}
+		f, err := os.CreateTemp("", "bent-perf-stat-*")
+		if err != nil {
+			return fmt.Sprintf("Error creating perf stat output file, %v", err), 1
+		}
+		f.Close()
+		perfOut := f.Name()
+		defer os.Remove(perfOut)
+
+		names := benchmarkNames(string(list))
+		if len(names) == 0 {
+			return run(b.Tests, b.Benchmarks, "", timeout)
+		}
+		tests := b.Tests
+		for _, name := range names {
+			remaining := timeout
+			if timeout > 0 {
+				remaining -= time.Since(start)
+				if remaining <= 0 {
+					return fmt.Sprintf("Timeout (%v) running %s, not run", timeout, name), timeoutRC
+				}
+			}
+			if s, rc = run(tests, benchPattern(name, sub), perfOut, remaining); s != "" || rc != 0 {
+				return s, rc
+			}
+			// Only run the tests once.
+			tests = "^$"
+			if stat, err := os.ReadFile(perfOut); err != nil {
+				fmt.Printf("Error reading perf stat output, %v\n", err)
+			} else {
+				say(out, perfStatLine(name, parsePerfStat(string(stat))))
+			}
+		}
 	} else {
 		// docker run --net=none -e GOROOT=... -w /src/github.com/minio/minio/cmd $D /testbin/cmd_Config.test -test.short -test.run=Nope -test.v -test.bench=Benchmark'(Get|Put|List)'
 		// TODO(jfaller): I don't think we need either of these "/" below, investigate...
@@ -1160,11 +1479,23 @@ func benchOne(c *Configuration, b *Benchmark, i int, moreArgs []string) (s strin
 		bin := "/" + path.Join(dirs.testBinDir, testBinaryName)
 		wrappersAndBin = append(wrappersAndBin, bin)
 
-		cmd := exec.Command("docker", "run", "--net=none", "-w", b.RunDir)
+		// Name the container so that it can be stopped if the run times out;
+		// killing the docker client leaves the container running.
+		name := fmt.Sprintf("bent-%d-%s", os.Getpid(), testBinaryName)
+		cmd := exec.Command("docker", "run", "--rm", "--name", name, "--net=none", "-w", b.RunDir)
+		if runCpuset != nil {
+			cmd.Args = append(cmd.Args, "--cpuset-cpus="+runCpuset.cpus())
+		}
 
 		for _, e := range runEnv {
 			cmd.Args = append(cmd.Args, "-e", e)
 		}
+		for _, e := range c.sweepEnv {
+			cmd.Args = append(cmd.Args, "-e", e)
+		}
+		if g := c.sandboxGodebug(runEnv); g != "" {
+			cmd.Args = append(cmd.Args, "-e", "GODEBUG="+g)
+		}
 
 		cmd.Args = append(cmd.Args, "-e", "BENT_PROFILES="+path.Join(dirs.wd, c.thingBenchName("profiles")))
 
@@ -1182,10 +1513,14 @@ func benchOne(c *Configuration, b *Benchmark, i int, moreArgs []string) (s strin
 		cmd.Args = append(cmd.Args, moreArgs...)
 		cmd.Args = sliceExpandEnv(cmd.Args, runEnv)
 
-		c.say("\n") // force a newline, there may have been loggy-gunk before this.
-		c.say("shortname: " + b.Name + "\n")
-		c.say("toolchain: " + c.Name + "\n")
-		s, rc = c.runBinary(dirs.wd, cmd, false)
+		// The docker client's memory use says nothing about the benchmark's.
+		// The container has a cgroup of its own, outside any cpuset.
+		oom := countOOMKills(nil)
+		s, rc = c.runBench(out, dirs.wd, b, cmd, timeout, warmup)
+		s, rc = annotateOOM(out, s, rc, nil, oom, nil, warmup)
+		if rc == timeoutRC {
+			exec.Command("docker", "kill", name).Run()
+		}
 	}
 	return s, rc
 }
@@ -1235,7 +1570,6 @@ func asCommandLine(cwd string, cmd *exec.Cmd) string {
 // Then, it shouldInit is true, it:
 //   - Creates a Dockerfile.
 //   - Creates all the configuration files.
-//   - Exits.
 func checkAndSetUpFileSystem(shouldInit bool) error {
 	// To avoid bad surprises, look for pkg and bin, if they exist, refuse to run
 	_, derr := os.Stat("Dockerfile")
@@ -1243,28 +1577,31 @@ func checkAndSetUpFileSystem(shouldInit bool) error {
 
 	if derr != nil && !shouldInit {
 		// Missing Dockerfile
-		return errors.New("Missing 'Dockerfile', please rerun with -I (initialize) flag if you intend to use this directory.\n")
+		return errors.New("Missing 'Dockerfile', please rerun with -I (initialize) flag if you intend to use this directory.")
 	}
 
 	if shuffle < 0 || shuffle > 3 {
-		return fmt.Errorf("Shuffle value (-s) ought to be between 0 and 3, inclusive, instead is %d\n", shuffle)
+		return fmt.Errorf("Shuffle value (-s) ought to be between 0 and 3, inclusive, instead is %d", shuffle)
 	}
 
 	// Initialize the directory, copying in default benchmarks and sample configurations, and creating a Dockerfile
 	if shouldInit {
 		if perr == nil {
 			if !force {
-				fmt.Printf("It looks like you've already initialized this directory, remove ./gopath/pkg if you want to reinit.\n")
-				os.Exit(1)
+				return errors.New("It looks like you've already initialized this directory, remove ./gopath/pkg if you want to reinit.")
 			}
 			fmt.Printf("Directory appears to already be initialized, but -f (force) so copying files anyway.\n")
 		}
 		for _, s := range copyExes {
-			copyAsset(scripts, "scripts", s)
+			if err := copyAsset(scripts, "scripts", s); err != nil {
+				return err
+			}
 			os.Chmod(s, 0755)
 		}
 		for _, s := range copyConfigs {
-			copyAsset(configs, "configs", s)
+			if err := copyAsset(configs, "configs", s); err != nil {
+				return err
+			}
 		}
 
 		err := os.WriteFile("Dockerfile",
@@ -1276,33 +1613,29 @@ ADD . /
 			return err
 		}
 		fmt.Printf("Created Dockerfile\n")
-		os.Exit(0)
 	}
 	return nil
 }
 
-func copyAsset(fs embed.FS, dir, file string) {
+func copyAsset(fs embed.FS, dir, file string) error {
 	f, err := fs.Open(path.Join(dir, file))
 	if err != nil {
-		fmt.Printf("Error opening asset %s\n", file)
-		os.Exit(1)
+		return fmt.Errorf("Error opening asset %s", file)
 	}
 	stat, err := f.Stat()
 	if err != nil {
-		fmt.Printf("Error reading stats %s\n", file)
-		os.Exit(1)
+		return fmt.Errorf("Error reading stats %s", file)
 	}
 	bytes := make([]byte, stat.Size())
 	if l, err := f.Read(bytes); err != nil || l != int(stat.Size()) {
-		fmt.Printf("Error reading asset %s\n", file)
-		os.Exit(1)
+		return fmt.Errorf("Error reading asset %s", file)
 	}
 	err = os.WriteFile(file, bytes, 0664)
 	if err != nil {
-		fmt.Printf("Error writing %s\n", file)
-		os.Exit(1)
+		return fmt.Errorf("Error writing %s", file)
 	}
 	fmt.Printf("Copied asset %s to current directory\n", file)
+	return nil
 }
 
 type directories struct {
@@ -1313,8 +1646,7 @@ type directories struct {
 func createDirectories() (*directories, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
-		fmt.Printf("Could not get current working directory %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("Could not get current working directory %v", err)
 	}
 	dirs := &directories{
 		wd:         cwd,
@@ -1435,19 +1767,35 @@ func ifMissingAddEnv(env []string, ev string, evv string) []string {
 	return env
 }
 
-// csToSet converts a comma-separated string into the set of strings between the commas.
-func csToSet(s string) map[string]bool {
-	if s == "" {
+// toSet converts a list of names into a set, or nil if there are none.
+func toSet(names []string) map[string]bool {
+	if len(names) == 0 {
 		return nil
 	}
 	m := make(map[string]bool)
-	ss := strings.Split(s, ",")
-	for _, sss := range ss {
-		m[sss] = true
+	for _, name := range names {
+		m[name] = true
 	}
 	return m
 }
 
+// listFlag is a flag.Value accumulating comma-separated or repeated
+// names.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s != "" {
+			*f = append(*f, s)
+		}
+	}
+	return nil
+}
+
 // counterFlag is a flag.Value that is like a flag.Bool and a flag.Int.
 // If used as -name, it increments the counterFlag, but -name=x sets the counterFlag.
 // Used for verbose flag -v and build-all flag -a
@@ -1476,3 +1824,81 @@ func (c *counterFlag) Set(s string) error {
 func (c *counterFlag) IsBoolFlag() bool {
 	return true
 }
+
+// sweep is a run-time environment variable and the values it should take.
+type sweep struct {
+	name   string
+	values []string
+}
+
+// flagSweep is a kind of build flags and the values they should take; an
+// empty value adds no flags.
+type flagSweep struct {
+	kind   string // "gcflags", "ldflags" or "goflags".
+	values []string
+}
+
+// flagSweepFlag is a flag.Value accumulating -flag-sweep KIND=v1,v2,...
+// arguments.
+type flagSweepFlag []flagSweep
+
+func (s *flagSweepFlag) String() string {
+	var ss []string
+	for _, sw := range *s {
+		ss = append(ss, sw.kind+"="+strings.Join(sw.values, ","))
+	}
+	return strings.Join(ss, " ")
+}
+
+func (s *flagSweepFlag) Set(v string) error {
+	kind, values, ok := strings.Cut(v, "=")
+	if !ok || values == "" {
+		return fmt.Errorf("invalid flag sweep %q, want KIND=flags1,flags2,...", v)
+	}
+	switch kind {
+	case "gcflags", "ldflags", "goflags":
+	default:
+		return fmt.Errorf("invalid flag sweep %q, kind %q is not gcflags, ldflags or goflags", v, kind)
+	}
+	for _, sw := range *s {
+		if sw.kind == kind {
+			return fmt.Errorf("%s swept more than once", kind)
+		}
+	}
+	sw := flagSweep{kind: kind, values: strings.Split(values, ",")}
+	names := make(map[string]string)
+	for _, f := range sw.values {
+		name := flagSweepName(f)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("swept %s %q and %q would give configurations the same name", kind, other, f)
+		}
+		names[name] = f
+	}
+	*s = append(*s, sw)
+	return nil
+}
+
+// sweepFlag is a flag.Value accumulating -sweep NAME=v1,v2,... arguments.
+type sweepFlag []sweep
+
+func (s *sweepFlag) String() string {
+	var ss []string
+	for _, sw := range *s {
+		ss = append(ss, sw.name+"="+strings.Join(sw.values, ","))
+	}
+	return strings.Join(ss, " ")
+}
+
+func (s *sweepFlag) Set(v string) error {
+	name, values, ok := strings.Cut(v, "=")
+	if !ok || name == "" || values == "" {
+		return fmt.Errorf("invalid sweep %q, want NAME=value1,value2,...", v)
+	}
+	for _, sw := range *s {
+		if sw.name == name {
+			return fmt.Errorf("variable %s swept more than once", name)
+		}
+	}
+	*s = append(*s, sweep{name: name, values: strings.Split(values, ",")})
+	return nil
+}
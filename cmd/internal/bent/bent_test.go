@@ -0,0 +1,1095 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.16
+
+package bent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var dir string
+
+// TestMain implemented to allow (1) alternate use as bent command itself if BENT_TEST_IS_CMD_BENT is in environment,
+// and (2) to create and remove a temporary directory for test initialization.
+func TestMain(m *testing.M) {
+	if os.Getenv("BENT_TEST_IS_CMD_BENT") != "" {
+		os.Exit(bentMain(os.Args[1:]))
+	}
+	var err error
+	dir, err = os.MkdirTemp("", "bent_test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(dir)
+	m.Run()
+}
+
+// bentMain runs bent with the command-line arguments args, as cmd/bent
+// does, returning the status to exit with.
+func bentMain(args []string) int {
+	HandleInternal()
+	opts := DefaultOptions()
+	fs := flag.NewFlagSet("bent", flag.ExitOnError)
+	opts.AddFlags(fs)
+	fs.Parse(args)
+	opts.Args = fs.Args()
+	res, err := Run(context.Background(), opts)
+	if err != nil {
+		fmt.Println(err)
+		return ExitCode(err)
+	}
+	return res.MaxRC
+}
+
+// bentCmd returns a "bent" command (that is implemented by rerunning the current program after setting
+// BENT_TEST_IS_CMD_BENT).  The command is always run in the temporary directory created by TestMain.
+func bentCmd(t *testing.T, args ...string) *exec.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "BENT_TEST_IS_CMD_BENT=1", "PWD="+dir)
+	return cmd
+}
+
+func TestBent(t *testing.T) {
+	if runtime.GOARCH == "wasm" {
+		t.Skipf("skipping test: exec not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	cmd := bentCmd(t, "-I")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", output)
+		t.Fatal(err)
+	}
+	t.Log(string(output))
+	Cs := []string{"sample", "cronjob", "cmpjob", "gollvm"}
+	Bs := []string{"all", "50", "gc", "gcplus", "trial"}
+	for _, c := range Cs {
+		for _, b := range Bs {
+			cmd = bentCmd(t, "-l", "-C=configurations-"+c+".toml", "-B=benchmarks-"+b+".toml")
+			output, err = cmd.CombinedOutput()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", output)
+				t.Fatal(err)
+			}
+			t.Log(string(output))
+		}
+		Bs = Bs[:1] // truncate Bs for remaining configurations
+	}
+
+}
+
+func TestOptions(t *testing.T) {
+	defer func() {
+		o := DefaultOptions()
+		o.apply()
+	}()
+
+	opts := DefaultOptions()
+	fs := flag.NewFlagSet("bent", flag.ContinueOnError)
+	opts.AddFlags(fs)
+	args := []string{"-b", "uuid,gonum_path", "-b=ethereum_bitutil", "-R=3", "-a=-1", "-v", "-v", "-sweep", "GOGC=50,100", "--", "-test.short"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	opts.Args = fs.Args()
+	if want := []string{"uuid", "gonum_path", "ethereum_bitutil"}; !slices.Equal(opts.Benchmarks, want) {
+		t.Errorf("Benchmarks = %q, want %q", opts.Benchmarks, want)
+	}
+	if opts.Verbose != 2 || opts.All != -1 || len(opts.Sweeps) != 1 || !slices.Equal(opts.Args, []string{"-test.short"}) {
+		t.Errorf("got %+v", opts)
+	}
+	if err := opts.apply(); err != nil {
+		t.Fatal(err)
+	}
+	// -R subsumes -N and -a, keeping the sign of -a.
+	if N != 3 || explicitAll != -3 || len(sweeps) != 1 || verbose != 2 {
+		t.Errorf("after apply, N = %d, explicitAll = %d, sweeps = %v, verbose = %d", N, explicitAll, sweeps, verbose)
+	}
+
+	for _, tc := range []struct {
+		name string
+		edit func(*Options)
+	}{
+		{"N", func(o *Options) { o.N = 2000000 }},
+		{"isolate", func(o *Options) { o.Isolate = true }},
+		{"export", func(o *Options) { o.Export = "xml" }},
+		{"sweep", func(o *Options) { o.Sweeps = []string{"GOGC"} }},
+		{"flag-sweep", func(o *Options) { o.FlagSweeps = []string{"asmflags=-S"} }},
+	} {
+		o := DefaultOptions()
+		tc.edit(&o)
+		if err := o.apply(); err == nil {
+			t.Errorf("%s: apply succeeded with bad options", tc.name)
+		} else if ExitCode(err) != 1 {
+			t.Errorf("%s: ExitCode(%v) = %d, want 1", tc.name, err, ExitCode(err))
+		}
+	}
+
+	if got := ExitCode(nil); got != 0 {
+		t.Errorf("ExitCode(nil) = %d, want 0", got)
+	}
+	if got := ExitCode(fmt.Errorf("building: %w", exitErrorf(2, "no go.mod"))); got != 2 {
+		t.Errorf("ExitCode of a wrapped exit error = %d, want 2", got)
+	}
+}
+
+func TestExpandSweeps(t *testing.T) {
+	var sw sweepFlag
+	for _, v := range []string{"GOGC=50,100", "GOMEMLIMIT=1GiB"} {
+		if err := sw.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Set("GOGC=off"); err == nil {
+		t.Errorf("expected error sweeping GOGC twice")
+	}
+	configs := expandSweeps([]Configuration{{Name: "Tip"}, {Name: "Base"}}, sw)
+	var names []string
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	want := "Tip-GOGC50-GOMEMLIMIT1GiB Tip-GOGC100-GOMEMLIMIT1GiB Base-GOGC50-GOMEMLIMIT1GiB Base-GOGC100-GOMEMLIMIT1GiB"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got configurations %s, want %s", got, want)
+	}
+	if got, want := configs[1].sweepKeys(), "gogc: 100\ngomemlimit: 1GiB\n"; got != want {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+}
+
+func TestExpandFlagSweeps(t *testing.T) {
+	var sw flagSweepFlag
+	for _, v := range []string{"gcflags=,-d=checkptr", "goflags=-tags=purego"} {
+		if err := sw.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []string{"gcflags=-N", "asmflags=-S", "ldflags=-s,s", "ldflags"} {
+		if err := sw.Set(v); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", v)
+		}
+	}
+	configs := expandFlagSweeps([]Configuration{{Name: "Tip", GcFlags: "-l", GcEnv: []string{"GOFLAGS=-trimpath"}}}, sw)
+	var names []string
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	want := "Tip-gcflags-none-goflags-tagspurego Tip-gcflags-dcheckptr-goflags-tagspurego"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got configurations %s, want %s", got, want)
+	}
+	c := configs[1]
+	if c.GcFlags != "-l -d=checkptr" {
+		t.Errorf("got GcFlags %q, want %q", c.GcFlags, "-l -d=checkptr")
+	}
+	if got, want := strings.Join(c.GcEnv, " "), "GOFLAGS=-trimpath -tags=purego"; got != want {
+		t.Errorf("got GcEnv %q, want %q", got, want)
+	}
+	if configs[0].GcFlags != "-l" {
+		t.Errorf("got GcFlags %q for the empty sweep value, want %q", configs[0].GcFlags, "-l")
+	}
+	if got, want := c.sweepKeys(), "gcflags: -d=checkptr\ngoflags: -tags=purego\n"; got != want {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+	if got, want := configs[0].sweepKeys(), "gcflags: none\ngoflags: -tags=purego\n"; got != want {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+}
+
+func TestExpandGodebugs(t *testing.T) {
+	configs := expandGodebugs([]Configuration{
+		{Name: "Tip", Godebug: []string{"madvdontneed=1", "gctrace=0,invalidptr=0"}},
+		{Name: "Base"},
+	})
+	var names []string
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	want := "Tip Tip-madvdontneed1 Tip-gctrace0-invalidptr0 Base"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got configurations %s, want %s", got, want)
+	}
+	bench := &Benchmark{Name: "uuid"}
+	if got, want := configs[1].benchName(bench, 0, false), configs[0].benchName(bench, 0, false); got != want {
+		t.Errorf("sub-configuration runs binary %s, want %s", got, want)
+	}
+	env := configs[2].withGodebug([]string{"GOGC=50", "GODEBUG=gctrace=1"})
+	if got, want := strings.Join(env, " "), "GOGC=50 GODEBUG=gctrace=1,gctrace=0,invalidptr=0"; got != want {
+		t.Errorf("got environment %s, want %s", got, want)
+	}
+	tip := configs[2]
+	tip.RunEnv = []string{"GOGC=50", "GODEBUG=gctrace=$BENT_I"}
+	if got, want := tip.sandboxGodebug([]string{"BENT_I=1"}), "gctrace=1,gctrace=0,invalidptr=0"; got != want {
+		t.Errorf("got sandboxed GODEBUG %q, want %q", got, want)
+	}
+	if got, want := configs[0].sandboxGodebug(nil), ""; got != want {
+		t.Errorf("got sandboxed GODEBUG %q without any, want %q", got, want)
+	}
+	for g, want := range map[string]bool{"gctrace=1": true, "gctrace=1,madvdontneed=1": true, "gctrace": false, "=1": false, "a=1,": false} {
+		if got := validGodebug(g); got != want {
+			t.Errorf("validGodebug(%q) = %v, want %v", g, got, want)
+		}
+	}
+}
+
+func TestMergeExtensions(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(tmp, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	ok := write("ok.toml", `
+[[Suites]]
+  Name = "internal"
+  Repo = "example.com/internal/bench"
+  Version = "@v1.0.0"
+
+[[Benchmarks]]
+  Name = "internal"
+  Benchmarks = "Benchmark"
+`)
+	dupSuite := write("dupsuite.toml", `
+[[Suites]]
+  Name = "toml"
+  Repo = "example.com/fork/toml"
+`)
+	dupBench := write("dupbench.toml", `
+[[Benchmarks]]
+  Name = "internal"
+`)
+	conf := write("conf.toml", `
+[[Configurations]]
+  Name = "Private"
+`)
+
+	base := func() *Todo {
+		return &Todo{
+			Suites:     []Suite{{Benchmark{Name: "toml", Repo: "github.com/BurntSushi/toml"}}},
+			Benchmarks: []Benchmark{{Name: "toml"}},
+		}
+	}
+	todo := base()
+	if err := mergeExtensions(todo, []string{ok}); err != nil {
+		t.Fatal(err)
+	}
+	if len(todo.Suites) != 2 || todo.Suites[1].Repo != "example.com/internal/bench" || len(todo.Benchmarks) != 2 {
+		t.Errorf("extension not merged: %+v", todo)
+	}
+	for _, files := range [][]string{{dupSuite}, {ok, dupBench}, {conf}} {
+		if err := mergeExtensions(base(), files); err == nil {
+			t.Errorf("merging %v: expected error", files)
+		} else {
+			t.Logf("merging %v: %v", files, err)
+		}
+	}
+}
+
+func TestBenchOutput(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.stdout")
+	o, err := createBenchOutput(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(o, "machine\n")
+
+	// Runs writing at the same time still end up in one piece each, in
+	// the order they finish.
+	r1, err := o.newRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := o.newRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(r1, "one %d\n", i)
+		fmt.Fprintf(r2, "two %d\n", i)
+	}
+	if err := r2.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r1.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "machine\ntwo 0\ntwo 1\ntwo 2\none 0\none 1\none 2\n"
+	if got := string(data); got != want {
+		t.Errorf("output is:\n%s\nwant:\n%s", got, want)
+	}
+	if files, _ := filepath.Glob(name + ".run-*"); len(files) != 0 {
+		t.Errorf("run output files left behind: %v", files)
+	}
+}
+
+func TestRunBinaryTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" || runtime.GOARCH == "wasm" {
+		t.Skipf("skipping test: needs sh and process groups on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	// The background sleep holds the output pipes open, so this only
+	// returns promptly if the whole process group is killed.
+	cmd := exec.Command("sh", "-c", "sleep 60 & sleep 60")
+	start := time.Now()
+	s, rc := runBinary(out, "", cmd, false, 100*time.Millisecond)
+	if rc != timeoutRC || !strings.HasPrefix(s, "Timeout") {
+		t.Errorf("got rc=%d, %q; want rc=%d and a timeout failure", rc, s, timeoutRC)
+	}
+	if d := time.Since(start); d > 30*time.Second {
+		t.Errorf("run took %v to time out", d)
+	}
+
+	s, rc = runBinary(out, "", exec.Command("true"), false, time.Minute)
+	if rc != 0 || s != "" {
+		t.Errorf("got rc=%d, %q for a run within its timeout", rc, s)
+	}
+}
+
+func TestRunBenchWarmup(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" || runtime.GOARCH == "wasm" {
+		t.Skipf("skipping test: needs sh on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	c := &Configuration{Name: "test"}
+	b := &Benchmark{Name: "bench"}
+
+	if s, rc := c.runBench(out, "", b, exec.Command("echo", "warm"), time.Minute, true); rc != 0 || s != "" {
+		t.Errorf("got rc=%d, %q for a warmup run", rc, s)
+	}
+	if s, rc := c.runBench(out, "", b, exec.Command("echo", "measured"), time.Minute, false); rc != 0 || s != "" {
+		t.Errorf("got rc=%d, %q for a measured run", rc, s)
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); strings.Contains(got, "warm") || !strings.Contains(got, "shortname: bench\n") || !strings.Contains(got, "measured") {
+		t.Errorf("results contain:\n%s\nwant only the measured run", got)
+	}
+
+	s, rc := c.runBench(out, "", b, exec.Command("sh", "-c", "echo oops; exit 3"), time.Minute, true)
+	if rc != 3 || !strings.Contains(s, "oops") {
+		t.Errorf("got rc=%d, %q for a failed warmup run, want rc=3 and its output", rc, s)
+	}
+}
+
+func TestAnnotateOOM(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("skipping test: OOM kills are only detected on Linux")
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	// Without a count of OOM kills to go by, a run that dies from SIGKILL
+	// is taken to have been killed by the OOM killer.
+	cmd := exec.Command("sh", "-c", "kill -KILL $$")
+	s, rc := runBinary(out, "", cmd, false, 0)
+	if s, rc := annotateOOM(out, s, rc, nil, -1, cmd.ProcessState, false); rc != oomRC || !strings.HasPrefix(s, "Killed by the out-of-memory killer, peak RSS") {
+		t.Errorf("got rc=%d, %q for a killed run; want rc=%d and an OOM failure", rc, s, oomRC)
+	}
+
+	// An ordinary failure is left alone, as is one that didn't change the count.
+	cmd = exec.Command("sh", "-c", "exit 3")
+	s, rc = runBinary(out, "", cmd, false, 0)
+	if s2, rc2 := annotateOOM(out, s, rc, nil, -1, cmd.ProcessState, false); rc2 != 3 || s2 != s {
+		t.Errorf("got rc=%d, %q for a failed run; want rc=3 and %q", rc2, s2, s)
+	}
+	// Nor is a docker run's failure, without a process state, unless its
+	// return code says it died from SIGKILL.
+	if s2, rc2 := annotateOOM(out, s, rc, nil, -1, nil, false); rc2 != 3 || s2 != s {
+		t.Errorf("got rc=%d, %q for a failed docker run; want rc=3 and %q", rc2, s2, s)
+	}
+	if before := countOOMKills(nil); before >= 0 {
+		cmd := exec.Command("sh", "-c", "kill -KILL $$")
+		s, rc := runBinary(out, "", cmd, false, 0)
+		if s2, rc2 := annotateOOM(out, s, rc, nil, before, cmd.ProcessState, false); rc2 == oomRC && countOOMKills(nil) == before {
+			t.Errorf("got rc=%d, %q for a run killed by SIGKILL but not the OOM killer", rc2, s2)
+		}
+	}
+}
+
+func TestRunTimeoutFor(t *testing.T) {
+	defer func(d time.Duration) { runTimeout = d }(runTimeout)
+	pastRuns = runHistory{"fast": time.Second, "slow": time.Hour}
+	latestRuns = runHistory{"slow": 2 * time.Hour}
+
+	for _, tc := range []struct {
+		b    Benchmark
+		flag time.Duration
+		want time.Duration
+	}{
+		{Benchmark{Name: "new"}, 0, defaultRunTimeout},
+		{Benchmark{Name: "fast"}, 0, minRunTimeout},
+		{Benchmark{Name: "slow"}, 0, 10 * time.Hour},
+		{Benchmark{Name: "slow"}, time.Minute, time.Minute},
+		{Benchmark{Name: "slow"}, -1, 0},
+		{Benchmark{Name: "slow", Timeout: "3m", timeout: 3 * time.Minute}, time.Minute, 3 * time.Minute},
+		{Benchmark{Name: "slow", Timeout: "0"}, 0, 0},
+	} {
+		runTimeout = tc.flag
+		if got := runTimeoutFor(&tc.b); got != tc.want {
+			t.Errorf("runTimeoutFor(%+v) with -run-timeout=%v = %v, want %v", tc.b, tc.flag, got, tc.want)
+		}
+	}
+}
+
+func TestRunDir(t *testing.T) {
+	defer func(k bool) { keepRunDirs = k }(keepRunDirs)
+	keepRunDirs = false
+
+	b := &Benchmark{BuildDir: t.TempDir(), runFiles: []string{"testdata", "input.txt"}}
+	if err := os.MkdirAll(filepath.Join(b.BuildDir, "testdata", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"testdata/sub/a": "pristine", "input.txt": "input"} {
+		if err := os.WriteFile(filepath.Join(b.BuildDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A run that mutates its testdata leaves the next run's copy alone.
+	dir1, remove1, err := newRunDir(b, "bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir1, "testdata", "sub", "a"), []byte("mutated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	remove1()
+	if _, err := os.Stat(dir1); !os.IsNotExist(err) {
+		t.Errorf("run directory %s still exists after removal (err=%v)", dir1, err)
+	}
+	dir2, remove2, err := newRunDir(b, "bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"testdata/sub/a": "pristine", "input.txt": "input"} {
+		if got, err := os.ReadFile(filepath.Join(dir2, name)); err != nil || string(got) != want {
+			t.Errorf("%s in second run directory = %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	// Kept directories survive until the next invocation scrubs them.
+	keepRunDirs = true
+	remove2()
+	if _, err := os.Stat(dir2); err != nil {
+		t.Errorf("kept run directory: %v", err)
+	}
+	keepRunDirs = false
+	if err := scrubRunDirs(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(b.BuildDir, runDirsName)); !os.IsNotExist(err) {
+		t.Errorf("run directories still exist after scrubbing (err=%v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(b.BuildDir, "testdata", "sub", "a")); err != nil {
+		t.Errorf("scrubbing removed the pristine testdata: %v", err)
+	}
+}
+
+func TestApplyTimeBudget(t *testing.T) {
+	defer func(h runHistory) { pastRuns = h }(pastRuns)
+	pastRuns = runHistory{"a": time.Minute, "b": 10 * time.Minute, "c": 2 * time.Minute, "d": 3 * time.Minute}
+
+	benchmarks := []Benchmark{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "new"}, {Name: "off", Disabled: true}}
+	core := map[string]bool{"b": true}
+	// Two runs each: b costs 20m, then a 2m, c 4m, d 6m, and new 8m
+	// (the average of the others).
+	skipped, total, ok := applyTimeBudget(benchmarks, core, 2, 35*time.Minute)
+	if !ok {
+		t.Fatalf("applyTimeBudget found no history")
+	}
+	if want := []string{"new (8m0s)"}; !slices.Equal(skipped, want) {
+		t.Errorf("skipped %q, want %q", skipped, want)
+	}
+	if want := 32 * time.Minute; total != want {
+		t.Errorf("total = %v, want %v", total, want)
+	}
+	for _, b := range benchmarks {
+		if want := b.Name == "new" || b.Name == "off"; b.Disabled != want {
+			t.Errorf("%s: Disabled = %v, want %v", b.Name, b.Disabled, want)
+		}
+	}
+
+	pastRuns = runHistory{}
+	if _, _, ok := applyTimeBudget([]Benchmark{{Name: "a"}}, nil, 1, time.Minute); ok {
+		t.Errorf("applyTimeBudget with no history = ok, want !ok")
+	}
+}
+
+func TestPerfStat(t *testing.T) {
+	out := `# started on Mon Oct  7 10:00:00 2024
+
+4000000,,instructions:u,1000000,100.00,2.00,insn per cycle
+2000000,,cycles:u,1000000,100.00,,
+500000,,branches:u,1000000,100.00,,
+<not counted>,,branch-misses:u,0,0.00,,
+`
+	counts := parsePerfStat(out)
+	want := map[string]float64{"instructions": 4e6, "cycles": 2e6, "branches": 5e5}
+	if len(counts) != len(want) {
+		t.Errorf("parsePerfStat got %v, want %v", counts, want)
+	}
+	for k, v := range want {
+		if counts[k] != v {
+			t.Errorf("parsePerfStat got %s = %v, want %v", k, counts[k], v)
+		}
+	}
+	if got, want := perfStatLine("BenchmarkFoo", counts), "BenchmarkFoo 1 4000000 instructions 2000000 cycles 2.000 IPC\n"; got != want {
+		t.Errorf("perfStatLine got %q, want %q", got, want)
+	}
+
+	counts["branch-misses"] = 5000
+	if got, want := perfStatLine("BenchmarkFoo", counts), "BenchmarkFoo 1 4000000 instructions 2000000 cycles 2.000 IPC 1.000 branch-miss-%\n"; got != want {
+		t.Errorf("perfStatLine got %q, want %q", got, want)
+	}
+	if got := perfStatLine("BenchmarkFoo", nil); got != "" {
+		t.Errorf("perfStatLine(nil) got %q, want empty", got)
+	}
+
+	list := "TestFoo\nBenchmarkFoo\nBenchmarkFoo.Bar\nExampleFoo\n"
+	if got, want := benchmarkNames(list), []string{"BenchmarkFoo", "BenchmarkFoo.Bar"}; !slices.Equal(got, want) {
+		t.Errorf("benchmarkNames got %q, want %q", got, want)
+	}
+	if got, want := benchPattern("BenchmarkFoo.Bar", ""), `^BenchmarkFoo\.Bar$`; got != want {
+		t.Errorf("benchPattern got %q, want %q", got, want)
+	}
+	if got, want := benchPattern("BenchmarkFoo", "size=1"), "^BenchmarkFoo$/size=1"; got != want {
+		t.Errorf("benchPattern got %q, want %q", got, want)
+	}
+}
+
+func TestGerritRefs(t *testing.T) {
+	for _, tc := range []struct {
+		ref, want string
+	}{
+		{"CL 12345", "refs/changes/45/12345/*"},
+		{"CL12345/3", "refs/changes/45/12345/3"},
+		{"12345/3", "refs/changes/45/12345/3"},
+		{"7", "refs/changes/07/7/*"},
+	} {
+		m := clRE.FindStringSubmatch(tc.ref)
+		if m == nil {
+			t.Errorf("%q is not recognized as a CL", tc.ref)
+			continue
+		}
+		if got := changeRef(m[1], m[2]); got != tc.want {
+			t.Errorf("changeRef for %q got %q, want %q", tc.ref, got, tc.want)
+		}
+	}
+	for _, ref := range []string{"master", "go1.23.0", "release-branch.go1.23"} {
+		if clRE.MatchString(ref) {
+			t.Errorf("%q is recognized as a CL", ref)
+		}
+	}
+
+	lsRemote := "aaaa\trefs/changes/45/12345/1\n" +
+		"cccc\trefs/changes/45/12345/10\n" +
+		"bbbb\trefs/changes/45/12345/2\n" +
+		"dddd\trefs/changes/45/12345/meta\n"
+	commit, ref := latestPatchSet(lsRemote)
+	if commit != "cccc" || ref != "refs/changes/45/12345/10" {
+		t.Errorf("latestPatchSet got %s %s, want cccc refs/changes/45/12345/10", commit, ref)
+	}
+}
+
+func TestReleaseArchive(t *testing.T) {
+	list := []byte(`[
+		{"version": "go1.22.5", "files": [
+			{"filename": "go1.22.5.src.tar.gz", "os": "", "arch": "", "sha256": "00", "kind": "source"},
+			{"filename": "go1.22.5.darwin-arm64.pkg", "os": "darwin", "arch": "arm64", "sha256": "11", "kind": "installer"},
+			{"filename": "go1.22.5.darwin-arm64.tar.gz", "os": "darwin", "arch": "arm64", "sha256": "22", "kind": "archive"},
+			{"filename": "go1.22.5.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "sha256": "33", "kind": "archive"}
+		]},
+		{"version": "go1.22.4", "files": [
+			{"filename": "go1.22.4.linux-amd64.tar.gz", "os": "linux", "arch": "amd64", "sha256": "44", "kind": "archive"}
+		]}
+	]`)
+	for _, tc := range []struct {
+		release, goos, goarch string
+		want                  string // filename, or "" for an error
+	}{
+		{"go1.22.5", "linux", "amd64", "go1.22.5.linux-amd64.tar.gz"},
+		{"go1.22.5", "darwin", "arm64", "go1.22.5.darwin-arm64.tar.gz"},
+		{"go1.22.4", "linux", "amd64", "go1.22.4.linux-amd64.tar.gz"},
+		{"go1.22.4", "darwin", "arm64", ""},
+		{"go1.22.3", "linux", "amd64", ""},
+	} {
+		f, err := releaseArchive(list, tc.release, tc.goos, tc.goarch)
+		switch {
+		case tc.want == "" && err == nil:
+			t.Errorf("releaseArchive(%s, %s/%s) got %s, want an error", tc.release, tc.goos, tc.goarch, f.Filename)
+		case tc.want != "" && err != nil:
+			t.Errorf("releaseArchive(%s, %s/%s) failed: %v", tc.release, tc.goos, tc.goarch, err)
+		case tc.want != "" && f.Filename != tc.want:
+			t.Errorf("releaseArchive(%s, %s/%s) got %s, want %s", tc.release, tc.goos, tc.goarch, f.Filename, tc.want)
+		}
+	}
+
+	for _, release := range []string{"go1.22.5", "go1.23rc1", "go1.21beta2", "go1.22"} {
+		if !releaseRE.MatchString(release) {
+			t.Errorf("%q is not recognized as a release", release)
+		}
+	}
+	for _, release := range []string{"1.22.5", "go1.22.5/../x", "master", "go1.22.x"} {
+		if releaseRE.MatchString(release) {
+			t.Errorf("%q is recognized as a release", release)
+		}
+	}
+}
+
+func TestAffects(t *testing.T) {
+	deps := []string{"errors", "internal/abi", "runtime", "net", "net/netip", "github.com/gonum/topo"}
+	for _, tc := range []struct {
+		changed []string
+		want    bool
+	}{
+		{[]string{"runtime"}, true},
+		{[]string{"encoding/json"}, false},
+		{[]string{"encoding/json", "net/netip"}, true},
+		{[]string{"net/..."}, true},
+		{[]string{"net/http/..."}, false},
+		{[]string{"internal/..."}, true},
+		{[]string{"runtime/pprof"}, false},
+		{[]string{"cmd/compile/internal/ssa"}, true},
+		{[]string{"cmd/..."}, true},
+	} {
+		if got := affects(tc.changed, deps); got != tc.want {
+			t.Errorf("affects(%v) = %v, want %v", tc.changed, got, tc.want)
+		}
+	}
+}
+
+func TestGoCommands(t *testing.T) {
+	todo := &Todo{Configurations: []Configuration{
+		{Name: "Base", Root: "/go/base/"},
+		{Name: "Off", Root: "/go/off/", Disabled: true},
+		{Name: "Tip", Root: "/go/tip/"},
+		{Name: "Tip-noopt", Root: "/go/tip/"},
+	}}
+	if got, want := goCommands(todo), []string{"/go/base/bin/go", "/go/tip/bin/go"}; !slices.Equal(got, want) {
+		t.Errorf("goCommands = %v, want %v", got, want)
+	}
+	todo = &Todo{Configurations: []Configuration{{Name: "Default"}}}
+	if got, want := goCommands(todo), []string{"go"}; !slices.Equal(got, want) {
+		t.Errorf("goCommands without roots = %v, want %v", got, want)
+	}
+}
+
+func TestParseResults(t *testing.T) {
+	out := `goos: linux
+goarch: amd64
+shortname: uuid
+toolchain: Tip
+BenchmarkNew-8 	 1000000	      1052 ns/op	      16 B/op
+BenchmarkParse-8   	 500000	      2001 ns/op
+PASS
+shortname: uuid
+toolchain: Tip
+BenchmarkNew-8 	 1000000	      1048 ns/op	      16 B/op
+BenchmarkUuid 1 5.5e+09 build-real-ns/op
+Benchmarking is fun
+`
+	rows, err := parseResults(strings.NewReader(out), "Tip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := writeResults(&b, rows, ','); err != nil {
+		t.Fatal(err)
+	}
+	want := `benchmark,config,iteration,metric,unit,value
+uuid,Tip,0,New-8,ns/op,1052
+uuid,Tip,0,New-8,B/op,16
+uuid,Tip,0,Parse-8,ns/op,2001
+uuid,Tip,1,New-8,ns/op,1048
+uuid,Tip,1,New-8,B/op,16
+uuid,Tip,0,Uuid,build-real-ns/op,5.5e+09
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	entries, err := catalog(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topo *CatalogEntry
+	for i := range entries {
+		if entries[i].Name == "gonum_topo" {
+			topo = &entries[i]
+		}
+	}
+	if topo == nil {
+		t.Fatal("gonum_topo missing from catalog")
+	}
+	if topo.Repo != "gonum.org/v1/gonum/graph/topo/" || !slices.Contains(topo.Tags, "all") || topo.Benchmarks["all"] != "Benchmark" {
+		t.Errorf("unexpected catalog entry for gonum_topo: %+v", *topo)
+	}
+
+	for _, format := range []string{"markdown", "json"} {
+		var b strings.Builder
+		if err := writeCatalog(&b, entries, format); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), "gonum.org/v1/gonum/graph/topo/") {
+			t.Errorf("%s catalog does not mention gonum_topo's repo:\n%s", format, b.String())
+		}
+	}
+	if err := writeCatalog(io.Discard, entries, "wiki"); err == nil {
+		t.Error("writeCatalog succeeded with an unknown format")
+	}
+}
+
+func TestCpusetCPUs(t *testing.T) {
+	for _, tc := range []struct {
+		spec, online, want string
+	}{
+		{"2-7", "0-7", "2-7"},
+		{"5,2-3,4,9", "0-15", "2-5,9"},
+		{"auto", "0-7", "1-7"},
+		{"auto", "0-3,8-11", "1-3,8-11"},
+		{"auto", "2,4", "2,4"},
+	} {
+		got, err := cpusetCPUs(tc.spec, tc.online)
+		if err != nil || got != tc.want {
+			t.Errorf("cpusetCPUs(%q, %q) = %q, %v; want %q", tc.spec, tc.online, got, err, tc.want)
+		}
+	}
+	for _, spec := range []string{"", "7-2", "x", "1-", "-1"} {
+		if got, err := cpusetCPUs(spec, "0-7"); err == nil {
+			t.Errorf("cpusetCPUs(%q) = %q, want error", spec, got)
+		}
+	}
+	if got, err := cpusetCPUs("auto", "0"); err == nil {
+		t.Errorf("cpusetCPUs(auto) on one CPU = %q, want error", got)
+	}
+}
+
+func TestHousekeepingCPUs(t *testing.T) {
+	got, err := housekeepingCPUs("0-7", "2-7")
+	if err != nil || FormatCPUList(got) != "0-1" {
+		t.Errorf("housekeepingCPUs(0-7, 2-7) = %v, %v; want 0-1", got, err)
+	}
+	if got, err := housekeepingCPUs("0-3", "0-3"); err == nil {
+		t.Errorf("housekeepingCPUs(0-3, 0-3) = %v, want error", got)
+	}
+	for _, tc := range []struct {
+		cpus []int
+		want string
+	}{
+		{nil, "0"},
+		{[]int{0}, "1"},
+		{[]int{0, 1, 4}, "13"},
+		{[]int{0, 35}, "8,00000001"},
+		{[]int{32}, "1,00000000"},
+	} {
+		if got := formatCPUMask(tc.cpus); got != tc.want {
+			t.Errorf("formatCPUMask(%v) = %q, want %q", tc.cpus, got, tc.want)
+		}
+	}
+}
+
+func TestParseNamespaces(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want namespaces
+	}{
+		{"net", namespaces{net: true}},
+		{"net,pid", namespaces{net: true, pid: true}},
+		{"pid, net", namespaces{net: true, pid: true}},
+		{"pid", namespaces{pid: true}},
+	} {
+		got, err := parseNamespaces(tc.spec)
+		if err != nil || got != tc.want {
+			t.Errorf("parseNamespaces(%q) = %+v, %v; want %+v", tc.spec, got, err, tc.want)
+		}
+	}
+	for _, spec := range []string{"", "mnt", "net,"} {
+		if got, err := parseNamespaces(spec); err == nil {
+			t.Errorf("parseNamespaces(%q) = %+v, want error", spec, got)
+		}
+	}
+}
+
+func TestPublishRun(t *testing.T) {
+	tmp := t.TempDir()
+	dest := filepath.Join(tmp, "dest")
+	write := func(name, content string) string {
+		file := filepath.Join(tmp, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	tip := write("20240101T000000.Tip.stdout", "BenchmarkFoo 1 2 ns/op\n")
+	base := write("20240101T000000.Base.stdout", "BenchmarkFoo 1 3 ns/op\n")
+	machine := write("20240101T000000.machine", "kernel: 6.1\n")
+
+	for _, stamp := range []string{"20240101T000000", "20240102T000000"} {
+		m := &runManifest{Runstamp: stamp, Host: "builder"}
+		got, err := publishRun(dest, m, []string{tip, base, machine})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := dest + "/runs/builder/" + stamp + ".json"; got != want {
+			t.Errorf("published manifest to %s, want %s", got, want)
+		}
+	}
+
+	// Both runs published the same files, so they are stored once.
+	blobs, err := os.ReadDir(filepath.Join(dest, "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 3 {
+		t.Errorf("got %d files stored, want 3", len(blobs))
+	}
+	b, err := os.ReadFile(filepath.Join(dest, "runs", "builder", "20240102T000000.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m runManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 3 || m.Files[0].Name != "20240101T000000.Tip.stdout" {
+		t.Fatalf("got manifest files %+v", m.Files)
+	}
+	content, err := os.ReadFile(filepath.Join(dest, "sha256", m.Files[0].SHA256))
+	if err != nil || string(content) != "BenchmarkFoo 1 2 ns/op\n" {
+		t.Errorf("published file content %q, %v", content, err)
+	}
+}
+
+func TestBuiltinScripts(t *testing.T) {
+	if runtime.GOARCH == "wasm" {
+		t.Skipf("skipping test: exec not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	if builtinCommand("benchsize") == nil {
+		t.Errorf("no built-in benchsize")
+	}
+	if cmd := builtinCommand("./benchsize"); cmd != nil {
+		t.Errorf("builtinCommand(\"./benchsize\") = %v, want the script itself", cmd)
+	}
+	if cmd := builtinCommand("benchdwarf"); cmd != nil {
+		t.Errorf("builtinCommand(\"benchdwarf\") = %v, want the script itself", cmd)
+	}
+
+	// benchtime runs the rest of its arguments with the benchtime added.
+	out, err := bentCmd(t, "internal-benchtime", "1x", "echo", "-test.bench=.").CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if got, want := strings.TrimSpace(string(out)), "-test.bench=. -test.benchtime=1x"; got != want {
+		t.Errorf("internal-benchtime ran %q, want %q", got, want)
+	}
+
+	// Wrapped commands killed by a signal are reported as a shell would,
+	// so that OOM kills can be told from other failures, also under -unshare.
+	if runtime.GOOS != "windows" {
+		err := bentCmd(t, "internal-benchtime", "1x", "sh", "-c", "kill -KILL $$").Run()
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) || ee.ExitCode() != oomRC {
+			t.Errorf("internal-benchtime of a command killed by SIGKILL: got %v, want exit status %d", err, oomRC)
+		}
+	}
+	if runtime.GOOS == "linux" {
+		cmd := exec.Command("sh", "-c", "kill -KILL $$")
+		namespaces{net: true}.apply(cmd)
+		cmd.Env = append(os.Environ(), "BENT_TEST_IS_CMD_BENT=1")
+		var ee *exec.ExitError
+		switch err := cmd.Run(); {
+		case !errors.As(err, &ee):
+			t.Logf("can't run commands in new namespaces: %v", err)
+		case ee.ExitCode() == 1:
+			t.Logf("can't bring up loopback in a new network namespace")
+		case ee.ExitCode() != oomRC:
+			t.Errorf("-unshare run of a command killed by SIGKILL: got %v, want exit status %d", err, oomRC)
+		}
+	}
+
+	// benchsize reports the sizes of this test binary's sections.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := writeBenchsize(&b, exe, "Bent"); err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(map[string]int64)
+	for _, line := range strings.Split(b.String(), "\n") {
+		var n, size int64
+		var unit string
+		if _, err := fmt.Sscanf(line, "BenchmarkBent %d %d %s", &n, &size, &unit); err == nil {
+			sizes[unit] = size
+		}
+	}
+	if sizes["text-bytes"] <= 0 || sizes["pclntab-bytes"] <= 0 || sizes["total-bytes"] < sizes["text-bytes"]+sizes["pclntab-bytes"] {
+		t.Errorf("implausible sizes %v from:\n%s", sizes, b.String())
+	}
+}
+
+func TestFindUnstable(t *testing.T) {
+	out := `shortname: uuid
+BenchmarkNew-8 	 1000000	      1000 ns/op	      16 B/op
+BenchmarkParse-8 	 10	      1000 ns/op
+shortname: uuid
+BenchmarkNew-8 	 1000000	      1010 ns/op	      16 B/op
+BenchmarkParse-8 	 10	      1500 ns/op
+shortname: uuid
+BenchmarkNew-8 	 1000000	       990 ns/op	      16 B/op
+BenchmarkParse-8 	 10	       500 ns/op
+BenchmarkUuid 1 5.5e+09 build-real-ns/op
+BenchmarkUuid 1 8.5e+09 build-real-ns/op
+`
+	rows, err := parseResults(strings.NewReader(out), "Tip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unstable := findUnstable(rows, 0.05)
+	if len(unstable) != 2 {
+		t.Fatalf("got %d unstable results, want 2: %+v", len(unstable), unstable)
+	}
+	parse, build := unstable[0], unstable[1]
+	if parse.Benchmark != "uuid" || parse.Metric != "Parse-8" || parse.Unit != "ns/op" || parse.Runs != 3 || parse.Mean != 1000 || math.Abs(parse.CV-0.5) > 1e-9 {
+		t.Errorf("got %+v, want uuid Parse-8 ns/op with mean 1000 and CV 0.5 over 3 runs", parse)
+	}
+	if len(parse.Causes) != 2 || !strings.Contains(parse.Causes[0], "timed only 10µs") || !strings.Contains(parse.Causes[1], "only 3 repetitions") {
+		t.Errorf("got causes %q for a short run with few repetitions", parse.Causes)
+	}
+	if build.Metric != "Uuid" || build.Unit != "build-real-ns/op" || !strings.Contains(build.Causes[0], "single build") {
+		t.Errorf("got %+v, want uuid build-real-ns/op from a single build", build)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quarantine.toml")
+	if q, err := readQuarantine(file); err != nil || q != nil {
+		t.Fatalf("reading a missing quarantine file: got %v, %v, want nothing", q, err)
+	}
+	os.WriteFile(file, []byte(`
+[[Quarantine]]
+  Name = "uuid"
+  Reason = "flaky"
+  Expires = "2024-07-01"
+  Issue = "golang/go#1"
+
+[[Quarantine]]
+  Name = "gonum_topo"
+  Reason = "hangs"
+  Expires = "2024-06-01"
+
+[[Quarantine]]
+  Name = "ethereum_core"
+  Reason = "fails"
+  Expires = "2024-07-01"
+
+[[Quarantine]]
+  Name = "nosuch"
+  Reason = "gone"
+  Expires = "2024-07-01"
+`), 0644)
+	q, err := readQuarantine(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := &Todo{Benchmarks: []Benchmark{{Name: "uuid"}, {Name: "gonum_topo"}, {Name: "ethereum_core"}, {Name: "minio"}}}
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	active, expired, unknown := applyQuarantine(todo, q, now, map[string]bool{"ethereum_core": false})
+	if len(active) != 1 || active[0].String() != "uuid until 2024-07-01 (golang/go#1): flaky" {
+		t.Errorf("got active quarantines %v, want just uuid", active)
+	}
+	if len(expired) != 1 || expired[0].Name != "gonum_topo" {
+		t.Errorf("got expired quarantines %v, want just gonum_topo", expired)
+	}
+	if len(unknown) != 1 || unknown[0].Name != "nosuch" {
+		t.Errorf("got unknown quarantines %v, want just nosuch", unknown)
+	}
+	for _, b := range todo.Benchmarks {
+		if b.Disabled != (b.Name == "uuid") {
+			t.Errorf("benchmark %s: got Disabled %v", b.Name, b.Disabled)
+		}
+	}
+
+	for _, bad := range []string{
+		"[[Quarantine]]\nName = \"uuid\"\nExpires = \"2024-07-01\"\n",
+		"[[Quarantine]]\nName = \"uuid\"\nReason = \"flaky\"\n",
+		"[[Quarantine]]\nName = \"uuid\"\nReason = \"flaky\"\nExpires = \"July 1\"\n",
+		"[[Quarantine]]\nName = \"uuid\"\nReason = \"a\"\nExpires = \"2024-07-01\"\n[[Quarantine]]\nName = \"uuid\"\nReason = \"b\"\nExpires = \"2024-07-01\"\n",
+	} {
+		os.WriteFile(file, []byte(bad), 0644)
+		if _, err := readQuarantine(file); err == nil {
+			t.Errorf("reading %q: expected error", bad)
+		}
+	}
+}
+
+func TestPostProcessResults(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	tmp := t.TempDir()
+	script := filepath.Join(tmp, "post.sh")
+	got := filepath.Join(tmp, "got")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" $BENT_CONFIG > "+got+"\n"), 0755)
+	results := filepath.Join(tmp, "results.stdout")
+	if err := postProcessResults(script+" -x", "Tip", results); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-x " + results + " Tip\n"; string(b) != want {
+		t.Errorf("post-processor got %q, want %q", b, want)
+	}
+	if err := postProcessResults(filepath.Join(tmp, "nosuch"), "Tip", results); err == nil {
+		t.Errorf("running a missing post-processor: expected error")
+	}
+}
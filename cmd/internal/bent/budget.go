@@ -0,0 +1,121 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// estimateRun returns how long a run of b is expected to take, based on
+// the longest run recorded in run-history.json, and whether there was
+// any history to go on.
+func estimateRun(b *Benchmark) (time.Duration, bool) {
+	d, ok := pastRuns[b.Name]
+	return d, ok && d > 0
+}
+
+// cmpCore orders benchmarks in core before the others.
+func cmpCore(core map[string]bool, a, b Benchmark) int {
+	switch {
+	case core[a.Name] && !core[b.Name]:
+		return -1
+	case !core[a.Name] && core[b.Name]:
+		return 1
+	}
+	return 0
+}
+
+// fitTimeBudget applies -time-budget, if set, to the enabled benchmarks of
+// todo, reporting what it skipped.
+func fitTimeBudget(todo *Todo, core map[string]bool) {
+	if timeBudget <= 0 {
+		return
+	}
+	// Each Godebug setting adds a sub-configuration, expanded later,
+	// that runs every benchmark too.
+	configs := 0
+	for _, c := range todo.Configurations {
+		if !c.Disabled {
+			configs += 1 + len(c.Godebug)
+		}
+	}
+	skipped, total, ok := applyTimeBudget(todo.Benchmarks, core, N*configs, timeBudget)
+	switch {
+	case !ok:
+		fmt.Printf("No run history to estimate durations from, ignoring -time-budget\n")
+	case len(skipped) > 0:
+		fmt.Printf("Skipping %d benchmarks to fit in -time-budget %v (estimated %v for the rest): %s\n",
+			len(skipped), timeBudget, total.Round(time.Second), strings.Join(skipped, ", "))
+	default:
+		fmt.Printf("All benchmarks fit in -time-budget %v (estimated %v)\n", timeBudget, total.Round(time.Second))
+	}
+}
+
+// applyTimeBudget disables enough of the enabled benchmarks that running
+// the rest runsEach times fits, by estimate, within budget. Benchmarks in
+// core are kept in preference to the others, in order; the others are
+// kept shortest first. Benchmarks without a history are estimated at the
+// average of those with one.
+//
+// It returns the names of the benchmarks it disabled, with their
+// estimated total durations, and the estimated duration of the rest.
+// If no enabled benchmark has any history to estimate from, it does
+// nothing and returns ok == false.
+func applyTimeBudget(benchmarks []Benchmark, core map[string]bool, runsEach int, budget time.Duration) (skipped []string, total time.Duration, ok bool) {
+	var enabled []*Benchmark
+	var known int
+	var sum time.Duration
+	for i := range benchmarks {
+		b := &benchmarks[i]
+		if b.Disabled {
+			continue
+		}
+		enabled = append(enabled, b)
+		if d, ok := estimateRun(b); ok {
+			sum += d
+			known++
+		}
+	}
+	if known == 0 {
+		return nil, 0, false
+	}
+	unknown := sum / time.Duration(known)
+
+	cost := func(b *Benchmark) time.Duration {
+		d, ok := estimateRun(b)
+		if !ok {
+			d = unknown
+		}
+		return d * time.Duration(runsEach)
+	}
+
+	// Consider core benchmarks in order, then the rest shortest first,
+	// so that as many as possible fit.
+	var order, rest []*Benchmark
+	for _, b := range enabled {
+		if core[b.Name] {
+			order = append(order, b)
+		} else {
+			rest = append(rest, b)
+		}
+	}
+	slices.SortStableFunc(rest, func(a, b *Benchmark) int {
+		return cmp.Compare(cost(a), cost(b))
+	})
+	for _, b := range append(order, rest...) {
+		c := cost(b)
+		if total+c > budget {
+			b.Disabled = true
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", b.Name, c.Round(time.Second)))
+			continue
+		}
+		total += c
+	}
+	return skipped, total, true
+}
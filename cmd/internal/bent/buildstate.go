@@ -0,0 +1,141 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// buildStateFile records what the binaries in testbin were built from,
+// so that a later run that changes only run-time settings can skip
+// getting and building.
+const buildStateFile = "build-state.json"
+
+type buildState struct {
+	Key       string // Hash of everything that affects the built binaries.
+	Container string // Docker image holding the binaries, if sandboxed.
+}
+
+// toolchainStamp identifies the state of the Go toolchain in root
+// (or the go command on PATH if root is empty), so that rebuilding a
+// toolchain in place, as the cron job does, invalidates its binaries.
+func toolchainStamp(root string) string {
+	gocmd := path.Join(root, "bin", "go")
+	if root == "" {
+		var err error
+		if gocmd, err = exec.LookPath("go"); err != nil {
+			return ""
+		}
+	}
+	s := ""
+	files := []string{gocmd, path.Join(root, "VERSION")}
+	tools, _ := filepath.Glob(path.Join(root, "pkg", "tool", "*", "*"))
+	files = append(files, tools...)
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			s += f + "@" + fi.ModTime().String() + "#" + strconv.FormatInt(fi.Size(), 10) + "\n"
+		}
+	}
+	return s
+}
+
+// buildKey returns a hash of every input that affects the binaries built
+// for the enabled benchmarks and configurations.
+func buildKey(todo *Todo) string {
+	type configKey struct {
+		Name, Root, Toolchain, GcFlags, LdFlags, PgoUse string
+		GcEnv, BuildFlags                               []string
+	}
+	type benchKey struct {
+		Name, Repo, Version string
+		GcEnv, BuildFlags   []string
+		NotSandboxed        bool
+	}
+	var k struct {
+		Configs      []configKey
+		Benchmarks   []benchKey
+		R, Builds    int
+		MinGoVersion string
+		Experiment   bool
+	}
+	for _, c := range todo.Configurations {
+		if c.Disabled {
+			continue
+		}
+		k.Configs = append(k.Configs, configKey{c.Name, c.Root, toolchainStamp(c.Root), c.GcFlags, c.LdFlags, c.PgoUse, c.GcEnv, c.BuildFlags})
+	}
+	for _, b := range todo.Benchmarks {
+		if b.Disabled {
+			continue
+		}
+		k.Benchmarks = append(k.Benchmarks, benchKey{b.Name, b.Repo, b.Version, b.GcEnv, b.BuildFlags, b.NotSandboxed})
+	}
+	k.R, k.Builds, k.MinGoVersion, k.Experiment = R, int(explicitAll), minGoVersion, experiment
+	j, err := json.Marshal(k)
+	if err != nil {
+		panic(err) // Can't happen, everything in k is marshalable.
+	}
+	h := sha256.Sum256(j)
+	return hex.EncodeToString(h[:])
+}
+
+// reusableBuild returns the recorded build state if the binaries from the
+// last build are still valid for todo, or nil if they must be rebuilt.
+func reusableBuild(todo *Todo) *buildState {
+	b, err := os.ReadFile(path.Join(dirs.wd, buildStateFile))
+	if err != nil {
+		return nil
+	}
+	var st buildState
+	if err := json.Unmarshal(b, &st); err != nil || st.Key != buildKey(todo) {
+		return nil
+	}
+	// Make sure the binaries are actually there.
+	count := 1
+	if R > 0 {
+		count = N
+	}
+	for _, c := range todo.Configurations {
+		if c.Disabled {
+			continue
+		}
+		for _, bench := range todo.Benchmarks {
+			if bench.Disabled {
+				continue
+			}
+			for i := 0; i < count; i++ {
+				if _, err := os.Stat(path.Join(dirs.wd, dirs.testBinDir, c.benchName(&bench, i, R > 0))); err != nil {
+					return nil
+				}
+			}
+		}
+	}
+	return &st
+}
+
+// saveBuildState records that the binaries in testbin were built for todo.
+func saveBuildState(todo *Todo, container string) {
+	b, err := json.MarshalIndent(buildState{Key: buildKey(todo), Container: container}, "", "\t")
+	if err == nil {
+		err = os.WriteFile(path.Join(dirs.wd, buildStateFile), b, 0664)
+	}
+	if err != nil {
+		fmt.Printf("Could not record build state, next run will rebuild: %v\n", err)
+	}
+}
+
+// forgetBuildState removes any record of the last build, for use when
+// builds fail or are skipped part way.
+func forgetBuildState() {
+	os.Remove(path.Join(dirs.wd, buildStateFile))
+}
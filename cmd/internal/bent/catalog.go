@@ -0,0 +1,152 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A CatalogEntry describes one suite, as found in the embedded
+// configuration files.
+type CatalogEntry struct {
+	Name    string `json:"name"`
+	Repo    string `json:"repo"`
+	Version string `json:"version"`
+	// Benchmarks maps the name of each benchmark list ("all" for
+	// benchmarks-all.toml, etc) that includes the suite to the
+	// regexp of the benchmarks it runs.
+	Benchmarks map[string]string `json:"benchmarks"`
+	// Tags are the names of the lists that include the suite, and
+	// "disabled" and "not-sandboxed" if those apply to it.
+	Tags []string `json:"tags"`
+}
+
+// catalog returns an entry for every suite in configs/suites.toml in fsys,
+// in the order they appear there.
+func catalog(fsys fs.FS) ([]CatalogEntry, error) {
+	var suites Todo
+	if err := decodeTOML(fsys, "configs/suites.toml", &suites); err != nil {
+		return nil, err
+	}
+	lists, err := fs.Glob(fsys, "configs/benchmarks-*.toml")
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(lists)
+
+	entries := make([]CatalogEntry, len(suites.Suites))
+	byName := make(map[string]*CatalogEntry)
+	for i, s := range suites.Suites {
+		entries[i] = CatalogEntry{
+			Name:       s.Name,
+			Repo:       s.Repo,
+			Version:    s.Version,
+			Benchmarks: make(map[string]string),
+			Tags:       []string{},
+		}
+		byName[s.Name] = &entries[i]
+	}
+	for _, file := range lists {
+		list := strings.TrimSuffix(strings.TrimPrefix(path.Base(file), "benchmarks-"), ".toml")
+		var todo Todo
+		if err := decodeTOML(fsys, file, &todo); err != nil {
+			return nil, err
+		}
+		for _, b := range todo.Benchmarks {
+			e := byName[b.Name]
+			if e == nil {
+				return nil, fmt.Errorf("benchmark %s appearing in %s is not listed in suites.toml", b.Name, path.Base(file))
+			}
+			re := b.Benchmarks
+			if re == "" {
+				re = suiteDefault(suites.Suites, b.Name)
+			}
+			e.Benchmarks[list] = re
+			e.Tags = append(e.Tags, list)
+		}
+	}
+	for i, s := range suites.Suites {
+		if s.Disabled {
+			entries[i].Tags = append(entries[i].Tags, "disabled")
+		}
+		if s.NotSandboxed {
+			entries[i].Tags = append(entries[i].Tags, "not-sandboxed")
+		}
+	}
+	return entries, nil
+}
+
+// decodeTOML unmarshals the TOML file in fsys into v.
+func decodeTOML(fsys fs.FS, file string, v any) error {
+	blob, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return err
+	}
+	if err := toml.Unmarshal(blob, v); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	return nil
+}
+
+// suiteDefault returns the default benchmark regexp of the named suite.
+func suiteDefault(suites []Suite, name string) string {
+	for _, s := range suites {
+		if s.Name == name {
+			return s.Benchmarks
+		}
+	}
+	return ""
+}
+
+// writeCatalog writes entries to w in format, which is "markdown" or "json".
+func writeCatalog(w io.Writer, entries []CatalogEntry, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "markdown":
+		fmt.Fprintln(w, "| Name | Repo | Version | Benchmarks | Tags |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+		for _, e := range entries {
+			fmt.Fprintf(w, "| %s | `%s` | %s | %s | %s |\n",
+				e.Name, e.Repo, strings.TrimPrefix(e.Version, "@"), markdownBenchmarks(e), strings.Join(e.Tags, ", "))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown catalog format %q, want markdown or json", format)
+}
+
+// markdownBenchmarks formats the benchmark regexps of e for a table cell,
+// giving the list each one comes from only if the lists disagree.
+func markdownBenchmarks(e CatalogEntry) string {
+	cell := func(re string) string {
+		return "`" + strings.ReplaceAll(re, "|", `\|`) + "`"
+	}
+	var res []string
+	for _, list := range e.Tags {
+		if re, ok := e.Benchmarks[list]; ok && !slices.Contains(res, re) {
+			res = append(res, re)
+		}
+	}
+	if len(res) == 1 {
+		return cell(res[0])
+	}
+	var parts []string
+	for _, list := range e.Tags {
+		if re, ok := e.Benchmarks[list]; ok {
+			parts = append(parts, list+": "+cell(re))
+		}
+	}
+	return strings.Join(parts, "<br>")
+}
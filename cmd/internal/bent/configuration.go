@@ -4,7 +4,7 @@
 
 //go:build go1.16
 
-package main
+package bent
 
 import (
 	"bufio"
@@ -18,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,6 +28,8 @@ import (
 type Configuration struct {
 	Name        string   // Short name used for binary names, mention on command line
 	Root        string   // Specific Go root to use for this trial
+	Ref         string   // Commit, branch, tag or Gerrit CL ("CL 12345" or "12345/3") of the Go repository to build a Go root from, instead of Root
+	Release     string   // Go release (e.g., "go1.22.5") whose official binary distribution to use as the Go root, instead of Root or Ref
 	PgoGen      string   // Name of sub-directory to put profiles for later loading
 	PgoUse      string   // Name of sub-directory to take generated profile files
 	BuildFlags  []string // BuildFlags supplied to 'go test -c' for building (e.g., "-p 1")
@@ -37,9 +40,15 @@ type Configuration struct {
 	RunFlags    []string // Extra flags passed to the test binary
 	RunEnv      []string // Extra environment variables passed to the test binary
 	RunWrapper  []string // (Outermost) Command and args to precede whatever the operation is; may fail in the sandbox.
+	Godebug     []string // GODEBUG settings (e.g., "madvdontneed=1") to also run this configuration's binaries with, each as a sub-configuration
+	PerfStat    bool     // Run each benchmark under 'perf stat' and report its counters with its results; needs perf, and not for sandboxed benchmarks
 	Disabled    bool     // True if this configuration is temporarily disabled
-	benchWriter *os.File
-	rootCopy    string // The contents of GOROOT are copied here to allow benchmarking of just the test compilation.
+	benchWriter *benchOutput
+	rootCopy    string   // The contents of GOROOT are copied here to allow benchmarking of just the test compilation.
+	sweepEnv    []string // Environment variables set by -sweep, e.g. "GOGC=50"; these override RunEnv.
+	sweepFlags  []string // Build flags added by -flag-sweep, e.g. "gcflags=-d=checkptr".
+	godebug     string   // For a sub-configuration expanded from Godebug, its GODEBUG setting; this overrides RunEnv.
+	binConfig   string   // For a sub-configuration expanded from Godebug, the name of the configuration whose binaries it runs.
 }
 
 var dirs *directories // constant across all configurations, useful in other contexts.
@@ -56,7 +65,11 @@ func (c *Configuration) thingBenchName(suffix string) string {
 }
 
 func (c *Configuration) benchName(b *Benchmark, count int, randomizingBinaries bool) string {
-	n := b.Name + "_" + c.Name
+	name := c.Name
+	if c.binConfig != "" {
+		name = c.binConfig
+	}
+	n := b.Name + "_" + name
 	if randomizingBinaries {
 		n += "_" + strconv.FormatInt(int64(count), 10)
 	}
@@ -64,6 +77,174 @@ func (c *Configuration) benchName(b *Benchmark, count int, randomizingBinaries b
 	return n
 }
 
+// sweepKeys returns benchfmt configuration lines describing the -sweep
+// and -flag-sweep values applied to c, e.g. "gogc: 50\n".
+func (c *Configuration) sweepKeys() string {
+	s := ""
+	for _, e := range c.sweepEnv {
+		k, v, _ := strings.Cut(e, "=")
+		s += strings.ToLower(k) + ": " + v + "\n"
+	}
+	for _, f := range c.sweepFlags {
+		k, v, _ := strings.Cut(f, "=")
+		if v == "" {
+			v = "none"
+		}
+		s += k + ": " + v + "\n"
+	}
+	return s
+}
+
+// expandSweeps returns configs with each configuration replaced by one
+// variant for every combination of values in sweeps.  Each variant has
+// the sweep variables appended to its environment and a name suffixed
+// with those variables and values, for example "Tip-GOGC50-GOMEMLIMIT1GiB".
+func expandSweeps(configs []Configuration, sweeps []sweep) []Configuration {
+	for _, sw := range sweeps {
+		var expanded []Configuration
+		for _, c := range configs {
+			for _, v := range sw.values {
+				x := c
+				x.Name = c.Name + "-" + sw.name + v
+				x.sweepEnv = append(append([]string{}, c.sweepEnv...), sw.name+"="+v)
+				expanded = append(expanded, x)
+			}
+		}
+		configs = expanded
+	}
+	return configs
+}
+
+// expandFlagSweeps returns configs with each configuration replaced by one
+// variant for every combination of build flags in sweeps.  Each variant
+// has the flags added to its own and a name suffixed with the kind of
+// flags and a version of them fit for a file name, for example
+// "Tip-gcflags-dcheckptr" for -gcflags=-d=checkptr.
+func expandFlagSweeps(configs []Configuration, sweeps []flagSweep) []Configuration {
+	for _, sw := range sweeps {
+		var expanded []Configuration
+		for _, c := range configs {
+			for _, v := range sw.values {
+				x := c
+				x.Name = c.Name + "-" + sw.kind + "-" + flagSweepName(v)
+				x.sweepFlags = append(append([]string{}, c.sweepFlags...), sw.kind+"="+v)
+				x.addBuildFlags(sw.kind, v)
+				expanded = append(expanded, x)
+			}
+		}
+		configs = expanded
+	}
+	return configs
+}
+
+// flagSweepName returns the letters and digits of the swept flags v, or
+// "none" if there are none.
+func flagSweepName(v string) string {
+	name := strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return -1
+	}, v)
+	if name == "" {
+		return "none"
+	}
+	return name
+}
+
+// addBuildFlags adds v to c's build flags of the given kind, one of those
+// -flag-sweep accepts.
+func (c *Configuration) addBuildFlags(kind, v string) {
+	if v == "" {
+		return
+	}
+	switch kind {
+	case "gcflags":
+		c.GcFlags = strings.TrimSpace(c.GcFlags + " " + v)
+	case "ldflags":
+		c.LdFlags = strings.TrimSpace(c.LdFlags + " " + v)
+	case "goflags":
+		goflags := v
+		env := make([]string, 0, len(c.GcEnv)+1)
+		for _, e := range c.GcEnv {
+			if old, ok := strings.CutPrefix(e, "GOFLAGS="); ok {
+				goflags = strings.TrimSpace(old + " " + v)
+				continue
+			}
+			env = append(env, e)
+		}
+		c.GcEnv = append(env, "GOFLAGS="+goflags)
+	}
+}
+
+// godebugName returns the name of the sub-configuration of c for the
+// GODEBUG setting g, for example "Tip-madvdontneed1" for "madvdontneed=1".
+func (c *Configuration) godebugName(g string) string {
+	return c.Name + "-" + strings.NewReplacer("=", "", ",", "-").Replace(g)
+}
+
+// validGodebug reports whether g is a GODEBUG setting, or a
+// comma-separated list of them, such as "gctrace=1,madvdontneed=1".
+func validGodebug(g string) bool {
+	for _, kv := range strings.Split(g, ",") {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" || strings.ContainsAny(kv, " \t/") {
+			return false
+		}
+	}
+	return true
+}
+
+// expandGodebugs returns configs with each configuration followed by one
+// sub-configuration for each of its Godebug settings.  A sub-configuration
+// runs the binaries of the configuration it was expanded from, with its
+// setting added to GODEBUG, so it needs no building of its own.
+func expandGodebugs(configs []Configuration) []Configuration {
+	var expanded []Configuration
+	for _, c := range configs {
+		expanded = append(expanded, c)
+		for _, g := range c.Godebug {
+			x := c
+			x.Name = c.godebugName(g)
+			x.Godebug = nil
+			x.godebug = g
+			x.binConfig = c.Name
+			x.benchWriter = nil
+			x.PgoGen = "" // Profiles for PGO come from the configuration itself.
+			expanded = append(expanded, x)
+		}
+	}
+	return expanded
+}
+
+// withGodebug returns env with c's GODEBUG setting, if it has one, added
+// to the end of any GODEBUG already in env, so that it takes precedence.
+func (c *Configuration) withGodebug(env []string) []string {
+	if c.godebug == "" {
+		return env
+	}
+	g := c.godebug
+	for _, e := range env {
+		if v, ok := strings.CutPrefix(e, "GODEBUG="); ok && v != "" {
+			g = v + "," + c.godebug
+		}
+	}
+	return replaceEnv(env, "GODEBUG", g)
+}
+
+// sandboxGodebug returns the GODEBUG for c's sandboxed runs, whose
+// environment is otherwise only runEnv: any GODEBUG that c's RunEnv sets,
+// expanded against runEnv, with c's own setting added, as for unsandboxed
+// runs, or "" if there is none.
+func (c *Configuration) sandboxGodebug(runEnv []string) string {
+	g := ""
+	for _, e := range c.withGodebug(sliceExpandEnv(c.RunEnv, runEnv)) {
+		if v, ok := strings.CutPrefix(e, "GODEBUG="); ok {
+			g = v
+		}
+	}
+	return g
+}
+
 func (c *Configuration) goCommandCopy() string {
 	gocmd := "go"
 	if c.rootCopy != "" {
@@ -118,14 +299,20 @@ func (config *Configuration) runOtherBenchmarks(b *Benchmark, cwd string, cmdEnv
 		}
 		f.Write([]byte(s))
 
-		if !strings.ContainsAny(cmd, "/") {
-			cmd = path.Join(cwd, cmd)
+		// AfterBuild commands run outside any sandbox, so bent can run
+		// its own versions of the scripts it has them for.
+		cmdLine := builtinCommand(cmd)
+		if cmdLine == nil {
+			if !strings.ContainsAny(cmd, "/") {
+				cmd = path.Join(cwd, cmd)
+			}
+			cmdLine = []string{cmd}
 		}
 		if b.Disabled {
 			continue
 		}
 		testBinaryName := config.benchName(b, count, randomizingBinaries)
-		c := exec.Command(cmd, path.Join(cwd, dirs.testBinDir, testBinaryName), strings.Title(b.Name))
+		c := exec.Command(cmdLine[0], append(cmdLine[1:], path.Join(cwd, dirs.testBinDir, testBinaryName), strings.Title(b.Name))...)
 
 		c.Env = cmdEnv
 		if !b.NotSandboxed {
@@ -258,9 +445,10 @@ func (config *Configuration) compileOne(bench *Benchmark, cwd string, count int,
 		buf.WriteString(s)
 		f, err := os.OpenFile(config.buildBenchName(), os.O_WRONLY|os.O_APPEND, os.ModePerm)
 		if err != nil {
-			fmt.Printf("There was an error opening %s for append, error %v\n", config.buildBenchName(), err)
+			s := fmt.Sprintf("There was an error opening %s for append, error %v", config.buildBenchName(), err)
+			fmt.Println(s)
 			cleanup(gopath)
-			os.Exit(2)
+			return s
 		}
 		f.Write(buf.Bytes())
 		f.Sync()
@@ -285,20 +473,53 @@ func (config *Configuration) compileOne(bench *Benchmark, cwd string, count int,
 	return ""
 }
 
-// say writes s to c's benchmark output file
-func (c *Configuration) say(s string) {
+// say writes s to the benchmark output w, and displays it.
+func say(w io.Writer, s string) {
 	b := []byte(s)
-	nw, err := c.benchWriter.Write(b)
+	nw, err := w.Write(b)
 	if err != nil {
 		fmt.Printf("Error writing, err = %v, nwritten = %d, nrequested = %d\n", err, nw, len(b))
 	}
-	c.benchWriter.Sync()
 	fmt.Print(string(b))
 }
 
-// runBinary runs cmd and displays the output.
+// runBench runs cmd, a run of benchmark b, recording its output to w under
+// the benchmark's name and the configuration's for benchstat. A warmup
+// run's output is discarded instead.
+func (c *Configuration) runBench(w io.Writer, cwd string, b *Benchmark, cmd *exec.Cmd, timeout time.Duration, warmup bool) (string, int) {
+	if warmup {
+		return runWarmup(cwd, cmd, timeout)
+	}
+	say(w, "\n") // force a newline, there may have been loggy-gunk before this.
+	say(w, "shortname: "+b.Name+"\n")
+	say(w, "toolchain: "+c.Name+"\n")
+	say(w, c.sweepKeys())
+	if c.godebug != "" {
+		say(w, "godebug: "+c.godebug+"\n")
+	}
+	return runBinary(w, cwd, cmd, false, timeout)
+}
+
+// runWarmup runs cmd like runBinary, but without displaying or recording
+// its output, except in the error string if it fails.
+func runWarmup(cwd string, cmd *exec.Cmd, timeout time.Duration) (string, int) {
+	line := asCommandLine(cwd, cmd)
+	if verbose > 0 {
+		fmt.Println("warmup:", line)
+	}
+	var out bytes.Buffer
+	s, rc := runCommand(&out, io.Discard, line, cmd, timeout)
+	if s != "" && rc != timeoutRC {
+		s += ", output:\n" + out.String()
+	}
+	return s, rc
+}
+
+// runBinary runs cmd, writes its output to w and displays it.
 // If the command returns an error, returns an error string.
-func (c *Configuration) runBinary(cwd string, cmd *exec.Cmd, printWorkingDot bool) (string, int) {
+// If timeout is positive and cmd runs for longer than that, cmd and
+// any processes it started are killed, and the return code is timeoutRC.
+func runBinary(w io.Writer, cwd string, cmd *exec.Cmd, printWorkingDot bool, timeout time.Duration) (string, int) {
 	line := asCommandLine(cwd, cmd)
 	if verbose > 0 {
 		fmt.Println(line)
@@ -307,7 +528,12 @@ func (c *Configuration) runBinary(cwd string, cmd *exec.Cmd, printWorkingDot boo
 			fmt.Print(".")
 		}
 	}
+	return runCommand(w, os.Stdout, line, cmd, timeout)
+}
 
+// runCommand runs cmd, whose command line is line, as runBinary does,
+// writing its output to both w and display.
+func runCommand(w, display io.Writer, line string, cmd *exec.Cmd, timeout time.Duration) (string, int) {
 	rc := 0
 
 	stdout, err := cmd.StdoutPipe()
@@ -318,11 +544,23 @@ func (c *Configuration) runBinary(cwd string, cmd *exec.Cmd, printWorkingDot boo
 	if err != nil {
 		return fmt.Sprintf("Error [stderrpipe] running '%s', %v", line, err), rc
 	}
+	if timeout > 0 {
+		setProcessGroup(cmd)
+	}
 	err = cmd.Start()
 	if err != nil {
 		return fmt.Sprintf("Error [command start] running '%s', %v", line, err), rc
 	}
 
+	var timedOut atomic.Bool
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			killProcessGroup(cmd)
+		})
+	}
+
 	var mu = &sync.Mutex{}
 
 	f := func(r *bufio.Reader, done chan error) {
@@ -331,12 +569,11 @@ func (c *Configuration) runBinary(cwd string, cmd *exec.Cmd, printWorkingDot boo
 			n := len(bytes)
 			if n > 0 {
 				mu.Lock()
-				nw, err := c.benchWriter.Write(bytes[0:n])
+				nw, err := w.Write(bytes[0:n])
 				if err != nil {
 					fmt.Printf("Error writing, err = %v, nwritten = %d, nrequested = %d\n", err, nw, n)
 				}
-				c.benchWriter.Sync()
-				fmt.Print(string(bytes[0:n]))
+				display.Write(bytes[0:n])
 				mu.Unlock()
 			}
 			if err == io.EOF || n == 0 {
@@ -359,9 +596,21 @@ func (c *Configuration) runBinary(cwd string, cmd *exec.Cmd, printWorkingDot boo
 	errS := <-doneS
 	errE := <-doneE
 
+	if timer != nil {
+		// Stop before waiting, so the process group can't be killed after
+		// it has been reaped and its ID reused.
+		timer.Stop()
+	}
 	err = cmd.Wait()
 	rc = cmd.ProcessState.ExitCode()
 
+	if timedOut.Load() {
+		msg := fmt.Sprintf("\nKilled after timeout of %v\n", timeout)
+		io.WriteString(w, msg)
+		io.WriteString(display, msg)
+		return fmt.Sprintf("Timeout (%v) running '%s', killed", timeout, line), timeoutRC
+	}
+
 	if err != nil {
 		switch e := err.(type) {
 		case *exec.ExitError:
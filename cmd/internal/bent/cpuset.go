@@ -0,0 +1,88 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// housekeepingCPU is the CPU left to bent and the rest of the system by
+// -cpuset=auto. Interrupts and kernel threads usually favor CPU 0.
+const housekeepingCPU = 0
+
+// ParseCPUList parses a list of CPUs in the kernel's format, as in
+// "0-3,8,10-11", returning the CPUs in increasing order.
+func ParseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, r := range strings.Split(strings.TrimSpace(s), ",") {
+		if r == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(hi)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU list %q", s)
+			}
+		}
+		for c := first; c <= last; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	slices.Sort(cpus)
+	return slices.Compact(cpus), nil
+}
+
+// FormatCPUList formats cpus, which must be in increasing order, in the
+// kernel's format.
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// cpusetCPUs returns the CPUs for -cpuset=spec, given the online CPUs:
+// either spec itself, or for "auto", all the online CPUs but the
+// housekeeping CPU.
+func cpusetCPUs(spec, online string) (string, error) {
+	if spec != "auto" {
+		cpus, err := ParseCPUList(spec)
+		if err != nil {
+			return "", err
+		}
+		if len(cpus) == 0 {
+			return "", fmt.Errorf("empty CPU list %q", spec)
+		}
+		return FormatCPUList(cpus), nil
+	}
+	cpus, err := ParseCPUList(online)
+	if err != nil {
+		return "", err
+	}
+	cpus = slices.DeleteFunc(cpus, func(c int) bool { return c == housekeepingCPU })
+	if len(cpus) == 0 {
+		return "", fmt.Errorf("-cpuset=auto needs more than one online CPU")
+	}
+	return FormatCPUList(cpus), nil
+}
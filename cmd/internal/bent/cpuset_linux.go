@@ -0,0 +1,149 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// A cpuset is a cgroup (v2) whose processes may only run on a dedicated set
+// of CPUs, which benchmark runs are started in so that they don't compete
+// with bent itself or other housekeeping work on the machine.
+type cpuset struct {
+	dir string
+	fd  *os.File
+}
+
+// newCpuset creates a cpuset for the CPUs selected by spec (see
+// cpusetCPUs) as a child of the cgroup parent, which must be writable:
+// either bent runs as root, or parent has been delegated to its user.
+// If exclusive is set and it can, it makes the cpuset a partition root, so
+// that its CPUs are taken away from every other cgroup.
+func newCpuset(parent, spec string, exclusive bool) (*cpuset, error) {
+	cpus, err := cpusetCPUs(spec, readTrimmed("/sys/devices/system/cpu/online"))
+	if err != nil {
+		return nil, err
+	}
+	controllers := " " + readTrimmed(filepath.Join(parent, "cgroup.controllers")) + " "
+	if !strings.Contains(controllers, " cpuset ") {
+		return nil, fmt.Errorf("cpuset controller not available in %s (is it a cgroup v2 hierarchy?)", parent)
+	}
+	if err := leaveCgroup(parent); err != nil {
+		return nil, err
+	}
+	if err := writeCgroupFile(parent, "cgroup.subtree_control", "+cpuset"); err != nil {
+		return nil, err
+	}
+	if strings.Contains(controllers, " memory ") {
+		// For memory.events, to tell the runs' OOM kills from others on
+		// the machine; without it, bent falls back to the machine's count.
+		writeCgroupFile(parent, "cgroup.subtree_control", "+memory")
+	}
+	dir := filepath.Join(parent, fmt.Sprintf("bent-%d", os.Getpid()))
+	if verbose > 0 {
+		fmt.Printf("mkdir %s\n", dir)
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &cpuset{dir: dir}
+	if err := writeCgroupFile(dir, "cpuset.cpus", cpus); err != nil {
+		c.remove()
+		return nil, err
+	}
+	if exclusive {
+		if err := writeCgroupFile(dir, "cpuset.cpus.partition", "root"); err != nil {
+			fmt.Printf("Warning: could not make cpuset %s exclusive, other processes may still use its CPUs: %v\n", dir, err)
+		}
+	}
+	c.fd, err = os.Open(dir)
+	if err != nil {
+		c.remove()
+		return nil, err
+	}
+	return c, nil
+}
+
+// leaveCgroup moves the processes in the cgroup dir, if it isn't the root,
+// into a leaf cgroup of their own, bent-<pid>-self. A cgroup with processes
+// in it can't enable controllers for its children, and that is the case of
+// one delegated with "systemd-run --user --scope -p Delegate=yes", which
+// bent runs in. The leaf is left behind for the scope to remove, as bent
+// itself is in it until it exits.
+func leaveCgroup(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.type")); err != nil {
+		// The root cgroup has no type, and may have both processes and
+		// controllers enabled for its children.
+		return nil
+	}
+	procs := strings.Fields(readTrimmed(filepath.Join(dir, "cgroup.procs")))
+	if len(procs) == 0 {
+		return nil
+	}
+	leaf := filepath.Join(dir, fmt.Sprintf("bent-%d-self", os.Getpid()))
+	if verbose > 0 {
+		fmt.Printf("mkdir %s\n", leaf)
+	}
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	for _, pid := range procs {
+		// A process may have exited since it was listed.
+		if err := writeCgroupFile(leaf, "cgroup.procs", pid); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	name := filepath.Join(dir, file)
+	if verbose > 0 {
+		fmt.Printf("echo %s > %s\n", value, name)
+	}
+	if err := os.WriteFile(name, []byte(value), 0644); err != nil {
+		return fmt.Errorf("writing %q to %s: %w", value, name, err)
+	}
+	return nil
+}
+
+// cpus returns the CPUs the cpuset's processes actually run on.
+func (c *cpuset) cpus() string {
+	return readTrimmed(filepath.Join(c.dir, "cpuset.cpus.effective"))
+}
+
+// record adds the cpuset's configuration to m.
+func (c *cpuset) record(m *machineState) {
+	m.set("cpuset", c.cpus())
+	m.set("cpuset-partition", readTrimmed(filepath.Join(c.dir, "cpuset.cpus.partition")))
+}
+
+// apply arranges for cmd to start in the cpuset.
+func (c *cpuset) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.fd.Fd())
+}
+
+// remove removes the cpuset, which must have no processes left in it.
+func (c *cpuset) remove() {
+	if c.fd != nil {
+		c.fd.Close()
+	}
+	if verbose > 0 {
+		fmt.Printf("rmdir %s\n", c.dir)
+	}
+	if err := os.Remove(c.dir); err != nil {
+		fmt.Printf("Warning: failed to remove cpuset %s: %v\n", c.dir, err)
+	}
+}
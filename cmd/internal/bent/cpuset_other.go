@@ -0,0 +1,25 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package bent
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// A cpuset is a dedicated set of CPUs for benchmark runs; they are only
+// supported on Linux.
+type cpuset struct{}
+
+func newCpuset(parent, spec string, exclusive bool) (*cpuset, error) {
+	return nil, fmt.Errorf("-cpuset is only supported on Linux")
+}
+
+func (c *cpuset) cpus() string           { return "" }
+func (c *cpuset) record(m *machineState) {}
+func (c *cpuset) apply(cmd *exec.Cmd)    {}
+func (c *cpuset) remove()                {}
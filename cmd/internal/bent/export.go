@@ -0,0 +1,136 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// A resultRow is one value from a benchmark result line, flattened for
+// export.
+type resultRow struct {
+	benchmark string // bent benchmark, e.g. "gonum_topo"
+	config    string
+	iteration int    // Counts the results for the same benchmark and metric, from 0.
+	metric    string // Go benchmark, e.g. "TarjanSCCGnp_1000_half-8"
+	ops       int    // The number of operations the result is for, b.N.
+	unit      string
+	value     float64
+}
+
+// parseResults parses the benchmark output in r, which was produced for
+// configuration config, into rows. The bent benchmark for each result is
+// given by the "shortname" configuration line before it, or if there is
+// none, as for build results, by the result's own name.
+func parseResults(r io.Reader, config string) ([]resultRow, error) {
+	var rows []resultRow
+	counts := make(map[string]int)
+	shortname := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "shortname: "); ok {
+			shortname = strings.TrimSpace(v)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		ops, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue // Not a result line, just something beginning with "Benchmark".
+		}
+		metric := strings.TrimPrefix(fields[0], "Benchmark")
+		benchmark := shortname
+		if benchmark == "" {
+			benchmark = metric
+		}
+		key := benchmark + "\x00" + metric
+		iteration := counts[key]
+		counts[key]++
+		for i := 2; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			rows = append(rows, resultRow{benchmark, config, iteration, metric, ops, fields[i+1], v})
+		}
+	}
+	return rows, scanner.Err()
+}
+
+// writeResults writes rows to w as comma- or tab-separated values, with a
+// header line.
+func writeResults(w io.Writer, rows []resultRow, sep rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = sep
+	cw.Write([]string{"benchmark", "config", "iteration", "metric", "unit", "value"})
+	for _, r := range rows {
+		cw.Write([]string{r.benchmark, r.config, strconv.Itoa(r.iteration), r.metric, r.unit, strconv.FormatFloat(r.value, 'g', -1, 64)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readResults reads every result of this run, from the benchmark, build
+// and AfterBuild output files of each enabled configuration.
+func readResults(configs []Configuration) ([]resultRow, error) {
+	var rows []resultRow
+	for i := range configs {
+		c := &configs[i]
+		if c.Disabled {
+			continue
+		}
+		for _, suffix := range append([]string{"stdout", "build"}, c.AfterBuild...) {
+			f, err := os.Open(c.thingBenchName(suffix))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			r, err := parseResults(f, c.Name)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %v", f.Name(), err)
+			}
+			rows = append(rows, r...)
+		}
+	}
+	return rows, nil
+}
+
+// exportResults writes every result of this run to a single file in the
+// bench directory in the given format, "csv" or "tsv", and returns its
+// name.
+func exportResults(configs []Configuration, format string) (string, error) {
+	sep := ','
+	if format == "tsv" {
+		sep = '\t'
+	}
+	rows, err := readResults(configs)
+	if err != nil {
+		return "", err
+	}
+
+	name := path.Join(dirs.benchDir, runstamp+"."+format)
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if err := writeResults(f, rows, sep); err != nil {
+		f.Close()
+		return "", err
+	}
+	return name, f.Close()
+}
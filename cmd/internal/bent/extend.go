@@ -0,0 +1,74 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// extensionDir is a directory of TOML files, each containing suites and
+// benchmarks, that are merged into the ones read from -B and suites.toml.
+// This lets private benchmarks be added without editing the standard files.
+const extensionDir = "suites.d"
+
+// extensionFiles returns the extension files named on the command line,
+// followed by those in extensionDir in lexical order.
+func extensionFiles(flagFiles []string) ([]string, error) {
+	files := append([]string{}, flagFiles...)
+	matches, err := filepath.Glob(filepath.Join(extensionDir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return append(files, matches...), nil
+}
+
+// mergeExtensions adds the suites and benchmarks in each of files to todo.
+// A suite or benchmark in an extension may not have the same name as one
+// already in todo or in another extension; extensions add to the standard
+// sets but never silently replace anything in them.
+func mergeExtensions(todo *Todo, files []string) error {
+	suiteFrom := make(map[string]string)
+	for _, s := range todo.Suites {
+		suiteFrom[s.Name] = suiteFile
+	}
+	benchFrom := make(map[string]string)
+	for _, b := range todo.Benchmarks {
+		benchFrom[b.Name] = benchFile
+	}
+	for _, file := range files {
+		blob, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var ext Todo
+		if err := toml.Unmarshal(blob, &ext); err != nil {
+			return fmt.Errorf("unmarshalling %s: %v", file, err)
+		}
+		if len(ext.Configurations) != 0 {
+			return fmt.Errorf("%s: configurations are not allowed in suite extensions, use -C", file)
+		}
+		for _, s := range ext.Suites {
+			if from, ok := suiteFrom[s.Name]; ok {
+				return fmt.Errorf("%s: suite %s is already defined in %s", file, s.Name, from)
+			}
+			suiteFrom[s.Name] = file
+		}
+		for _, b := range ext.Benchmarks {
+			if from, ok := benchFrom[b.Name]; ok {
+				return fmt.Errorf("%s: benchmark %s is already defined in %s", file, b.Name, from)
+			}
+			benchFrom[b.Name] = file
+		}
+		todo.Suites = append(todo.Suites, ext.Suites...)
+		todo.Benchmarks = append(todo.Benchmarks, ext.Benchmarks...)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goRepo is the repository that configuration Refs are fetched from.
+const goRepo = "https://go.googlesource.com/go"
+
+var (
+	commitRE = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	clRE     = regexp.MustCompile(`^(?:CL ?)?([0-9]+)(?:/([0-9]+))?$`)
+)
+
+// changeRef returns the Gerrit ref for patch set ps of CL cl, or, if ps is
+// empty, the pattern matching all of its patch sets.
+func changeRef(cl, ps string) string {
+	shard := cl
+	if len(shard) > 2 {
+		shard = shard[len(shard)-2:]
+	} else if len(shard) < 2 {
+		shard = "0" + shard
+	}
+	if ps == "" {
+		ps = "*"
+	}
+	return fmt.Sprintf("refs/changes/%s/%s/%s", shard, cl, ps)
+}
+
+// latestPatchSet returns the commit and ref of the highest-numbered patch
+// set in the output of git ls-remote listing a CL's refs.
+func latestPatchSet(lsRemote string) (commit, ref string) {
+	best := -1
+	for _, line := range strings.Split(lsRemote, "\n") {
+		c, r, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(path.Base(r)) // Skips refs/changes/NN/CL/meta.
+		if err != nil || n <= best {
+			continue
+		}
+		best, commit, ref = n, c, r
+	}
+	return commit, ref
+}
+
+// resolveRef returns the commit that ref, a commit hash, a branch or tag, or
+// a Gerrit CL with an optional patch set ("CL 12345", "12345" or "12345/3"),
+// currently refers to in goRepo, and the ref to fetch it with.
+func resolveRef(ref string) (commit, fetch string, err error) {
+	if commitRE.MatchString(ref) {
+		return ref, ref, nil
+	}
+	pattern := ref
+	if m := clRE.FindStringSubmatch(ref); m != nil {
+		pattern = changeRef(m[1], m[2])
+	}
+	cmd := exec.Command("git", "ls-remote", goRepo, pattern)
+	if verbose > 0 {
+		fmt.Println(asCommandLine(dirs.wd, cmd))
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git ls-remote %s %s: %v", goRepo, pattern, err)
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		commit, fetch = latestPatchSet(string(out))
+	} else if c, r, ok := strings.Cut(strings.SplitN(string(out), "\n", 2)[0], "\t"); ok {
+		commit, fetch = c, r
+	}
+	if commit == "" {
+		return "", "", fmt.Errorf("%s not found in %s", ref, goRepo)
+	}
+	return commit, fetch, nil
+}
+
+// builtRoots caches the GOROOTs already built for each Ref in this run.
+var builtRoots = make(map[string]string)
+
+// goRootForRef returns a GOROOT containing a Go toolchain built from ref (see
+// resolveRef) by make.bash. Toolchains are kept in the goroots directory by
+// commit, so each commit is only fetched and built once, however many
+// configurations and runs use it.
+func goRootForRef(ref string) (string, error) {
+	if root, ok := builtRoots[ref]; ok {
+		return root, nil
+	}
+	commit, fetch, err := resolveRef(ref)
+	if err != nil {
+		return "", err
+	}
+	root := path.Join(dirs.goroots, "ref-"+commit)
+	if _, err := os.Stat(path.Join(root, "bin", "go")); err == nil {
+		fmt.Printf("Using Go toolchain for %s (commit %s) from %s\n", ref, commit, root)
+		builtRoots[ref] = root
+		return root, nil
+	}
+
+	// Build somewhere else first, so that a failed or interrupted build
+	// is never mistaken for a finished one.
+	fmt.Printf("Building Go toolchain for %s (commit %s)\n", ref, commit)
+	partial := root + ".partial"
+	if verbose > 0 {
+		fmt.Printf("rm -rf %s\n", partial)
+	}
+	if err := os.RemoveAll(partial); err != nil {
+		return "", err
+	}
+	if err := mkdirAsNeeded(partial); err != nil {
+		return "", err
+	}
+	run := func(dir string, args ...string) error {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = dir
+		if verbose > 0 {
+			fmt.Println(asCommandLine(dirs.wd, cmd))
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return nil
+	}
+	// The Go build derives the toolchain's version from the git checkout,
+	// so build in one rather than in an exported tree.
+	if err := run(partial, "git", "init", "-q"); err != nil {
+		return "", err
+	}
+	if err := run(partial, "git", "fetch", "-q", "--depth=1", goRepo, fetch); err != nil {
+		return "", err
+	}
+	if err := run(partial, "git", "checkout", "-q", "--detach", commit); err != nil {
+		return "", err
+	}
+	if err := run(path.Join(partial, "src"), "./make.bash"); err != nil {
+		return "", err
+	}
+	if verbose > 0 {
+		fmt.Printf("mv %s %s\n", partial, root)
+	}
+	if err := os.Rename(partial, root); err != nil {
+		return "", err
+	}
+	builtRoots[ref] = root
+	return root, nil
+}
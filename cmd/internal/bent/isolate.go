@@ -0,0 +1,84 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// cancelOnSignal returns a copy of ctx that is canceled if bent is
+// interrupted or terminated before the returned stop is called, so that
+// Run stops after the current benchmark run and undoes its changes to the
+// machine on the way out instead of leaving it set up for benchmarking.
+// The cause of the cancellation is an error that ExitCode maps to the
+// status the signal would have given. Only the first signal is caught;
+// another one ends the process as usual.
+func cancelOnSignal(ctx context.Context) (_ context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-c:
+			signal.Stop(c)
+			fmt.Printf("Received %v, stopping after the current run to undo changes to the machine\n", sig)
+			cancel(exitErrorf(128+int(sig.(syscall.Signal)), "Interrupted by %v", sig))
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(c)
+		cancel(nil)
+	}
+}
+
+// housekeepingCPUs returns the online CPUs that are not in bench, which
+// IRQs are moved to by -isolate.
+func housekeepingCPUs(online, bench string) ([]int, error) {
+	all, err := ParseCPUList(online)
+	if err != nil {
+		return nil, err
+	}
+	busy, err := ParseCPUList(bench)
+	if err != nil {
+		return nil, err
+	}
+	cpus := slices.DeleteFunc(all, func(c int) bool { return slices.Contains(busy, c) })
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no online CPUs are left outside %s for IRQs", bench)
+	}
+	return cpus, nil
+}
+
+// formatCPUMask formats cpus as a CPU mask in the kernel's format, as
+// 32-bit hexadecimal words separated by commas, most significant first,
+// as in /proc/irq/default_smp_affinity.
+func formatCPUMask(cpus []int) string {
+	var words []uint32
+	for _, c := range cpus {
+		for len(words) <= c/32 {
+			words = append(words, 0)
+		}
+		words[c/32] |= 1 << (c % 32)
+	}
+	if len(words) == 0 {
+		return "0"
+	}
+	var parts []string
+	for i := len(words) - 1; i >= 0; i-- {
+		if i == len(words)-1 {
+			parts = append(parts, fmt.Sprintf("%x", words[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%08x", words[i]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
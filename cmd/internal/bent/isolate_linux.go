@@ -0,0 +1,156 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// An isolation is the changes -isolate made to keep the rest of the
+// machine off the benchmark CPUs, and what to restore when the run is
+// done.
+type isolation struct {
+	housekeeping string            // CPUs the IRQs were moved to.
+	saved        map[string]string // Files written, to their original contents.
+	order        []string          // Files written, in order.
+	moved        int               // IRQs moved off the benchmark CPUs.
+	stuck        []string          // IRQs whose affinity could not be changed.
+	governor     string            // cpufreq governor the benchmark CPUs were set to, if any.
+}
+
+// isolateCPUs moves every IRQ it can off the CPUs cpus, including any
+// IRQs registered later, and sets their cpufreq governor to performance.
+// It needs root; it prints guidance about whatever it could not change.
+func isolateCPUs(cpus string) (*isolation, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("-isolate must run as root")
+	}
+	bench, err := ParseCPUList(cpus)
+	if err != nil {
+		return nil, err
+	}
+	keep, err := housekeepingCPUs(readTrimmed("/sys/devices/system/cpu/online"), cpus)
+	if err != nil {
+		return nil, err
+	}
+	iso := &isolation{housekeeping: FormatCPUList(keep), saved: make(map[string]string)}
+
+	if err := iso.write("/proc/irq/default_smp_affinity", formatCPUMask(keep)); err != nil {
+		fmt.Printf("Warning: could not set the default IRQ affinity, %v\n", err)
+	}
+	files, _ := filepath.Glob("/proc/irq/[0-9]*/smp_affinity_list")
+	for _, f := range files {
+		affinity, err := ParseCPUList(readTrimmed(f))
+		if err != nil || !slices.ContainsFunc(affinity, func(c int) bool { return slices.Contains(bench, c) }) {
+			continue
+		}
+		if err := iso.write(f, iso.housekeeping); err != nil {
+			iso.stuck = append(iso.stuck, filepath.Base(filepath.Dir(f)))
+			continue
+		}
+		iso.moved++
+	}
+	slices.SortFunc(iso.stuck, func(a, b string) int {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	})
+
+	governed := 0
+	for _, c := range bench {
+		dir := fmt.Sprintf("/sys/devices/system/cpu/cpu%d/cpufreq", c)
+		if !strings.Contains(" "+readTrimmed(filepath.Join(dir, "scaling_available_governors"))+" ", " performance ") {
+			continue
+		}
+		if readTrimmed(filepath.Join(dir, "scaling_governor")) == "performance" {
+			governed++
+			continue
+		}
+		if err := iso.write(filepath.Join(dir, "scaling_governor"), "performance"); err != nil {
+			fmt.Printf("Warning: could not set the cpufreq governor of CPU %d, %v\n", c, err)
+			continue
+		}
+		governed++
+	}
+	if governed == len(bench) {
+		iso.governor = "performance"
+	} else {
+		fmt.Printf("Warning: the performance cpufreq governor is not available on all of CPUs %s\n", cpus)
+	}
+
+	iso.advise(cpus)
+	return iso, nil
+}
+
+// write writes value to the sysfs or procfs file name, first saving its
+// contents to restore.
+func (iso *isolation) write(name, value string) error {
+	old := readTrimmed(name)
+	if verbose > 0 {
+		fmt.Printf("echo %s > %s\n", value, name)
+	}
+	if err := os.WriteFile(name, []byte(value), 0644); err != nil {
+		return fmt.Errorf("writing %q to %s: %w", value, name, err)
+	}
+	if _, ok := iso.saved[name]; !ok {
+		iso.saved[name] = old
+		iso.order = append(iso.order, name)
+	}
+	return nil
+}
+
+// advise prints what is still likely to disturb the benchmark CPUs, and
+// how to fix it, since not everything can be changed at run time.
+func (iso *isolation) advise(cpus string) {
+	if len(iso.stuck) > 0 {
+		fmt.Printf("Warning: IRQs %s could not be moved off CPUs %s.  They are likely managed by the kernel, as\n"+
+			"per-queue interrupts of network and NVMe devices are; booting with isolcpus=managed_irq,%s keeps them off.\n",
+			strings.Join(iso.stuck, " "), cpus, cpus)
+	}
+	if irqbalanceRunning() {
+		fmt.Printf("Warning: irqbalance is running and may move IRQs back onto CPUs %s; stop it for the run\n"+
+			"(e.g. systemctl stop irqbalance) or set IRQBALANCE_BANNED_CPULIST=%s in its configuration.\n", cpus, cpus)
+	}
+	if !strings.Contains(readTrimmed("/proc/cmdline"), "nohz_full=") {
+		fmt.Printf("Note: booting with nohz_full=%s also stops most timer ticks on the benchmark CPUs.\n", cpus)
+	}
+}
+
+func irqbalanceRunning() bool {
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, f := range comms {
+		if readTrimmed(f) == "irqbalance" {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds what the isolation changed to m.
+func (iso *isolation) record(m *machineState) {
+	m.set("isolate-irq-cpus", iso.housekeeping)
+	m.set("isolate-irqs-moved", strconv.Itoa(iso.moved))
+	m.set("isolate-irqs-stuck", strings.Join(iso.stuck, ","))
+	m.set("isolate-governor", iso.governor)
+}
+
+// restore puts back everything the isolation changed, in reverse order.
+func (iso *isolation) restore() {
+	for i := len(iso.order) - 1; i >= 0; i-- {
+		name := iso.order[i]
+		if verbose > 0 {
+			fmt.Printf("echo %s > %s\n", iso.saved[name], name)
+		}
+		if err := os.WriteFile(name, []byte(iso.saved[name]), 0644); err != nil {
+			fmt.Printf("Warning: failed to restore %s, %v\n", name, err)
+		}
+	}
+	iso.order = nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package bent
+
+import "fmt"
+
+// An isolation is the changes -isolate made to keep the machine off the
+// benchmark CPUs; it is only supported on Linux.
+type isolation struct{}
+
+func isolateCPUs(cpus string) (*isolation, error) {
+	return nil, fmt.Errorf("-isolate is only supported on Linux")
+}
+
+func (iso *isolation) record(m *machineState) {}
+func (iso *isolation) restore()               {}
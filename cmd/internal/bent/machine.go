@@ -0,0 +1,135 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// machineState is a snapshot of the parts of the machine's state that
+// commonly explain noisy benchmark results, as benchfmt configuration
+// key/value pairs.  Keys are in the order they should be written.
+type machineState struct {
+	keys   []string
+	values map[string]string
+}
+
+func (m *machineState) set(key, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// String formats m as benchfmt configuration lines.
+func (m *machineState) String() string {
+	var b strings.Builder
+	for _, k := range m.keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, m.values[k])
+	}
+	return b.String()
+}
+
+// readTrimmed returns the trimmed contents of file, or "" if it cannot be read.
+func readTrimmed(file string) string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// snapshotMachineState records the machine state at the start of a run.
+// Anything that cannot be determined on this platform is left out.
+func snapshotMachineState() *machineState {
+	m := &machineState{values: make(map[string]string)}
+	if runtime.GOOS != "linux" {
+		if out, err := exec.Command("uname", "-r").Output(); err == nil {
+			m.set("kernel", string(out))
+		}
+		return m
+	}
+
+	m.set("kernel", readTrimmed("/proc/sys/kernel/osrelease"))
+
+	// Collect the distinct frequency governors, usually there is just one.
+	govs := make(map[string]bool)
+	var govList []string
+	files, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor")
+	for _, f := range files {
+		if g := readTrimmed(f); g != "" && !govs[g] {
+			govs[g] = true
+			govList = append(govList, g)
+		}
+	}
+	m.set("cpufreq-governor", strings.Join(govList, ","))
+
+	// intel_pstate reports no_turbo, acpi-cpufreq reports boost.
+	if v := readTrimmed("/sys/devices/system/cpu/intel_pstate/no_turbo"); v != "" {
+		m.set("turbo", onOff(v == "0"))
+	} else if v := readTrimmed("/sys/devices/system/cpu/cpufreq/boost"); v != "" {
+		m.set("turbo", onOff(v == "1"))
+	}
+
+	if v := readTrimmed("/sys/devices/system/cpu/smt/active"); v != "" {
+		m.set("smt", onOff(v == "1"))
+	}
+
+	if f := strings.Fields(readTrimmed("/proc/loadavg")); len(f) >= 3 {
+		m.set("loadavg", strings.Join(f[:3], " "))
+	}
+
+	// /proc/meminfo reports kB.
+	for _, line := range strings.Split(readTrimmed("/proc/meminfo"), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 || (f[0] != "MemFree:" && f[0] != "MemAvailable:") {
+			continue
+		}
+		if kb, err := strconv.ParseUint(f[1], 10, 64); err == nil {
+			m.set(strings.ToLower(strings.TrimSuffix(f[0], ":"))+"-bytes", strconv.FormatUint(kb*1024, 10))
+		}
+	}
+
+	m.set("container", detectContainer())
+	return m
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// detectContainer returns the kind of container bent is running in,
+// "none" if it appears not to be in one.
+func detectContainer() string {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	if c := readTrimmed("/run/systemd/container"); c != "" {
+		return c
+	}
+	cgroup := readTrimmed("/proc/1/cgroup")
+	for _, kind := range []string{"docker", "kubepods", "lxc", "containerd"} {
+		if strings.Contains(cgroup, kind) {
+			return kind
+		}
+	}
+	return "none"
+}
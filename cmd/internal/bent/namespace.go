@@ -0,0 +1,46 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespaces are the Linux namespaces of their own that unsandboxed
+// benchmark runs get with -unshare, so that, for instance, runs that
+// listen on fixed ports don't collide, as they wouldn't in a container.
+type namespaces struct {
+	net bool // A network namespace, with only a loopback interface.
+	pid bool // A PID namespace, whose processes all go when the run does.
+}
+
+// parseNamespaces parses a -unshare value, a comma-separated list of
+// "net" and "pid".
+func parseNamespaces(s string) (namespaces, error) {
+	var ns namespaces
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "net":
+			ns.net = true
+		case "pid":
+			ns.pid = true
+		default:
+			return namespaces{}, fmt.Errorf("unknown namespace %q, want net or pid", name)
+		}
+	}
+	return ns, nil
+}
+
+func (ns namespaces) String() string {
+	var names []string
+	if ns.net {
+		names = append(names, "net")
+	}
+	if ns.pid {
+		names = append(names, "pid")
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	internalCommands["unshare"] = unshareCommand
+}
+
+// check reports whether runs can have the namespaces, by running true in
+// them.
+func (ns namespaces) check() error {
+	cmd := exec.Command("true")
+	ns.apply(cmd)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("running a command in new %s namespaces: %v\n%s", ns, err, out)
+	}
+	return nil
+}
+
+// apply arranges for cmd to run in new namespaces, under bent itself,
+// which first brings up the new network namespace's loopback interface.
+// Unless bent runs as root, cmd also gets a user namespace, in which it
+// runs as root, since that is what it takes to create the others.
+func (ns namespaces) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	if ns.net {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	if ns.pid {
+		attr.Cloneflags |= syscall.CLONE_NEWPID
+	}
+	if uid, gid := os.Geteuid(), os.Getegid(); uid != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: gid, Size: 1}}
+	}
+	exe, err := os.Executable()
+	if err != nil || cmd.Err != nil {
+		// Run cmd as it is; without a loopback interface, only
+		// benchmarks that use the network will fail.
+		return
+	}
+	cmd.Args[0] = cmd.Path
+	cmd.Args = append([]string{exe, internalPrefix + "unshare"}, cmd.Args...)
+	cmd.Path = exe
+}
+
+// unshareCommand runs args in the namespaces apply put it in, once it has
+// brought up the loopback interface. In a PID namespace, bent is then the
+// init process, so every process the run leaves behind goes when it exits.
+func unshareCommand(args []string) error {
+	if err := loopbackUp(); err != nil {
+		return fmt.Errorf("bringing up the loopback interface: %v", err)
+	}
+	return runWrapped(args)
+}
+
+// loopbackUp brings up the network namespace's loopback interface, if it
+// isn't already.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte // The rest of struct ifreq.
+	}
+	copy(req.name[:], "lo")
+	ioctl := func(op uintptr) error {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), op, uintptr(unsafe.Pointer(&req))); errno != 0 {
+			return errno
+		}
+		return nil
+	}
+	if err := ioctl(syscall.SIOCGIFFLAGS); err != nil {
+		return err
+	}
+	if req.flags&syscall.IFF_UP != 0 {
+		return nil
+	}
+	req.flags |= syscall.IFF_UP
+	return ioctl(syscall.SIOCSIFFLAGS)
+}
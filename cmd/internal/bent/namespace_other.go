@@ -0,0 +1,18 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package bent
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func (ns namespaces) check() error {
+	return fmt.Errorf("-unshare is only supported on Linux")
+}
+
+func (ns namespaces) apply(cmd *exec.Cmd) {}
@@ -0,0 +1,34 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// oomRC is the return code of a run killed by the kernel's out-of-memory
+// killer; it is the same as a shell's for a process killed by SIGKILL.
+const oomRC = 137
+
+// annotateOOM returns the failure s, rc of a run, rewritten to say so, in
+// the run's output w too, if the kernel's out-of-memory killer killed it,
+// given the count of OOM kills in the cpuset c (which may be nil) from
+// before the run started. If ps, the state of the process bent ran, is not
+// nil, the failure includes its peak memory use.
+func annotateOOM(w io.Writer, s string, rc int, c *cpuset, before oomCount, ps *os.ProcessState, warmup bool) (string, int) {
+	if s == "" || rc == timeoutRC || !before.killed(c, ps, rc) {
+		return s, rc
+	}
+	msg := "Killed by the out-of-memory killer"
+	if rss := peakRSS(ps); rss > 0 {
+		msg += fmt.Sprintf(", peak RSS %d MiB", rss>>20)
+	}
+	if !warmup {
+		say(w, "\n"+msg+"\n")
+	}
+	return msg + ": " + s, oomRC
+}
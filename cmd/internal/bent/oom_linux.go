@@ -0,0 +1,88 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// An oomCount is the number of processes the kernel's out-of-memory killer
+// has killed, in a cgroup or since boot, or -1 if that is unknown.
+type oomCount int64
+
+// countOOMKills returns the current oomCount of the cpuset c, if it is not
+// nil and its memory is accounted, and otherwise of the whole machine,
+// which includes sandboxed runs' containers, whose limits are enforced by
+// OOM kills in their own cgroups.
+func countOOMKills(c *cpuset) oomCount {
+	if c != nil {
+		if n := readOOMKills(filepath.Join(c.dir, "memory.events"), "oom_kill "); n >= 0 {
+			return n
+		}
+	}
+	return readOOMKills("/proc/vmstat", "oom_kill ") // Kernels before 4.13 don't count OOM kills.
+}
+
+// readOOMKills returns the count on the line of file starting with
+// prefix, or -1 if there is none.
+func readOOMKills(file, prefix string) oomCount {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(line, prefix); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return -1
+			}
+			return oomCount(n)
+		}
+	}
+	return -1
+}
+
+// killed reports whether a failed run that ended with process state ps
+// (which may be nil, in which case rc must say it died from SIGKILL) and
+// return code rc was killed by the OOM killer, given the count from before
+// it started, in the cpuset c, which may be nil. A run must have died from
+// SIGKILL; if the count went up, it is taken to be the OOM killer's doing,
+// and without counts it is assumed to be, since bent itself only sends
+// that on a timeout.
+func (before oomCount) killed(c *cpuset, ps *os.ProcessState, rc int) bool {
+	if ps != nil {
+		// Wrappers such as shells and -unshare's exit with oomRC when what
+		// they run dies from SIGKILL.
+		ws, ok := ps.Sys().(syscall.WaitStatus)
+		if !ok || !(ws.Signaled() && ws.Signal() == syscall.SIGKILL || ws.Exited() && ws.ExitStatus() == oomRC) {
+			return false
+		}
+	} else if rc != oomRC {
+		return false
+	}
+	if before >= 0 {
+		if after := countOOMKills(c); after >= 0 {
+			return after > before
+		}
+	}
+	return true
+}
+
+// peakRSS returns the peak resident set size of the process that ended
+// with ps, and of any of its descendants that it waited for, in bytes,
+// or 0 if it is unknown.
+func peakRSS(ps *os.ProcessState) int64 {
+	if ps == nil {
+		return 0
+	}
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		return int64(ru.Maxrss) << 10 // Linux reports kilobytes.
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOOMKills(t *testing.T) {
+	events := filepath.Join(t.TempDir(), "memory.events")
+	if err := os.WriteFile(events, []byte("low 0\nhigh 0\nmax 12\noom 2\noom_kill 1\noom_group_kill 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := readOOMKills(events, "oom_kill "); got != 1 {
+		t.Errorf("readOOMKills of memory.events = %d, want 1", got)
+	}
+	if got := readOOMKills(events, "oom_kills "); got != -1 {
+		t.Errorf("readOOMKills of a missing counter = %d, want -1", got)
+	}
+	if got := readOOMKills(filepath.Join(t.TempDir(), "missing"), "oom_kill "); got != -1 {
+		t.Errorf("readOOMKills of a missing file = %d, want -1", got)
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package bent
+
+import "os"
+
+// An oomCount is a count of the processes killed by an out-of-memory
+// killer; only Linux's is detected.
+type oomCount int64
+
+func countOOMKills(c *cpuset) oomCount { return -1 }
+
+func (before oomCount) killed(c *cpuset, ps *os.ProcessState, rc int) bool { return false }
+
+func peakRSS(ps *os.ProcessState) int64 { return 0 }
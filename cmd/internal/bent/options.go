@@ -0,0 +1,270 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Options says what a Run should do. The fields correspond to the
+// command-line flags of bent, which AddFlags registers; start from
+// DefaultOptions, since the zero Options is not a useful one.
+type Options struct {
+	N         int  // Benchmark/test repeat count.
+	R         int  // Randomized build/benchmark repeat count; subsumes All and N.
+	GroupRuns bool // Group runs by benchmark, giving them similar platform noise.
+	All       int  // Number of times to build each test binary with 'go test -c -a'; negative varies configurations least often.
+	Shuffle   int  // Dimensionality of (build) shuffling, 0-3.
+
+	Benchmarks     []string // Names of the benchmarks to run; all of them if empty.
+	BenchFile      string   // File listing the benchmarks.
+	ExtraFiles     []string // Additional files of suites and benchmarks to merge with the standard ones.
+	Configurations []string // Names of the configurations to run; all of them if empty.
+	ConfFile       string   // File describing the configurations.
+	QuarantineFile string   // File listing benchmarks not to run until a given date; it need not exist.
+	Core           []string // Benchmarks to run first and keep in preference to others under TimeBudget.
+
+	Sandbox         bool   // Run benchmarks in a Docker sandbox, excluding those that can't be.
+	GetOnly         bool   // Get the benchmarks and their dependencies, but don't build or run them.
+	RunContainer    string // If nonempty, skip getting and building and run with this container (any string outside the sandbox).
+	Rebuild         bool   // Get and build even if nothing affecting the build has changed since the last run.
+	UseScripts      bool   // Run the embedded shell scripts even where bent implements them itself.
+	StampLog        string // If nonempty, a file to append the run's runstamp to.
+	List            bool   // List the benchmarks and configurations instead of running them.
+	Force           bool   // Run past some of the consistency checks.
+	Initialize      bool   // Initialize the directory for running benchmarks instead of running them.
+	Test            bool   // Run tests instead of benchmarks.
+	Catalog         string // If "markdown" or "json", print the catalog of known benchmarks instead of running them.
+	Experiment      bool   // Don't reset build/*/go.mod, for experimental changes to 3rd party software.
+	ReportBuildTime bool   // Report build real/CPU time as benchmark results.
+	KeepRunDirs     bool   // Leave the working directory of each unsandboxed run behind instead of deleting it, for debugging.
+
+	RunTimeout      time.Duration // Per-run timeout; 0 derives it from earlier runs, negative disables it.
+	TimeBudget      time.Duration // If positive, skip benchmarks so the runs are estimated to fit in this much time.
+	ChangedPkgs     []string      // If nonempty, packages changed by a CL; only run the benchmarks that depend on them.
+	BaselineRelease string        // If nonempty, a Go release to add a configuration for.
+	Publish         string        // If nonempty, a directory or gs:// URL to publish the results to.
+	MaxCV           float64       // Coefficient of variation above which a result is flagged as unstable; 0 disables.
+	PostProcess     string        // If nonempty, a command to run on each configuration's output once its runs are done.
+	Export          string        // If "csv" or "tsv", also write all the results to one file in that format.
+
+	Cpuset       string // If nonempty, the CPUs to run benchmarks on, in a cpuset of their own (Linux only).
+	CpusetParent string // Writable cgroup v2 directory in which to create the cpuset.
+	Unshare      string // If nonempty, the namespaces each unsandboxed run gets of its own, e.g. "net,pid" (Linux only).
+	Isolate      bool   // Move IRQs off the Cpuset CPUs and set their governor to performance for the run.
+
+	FlagSweeps []string // Build flags to sweep across, as KIND=flags1,flags2,...
+	Sweeps     []string // Run-time environment variables to sweep across, as NAME=value1,value2,...
+
+	Verbose      int    // How much to print about commands and other information.
+	MinGoVersion string // Minimum Go version across all toolchains used for benchmarking.
+
+	Args []string // Additional arguments for every run of a test binary.
+}
+
+// DefaultOptions returns the Options that bent runs with when given no
+// flags.
+func DefaultOptions() Options {
+	return Options{
+		N:               1,
+		Shuffle:         2,
+		BenchFile:       "benchmarks-50.toml",
+		ConfFile:        "configurations.toml",
+		QuarantineFile:  "quarantine.toml",
+		ReportBuildTime: true,
+		MaxCV:           0.05,
+		CpusetParent:    "/sys/fs/cgroup",
+		MinGoVersion:    "1.22",
+	}
+}
+
+// AddFlags registers bent's command-line flags in fs, setting the fields
+// of o, whose values become their defaults.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.N, "N", o.N, "benchmark/test repeat count")
+	fs.IntVar(&o.R, "R", o.R, "randomize binary layouts to reduce alignment artifacts (subsumes and is incompatible with -a, -N)")
+	fs.BoolVar(&o.GroupRuns, "G", o.GroupRuns, "group runs by benchmark (give them similar platform noise)")
+
+	fs.Var((*counterFlag)(&o.All), "a", "add '-a' flag to 'go test -c' to demand full recompile. Repeat or assign a value for repeat builds for benchmarking")
+	fs.IntVar(&o.Shuffle, "s", o.Shuffle, "dimensionality of (build) shuffling (0-3), 0 = none, 1 = per-benchmark, configuration ordering, 2 = bench, config pairs, 3 = across repetitions.")
+
+	fs.Var((*listFlag)(&o.Benchmarks), "b", "comma-separated list of test/benchmark names (default is all)")
+	fs.StringVar(&o.BenchFile, "B", o.BenchFile, "name of file containing benchmarks to run")
+	fs.Var((*listFlag)(&o.ExtraFiles), "B-extra", "comma-separated list of additional files of suites and benchmarks to merge with the standard ones (may be repeated; files in "+extensionDir+" are always merged)")
+
+	fs.Var((*listFlag)(&o.Configurations), "c", "comma-separated list of test/benchmark configurations (default is all)")
+	fs.StringVar(&o.ConfFile, "C", o.ConfFile, "name of file describing configurations")
+	fs.StringVar(&o.QuarantineFile, "quarantine", o.QuarantineFile, "name of file listing benchmarks not to run until a given date, and why")
+
+	fs.BoolVar(&o.Sandbox, "sandbox", o.Sandbox, "require Docker sandbox to run tests/benchmarks (& exclude unsandboxable tests/benchmarks)")
+
+	fs.BoolVar(&o.GetOnly, "g", o.GetOnly, "get tests/benchmarks and dependencies, do not build or run")
+	fs.StringVar(&o.RunContainer, "r", o.RunContainer, "skip get and build, go directly to run, using specified container (any non-empty string will do for unsandboxed execution)")
+
+	fs.BoolVar(&o.Rebuild, "rebuild", o.Rebuild, "get and build even if nothing affecting the build has changed since the last run")
+	fs.BoolVar(&o.UseScripts, "scripts", o.UseScripts, "run the embedded shell scripts (benchtime, benchsize, cpuprofile, memprofile) instead of bent's own versions of them, even outside the sandbox")
+
+	fs.StringVar(&o.StampLog, "L", o.StampLog, "name of log file to which runstamps are appended")
+
+	fs.BoolVar(&o.List, "l", o.List, "list available benchmarks and configurations, then exit")
+	fs.BoolVar(&o.Force, "f", o.Force, "force run past some of the consistency checks (gopath/{pkg,bin} in particular)")
+	fs.BoolVar(&o.Initialize, "I", o.Initialize, "initialize a directory for running tests ((re)creates Dockerfile, (re)copies in benchmark and configuration files)")
+	fs.BoolVar(&o.Test, "T", o.Test, "run tests instead of benchmarks")
+
+	fs.StringVar(&o.Catalog, "catalog", o.Catalog, "print the catalog of known benchmarks as markdown or json, then exit")
+	fs.BoolFunc("W", "same as -catalog=markdown", func(string) error {
+		o.Catalog = "markdown"
+		return nil
+	})
+	fs.BoolVar(&o.Experiment, "X", o.Experiment, "for experimental changes to 3rd party software, do not reset build/*/go.mod")
+
+	fs.BoolVar(&o.ReportBuildTime, "report-build-time", o.ReportBuildTime, "report build real/CPU time as benchmark results")
+	fs.BoolVar(&o.KeepRunDirs, "keep-run-dirs", o.KeepRunDirs, "leave the working directory of each benchmark run, with its copy of the testdata, in build/<benchmark>/runs instead of deleting it, for debugging")
+
+	fs.DurationVar(&o.RunTimeout, "run-timeout", o.RunTimeout, "kill any benchmark run taking longer than this and continue with the rest (0 = derive from earlier runs, negative = never; overridden by a benchmark's Timeout)")
+
+	fs.DurationVar(&o.TimeBudget, "time-budget", o.TimeBudget, "skip benchmarks as needed so that the runs are estimated to take no longer than this, based on earlier runs (0 = no limit)")
+	fs.Var((*listFlag)(&o.ChangedPkgs), "changed-pkgs", "(experimental) comma-separated list of packages changed by a CL, e.g. runtime,internal/abi or net/...; only run benchmarks whose test binaries include them (changes under cmd/ run all)")
+	fs.Var((*listFlag)(&o.Core), "core", "comma-separated list of benchmarks to run first, and to keep in preference to others under -time-budget")
+	fs.StringVar(&o.BaselineRelease, "baseline-release", o.BaselineRelease, "add a configuration, named for the release, that uses the official binary distribution of this Go release, e.g. go1.22.5, downloading it if needed")
+	fs.StringVar(&o.Publish, "publish", o.Publish, "after running, copy the results, a JSON manifest of the run and a snapshot of the machine to this directory or gs:// URL, stored by content hash")
+	fs.Float64Var(&o.MaxCV, "max-cv", o.MaxCV, "after running, flag results whose coefficient of variation across the -N repetitions exceeds this, with likely causes, in the output and bench/<runstamp>.unstable.json (0 = don't check)")
+	fs.StringVar(&o.PostProcess, "post-process", o.PostProcess, "command to run, with the name of the file appended to its arguments, on each configuration's benchmark output as soon as all its runs are done, e.g. to upload or check the results")
+	fs.StringVar(&o.Export, "export", o.Export, "after running, also write all results to bench/<runstamp>.<format> as a flat table (format csv or tsv)")
+
+	fs.StringVar(&o.Cpuset, "cpuset", o.Cpuset, "run benchmarks in a dedicated cgroup cpuset on these CPUs, e.g. 2-7, or auto for all online CPUs but CPU 0 (Linux only)")
+	fs.StringVar(&o.Unshare, "unshare", o.Unshare, "run each unsandboxed benchmark in new Linux namespaces of its own: net, for a network with only loopback, and optionally pid, e.g. net,pid (Linux only)")
+	fs.StringVar(&o.CpusetParent, "cpuset-parent", o.CpusetParent, "writable cgroup v2 directory in which to create the -cpuset cgroup")
+	fs.BoolVar(&o.Isolate, "isolate", o.Isolate, "with -cpuset, also move IRQs off its CPUs and set their cpufreq governor to performance for the run, restoring both afterwards (Linux only, needs root)")
+
+	fs.Func("flag-sweep", "build each configuration once per value of its gcflags, ldflags or goflags, adding to those it has, e.g. 'gcflags=,-d=checkptr,-d=ssa/check/on' (an empty value adds nothing; may be repeated)", func(v string) error {
+		o.FlagSweeps = append(o.FlagSweeps, v)
+		return nil
+	})
+	fs.Func("sweep", "run each configuration once per value of an environment variable, e.g. GOGC=50,100,200 (may be repeated)", func(v string) error {
+		o.Sweeps = append(o.Sweeps, v)
+		return nil
+	})
+
+	fs.Var((*counterFlag)(&o.Verbose), "v", "print commands and other information (more -v = print more details)")
+
+	fs.StringVar(&o.MinGoVersion, "m", o.MinGoVersion, "minimum Go version across all toolchains used for benchmarking")
+}
+
+// apply checks o and copies it into the package state that the rest of
+// bent works from, resetting whatever an earlier Run left there.
+func (o *Options) apply() error {
+	if o.R > 0 {
+		if o.R > 1000000 {
+			return errors.New("R must be less than or equal to 1,000,000 (1e6)")
+		}
+		if o.All != 0 {
+			fmt.Println("Warning: -R overrides -a, using -R value")
+		}
+		if o.N != 1 {
+			fmt.Println("Warning: -R overrides -N, using -R value")
+		}
+	}
+	if o.N > 1000000 {
+		return errors.New("N must be less than or equal to 1,000,000 (1e6)")
+	}
+	if o.Isolate && o.Cpuset == "" {
+		return errors.New("-isolate needs -cpuset")
+	}
+	switch o.Export {
+	case "", "csv", "tsv":
+	default:
+		return fmt.Errorf("-export format must be csv or tsv, not %q", o.Export)
+	}
+
+	sweeps, flagSweeps = nil, nil
+	for _, v := range o.Sweeps {
+		if err := sweeps.Set(v); err != nil {
+			return err
+		}
+	}
+	for _, v := range o.FlagSweeps {
+		if err := flagSweeps.Set(v); err != nil {
+			return err
+		}
+	}
+
+	N, R, groupRuns = o.N, o.R, o.GroupRuns
+	explicitAll = counterFlag(o.All)
+	if R > 0 {
+		if explicitAll < 0 {
+			// preserve the sign; this used to be a thing, it might be again
+			explicitAll = -counterFlag(R)
+		} else {
+			explicitAll = counterFlag(R)
+		}
+		N = R
+	}
+	shuffle = o.Shuffle
+	benchFile, confFile, quarantineFile = o.BenchFile, o.ConfFile, o.QuarantineFile
+	extraFiles = o.ExtraFiles
+	requireSandbox, getOnly, runContainer = o.Sandbox, o.GetOnly, o.RunContainer
+	rebuild, useScripts = o.Rebuild, o.UseScripts
+	list, force, initialize, test = o.List, o.Force, o.Initialize, o.Test
+	catalogFormat, experiment, reportBuildTime, keepRunDirs = o.Catalog, o.Experiment, o.ReportBuildTime, o.KeepRunDirs
+	runTimeout, timeBudget = o.RunTimeout, o.TimeBudget
+	changedPkgs, baselineRelease, publishDest = o.ChangedPkgs, o.BaselineRelease, o.Publish
+	maxCV, postProcess, exportFormat = o.MaxCV, o.PostProcess, o.Export
+	cpusetSpec, cpusetParent, unshareSpec, isolate = o.Cpuset, o.CpusetParent, o.Unshare, o.Isolate
+	verbose = counterFlag(o.Verbose)
+	minGoVersion = o.MinGoVersion
+
+	runstamp = newRunstamp()
+	container = ""
+	defaultEnv = nil
+	runCpuset, runIsolation, runNamespaces = nil, nil, nil
+	return nil
+}
+
+// A Result says what a Run did and where its results are.
+type Result struct {
+	Runstamp string            // Distinguishes the run's files from those of other runs in the same directory.
+	Outputs  map[string]string // Files of benchmark output, in the format of 'go test -bench', by configuration name.
+	Extra    []string          // Other files of results, such as from Options.Export.
+	Manifest string            // Where the results were published, if Options.Publish was set.
+
+	Failures      []string // Runs that failed, and why.
+	BuildFailures []string // Benchmarks that could not be got or built, and why; they were not run.
+	MaxRC         int      // The highest exit status of any run; 0 if all of them succeeded.
+}
+
+// An exitError is an error that bent exits with a particular status for.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitErrorf returns an error, formatted as by fmt.Errorf, that bent
+// exits with status code for.
+func exitErrorf(code int, format string, args ...any) error {
+	return &exitError{code, fmt.Errorf(format, args...)}
+}
+
+// ExitCode returns the status that the bent command exits with for err,
+// an error returned by Run: 0 for nil, 2 for failing partway through
+// getting, building or running the benchmarks, 128 plus the signal
+// number for being interrupted while running them with a cpuset, and 1
+// for other errors, such as bad Options.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return 1
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A benchOutput is the file that collects a configuration's benchmark
+// output, <runstamp>.<config>.stdout.  Runs don't write to it directly;
+// each writes to a runOutput of its own, which is appended to the
+// benchOutput in one piece when the run is over, so that the output of
+// runs can't interleave even if they execute in parallel.
+type benchOutput struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// createBenchOutput creates, or truncates, the benchmark output file name.
+func createBenchOutput(name string) (*benchOutput, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return &benchOutput{f: f}, nil
+}
+
+// Write writes b to o directly, for output that belongs to no run, such
+// as the state of the machine.
+func (o *benchOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n, err := o.f.Write(b)
+	o.f.Sync()
+	return n, err
+}
+
+func (o *benchOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.f.Close()
+}
+
+// A runOutput collects the output of a single run, in a temporary file
+// next to the benchOutput it will be appended to.  If bent dies during
+// the run, the file is left behind.
+type runOutput struct {
+	f   *os.File
+	out *benchOutput
+}
+
+// newRun returns a runOutput for the output of a run, to be appended to o.
+func (o *benchOutput) newRun() (*runOutput, error) {
+	name := o.f.Name()
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".run-*")
+	if err != nil {
+		return nil, err
+	}
+	return &runOutput{f: f, out: o}, nil
+}
+
+func (r *runOutput) Write(b []byte) (int, error) {
+	return r.f.Write(b)
+}
+
+// commit appends everything written to r to its benchOutput, and removes
+// r's file.
+func (r *runOutput) commit() error {
+	defer os.Remove(r.f.Name())
+	defer r.f.Close()
+	if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.out.mu.Lock()
+	defer r.out.mu.Unlock()
+	if _, err := io.Copy(r.out.f, r.f); err != nil {
+		return fmt.Errorf("appending %s to %s: %v", r.f.Name(), r.out.f.Name(), err)
+	}
+	return r.out.f.Sync()
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// perfStatEvents are the hardware events counted for configurations with
+// PerfStat set.
+const perfStatEvents = "instructions,cycles,branches,branch-misses"
+
+var (
+	perfStatOnce  sync.Once
+	perfStatFound bool
+)
+
+// perfStatAvailable reports whether the perf tool can be run, warning
+// (once) if it can't.
+func perfStatAvailable() bool {
+	perfStatOnce.Do(func() {
+		_, err := exec.LookPath("perf")
+		perfStatFound = err == nil
+		if !perfStatFound {
+			fmt.Println("Warning: PerfStat is set but perf was not found in PATH, so no perf counters will be reported")
+		}
+	})
+	return perfStatFound
+}
+
+// perfStatArgs returns the command and arguments that run a command under
+// perf stat, writing its counts in CSV form to out.
+func perfStatArgs(out string) []string {
+	return []string{"perf", "stat", "-x,", "-o", out, "-e", perfStatEvents, "--"}
+}
+
+// parsePerfStat parses the CSV output of perf stat -x, into a map from
+// event name to count. Events perf could not count are left out.
+func parsePerfStat(s string) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		// Lines are value,unit,event,... and the event may carry
+		// modifiers such as ":u".
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			// "<not counted>" or "<not supported>".
+			continue
+		}
+		event, _, _ := strings.Cut(fields[2], ":")
+		counts[event] = v
+	}
+	return counts
+}
+
+// benchmarkNames returns the benchmarks in list, the output of a test
+// binary's -test.list flag.
+func benchmarkNames(list string) []string {
+	var names []string
+	for _, line := range strings.Split(list, "\n") {
+		if strings.HasPrefix(line, "Benchmark") {
+			names = append(names, line)
+		}
+	}
+	return names
+}
+
+// benchPattern returns the -test.bench pattern that runs only the
+// benchmark name, and those of its sub-benchmarks that match sub, if
+// that is not empty.
+func benchPattern(name, sub string) string {
+	p := "^" + regexp.QuoteMeta(name) + "$"
+	if sub != "" {
+		p += "/" + sub
+	}
+	return p
+}
+
+// perfStatLine formats counts as a result line for the benchmark name,
+// so that they are reported alongside its other results. It returns ""
+// if there is nothing to report.
+func perfStatLine(name string, counts map[string]float64) string {
+	s := ""
+	instructions, haveInstructions := counts["instructions"]
+	cycles, haveCycles := counts["cycles"]
+	if haveInstructions {
+		s += fmt.Sprintf(" %.0f instructions", instructions)
+	}
+	if haveCycles {
+		s += fmt.Sprintf(" %.0f cycles", cycles)
+	}
+	if haveInstructions && haveCycles && cycles > 0 {
+		s += fmt.Sprintf(" %.3f IPC", instructions/cycles)
+	}
+	if misses, ok := counts["branch-misses"]; ok {
+		if branches := counts["branches"]; branches > 0 {
+			s += fmt.Sprintf(" %.3f branch-miss-%%", 100*misses/branches)
+		}
+	}
+	if s == "" {
+		return ""
+	}
+	return name + " 1" + s + "\n"
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var postProcess string // If nonempty, a command to run on each configuration's benchmark output once its runs are done.
+
+// postProcessResults runs command, split into words at spaces, with the
+// absolute name of file, the benchmark output of configuration config,
+// appended to its arguments. The command also gets the configuration's
+// name and the runstamp in its environment, as BENT_CONFIG and
+// BENT_RUNSTAMP. Its output goes to bent's.
+func postProcessResults(command, config, file string) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return fmt.Errorf("empty post-processing command")
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], append(args[1:], abs)...)
+	cmd.Env = append(os.Environ(), "BENT_CONFIG="+config, "BENT_RUNSTAMP="+runstamp)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if verbose > 0 {
+		fmt.Println(asCommandLine(dirs.wd, cmd))
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A runManifest describes a run published with -publish, and the files
+// it produced.  Files are published under their SHA-256 hash, as
+// sha256/<hash> in the destination, so that identical files published by
+// different runs are stored once; the manifest of each run is published as
+// runs/<host>/<runstamp>.json.
+type runManifest struct {
+	Runstamp       string            `json:"runstamp"`
+	Host           string            `json:"host"`
+	Args           []string          `json:"args"`
+	Configurations []string          `json:"configurations"`
+	Benchmarks     []string          `json:"benchmarks"`
+	Machine        map[string]string `json:"machine"`
+	Files          []publishedFile   `json:"files"`
+}
+
+type publishedFile struct {
+	Name   string `json:"name"` // The file's name in the bench directory, e.g. "20240101T000000.Tip.stdout"
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// isGCS reports whether dest is a Cloud Storage URL, rather than a directory.
+func isGCS(dest string) bool {
+	return strings.HasPrefix(dest, "gs://")
+}
+
+// checkPublishDest reports whether bent can publish to dest, before any
+// time is spent running benchmarks.
+func checkPublishDest(dest string) error {
+	if isGCS(dest) {
+		if _, err := exec.LookPath("gsutil"); err != nil {
+			return fmt.Errorf("publishing to %s needs gsutil: %v", dest, err)
+		}
+		return nil
+	}
+	return os.MkdirAll(dest, 0775)
+}
+
+// putFile copies the local file src to rel, a slash-separated path in
+// dest.  Unless overwrite is set, a file already at rel is left alone.
+func putFile(dest, rel, src string, overwrite bool) error {
+	if isGCS(dest) {
+		cmd := exec.Command("gsutil", "-q", "cp")
+		if !overwrite {
+			cmd.Args = append(cmd.Args, "-n")
+		}
+		cmd.Args = append(cmd.Args, src, strings.TrimSuffix(dest, "/")+"/"+rel)
+		if verbose > 0 {
+			fmt.Println(asCommandLine(dirs.wd, cmd))
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		return nil
+	}
+	dst := filepath.Join(dest, filepath.FromSlash(rel))
+	if _, err := os.Stat(dst); err == nil && !overwrite {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0775); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Write to a temporary file first, so that a file is never seen
+	// half-written under its final name.
+	out, err := os.CreateTemp(filepath.Dir(dst), ".publish-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0664); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+func hashFile(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// publishRun publishes files, and then m listing them, to dest, returning
+// the path of the manifest there.
+func publishRun(dest string, m *runManifest, files []string) (string, error) {
+	for _, file := range files {
+		sum, size, err := hashFile(file)
+		if err != nil {
+			return "", err
+		}
+		if err := putFile(dest, path.Join("sha256", sum), file, false); err != nil {
+			return "", fmt.Errorf("publishing %s: %v", file, err)
+		}
+		m.Files = append(m.Files, publishedFile{Name: filepath.Base(file), SHA256: sum, Size: size})
+	}
+
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "bent-manifest-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	// The manifest goes last, so that everything it lists is already there
+	// when it appears.
+	rel := path.Join("runs", m.Host, m.Runstamp+".json")
+	if err := putFile(dest, rel, tmp.Name(), true); err != nil {
+		return "", fmt.Errorf("publishing manifest: %v", err)
+	}
+	return strings.TrimSuffix(dest, "/") + "/" + rel, nil
+}
+
+// publishResults publishes the results of this run, the output files of
+// each enabled configuration and extra files such as the export, to dest,
+// along with a manifest describing the run and the machine it ran on.
+func publishResults(dest string, todo *Todo, machine *machineState, extra []string) (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	m := &runManifest{
+		Runstamp: runstamp,
+		Host:     host,
+		Args:     os.Args,
+		Machine:  machine.values,
+	}
+	var files []string
+	for i := range todo.Configurations {
+		c := &todo.Configurations[i]
+		if c.Disabled {
+			continue
+		}
+		m.Configurations = append(m.Configurations, c.Name)
+		for _, suffix := range append([]string{"stdout", "build"}, c.AfterBuild...) {
+			if name := c.thingBenchName(suffix); fileExists(name) {
+				files = append(files, name)
+			}
+		}
+	}
+	for _, b := range todo.Benchmarks {
+		if !b.Disabled {
+			m.Benchmarks = append(m.Benchmarks, b.Name)
+		}
+	}
+	files = append(files, extra...)
+
+	// The machine snapshot, in the same format as in the benchmark output.
+	snapshot := path.Join(dirs.benchDir, runstamp+".machine")
+	if err := os.WriteFile(snapshot, []byte(machine.String()), 0664); err != nil {
+		return "", err
+	}
+	files = append(files, snapshot)
+
+	return publishRun(dest, m, files)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
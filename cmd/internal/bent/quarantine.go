@@ -0,0 +1,113 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+var quarantineFile string // Benchmarks temporarily not to run; it need not exist.
+
+// A quarantineEntry keeps a benchmark, usually a flaky one, from running
+// until it expires, without editing the benchmark files.
+type quarantineEntry struct {
+	Name    string // Benchmark not to run.
+	Reason  string // Why not, e.g. "fails with -race since v1.2.3".
+	Expires string // Day, as 2006-01-02 in UTC, from which the benchmark runs again.
+	Issue   string // Issue tracking the fix, e.g. "golang/go#12345".
+	expires time.Time
+}
+
+func (q *quarantineEntry) String() string {
+	s := fmt.Sprintf("%s until %s", q.Name, q.Expires)
+	if q.Issue != "" {
+		s += " (" + q.Issue + ")"
+	}
+	return s + ": " + q.Reason
+}
+
+// readQuarantine reads the quarantine entries in file, which need not
+// exist. Every entry must have a name, a reason and an expiry date.
+func readQuarantine(file string) ([]quarantineEntry, error) {
+	blob, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var q struct{ Quarantine []quarantineEntry }
+	if err := toml.Unmarshal(blob, &q); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %v", file, err)
+	}
+	seen := make(map[string]bool)
+	for i := range q.Quarantine {
+		e := &q.Quarantine[i]
+		switch {
+		case e.Name == "":
+			return nil, fmt.Errorf("%s: quarantine entry %d has no Name", file, i)
+		case seen[e.Name]:
+			return nil, fmt.Errorf("%s: benchmark %s is quarantined more than once", file, e.Name)
+		case e.Reason == "":
+			return nil, fmt.Errorf("%s: quarantine of %s has no Reason", file, e.Name)
+		case e.Expires == "":
+			return nil, fmt.Errorf("%s: quarantine of %s has no Expires date; quarantines are temporary", file, e.Name)
+		}
+		seen[e.Name] = true
+		e.expires, err = time.Parse(time.DateOnly, e.Expires)
+		if err != nil {
+			return nil, fmt.Errorf("%s: quarantine of %s has an invalid Expires date, want YYYY-MM-DD: %v", file, e.Name, err)
+		}
+	}
+	return q.Quarantine, nil
+}
+
+// applyQuarantine disables the benchmarks in todo that have entries in
+// quarantine that have not expired by now, unless they are keys of
+// explicit, the benchmarks listed after -b. It returns the entries it
+// applied, those that have expired, and those naming benchmarks that are not
+// in todo.
+func applyQuarantine(todo *Todo, quarantine []quarantineEntry, now time.Time, explicit map[string]bool) (active, expired, unknown []quarantineEntry) {
+	index := make(map[string]int)
+	for i, b := range todo.Benchmarks {
+		index[b.Name] = i
+	}
+	for _, e := range quarantine {
+		i, ok := index[e.Name]
+		_, asked := explicit[e.Name]
+		switch {
+		case !now.Before(e.expires):
+			expired = append(expired, e)
+		case !ok:
+			unknown = append(unknown, e)
+		case asked:
+			// Asked for by name, probably to check whether it's fixed.
+		default:
+			todo.Benchmarks[i].Disabled = true
+			active = append(active, e)
+		}
+	}
+	return active, expired, unknown
+}
+
+// printQuarantine prints the quarantine entries that applyQuarantine sorted
+// out, so that no quarantine goes unnoticed.
+func printQuarantine(active, expired, unknown []quarantineEntry) {
+	if len(active) > 0 {
+		fmt.Printf("Quarantined benchmarks, not running (from %s):\n", quarantineFile)
+		for _, e := range active {
+			fmt.Printf("   %v\n", &e)
+		}
+	}
+	for _, e := range expired {
+		fmt.Printf("Quarantine of %s expired on %s, running it again; remove it from %s\n", e.Name, e.Expires, quarantineFile)
+	}
+	for _, e := range unknown {
+		fmt.Printf("Quarantined benchmark %s does not appear in %s\n", e.Name, benchFile)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// releasesURL lists the files of every Go release, as JSON.
+const releasesURL = "https://go.dev/dl/?mode=json&include=all"
+
+// releaseRE matches the names of Go releases, as in "go1.22.5" or "go1.23rc1".
+var releaseRE = regexp.MustCompile(`^go1(\.[0-9]+)+((rc|beta)[0-9]+)?$`)
+
+// releaseFile is a downloadable file of a Go release, as described at releasesURL.
+type releaseFile struct {
+	Filename string `json:"filename"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Version  string `json:"version"`
+	SHA256   string `json:"sha256"`
+	Kind     string `json:"kind"`
+}
+
+// releaseArchive returns the binary distribution of release for goos and
+// goarch among the releases listed in the JSON list.
+func releaseArchive(list []byte, release, goos, goarch string) (*releaseFile, error) {
+	var releases []struct {
+		Version string        `json:"version"`
+		Files   []releaseFile `json:"files"`
+	}
+	if err := json.Unmarshal(list, &releases); err != nil {
+		return nil, fmt.Errorf("parsing list of Go releases: %v", err)
+	}
+	for _, r := range releases {
+		if r.Version != release {
+			continue
+		}
+		for i, f := range r.Files {
+			if f.Kind == "archive" && f.OS == goos && f.Arch == goarch {
+				return &r.Files[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no %s/%s binary distribution of %s", goos, goarch, release)
+	}
+	return nil, fmt.Errorf("unknown Go release %s", release)
+}
+
+// goRootForRelease returns a GOROOT containing the official binary
+// distribution of release, e.g. "go1.22.5", for the host platform.
+// Distributions are kept in the goroots directory, so each is only
+// downloaded once.
+func goRootForRelease(release string) (string, error) {
+	if !releaseRE.MatchString(release) {
+		return "", fmt.Errorf("%q is not a Go release, such as go1.22.5", release)
+	}
+	root := path.Join(dirs.goroots, "release-"+release)
+	if _, err := os.Stat(path.Join(root, "bin", "go")); err == nil {
+		fmt.Printf("Using Go %s from %s\n", release, root)
+		return root, nil
+	}
+
+	list, err := httpGet(releasesURL)
+	if err != nil {
+		return "", err
+	}
+	file, err := releaseArchive(list, release, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Downloading Go %s (%s)\n", release, file.Filename)
+	archive, err := httpGet("https://go.dev/dl/" + file.Filename)
+	if err != nil {
+		return "", err
+	}
+	if sum := sha256.Sum256(archive); hex.EncodeToString(sum[:]) != file.SHA256 {
+		return "", fmt.Errorf("%s has SHA-256 %x, want %s", file.Filename, sum, file.SHA256)
+	}
+
+	// Unpack somewhere else first, so that a failed or interrupted unpacking
+	// is never mistaken for a finished one.
+	partial := root + ".partial"
+	if verbose > 0 {
+		fmt.Printf("rm -rf %s\n", partial)
+	}
+	if err := os.RemoveAll(partial); err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(file.Filename, ".zip") {
+		err = unzip(archive, partial)
+	} else {
+		err = untargz(archive, partial)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unpacking %s: %v", file.Filename, err)
+	}
+	// The archives hold a single directory, go.
+	if verbose > 0 {
+		fmt.Printf("mv %s %s\n", path.Join(partial, "go"), root)
+	}
+	if err := os.Rename(path.Join(partial, "go"), root); err != nil {
+		return "", err
+	}
+	os.RemoveAll(partial)
+	return root, nil
+}
+
+func httpGet(url string) ([]byte, error) {
+	if verbose > 0 {
+		fmt.Printf("curl %s\n", url)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// extractTo returns the path under dir for the archive member name,
+// rejecting names that would escape dir.
+func extractTo(dir, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("archive member %q is outside the archive", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func writeFile(name string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func untargz(archive []byte, dir string) error {
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(zr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, err := extractTo(dir, h.Name)
+		if err != nil {
+			return err
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(name, 0777)
+		case tar.TypeReg:
+			err = writeFile(name, tr, h.FileInfo().Mode())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func unzip(archive []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		name, err := extractTo(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(name, 0777); err != nil {
+				return err
+			}
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(name, r, f.Mode())
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
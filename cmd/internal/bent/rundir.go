@@ -0,0 +1,100 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/common/fileutil"
+)
+
+// runDirsName is the directory under each benchmark's BuildDir that holds
+// the working directories of its runs. They are nested in BuildDir so that
+// a test that looks for its go.mod above its working directory finds it.
+const runDirsName = "runs"
+
+// newRunDir creates a fresh working directory for an unsandboxed run of
+// b's test binary, named for the binary, holding copies of the testdata and
+// other files in b.BuildDir, which the runs themselves never touch, and
+// so stay as they were copied from the module cache. The returned function
+// deletes the directory, unless keepRunDirs is set.
+//
+// The copies are flushed to disk before newRunDir returns, so that writing
+// them back doesn't compete with the timed run that follows.
+func newRunDir(b *Benchmark, name string) (string, func(), error) {
+	parent := path.Join(b.BuildDir, runDirsName)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp(parent, name+"-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		if keepRunDirs {
+			fmt.Printf("# Kept run directory %s\n", dir)
+			return
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			fmt.Printf("Error removing run directory %s, %v\n", dir, err)
+		}
+	}
+	for _, f := range b.runFiles {
+		src, dst := path.Join(b.BuildDir, f), path.Join(dir, f)
+		if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		stat, err := os.Stat(src)
+		if err == nil {
+			if stat.IsDir() {
+				err = fileutil.CopyDir(dst, src, nil)
+			} else {
+				err = fileutil.CopyFile(dst, src, stat, nil)
+			}
+		}
+		if err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("copying %s to run directory: %v", src, err)
+		}
+	}
+	if err := syncTree(dir); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("flushing run directory: %v", err)
+	}
+	return dir, cleanup, nil
+}
+
+// syncTree flushes the regular files under dir to disk.
+func syncTree(dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		serr := f.Sync()
+		if err := f.Close(); serr == nil {
+			serr = err
+		}
+		return serr
+	})
+}
+
+// scrubRunDirs removes the working directories that earlier invocations of
+// bent left behind for b, whether kept on request or abandoned by a crash,
+// unless keepRunDirs is set.
+func scrubRunDirs(b *Benchmark) error {
+	if keepRunDirs {
+		return nil
+	}
+	return os.RemoveAll(path.Join(b.BuildDir, runDirsName))
+}
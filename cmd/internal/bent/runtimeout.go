@@ -0,0 +1,90 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// runHistoryFile records how long each benchmark's runs took, so that
+// a later run can tell when one has hung.
+const runHistoryFile = "run-history.json"
+
+const (
+	timeoutFactor     = 5                // Multiple of the historical duration allowed before a run is killed.
+	minRunTimeout     = 10 * time.Minute // Lower bound for timeouts derived from history.
+	defaultRunTimeout = time.Hour        // Timeout for benchmarks with no history.
+)
+
+// timeoutRC is the return code of a run that was killed for taking too
+// long; it is the same as timeout(1)'s.
+const timeoutRC = 124
+
+// runHistory maps benchmark names to the duration of their longest
+// successful run.
+type runHistory map[string]time.Duration
+
+var (
+	pastRuns   runHistory // From earlier invocations of bent.
+	latestRuns runHistory // From this invocation.
+)
+
+// loadRunHistory reads the durations recorded by earlier runs.
+// A missing or unreadable history is treated as empty.
+func loadRunHistory() {
+	pastRuns, latestRuns = runHistory{}, runHistory{}
+	b, err := os.ReadFile(path.Join(dirs.wd, runHistoryFile))
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(b, &pastRuns); err != nil {
+		fmt.Printf("Ignoring unreadable %s: %v\n", runHistoryFile, err)
+		pastRuns = runHistory{}
+	}
+}
+
+// recordRun notes that a run of b succeeded after d.
+func recordRun(b *Benchmark, d time.Duration) {
+	if d > latestRuns[b.Name] {
+		latestRuns[b.Name] = d
+	}
+}
+
+// saveRunHistory records the durations of this invocation's runs,
+// replacing older ones for the same benchmarks, for use by later runs.
+func saveRunHistory() {
+	for name, d := range latestRuns {
+		pastRuns[name] = d
+	}
+	b, err := json.MarshalIndent(pastRuns, "", "\t")
+	if err == nil {
+		err = os.WriteFile(path.Join(dirs.wd, runHistoryFile), b, 0664)
+	}
+	if err != nil {
+		fmt.Printf("Could not record run durations: %v\n", err)
+	}
+}
+
+// runTimeoutFor returns how long a run of b may take before it is killed,
+// or 0 if it may run forever. In order of preference this is the
+// benchmark's Timeout, the -run-timeout flag, or a multiple of the
+// longest run of b seen so far.
+func runTimeoutFor(b *Benchmark) time.Duration {
+	if b.Timeout != "" {
+		return b.timeout
+	}
+	if runTimeout != 0 {
+		return max(runTimeout, 0)
+	}
+	d := max(pastRuns[b.Name], latestRuns[b.Name])
+	if d == 0 {
+		return defaultRunTimeout
+	}
+	return max(timeoutFactor*d, minRunTimeout)
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package bent
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the started command cmd. Process groups are not
+// available here, so anything cmd started is left running.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// exitStatus returns the exit code of the command that ended with ee.
+func exitStatus(ee *exec.ExitError) int {
+	return ee.ExitCode()
+}
@@ -0,0 +1,277 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// internalPrefix prefixes the names of the subcommands through which bent
+// runs its own implementations of embedded scripts, e.g.
+// "bent internal-benchsize".
+const internalPrefix = "internal-"
+
+// builtinScripts are the embedded scripts that bent also implements in Go,
+// keyed by script name.  Each takes the arguments the script would.
+var builtinScripts = map[string]func(args []string) error{
+	"benchtime":  benchtimeScript,
+	"benchsize":  benchsizeScript,
+	"cpuprofile": cpuprofileScript,
+	"memprofile": memprofileScript,
+}
+
+// internalCommands are the other subcommands bent runs itself as, keyed by
+// name.
+var internalCommands = map[string]func(args []string) error{}
+
+var useScripts bool // If true, run the embedded shell scripts even where bent implements them itself.
+
+// builtinCommand returns the command line that runs bent's own
+// implementation of the script name, used as an AfterBuild command or a
+// RunWrapper, or nil if there is none and the script itself should run.
+// Explicit paths are always left alone.
+func builtinCommand(name string) []string {
+	if useScripts || strings.Contains(name, "/") || builtinScripts[name] == nil {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	return []string{exe, internalPrefix + name}
+}
+
+// HandleInternal runs the subcommand that this process was started as,
+// then exits, if it is one of those through which Run runs parts of
+// itself; otherwise it does nothing. Programs that call Run must call
+// HandleInternal before doing anything else in main.
+func HandleInternal() {
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], internalPrefix) {
+		os.Exit(runInternal(os.Args[1], os.Args[2:]))
+	}
+}
+
+// runInternal runs the subcommand "internal-<name>" with args, returning
+// the status to exit with.
+func runInternal(sub string, args []string) int {
+	name := strings.TrimPrefix(sub, internalPrefix)
+	f := builtinScripts[name]
+	if f == nil {
+		f = internalCommands[name]
+	}
+	if f == nil {
+		fmt.Fprintf(os.Stderr, "Unknown subcommand %s\n", sub)
+		return 2
+	}
+	if err := f(args); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			// The wrapped command already said what went wrong, and
+			// its status, as a shell would give it, says how it ended.
+			return exitStatus(ee)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", sub, err)
+		return 1
+	}
+	return 0
+}
+
+// runWrapped runs the command args, with extra arguments, as a wrapper
+// script would, connected to this process's standard files.
+func runWrapped(args []string, extra ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command to run")
+	}
+	cmd := exec.Command(args[0], append(args[1:], extra...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// benchtimeScript runs args[1:] with the benchtime args[0].
+func benchtimeScript(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: benchtime benchtime command [args...]")
+	}
+	return runWrapped(args[1:], "-test.benchtime="+args[0])
+}
+
+// pprofCommand returns the command that runs pprof, preferring a
+// standalone pprof to the go tool's.
+func pprofCommand(args ...string) *exec.Cmd {
+	if p, err := exec.LookPath("pprof"); err == nil {
+		return exec.Command(p, args...)
+	}
+	return exec.Command("go", append([]string{"tool", "pprof"}, args...)...)
+}
+
+// runPprof prints a summary of the profile file with pprof, with flags.
+func runPprof(file string, flags ...string) error {
+	cmd := pprofCommand(append(flags, file)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// cpuprofileScript runs args with a CPU profile, into $BENT_PGO if set
+// for PGO, and prints the hottest functions.
+func cpuprofileScript(args []string) error {
+	var pf string
+	if dir := os.Getenv("BENT_PGO"); dir != "" {
+		pf = filepath.Join(dir, os.Getenv("BENT_BENCH")+".prof")
+	} else {
+		pf = filepath.Join(os.Getenv("BENT_PROFILES"), os.Getenv("BENT_BINARY")+"_"+os.Getenv("BENT_I")+".prof")
+	}
+	if err := os.MkdirAll(filepath.Dir(pf), 0775); err != nil {
+		return err
+	}
+	if err := runWrapped(args, "-test.cpuprofile="+pf); err != nil {
+		return err
+	}
+	fmt.Println("cpuprofile in", pf)
+	return runPprof(pf, "-text", "-flat", "-nodecount=20")
+}
+
+// memprofileScript runs args with a memory profile, and prints the
+// functions that allocate the most.
+func memprofileScript(args []string) error {
+	pf := filepath.Join(os.Getenv("BENT_PROFILES"), os.Getenv("BENT_BINARY")+"_"+os.Getenv("BENT_I")+".mprof")
+	if err := os.MkdirAll(filepath.Dir(pf), 0775); err != nil {
+		return err
+	}
+	if err := runWrapped(args, "-test.memprofile="+pf); err != nil {
+		return err
+	}
+	fmt.Println("memprofile in", pf)
+	return runPprof(pf, "--alloc_space", "--text", "--cum", "--nodecount=20")
+}
+
+// A section is the name and size of a section of an executable.
+type section struct {
+	name string
+	size uint64
+}
+
+// readSections returns the sections of the executable file, in whichever
+// object format it is in, and whether that format is Mach-O.
+func readSections(file string) ([]section, bool, error) {
+	var secs []section
+	if f, err := elf.Open(file); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			switch {
+			case s.Type == elf.SHT_NULL:
+				continue
+			case (s.Type == elf.SHT_SYMTAB || s.Type == elf.SHT_STRTAB) && s.Flags&elf.SHF_ALLOC == 0:
+				// Like size, leave out the symbol table and section names.
+				continue
+			}
+			size := s.Size
+			if s.Flags&elf.SHF_COMPRESSED != 0 {
+				// As size does, count the compressed size.
+				size = s.FileSize
+			}
+			secs = append(secs, section{s.Name, size})
+		}
+		return secs, false, nil
+	}
+	if f, err := macho.Open(file); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			secs = append(secs, section{s.Name, s.Size})
+		}
+		return secs, true, nil
+	}
+	if f, err := pe.Open(file); err == nil {
+		defer f.Close()
+		for _, s := range f.Sections {
+			secs = append(secs, section{s.Name, uint64(s.Size)})
+		}
+		return secs, false, nil
+	}
+	return nil, false, fmt.Errorf("%s is not an ELF, Mach-O or PE executable", file)
+}
+
+// sectionSize returns the total size of the sections named like name,
+// without its leading "." or "__", e.g. ".text" or "__text". Like the
+// benchsize script, it counts variants such as ".zdebug_info" or
+// ".noptrdata" too.
+func sectionSize(secs []section, isMacho bool, name string) uint64 {
+	var tot uint64
+	for _, s := range secs {
+		n := s.name
+		var match bool
+		if isMacho {
+			// __text, or __Xtext for any one character X.
+			rest, ok := strings.CutPrefix(n, "__")
+			match = ok && strings.HasSuffix(rest, name) && len(rest) <= len(name)+1
+		} else {
+			// Any character followed by name, anywhere in the name.
+			match = strings.Index(n, name) >= 1
+		}
+		if match {
+			tot += s.size
+		}
+	}
+	return tot
+}
+
+// debugSections are the DWARF sections counted as debug-bytes.
+var debugSections = []string{
+	"debug_info", "debug_loc", "debug_line", "debug_ranges",
+	"debug_frame", "debug_abbrev", "debug_pubname", "debug_pubtype",
+}
+
+// writeBenchsize writes the sizes of the sections of the executable file,
+// in the benchmark format, as results of the benchmark name.
+func writeBenchsize(w io.Writer, file, name string) error {
+	secs, isMacho, err := readSections(file)
+	if err != nil {
+		return err
+	}
+	var total uint64
+	for _, s := range secs {
+		total += s.size
+	}
+	var debug uint64
+	for _, d := range debugSections {
+		debug += sectionSize(secs, isMacho, d)
+	}
+
+	fmt.Fprintf(w, "goos: %s\n", os.Getenv("GOOS"))
+	fmt.Fprintf(w, "goarch: %s\n", os.Getenv("GOARCH"))
+	fmt.Fprintf(w, "pkg:\n") // Erase any inherited pkg if files are concatenated
+	for _, m := range []struct {
+		unit string
+		size uint64
+	}{
+		{"total-bytes", total},
+		{"text-bytes", sectionSize(secs, isMacho, "text")},
+		{"data-bytes", sectionSize(secs, isMacho, "data")},
+		{"rodata-bytes", sectionSize(secs, isMacho, "rodata")},
+		{"pclntab-bytes", sectionSize(secs, isMacho, "gopclntab")},
+		{"debug-bytes", debug},
+	} {
+		fmt.Fprintf(w, "Unit %s assume=exact\n", m.unit)
+		fmt.Fprintf(w, "Benchmark%s 1 %d %s\n", name, m.size, m.unit)
+	}
+	return nil
+}
+
+// benchsizeScript reports the section sizes of the executable args[0] as
+// results of the benchmark args[1].
+func benchsizeScript(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: benchsize executable benchmark")
+	}
+	return writeBenchsize(os.Stdout, args[0], args[1])
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+)
+
+// pkgMatch reports whether the import path pkg matches pattern, which is
+// either an import path or, as with go list, a prefix followed by "/..."
+// matching the packages at and below it.
+func pkgMatch(pattern, pkg string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+	}
+	return pkg == pattern
+}
+
+// affectsAll reports whether a change to the packages matching changed
+// can affect every benchmark, because it changes the toolchain that builds
+// them rather than code linked into them.
+func affectsAll(changed []string) bool {
+	for _, p := range changed {
+		if p == "cmd" || p == "cmd/..." || strings.HasPrefix(p, "cmd/") {
+			return true
+		}
+	}
+	return false
+}
+
+// affects reports whether a change to the packages matching changed can
+// affect a benchmark whose test binary is built from the packages deps.
+func affects(changed, deps []string) bool {
+	if affectsAll(changed) {
+		return true
+	}
+	for _, p := range changed {
+		for _, d := range deps {
+			if pkgMatch(p, d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// benchDeps returns the import paths of the packages that go into the
+// test binary of bench, as built by the go command goCmd, which must
+// already have been fetched into its build directory.
+func benchDeps(bench *Benchmark, goCmd string) ([]string, error) {
+	cmd := exec.Command(goCmd, "list", "-deps", "-test", "-f", "{{.ImportPath}}", bench.Repo)
+	cmd.Env = DefaultEnv()
+	cmd.Dir = bench.BuildDir
+	if !bench.NotSandboxed { // As for go get, the sandbox runs Linux.
+		cmd.Env = replaceEnv(cmd.Env, "GOOS", "linux")
+	}
+	cmd.Env = replaceEnvs(cmd.Env, sliceExpandEnv(bench.GcEnv, cmd.Env))
+	if verbose > 0 {
+		fmt.Println(asCommandLine(dirs.wd, cmd))
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%v, stderr = %s", err, ee.Stderr)
+		}
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// goCommands returns the go commands of the enabled configurations of
+// todo, once each, or just the one on the PATH if none has its own root.
+func goCommands(todo *Todo) []string {
+	var cmds []string
+	for _, c := range todo.Configurations {
+		if c.Disabled || c.Root == "" {
+			continue
+		}
+		if cmd := path.Join(c.Root, "bin", "go"); !slices.Contains(cmds, cmd) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	if len(cmds) == 0 {
+		cmds = append(cmds, "go")
+	}
+	return cmds
+}
+
+// selectAffected disables the enabled benchmarks of todo that a change to
+// the packages matching changed cannot affect, because none of those
+// packages go into their test binaries as built by any configuration's
+// toolchain. Benchmarks whose packages can't be listed are kept.
+func selectAffected(todo *Todo, changed []string) {
+	benchmarks := todo.Benchmarks
+	goCmds := goCommands(todo)
+	if affectsAll(changed) {
+		fmt.Printf("Changes to the toolchain (%s) affect every benchmark, running them all\n", strings.Join(changed, ", "))
+		return
+	}
+	var kept, skipped []string
+	for i := range benchmarks {
+		bench := &benchmarks[i]
+		if bench.Disabled {
+			continue
+		}
+		affected := false
+		for _, goCmd := range goCmds {
+			deps, err := benchDeps(bench, goCmd)
+			if err != nil {
+				fmt.Printf("Could not list the packages of benchmark %s, keeping it: %v\n", bench.Name, err)
+				affected = true
+				break
+			}
+			if affects(changed, deps) {
+				affected = true
+				break
+			}
+		}
+		if affected {
+			kept = append(kept, bench.Name)
+		} else {
+			bench.Disabled = true
+			skipped = append(skipped, bench.Name)
+		}
+	}
+	fmt.Printf("Running %d benchmarks affected by changes to %s: %s\n", len(kept), strings.Join(changed, ", "), strings.Join(kept, ", "))
+	if len(skipped) > 0 {
+		fmt.Printf("Skipping %d unaffected benchmarks: %s\n", len(skipped), strings.Join(skipped, ", "))
+	}
+}
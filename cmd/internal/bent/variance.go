@@ -0,0 +1,148 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var maxCV float64 // Coefficient of variation across repetitions above which a result is flagged as unstable; 0 disables.
+
+const (
+	minRunDuration = 100 * time.Millisecond // Shorter timed runs are likely too short to measure well.
+	minRepetitions = 5                      // Fewer repetitions are likely too few to estimate variation well.
+)
+
+// An unstableResult is a result whose values varied so much across the
+// repetitions of a run that comparisons involving it are untrustworthy.
+type unstableResult struct {
+	Benchmark string   `json:"benchmark"` // bent benchmark, e.g. "gonum_topo"
+	Config    string   `json:"config"`
+	Metric    string   `json:"metric"` // Go benchmark, e.g. "TarjanSCCGnp_1000_half-8"
+	Unit      string   `json:"unit"`
+	Runs      int      `json:"runs"`
+	Mean      float64  `json:"mean"`
+	CV        float64  `json:"cv"`     // Sample standard deviation divided by the mean.
+	Causes    []string `json:"causes"` // Likely causes of the variation, and remedies.
+}
+
+// findUnstable returns the results in rows whose coefficient of variation
+// across iterations exceeds threshold, in the order they first appear.
+func findUnstable(rows []resultRow, threshold float64) []unstableResult {
+	type key struct{ benchmark, config, metric, unit string }
+	var keys []key
+	samples := make(map[key][]resultRow)
+	for _, r := range rows {
+		k := key{r.benchmark, r.config, r.metric, r.unit}
+		if _, ok := samples[k]; !ok {
+			keys = append(keys, k)
+		}
+		samples[k] = append(samples[k], r)
+	}
+
+	var unstable []unstableResult
+	for _, k := range keys {
+		s := samples[k]
+		if len(s) < 2 {
+			continue
+		}
+		var sum float64
+		for _, r := range s {
+			sum += r.value
+		}
+		mean := sum / float64(len(s))
+		if mean == 0 {
+			continue
+		}
+		var ss float64
+		for _, r := range s {
+			ss += (r.value - mean) * (r.value - mean)
+		}
+		cv := math.Sqrt(ss/float64(len(s)-1)) / math.Abs(mean)
+		if cv <= threshold {
+			continue
+		}
+		unstable = append(unstable, unstableResult{
+			Benchmark: k.benchmark,
+			Config:    k.config,
+			Metric:    k.metric,
+			Unit:      k.unit,
+			Runs:      len(s),
+			Mean:      mean,
+			CV:        cv,
+			Causes:    likelyCauses(s),
+		})
+	}
+	return unstable
+}
+
+// likelyCauses guesses why the values of a result, given by its samples,
+// varied so much.
+func likelyCauses(samples []resultRow) []string {
+	var causes []string
+	unit := samples[0].unit
+	if strings.HasPrefix(unit, "build-") {
+		causes = append(causes, "each value is from a single build, which depends on the state of caches and the file system; repeat the builds with -a=N")
+	} else {
+		once := true
+		var total float64
+		for _, r := range samples {
+			once = once && r.ops == 1
+			total += float64(r.ops) * r.value
+		}
+		if once {
+			causes = append(causes, "the benchmark ran just one operation per run (b.N=1), so one-off costs such as page faults and GC cycles aren't amortized")
+		}
+		if unit == "ns/op" {
+			if d := time.Duration(total / float64(len(samples))); d < minRunDuration {
+				causes = append(causes, fmt.Sprintf("each run timed only %v on average, so timer resolution and one-off costs matter; raise the benchmark's -test.benchtime", d.Round(time.Microsecond)))
+			}
+		}
+	}
+	if len(samples) < minRepetitions {
+		causes = append(causes, fmt.Sprintf("only %d repetitions, too few to estimate the variation well; raise -N", len(samples)))
+	}
+	if len(causes) == 0 {
+		causes = append(causes, "nothing obvious; check the machine state recorded with the results for interference")
+	}
+	return causes
+}
+
+// reportUnstable finds the unstable results of this run, prints a summary
+// of them, and writes them all, as JSON, to a file in the bench directory,
+// whose name it returns.
+func reportUnstable(configs []Configuration) (string, error) {
+	rows, err := readResults(configs)
+	if err != nil {
+		return "", err
+	}
+	unstable := findUnstable(rows, maxCV)
+	if unstable == nil {
+		unstable = []unstableResult{} // Record that there were none, rather than null.
+	}
+
+	if len(unstable) > 0 {
+		fmt.Printf("UNSTABLE: %d results vary by more than %.0f%% (coefficient of variation) across runs:\n", len(unstable), 100*maxCV)
+		for _, u := range unstable {
+			fmt.Printf("   %s %s %s in %s: ±%.1f%% over %d runs\n", u.Benchmark, u.Metric, u.Unit, u.Config, 100*u.CV, u.Runs)
+			for _, c := range u.Causes {
+				fmt.Printf("      likely cause: %s\n", c)
+			}
+		}
+	}
+
+	b, err := json.MarshalIndent(unstable, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	name := path.Join(dirs.benchDir, runstamp+".unstable.json")
+	return name, os.WriteFile(name, b, 0664)
+}
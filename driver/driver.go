@@ -124,7 +124,7 @@ type Result struct {
 	Duration time.Duration // total run duration
 	RunTime  uint64        // ns/op
 	Metrics  map[string]uint64
-	Files    map[string]string
+	Files    map[string]string // absolute paths of the files the run produced, by kind, e.g. "cpuprof"
 }
 
 func MakeResult() Result {
@@ -156,17 +156,18 @@ func report(name string, res Result) {
 }
 
 // Benchmark runs f several times, collects stats,
-// and creates cpu/mem profiles.
+// and creates cpu/mem profiles in a directory of their own.
 func Benchmark(f func(uint64)) Result {
-	res := runBenchmark(f)
+	dir := newRunDir()
+	res := runBenchmark(f, dir)
 
-	cpuprof := processProfile(os.Args[0], res.Files["cpuprof"])
+	cpuprof := processProfile(dir, "cpuprof", os.Args[0], res.Files["cpuprof"])
 	delete(res.Files, "cpuprof")
 	if cpuprof != "" {
 		res.Files["cpuprof"] = cpuprof
 	}
 
-	memprof := processProfile("--lines", "--unit=byte", "--alloc_space", "--base", res.Files["memprof0"], os.Args[0], res.Files["memprof"])
+	memprof := processProfile(dir, "memprof", "--lines", "--unit=byte", "--alloc_space", "--base", res.Files["memprof0"], os.Args[0], res.Files["memprof"])
 	delete(res.Files, "memprof")
 	delete(res.Files, "memprof0")
 	if memprof != "" {
@@ -177,15 +178,19 @@ func Benchmark(f func(uint64)) Result {
 }
 
 // processProfile invokes 'go tool pprof' with the specified args
-// and returns name of the resulting file, or an empty string.
-func processProfile(args ...string) string {
-	fname := "prof.txt"
+// and returns name of the resulting file, named for the profile, in dir,
+// or an empty string.
+func processProfile(dir, name string, args ...string) string {
+	if dir == "" {
+		return ""
+	}
+	fname := name + ".prof.txt"
 	typ := "--text"
 	if *genSvg {
-		fname = "prof.svg"
+		fname = name + ".prof.svg"
 		typ = "--svg"
 	}
-	proff, err := os.Create(tempFilename(fname))
+	proff, err := os.Create(filepath.Join(dir, fname))
 	if err != nil {
 		log.Printf("Failed to create profile file: %v", err)
 		return ""
@@ -206,17 +211,20 @@ func processProfile(args ...string) string {
 
 // runBenchmark runs f several times with increasing number of iterations
 // until execution time reaches the requested duration.
-func runBenchmark(f func(uint64)) Result {
+func runBenchmark(f func(uint64), dir string) Result {
 	res := MakeResult()
 	for chooseN(&res) {
 		log.Printf("Benchmarking %v iterations\n", res.N)
-		res = runBenchmarkOnce(f, res.N)
+		res = measure(f, res.N, dir)
 	}
 	return res
 }
 
-// runBenchmarkOnce runs f once and collects all performance metrics and profiles.
-func runBenchmarkOnce(f func(uint64), N uint64) Result {
+// measure runs f once and collects all performance metrics, and also
+// profiles, written to dir, if dir is not empty. Each call overwrites the
+// profiles of the last, so dir holds only those of the final iteration
+// count.
+func measure(f func(uint64), N uint64, dir string) Result {
 	latencyInit(N)
 	runtime.GC()
 	mstats0 := new(runtime.MemStats)
@@ -224,38 +232,45 @@ func runBenchmarkOnce(f func(uint64), N uint64) Result {
 	ss := InitSysStats(N)
 	res := MakeResult()
 	res.N = N
-	res.Files["memprof0"] = tempFilename("memprof")
-	memprof0, err := os.Create(res.Files["memprof0"])
-	if err != nil {
-		log.Fatalf("Failed to create profile file '%v': %v", res.Files["memprof0"], err)
-	}
-	pprof.WriteHeapProfile(memprof0)
-	memprof0.Close()
+	profile := dir != ""
+	if profile {
+		res.Files["memprof0"] = filepath.Join(dir, "memprof0")
+		memprof0, err := os.Create(res.Files["memprof0"])
+		if err != nil {
+			log.Fatalf("Failed to create profile file '%v': %v", res.Files["memprof0"], err)
+		}
+		pprof.WriteHeapProfile(memprof0)
+		memprof0.Close()
 
-	res.Files["cpuprof"] = tempFilename("cpuprof")
-	cpuprof, err := os.Create(res.Files["cpuprof"])
-	if err != nil {
-		log.Fatalf("Failed to create profile file '%v': %v", res.Files["cpuprof"], err)
+		res.Files["cpuprof"] = filepath.Join(dir, "cpuprof")
+		cpuprof, err := os.Create(res.Files["cpuprof"])
+		if err != nil {
+			log.Fatalf("Failed to create profile file '%v': %v", res.Files["cpuprof"], err)
+		}
+		defer cpuprof.Close()
+		pprof.StartCPUProfile(cpuprof)
 	}
-	defer cpuprof.Close()
-	pprof.StartCPUProfile(cpuprof)
 	t0 := time.Now()
 	f(N)
 	res.Duration = time.Since(t0)
 	res.RunTime = uint64(time.Since(t0)) / N
 	res.Metrics["ns/op"] = res.RunTime
-	pprof.StopCPUProfile()
+	if profile {
+		pprof.StopCPUProfile()
+	}
 
 	latencyCollect(&res)
 	ss.Collect(&res)
 
-	res.Files["memprof"] = tempFilename("memprof")
-	memprof, err := os.Create(res.Files["memprof"])
-	if err != nil {
-		log.Fatalf("Failed to create profile file '%v': %v", res.Files["memprof"], err)
+	if profile {
+		res.Files["memprof"] = filepath.Join(dir, "memprof")
+		memprof, err := os.Create(res.Files["memprof"])
+		if err != nil {
+			log.Fatalf("Failed to create profile file '%v': %v", res.Files["memprof"], err)
+		}
+		pprof.WriteHeapProfile(memprof)
+		memprof.Close()
 	}
-	pprof.WriteHeapProfile(memprof)
-	memprof.Close()
 
 	mstats1 := new(runtime.MemStats)
 	runtime.ReadMemStats(mstats1)
@@ -390,9 +405,20 @@ func max(a, b uint64) uint64 {
 	return b
 }
 
-var tmpSeq = 0
-
-func tempFilename(ext string) string {
-	tmpSeq++
-	return filepath.Join(*tmpDir, fmt.Sprintf("%v.%v", tmpSeq, ext))
+// newRunDir creates a directory under -tmpdir for the files of a single
+// benchmark run, or of a call to one of the helpers that produce files,
+// and returns its absolute path. The directory's name is unique, so
+// benchmark processes sharing -tmpdir, or runs within one, never write
+// to each other's files. It returns an empty string if the directory
+// can't be created, in which case no files are produced.
+func newRunDir() string {
+	dir, err := os.MkdirTemp(*tmpDir, fmt.Sprintf("%s.%d.*", filepath.Base(os.Args[0]), os.Getpid()))
+	if err == nil {
+		dir, err = filepath.Abs(dir)
+	}
+	if err != nil {
+		log.Printf("Failed to create directory for benchmark files: %v", err)
+		return ""
+	}
+	return dir
 }
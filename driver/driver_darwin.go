@@ -11,6 +11,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 )
 
 const rssMultiplier = 1
@@ -19,9 +20,14 @@ func RunUnderProfiler(args ...string) (string, string) {
 	return "", ""
 }
 
-// Size runs size command on the file. Returns filename with output. Any errors are ignored.
+// Size runs size command on the file. Returns filename with output, in a
+// directory of its own. Any errors are ignored.
 func Size(file string) string {
-	resf, err := os.Create(tempFilename("size.txt"))
+	dir := newRunDir()
+	if dir == "" {
+		return ""
+	}
+	resf, err := os.Create(filepath.Join(dir, "size.txt"))
 	if err != nil {
 		log.Printf("Failed to create output file: %v", err)
 		return ""
@@ -13,6 +13,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -25,24 +26,32 @@ import (
 
 const rssMultiplier = 1 << 10
 
-// Runs the cmd under perf. Returns filename of the profile. Any errors are ignored.
+// Runs the cmd under perf. Returns filenames of the reports, by process and
+// by function, in a directory of their own. Any errors are ignored.
 func RunUnderProfiler(args ...string) (string, string) {
-	cmd := exec.Command("perf", append([]string{"record", "-o", "perf.data"}, args...)...)
+	dir := newRunDir()
+	if dir == "" {
+		return "", ""
+	}
+	data := filepath.Join(dir, "perf.data")
+	cmd := exec.Command("perf", append([]string{"record", "-o", data}, args...)...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Printf("Failed to execute 'perf record %v': %v\n%v", args, err, string(out))
 		return "", ""
 	}
 
-	perf1 := perfReport("--sort", "comm")
-	perf2 := perfReport()
+	perf1 := perfReport(data, filepath.Join(dir, "perf-comm.txt"), "--sort", "comm")
+	perf2 := perfReport(data, filepath.Join(dir, "perf.txt"))
 	return perf1, perf2
 }
 
-func perfReport(args ...string) string {
+// perfReport writes the report of the perf data in data to the file out,
+// and returns its name.
+func perfReport(data, out string, args ...string) string {
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	cmd := exec.Command("perf", append([]string{"report", "--stdio"}, args...)...)
+	cmd := exec.Command("perf", append([]string{"report", "--stdio", "-i", data}, args...)...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -50,7 +59,7 @@ func perfReport(args ...string) string {
 		return ""
 	}
 
-	f, err := os.Create(tempFilename("perf.txt"))
+	f, err := os.Create(out)
 	if err != nil {
 		log.Printf("Failed to create profile file: %v", err)
 		return ""
@@ -81,9 +90,14 @@ func perfReport(args ...string) string {
 	return f.Name()
 }
 
-// Size runs size command on the file. Returns filename with output. Any errors are ignored.
+// Size runs size command on the file. Returns filename with output, in a
+// directory of its own. Any errors are ignored.
 func Size(file string) string {
-	resf, err := os.Create(tempFilename("size.txt"))
+	dir := newRunDir()
+	if dir == "" {
+		return ""
+	}
+	resf, err := os.Create(filepath.Join(dir, "size.txt"))
 	if err != nil {
 		log.Printf("Failed to create output file: %v", err)
 		return ""
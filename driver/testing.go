@@ -0,0 +1,29 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import "testing"
+
+// RunTesting runs f as a standard testing benchmark, so that benchmarks
+// written for this package can also be built with "go test -c" and run
+// with -test.bench, as bent does. It collects the same metrics as
+// Benchmark, other than profiles, and reports them alongside the
+// testing package's own, so results stay comparable with those printed
+// by Main. Only the time spent in f counts towards ns/op.
+func RunTesting(b *testing.B, f func(uint64)) {
+	b.StopTimer()
+	res := measure(func(N uint64) {
+		b.StartTimer()
+		f(N)
+		b.StopTimer()
+	}, uint64(b.N), "")
+	for metric, v := range res.Metrics {
+		if metric == "ns/op" {
+			// Already reported by the testing package.
+			continue
+		}
+		b.ReportMetric(float64(v), metric)
+	}
+}
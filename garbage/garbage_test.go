@@ -0,0 +1,25 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build bent
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/benchmarks/driver"
+)
+
+// BenchmarkGarbage runs the benchmark under the testing package, for bent.
+// bent builds it with the bent tag, which keeps it out of the plain
+// "go test" runs of cmd/bench.
+// It has the same name as the results printed by main.
+func BenchmarkGarbage(b *testing.B) {
+	b.Run(fmt.Sprintf("benchmem-MB=%d", driver.BenchMem()), func(b *testing.B) {
+		setup()
+		driver.RunTesting(b, benchmarkN)
+	})
+}
@@ -5,12 +5,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
 	"runtime/trace"
 	"sort"
+	"strconv"
 	"testing"
 	"time"
 	"unsafe"
@@ -20,6 +22,9 @@ const (
 	bufferLen   = 200_000
 	warmupCount = 1_000_000
 	runCount    = 5_000_000
+
+	// batchLen is the number of allocations in each trace region.
+	batchLen = 10_000
 )
 
 type kbyte []byte
@@ -31,10 +36,11 @@ type LB struct {
 	worst  time.Duration   // worst delay observed
 
 	// For making sense of the bad outcome.
-	total        time.Duration // total time spent in allocations
-	allStart     time.Time     // time (very nearly) at which the trace begins
-	worstIndex   int           // index of worst case allocation delay
-	worstElapsed time.Duration // duration of worst case allocation delay
+	total        time.Duration   // total time spent in allocations
+	allStart     time.Time       // time (very nearly) at which the trace begins
+	worstIndex   int             // index of worst case allocation delay
+	worstElapsed time.Duration   // duration of worst case allocation delay
+	traceCtx     context.Context // context of the trace task for the run, if any
 
 	sink *circularBuffer // assign a pointer here to ensure heap allocation
 
@@ -104,15 +110,38 @@ func (lb *LB) storeSlice(c *circularBuffer, count int) {
 		lb.worst = elapsed
 		lb.worstIndex = count
 		lb.worstElapsed = candElapsed
+		if trace.IsEnabled() {
+			// Mark the spot, so that the last of these in the trace is
+			// the worst case.
+			trace.Logf(lb.ctx(), "worst allocation", "index %d, latency %v", count, elapsed)
+		}
 	}
 	lb.total = time.Duration(lb.total.Nanoseconds() + elapsed.Nanoseconds())
 	lb.delays = append(lb.delays, elapsed)
 }
 
+// ctx returns the context to annotate the trace with.
+func (lb *LB) ctx() context.Context {
+	if lb.traceCtx == nil {
+		return context.Background()
+	}
+	return lb.traceCtx
+}
+
 //go:noinline
 func (lb *LB) work(c *circularBuffer, count int) {
-	for i := 0; i < count; i++ {
-		lb.storeSlice(c, i)
+	// Group allocations into batches, each a region in the trace labeled
+	// with the index of its first allocation, so that any index can be
+	// found in the trace.
+	for i := 0; i < count; i += batchLen {
+		region := trace.StartRegion(lb.ctx(), "allocation batch")
+		if trace.IsEnabled() {
+			trace.Log(lb.ctx(), "batch start index", strconv.Itoa(i))
+		}
+		for j := i; j < i+batchLen && j < count; j++ {
+			lb.storeSlice(c, j)
+		}
+		region.End()
 	}
 }
 
@@ -183,6 +212,11 @@ func (lb0 *LB) bench(b *testing.B) {
 			defer trace.Stop()
 		}
 		lb.allStart = time.Now() // this is for trace file navigation, not benchmark timing.
+		if trace.IsEnabled() {
+			var task *trace.Task
+			lb.traceCtx, task = trace.NewTask(context.Background(), "allocations")
+			defer task.End()
+		}
 
 		if b != nil {
 			count = b.N * count
@@ -224,6 +258,9 @@ func (lb0 *LB) bench(b *testing.B) {
 		fmt.Println("Worst allocation latency:", lb.worst)
 		fmt.Println("Worst allocation index:", lb.worstIndex)
 		fmt.Println("Worst allocation occurs at run elapsed time:", lb.worstElapsed)
+		if traceFile != "" {
+			fmt.Println("Worst allocation is marked in the trace by the last \"worst allocation\" log in the \"allocations\" task")
+		}
 		fmt.Println("Average allocation latency:", average)
 		fmt.Println("Median allocation latency:", median)
 		fmt.Println("99% allocation latency:", p29)
@@ -7,7 +7,10 @@ package main
 // Gc_latency is a modified version of a program that tickled multiple
 // latency glitches in the Go GC/runtime.  This version reports the time
 // of the worst observed glitches so that they can be easily located in
-// a trace file and debugged.  This program can also be run as a benchmark
+// a trace file and debugged.  When tracing, the allocations are grouped
+// into regions of an "allocations" task, and each new worst case is marked
+// with a "worst allocation" log, so the last such log in the trace is the
+// worst case.  This program can also be run as a benchmark
 // to allow easier automated performance monitoring; the benchmark doesn't
 // report worst case times because those are too noisy.
 //
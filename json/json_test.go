@@ -0,0 +1,20 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build bent
+
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/benchmarks/driver"
+)
+
+// BenchmarkJSON runs the benchmark under the testing package, for bent.
+// bent builds it with the bent tag, which keeps it out of the plain
+// "go test" runs of cmd/bench.
+func BenchmarkJSON(b *testing.B) {
+	driver.RunTesting(b, benchmarkN)
+}
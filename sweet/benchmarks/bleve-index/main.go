@@ -10,26 +10,36 @@ import (
 	"compress/bzip2"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
 	blevebench "golang.org/x/benchmarks/third_party/bleve-bench"
 
 	"github.com/blevesearch/bleve"
 	_ "github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/mapping"
 	wikiparse "github.com/dustin/go-wikiparse"
 )
 
 var (
-	batchSize int
-	documents int
+	batchSize   int
+	documents   int
+	shards      int
+	workersFlag string
+	workers     []int
 )
 
 func init() {
 	driver.SetFlags(flag.CommandLine)
 	flag.IntVar(&batchSize, "batch-size", 256, "number of index requests to batch together")
 	flag.IntVar(&documents, "documents", 1000, "number of documents to index")
+	flag.IntVar(&shards, "shards", 8, "number of index shards for parallel indexing")
+	flag.StringVar(&workersFlag, "workers", "", "comma-separated list of worker counts to run parallel indexing with (empty for none)")
 }
 
 func parseFlags() error {
@@ -37,6 +47,19 @@ func parseFlags() error {
 	if flag.NArg() != 1 {
 		return fmt.Errorf("expected wiki dump as input")
 	}
+	if shards < 1 {
+		return fmt.Errorf("-shards must be at least 1")
+	}
+	for _, s := range strings.Split(workersFlag, ",") {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid worker count %q", s)
+		}
+		workers = append(workers, n)
+	}
 	return nil
 }
 
@@ -73,7 +96,7 @@ func run(wikidump string) error {
 
 	mapping := blevebench.ArticleMapping()
 	name := fmt.Sprintf("BleveIndexBatch%d", batchSize)
-	return driver.RunBenchmark(name, func(d *driver.B) error {
+	err = driver.RunBenchmark(name, func(d *driver.B) error {
 		index, err := bleve.NewMemOnly(mapping)
 		if err != nil {
 			return err
@@ -82,20 +105,120 @@ func run(wikidump string) error {
 		for _, a := range articles {
 			b.Index(a.Title, a)
 			if b.Size() >= batchSize {
-				if err := index.Batch(b); err != nil {
+				d.StartPhase("batch")
+				err := index.Batch(b)
+				d.EndPhase("batch")
+				if err != nil {
 					return err
 				}
 				b = index.NewBatch()
 			}
 		}
 		if b.Size() != 0 {
-			if err := index.Batch(b); err != nil {
+			d.StartPhase("batch")
+			err := index.Batch(b)
+			d.EndPhase("batch")
+			if err != nil {
 				return err
 			}
 		}
 		d.StopTimer()
 		return index.Close()
 	}, driver.InProcessMeasurementOptions...)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range workers {
+		name := fmt.Sprintf("BleveIndexParallel/workers=%d", n)
+		err := driver.RunBenchmark(name, func(d *driver.B) error {
+			return indexParallel(d, mapping, articles, n)
+		}, driver.InProcessMeasurementOptions...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexParallel indexes articles from workers goroutines into an index
+// made up of several shards. Each article goes to the shard chosen by a
+// hash of its title, so every shard is written to by all of the workers
+// at once.
+func indexParallel(d *driver.B, m mapping.IndexMapping, articles []blevebench.Article, workers int) error {
+	indexes := make([]bleve.Index, shards)
+	for i := range indexes {
+		index, err := bleve.NewMemOnly(m)
+		if err != nil {
+			return err
+		}
+		indexes[i] = index
+	}
+	d.ResetTimer()
+
+	stream := make(chan blevebench.Article)
+	go func() {
+		defer close(stream)
+		for _, a := range articles {
+			stream <- a
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = indexWorker(indexes, stream)
+		}(w)
+	}
+	wg.Wait()
+	d.StopTimer()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	for _, index := range indexes {
+		if err := index.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexWorker indexes articles from stream until it is closed, keeping a
+// batch for each shard in indexes.
+func indexWorker(indexes []bleve.Index, stream <-chan blevebench.Article) error {
+	batches := make([]*bleve.Batch, len(indexes))
+	for i, index := range indexes {
+		batches[i] = index.NewBatch()
+	}
+	for a := range stream {
+		h := fnv.New32a()
+		h.Write([]byte(a.Title))
+		i := int(h.Sum32() % uint32(len(indexes)))
+		if err := batches[i].Index(a.Title, a); err != nil {
+			return err
+		}
+		if batches[i].Size() >= batchSize {
+			if err := indexes[i].Batch(batches[i]); err != nil {
+				return err
+			}
+			batches[i] = indexes[i].NewBatch()
+		}
+	}
+	for i, b := range batches {
+		if b.Size() == 0 {
+			continue
+		}
+		if err := indexes[i].Batch(b); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func main() {
@@ -0,0 +1,124 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is an in-memory cache of values by key.
+type cache interface {
+	Get(key uint64) ([]byte, bool)
+	Set(key uint64, value []byte)
+}
+
+// newCache returns a new cache of the named kind holding up to capacity
+// entries.
+func newCache(kind string, capacity int) cache {
+	switch kind {
+	case "sharded":
+		return newShardedCache(capacity)
+	case "syncmap":
+		return new(syncMapCache)
+	}
+	panic("unknown cache " + kind)
+}
+
+// numShards is the number of shards of a shardedCache, enough that
+// goroutines on different CPUs rarely want the same shard at once,
+// except for the hottest keys.
+const numShards = 64
+
+// shardedCache is an LRU cache split into shards by key, each with its own
+// lock, in the style of groupcache and ristretto. Gets update the recency
+// of their entry, so they take the lock exclusively too, and a popular key
+// contends on its shard.
+type shardedCache struct {
+	shards [numShards]shard
+}
+
+type shard struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[uint64]*list.Element
+	lru      list.List // Of *entry, most recently used first.
+
+	// Pad shards out to separate cache lines, so that locking one
+	// doesn't slow down goroutines using its neighbors.
+	_ [64]byte
+}
+
+type entry struct {
+	key   uint64
+	value []byte
+}
+
+func newShardedCache(capacity int) *shardedCache {
+	c := new(shardedCache)
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.capacity = max(1, capacity/numShards)
+		s.entries = make(map[uint64]*list.Element, s.capacity)
+	}
+	return c
+}
+
+func (c *shardedCache) shard(key uint64) *shard {
+	// Mix the bits of the key, so that neighboring keys, which are
+	// similarly popular, end up in different shards.
+	key ^= key >> 33
+	key *= 0xff51afd7ed558ccd
+	key ^= key >> 33
+	return &c.shards[key%numShards]
+}
+
+func (c *shardedCache) Get(key uint64) ([]byte, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(e)
+	return e.Value.(*entry).value, true
+}
+
+func (c *shardedCache) Set(key uint64, value []byte) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.Value.(*entry).value = value
+		s.lru.MoveToFront(e)
+		return
+	}
+	if s.lru.Len() >= s.capacity {
+		oldest := s.lru.Back()
+		delete(s.entries, oldest.Value.(*entry).key)
+		s.lru.Remove(oldest)
+	}
+	s.entries[key] = s.lru.PushFront(&entry{key, value})
+}
+
+// syncMapCache is a cache that never evicts, backed by a sync.Map, as is
+// common for read-mostly caches over a bounded set of keys. Its Gets take
+// no locks once a key is stable, but Sets of new keys do.
+type syncMapCache struct {
+	m sync.Map
+}
+
+func (c *syncMapCache) Get(key uint64) ([]byte, bool) {
+	v, ok := c.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+func (c *syncMapCache) Set(key uint64, value []byte) {
+	c.m.Store(key, value)
+}
@@ -0,0 +1,219 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/keydist"
+)
+
+type config struct {
+	caches    []string
+	ops       int
+	keys      int
+	keyDist   keydist.Distribution
+	capacity  int
+	setFrac   float64
+	valueSize int
+	short     bool
+}
+
+var (
+	cliCfg     config
+	cachesFlag string
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&cachesFlag, "caches", "sharded,syncmap", "comma-separated list of caches to benchmark (sharded, syncmap)")
+	flag.IntVar(&cliCfg.ops, "ops", 4000000, "number of operations to perform at each level of contention")
+	flag.IntVar(&cliCfg.keys, "keys", 1<<20, "number of distinct keys")
+	cliCfg.keyDist = defaultKeyDist
+	flag.Var(&cliCfg.keyDist, "key-dist", "distribution of the popularity of keys: "+keydist.Usage)
+	flag.IntVar(&cliCfg.capacity, "capacity", 1<<16, "number of entries the sharded cache holds before evicting")
+	flag.Float64Var(&cliCfg.setFrac, "set-fraction", 0.1, "fraction of operations that set a key rather than get it")
+	flag.IntVar(&cliCfg.valueSize, "value-bytes", 128, "size of each value in bytes")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// defaultKeyDist is the distribution of keys by default. Results for other
+// distributions are named after theirs.
+var defaultKeyDist = keydist.Distribution{Kind: keydist.Zipf, Skew: 1.1}
+
+// level is a level of contention: a number of goroutines using the cache
+// at once, as a multiple of GOMAXPROCS, or exactly one.
+type level struct {
+	name    string
+	perProc int // Goroutines per P, or 0 for a single goroutine.
+}
+
+// levels are the levels of contention each cache is benchmarked at: no
+// contention, one goroutine for each P, and more goroutines than Ps, as
+// when a server handles many more requests at once than it has CPUs.
+var levels = []level{
+	{"1", 0},
+	{"P", 1},
+	{"4P", 4},
+}
+
+func (l level) goroutines() int {
+	if l.perProc == 0 {
+		return 1
+	}
+	return l.perProc * runtime.GOMAXPROCS(-1)
+}
+
+// latencySampleEvery is how many operations each goroutine performs per
+// operation it measures the latency of. Reading the clock for every
+// operation would take about as long as the operation itself.
+const latencySampleEvery = 8
+
+type worker struct {
+	cache cache
+	cfg   *config
+	keys  *keydist.Generator
+	rng   *rand.Rand
+	ops   int
+	lat   []time.Duration
+}
+
+func newWorker(c cache, cfg *config, seed int64, ops int) *worker {
+	rng := rand.New(rand.NewSource(seed))
+	return &worker{
+		cache: c,
+		cfg:   cfg,
+		keys:  cfg.keyDist.New(rng, uint64(cfg.keys)),
+		rng:   rng,
+		ops:   ops,
+		lat:   make([]time.Duration, 0, ops/latencySampleEvery+1),
+	}
+}
+
+// op performs one operation: a set, or a get, which fills the entry on a
+// miss, as a cache in front of a slower store would.
+func (w *worker) op() {
+	key := w.keys.Next()
+	if w.rng.Float64() < w.cfg.setFrac {
+		w.cache.Set(key, make([]byte, w.cfg.valueSize))
+		return
+	}
+	if _, ok := w.cache.Get(key); !ok {
+		w.cache.Set(key, make([]byte, w.cfg.valueSize))
+	}
+}
+
+func (w *worker) run() {
+	for i := 0; i < w.ops; i++ {
+		if i%latencySampleEvery != 0 {
+			w.op()
+			continue
+		}
+		start := time.Now()
+		w.op()
+		w.lat = append(w.lat, time.Since(start))
+	}
+}
+
+func runBenchmark(d *driver.B, cfg *config, kind string, l level, ops int) error {
+	c := newCache(kind, cfg.capacity)
+
+	// Start with the most popular keys in the cache, as a cache that has
+	// been serving for a while would.
+	for key := 0; key < min(cfg.keys, cfg.capacity); key++ {
+		c.Set(uint64(key), make([]byte, cfg.valueSize))
+	}
+
+	n := l.goroutines()
+	workers := make([]*worker, n)
+	for i := range workers {
+		workers[i] = newWorker(c, cfg, int64(i), max(1, ops/n))
+	}
+
+	d.ResetTimer()
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run()
+		}()
+	}
+	wg.Wait()
+	d.StopTimer()
+
+	var total int
+	var latencies []time.Duration
+	for _, w := range workers {
+		total += w.ops
+		latencies = append(latencies, w.lat...)
+	}
+	slices.Sort(latencies)
+
+	d.Report("p50-latency-ns", uint64(latencies[len(latencies)*50/100]))
+	d.Report("p99-latency-ns", uint64(latencies[len(latencies)*99/100]))
+
+	lengthS := float64(d.Elapsed()) / float64(time.Second)
+	d.Report("ops/s", uint64(float64(total)/lengthS))
+
+	d.Ops(total)
+	d.Report(driver.StatTime, uint64((int(d.Elapsed())*n)/total))
+	return nil
+}
+
+func run(cfg *config) error {
+	ops := cfg.ops
+	if cfg.short {
+		ops = 10000
+	}
+	for _, kind := range cfg.caches {
+		for _, l := range levels {
+			name := fmt.Sprintf("Cache/cache=%s/goroutines=%s", kind, l.name)
+			if cfg.keyDist != defaultKeyDist {
+				name += "/dist=" + cfg.keyDist.String()
+			}
+			err := driver.RunBenchmark(name, func(d *driver.B) error {
+				return runBenchmark(d, cfg, kind, l, ops)
+			}, driver.InProcessMeasurementOptions...)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	for _, kind := range strings.Split(cachesFlag, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind != "sharded" && kind != "syncmap" {
+			fmt.Fprintf(os.Stderr, "error: -caches: unknown cache %q, want one of sharded, syncmap\n", kind)
+			os.Exit(1)
+		}
+		cliCfg.caches = append(cliCfg.caches, kind)
+	}
+	if cliCfg.keys < 2 || cliCfg.capacity < 1 || cliCfg.ops < 1 {
+		fmt.Fprintf(os.Stderr, "error: -keys must be at least 2, and -capacity and -ops at least 1\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
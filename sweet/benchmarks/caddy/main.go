@@ -0,0 +1,445 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/par"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/pool"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/server"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
+)
+
+const host = "127.0.0.1"
+
+type config struct {
+	caddyBin    string
+	tmpDir      string
+	serverProcs int
+	gomaxprocs  int
+	short       bool
+	ports       server.PortAllocator
+
+	port         int // Caddy's site.
+	adminPort    int // Caddy's admin API, which also serves pprof.
+	upstreamPort int // The backend Caddy proxies API requests to.
+}
+
+var cliCfg config
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&cliCfg.caddyBin, "caddy", "", "path to caddy binary")
+	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to temporary directory")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+	cliCfg.ports.SetFlags(flag.CommandLine)
+
+	// Grab the number of procs we have and give ourselves, the clients and
+	// the upstream server, only 1/4 of those.
+	procs := runtime.GOMAXPROCS(-1)
+	clientProcs := procs / 4
+	if clientProcs == 0 {
+		clientProcs = 1
+	}
+	serverProcs := procs - clientProcs
+	if serverProcs == 0 {
+		serverProcs = 1
+	}
+	runtime.GOMAXPROCS(clientProcs)
+	cliCfg.serverProcs = serverProcs
+	cliCfg.gomaxprocs = procs
+}
+
+// siteFiles are the static files Caddy serves, with their sizes. They
+// stand in for a page, its script and an image.
+var siteFiles = []struct {
+	name string
+	size int
+}{
+	{"index.html", 8 << 10},
+	{"app.js", 64 << 10},
+	{"hero.jpg", 256 << 10},
+}
+
+// requestMix is the mix of requests the clients make: mostly for the
+// page, and for the API behind it, which Caddy proxies to the upstream
+// server, with fewer requests for the larger files.
+var requestMix = []string{
+	"/index.html",
+	"/index.html",
+	"/index.html",
+	"/api/items",
+	"/api/items",
+	"/api/items",
+	"/app.js",
+	"/app.js",
+	"/hero.jpg",
+}
+
+// writeSite writes the static site into dir. The contents are random, but
+// deterministic.
+func writeSite(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	r := rand.New(rand.NewSource(0))
+	for _, f := range siteFiles {
+		data := make([]byte, f.size)
+		r.Read(data)
+		if err := os.WriteFile(filepath.Join(dir, f.name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// caddyConfig returns the JSON configuration for Caddy, which serves the
+// files in siteDir and proxies everything under /api/ to the upstream
+// server.
+func caddyConfig(cfg *config, siteDir string) ([]byte, error) {
+	type m = map[string]any
+	return json.MarshalIndent(m{
+		"admin": m{"listen": fmt.Sprintf("%s:%d", host, cfg.adminPort)},
+		"logging": m{
+			"logs": m{"default": m{"level": "ERROR"}},
+		},
+		"apps": m{
+			"http": m{
+				"servers": m{
+					"bench": m{
+						"listen":          []string{fmt.Sprintf("%s:%d", host, cfg.port)},
+						"automatic_https": m{"disable": true},
+						"routes": []m{
+							{
+								"match": []m{{"path": []string{"/api/*"}}},
+								"handle": []m{{
+									"handler":   "reverse_proxy",
+									"upstreams": []m{{"dial": fmt.Sprintf("%s:%d", host, cfg.upstreamPort)}},
+								}},
+							},
+							{
+								"handle": []m{{
+									"handler": "file_server",
+									"root":    siteDir,
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, "", "\t")
+}
+
+// apiResponse is the body the upstream server returns for every request.
+var apiResponse = func() []byte {
+	type item struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Price int    `json:"price"`
+	}
+	items := make([]item, 20)
+	for i := range items {
+		items[i] = item{ID: i, Name: "item-" + strconv.Itoa(i), Price: 100 * i}
+	}
+	b, err := json.Marshal(map[string]any{"items": items})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+// startUpstream starts the backend server Caddy proxies API requests to.
+func startUpstream(cfg *config) (*http.Server, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", host, cfg.upstreamPort))
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(apiResponse)
+	})}
+	go srv.Serve(ln)
+	return srv, nil
+}
+
+func launchServer(cfg *config, out io.Writer) (*exec.Cmd, error) {
+	siteDir := filepath.Join(cfg.tmpDir, "site")
+	if err := writeSite(siteDir); err != nil {
+		return nil, err
+	}
+	conf, err := caddyConfig(cfg, siteDir)
+	if err != nil {
+		return nil, err
+	}
+	confFile := filepath.Join(cfg.tmpDir, "caddy.json")
+	if err := os.WriteFile(confFile, conf, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(cfg.caddyBin, "run", "--config", confFile)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("GOMAXPROCS=%d", cfg.serverProcs),
+		// Keep Caddy's data and autosaved configuration out of
+		// the user's home directory.
+		"XDG_DATA_HOME="+filepath.Join(cfg.tmpDir, "data"),
+		"XDG_CONFIG_HOME="+filepath.Join(cfg.tmpDir, "config"),
+	)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := driver.StartChild(cmd); err != nil {
+		return nil, fmt.Errorf("failed to start server: %v", err)
+	}
+
+	// Poll until the server is ready to serve, up to 60 seconds.
+	url := fmt.Sprintf("http://%s:%d/index.html", host, cfg.port)
+	testConnection := func() error {
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+	start := time.Now()
+	for time.Since(start) < 60*time.Second {
+		err = testConnection()
+		if err == nil {
+			return cmd, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	return nil, fmt.Errorf("timeout trying to connect to server: %v", err)
+}
+
+// worker is a closed-loop client with its own connection: it issues its
+// next request as soon as the previous one completes, until the shared
+// iteration budget runs out.
+type worker struct {
+	client    *http.Client
+	port      int
+	iterCount *int64 // Accessed atomically.
+	lat       []time.Duration
+}
+
+func newWorker(port int, iterCount *int64) *worker {
+	return &worker{
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxConnsPerHost:    1,
+				DisableCompression: true,
+			},
+		},
+		port:      port,
+		iterCount: iterCount,
+		lat:       make([]time.Duration, 0, 10000),
+	}
+}
+
+func (w *worker) Run(_ context.Context) error {
+	count := atomic.AddInt64(w.iterCount, -1)
+	if count < 0 {
+		return pool.Done
+	}
+	url := fmt.Sprintf("http://%s:%d%s", host, w.port, requestMix[count%int64(len(requestMix))])
+	start := time.Now()
+	resp, err := w.client.Get(url)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	w.lat = append(w.lat, time.Since(start))
+	return nil
+}
+
+func (w *worker) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+type durSlice []time.Duration
+
+func (d durSlice) Len() int           { return len(d) }
+func (d durSlice) Less(i, j int) bool { return d[i] < d[j] }
+func (d durSlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// runBenchmark makes iters requests to the site on port from the given
+// number of clients and reports the throughput and latencies.
+func runBenchmark(d *driver.B, port, clients, iters int) error {
+	workers := make([]pool.Worker, 0, clients)
+	iterCount := int64(iters) // Shared atomic variable.
+	for i := 0; i < clients; i++ {
+		workers = append(workers, newWorker(port, &iterCount))
+	}
+	p := pool.New(context.Background(), workers)
+
+	d.ResetTimer()
+	start := time.Now()
+	if err := p.Run(); err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	d.StopTimer()
+
+	// Test is done, bring all latency measurements together.
+	latencies := make([]time.Duration, 0, iters)
+	for _, w := range workers {
+		latencies = append(latencies, w.(*worker).lat...)
+	}
+
+	// Sort and report percentiles.
+	sort.Sort(durSlice(latencies))
+	p50 := latencies[len(latencies)*50/100]
+	p90 := latencies[len(latencies)*90/100]
+	p99 := latencies[len(latencies)*99/100]
+	p999 := latencies[len(latencies)*999/1000]
+	d.Report("p50-latency-ns", uint64(p50))
+	d.Report("p90-latency-ns", uint64(p90))
+	d.Report("p99-latency-ns", uint64(p99))
+	d.Report("p99.9-latency-ns", uint64(p999))
+
+	// Report throughput.
+	d.Report("requests/s", uint64(float64(len(latencies))/elapsed.Seconds()))
+
+	// Report the average request latency.
+	d.Ops(len(latencies))
+	d.Report(driver.StatTime, uint64((int(elapsed)*clients)/len(latencies)))
+	return nil
+}
+
+const benchName = "Caddy"
+
+func run(cfg *config) (err error) {
+	// Pick the ports the servers listen on.
+	defer cfg.ports.Release()
+	ports, err := cfg.ports.Reserve(3)
+	if err != nil {
+		return err
+	}
+	cfg.port, cfg.adminPort, cfg.upstreamPort = ports[0], ports[1], ports[2]
+
+	upstream, err := startUpstream(cfg)
+	if err != nil {
+		return fmt.Errorf("starting upstream server: %v", err)
+	}
+	defer upstream.Close()
+
+	// Launch the server.
+	var buf bytes.Buffer
+	srvCmd, err := launchServer(cfg, &buf)
+	if err != nil {
+		return fmt.Errorf("starting server: %v\n%s", err, &buf)
+	}
+
+	// Clean up the server process after we're done.
+	defer func() {
+		if r := srvCmd.Process.Signal(os.Interrupt); r != nil {
+			if err == nil {
+				err = r
+			} else {
+				fmt.Fprintf(os.Stderr, "failed to shut down server: %v\n", r)
+			}
+			return
+		}
+		if _, r := srvCmd.Process.Wait(); r != nil {
+			if err == nil {
+				err = r
+			} else {
+				fmt.Fprintf(os.Stderr, "failed to wait for server to exit: %v\n", r)
+			}
+			return
+		}
+		if buf.Len() != 0 {
+			fmt.Fprintln(os.Stderr, "=== Server stdout+stderr ===")
+			fmt.Fprintln(os.Stderr, buf.String())
+		}
+	}()
+
+	opts := []driver.RunOption{
+		driver.DoPeakRSS(true),
+		driver.DoPeakVM(true),
+		driver.DoDefaultAvgRSS(),
+		driver.DoCoreDump(true),
+		driver.BenchmarkPID(srvCmd.Process.Pid),
+		driver.WithEnv(srvCmd.Env),
+		driver.DoPerf(true),
+		driver.DoClientCPU(true),
+		driver.WithGOMAXPROCS(cfg.gomaxprocs),
+	}
+	iters := 200000
+	if cfg.short {
+		iters = 100
+	}
+	return driver.RunBenchmark(benchName, func(d *driver.B) error {
+		// Collect diagnostics from Caddy's admin endpoint, which serves
+		// the standard pprof handlers.
+		var stopAll par.Funcs
+		diag := driver.NewDiagnostics(benchName)
+		for _, typ := range diagnostics.Types() {
+			if typ.HTTPEndpoint() == "" {
+				continue
+			}
+			stop := server.FetchDiagnostic(fmt.Sprintf("%s:%d", host, cfg.adminPort), diag, typ, diagnostics.ServerName)
+			stopAll.Add(stop)
+		}
+		defer diag.Commit(d)
+		defer stopAll.Run()
+
+		// Use a few clients per server thread, so that Caddy is kept
+		// busy while some of them wait on the network.
+		return runBenchmark(d, cfg.port, 4*cfg.serverProcs, iters)
+	}, opts...)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	if cliCfg.caddyBin == "" {
+		fmt.Fprintf(os.Stderr, "error: expected non-empty caddy flag\n")
+		os.Exit(1)
+	}
+	if cliCfg.tmpDir == "" {
+		fmt.Fprintf(os.Stderr, "error: expected non-empty tmp flag\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
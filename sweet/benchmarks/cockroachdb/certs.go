@@ -0,0 +1,126 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !wasm
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/server"
+)
+
+// certsDir returns the directory holding the certificates of the cluster
+// and of its root client, for secure benchmarks.
+func (c *config) certsDir() string {
+	return filepath.Join(c.tmpDir, "certs")
+}
+
+// createCerts creates a CA, a certificate for the nodes, valid for every
+// address they are reached at, and a certificate for the root user, in
+// cfg.certsDir.
+func createCerts(cfg *config) error {
+	certsDir := cfg.certsDir()
+	if err := os.MkdirAll(certsDir, 0o700); err != nil {
+		return err
+	}
+	// The CA key must stay out of the certificates directory.
+	caKey := filepath.Join(cfg.tmpDir, "ca.key")
+	hosts := []string{"localhost", "127.0.0.1", cfg.host}
+	if addr := cfg.client.ServerAddr(cfg.host); addr != cfg.host {
+		hosts = append(hosts, addr)
+	}
+	for _, args := range [][]string{
+		{"cert", "create-ca"},
+		append([]string{"cert", "create-node"}, hosts...),
+		{"cert", "create-client", "root"},
+	} {
+		args = append(args, "--certs-dir", certsDir, "--ca-key", caKey)
+		cmd := exec.Command(cfg.cockroachdbBin, args...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("running %s: %v: output:\n%s", cmd, err, out)
+		}
+	}
+	return nil
+}
+
+// securityArgs returns the flags that cockroach commands need to reach the
+// cluster: its certificates for secure benchmarks, and --insecure otherwise.
+func (c *config) securityArgs() []string {
+	if c.bench.secure {
+		return []string{"--certs-dir", c.certsDir()}
+	}
+	return []string{"--insecure"}
+}
+
+// pgURL returns the URL with which the workload connects as root to the
+// node at addr, over TLS with the root client's certificate for secure
+// benchmarks.
+func (c *config) pgURL(addr string) string {
+	q := url.Values{}
+	if c.bench.secure {
+		certsDir := c.certsDir()
+		q.Set("sslmode", "verify-full")
+		q.Set("sslrootcert", filepath.Join(certsDir, "ca.crt"))
+		q.Set("sslcert", filepath.Join(certsDir, "client.root.crt"))
+		q.Set("sslkey", filepath.Join(certsDir, "client.root.key"))
+	} else {
+		q.Set("sslmode", "disable")
+	}
+	u := url.URL{Scheme: "postgres", User: url.User("root"), Host: addr, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// httpEndpoint returns where the node i serves its HTTP endpoints, such as
+// pprof's. A secure cluster serves them over TLS, and only to a logged-in
+// admin, so for one it logs root in, once, for a session cookie.
+func (c *config) httpEndpoint(i *cockroachdbInstance) (server.Endpoint, error) {
+	if !c.bench.secure {
+		return server.Endpoint{URL: "http://" + i.httpAddr()}, nil
+	}
+	if c.httpClient == nil {
+		ca, err := os.ReadFile(filepath.Join(c.certsDir(), "ca.crt"))
+		if err != nil {
+			return server.Endpoint{}, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return server.Endpoint{}, fmt.Errorf("no certificates in %s", filepath.Join(c.certsDir(), "ca.crt"))
+		}
+		c.httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	}
+	if c.session == "" {
+		// The session is stored by the cluster, so it works on every node.
+		args := append([]string{"auth-session", "login", "root", "--only-cookie"}, c.securityArgs()...)
+		cmd := exec.Command(c.cockroachdbBin, append(args,
+			fmt.Sprintf("--host=%s", c.host),
+			fmt.Sprintf("--port=%d", i.sqlPort),
+		)...)
+		out, err := cmd.Output()
+		if err != nil {
+			return server.Endpoint{}, fmt.Errorf("logging in for a session cookie: %v", err)
+		}
+		// The cookie comes as for a Set-Cookie header; only its name and
+		// value go back to the server.
+		cookie, _, _ := strings.Cut(strings.TrimSpace(string(out)), ";")
+		if cookie == "" {
+			return server.Endpoint{}, fmt.Errorf("logging in for a session cookie: no cookie in output %q", out)
+		}
+		c.session = cookie
+	}
+	return server.Endpoint{
+		URL:    "https://" + i.httpAddr(),
+		Client: c.httpClient,
+		Header: http.Header{"Cookie": {c.session}},
+	}, nil
+}
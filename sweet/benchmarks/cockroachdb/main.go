@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,14 +27,13 @@ import (
 	"time"
 
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/keydist"
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/par"
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/server"
 	"golang.org/x/benchmarks/sweet/common/diagnostics"
 )
 
 const (
-	// Arbitrarily chosen to match the cockroachdb default.
-	basePort = 26257
 	// The percentage of memory to allocate to the pebble cache.
 	cacheSize = "0.25"
 )
@@ -43,9 +43,18 @@ type config struct {
 	cockroachdbBin string
 	tmpDir         string
 	benchName      string
+	keyDist        string
 	short          bool
-	procsPerInst   int
+	serverProcs    int // GOMAXPROCS of each node.
+	clientProcs    int // GOMAXPROCS of the workload, or 0 to leave it alone.
 	bench          *benchmark
+	client         server.ClientConfig
+	ports          server.PortAllocator
+
+	// httpClient and session reach the HTTP endpoints of a secure
+	// cluster, once root has logged in (see httpEndpoint).
+	httpClient *http.Client
+	session    string
 }
 
 var cliCfg config
@@ -56,7 +65,12 @@ func init() {
 	flag.StringVar(&cliCfg.cockroachdbBin, "cockroachdb-bin", "", "path to cockroachdb binary")
 	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to temporary directory")
 	flag.StringVar(&cliCfg.benchName, "bench", "", "name of the benchmark to run")
+	flag.StringVar(&cliCfg.keyDist, "key-dist", string(keydist.Uniform), "distribution of the keys kv benchmarks access: uniform, or zipf, with the skew built into the workload, which can't be set")
 	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+	flag.IntVar(&cliCfg.serverProcs, "server-procs", 0, "GOMAXPROCS of each cockroachdb node (default: the CPUs not given to the workload, split evenly between the nodes)")
+	flag.IntVar(&cliCfg.clientProcs, "client-procs", 0, "GOMAXPROCS of the workload (default: as much as each node, or with -client-host, the client host's default)")
+	cliCfg.client.SetFlags(flag.CommandLine)
+	cliCfg.ports.SetFlags(flag.CommandLine)
 }
 
 type cockroachdbInstance struct {
@@ -75,48 +89,52 @@ func clusterAddresses(instances []*cockroachdbInstance) string {
 	return strings.Join(s, ",")
 }
 
-func launchSingleNodeCluster(cfg *config) ([]*cockroachdbInstance, error) {
+func launchSingleNodeCluster(cfg *config, ports []int) ([]*cockroachdbInstance, error) {
 	var instances []*cockroachdbInstance
 	instances = append(instances, &cockroachdbInstance{
 		name:     "roach-node",
-		sqlPort:  basePort,
-		httpPort: basePort + 1,
+		sqlPort:  ports[0],
+		httpPort: ports[1],
 	})
 	inst := instances[0]
 
 	// `cockroach start-single-node` handles both creation of the node
 	// and initialization.
-	inst.cmd = exec.Command(cfg.cockroachdbBin,
-		"start-single-node",
-		"--insecure",
-		"--listen-addr", inst.sqlAddr(),
+	args := append([]string{"start-single-node"}, cfg.securityArgs()...)
+	inst.cmd = exec.Command(cfg.cockroachdbBin, append(args,
+		"--listen-addr", inst.listenAddr(cfg),
+		"--advertise-addr", inst.clientAddr(cfg),
 		"--http-addr", inst.httpAddr(),
 		"--cache", cacheSize,
 		"--store", filepath.Join(cfg.tmpDir, inst.name),
 		"--log-dir", filepath.Join(cfg.tmpDir, inst.name+"-log"),
-	)
+	)...)
 	inst.cmd.Env = append(os.Environ(),
-		fmt.Sprintf("GOMAXPROCS=%d", cfg.procsPerInst),
+		fmt.Sprintf("GOMAXPROCS=%d", cfg.serverProcs),
 	)
 	inst.cmd.Stdout = &inst.output
 	inst.cmd.Stderr = &inst.output
-	if err := inst.cmd.Start(); err != nil {
+	if err := driver.StartChild(inst.cmd); err != nil {
 		return nil, fmt.Errorf("failed to start instance %q: %v", inst.name, err)
 	}
 	return instances, nil
 }
 
 func launchCockroachCluster(cfg *config) ([]*cockroachdbInstance, error) {
+	ports, err := cfg.ports.Reserve(2 * cfg.bench.nodeCount)
+	if err != nil {
+		return nil, err
+	}
 	if cfg.bench.nodeCount == 1 {
 		// Use `cockroach start-single-node` instead for single node clusters.
-		return launchSingleNodeCluster(cfg)
+		return launchSingleNodeCluster(cfg, ports)
 	}
 	var instances []*cockroachdbInstance
 	for i := 0; i < cfg.bench.nodeCount; i++ {
 		instances = append(instances, &cockroachdbInstance{
 			name:     fmt.Sprintf("roach-node-%d", i+1),
-			sqlPort:  basePort + 2*i,
-			httpPort: basePort + 2*i + 1,
+			sqlPort:  ports[2*i],
+			httpPort: ports[2*i+1],
 		})
 	}
 
@@ -125,36 +143,35 @@ func launchCockroachCluster(cfg *config) ([]*cockroachdbInstance, error) {
 		allOtherInstances := append(instances[:n:n], instances[n+1:]...)
 		join := fmt.Sprintf("--join=%s", clusterAddresses(allOtherInstances))
 
-		inst.cmd = exec.Command(cfg.cockroachdbBin,
-			"start",
-			"--insecure",
-			"--listen-addr", inst.sqlAddr(),
+		args := append([]string{"start"}, cfg.securityArgs()...)
+		inst.cmd = exec.Command(cfg.cockroachdbBin, append(args,
+			"--listen-addr", inst.listenAddr(cfg),
+			"--advertise-addr", inst.clientAddr(cfg),
 			"--http-addr", inst.httpAddr(),
 			"--cache", cacheSize,
 			"--store", filepath.Join(cfg.tmpDir, inst.name),
 			"--log-dir", filepath.Join(cfg.tmpDir, inst.name+"-log"),
 			join,
-		)
+		)...)
 		inst.cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GOMAXPROCS=%d", cfg.procsPerInst),
+			fmt.Sprintf("GOMAXPROCS=%d", cfg.serverProcs),
 		)
 		inst.cmd.Stdout = &inst.output
 		inst.cmd.Stderr = &inst.output
-		if err := inst.cmd.Start(); err != nil {
+		if err := driver.StartChild(inst.cmd); err != nil {
 			return nil, fmt.Errorf("failed to start instance %q: %v", inst.name, err)
 		}
 	}
 
 	// Initialize the cluster with `cockroach init`.
 	inst1 := instances[0]
-	initCmd := exec.Command(cfg.cockroachdbBin,
-		"init",
-		"--insecure",
+	initArgs := append([]string{"init"}, cfg.securityArgs()...)
+	initCmd := exec.Command(cfg.cockroachdbBin, append(initArgs,
 		fmt.Sprintf("--host=%s", cfg.host),
 		fmt.Sprintf("--port=%d", inst1.sqlPort),
-	)
+	)...)
 	initCmd.Env = append(os.Environ(),
-		fmt.Sprintf("GOMAXPROCS=%d", cfg.procsPerInst),
+		fmt.Sprintf("GOMAXPROCS=%d", cfg.serverProcs),
 	)
 	initCmd.Stdout = &inst1.output
 	initCmd.Stderr = &inst1.output
@@ -222,13 +239,12 @@ func (i *cockroachdbInstance) setClusterSettings(cfg *config) error {
 
 	// Multi-line cluster setting changes aren't allowed.
 	for _, setting := range settings {
-		cmd := exec.Command(cfg.cockroachdbBin,
-			"sql",
-			"--insecure",
+		args := append([]string{"sql"}, cfg.securityArgs()...)
+		cmd := exec.Command(cfg.cockroachdbBin, append(args,
 			fmt.Sprintf("--host=%s", cfg.host),
 			fmt.Sprintf("--port=%d", i.sqlPort),
 			"--execute", fmt.Sprintf("SET CLUSTER SETTING %s;", setting),
-		)
+		)...)
 		cmd.Stdout = &i.output
 		cmd.Stderr = &i.output
 		if err := cmd.Run(); err != nil {
@@ -244,13 +260,11 @@ func (i *cockroachdbInstance) ping(cfg *config) error {
 	// `node status` is a bit arbitrary, if it responds at all
 	// we know the node is live. Generally it is used to see if
 	// *other* nodes are live.
-	cmd := exec.Command(cfg.cockroachdbBin,
-		"node",
-		"status",
-		"--insecure",
+	args := append([]string{"node", "status"}, cfg.securityArgs()...)
+	cmd := exec.Command(cfg.cockroachdbBin, append(args,
 		fmt.Sprintf("--host=%s", cfg.host),
 		fmt.Sprintf("--port=%d", i.sqlPort),
-	)
+	)...)
 	cmd.Stdout = &i.output
 	cmd.Stderr = &i.output
 	if err := cmd.Run(); err != nil {
@@ -259,12 +273,18 @@ func (i *cockroachdbInstance) ping(cfg *config) error {
 	// Check to see if profiling works. We don't care what the data is, we just want to
 	// make sure that it doesn't fail. We've unfortunately seen this fail before.
 	// See #56958.
-	endpoint := fmt.Sprintf("http://%s:%d/%s", cfg.host, i.httpPort, diagnostics.MemProfile.HTTPEndpoint())
-	resp, err := http.Get(endpoint)
+	ep, err := cfg.httpEndpoint(i)
+	if err != nil {
+		return err
+	}
+	resp, err := ep.Get(context.Background(), diagnostics.MemProfile.HTTPEndpoint())
 	if err != nil {
 		return err
 	}
 	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", diagnostics.MemProfile, resp.Status)
+	}
 	return nil
 }
 
@@ -272,6 +292,16 @@ func (i *cockroachdbInstance) sqlAddr() string {
 	return fmt.Sprintf("%s:%d", cliCfg.host, i.sqlPort)
 }
 
+// listenAddr returns the address on which i accepts SQL connections.
+func (i *cockroachdbInstance) listenAddr(cfg *config) string {
+	return fmt.Sprintf("%s:%d", cfg.client.ListenHost(cfg.host), i.sqlPort)
+}
+
+// clientAddr returns the address at which the workload reaches i.
+func (i *cockroachdbInstance) clientAddr(cfg *config) string {
+	return fmt.Sprintf("%s:%d", cfg.client.ServerAddr(cfg.host), i.sqlPort)
+}
+
 func (i *cockroachdbInstance) httpAddr() string {
 	return fmt.Sprintf("%s:%d", cliCfg.host, i.httpPort)
 }
@@ -340,6 +370,7 @@ type benchmark struct {
 	pingArgs    []string
 	metricTypes []string
 	timeout     time.Duration
+	secure      bool // Whether nodes and clients talk over TLS.
 }
 
 const (
@@ -390,6 +421,40 @@ func kvBenchmark(readPercent int, nodeCount int) benchmark {
 	}
 }
 
+// secureBenchmark returns a variant of b that runs a cluster secured with
+// TLS certificates, in place of --insecure, so that nodes talk to each other
+// and the workload talks to nodes over TLS.
+func secureBenchmark(b benchmark) benchmark {
+	b.name += "/secure=true"
+	b.reportName += "/secure=true"
+	b.secure = true
+	return b
+}
+
+// zipfianBenchmark returns a variant of the kv benchmark b whose keys follow
+// the workload's Zipf distribution, in place of a uniform one, so that a
+// few keys take most of the reads and writes.
+func zipfianBenchmark(b benchmark) benchmark {
+	b.reportName += "/dist=zipf"
+	b.args = append(b.args[:len(b.args):len(b.args)], "--zipfian")
+	return b
+}
+
+// supportedKeyDist returns an error if the workload can't access keys with
+// the distribution d. It only has a uniform distribution and, with
+// --zipfian, a Zipf distribution whose skew is built in, which stands for
+// the default one.
+func supportedKeyDist(d keydist.Distribution) error {
+	zipf, _ := keydist.Parse(string(keydist.Zipf))
+	switch {
+	case d.Kind == keydist.Uniform, d == zipf:
+		return nil
+	case d.Kind == keydist.Zipf:
+		return fmt.Errorf("the workload's Zipf distribution has a fixed skew, so it can't be set")
+	}
+	return fmt.Errorf("unsupported distribution %s, want uniform or zipf", d.Kind)
+}
+
 var benchmarks = []benchmark{
 	kvBenchmark(0 /* readPercent */, 1 /* nodeCount */),
 	kvBenchmark(0 /* readPercent */, 3 /* nodeCount */),
@@ -397,13 +462,15 @@ var benchmarks = []benchmark{
 	kvBenchmark(50 /* readPercent */, 3 /* nodeCount */),
 	kvBenchmark(95 /* readPercent */, 1 /* nodeCount */),
 	kvBenchmark(95 /* readPercent */, 3 /* nodeCount */),
+	secureBenchmark(kvBenchmark(0 /* readPercent */, 3 /* nodeCount */)),
+	secureBenchmark(kvBenchmark(95 /* readPercent */, 3 /* nodeCount */)),
 }
 
 func runBenchmark(b *driver.B, cfg *config, instances []*cockroachdbInstance) (err error) {
-	var pgurls []string
+	var pgurls, clientURLs []string
 	for _, inst := range instances {
-		host := inst.sqlAddr()
-		pgurls = append(pgurls, fmt.Sprintf(`postgres://root@%s?sslmode=disable`, host))
+		pgurls = append(pgurls, cfg.pgURL(inst.sqlAddr()))
+		clientURLs = append(clientURLs, cfg.pgURL(inst.clientAddr(cfg)))
 	}
 	// Load in the schema needed for the workload via `workload init`
 	log.Println("loading the schema")
@@ -422,13 +489,13 @@ func runBenchmark(b *driver.B, cfg *config, instances []*cockroachdbInstance) (e
 	log.Println("pinging server with benchmark tool")
 	pingArgs := cfg.bench.args
 	pingArgs = append(pingArgs, cfg.bench.pingArgs...)
-	pingArgs = append(pingArgs, pgurls...)
+	pingArgs = append(pingArgs, clientURLs...)
 	var pingOutput []byte
 	var pingErr error
 	pingStart := time.Now()
 	for time.Since(pingStart) < 30*time.Second {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		pingCmd := exec.CommandContext(ctx, cfg.cockroachdbBin, pingArgs...)
+		pingCmd := cfg.client.CommandContext(ctx, nil, cfg.cockroachdbBin, pingArgs...)
 		pingOutput, pingErr = pingCmd.CombinedOutput()
 		cancel()
 		if pingErr == nil {
@@ -445,15 +512,18 @@ func runBenchmark(b *driver.B, cfg *config, instances []*cockroachdbInstance) (e
 	} else {
 		args = append(args, cfg.bench.longArgs...)
 	}
-	args = append(args, pgurls...)
+	args = append(args, clientURLs...)
 
 	log.Println("running benchmark tool")
-	cmd := exec.Command(cfg.cockroachdbBin, args...)
+	var env []string
+	if cfg.clientProcs > 0 {
+		env = append(env, fmt.Sprintf("GOMAXPROCS=%d", cfg.clientProcs))
+	}
+	cmd := cfg.client.Command(env, cfg.cockroachdbBin, args...)
 	fmt.Fprintln(os.Stderr, cmd.String())
 
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	cmd.Env = append(os.Environ(), fmt.Sprintf("GOMAXPROCS=%d", cfg.procsPerInst))
 
 	defer func() {
 		if err != nil && stderr.Len() != 0 {
@@ -466,7 +536,7 @@ func runBenchmark(b *driver.B, cfg *config, instances []*cockroachdbInstance) (e
 	var benchmarkErr error
 	go func() {
 		b.ResetTimer()
-		if err := cmd.Run(); err != nil {
+		if err := driver.RunChild(cmd); err != nil {
 			benchmarkErr = err
 		}
 		b.StopTimer()
@@ -537,14 +607,40 @@ func getAndReportMetrics(b *driver.B, metricType string, output string) error {
 	return nil
 }
 
+// metricsColumns are the columns of the totals that the workload prints
+// for each type of operation, e.g.
+//
+//	_elapsed___errors_____ops(total)___ops/sec(cum)__avg(ms)__p50(ms)__p95(ms)__p99(ms)_pMax(ms)__total
+//	   60.0s        0         123456         2057.6      3.9      3.5      8.4     13.6    104.9  read
+var metricsColumns = []string{"elapsed", "errors", "ops(total)", "ops/sec(cum)", "avg(ms)", "p50(ms)", "p95(ms)", "p99(ms)", "pMax(ms)", "total"}
+
 func getMetrics(metricType string, output string) (benchmarkMetrics, error) {
 	re := regexp.MustCompile(fmt.Sprintf(`.*(__total)\n.*%s`, metricType))
-	match := re.FindString(output)
-	if len(match) == 0 {
+	matches := re.FindAllString(output, -1)
+	if len(matches) == 0 {
 		return benchmarkMetrics{}, fmt.Errorf("failed to find %s metrics in output", metricType)
 	}
-	match = strings.Split(match, "\n")[1]
+	if len(matches) > 1 {
+		if err := driver.Anomalyf("found %d sets of %s metrics, using the first", len(matches), metricType); err != nil {
+			return benchmarkMetrics{}, err
+		}
+	}
+	header, match, _ := strings.Cut(matches[0], "\n")
+	columns := strings.FieldsFunc(header, func(r rune) bool { return r == '_' })
+	if !slices.Equal(columns, metricsColumns) {
+		if err := driver.Anomalyf("%s metrics have columns %q, want %q", metricType, columns, metricsColumns); err != nil {
+			return benchmarkMetrics{}, err
+		}
+	}
 	fields := strings.Fields(match)
+	if len(fields) < len(metricsColumns) {
+		return benchmarkMetrics{}, fmt.Errorf("%s metrics have %d fields, want %d", metricType, len(fields), len(metricsColumns))
+	}
+	if len(fields) > len(metricsColumns) {
+		if err := driver.Anomalyf("%s metrics have %d fields, want %d", metricType, len(fields), len(metricsColumns)); err != nil {
+			return benchmarkMetrics{}, err
+		}
+	}
 
 	stringToFloat64 := func(field string) (float64, error) {
 		number, err := strconv.ParseFloat(field, 64)
@@ -554,13 +650,29 @@ func getMetrics(metricType string, output string) (benchmarkMetrics, error) {
 		return number, nil
 	}
 
-	float64Fields := make([]float64, len(fields[2:])-1)
+	// Errors make the rest of the metrics suspect, but the workload
+	// carries on after them.
+	if errs, err := stringToFloat64(fields[1]); err != nil {
+		return benchmarkMetrics{}, err
+	} else if errs != 0 {
+		if err := driver.Anomalyf("workload reported %v errors for %s operations", errs, metricType); err != nil {
+			return benchmarkMetrics{}, err
+		}
+	}
+	float64Fields := make([]float64, len(metricsColumns)-3)
 	for i := range float64Fields {
 		var err error
 		float64Fields[i], err = stringToFloat64(fields[2+i])
 		if err != nil {
 			return benchmarkMetrics{}, err
 		}
+		// Only the totals must be positive: latencies of fast
+		// operations can round down to 0ms.
+		if float64Fields[i] == 0 && i < 2 {
+			if err := driver.Anomalyf("%s metric %s is zero", metricType, metricsColumns[2+i]); err != nil {
+				return benchmarkMetrics{}, err
+			}
+		}
 	}
 	// Parse benchmark duration.
 	dur, err := time.ParseDuration(fields[0])
@@ -581,17 +693,29 @@ func getMetrics(metricType string, output string) (benchmarkMetrics, error) {
 	return metrics, nil
 }
 
+// reportMetrics reports metrics as the sub-result of b for metricType,
+// e.g. CockroachDBkv95/nodes=3/op=read.
 func reportMetrics(b *driver.B, metricType string, metrics benchmarkMetrics) {
-	b.Report(fmt.Sprintf("%s-ops/sec", metricType), uint64(metrics.opsPerSecond))
-	b.Report(fmt.Sprintf("%s-ops", metricType), uint64(metrics.totalOps))
-	b.Report(fmt.Sprintf("%s-avg-latency-ns", metricType), uint64(metrics.averageLatency))
-	b.Report(fmt.Sprintf("%s-p50-latency-ns", metricType), uint64(metrics.p50Latency))
-	b.Report(fmt.Sprintf("%s-p95-latency-ns", metricType), uint64(metrics.p95Latency))
-	b.Report(fmt.Sprintf("%s-p99-latency-ns", metricType), uint64(metrics.p99Latency))
-	b.Report(fmt.Sprintf("%s-p100-latency-ns", metricType), uint64(metrics.p100Latency))
+	s := b.SubResult("op=" + metricType)
+	s.Ops(int(metrics.totalOps))
+	s.Report("ops/sec", uint64(metrics.opsPerSecond))
+	s.Report("avg-latency-ns", uint64(metrics.averageLatency))
+	s.Report("p50-latency-ns", uint64(metrics.p50Latency))
+	s.Report("p95-latency-ns", uint64(metrics.p95Latency))
+	s.Report("p99-latency-ns", uint64(metrics.p99Latency))
+	s.Report("p100-latency-ns", uint64(metrics.p100Latency))
 }
 
 func run(cfg *config) (err error) {
+	defer cfg.ports.Release()
+
+	if cfg.bench.secure {
+		log.Println("creating certificates")
+		if err := createCerts(cfg); err != nil {
+			return fmt.Errorf("creating certificates: %v", err)
+		}
+	}
+
 	log.Println("launching cluster")
 	var instances []*cockroachdbInstance
 	// Launch the server.
@@ -655,23 +779,32 @@ func run(cfg *config) (err error) {
 		driver.DoDefaultAvgRSS(),
 		driver.DoCoreDump(true),
 		driver.BenchmarkPID(instances[0].cmd.Process.Pid),
+		driver.WithEnv(instances[0].cmd.Env),
 		driver.DoPerf(true),
+		// Keep naming results for the GOMAXPROCS of each node, as
+		// before the split could be set, and record the split itself.
+		driver.WithGOMAXPROCS(cfg.serverProcs),
+		driver.WithConfigLine("server-procs", strconv.Itoa(cfg.serverProcs)),
+		driver.WithConfigLine("client-procs", procsString(cfg.clientProcs)),
 	}
 	return driver.RunBenchmark(cfg.bench.reportName, func(d *driver.B) error {
 		// Set up diagnostics.
 		var stopAll par.Funcs
 		diag := driver.NewDiagnostics(cfg.bench.reportName)
+		var endpoints []server.Endpoint
+		for _, inst := range instances {
+			ep, err := cfg.httpEndpoint(inst)
+			if err != nil {
+				return err
+			}
+			endpoints = append(endpoints, ep)
+		}
 		for _, typ := range diagnostics.Types() {
 			if typ.HTTPEndpoint() == "" {
 				continue
 			}
-			for i, inst := range instances {
-				name := ""
-				if !typ.CanMerge() {
-					// Create a separate file for each instance.
-					name = fmt.Sprintf("inst%d", i)
-				}
-				stop := server.FetchDiagnostic(inst.httpAddr(), diag, typ, name)
+			for i, ep := range endpoints {
+				stop := server.FetchDiagnosticFrom(ep, diag, typ, driver.InstanceName(typ, i))
 				stopAll.Add(stop)
 			}
 		}
@@ -684,6 +817,38 @@ func run(cfg *config) (err error) {
 	}, opts...)
 }
 
+// splitProcs returns the GOMAXPROCS of each of nodes cockroachdb nodes and
+// of the workload on a machine with procs CPUs, filling in the defaults
+// for those that are 0. By default, as before the split could be set, each
+// node and the workload get an equal share of the CPUs. If the workload
+// runs on another machine, it keeps its default there, unless set, and the
+// nodes split all the CPUs. If only the workload's share is set, the nodes
+// split the rest.
+func splitProcs(procs, nodes int, remote bool, server, client int) (int, int) {
+	if server == 0 {
+		avail, shares := procs, nodes
+		if !remote && client != 0 {
+			avail -= client
+		} else if !remote {
+			shares++
+		}
+		server = max(avail/shares, 1)
+	}
+	if client == 0 && !remote {
+		client = max(procs/(nodes+1), 1)
+	}
+	return server, client
+}
+
+// procsString formats a GOMAXPROCS value for a configuration line, where
+// 0 means it was left to the default.
+func procsString(procs int) string {
+	if procs == 0 {
+		return "default"
+	}
+	return strconv.Itoa(procs)
+}
+
 func main() {
 	flag.Parse()
 	if flag.NArg() != 0 {
@@ -701,15 +866,42 @@ func main() {
 		os.Exit(1)
 	}
 
-	// We're going to launch a bunch of cockroachdb instances. Distribute
-	// GOMAXPROCS between those and ourselves equally.
-	procs := runtime.GOMAXPROCS(-1)
-	procsPerInst := procs / (cliCfg.bench.nodeCount + 1)
-	if procsPerInst == 0 {
-		procsPerInst = 1
+	dist, err := keydist.Parse(cliCfg.keyDist)
+	if err == nil {
+		err = supportedKeyDist(dist)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -key-dist: %v\n", err)
+		os.Exit(1)
+	}
+	if dist.Kind == keydist.Zipf {
+		if cliCfg.bench.workload != "kv" {
+			fmt.Fprintf(os.Stderr, "error: -key-dist is only supported by kv benchmarks\n")
+			os.Exit(1)
+		}
+		b := zipfianBenchmark(*cliCfg.bench)
+		cliCfg.bench = &b
+	}
+
+	if cliCfg.client.Remote() && cliCfg.client.ServerHost == "" {
+		fmt.Fprintf(os.Stderr, "error: -client-host requires -server-host\n")
+		os.Exit(1)
+	}
+	if cliCfg.client.Remote() && cliCfg.bench.secure {
+		// The workload would need the client certificates on the other machine.
+		fmt.Fprintf(os.Stderr, "error: secure benchmarks don't support -client-host\n")
+		os.Exit(1)
+	}
+
+	if cliCfg.serverProcs < 0 || cliCfg.clientProcs < 0 {
+		fmt.Fprintf(os.Stderr, "error: -server-procs and -client-procs must not be negative\n")
+		os.Exit(1)
+	}
+	cliCfg.serverProcs, cliCfg.clientProcs = splitProcs(runtime.GOMAXPROCS(-1), cliCfg.bench.nodeCount, cliCfg.client.Remote(), cliCfg.serverProcs, cliCfg.clientProcs)
+	if cliCfg.clientProcs > 0 {
+		// We only drive the workload, so keep to its share.
+		runtime.GOMAXPROCS(cliCfg.clientProcs)
 	}
-	runtime.GOMAXPROCS(procsPerInst)
-	cliCfg.procsPerInst = procsPerInst
 
 	if err := run(&cliCfg); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
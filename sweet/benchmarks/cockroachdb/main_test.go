@@ -0,0 +1,172 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !wasm
+
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+// kv95Output is the end of the output of `cockroach workload run kv
+// --read-percent=95`: the last progress lines, then the totals for each
+// type of operation and for all of them.
+const kv95Output = `  59.0s        0         2011.9         2057.3      3.4      8.4     13.6     46.1 read
+  59.0s        0          104.0          108.5     10.0     25.2     41.9     60.8 write
+  60.0s        0         2065.1         2057.4      3.4      8.1     13.1     37.7 read
+  60.0s        0          109.0          108.5     10.5     24.1     39.8     58.7 write
+
+_elapsed___errors_____ops(total)___ops/sec(cum)__avg(ms)__p50(ms)__p95(ms)__p99(ms)_pMax(ms)__total
+   60.0s        0         123456         2057.6      3.9      3.5      8.4     13.6    104.9  read
+
+_elapsed___errors_____ops(total)___ops/sec(cum)__avg(ms)__p50(ms)__p95(ms)__p99(ms)_pMax(ms)__total
+   60.0s        0           6510          108.5     12.1     10.0     25.2     41.9    142.6  write
+
+_elapsed___errors_____ops(total)___ops/sec(cum)__avg(ms)__p50(ms)__p95(ms)__p99(ms)_pMax(ms)__result
+   60.0s        0         129966         2166.1      4.3      3.7      9.4     16.3    142.6
+`
+
+const totalsHeader = "_elapsed___errors_____ops(total)___ops/sec(cum)__avg(ms)__p50(ms)__p95(ms)__p99(ms)_pMax(ms)__total\n"
+
+func setStrict(t *testing.T) {
+	t.Helper()
+	if err := flag.CommandLine.Set("strict", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.CommandLine.Set("strict", "false") })
+}
+
+func TestGetMetrics(t *testing.T) {
+	setStrict(t)
+	for _, tc := range []struct {
+		name, metricType, output string
+		want                     benchmarkMetrics
+		wantErr                  bool
+	}{
+		{
+			name:       "read",
+			metricType: "read",
+			output:     kv95Output,
+			want: benchmarkMetrics{
+				totalSec:       60,
+				totalOps:       123456,
+				opsPerSecond:   2057.6,
+				averageLatency: 3.9e6,
+				p50Latency:     3.5e6,
+				p95Latency:     8.4e6,
+				p99Latency:     13.6e6,
+				p100Latency:    104.9e6,
+			},
+		},
+		{
+			name:       "write",
+			metricType: "write",
+			output:     kv95Output,
+			want: benchmarkMetrics{
+				totalSec:       60,
+				totalOps:       6510,
+				opsPerSecond:   108.5,
+				averageLatency: 12.1e6,
+				p50Latency:     10e6,
+				p95Latency:     25.2e6,
+				p99Latency:     41.9e6,
+				p100Latency:    142.6e6,
+			},
+		},
+		{
+			// Operations faster than the output's resolution.
+			name:       "zero latencies",
+			metricType: "read",
+			output:     totalsHeader + "   60.0s        0         123456         2057.6      0.0      0.0      0.4      1.6      4.9  read\n",
+			want: benchmarkMetrics{
+				totalSec:       60,
+				totalOps:       123456,
+				opsPerSecond:   2057.6,
+				averageLatency: 0,
+				p50Latency:     0,
+				p95Latency:     0.4e6,
+				p99Latency:     1.6e6,
+				p100Latency:    4.9e6,
+			},
+		},
+		{
+			name:       "missing",
+			metricType: "scan",
+			output:     kv95Output,
+			wantErr:    true,
+		},
+		{
+			name:       "zero ops",
+			metricType: "read",
+			output:     totalsHeader + "   60.0s        0              0            0.0      3.9      3.5      8.4     13.6    104.9  read\n",
+			wantErr:    true,
+		},
+		{
+			name:       "errors",
+			metricType: "read",
+			output:     totalsHeader + "   60.0s       12         123456         2057.6      3.9      3.5      8.4     13.6    104.9  read\n",
+			wantErr:    true,
+		},
+		{
+			name:       "extra column",
+			metricType: "read",
+			output:     "_elapsed___errors_____ops(total)___ops/sec(cum)__avg(ms)__p50(ms)__p95(ms)__p99(ms)__p999(ms)_pMax(ms)__total\n   60.0s        0         123456         2057.6      3.9      3.5      8.4     13.6     50.1    104.9  read\n",
+			wantErr:    true,
+		},
+		{
+			name:       "repeated",
+			metricType: "read",
+			output:     kv95Output + kv95Output,
+			wantErr:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := getMetrics(tc.metricType, tc.output)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("getMetrics succeeded with %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("getMetrics = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitProcs(t *testing.T) {
+	for _, tc := range []struct {
+		procs, nodes   int
+		remote         bool
+		server, client int
+		wantServer     int
+		wantClient     int
+	}{
+		// By default, the nodes and the workload share the CPUs equally.
+		{procs: 16, nodes: 1, wantServer: 8, wantClient: 8},
+		{procs: 16, nodes: 3, wantServer: 4, wantClient: 4},
+		{procs: 2, nodes: 3, wantServer: 1, wantClient: 1},
+		// A remote workload keeps its own default.
+		{procs: 16, nodes: 3, remote: true, wantServer: 5, wantClient: 0},
+		{procs: 16, nodes: 3, remote: true, client: 2, wantServer: 5, wantClient: 2},
+		// The nodes split what the workload leaves them.
+		{procs: 16, nodes: 1, client: 4, wantServer: 12, wantClient: 4},
+		{procs: 16, nodes: 3, client: 4, wantServer: 4, wantClient: 4},
+		{procs: 4, nodes: 3, client: 4, wantServer: 1, wantClient: 4},
+		// The workload's default doesn't depend on the nodes'.
+		{procs: 16, nodes: 1, server: 12, wantServer: 12, wantClient: 8},
+		{procs: 16, nodes: 1, server: 12, client: 2, wantServer: 12, wantClient: 2},
+	} {
+		server, client := splitProcs(tc.procs, tc.nodes, tc.remote, tc.server, tc.client)
+		if server != tc.wantServer || client != tc.wantClient {
+			t.Errorf("splitProcs(%d, %d, %v, %d, %d) = %d, %d; want %d, %d", tc.procs, tc.nodes, tc.remote, tc.server, tc.client, server, client, tc.wantServer, tc.wantClient)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command encoding benchmarks the hot paths of encoding/gob, streaming large
+// object graphs as an RPC or persistence layer does, and of encoding/binary,
+// packing fixed-size and variable-length records.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+)
+
+type config struct {
+	messages int
+	records  int
+	short    bool
+}
+
+var cliCfg config
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.IntVar(&cliCfg.messages, "messages", 100000, "number of object graphs to encode and decode with encoding/gob")
+	flag.IntVar(&cliCfg.records, "records", 20000000, "number of records to encode and decode with encoding/binary")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// An Order is the object graph gob encodes: nested structs, pointers,
+// slices, maps and a type with its own encoding.
+type Order struct {
+	ID       uint64
+	Customer *Customer
+	Items    []Item
+	Attrs    map[string]string
+	Created  time.Time
+	Notes    []string
+}
+
+type Customer struct {
+	Name    string
+	Email   string
+	Address Address
+}
+
+type Address struct {
+	Street, City, Country string
+	Postcode              string
+}
+
+type Item struct {
+	SKU       string
+	Quantity  int32
+	Price     float64
+	Discounts []float64
+}
+
+// A Record is a packed record, as in a log or time series store, that
+// encoding/binary encodes. It has no padding, so that its encoded size is
+// its size in memory.
+type Record struct {
+	Timestamp int64
+	ID        uint64
+	Value     float64
+	Count     uint32
+	Flags     uint16
+	Kind      uint16
+}
+
+// poolSize is the number of distinct orders and record batches; the
+// benchmarks cycle through them, so that they measure encoding rather
+// than the generation of data.
+const poolSize = 1024
+
+// recordBatch is the number of records encoded together.
+const recordBatch = 1024
+
+var words = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliett", "kilo", "lima"}
+
+func word(rng *rand.Rand) string {
+	return words[rng.Intn(len(words))]
+}
+
+func newOrders(rng *rand.Rand) []*Order {
+	orders := make([]*Order, poolSize)
+	for i := range orders {
+		o := &Order{
+			ID: rng.Uint64(),
+			Customer: &Customer{
+				Name:  word(rng) + " " + word(rng),
+				Email: word(rng) + "@example.com",
+				Address: Address{
+					Street:   fmt.Sprintf("%d %s Street", rng.Intn(1000), word(rng)),
+					City:     word(rng),
+					Country:  word(rng),
+					Postcode: fmt.Sprintf("%05d", rng.Intn(100000)),
+				},
+			},
+			Attrs:   make(map[string]string),
+			Created: time.Unix(1700000000+rng.Int63n(1e7), 0).UTC(),
+		}
+		for j := 1 + rng.Intn(40); j > 0; j-- {
+			it := Item{SKU: fmt.Sprintf("%s-%06d", word(rng), rng.Intn(1e6)), Quantity: int32(1 + rng.Intn(10)), Price: rng.Float64() * 100}
+			for k := rng.Intn(3); k > 0; k-- {
+				it.Discounts = append(it.Discounts, rng.Float64())
+			}
+			o.Items = append(o.Items, it)
+		}
+		for j := rng.Intn(8); j > 0; j-- {
+			o.Attrs[word(rng)] = word(rng)
+		}
+		for j := rng.Intn(4); j > 0; j-- {
+			o.Notes = append(o.Notes, word(rng)+" "+word(rng)+" "+word(rng))
+		}
+		orders[i] = o
+	}
+	return orders
+}
+
+func newRecords(rng *rand.Rand) [][]Record {
+	batches := make([][]Record, poolSize)
+	for i := range batches {
+		batch := make([]Record, recordBatch)
+		t := rng.Int63n(1e18)
+		for j := range batch {
+			// Timestamps and counts are small and increasing, as is
+			// typical, so that they pack well as varints.
+			t += rng.Int63n(1e6)
+			batch[j] = Record{Timestamp: t, ID: uint64(rng.Intn(1e4)), Value: rng.NormFloat64(), Count: uint32(rng.Intn(1000)), Flags: uint16(rng.Intn(16)), Kind: uint16(rng.Intn(4))}
+		}
+		batches[i] = batch
+	}
+	return batches
+}
+
+// countingWriter counts the bytes written to it and discards them.
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += int64(len(b))
+	return len(b), nil
+}
+
+var sink uint64
+
+// measure runs op ops times, passing it the number of each, as the
+// benchmark d, and reports the allocations per op. The allocations are
+// counted around the loop alone, so that those of the driver's timer
+// don't count.
+func measure(d *driver.B, ops int, op func(i int) error) error {
+	runtime.GC()
+	var before, after runtime.MemStats
+	d.ResetTimer()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < ops; i++ {
+		if err := op(i); err != nil {
+			return err
+		}
+	}
+	runtime.ReadMemStats(&after)
+	d.StopTimer()
+	d.Ops(ops)
+	d.Report("B/op", (after.TotalAlloc-before.TotalAlloc)/uint64(ops))
+	d.Report("allocs/op", (after.Mallocs-before.Mallocs)/uint64(ops))
+	return nil
+}
+
+// reportThroughput reports the rate at which d encoded or decoded bytes
+// bytes.
+func reportThroughput(d *driver.B, bytes int64) {
+	d.ReportFloat("MB/s", float64(bytes)/1e6/d.Elapsed().Seconds())
+}
+
+// gobEncode encodes n orders onto one stream, as an RPC connection does,
+// so that the types are sent once and the values many times.
+func gobEncode(d *driver.B, orders []*Order, n int) error {
+	w := new(countingWriter)
+	enc := gob.NewEncoder(w)
+	if err := measure(d, n, func(i int) error {
+		return enc.Encode(orders[i%len(orders)])
+	}); err != nil {
+		return err
+	}
+	reportThroughput(d, w.n)
+	return nil
+}
+
+// gobDecode decodes n orders from streams of the encoded orders, each read
+// by a new decoder, as a reconnecting client or a reader of a file would.
+func gobDecode(d *driver.B, orders []*Order, n int) error {
+	var stream bytes.Buffer
+	enc := gob.NewEncoder(&stream)
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			return err
+		}
+	}
+	var r *bytes.Reader
+	var dec *gob.Decoder
+	var total int64
+	if err := measure(d, n, func(i int) error {
+		if i%len(orders) == 0 {
+			r = bytes.NewReader(stream.Bytes())
+			dec = gob.NewDecoder(r)
+			total += int64(stream.Len())
+		}
+		var o Order
+		if err := dec.Decode(&o); err != nil {
+			return err
+		}
+		sink += o.ID + uint64(len(o.Items))
+		return nil
+	}); err != nil {
+		return err
+	}
+	// Count only the bytes actually decoded from the last stream.
+	reportThroughput(d, total-int64(r.Len()))
+	return nil
+}
+
+// binaryEncode packs n records, a batch at a time, with binary.Write.
+func binaryEncode(d *driver.B, batches [][]Record, n int) error {
+	var buf bytes.Buffer
+	var total int64
+	if err := measure(d, n/recordBatch, func(i int) error {
+		buf.Reset()
+		if err := binary.Write(&buf, binary.LittleEndian, batches[i%len(batches)]); err != nil {
+			return err
+		}
+		total += int64(buf.Len())
+		return nil
+	}); err != nil {
+		return err
+	}
+	reportThroughput(d, total)
+	return nil
+}
+
+// binaryDecode unpacks n records, a batch at a time, with binary.Read.
+func binaryDecode(d *driver.B, batches [][]Record, n int) error {
+	encoded := make([][]byte, len(batches))
+	for i, batch := range batches {
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, binary.LittleEndian, batch); err != nil {
+			return err
+		}
+		encoded[i] = buf.Bytes()
+	}
+	dst := make([]Record, recordBatch)
+	var total int64
+	if err := measure(d, n/recordBatch, func(i int) error {
+		b := encoded[i%len(encoded)]
+		if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, dst); err != nil {
+			return err
+		}
+		sink += dst[0].ID
+		total += int64(len(b))
+		return nil
+	}); err != nil {
+		return err
+	}
+	reportThroughput(d, total)
+	return nil
+}
+
+// appendVarintBatch appends batch to b with the timestamps delta-encoded,
+// the integers as varints and the values in fixed point.
+func appendVarintBatch(b []byte, batch []Record) []byte {
+	var last int64
+	for _, r := range batch {
+		b = binary.AppendVarint(b, r.Timestamp-last)
+		last = r.Timestamp
+		b = binary.AppendUvarint(b, r.ID)
+		b = binary.LittleEndian.AppendUint64(b, uint64(int64(r.Value*1e6)))
+		b = binary.AppendUvarint(b, uint64(r.Count))
+		b = binary.AppendUvarint(b, uint64(r.Flags))
+		b = binary.AppendUvarint(b, uint64(r.Kind))
+	}
+	return b
+}
+
+// readVarintBatch reads the records appendVarintBatch wrote to b into dst.
+func readVarintBatch(dst []Record, b []byte) error {
+	r := varintReader{b: b}
+	var last int64
+	for i := range dst {
+		last += r.varint()
+		dst[i].Timestamp = last
+		dst[i].ID = r.uvarint()
+		dst[i].Value = float64(int64(r.uint64())) / 1e6
+		dst[i].Count = uint32(r.uvarint())
+		dst[i].Flags = uint16(r.uvarint())
+		dst[i].Kind = uint16(r.uvarint())
+	}
+	return r.err
+}
+
+// A varintReader reads values from b until it runs out, after which it
+// returns zeros and sets err.
+type varintReader struct {
+	b   []byte
+	err error
+}
+
+func (r *varintReader) fail() uint64 {
+	r.b, r.err = nil, io.ErrUnexpectedEOF
+	return 0
+}
+
+func (r *varintReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return r.fail()
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *varintReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		return int64(r.fail())
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *varintReader) uint64() uint64 {
+	if len(r.b) < 8 {
+		return r.fail()
+	}
+	v := binary.LittleEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+// varintEncode packs n records, a batch at a time, as varints.
+func varintEncode(d *driver.B, batches [][]Record, n int) error {
+	var buf []byte
+	var total int64
+	if err := measure(d, n/recordBatch, func(i int) error {
+		buf = appendVarintBatch(buf[:0], batches[i%len(batches)])
+		total += int64(len(buf))
+		return nil
+	}); err != nil {
+		return err
+	}
+	reportThroughput(d, total)
+	return nil
+}
+
+// varintDecode unpacks n records, a batch at a time, from varints.
+func varintDecode(d *driver.B, batches [][]Record, n int) error {
+	encoded := make([][]byte, len(batches))
+	for i, batch := range batches {
+		encoded[i] = appendVarintBatch(nil, batch)
+	}
+	dst := make([]Record, recordBatch)
+	var total int64
+	if err := measure(d, n/recordBatch, func(i int) error {
+		b := encoded[i%len(encoded)]
+		if err := readVarintBatch(dst, b); err != nil {
+			return err
+		}
+		sink += dst[0].ID
+		total += int64(len(b))
+		return nil
+	}); err != nil {
+		return err
+	}
+	reportThroughput(d, total)
+	return nil
+}
+
+func run(cfg *config) error {
+	rng := rand.New(rand.NewSource(1))
+	orders := newOrders(rng)
+	batches := newRecords(rng)
+
+	messages, records := cfg.messages, cfg.records
+	if cfg.short {
+		messages, records = 1000, 100*recordBatch
+	}
+	for _, bench := range []struct {
+		name string
+		run  func(d *driver.B) error
+	}{
+		{"EncodingGob/op=encode", func(d *driver.B) error { return gobEncode(d, orders, messages) }},
+		{"EncodingGob/op=decode", func(d *driver.B) error { return gobDecode(d, orders, messages) }},
+		{"EncodingBinary/format=fixed/op=encode", func(d *driver.B) error { return binaryEncode(d, batches, records) }},
+		{"EncodingBinary/format=fixed/op=decode", func(d *driver.B) error { return binaryDecode(d, batches, records) }},
+		{"EncodingBinary/format=varint/op=encode", func(d *driver.B) error { return varintEncode(d, batches, records) }},
+		{"EncodingBinary/format=varint/op=decode", func(d *driver.B) error { return varintDecode(d, batches, records) }},
+	} {
+		if err := driver.RunBenchmark(bench.name, bench.run, driver.InProcessMeasurementOptions...); err != nil {
+			return fmt.Errorf("%s: %v", bench.name, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	if cliCfg.messages <= 0 || cliCfg.records < recordBatch {
+		fmt.Fprintf(os.Stderr, "error: -messages must be positive and -records at least %d\n", recordBatch)
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
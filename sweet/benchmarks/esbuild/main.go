@@ -151,10 +151,12 @@ func run(name, bin, src, tmp string) error {
 	baseCmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
 	baseCmd.Stdout = os.Stderr // Redirect all tool output to stderr.
 	baseCmd.Stderr = os.Stderr
-	cmd, err := cgroups.WrapCommand(baseCmd, "test.scope")
+	cpus := driver.CPULimit()
+	cmd, err := cgroups.WrapCommandCPUs(baseCmd, "test.scope", cpus)
 	if err != nil {
 		return err
 	}
+	opts := []driver.RunOption{driver.DoTime(true), driver.DoAvgRSS(cmd.RSSFunc()), driver.WithEnv(cmd.Env), driver.WithCPULimit()}
 	return driver.RunBenchmark(name, func(d *driver.B) error {
 		defer diag.Commit(d)
 		defer func() {
@@ -164,5 +166,5 @@ func run(name, bin, src, tmp string) error {
 		}()
 		defer d.StopTimer()
 		return cmd.Run()
-	}, []driver.RunOption{driver.DoTime(true), driver.DoAvgRSS(cmd.RSSFunc())}...)
+	}, opts...)
 }
@@ -32,7 +32,6 @@ const (
 	// just running a single instance because of intracluster
 	// traffic.
 	etcdInstances = 3
-	basePort      = 2379
 )
 
 type config struct {
@@ -45,6 +44,8 @@ type config struct {
 	procsPerInst int
 	gomaxprocs   int
 	bench        *benchmark
+	client       server.ClientConfig
+	ports        server.PortAllocator
 }
 
 var cliCfg config
@@ -57,6 +58,8 @@ func init() {
 	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to temporary directory")
 	flag.StringVar(&cliCfg.benchName, "bench", "", "name of the benchmark to run")
 	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+	cliCfg.client.SetFlags(flag.CommandLine)
+	cliCfg.ports.SetFlags(flag.CommandLine)
 
 	// We're going to launch a bunch of etcd instances. Distribute
 	// GOMAXPROCS between those and ourselves equally.
@@ -95,20 +98,24 @@ func clusterString(instances []*etcdInstance, typ portType) string {
 }
 
 func launchEtcdCluster(cfg *config) ([]*etcdInstance, error) {
+	ports, err := cfg.ports.Reserve(2 * etcdInstances)
+	if err != nil {
+		return nil, err
+	}
 	var instances []*etcdInstance
 	for i := 0; i < etcdInstances; i++ {
 		instances = append(instances, &etcdInstance{
 			name:       fmt.Sprintf("infra%d", i+1),
-			clientPort: basePort + 2*i,
-			peerPort:   basePort + 2*i + 1,
+			clientPort: ports[2*i],
+			peerPort:   ports[2*i+1],
 		})
 	}
 	initCluster := clusterString(instances, peerPort)
 	for _, inst := range instances {
 		inst.cmd = exec.Command(cfg.etcdBin,
 			"--name", inst.name,
-			"--listen-client-urls", "http://"+inst.host(clientPort),
-			"--advertise-client-urls", "http://"+inst.host(clientPort),
+			"--listen-client-urls", fmt.Sprintf("http://%s:%d", cfg.client.ListenHost("127.0.0.1"), inst.clientPort),
+			"--advertise-client-urls", "http://"+inst.clientAddr(cfg),
 			"--listen-peer-urls", "http://"+inst.host(peerPort),
 			"--initial-advertise-peer-urls", "http://"+inst.host(peerPort),
 			"--initial-cluster-token", "etcd-cluster-1",
@@ -124,7 +131,7 @@ func launchEtcdCluster(cfg *config) ([]*etcdInstance, error) {
 		)
 		inst.cmd.Stdout = &inst.output
 		inst.cmd.Stderr = &inst.output
-		if err := inst.cmd.Start(); err != nil {
+		if err := driver.StartChild(inst.cmd); err != nil {
 			return nil, fmt.Errorf("failed to start instance %q: %v", inst.name, err)
 		}
 	}
@@ -164,6 +171,11 @@ func (i *etcdInstance) host(typ portType) string {
 	return fmt.Sprintf("127.0.0.1:%d", port)
 }
 
+// clientAddr returns the address at which the benchmarking tool reaches i.
+func (i *etcdInstance) clientAddr(cfg *config) string {
+	return fmt.Sprintf("%s:%d", cfg.client.ServerAddr("127.0.0.1"), i.clientPort)
+}
+
 func (i *etcdInstance) shutdown() error {
 	if err := i.cmd.Process.Signal(os.Interrupt); err != nil {
 		return err
@@ -227,7 +239,7 @@ var benchmarks = []benchmark{
 func runBenchmark(b *driver.B, cfg *config, instances []*etcdInstance) (err error) {
 	var hosts []string
 	for _, inst := range instances {
-		hosts = append(hosts, inst.host(clientPort))
+		hosts = append(hosts, inst.clientAddr(cfg))
 	}
 	args := append([]string{"--endpoints", strings.Join(hosts, ",")}, cfg.bench.args...)
 	if cfg.short {
@@ -235,12 +247,15 @@ func runBenchmark(b *driver.B, cfg *config, instances []*etcdInstance) (err erro
 	} else {
 		args = append(args, cfg.bench.longArgs...)
 	}
-	cmd := exec.Command(cfg.benchmarkBin, args...)
+	var env []string
+	if !cfg.client.Remote() {
+		env = append(env, fmt.Sprintf("GOMAXPROCS=%d", cfg.procsPerInst))
+	}
+	cmd := cfg.client.Command(env, cfg.benchmarkBin, args...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	cmd.Env = append(os.Environ(), fmt.Sprintf("GOMAXPROCS=%d", cfg.procsPerInst))
 
 	defer func() {
 		if err != nil && stderr.Len() != 0 {
@@ -249,11 +264,16 @@ func runBenchmark(b *driver.B, cfg *config, instances []*etcdInstance) (err erro
 		}
 	}()
 
+	before := scrapeMetrics(instances)
 	b.ResetTimer()
-	if err := cmd.Run(); err != nil {
+	if err := driver.RunChild(cmd); err != nil {
 		return err
 	}
 	b.StopTimer()
+	if !cfg.client.Remote() {
+		b.ReportClientCPU(cmd.ProcessState)
+	}
+	reportServerMetrics(b, instances, before, scrapeMetrics(instances))
 
 	return reportFromBenchmarkOutput(b, stdout.String())
 }
@@ -266,6 +286,14 @@ func reportFromBenchmarkOutput(b *driver.B, output string) (err error) {
 		}
 	}()
 
+	// The benchmark tool reports errors after its summary, but carries on
+	// after them, so they make its numbers suspect.
+	if strings.Contains(output, "Error distribution:") {
+		if err := driver.Anomalyf("benchmark tool reported errors"); err != nil {
+			return err
+		}
+	}
+
 	p50, err := getQuantileLatency("50", output)
 	if err != nil {
 		return err
@@ -306,25 +334,43 @@ func reportFromBenchmarkOutput(b *driver.B, output string) (err error) {
 
 func getQuantileLatency(quantile, output string) (float64, error) {
 	re := regexp.MustCompile(fmt.Sprintf(`%s%%\s*in\s*(?P<value>\d+\.\d+(e(\+|-)\d+)?)`, regexp.QuoteMeta(quantile)))
-	vi := re.SubexpIndex("value")
-	matches := re.FindStringSubmatch(output)
-	if len(matches) <= vi {
-		return 0, fmt.Errorf("failed to find quantile latency pattern in output")
-	}
-	return strconv.ParseFloat(matches[vi], 64)
+	return findValue(re, quantile+"% quantile latency", output)
 }
 
 func getSummaryField(field, output string) (float64, error) {
 	re := regexp.MustCompile(fmt.Sprintf(`%s:\s*(?P<value>\d+\.\d+(e(\+|-)\d+)?)`, regexp.QuoteMeta(field)))
+	return findValue(re, "summary field "+field, output)
+}
+
+// findValue returns the value that re finds in output, which should be
+// there once, and not be zero; anything else is an anomaly (see
+// driver.Anomalyf).
+func findValue(re *regexp.Regexp, what, output string) (float64, error) {
 	vi := re.SubexpIndex("value")
-	matches := re.FindStringSubmatch(output)
-	if len(matches) <= vi {
-		return 0, fmt.Errorf("failed to find summary field pattern in output")
+	matches := re.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("failed to find %s pattern in output", what)
+	}
+	if len(matches) > 1 {
+		if err := driver.Anomalyf("found %s %d times, using the first", what, len(matches)); err != nil {
+			return 0, err
+		}
+	}
+	v, err := strconv.ParseFloat(matches[0][vi], 64)
+	if err != nil {
+		return 0, err
+	}
+	if v == 0 {
+		if err := driver.Anomalyf("%s is zero", what); err != nil {
+			return 0, err
+		}
 	}
-	return strconv.ParseFloat(matches[vi], 64)
+	return v, nil
 }
 
 func run(cfg *config) (err error) {
+	defer cfg.ports.Release()
+
 	// Launch the server.
 	instances, err := launchEtcdCluster(cfg)
 	if err != nil {
@@ -348,7 +394,6 @@ func run(cfg *config) (err error) {
 		}
 	}()
 
-	// TODO(mknyszek): Consider collecting summed memory metrics for all instances.
 	// TODO(mknyszek): Consider running all instances under perf.
 	opts := []driver.RunOption{
 		driver.DoPeakRSS(true),
@@ -356,6 +401,7 @@ func run(cfg *config) (err error) {
 		driver.DoDefaultAvgRSS(),
 		driver.DoCoreDump(true),
 		driver.BenchmarkPID(instances[0].cmd.Process.Pid),
+		driver.WithEnv(instances[0].cmd.Env),
 		driver.DoPerf(true),
 		driver.WithGOMAXPROCS(cfg.gomaxprocs),
 	}
@@ -368,12 +414,7 @@ func run(cfg *config) (err error) {
 				continue
 			}
 			for i, inst := range instances {
-				name := ""
-				if !typ.CanMerge() {
-					// Create a separate file for each instance.
-					name = fmt.Sprintf("inst%d", i)
-				}
-				stop := server.FetchDiagnostic(inst.host(clientPort), diag, typ, name)
+				stop := server.FetchDiagnostic(inst.host(clientPort), diag, typ, driver.InstanceName(typ, i))
 				stopAll.Add(stop)
 			}
 		}
@@ -391,6 +432,18 @@ func main() {
 		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
 		os.Exit(1)
 	}
+	if cliCfg.client.Remote() {
+		if cliCfg.client.ServerHost == "" {
+			fmt.Fprintf(os.Stderr, "error: -client-host requires -server-host\n")
+			os.Exit(1)
+		}
+		// The benchmarking tool runs elsewhere, so the instances can
+		// have its share of the machine.
+		cliCfg.procsPerInst = cliCfg.gomaxprocs / etcdInstances
+		if cliCfg.procsPerInst == 0 {
+			cliCfg.procsPerInst = 1
+		}
+	}
 	for i := range benchmarks {
 		if benchmarks[i].name == cliCfg.benchName {
 			cliCfg.bench = &benchmarks[i]
@@ -0,0 +1,80 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !wasm && !plan9
+
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+// putOutput is the summary etcd's benchmark tool prints after `benchmark
+// put`.
+const putOutput = `
+Summary:
+  Total:	10.0180 secs.
+  Slowest:	0.0519 secs.
+  Fastest:	0.0003 secs.
+  Average:	0.0049 secs.
+  Stddev:	0.0031 secs.
+  Requests/sec:	19962.1187
+
+Response time histogram:
+  0.0003 [1]	|
+  0.0055 [136871]	|∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎∎
+  0.0519 [12]	|
+
+Latency distribution:
+  10% in 0.0023 secs.
+  25% in 0.0031 secs.
+  50% in 0.0042 secs.
+  75% in 0.0058 secs.
+  90% in 0.0080 secs.
+  95% in 0.0097 secs.
+  99% in 0.0152 secs.
+  99.9% in 0.0304 secs.
+`
+
+func TestFindValue(t *testing.T) {
+	if err := flag.CommandLine.Set("strict", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer flag.CommandLine.Set("strict", "false")
+
+	for _, tc := range []struct {
+		name    string
+		find    func(output string) (float64, error)
+		output  string
+		want    float64
+		wantErr bool
+	}{
+		{"total", func(o string) (float64, error) { return getSummaryField("Total", o) }, putOutput, 10.018, false},
+		{"requests/sec", func(o string) (float64, error) { return getSummaryField("Requests/sec", o) }, putOutput, 19962.1187, false},
+		{"p50", func(o string) (float64, error) { return getQuantileLatency("50", o) }, putOutput, 0.0042, false},
+		{"p99", func(o string) (float64, error) { return getQuantileLatency("99", o) }, putOutput, 0.0152, false},
+		{"p99.9", func(o string) (float64, error) { return getQuantileLatency("99.9", o) }, putOutput, 0.0304, false},
+		{"exponent", func(o string) (float64, error) { return getQuantileLatency("50", o) }, "  50% in 4.2e-03 secs.\n", 0.0042, false},
+		{"missing", func(o string) (float64, error) { return getQuantileLatency("75", o) }, "  50% in 0.0042 secs.\n", 0, true},
+		{"zero", func(o string) (float64, error) { return getSummaryField("Requests/sec", o) }, "  Requests/sec:\t0.0000\n", 0, true},
+		{"repeated", func(o string) (float64, error) { return getSummaryField("Total", o) }, putOutput + putOutput, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.find(tc.output)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !wasm && !plan9
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/server"
+)
+
+// scrapeMetrics returns the Prometheus metrics of each instance, or nil
+// for any instance whose metrics could not be read.
+func scrapeMetrics(instances []*etcdInstance) []map[string]float64 {
+	metrics := make([]map[string]float64, len(instances))
+	for i, inst := range instances {
+		m, err := server.ScrapeMetrics(inst.host(clientPort))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read metrics of %s: %v\n", inst.name, err)
+			continue
+		}
+		metrics[i] = m
+	}
+	return metrics
+}
+
+// serverMetrics are the Prometheus metrics of each instance that are
+// reported, under the given names without the instance prefix, after
+// scaling them. Counters are reported as their change over the benchmark
+// run, and gauges, which can shrink, as their value at its end.
+var serverMetrics = []struct {
+	prom    string
+	report  string
+	scale   float64
+	counter bool
+}{
+	{"go_gc_duration_seconds_sum", "gc-pause-ns", 1e9, true},
+	{"go_gc_duration_seconds_count", "gc-cycles", 1, true},
+	{"go_memstats_alloc_bytes_total", "alloc-bytes", 1, true},
+	{"go_memstats_mallocs_total", "mallocs", 1, true},
+	{"go_memstats_heap_inuse_bytes", "heap-inuse-bytes", 1, false},
+	{"go_memstats_sys_bytes", "runtime-sys-bytes", 1, false},
+	{"process_resident_memory_bytes", "rss-bytes", 1, false},
+}
+
+// reportServerMetrics reports serverMetrics for each instance, given its
+// metrics before and after the benchmark run, so that client-side
+// regressions can be tied to what the servers' runtimes were doing.
+func reportServerMetrics(b *driver.B, instances []*etcdInstance, before, after []map[string]float64) {
+	for i, inst := range instances {
+		if before[i] == nil || after[i] == nil {
+			continue
+		}
+		for _, m := range serverMetrics {
+			v, ok := after[i][m.prom]
+			if !ok {
+				continue
+			}
+			if m.counter {
+				v0, ok := before[i][m.prom]
+				if !ok {
+					continue
+				}
+				if v < v0 {
+					fmt.Fprintf(os.Stderr, "counter %s of %s went backwards\n", m.prom, inst.name)
+					continue
+				}
+				v -= v0
+			}
+			b.Report(fmt.Sprintf("%s-%s", inst.name, m.report), uint64(v*m.scale))
+		}
+	}
+}
@@ -0,0 +1,19 @@
+module golang.org/x/benchmarks/sweet/benchmarks/fasthttp
+
+go 1.23.0
+
+require (
+	github.com/valyala/fasthttp v1.62.0
+	golang.org/x/benchmarks v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/google/pprof v0.0.0-20241017200806-017d972448fc // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+)
+
+// The benchmark driver lives in the parent module.
+replace golang.org/x/benchmarks => ../../..
@@ -0,0 +1,291 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/pool"
+
+	"github.com/valyala/fasthttp"
+)
+
+type config struct {
+	servers  []string
+	handlers []string
+	clients  int
+	requests int
+	short    bool
+}
+
+var (
+	cliCfg       config
+	serversFlag  string
+	handlersFlag string
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&serversFlag, "servers", "nethttp,fasthttp", "comma-separated list of servers to benchmark (nethttp, fasthttp)")
+	flag.StringVar(&handlersFlag, "handlers", "plaintext,json", "comma-separated list of handlers to benchmark (plaintext, json)")
+	flag.IntVar(&cliCfg.clients, "clients", 0, "number of concurrent client connections (default 4*GOMAXPROCS)")
+	flag.IntVar(&cliCfg.requests, "requests", 2000000, "number of requests to send to each server for each handler")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// The handlers below compute their responses with the same code for both
+// servers, so that any difference in the results comes from the servers
+// themselves: request parsing, connection handling, and response writing.
+
+var helloWorld = []byte("Hello, World!")
+
+type item struct {
+	ID      int      `json:"id"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+}
+
+// jsonResponse returns the response body for the json handler given the
+// value of the request's "id" query parameter.
+func jsonResponse(id string) ([]byte, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&item{
+		ID:      n,
+		Message: "Hello, World!",
+		Tags:    []string{"sweet", "benchmark", strconv.Itoa(n % 16)},
+	})
+}
+
+func netHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plaintext", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(helloWorld)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		body, err := jsonResponse(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+	return mux
+}
+
+func fastHTTPHandler(ctx *fasthttp.RequestCtx) {
+	switch string(ctx.Path()) {
+	case "/plaintext":
+		ctx.SetContentType("text/plain")
+		ctx.Write(helloWorld)
+	case "/json":
+		body, err := jsonResponse(string(ctx.QueryArgs().Peek("id")))
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		ctx.SetContentType("application/json")
+		ctx.Write(body)
+	default:
+		ctx.NotFound()
+	}
+}
+
+// startServer starts the named server on a loopback port and returns its
+// address along with a function that shuts it down.
+func startServer(name string) (string, func(), error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	switch name {
+	case "nethttp":
+		srv := &http.Server{Handler: netHTTPHandler()}
+		go srv.Serve(lis)
+		return lis.Addr().String(), func() { srv.Close() }, nil
+	case "fasthttp":
+		srv := &fasthttp.Server{Handler: fastHTTPHandler}
+		go srv.Serve(lis)
+		return lis.Addr().String(), func() { srv.Shutdown() }, nil
+	}
+	lis.Close()
+	return "", nil, fmt.Errorf("unknown server %q", name)
+}
+
+// worker issues requests over a single keep-alive connection. The same
+// client is used against both servers so that the load is identical.
+type worker struct {
+	client    *fasthttp.HostClient
+	handler   string
+	id        int
+	iterCount *int64 // Accessed atomically.
+	lat       []time.Duration
+}
+
+func (w *worker) Run(_ context.Context) error {
+	if atomic.AddInt64(w.iterCount, -1) < 0 {
+		return pool.Done
+	}
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	uri := "http://" + w.client.Addr + "/" + w.handler
+	if w.handler == "json" {
+		w.id++
+		uri += "?id=" + strconv.Itoa(w.id)
+	}
+	req.SetRequestURI(uri)
+
+	start := time.Now()
+	if err := w.client.Do(req, resp); err != nil {
+		return err
+	}
+	w.lat = append(w.lat, time.Since(start))
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return fmt.Errorf("unexpected status %d for %s: %s", resp.StatusCode(), uri, resp.Body())
+	}
+	return nil
+}
+
+func (w *worker) Close() error {
+	return nil
+}
+
+type durSlice []time.Duration
+
+func (d durSlice) Len() int           { return len(d) }
+func (d durSlice) Less(i, j int) bool { return d[i] < d[j] }
+func (d durSlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func runBenchmark(d *driver.B, addr string, cfg *config, handler string, iters int) error {
+	client := &fasthttp.HostClient{
+		Addr:     addr,
+		MaxConns: cfg.clients,
+	}
+	defer client.CloseIdleConnections()
+
+	iterCount := int64(iters) // Shared atomic variable.
+	workers := make([]pool.Worker, 0, cfg.clients)
+	for i := 0; i < cfg.clients; i++ {
+		workers = append(workers, &worker{
+			client:    client,
+			handler:   handler,
+			id:        i * iters,
+			iterCount: &iterCount,
+			lat:       make([]time.Duration, 0, iters/cfg.clients+1),
+		})
+	}
+	p := pool.New(context.Background(), workers)
+
+	d.ResetTimer()
+	if err := p.Run(); err != nil {
+		return err
+	}
+	d.StopTimer()
+
+	latencies := make([]time.Duration, 0, iters)
+	for _, w := range workers {
+		latencies = append(latencies, w.(*worker).lat...)
+	}
+	sort.Sort(durSlice(latencies))
+
+	d.Report("p50-latency-ns", uint64(latencies[len(latencies)*50/100]))
+	d.Report("p90-latency-ns", uint64(latencies[len(latencies)*90/100]))
+	d.Report("p99-latency-ns", uint64(latencies[len(latencies)*99/100]))
+
+	lengthS := float64(d.Elapsed()) / float64(time.Second)
+	d.Report("ops/s", uint64(float64(len(latencies))/lengthS))
+
+	d.Ops(len(latencies))
+	d.Report(driver.StatTime, uint64((int(d.Elapsed())*cfg.clients)/len(latencies)))
+	return nil
+}
+
+func run(cfg *config) error {
+	iters := cfg.requests
+	if cfg.short {
+		iters = 1000
+	}
+	if iters < cfg.clients {
+		iters = cfg.clients
+	}
+	for _, server := range cfg.servers {
+		addr, stop, err := startServer(server)
+		if err != nil {
+			return err
+		}
+		for _, h := range cfg.handlers {
+			name := fmt.Sprintf("HTTPServer/server=%s/handler=%s", server, h)
+			err := driver.RunBenchmark(name, func(d *driver.B) error {
+				return runBenchmark(d, addr, cfg, h, iters)
+			}, driver.InProcessMeasurementOptions...)
+			if err != nil {
+				stop()
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		stop()
+	}
+	return nil
+}
+
+func splitList(s string, valid ...string) ([]string, error) {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		ok := false
+		for _, v := range valid {
+			ok = ok || e == v
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown value %q, want one of %s", e, strings.Join(valid, ", "))
+		}
+		l = append(l, e)
+	}
+	return l, nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	var err error
+	if cliCfg.servers, err = splitList(serversFlag, "nethttp", "fasthttp"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: -servers: %v\n", err)
+		os.Exit(1)
+	}
+	if cliCfg.handlers, err = splitList(handlersFlag, "plaintext", "json"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: -handlers: %v\n", err)
+		os.Exit(1)
+	}
+	if cliCfg.clients <= 0 {
+		cliCfg.clients = 4 * runtime.GOMAXPROCS(-1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
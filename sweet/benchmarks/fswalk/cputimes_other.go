@@ -0,0 +1,15 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package main
+
+import "time"
+
+// cpuTimes returns zero times: the CPU time breakdown isn't available
+// here, so it isn't reported.
+func cpuTimes() (user, sys time.Duration) {
+	return 0, 0
+}
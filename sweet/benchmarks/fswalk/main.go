@@ -0,0 +1,263 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+)
+
+type config struct {
+	files   int
+	fanout  int
+	dupes   float64
+	tmpDir  string
+	short   bool
+	workers int
+}
+
+var cliCfg config
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.IntVar(&cliCfg.files, "files", 20000, "number of files in the generated tree")
+	flag.IntVar(&cliCfg.fanout, "fanout", 16, "number of subdirectories of each directory in the generated tree")
+	flag.Float64Var(&cliCfg.dupes, "dupes", 0.1, "fraction of files that duplicate the contents of another file")
+	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to a temporary working directory")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+	flag.IntVar(&cliCfg.workers, "workers", 0, "number of goroutines hashing files (0 = 2*GOMAXPROCS)")
+}
+
+// filesPerDir is the number of files in each directory of the generated
+// tree before it gets another level of subdirectories.
+const filesPerDir = 64
+
+// fileSize returns a random file size distributed roughly like the sizes of
+// the files in a source tree: mostly a few KiB, with a long tail of larger
+// files up to 256 KiB.
+func fileSize(rng *rand.Rand) int {
+	switch r := rng.Float64(); {
+	case r < 0.6:
+		return 256 + rng.Intn(4<<10)
+	case r < 0.95:
+		return 4<<10 + rng.Intn(28<<10)
+	default:
+		return 32<<10 + rng.Intn(224<<10)
+	}
+}
+
+// recentContents is the number of recently generated file contents that
+// duplicate files may copy.
+const recentContents = 64
+
+// tree describes a generated directory tree.
+type tree struct {
+	root   string
+	files  int
+	bytes  int64
+	unique int // Number of distinct file contents.
+}
+
+// generate deterministically writes a tree of cfg.files files to dir,
+// spreading them over nested directories of at most filesPerDir files
+// each, as a checkout or build cache would be.
+func generate(cfg *config, dir string) (*tree, error) {
+	t := &tree{root: dir}
+	rng := rand.New(rand.NewSource(1))
+	var recent [][]byte
+	for i := 0; i < cfg.files; i++ {
+		var data []byte
+		if len(recent) > 0 && rng.Float64() < cfg.dupes {
+			data = recent[rng.Intn(len(recent))]
+		} else {
+			data = make([]byte, fileSize(rng))
+			rng.Read(data)
+			if len(recent) < recentContents {
+				recent = append(recent, data)
+			} else {
+				recent[t.unique%recentContents] = data
+			}
+			t.unique++
+		}
+
+		// Place the file in the directory given by the digits of its
+		// directory number in base fanout.
+		path := dir
+		for d := i / filesPerDir; d > 0; d /= cfg.fanout {
+			path = filepath.Join(path, fmt.Sprintf("d%02d", d%cfg.fanout))
+		}
+		if err := os.MkdirAll(path, 0777); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(path, fmt.Sprintf("f%06d.dat", i))
+		if err := os.WriteFile(name, data, 0666); err != nil {
+			return nil, err
+		}
+		t.files++
+		t.bytes += int64(len(data))
+	}
+	return t, nil
+}
+
+// digest is a SHA-256 digest of a file's contents.
+type digest [sha256.Size]byte
+
+// store is an index of a content-addressable store: the path of one file
+// with each distinct content.
+type store struct {
+	mu      sync.Mutex
+	objects map[digest]string
+	files   int
+	bytes   int64
+}
+
+func (s *store) add(d digest, path string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[d]; !ok {
+		s.objects[d] = path
+	}
+	s.files++
+	s.bytes += size
+}
+
+// hashFile stats, reads and hashes the file at path, using buf for reading.
+func hashFile(path string, buf *bufio.Reader) (digest, int64, error) {
+	var d digest
+	fi, err := os.Stat(path)
+	if err != nil {
+		return d, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return d, 0, err
+	}
+	defer f.Close()
+	buf.Reset(f)
+	h := sha256.New()
+	n, err := io.Copy(h, buf)
+	if err != nil {
+		return d, 0, err
+	}
+	if n != fi.Size() {
+		return d, 0, fmt.Errorf("%s: read %d bytes, but it has %d", path, n, fi.Size())
+	}
+	h.Sum(d[:0])
+	return d, n, nil
+}
+
+// index walks t and hashes all its regular files with workers goroutines,
+// returning the resulting store.
+func index(t *tree, workers int) (*store, error) {
+	s := &store{objects: make(map[digest]string)}
+	paths := make(chan string, 4*workers)
+	errc := make(chan error, workers+1)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := bufio.NewReaderSize(nil, 32<<10)
+			for path := range paths {
+				d, n, err := hashFile(path, buf)
+				if err != nil {
+					errc <- err
+					// Keep draining paths so the walk can finish.
+					for range paths {
+					}
+					return
+				}
+				s.add(d, path, n)
+			}
+		}()
+	}
+	errc <- filepath.WalkDir(t.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths <- path
+		}
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func runBenchmark(d *driver.B, cfg *config, t *tree) error {
+	workers := cfg.workers
+	if workers <= 0 {
+		workers = 2 * runtime.GOMAXPROCS(0)
+	}
+
+	d.ResetTimer()
+	user0, sys0 := cpuTimes()
+	s, err := index(t, workers)
+	if err != nil {
+		return err
+	}
+	user1, sys1 := cpuTimes()
+	d.StopTimer()
+
+	if s.files != t.files || s.bytes != t.bytes || len(s.objects) != t.unique {
+		return fmt.Errorf("indexed %d files, %d bytes, %d objects; want %d files, %d bytes, %d objects",
+			s.files, s.bytes, len(s.objects), t.files, t.bytes, t.unique)
+	}
+	elapsed := d.Elapsed()
+	d.Report("files/s", uint64(float64(s.files)/elapsed.Seconds()))
+	d.ReportFloat("MB/s", float64(s.bytes)/1e6/elapsed.Seconds())
+	d.Report("user-cpu-ns/op", uint64(user1-user0))
+	d.Report("sys-cpu-ns/op", uint64(sys1-sys0))
+	return nil
+}
+
+func run(cfg *config) error {
+	if cfg.tmpDir == "" {
+		return fmt.Errorf("-tmp is required")
+	}
+	if cfg.short {
+		cfg.files = 500
+	}
+	// The tree is generated once and read from the page cache thereafter,
+	// so the benchmark measures the CPU cost of walking, system calls and
+	// hashing rather than the speed of the disk.
+	t, err := generate(cfg, filepath.Join(cfg.tmpDir, "tree"))
+	if err != nil {
+		return fmt.Errorf("generating tree: %v", err)
+	}
+	return driver.RunBenchmark("FSWalkHash", func(d *driver.B) error {
+		return runBenchmark(d, cfg, t)
+	}, driver.InProcessMeasurementOptions...)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package collections
+
+import (
+	"cmp"
+	"slices"
+)
+
+// MapSlice returns the result of applying f to each element of s.
+func MapSlice[T, U any](s []T, f func(T) U) []U {
+	r := make([]U, len(s))
+	for i, v := range s {
+		r[i] = f(v)
+	}
+	return r
+}
+
+// Filter returns the elements of s for which keep returns true.
+func Filter[T any](s []T, keep func(T) bool) []T {
+	var r []T
+	for _, v := range s {
+		if keep(v) {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// Reduce combines the elements of s, in order, into an accumulator
+// starting at init.
+func Reduce[T, A any](s []T, init A, f func(A, T) A) A {
+	acc := init
+	for _, v := range s {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+// GroupBy groups the elements of s by the key that key returns for them.
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	groups := make(map[K][]T)
+	for _, v := range s {
+		k := key(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
+
+// SortedKeys returns the keys of m in order.
+func SortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// TopN returns the n greatest elements of s by cmp, greatest first, using
+// a heap.
+func TopN[T any](s []T, n int, cmp func(a, b T) int) []T {
+	h := NewHeap(cmp) // A min-heap of the greatest elements so far.
+	for _, v := range s {
+		if h.Len() < n {
+			h.Push(v)
+		} else if cmp(v, h.Peek()) > 0 {
+			h.Pop()
+			h.Push(v)
+		}
+	}
+	r := make([]T, h.Len())
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i] = h.Pop()
+	}
+	return r
+}
+
+// Heap is a binary min-heap ordered by a comparison function.
+type Heap[T any] struct {
+	cmp   func(a, b T) int
+	elems []T
+}
+
+// NewHeap returns an empty heap ordered by cmp.
+func NewHeap[T any](cmp func(a, b T) int) *Heap[T] {
+	return &Heap[T]{cmp: cmp}
+}
+
+// Len returns the number of elements in h.
+func (h *Heap[T]) Len() int {
+	return len(h.elems)
+}
+
+// Peek returns the least element of h, which must not be empty.
+func (h *Heap[T]) Peek() T {
+	return h.elems[0]
+}
+
+// Push adds v to h.
+func (h *Heap[T]) Push(v T) {
+	h.elems = append(h.elems, v)
+	for i := len(h.elems) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if h.cmp(h.elems[i], h.elems[parent]) >= 0 {
+			break
+		}
+		h.elems[i], h.elems[parent] = h.elems[parent], h.elems[i]
+		i = parent
+	}
+}
+
+// Pop removes and returns the least element of h, which must not be empty.
+func (h *Heap[T]) Pop() T {
+	top := h.elems[0]
+	last := len(h.elems) - 1
+	h.elems[0] = h.elems[last]
+	var zero T
+	h.elems[last] = zero
+	h.elems = h.elems[:last]
+	for i := 0; ; {
+		least := i
+		for _, c := range []int{2*i + 1, 2*i + 2} {
+			if c < len(h.elems) && h.cmp(h.elems[c], h.elems[least]) < 0 {
+				least = c
+			}
+		}
+		if least == i {
+			break
+		}
+		h.elems[i], h.elems[least] = h.elems[least], h.elems[i]
+		i = least
+	}
+	return top
+}
+
+// Set is a set of comparable values.
+type Set[T comparable] map[T]struct{}
+
+// Add adds v to s.
+func (s Set[T]) Add(v T) {
+	s[v] = struct{}{}
+}
+
+// Has reports whether v is in s.
+func (s Set[T]) Has(v T) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// Intersect returns the values in both s and t.
+func Intersect[T comparable](s, t Set[T]) Set[T] {
+	if len(s) > len(t) {
+		s, t = t, s
+	}
+	r := make(Set[T])
+	for v := range s {
+		if t.Has(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package collections is a set of generic collections and algorithms, in
+// the style of those found in real programs, for benchmarking the cost of
+// generic code. It is compiled by the benchmark, too, since instantiating
+// it is part of that cost.
+package collections
+
+import "cmp"
+
+// degree is the minimum number of children of an interior B-tree node.
+const degree = 16
+
+// Map is a sorted map implemented as a B-tree, ordered by a comparison
+// function.
+type Map[K, V any] struct {
+	cmp  func(a, b K) int
+	root *node[K, V]
+	len  int
+}
+
+type node[K, V any] struct {
+	keys     []K
+	vals     []V
+	children []*node[K, V] // nil for leaves
+}
+
+// NewMap returns an empty map ordered by cmp.
+func NewMap[K, V any](cmp func(a, b K) int) *Map[K, V] {
+	return &Map[K, V]{cmp: cmp}
+}
+
+// NewOrderedMap returns an empty map ordered by the natural order of K.
+func NewOrderedMap[K cmp.Ordered, V any]() *Map[K, V] {
+	return NewMap[K, V](cmp.Compare[K])
+}
+
+// Len returns the number of entries in m.
+func (m *Map[K, V]) Len() int {
+	return m.len
+}
+
+// search returns the index of the first key in n not less than key, and
+// whether it is equal to key.
+func (m *Map[K, V]) search(n *node[K, V], key K) (int, bool) {
+	lo, hi := 0, len(n.keys)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if m.cmp(n.keys[mid], key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.keys) && m.cmp(n.keys[lo], key) == 0
+}
+
+// Get returns the value for key, and whether it is present.
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	for n := m.root; n != nil; {
+		i, found := m.search(n, key)
+		if found {
+			return n.vals[i], true
+		}
+		if n.children == nil {
+			break
+		}
+		n = n.children[i]
+	}
+	var zero V
+	return zero, false
+}
+
+// Set sets the value for key.
+func (m *Map[K, V]) Set(key K, val V) {
+	if m.root == nil {
+		m.root = &node[K, V]{}
+	}
+	if len(m.root.keys) == 2*degree-1 {
+		old := m.root
+		m.root = &node[K, V]{children: []*node[K, V]{old}}
+		m.split(m.root, 0)
+	}
+	n := m.root
+	for {
+		i, found := m.search(n, key)
+		if found {
+			n.vals[i] = val
+			return
+		}
+		if n.children == nil {
+			n.keys = insertAt(n.keys, i, key)
+			n.vals = insertAt(n.vals, i, val)
+			m.len++
+			return
+		}
+		if len(n.children[i].keys) == 2*degree-1 {
+			m.split(n, i)
+			switch c := m.cmp(key, n.keys[i]); {
+			case c == 0:
+				n.vals[i] = val
+				return
+			case c > 0:
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+// split splits the full child i of n in two, moving its middle entry up
+// into n.
+func (m *Map[K, V]) split(n *node[K, V], i int) {
+	child := n.children[i]
+	right := &node[K, V]{
+		keys: append([]K(nil), child.keys[degree:]...),
+		vals: append([]V(nil), child.vals[degree:]...),
+	}
+	if child.children != nil {
+		right.children = append([]*node[K, V](nil), child.children[degree:]...)
+		child.children = child.children[:degree]
+	}
+	n.keys = insertAt(n.keys, i, child.keys[degree-1])
+	n.vals = insertAt(n.vals, i, child.vals[degree-1])
+	n.children = insertAt(n.children, i+1, right)
+	clear(child.keys[degree-1:])
+	clear(child.vals[degree-1:])
+	child.keys = child.keys[:degree-1]
+	child.vals = child.vals[:degree-1]
+}
+
+// Ascend calls f for each entry of m with a key not less than from, in
+// order, until f returns false.
+func (m *Map[K, V]) Ascend(from K, f func(K, V) bool) {
+	if m.root != nil {
+		m.ascend(m.root, from, f)
+	}
+}
+
+func (m *Map[K, V]) ascend(n *node[K, V], from K, f func(K, V) bool) bool {
+	i, _ := m.search(n, from)
+	for ; i < len(n.keys); i++ {
+		if n.children != nil && !m.ascend(n.children[i], from, f) {
+			return false
+		}
+		if !f(n.keys[i], n.vals[i]) {
+			return false
+		}
+	}
+	if n.children != nil {
+		return m.ascend(n.children[i], from, f)
+	}
+	return true
+}
+
+func insertAt[T any](s []T, i int, v T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
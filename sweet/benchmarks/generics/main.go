@@ -0,0 +1,290 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"go/build"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/generics/collections"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+)
+
+// collectionsPkg is the import path of the generic package the benchmark
+// compiles.
+const collectionsPkg = "golang.org/x/benchmarks/sweet/benchmarks/generics/collections"
+
+type config struct {
+	entries int
+	records int
+	goTool  string
+	srcDir  string
+	tmpDir  string
+	short   bool
+}
+
+var cliCfg config
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.IntVar(&cliCfg.entries, "entries", 1<<18, "number of entries in each sorted map")
+	flag.IntVar(&cliCfg.records, "records", 1<<18, "number of records processed by the pipeline")
+	flag.StringVar(&cliCfg.goTool, "go", "", "path to the go command to compile the generic package with (default: don't benchmark compiling it)")
+	flag.StringVar(&cliCfg.srcDir, "src", "", "directory containing the source of the generic package, for -go")
+	flag.StringVar(&cliCfg.tmpDir, "tmp", os.TempDir(), "scratch directory, for -go")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// point is a key type with a struct GC shape, ordered by its own
+// comparison function.
+type point struct {
+	x, y int32
+}
+
+func comparePoints(a, b point) int {
+	if c := cmp.Compare(a.x, b.x); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.y, b.y)
+}
+
+// record is a row of the data the pipeline processes. Pointers to records
+// also serve as map keys, sharing their GC shape with all pointer types,
+// so that generic code on them passes dictionaries around.
+type record struct {
+	id     int
+	name   string
+	region string
+	amount float64
+}
+
+func compareRecords(a, b *record) int {
+	return cmp.Compare(a.id, b.id)
+}
+
+// sortedMapOps exercises m with keys: it sets every key, in random order,
+// looks each of them up, then scans the map in runs of 100 entries,
+// returning the number of operations performed.
+func sortedMapOps[K, V any](m *collections.Map[K, V], keys []K, val func(K) V) int {
+	for _, k := range keys {
+		m.Set(k, val(k))
+	}
+	for _, k := range keys {
+		if _, ok := m.Get(k); !ok {
+			panic("missing key")
+		}
+	}
+	scans := len(keys) / 100
+	for i := 0; i < scans; i++ {
+		n := 0
+		m.Ascend(keys[i], func(K, V) bool {
+			n++
+			return n < 100
+		})
+	}
+	return 2*len(keys) + scans
+}
+
+func runSortedMap(d *driver.B, cfg *config, key string) error {
+	r := rand.New(rand.NewSource(1))
+	perm := r.Perm(cfg.entries)
+
+	// Generate the keys ahead of time, so only the map is measured.
+	var run func() int
+	switch key {
+	case "int":
+		m := collections.NewOrderedMap[int, int]()
+		run = func() int { return sortedMapOps(m, perm, func(k int) int { return k }) }
+	case "string":
+		keys := collections.MapSlice(perm, func(i int) string { return fmt.Sprintf("key-%09d", i) })
+		m := collections.NewOrderedMap[string, int]()
+		run = func() int { return sortedMapOps(m, keys, func(k string) int { return len(k) }) }
+	case "struct":
+		keys := collections.MapSlice(perm, func(i int) point { return point{int32(i % 1024), int32(i / 1024)} })
+		m := collections.NewMap[point, point](comparePoints)
+		run = func() int { return sortedMapOps(m, keys, func(k point) point { return k }) }
+	case "pointer":
+		keys := collections.MapSlice(perm, func(i int) *record { return &record{id: i} })
+		m := collections.NewMap[*record, *record](compareRecords)
+		run = func() int { return sortedMapOps(m, keys, func(k *record) *record { return k }) }
+	default:
+		panic("unknown key type " + key)
+	}
+
+	d.ResetTimer()
+	ops := run()
+	d.StopTimer()
+
+	d.Ops(ops)
+	return nil
+}
+
+var regions = []string{"africa", "americas", "asia", "europe", "oceania"}
+
+// pipeline processes records through a chain of generic algorithms over
+// several instantiations, as a reporting job might, returning something
+// derived from all of their results.
+func pipeline(records []*record) int {
+	large := collections.Filter(records, func(r *record) bool { return r.amount > 500 })
+	amounts := collections.MapSlice(large, func(r *record) float64 { return r.amount })
+	total := collections.Reduce(amounts, 0.0, func(sum, a float64) float64 { return sum + a })
+
+	byRegion := collections.GroupBy(records, func(r *record) string { return r.region })
+	sum := 0
+	for _, region := range collections.SortedKeys(byRegion) {
+		top := collections.TopN(byRegion[region], 10, func(a, b *record) int { return cmp.Compare(a.amount, b.amount) })
+		sum += top[0].id
+	}
+
+	byName := collections.GroupBy(records, func(r *record) byte { return r.name[len(r.name)-1] })
+	even, odd := make(collections.Set[int]), make(collections.Set[int])
+	for _, r := range byName['0'] {
+		even.Add(r.id % 1000)
+	}
+	for _, r := range byName['5'] {
+		odd.Add(r.id % 1000)
+	}
+	names := collections.MapSlice(large, func(r *record) string { return strings.ToUpper(r.name) })
+	return sum + len(collections.Intersect(even, odd)) + len(names) + int(total)
+}
+
+var pipelineSink int
+
+func runPipeline(d *driver.B, cfg *config) error {
+	r := rand.New(rand.NewSource(1))
+	records := make([]*record, cfg.records)
+	for i := range records {
+		records[i] = &record{
+			id:     i,
+			name:   "customer-" + strconv.Itoa(r.Intn(cfg.records)),
+			region: regions[r.Intn(len(regions))],
+			amount: r.Float64() * 1000,
+		}
+	}
+
+	d.ResetTimer()
+	const iterations = 10
+	for i := 0; i < iterations; i++ {
+		pipelineSink += pipeline(records)
+	}
+	d.StopTimer()
+
+	d.Ops(iterations)
+	return nil
+}
+
+// runCompile compiles the generic package from cfg.srcDir with the
+// compiler alone, so that only the package itself is measured, not its
+// dependencies or the go command.
+func runCompile(cfg *config) error {
+	srcDir, err := filepath.Abs(cfg.srcDir)
+	if err != nil {
+		return err
+	}
+	pkg, err := build.ImportDir(srcDir, 0)
+	if err != nil {
+		return err
+	}
+
+	// Build the package's dependencies and write an import configuration
+	// pointing to their export data.
+	list := exec.Command(cfg.goTool, append([]string{"list", "-export", "-deps", "-f", "{{if .Export}}packagefile {{.ImportPath}}={{.Export}}{{end}}"}, pkg.Imports...)...)
+	list.Dir = cfg.tmpDir
+	list.Stderr = os.Stderr
+	importcfg, err := list.Output()
+	if err != nil {
+		return fmt.Errorf("listing dependencies: %v", err)
+	}
+	importcfgFile := filepath.Join(cfg.tmpDir, "importcfg")
+	if err := os.WriteFile(importcfgFile, importcfg, 0644); err != nil {
+		return err
+	}
+
+	// Run the compiler directly, rather than through go tool, so the go
+	// command's own startup isn't measured.
+	toolDir, err := exec.Command(cfg.goTool, "env", "GOTOOLDIR").Output()
+	if err != nil {
+		return fmt.Errorf("finding GOTOOLDIR: %v", err)
+	}
+	compiler := filepath.Join(strings.TrimSpace(string(toolDir)), "compile")
+
+	out := filepath.Join(cfg.tmpDir, "collections.a")
+	args := []string{"-p", collectionsPkg, "-importcfg", importcfgFile, "-pack", "-o", out}
+	for _, f := range pkg.GoFiles {
+		args = append(args, filepath.Join(srcDir, f))
+	}
+	return driver.RunBenchmark("GenericsCompile", func(d *driver.B) error {
+		cmd := exec.Command(compiler, args...)
+		cmd.Dir = cfg.tmpDir
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+		d.StopTimer()
+		st, err := os.Stat(out)
+		if err != nil {
+			return err
+		}
+		d.Report("archive-bytes", uint64(st.Size()))
+		return nil
+	}, driver.DoTime(true))
+}
+
+func run(cfg *config) error {
+	if cfg.short {
+		cfg.entries = 1 << 12
+		cfg.records = 1 << 12
+	}
+	for _, key := range []string{"int", "string", "struct", "pointer"} {
+		name := "GenericsSortedMap/key=" + key
+		err := driver.RunBenchmark(name, func(d *driver.B) error {
+			return runSortedMap(d, cfg, key)
+		}, driver.InProcessMeasurementOptions...)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	err := driver.RunBenchmark("GenericsPipeline", func(d *driver.B) error {
+		return runPipeline(d, cfg)
+	}, driver.InProcessMeasurementOptions...)
+	if err != nil {
+		return fmt.Errorf("GenericsPipeline: %v", err)
+	}
+	if cfg.goTool != "" {
+		if err := runCompile(cfg); err != nil {
+			return fmt.Errorf("GenericsCompile: %v", err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	if cliCfg.entries < 100 || cliCfg.records < 1 {
+		fmt.Fprintf(os.Stderr, "error: -entries must be at least 100 and -records at least 1\n")
+		os.Exit(1)
+	}
+	if cliCfg.goTool != "" && cliCfg.srcDir == "" {
+		fmt.Fprintf(os.Stderr, "error: -go requires -src\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+)
+
+// An action is one compile or link the go command ran during the build,
+// as recorded by the toolexec wrapper.
+type action struct {
+	Name  string        // Import path of the package compiled, or "link".
+	Out   string        // File it writes.
+	Deps  []string      // Archives it reads, from its importcfg.
+	Start time.Time     // When the tool started.
+	End   time.Time     // When it exited.
+	CPU   time.Duration // User and system time the tool took.
+}
+
+func tmpActionsDir() string {
+	return filepath.Join(tmpDir, "actions")
+}
+
+// newAction returns the action for running the compiler or linker bin with
+// args, or nil if the go command is only asking it for its version.
+func newAction(bin string, args []string) (*action, error) {
+	args, err := expandResponseFiles(args)
+	if err != nil {
+		return nil, err
+	}
+	var pkg, out, importcfg string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-p":
+			pkg = args[i+1]
+		case "-o":
+			out = args[i+1]
+		case "-importcfg":
+			importcfg = args[i+1]
+		}
+	}
+	if importcfg == "" || out == "" {
+		return nil, nil
+	}
+	a := &action{Name: pkg, Out: out}
+	if bin == "link" {
+		a.Name = "link"
+	}
+	a.Deps, err = readImportcfg(importcfg)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// expandResponseFiles replaces each @file argument, which the go command
+// uses for long command lines, with the arguments in the file.
+func expandResponseFiles(args []string) ([]string, error) {
+	var out []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			out = append(out, arg)
+			continue
+		}
+		data, err := os.ReadFile(arg[1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			// The go command escapes backslashes and newlines.
+			out = append(out, strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(line))
+		}
+	}
+	return out, nil
+}
+
+// readImportcfg returns the archives an importcfg file names. They are the
+// files the actions that built them wrote, which, unlike import paths, are
+// unique even for main packages.
+func readImportcfg(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var deps []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		rest, ok := strings.CutPrefix(s.Text(), "packagefile ")
+		if !ok {
+			continue
+		}
+		if _, file, ok := strings.Cut(rest, "="); ok {
+			deps = append(deps, file)
+		}
+	}
+	return deps, s.Err()
+}
+
+// record writes a to the actions directory, for the benchmark to read back
+// once the build is done.
+func (a *action) record() error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(tmpActionsDir(), "*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readActions(dir string) ([]*action, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var actions []*action
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		a := new(action)
+		if err := json.Unmarshal(data, a); err != nil {
+			return nil, fmt.Errorf("reading %s: %v", entry.Name(), err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// peakConcurrency returns the largest number of actions that ran at once.
+func peakConcurrency(actions []*action) int {
+	type event struct {
+		t     time.Time
+		delta int
+	}
+	var events []event
+	for _, a := range actions {
+		events = append(events, event{a.Start, 1}, event{a.End, -1})
+	}
+	// At equal times, count ends first, so that an action started as
+	// another finished doesn't count as running alongside it.
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].t.Equal(events[j].t) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].t.Before(events[j].t)
+	})
+	n, peak := 0, 0
+	for _, e := range events {
+		n += e.delta
+		peak = max(peak, n)
+	}
+	return peak
+}
+
+// criticalPath returns the length of the longest chain of actions in the
+// build graph, each depending on the one before: how long the build would
+// take with unlimited CPUs and a perfect scheduler.
+func criticalPath(actions []*action) time.Duration {
+	byOut := make(map[string]*action)
+	for _, a := range actions {
+		byOut[a.Out] = a
+	}
+	memo := make(map[*action]time.Duration)
+	var path func(a *action) time.Duration
+	path = func(a *action) time.Duration {
+		if d, ok := memo[a]; ok {
+			return d
+		}
+		memo[a] = 0 // Break cycles, which a build graph shouldn't have.
+		var longest time.Duration
+		for _, dep := range a.Deps {
+			if d, ok := byOut[dep]; ok {
+				longest = max(longest, path(d))
+			}
+		}
+		memo[a] = longest + a.End.Sub(a.Start)
+		return memo[a]
+	}
+	var longest time.Duration
+	for _, a := range actions {
+		longest = max(longest, path(a))
+	}
+	return longest
+}
+
+// reportActions reports how well the go command kept the CPUs busy during
+// a build that took wall time, from the actions recorded in dir. The
+// metrics change with cmd/go's action scheduling even if the compiler's
+// and linker's speed doesn't.
+func reportActions(d *driver.B, dir string, wall time.Duration) error {
+	actions, err := readActions(dir)
+	if err != nil {
+		return err
+	}
+	if len(actions) == 0 || wall <= 0 {
+		return nil
+	}
+	var cpu time.Duration
+	for _, a := range actions {
+		cpu += a.CPU
+	}
+	d.Report("action-cpu-ns", uint64(cpu))
+	d.Report("critical-path-ns", uint64(criticalPath(actions)))
+	d.Report("peak-actions", uint64(peakConcurrency(actions)))
+	d.Report("parallel-efficiency-%", uint64(100*cpu/wall))
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+const (
+	loadObjectSize = 4 << 10 // Size of each object allocated by the load.
+	loadLiveObjs   = 4096    // Objects each load goroutine keeps live (16 MiB).
+)
+
+// loadSink keeps the load's computation from being optimized away.
+var loadSink byte
+
+// backgroundLoad runs procs goroutines that each allocate objects as fast as
+// they can, touching every page of them and keeping the most recent
+// loadLiveObjs alive, so that the process both competes for CPU and keeps
+// its own garbage collector busy. It returns a function that stops the
+// load and waits for it to finish.
+func backgroundLoad(procs int) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < procs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var live [loadLiveObjs][]byte
+			var sum byte
+			for n := 0; ; n++ {
+				if n%64 == 0 {
+					select {
+					case <-done:
+						loadSink += sum
+						return
+					default:
+					}
+				}
+				b := make([]byte, loadObjectSize)
+				for j := 0; j < len(b); j += 64 {
+					b[j] = byte(n + j)
+					sum += b[j]
+				}
+				live[n%loadLiveObjs] = b
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// runLoaded runs the command given by args to completion under
+// backgroundLoad(procs), in the same process tree, and hence the same
+// cgroup, as the load.
+func runLoaded(procs int, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command to run under load")
+	}
+	stop := backgroundLoad(procs)
+	defer stop()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
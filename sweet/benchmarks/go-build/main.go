@@ -12,7 +12,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/cgroups"
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
@@ -25,6 +27,8 @@ var (
 	tmpDir    string
 	toolexec  bool
 	benchName string
+	loadProcs int
+	loaded    bool
 
 	diag *driver.Diagnostics
 )
@@ -35,6 +39,8 @@ func init() {
 	flag.StringVar(&tmpDir, "tmp", "", "work directory (cleared before use)")
 	flag.BoolVar(&toolexec, "toolexec", false, "run as a toolexec binary")
 	flag.StringVar(&benchName, "bench-name", "", "for -toolexec")
+	flag.IntVar(&loadProcs, "load", 0, "number of goroutines generating background CPU and allocation load during the build")
+	flag.BoolVar(&loaded, "loaded", false, "run the command given as arguments under the background load set by -load")
 	flag.Func("diagnostics", "for -toolexec", func(s string) error {
 		diag = new(driver.Diagnostics)
 		return diag.UnmarshalText([]byte(s))
@@ -45,21 +51,35 @@ func tmpResultsDir() string {
 	return filepath.Join(tmpDir, "results")
 }
 
+// benchOpts are the options of both the build and the link benchmarks,
+// which get -cpu-limit passed along with the other flags.
 var benchOpts = []driver.RunOption{
 	driver.DoTime(true),
+	driver.WithCPULimit(),
 }
 
 func run(pkgPath string) error {
 	// Clear any stale results from previous runs and recreate
-	// the directory.
-	if err := os.RemoveAll(tmpResultsDir()); err != nil {
-		return err
-	}
-	if err := os.MkdirAll(tmpResultsDir(), 0777); err != nil {
-		return err
+	// the directories.
+	for _, dir := range []string{tmpResultsDir(), tmpActionsDir()} {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
 	}
 
 	name := "GoBuild" + strings.Title(filepath.Base(pkgPath))
+	if loadProcs > 0 {
+		name = "GoBuildLoaded" + strings.Title(filepath.Base(pkgPath))
+	}
+
+	cpus := driver.CPULimit()
+	if cpus > 0 && loadProcs > 0 {
+		// The load occupies half of the CPUs the build may use.
+		loadProcs = max(1, cpus/2)
+	}
 
 	cmdArgs := []string{goTool, "build", "-a"}
 
@@ -73,7 +93,7 @@ func run(pkgPath string) error {
 		"-bench-name", name,
 	}
 	flag.CommandLine.Visit(func(f *flag.Flag) {
-		if f.Name == "go" || f.Name == "bench-name" || strings.HasPrefix(f.Name, "perf") {
+		if f.Name == "go" || f.Name == "bench-name" || f.Name == "load" || strings.HasPrefix(f.Name, "perf") {
 			// No need to pass this along.
 			return
 		}
@@ -100,20 +120,36 @@ func run(pkgPath string) error {
 		perfArgs = append(perfArgs, cmdArgs...)
 		cmdArgs = perfArgs
 	}
+	if loadProcs > 0 {
+		// Run the build under load from another instance of this binary,
+		// which is thus wrapped into the same cgroup as the build below.
+		loadArgs := []string{selfPath, "-loaded", "-load", strconv.Itoa(loadProcs), "--"}
+		cmdArgs = append(loadArgs, cmdArgs...)
+	}
 
 	baseCmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
 	baseCmd.Dir = pkgPath
 	baseCmd.Env = common.NewEnvFromEnviron().MustSet("GOROOT=" + filepath.Dir(filepath.Dir(goTool))).Collapse()
 	baseCmd.Stdout = os.Stderr // Redirect all tool output to stderr.
 	baseCmd.Stderr = os.Stderr
-	cmd, err := cgroups.WrapCommand(baseCmd, "test.scope")
+	cmd, err := cgroups.WrapCommandCPUs(baseCmd, "test.scope", cpus)
 	if err != nil {
 		return err
 	}
+	opts := append(benchOpts, driver.WithEnv(cmd.Env))
+	if loadProcs == 0 {
+		// The load shares the build's cgroup, so under load the cgroup's
+		// RSS is mostly the load's.
+		opts = append(opts, driver.DoAvgRSS(cmd.RSSFunc()))
+	}
 	err = driver.RunBenchmark(name, func(d *driver.B) error {
 		defer diag.Commit(d)
-		return cmd.Run()
-	}, append(benchOpts, driver.DoAvgRSS(cmd.RSSFunc()))...)
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+		d.StopTimer()
+		return reportActions(d, tmpActionsDir(), d.Elapsed())
+	}, opts...)
 	if err != nil {
 		return err
 	}
@@ -195,6 +231,23 @@ func runToolexec() error {
 	// in the final output.
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	act, err := newAction(bin, flag.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if act != nil {
+		act.Start = time.Now()
+		defer func() {
+			act.End = time.Now()
+			if cmd.ProcessState == nil {
+				return
+			}
+			act.CPU = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+			if err := act.record(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to record %s action: %v\n", bin, err)
+			}
+		}()
+	}
 	if benchmark {
 		name := benchName + benchSuffix
 		f, err := os.Create(filepath.Join(tmpResultsDir(), name+".results"))
@@ -212,6 +265,13 @@ func runToolexec() error {
 func main() {
 	flag.Parse()
 
+	if loaded {
+		if err := runLoaded(loadProcs, flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if toolexec {
 		if err := runToolexec(); err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -0,0 +1,321 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go-link benchmarks the Go linker on its own, by relinking a large
+// program from a prebuilt set of archives with a few different sets of
+// flags.
+//
+// With -prepare, it builds the program and captures the archives and the
+// linker invocation into a directory, using itself as the -toolexec of the
+// build. Otherwise, it runs the linker on the captured archives.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
+)
+
+var (
+	goTool   string
+	tmpDir   string
+	prepare  string
+	race     bool
+	capture  string
+	toolexec bool
+	archives string
+	name     string
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&goTool, "go", "", "path to cmd/go binary, for -prepare")
+	flag.StringVar(&tmpDir, "tmp", "", "work directory")
+	flag.StringVar(&prepare, "prepare", "", "build the package given as the argument and capture its archives and link command into this directory")
+	flag.BoolVar(&race, "race", false, "for -prepare, also capture the archives of a -race build")
+	flag.BoolVar(&toolexec, "toolexec", false, "run as a toolexec binary")
+	flag.StringVar(&capture, "capture", "", "for -toolexec, directory to capture the link command into")
+	flag.StringVar(&archives, "archives", "", "directory of archives captured by -prepare to link")
+	flag.StringVar(&name, "name", "", "name of the program being linked, for the benchmark names")
+}
+
+// linkCommand is a captured linker invocation.
+type linkCommand struct {
+	Linker string   // Path to the linker.
+	Args   []string // Arguments, less the output and the main package's archive.
+	Main   string   // Path to the main package's archive.
+}
+
+const linkCommandFile = "link.json"
+
+// runPrepare builds pkgPath, once normally and, with -race, once with the
+// race detector, capturing the link of each into a subdirectory of dir.
+func runPrepare(dir, pkgPath string) error {
+	selfPath, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		return err
+	}
+	sets := []string{"default"}
+	if race {
+		sets = append(sets, "race")
+	}
+	for _, set := range sets {
+		out, err := filepath.Abs(filepath.Join(dir, set))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+		args := []string{"build", "-a", "-o", os.DevNull, "-toolexec", selfPath + " -toolexec -capture " + out}
+		if set == "race" {
+			args = append(args, "-race")
+		}
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = pkgPath
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			if set == "race" {
+				// The race detector isn't supported everywhere. Do
+				// without that variant.
+				fmt.Fprintf(os.Stderr, "warning: building race archives: %v; skipping the race variant\n", err)
+				os.RemoveAll(out)
+				continue
+			}
+			return fmt.Errorf("building %s archives: %v", set, err)
+		}
+		if _, err := os.Stat(filepath.Join(out, linkCommandFile)); err != nil {
+			return fmt.Errorf("building %s archives: link command not captured: %v", set, err)
+		}
+	}
+	return nil
+}
+
+// runToolexec runs the tool in flag.Args(), first capturing it into the
+// -capture directory if it's the linker linking a program: its archives
+// are copied, so that they outlive the build cache, and its arguments are
+// recorded.
+func runToolexec() error {
+	args := flag.Args()
+	if filepath.Base(args[0]) == "link" && slices.Contains(args, "-importcfg") {
+		if err := captureLink(capture, args); err != nil {
+			return fmt.Errorf("capturing link: %v", err)
+		}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	// The go command reads some tools' stdout, e.g. for -V=full.
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func captureLink(dir string, args []string) error {
+	lc := linkCommand{Linker: args[0]}
+	// The last argument is the main package's archive. Of the rest, drop
+	// the output, which the benchmark chooses, and redirect the import
+	// configuration to a copy referring to copies of the archives.
+	mainArchive := args[len(args)-1]
+	for i := 1; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-o":
+			i++
+		case "-importcfg":
+			i++
+			cfg, err := captureImportcfg(dir, args[i])
+			if err != nil {
+				return err
+			}
+			lc.Args = append(lc.Args, "-importcfg", cfg)
+		default:
+			lc.Args = append(lc.Args, args[i])
+		}
+	}
+	lc.Main = filepath.Join(dir, "main.a")
+	if err := copyFile(lc.Main, mainArchive); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(&lc, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, linkCommandFile), b, 0644)
+}
+
+// captureImportcfg copies the archives of the import configuration at path
+// into dir/pkg and writes an import configuration referring to them,
+// returning its path.
+func captureImportcfg(dir, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(filepath.Join(dir, "pkg"), 0755); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for n := 0; s.Scan(); {
+		line := s.Text()
+		if rest, ok := strings.CutPrefix(line, "packagefile "); ok {
+			pkg, file, ok := strings.Cut(rest, "=")
+			if !ok {
+				return "", fmt.Errorf("malformed import configuration line %q", line)
+			}
+			dst := filepath.Join(dir, "pkg", fmt.Sprintf("%d.a", n))
+			n++
+			if err := copyFile(dst, file); err != nil {
+				return "", err
+			}
+			line = "packagefile " + pkg + "=" + dst
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	cfg := filepath.Join(dir, "importcfg.link")
+	return cfg, os.WriteFile(cfg, []byte(out.String()), 0644)
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// variant is a set of flags the program is linked with.
+type variant struct {
+	name  string
+	set   string   // Subdirectory of -archives to link from.
+	flags []string // Flags to add to the captured ones.
+}
+
+var variants = []variant{
+	{"default", "default", nil},
+	{"stripped", "default", []string{"-s", "-w"}},
+	{"race", "race", nil},
+}
+
+func readLinkCommand(dir string) (*linkCommand, error) {
+	b, err := os.ReadFile(filepath.Join(dir, linkCommandFile))
+	if err != nil {
+		return nil, err
+	}
+	lc := new(linkCommand)
+	return lc, json.Unmarshal(b, lc)
+}
+
+func runLink(v variant, lc *linkCommand) error {
+	benchName := fmt.Sprintf("GoLink%s/flags=%s", strings.Title(name), v.name)
+	out := filepath.Join(tmpDir, "a.out")
+	defer os.Remove(out)
+
+	diag := driver.NewDiagnostics(benchName)
+	var diagFlags []string
+	var diagFiles []*driver.DiagnosticFile
+	for _, typ := range []diagnostics.Type{diagnostics.CPUProfile, diagnostics.MemProfile} {
+		df, err := diag.Create(typ)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s diagnostics: %s\n", typ, err)
+			continue
+		} else if df != nil {
+			df.Close()
+			diagFiles = append(diagFiles, df)
+			diagFlags = append(diagFlags, "-"+string(typ), df.Name())
+		}
+	}
+
+	args := append([]string{"-o", out}, diagFlags...)
+	args = append(args, lc.Args...)
+	args = append(args, v.flags...)
+	args = append(args, lc.Main)
+	cmd := exec.Command(lc.Linker, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return driver.RunBenchmark(benchName, func(d *driver.B) error {
+		defer func() {
+			for _, df := range diagFiles {
+				df.Commit()
+			}
+			diag.Commit(d)
+		}()
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+		d.StopTimer()
+		d.Report(driver.StatPeakRSS, driver.ProcessPeakRSS(cmd.ProcessState))
+		st, err := os.Stat(out)
+		if err != nil {
+			return err
+		}
+		d.Report("binary-bytes", uint64(st.Size()))
+		return nil
+	}, driver.DoTime(true))
+}
+
+func run() error {
+	for _, v := range variants {
+		lc, err := readLinkCommand(filepath.Join(archives, v.set))
+		if os.IsNotExist(err) && v.set != "default" {
+			// -prepare didn't capture these archives.
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := runLink(v, lc); err != nil {
+			return fmt.Errorf("linking %s: %v", v.name, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	var err error
+	switch {
+	case toolexec:
+		err = runToolexec()
+	case prepare != "":
+		if flag.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "error: expected one argument\n")
+			os.Exit(1)
+		}
+		err = runPrepare(prepare, flag.Arg(0))
+	default:
+		if archives == "" || flag.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "error: expected -archives and no arguments\n")
+			os.Exit(1)
+		}
+		err = run()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
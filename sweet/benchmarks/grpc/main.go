@@ -0,0 +1,307 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/pool"
+
+	"github.com/golang/snappy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // Registers the gzip compressor.
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type config struct {
+	sizes        []int
+	compressors  []string
+	clients      int
+	payloadBytes int
+	short        bool
+}
+
+var (
+	cliCfg      config
+	sizesFlag   string
+	compressors string
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&sizesFlag, "sizes", "256,4096,65536,1048576", "comma-separated list of approximate encoded message sizes in bytes")
+	flag.StringVar(&compressors, "compression", "none,gzip,snappy", "comma-separated list of compressors to use (none, gzip, snappy)")
+	flag.IntVar(&cliCfg.clients, "clients", 0, "number of concurrent clients (default GOMAXPROCS)")
+	flag.IntVar(&cliCfg.payloadBytes, "payload-bytes", 256<<20, "approximate number of payload bytes to send for each message size and compressor")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// snappyCompressor implements encoding.Compressor for snappy. Unlike gzip,
+// gRPC doesn't ship one, so register our own.
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string { return "snappy" }
+
+func (snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+func init() {
+	encoding.RegisterCompressor(snappyCompressor{})
+}
+
+// echoServer is a gRPC service with a single unary method that
+// returns its input. The service descriptor is written by hand so
+// that the benchmark doesn't need generated code, but the data path
+// is the same one generated code would take.
+type echoServer interface {
+	Echo(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+type echoImpl struct{}
+
+func (echoImpl) Echo(_ context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	return in, nil
+}
+
+const echoMethod = "/sweet.grpc.Echo/Echo"
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sweet.grpc.Echo",
+	HandlerType: (*echoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Echo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(echoServer).Echo(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: echoMethod}
+				return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(echoServer).Echo(ctx, req.(*structpb.Struct))
+				})
+			},
+		},
+	},
+	Metadata: "echo.proto",
+}
+
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do
+eiusmod tempor incididunt ut labore et dolore magna aliqua ut enim ad minim veniam quis
+nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat`)
+
+// makeMessage deterministically generates a message whose encoded size is
+// approximately size bytes. The message consists of a list of records with
+// a mix of field types, which is roughly what a typical RPC payload looks
+// like, and is somewhat compressible.
+func makeMessage(r *rand.Rand, size int) (*structpb.Struct, error) {
+	records := &structpb.ListValue{}
+	msg := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"records": structpb.NewListValue(records),
+	}}
+	for total := 0; total < size; {
+		var desc strings.Builder
+		for i := 0; i < 4+r.Intn(8); i++ {
+			if i != 0 {
+				desc.WriteByte(' ')
+			}
+			desc.WriteString(words[r.Intn(len(words))])
+		}
+		rec, err := structpb.NewValue(map[string]interface{}{
+			"id":          float64(r.Int63n(1 << 40)),
+			"name":        words[r.Intn(len(words))] + "-" + strconv.Itoa(r.Intn(10000)),
+			"description": desc.String(),
+			"score":       r.Float64(),
+			"active":      r.Intn(2) == 0,
+		})
+		if err != nil {
+			return nil, err
+		}
+		records.Values = append(records.Values, rec)
+		// Account for the record and a few bytes of framing, rather than
+		// recomputing the size of the whole message each time.
+		total += proto.Size(rec) + 4
+	}
+	return msg, nil
+}
+
+type worker struct {
+	conn       *grpc.ClientConn
+	compressor string
+	msg        *structpb.Struct
+	iterCount  *int64 // Accessed atomically.
+	lat        []time.Duration
+}
+
+func (w *worker) Run(ctx context.Context) error {
+	if atomic.AddInt64(w.iterCount, -1) < 0 {
+		return pool.Done
+	}
+	var opts []grpc.CallOption
+	if w.compressor != "none" {
+		opts = append(opts, grpc.UseCompressor(w.compressor))
+	}
+	resp := new(structpb.Struct)
+	start := time.Now()
+	if err := w.conn.Invoke(ctx, echoMethod, w.msg, resp, opts...); err != nil {
+		return err
+	}
+	w.lat = append(w.lat, time.Since(start))
+	return nil
+}
+
+func (w *worker) Close() error {
+	return nil
+}
+
+type durSlice []time.Duration
+
+func (d durSlice) Len() int           { return len(d) }
+func (d durSlice) Less(i, j int) bool { return d[i] < d[j] }
+func (d durSlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func runBenchmark(d *driver.B, conn *grpc.ClientConn, cfg *config, compressor string, msg *structpb.Struct, iters int) error {
+	iterCount := int64(iters) // Shared atomic variable.
+	workers := make([]pool.Worker, 0, cfg.clients)
+	for i := 0; i < cfg.clients; i++ {
+		workers = append(workers, &worker{
+			conn:       conn,
+			compressor: compressor,
+			msg:        msg,
+			iterCount:  &iterCount,
+			lat:        make([]time.Duration, 0, iters/cfg.clients+1),
+		})
+	}
+	p := pool.New(context.Background(), workers)
+
+	d.ResetTimer()
+	if err := p.Run(); err != nil {
+		return err
+	}
+	d.StopTimer()
+
+	latencies := make([]time.Duration, 0, iters)
+	for _, w := range workers {
+		latencies = append(latencies, w.(*worker).lat...)
+	}
+	sort.Sort(durSlice(latencies))
+
+	d.Report("p50-latency-ns", uint64(latencies[len(latencies)*50/100]))
+	d.Report("p90-latency-ns", uint64(latencies[len(latencies)*90/100]))
+	d.Report("p99-latency-ns", uint64(latencies[len(latencies)*99/100]))
+
+	// Report throughput, both in terms of requests and in terms of the
+	// uncompressed payload bytes that made it through the full pipeline
+	// in both directions.
+	lengthS := float64(d.Elapsed()) / float64(time.Second)
+	d.Report("ops/s", uint64(float64(len(latencies))/lengthS))
+	d.Report("B/s", uint64(float64(2*proto.Size(msg)*len(latencies))/lengthS))
+
+	d.Ops(len(latencies))
+	d.Report(driver.StatTime, uint64((int(d.Elapsed())*cfg.clients)/len(latencies)))
+	return nil
+}
+
+func run(cfg *config) error {
+	// Start the server on loopback. The client and server share a process,
+	// so in-process measurements capture both sides of the data path.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(64<<20), grpc.MaxSendMsgSize(64<<20))
+	srv.RegisterService(&echoServiceDesc, echoImpl{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(64<<20), grpc.MaxCallSendMsgSize(64<<20)),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r := rand.New(rand.NewSource(0))
+	for _, size := range cfg.sizes {
+		msg, err := makeMessage(r, size)
+		if err != nil {
+			return fmt.Errorf("generating message of size %d: %v", size, err)
+		}
+		iters := cfg.payloadBytes / size
+		if cfg.short {
+			iters = 10
+		}
+		if iters < cfg.clients {
+			iters = cfg.clients
+		}
+		for _, c := range cfg.compressors {
+			name := fmt.Sprintf("GRPCUnary/compression=%s/size=%d", c, size)
+			err := driver.RunBenchmark(name, func(d *driver.B) error {
+				return runBenchmark(d, conn, cfg, c, msg, iters)
+			}, driver.InProcessMeasurementOptions...)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	for _, s := range strings.Split(sizesFlag, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size <= 0 {
+			fmt.Fprintf(os.Stderr, "error: invalid message size %q\n", s)
+			os.Exit(1)
+		}
+		cliCfg.sizes = append(cliCfg.sizes, size)
+	}
+	for _, c := range strings.Split(compressors, ",") {
+		c = strings.TrimSpace(c)
+		if c != "none" && encoding.GetCompressor(c) == nil {
+			fmt.Fprintf(os.Stderr, "error: unknown compressor %q\n", c)
+			os.Exit(1)
+		}
+		cliCfg.compressors = append(cliCfg.compressors, c)
+	}
+	if cliCfg.clients <= 0 {
+		cliCfg.clients = runtime.GOMAXPROCS(-1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
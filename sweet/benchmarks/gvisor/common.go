@@ -29,6 +29,9 @@ func (cfg *config) runscCmd(arg ...string) (*exec.Cmd, []func()) {
 	var cmd *exec.Cmd
 
 	cmdArgs := []string{cfg.runscPath}
+	if cfg.platform != "" {
+		cmdArgs = append(cmdArgs, "-platform", cfg.platform)
+	}
 
 	goProfiling := false
 	var postExit []func()
@@ -145,8 +145,8 @@ func (b httpServer) run(cfg *config, out io.Writer) (err error) {
 		}
 	}()
 
-	err = driver.RunBenchmark(b.name()+"Startup", func(d *driver.B) error {
-		if err := srvCmd.Start(); err != nil {
+	err = driver.RunBenchmark(cfg.benchName(b.name()+"Startup"), func(d *driver.B) error {
+		if err := driver.StartChild(&srvCmd.Cmd); err != nil {
 			return err
 		}
 		// Poll until the server is ready to serve, up to a maximum in case of a bug.
@@ -174,7 +174,7 @@ func (b httpServer) run(cfg *config, out io.Writer) (err error) {
 	ctx, cancel := context.WithTimeout(ctx, b.duration)
 	defer cancel()
 	p := pool.New(ctx, workers)
-	return driver.RunBenchmark(b.name(), func(d *driver.B) error {
+	return driver.RunBenchmark(cfg.benchName(b.name()), func(d *driver.B) error {
 		if err := p.Run(); err != nil {
 			return err
 		}
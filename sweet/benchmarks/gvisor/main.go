@@ -14,6 +14,7 @@ import (
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
@@ -24,6 +25,8 @@ type config struct {
 	assetsDir string
 	tmpDir    string
 	short     bool
+	platforms string
+	platform  string
 
 	diag *driver.Diagnostics
 }
@@ -36,6 +39,30 @@ func init() {
 	flag.StringVar(&cliCfg.assetsDir, "assets-dir", "", "path to the directory containing benchmark root filesystems")
 	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to a temporary working directory")
 	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of the benchmarks")
+	flag.StringVar(&cliCfg.platforms, "platforms", "", "comma-separated list of runsc platforms to run each benchmark under, e.g. ,ptrace,kvm; an empty element means runsc's default platform (default: only runsc's default platform)")
+}
+
+// benchName returns the name to report for the benchmark base when
+// running under cfg's platform.
+func (cfg *config) benchName(base string) string {
+	if cfg.platform == "" {
+		return base
+	}
+	return base + "/platform=" + cfg.platform
+}
+
+// platformAvailable reports whether runsc can use platform on this machine.
+func platformAvailable(platform string) bool {
+	if platform != "kvm" {
+		return true
+	}
+	// The KVM platform needs read-write access to /dev/kvm.
+	f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
 }
 
 type benchmark interface {
@@ -61,22 +88,40 @@ func main1() error {
 		}
 	}
 
-	// Run each benchmark once.
-	for _, bench := range benchmarks {
-		cfg := cliCfg
-		cfg.diag = driver.NewDiagnostics(bench.name())
-
-		// Run the benchmark command under runsc.
-		var buf bytes.Buffer
-		if err := bench.run(&cfg, &buf); err != nil {
-			if buf.Len() != 0 {
-				fmt.Fprintf(os.Stderr, "=== Benchmark %s stdout+stderr ===", bench.name())
-				fmt.Fprintf(os.Stderr, "%s\n", buf.String())
-			}
-			return err
+	// The Go runtime interacts very differently with each platform's
+	// trap path, so run every benchmark under each requested platform.
+	// Each one adds a full run of every benchmark, so only runsc's
+	// default platform is run unless others are asked for. Short runs
+	// are smoke tests, so stick to the default platform.
+	platforms := strings.Split(cliCfg.platforms, ",")
+	if cliCfg.short {
+		platforms = []string{""}
+	}
+
+	// Run each benchmark once per platform.
+	for _, platform := range platforms {
+		if !platformAvailable(platform) {
+			fmt.Fprintf(os.Stderr, "warning: skipping runsc platform %s: not available on this machine\n", platform)
+			continue
 		}
+		for _, bench := range benchmarks {
+			cfg := cliCfg
+			cfg.platform = platform
+			name := cfg.benchName(bench.name())
+			cfg.diag = driver.NewDiagnostics(name)
 
-		cfg.diag.Commit(nil)
+			// Run the benchmark command under runsc.
+			var buf bytes.Buffer
+			if err := bench.run(&cfg, &buf); err != nil {
+				if buf.Len() != 0 {
+					fmt.Fprintf(os.Stderr, "=== Benchmark %s stdout+stderr ===", name)
+					fmt.Fprintf(os.Stderr, "%s\n", buf.String())
+				}
+				return err
+			}
+
+			cfg.diag.Commit(nil)
+		}
 	}
 	return nil
 }
@@ -30,7 +30,7 @@ func (b startup) run(cfg *config, out io.Writer) error {
 			fn()
 		}
 	}()
-	return driver.RunBenchmark(b.name(), func(d *driver.B) error {
+	return driver.RunBenchmark(cfg.benchName(b.name()), func(d *driver.B) error {
 		return cmd.Run()
 	}, driver.DoTime(true))
 }
@@ -35,7 +35,7 @@ func (b systemCall) run(cfg *config, out io.Writer) error {
 			fn()
 		}
 	}()
-	return driver.RunBenchmark(b.name(), func(d *driver.B) error {
+	return driver.RunBenchmark(cfg.benchName(b.name()), func(d *driver.B) error {
 		d.Ops(b.ops)
 		d.ResetTimer()
 		return cmd.Run()
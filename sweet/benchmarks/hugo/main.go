@@ -0,0 +1,246 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/cgroups"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
+	"golang.org/x/benchmarks/sweet/common/fileutil"
+)
+
+var (
+	hugoBin string
+	siteDir string
+	tmpDir  string
+	short   bool
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&hugoBin, "hugo", "", "path to hugo binary")
+	flag.StringVar(&siteDir, "site", "", "directory of the site to build, which is left as it is")
+	flag.StringVar(&tmpDir, "tmp", "", "work directory (cleared before use)")
+	flag.BoolVar(&short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// shortPages is the number of pages short runs build, the first of the
+// site's, which are numbered from 0 in page<N>.md files (see the hugo
+// generator).
+const shortPages = 100
+
+// copySite copies the site in src to dst, where hugo can write to it, with
+// only its first shortPages pages in short mode.
+func copySite(dst, src string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		var n int
+		if _, err := fmt.Sscanf(d.Name(), "page%d.md", &n); short && err == nil && n >= shortPages {
+			return nil
+		}
+		return fileutil.CopyFile(target, path, nil, nil)
+	})
+}
+
+// gcCounter counts the GC cycles in the gctrace output written to it, and
+// passes everything else on to w.
+type gcCounter struct {
+	w      io.Writer
+	cycles uint64
+	pw     *io.PipeWriter
+	done   chan struct{}
+}
+
+func newGCCounter(w io.Writer) *gcCounter {
+	pr, pw := io.Pipe()
+	c := &gcCounter{w: w, pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		s := bufio.NewScanner(pr)
+		for s.Scan() {
+			if strings.HasPrefix(s.Text(), "gc ") {
+				c.cycles++
+				continue
+			}
+			fmt.Fprintln(c.w, s.Text())
+		}
+		io.Copy(io.Discard, pr)
+	}()
+	return c
+}
+
+func (c *gcCounter) Write(b []byte) (int, error) {
+	return c.pw.Write(b)
+}
+
+// Close waits for everything written to c to be counted, and returns the
+// number of GC cycles.
+func (c *gcCounter) Close() uint64 {
+	c.pw.Close()
+	<-c.done
+	return c.cycles
+}
+
+func run() error {
+	// Hugo writes its build lock and resource cache into the site, so
+	// build a copy of it.
+	srcDir := filepath.Join(tmpDir, "site")
+	pubDir := filepath.Join(tmpDir, "public")
+	for _, dir := range []string{srcDir, pubDir} {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	if err := copySite(srcDir, siteDir); err != nil {
+		return fmt.Errorf("copying site: %v", err)
+	}
+
+	const name = "HugoBuild"
+	cmdArgs := []string{hugoBin, "--source", srcDir, "--destination", pubDir, "--quiet"}
+
+	// Set up diagnostics. Rather than run hugo under perf, perf is
+	// attached to it once it has started, so that hugo's process state,
+	// and the peak RSS it gives, are hugo's own.
+	var diagFiles []*driver.DiagnosticFile
+	diag := driver.NewDiagnostics(name)
+	perfFile, err := diag.Create(diagnostics.Perf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %s diagnostics: %s\n", diagnostics.Perf, err)
+	} else if perfFile != nil {
+		perfFile.Close()
+		diagFiles = append(diagFiles, perfFile)
+	}
+	for _, typ := range []diagnostics.Type{diagnostics.CPUProfile, diagnostics.MemProfile, diagnostics.Trace} {
+		df, err := diag.Create(typ)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s diagnostics: %s\n", typ, err)
+			continue
+		} else if df != nil {
+			df.Close()
+			diagFiles = append(diagFiles, df)
+
+			flag := "--trace"
+			switch typ {
+			case diagnostics.CPUProfile:
+				flag = "--profile-cpu"
+			case diagnostics.MemProfile:
+				flag = "--profile-mem"
+			}
+			cmdArgs = append(cmdArgs, flag, df.Name())
+		}
+	}
+
+	// Count GC cycles with gctrace, keeping any GODEBUG settings the
+	// configuration has.
+	godebug := "gctrace=1"
+	if v := os.Getenv("GODEBUG"); v != "" {
+		godebug = v + "," + godebug
+	}
+	gcs := newGCCounter(os.Stderr)
+	baseCmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	baseCmd.Env = append(os.Environ(), "GODEBUG="+godebug)
+	baseCmd.Stdout = os.Stderr // Redirect all tool output to stderr.
+	baseCmd.Stderr = gcs
+	cpus := driver.CPULimit()
+	cmd, err := cgroups.WrapCommandCPUs(baseCmd, "test.scope", cpus)
+	if err != nil {
+		return err
+	}
+	opts := []driver.RunOption{driver.DoTime(true), driver.DoAvgRSS(cmd.RSSFunc()), driver.WithEnv(cmd.Env), driver.WithCPULimit()}
+	return driver.RunBenchmark(name, func(d *driver.B) error {
+		defer diag.Commit(d)
+		defer func() {
+			for _, df := range diagFiles {
+				df.Commit()
+			}
+		}()
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		var perf *exec.Cmd
+		if perfFile != nil {
+			perf, err = startPerf(perfFile, cmd.Process.Pid)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to start perf: %v\n", err)
+			}
+		}
+		err := cmd.Wait()
+		d.StopTimer()
+		if perf != nil {
+			if err := stopPerf(perf); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to stop perf: %v\n", err)
+			}
+		}
+		cycles := gcs.Close()
+		if err != nil {
+			return err
+		}
+		d.Report(driver.StatPeakRSS, driver.ProcessPeakRSS(cmd.ProcessState))
+		d.Report("gc-cycles", cycles)
+		return nil
+	}, opts...)
+}
+
+// startPerf starts perf recording the process pid into df.
+func startPerf(df *driver.DiagnosticFile, pid int) (*exec.Cmd, error) {
+	args := []string{"record", "-o", df.Name(), "-p", strconv.Itoa(pid)}
+	args = append(args, driver.PerfFlags()...)
+	perf := exec.Command("perf", args...)
+	perf.Stderr = os.Stderr
+	if err := perf.Start(); err != nil {
+		return nil, err
+	}
+	return perf, nil
+}
+
+// stopPerf stops perf, started by startPerf, once it has written out its
+// recording.
+func stopPerf(perf *exec.Cmd) error {
+	if err := perf.Process.Signal(os.Interrupt); err != nil {
+		return err
+	}
+	return perf.Wait()
+}
+
+func main() {
+	flag.Parse()
+	if hugoBin == "" {
+		fmt.Fprintln(os.Stderr, "expected non-empty hugo flag")
+		os.Exit(1)
+	}
+	if siteDir == "" {
+		fmt.Fprintln(os.Stderr, "expected non-empty site flag")
+		os.Exit(1)
+	}
+	if tmpDir == "" {
+		fmt.Fprintln(os.Stderr, "expected non-empty tmp flag")
+		os.Exit(1)
+	}
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -71,7 +71,22 @@ type Cmd struct {
 }
 
 func WrapCommand(cmd *exec.Cmd, scope string) (*Cmd, error) {
+	return WrapCommandCPUs(cmd, scope, 0)
+}
+
+// WrapCommandCPUs is like WrapCommand, but if cpus is positive it also
+// caps the command at that many CPUs, both in the scope's cpu.max and in
+// GOMAXPROCS, so that the parallelism of a Go command doesn't depend on
+// the size of the machine. Without systemd-run only GOMAXPROCS is set.
+func WrapCommandCPUs(cmd *exec.Cmd, scope string, cpus int) (*Cmd, error) {
 	wrapped := Cmd{Cmd: *cmd}
+	if cpus > 0 {
+		env := wrapped.Cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		wrapped.Cmd.Env = append(env[:len(env):len(env)], fmt.Sprintf("GOMAXPROCS=%d", cpus))
+	}
 
 	systemdOnce.Do(func() {
 		systemdRunPath, systemdRunError = findSystemdRun()
@@ -86,9 +101,12 @@ func WrapCommand(cmd *exec.Cmd, scope string) (*Cmd, error) {
 	if err != nil {
 		return nil, err
 	}
-	wrapped.Cmd.Args = append([]string{
-		systemdRunPath, "--user", "--scope", "--unit=" + scope,
-	}, wrapped.Cmd.Args...)
+	systemdArgs := []string{systemdRunPath, "--user", "--scope", "--unit=" + scope}
+	if cpus > 0 {
+		// CPUQuota is written to cpu.max, as a percentage of one CPU.
+		systemdArgs = append(systemdArgs, fmt.Sprintf("--property=CPUQuota=%d%%", cpus*100))
+	}
+	wrapped.Cmd.Args = append(systemdArgs, wrapped.Cmd.Args...)
 	wrapped.Cmd.Path = systemdRunPath
 	wrapped.modified = true
 	wrapped.path = fmt.Sprintf("user-%s.slice/user@%s.service/app.slice", u.Uid, u.Uid)
@@ -0,0 +1,144 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statMinCPUFreq     = "min-cpu-freq-Hz"
+	statAvgCPUFreq     = "avg-cpu-freq-Hz"
+	statThrottleEvents = "thermal-throttle-events"
+)
+
+// sysCPUDir is the sysfs directory with a subdirectory for each CPU.
+const sysCPUDir = "/sys/devices/system/cpu"
+
+// DoCPUFreq samples the current frequency of every CPU while the benchmark
+// runs, and counts the thermal throttling events the CPUs report, where
+// Linux makes them available.
+//
+// The benchmark reports the minimum and average frequency seen, in Hz,
+// and the number of throttling events, if any CPU reports them. A
+// benchmark that ran while the machine was throttled warns about it,
+// since its results are likely to be compromised.
+func DoCPUFreq(v bool) RunOption {
+	return func(b *B) {
+		b.doCPUFreq = v
+	}
+}
+
+// readSysUint reads a file containing a single unsigned integer, as found
+// in sysfs.
+func readSysUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readThrottleCount returns the total number of thermal throttling events
+// reported by all CPUs under cpuDir so far, and whether any CPU reports
+// them at all. SMT siblings report the throttling events of their shared
+// core, and every CPU in a package reports those of the whole package, so
+// those are only counted once per core and once per package.
+func readThrottleCount(cpuDir string) (uint64, bool) {
+	cpus, _ := filepath.Glob(filepath.Join(cpuDir, "cpu[0-9]*", "thermal_throttle"))
+	var total uint64
+	found := false
+	type core struct{ pkg, id uint64 }
+	cores := make(map[core]bool)
+	packages := make(map[uint64]bool)
+	for _, dir := range cpus {
+		topology := filepath.Join(dir, "..", "topology")
+		pkg, err := readSysUint(filepath.Join(topology, "physical_package_id"))
+		if err != nil {
+			continue
+		}
+		id, err := readSysUint(filepath.Join(topology, "core_id"))
+		if err == nil && !cores[core{pkg, id}] {
+			if n, err := readSysUint(filepath.Join(dir, "core_throttle_count")); err == nil {
+				total += n
+				found = true
+				cores[core{pkg, id}] = true
+			}
+		}
+		if packages[pkg] {
+			continue
+		}
+		if n, err := readSysUint(filepath.Join(dir, "package_throttle_count")); err == nil {
+			total += n
+			found = true
+			packages[pkg] = true
+		}
+	}
+	return total, found
+}
+
+func (b *B) startCPUFreqSampler() chan<- struct{} {
+	if !b.doCPUFreq {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(sysCPUDir, "cpu[0-9]*", "cpufreq", "scaling_cur_freq"))
+	if len(paths) == 0 {
+		warningf("failed to sample CPU frequencies: cpufreq is not available")
+		return nil
+	}
+	stop := make(chan struct{})
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		throttleStart, haveThrottle := readThrottleCount(sysCPUDir)
+		var minHz, sum, n uint64
+		sample := func() {
+			for _, p := range paths {
+				khz, err := readSysUint(p)
+				if err != nil {
+					continue // The CPU may have gone offline.
+				}
+				hz := khz * 1000
+				if n == 0 || hz < minHz {
+					minHz = hz
+				}
+				sum += hz
+				n++
+			}
+		}
+		sample()
+		for {
+			select {
+			case <-stop:
+				sample()
+				if n != 0 {
+					b.setStat(statMinCPUFreq, minHz)
+					b.setStat(statAvgCPUFreq, sum/n)
+				}
+				if haveThrottle {
+					throttleEnd, _ := readThrottleCount(sysCPUDir)
+					var events uint64
+					if throttleEnd > throttleStart {
+						// Otherwise, a CPU went offline.
+						events = throttleEnd - throttleStart
+					}
+					b.setStat(statThrottleEvents, events)
+					if events != 0 {
+						warningf("CPUs were thermally throttled %d times during the run; results may be compromised", events)
+					}
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+				sample()
+			}
+		}
+	}()
+	return stop
+}
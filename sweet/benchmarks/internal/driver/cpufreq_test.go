@@ -0,0 +1,162 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSysUint(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		data    string
+		want    uint64
+		wantErr bool
+	}{
+		{"2400000\n", 2400000, false},
+		{"0", 0, false},
+		{"  17 \n", 17, false},
+		{"-1\n", 0, true},
+		{"<unknown>\n", 0, true},
+		{"", 0, true},
+	} {
+		file := filepath.Join(dir, "value")
+		if err := os.WriteFile(file, []byte(tc.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readSysUint(file)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("readSysUint of %q = %d, %v; want %d, error %v", tc.data, got, err, tc.want, tc.wantErr)
+		}
+	}
+	if _, err := readSysUint(filepath.Join(dir, "missing")); err == nil {
+		t.Error("readSysUint of a missing file succeeded")
+	}
+}
+
+// sysCPU is the sysfs topology and throttling counters of one CPU. A
+// negative value leaves out its file.
+type sysCPU struct {
+	pkg, core             int
+	coreCount, pkgCount   int
+	noThrottle, noTopoDir bool
+}
+
+// writeSysCPUs lays out cpus under dir as Linux does under
+// /sys/devices/system/cpu.
+func writeSysCPUs(t *testing.T, dir string, cpus []sysCPU) {
+	t.Helper()
+	write := func(path string, v int) {
+		if v < 0 {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", v)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, c := range cpus {
+		cpu := filepath.Join(dir, fmt.Sprintf("cpu%d", i))
+		if !c.noTopoDir {
+			write(filepath.Join(cpu, "topology", "physical_package_id"), c.pkg)
+			write(filepath.Join(cpu, "topology", "core_id"), c.core)
+		}
+		if !c.noThrottle {
+			if err := os.MkdirAll(filepath.Join(cpu, "thermal_throttle"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			write(filepath.Join(cpu, "thermal_throttle", "core_throttle_count"), c.coreCount)
+			write(filepath.Join(cpu, "thermal_throttle", "package_throttle_count"), c.pkgCount)
+		}
+	}
+	// Not a CPU.
+	if err := os.MkdirAll(filepath.Join(dir, "cpufreq"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadThrottleCount(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		cpus      []sysCPU
+		want      uint64
+		wantFound bool
+	}{
+		{
+			name: "no throttling support",
+			cpus: []sysCPU{{noThrottle: true}, {core: 1, noThrottle: true}},
+		},
+		{
+			name: "one package",
+			cpus: []sysCPU{
+				{pkg: 0, core: 0, coreCount: 3, pkgCount: 10},
+				{pkg: 0, core: 1, coreCount: 4, pkgCount: 10},
+			},
+			want:      3 + 4 + 10,
+			wantFound: true,
+		},
+		{
+			// SMT siblings report the same core count.
+			name: "SMT siblings",
+			cpus: []sysCPU{
+				{pkg: 0, core: 0, coreCount: 3, pkgCount: 10},
+				{pkg: 0, core: 1, coreCount: 4, pkgCount: 10},
+				{pkg: 0, core: 0, coreCount: 3, pkgCount: 10},
+				{pkg: 0, core: 1, coreCount: 4, pkgCount: 10},
+			},
+			want:      3 + 4 + 10,
+			wantFound: true,
+		},
+		{
+			// Core IDs are only unique within a package.
+			name: "two packages",
+			cpus: []sysCPU{
+				{pkg: 0, core: 0, coreCount: 1, pkgCount: 10},
+				{pkg: 1, core: 0, coreCount: 2, pkgCount: 20},
+				{pkg: 0, core: 0, coreCount: 1, pkgCount: 10},
+				{pkg: 1, core: 0, coreCount: 2, pkgCount: 20},
+			},
+			want:      1 + 2 + 10 + 20,
+			wantFound: true,
+		},
+		{
+			name: "package counts only",
+			cpus: []sysCPU{
+				{pkg: 0, core: 0, coreCount: -1, pkgCount: 5},
+				{pkg: 0, core: 1, coreCount: -1, pkgCount: 5},
+			},
+			want:      5,
+			wantFound: true,
+		},
+		{
+			name: "no core ID",
+			cpus: []sysCPU{
+				{pkg: 0, core: -1, coreCount: 3, pkgCount: 5},
+			},
+			want:      5,
+			wantFound: true,
+		},
+		{
+			name: "no topology",
+			cpus: []sysCPU{
+				{coreCount: 3, pkgCount: 5, noTopoDir: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSysCPUs(t, dir, tc.cpus)
+			got, found := readThrottleCount(dir)
+			if got != tc.want || found != tc.wantFound {
+				t.Errorf("readThrottleCount = %d, %v; want %d, %v", got, found, tc.want, tc.wantFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"os"
+	"os/exec"
+	"runtime/pprof"
+	"sync"
+)
+
+// DeadlineExitCode is the status a benchmark exits with when a run exceeds
+// its deadline (see -deadline and WithDeadline), the same as timeout(1)'s,
+// so that a wedged benchmark can be told apart from one that failed.
+const DeadlineExitCode = 124
+
+var (
+	childrenMu sync.Mutex
+	children   = make(map[*exec.Cmd]bool)
+)
+
+// StartChild starts cmd, a process the benchmark runs alongside itself such
+// as a server or a load generator, and makes sure it is killed if a run
+// exceeds its deadline. A benchmark exits then without running any deferred
+// cleanup, which would otherwise leave the process behind.
+func StartChild(cmd *exec.Cmd) error {
+	childrenMu.Lock()
+	defer childrenMu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	children[cmd] = true
+	return nil
+}
+
+// RunChild is like StartChild, but waits for cmd to finish, like cmd.Run.
+func RunChild(cmd *exec.Cmd) error {
+	if err := StartChild(cmd); err != nil {
+		return err
+	}
+	err := cmd.Wait()
+	childrenMu.Lock()
+	delete(children, cmd)
+	childrenMu.Unlock()
+	return err
+}
+
+// killChildren kills the processes started with StartChild, and the
+// process being benchmarked if it isn't this one.
+func (b *B) killChildren() {
+	childrenMu.Lock()
+	defer childrenMu.Unlock()
+	for cmd := range children {
+		// Kill fails harmlessly for processes that were already waited for.
+		cmd.Process.Kill()
+	}
+	if b.pid != 0 && b.pid != os.Getpid() {
+		if p, err := os.FindProcess(b.pid); err == nil {
+			p.Kill()
+		}
+	}
+}
+
+// deadlineExceeded is called when a run of b has taken longer than its
+// deadline. Rather than leave the run to be killed by some outer timeout
+// with nothing to show for it, it dumps what the benchmark's goroutines
+// are doing, commits the diagnostics collected so far as partial, kills
+// the processes it started, and exits.
+func (b *B) deadlineExceeded() {
+	warningf("benchmark %s did not finish within its deadline of %v; dumping goroutine stacks and partial diagnostics", b.fullName(""), b.deadline)
+	if err := b.writeStacks(); err != nil {
+		warningf("failed to write goroutine stacks: %v", err)
+	}
+	b.commitPartialDiagnostics()
+	b.killChildren()
+	os.Exit(DeadlineExitCode)
+}
+
+// writeStacks writes the stacks of all goroutines, in the format of an
+// unrecovered panic, to a file in the diagnostics results directory, if
+// there is one, and otherwise to stderr.
+func (b *B) writeStacks() error {
+	goroutines := pprof.Lookup("goroutine")
+	if diag.ResultsDir == "" {
+		return goroutines.WriteTo(os.Stderr, 2)
+	}
+	f, err := os.CreateTemp(diag.ResultsDir, safeFileName(b.fullName(""))+"-*-stacks.txt")
+	if err != nil {
+		return err
+	}
+	werr := goroutines.WriteTo(f, 2)
+	if err := f.Close(); werr == nil {
+		werr = err
+	}
+	if werr == nil {
+		warningf("wrote goroutine stacks to %s", f.Name())
+	}
+	return werr
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestKillChildren(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep command not found")
+	}
+	running := exec.Command("sleep", "60")
+	if err := StartChild(running); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		childrenMu.Lock()
+		delete(children, running)
+		childrenMu.Unlock()
+	})
+	done := exec.Command("true")
+	if err := RunChild(done); err != nil {
+		t.Fatal(err)
+	}
+	childrenMu.Lock()
+	if children[done] {
+		t.Error("RunChild left its finished process registered")
+	}
+	childrenMu.Unlock()
+
+	newB("Test").killChildren()
+	exited := make(chan error, 1)
+	go func() { exited <- running.Wait() }()
+	select {
+	case err := <-exited:
+		if err == nil {
+			t.Error("child exited successfully, want it killed")
+		}
+	case <-time.After(10 * time.Second):
+		running.Process.Kill()
+		t.Fatal("child still running after killChildren")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -38,6 +39,9 @@ import (
 type Diagnostics struct {
 	name string
 
+	// partial marks the output files as incomplete. See commitPartial.
+	partial bool
+
 	once      sync.Once
 	tmpDir    string
 	tmpDirErr error
@@ -106,6 +110,14 @@ func (d *Diagnostics) Commit(b *B) error {
 	return err
 }
 
+// commitPartial is like Commit, but appends ".partial" to the names of the
+// output files, for diagnostics that were cut short, such as by a panic.
+// Tools that consume complete diagnostics, like sweet's -pgo, skip them.
+func (d *Diagnostics) commitPartial(b *B) error {
+	d.partial = true
+	return d.Commit(b)
+}
+
 func (d *Diagnostics) commit1(b *B) error {
 	if d.tmpDir == "" {
 		// No diagnostics were created.
@@ -152,8 +164,9 @@ func (d *Diagnostics) commit1(b *B) error {
 
 	// Process each merge list.
 	var errs []error
-	anyTrace := false
+	var tracePaths, mutexPaths []string
 	var traceBytes int64
+	instanceBytes := make(map[string]int64)
 	for k, paths := range toMerge {
 		if err, outPath, deleteInputs := d.merge(k.typ, k.name, paths); err != nil {
 			errs = append(errs, err)
@@ -161,17 +174,50 @@ func (d *Diagnostics) commit1(b *B) error {
 			if deleteInputs {
 				toDelete = append(toDelete, paths...)
 			}
+			var size int64
+			if st, err := os.Stat(outPath); err == nil {
+				size = st.Size()
+			}
 			if k.typ == diagnostics.Trace {
-				anyTrace = true
-				if st, err := os.Stat(outPath); err == nil {
-					traceBytes = st.Size()
-				}
+				tracePaths = append(tracePaths, outPath)
+				traceBytes += size
+			}
+			if k.typ == diagnostics.MutexProfile {
+				mutexPaths = append(mutexPaths, outPath)
+			}
+			if i, ok := parseInstanceName(k.name); ok && !k.typ.CanMerge() {
+				instanceBytes[fmt.Sprintf("%s-inst%d-bytes", k.typ, i)] = size
 			}
 		}
 	}
-	if b != nil && anyTrace {
+	if b != nil && len(tracePaths) != 0 {
 		// Report metric for diagnostic size.
 		b.Report("trace-bytes", uint64(traceBytes))
+
+		// Summarize what the traces show, so they aren't just opaque
+		// artifacts. Partial traces may be cut off mid-event, so skip
+		// them.
+		if !d.partial {
+			if stats, err := readTracesStats(diag.GoTool, tracePaths); err != nil {
+				warningf("failed to analyze traces: %v", err)
+			} else {
+				stats.report(b)
+			}
+		}
+	}
+	if b != nil && len(mutexPaths) != 0 {
+		// Summarize the contention, so that regressions in it show up
+		// without opening the profile.
+		if stats, err := readMutexStats(mutexPaths); err != nil {
+			warningf("failed to analyze mutex profiles: %v", err)
+		} else {
+			stats.report(b)
+		}
+	}
+	if b != nil {
+		for unit, size := range instanceBytes {
+			b.Report(unit, uint64(size))
+		}
 	}
 
 	// Delete all of the temporary files.
@@ -193,7 +239,11 @@ func (d *Diagnostics) merge(typ diagnostics.Type, subName string, paths []string
 	if subName != "" {
 		name += "-" + subName
 	}
-	outFile, err := os.CreateTemp(diag.ResultsDir, safeFileName(name)+"-*-"+typ.FileName())
+	suffix := typ.FileName()
+	if d.partial {
+		suffix += ".partial"
+	}
+	outFile, err := os.CreateTemp(diag.ResultsDir, safeFileName(name)+"-*-"+suffix)
 	if err != nil {
 		return err, "", false
 	}
@@ -242,9 +292,35 @@ type DiagnosticFile struct {
 	*os.File
 }
 
+// staleTmpOnce guards the removal of stale uncommitted diagnostics.
+var staleTmpOnce sync.Once
+
+// removeStaleTmp removes the uncommitted diagnostics that benchmark
+// processes which crashed left in the results directory, reporting what it
+// removed to stderr.
+func removeStaleTmp() {
+	if diag.ResultsDir == "" {
+		// The diagnostics go to the system's temporary directory, which
+		// isn't ours to clean up.
+		return
+	}
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+	n, err := diagnostics.RemoveStaleTmp(diag.ResultsDir, diagnostics.StaleTmpAge, logf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "removing stale diagnostics: %v\n", err)
+	}
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "reclaimed %d bytes from stale diagnostics in %s\n", n, diag.ResultsDir)
+	}
+}
+
 // getTmpDir returns the directory for storing uncommitted diagnostics files.
+// The first time a process needs one, it cleans up after any that crashed.
 func (d *Diagnostics) getTmpDir() (string, error) {
 	d.once.Do(func() {
+		staleTmpOnce.Do(removeStaleTmp)
 		// Create the uncommitted results directory.
 		d.tmpDir, d.tmpDirErr = os.MkdirTemp(diag.ResultsDir, safeFileName(d.name)+"-*.tmp")
 	})
@@ -256,6 +332,38 @@ func (d *Diagnostics) Create(typ diagnostics.Type) (*DiagnosticFile, error) {
 	return d.CreateNamed(typ, "")
 }
 
+// CreateForInstance is shorthand for CreateNamed(typ, InstanceName(typ, i)).
+func (d *Diagnostics) CreateForInstance(typ diagnostics.Type, i int) (*DiagnosticFile, error) {
+	return d.CreateNamed(typ, InstanceName(typ, i))
+}
+
+// instancePrefix begins the names of diagnostics from individual server
+// instances.
+const instancePrefix = diagnostics.ServerName + "-inst"
+
+// InstanceName returns the name for diagnostics of type typ collected from
+// instance i of a benchmark that runs several server instances, such as the
+// nodes of a cluster. Diagnostics that can be merged are merged across all
+// instances; all others get a file per instance, and Commit reports the size
+// of each as a "<typ>-inst<i>-bytes" metric.
+func InstanceName(typ diagnostics.Type, i int) string {
+	if typ.CanMerge() {
+		return diagnostics.ServerName
+	}
+	return fmt.Sprintf("%s%d", instancePrefix, i)
+}
+
+// parseInstanceName returns the instance number from a diagnostic name
+// returned by InstanceName for an unmergeable type.
+func parseInstanceName(name string) (int, bool) {
+	s, ok := strings.CutPrefix(name, instancePrefix)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(s)
+	return i, err == nil && i >= 0
+}
+
 // CreateNamed returns a new file that a diagnostic can be written to. If this
 // type of diagnostic can be merged, this can be called multiple times with the
 // same type and name and Commit will merge all of the files. The caller must
@@ -21,17 +21,74 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/pprof/profile"
 	"golang.org/x/benchmarks/sweet/common/diagnostics"
+	"golang.org/x/benchmarks/sweet/common/priority"
 )
 
 var (
 	coreDumpDir string
+	psiDir      string
+	cpuFreq     bool
+	schedStats  bool
+	netStats    bool
+	strict      bool
+	cpuLimit    int
+	deadline    time.Duration
 	diag        diagnostics.DriverConfig
+
+	// gomaxprocsSweep is the list of GOMAXPROCS values to run in-process
+	// benchmarks at, if any.
+	gomaxprocsSweep []int
 )
 
 func SetFlags(f *flag.FlagSet) {
 	f.StringVar(&coreDumpDir, "dump-cores", "", "dump a core file to the given directory after every benchmark run")
+	f.StringVar(&psiDir, "psi", "", "sample pressure stall information from the given cgroup directory, or system-wide if \"system\", during every benchmark run")
+	f.BoolVar(&cpuFreq, "cpufreq", false, "sample CPU frequencies and count thermal throttling events during every benchmark run")
+	f.BoolVar(&schedStats, "sched-stats", false, "report the context switches of the benchmark process, and the time its threads waited for a CPU, during every benchmark run")
+	f.BoolVar(&netStats, "net-stats", false, "report TCP retransmits and socket counts, UDP traffic, and the CPU time of clients on this machine, during every benchmark run")
+	f.BoolVar(&strict, "strict", false, "fail on any anomaly in the output of the tools a benchmark runs and parses, rather than warning about it")
+	f.IntVar(&cpuLimit, "cpu-limit", 0, "number of CPUs to cap the parallelism of benchmarks that build code at, such as esbuild and go-build (default no cap)")
+	f.DurationVar(&deadline, "deadline", 0, fmt.Sprintf("wall-clock time after which a benchmark run that hasn't finished dumps all goroutine stacks and its partial diagnostics, then exits with status %d (default no deadline)", DeadlineExitCode))
 	diag.AddFlags(f)
+	f.Func("gomaxprocs", "comma-separated list of GOMAXPROCS values to run in-process benchmarks at, where N is the number of CPUs", parseGOMAXPROCSSweep)
+	f.StringVar(&metricsSpec, "metrics", "", "comma-separated list of metrics to report (default all), where * matches anything, -pattern drops metrics and old=new renames one")
+	f.StringVar(&samplesFile, "samples-file", "", "file to append samples of metrics over intervals of each run to, as lines of JSON")
+	// These take effect as soon as they're parsed, so that they apply to
+	// any servers a benchmark starts before running.
+	f.Func("nice", "CPU niceness to run the benchmark and everything it starts at", func(s string) error {
+		n, err := priority.ParseNice(s)
+		if err != nil {
+			return err
+		}
+		return priority.SetNice(n)
+	})
+	f.Func("ionice", "IO priority, as realtime[:level], best-effort[:level] or idle, to run the benchmark and everything it starts at", func(s string) error {
+		p, err := priority.ParseIO(s)
+		if err != nil {
+			return err
+		}
+		return priority.SetIO(p)
+	})
+}
+
+// parseGOMAXPROCSSweep parses the value of the -gomaxprocs flag.
+func parseGOMAXPROCSSweep(s string) error {
+	var procs []int
+	for _, f := range strings.Split(s, ",") {
+		if f == "N" {
+			procs = append(procs, runtime.NumCPU())
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid GOMAXPROCS value %q", f)
+		}
+		procs = append(procs, n)
+	}
+	gomaxprocsSweep = procs
+	return nil
 }
 
 const (
@@ -93,6 +150,12 @@ func DoMemProfile(v bool) RunOption {
 	}
 }
 
+func DoMutexProfile(v bool) RunOption {
+	return func(b *B) {
+		b.collectDiag[diagnostics.MutexProfile] = v
+	}
+}
+
 func DoPerf(v bool) RunOption {
 	return func(b *B) {
 		b.collectDiag[diagnostics.Perf] = v
@@ -111,6 +174,7 @@ func BenchmarkPID(pid int) RunOption {
 		if pid != os.Getpid() {
 			b.collectDiag[diagnostics.CPUProfile] = false
 			b.collectDiag[diagnostics.MemProfile] = false
+			b.collectDiag[diagnostics.MutexProfile] = false
 			b.collectDiag[diagnostics.Perf] = false
 			b.collectDiag[diagnostics.Trace] = false
 		}
@@ -135,6 +199,24 @@ func WithGOMAXPROCS(procs int) RunOption {
 	}
 }
 
+// WithDeadline sets the wall-clock time a run of the benchmark may take,
+// overriding the -deadline flag. See DeadlineExitCode.
+func WithDeadline(d time.Duration) RunOption {
+	return func(b *B) {
+		b.deadline = d
+	}
+}
+
+// DoGOMAXPROCSSweep indicates whether the benchmark may be run once for
+// each value of the -gomaxprocs flag. Only benchmarks whose work happens
+// in this process are affected by runtime.GOMAXPROCS, so only they
+// should set it.
+func DoGOMAXPROCSSweep(v bool) RunOption {
+	return func(b *B) {
+		b.doSweep = v
+	}
+}
+
 var InProcessMeasurementOptions = []RunOption{
 	DoTime(true),
 	DoPeakRSS(true),
@@ -143,31 +225,58 @@ var InProcessMeasurementOptions = []RunOption{
 	DoCoreDump(true),
 	DoCPUProfile(true),
 	DoMemProfile(true),
+	DoMutexProfile(true),
 	DoPerf(true),
 	DoTrace(true),
+	DoGOMAXPROCSSweep(true),
+	DoRuntimeMetrics(true),
 }
 
 type B struct {
-	ctx           context.Context
-	pid           int
-	name          string
-	start         time.Time
-	dur           time.Duration
-	doTime        bool
-	doPeakRSS     bool
-	doPeakVM      bool
-	doCoreDump    bool
-	gomaxprocs    int
-	collectDiag   map[diagnostics.Type]bool
-	rssFunc       func() (uint64, error)
-	statsMu       sync.Mutex
-	stats         map[string]uint64
-	ops           int
-	wg            sync.WaitGroup
-	resultsWriter io.Writer
+	ctx              context.Context
+	pid              int
+	name             string
+	start            time.Time
+	dur              time.Duration
+	doTime           bool
+	doPeakRSS        bool
+	doPeakVM         bool
+	doCoreDump       bool
+	doCPUFreq        bool
+	doSchedStats     bool
+	doNetStats       bool
+	doClientCPU      bool
+	doSweep          bool
+	doRuntimeMetrics bool
+	gomaxprocs       int
+	cpuLimited       bool // gomaxprocs is the -cpu-limit cap.
+	deadline         time.Duration
+	collectDiag      map[diagnostics.Type]bool
+	rssFunc          func() (uint64, error)
+	psiSources       []psiSource
+	statsMu          sync.Mutex
+	stats            map[string]uint64
+	floatStats       map[string]float64
+	subResults       []*SubResult
+	metricFilters    []MetricFilter
+	ops              int
+	phaseMu          sync.Mutex
+	phaseStart       map[string]time.Time
+	phaseDur         map[string]time.Duration
+	timeline         *runTimeline
+	runStart         time.Time
+	sampleMu         sync.Mutex
+	lastSample       time.Time // End of the interval of the last sample.
+	wg               sync.WaitGroup
+	resultsWriter    io.Writer
+	env              []string    // Environment of the benchmarked process, or nil for this one's.
+	configLines      [][2]string // Extra configuration lines, from WithConfigLine.
 
 	diag        *Diagnostics
 	diagFiles   map[diagnostics.Type]*DiagnosticFile
+	heapBase    *profile.Profile // Heap profile at the start of the measured region, for -memprofile=delta.
+	mutexBase   *profile.Profile // Mutex profile at the start of the run.
+	diagDone    bool             // Diagnostics have been finalized.
 	perfProcess *os.Process
 }
 
@@ -179,8 +288,11 @@ func newB(name string) *B {
 			diagnostics.CPUProfile: false,
 			diagnostics.MemProfile: false,
 		},
-		stats: make(map[string]uint64),
-		ops:   1,
+		stats:      make(map[string]uint64),
+		floatStats: make(map[string]float64),
+		ops:        1,
+		phaseStart: make(map[string]time.Time),
+		phaseDur:   make(map[string]time.Duration),
 
 		diag:      NewDiagnostics(name),
 		diagFiles: make(map[diagnostics.Type]*DiagnosticFile),
@@ -220,10 +332,14 @@ func (b *B) StartTimer() {
 		}
 	}
 
+	if b.heapBase == nil {
+		b.snapshotHeap()
+	}
 	b.start = time.Now()
 }
 
 func (b *B) ResetTimer() {
+	b.snapshotHeap()
 	if df := b.diagFiles[diagnostics.CPUProfile]; df != nil {
 		pprof.StopCPUProfile()
 		if err := b.truncateDiagnosticData(df); err != nil {
@@ -282,10 +398,64 @@ func (b *B) Elapsed() time.Duration {
 	return b.dur
 }
 
+// StartPhase marks the beginning of the named phase of the benchmark.
+//
+// Phases measure wall-clock time independently of the benchmark timer,
+// may overlap, and may be entered more than once, in which case their
+// durations accumulate. The total time spent in each phase is reported
+// as a "<phase>-ns" metric.
+func (b *B) StartPhase(name string) {
+	now := time.Now()
+	b.phaseMu.Lock()
+	defer b.phaseMu.Unlock()
+	if _, ok := b.phaseStart[name]; ok {
+		panic("starting already-started phase " + name)
+	}
+	b.phaseStart[name] = now
+}
+
+// EndPhase marks the end of the named phase, which must have been
+// started with StartPhase.
+func (b *B) EndPhase(name string) {
+	end := time.Now()
+	b.phaseMu.Lock()
+	defer b.phaseMu.Unlock()
+	start, ok := b.phaseStart[name]
+	if !ok {
+		panic("ending unstarted phase " + name)
+	}
+	delete(b.phaseStart, name)
+	b.phaseDur[name] += end.Sub(start)
+}
+
+// reportPhases ends any phases still running and records the
+// duration of every phase as a stat.
+func (b *B) reportPhases() {
+	b.phaseMu.Lock()
+	var running []string
+	for name := range b.phaseStart {
+		running = append(running, name)
+	}
+	b.phaseMu.Unlock()
+	for _, name := range running {
+		warningf("phase %s never ended", name)
+		b.EndPhase(name)
+	}
+	for name, dur := range b.phaseDur {
+		b.setStat(name+"-ns", uint64(dur.Nanoseconds()))
+	}
+}
+
 func (b *B) Report(name string, value uint64) {
 	b.stats[name] = value
 }
 
+// ReportFloat records the value of the metric name, for metrics such as
+// rates that an integer would truncate.
+func (b *B) ReportFloat(name string, value float64) {
+	b.floatStats[name] = value
+}
+
 func (b *B) Ops(ops int) {
 	b.ops = ops
 }
@@ -343,20 +513,57 @@ func splitName(s string) []string {
 	return comps
 }
 
+// fullName returns the name of the benchmark, or with sub, of its
+// sub-result sub, as it appears in the results, minus the "Benchmark"
+// prefix. Like go test, it puts the GOMAXPROCS suffix last.
+func (b *B) fullName(sub string) string {
+	name := b.name
+	if sub != "" {
+		name += "/" + sub
+	}
+	if b.gomaxprocs > 1 || b.cpuLimited {
+		return fmt.Sprintf("%s-%d", name, b.gomaxprocs)
+	}
+	return name
+}
+
 func (b *B) report() {
 	b.statsMu.Lock()
 	defer b.statsMu.Unlock()
 
-	// Collect all names of non-zero stats.
-	names := make([]string, 0, len(b.stats))
-	for name, value := range b.stats {
-		if value != 0 {
-			names = append(names, name)
+	var out io.Writer = os.Stdout
+	if b.resultsWriter != nil {
+		out = b.resultsWriter
+	}
+	writeRuntimeEnvConfig(out, b.env, b.configLines)
+	if b.timeline != nil {
+		b.timeline.writeComment(out)
+	}
+	reported := writeResult(out, b.fullName(""), b.ops, b.reportedStats(), b.reportedFloatStats())
+	for _, s := range b.subResults {
+		if writeResult(out, b.fullName(s.name), s.ops, s.reportedStats(b), nil) {
+			reported = true
 		}
 	}
-	if len(names) == 0 {
+	if !reported {
 		fmt.Fprintln(os.Stderr, "# No benchmark results found for this run.")
-		return
+	}
+}
+
+// writeResult writes a benchmark result line for the benchmark name, with
+// ops iterations and the metrics in stats and floats, to out, unless there
+// are no metrics. It reports whether it wrote anything.
+func writeResult(out io.Writer, name string, ops int, stats map[string]uint64, floats map[string]float64) bool {
+	// Collect all names of non-zero stats that are to be reported.
+	names := make([]string, 0, len(stats)+len(floats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	for name := range floats {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return false
 	}
 	namesToComps := make(map[string][]string)
 	for _, n := range names {
@@ -387,22 +594,30 @@ func (b *B) report() {
 	})
 
 	// Write out stats.
-	var out io.Writer = os.Stdout
-	if b.resultsWriter != nil {
-		out = b.resultsWriter
-	}
-	suffix := ""
-	if b.gomaxprocs > 1 {
-		suffix = fmt.Sprintf("-%d", b.gomaxprocs)
-	}
-	fmt.Fprintf(out, "Benchmark%s%s %d", b.name, suffix, b.ops)
+	fmt.Fprintf(out, "Benchmark%s %d", name, ops)
 	for _, name := range names {
-		value := b.stats[name]
-		if value != 0 {
-			fmt.Fprintf(out, " %d %s", value, name)
+		if v, ok := floats[name]; ok {
+			fmt.Fprintf(out, " %s %s", strconv.FormatFloat(v, 'f', -1, 64), name)
+		} else {
+			fmt.Fprintf(out, " %d %s", stats[name], name)
 		}
 	}
 	fmt.Fprintln(out)
+	return true
+}
+
+// Anomalyf reports something unexpected in the output of a tool that a
+// benchmark runs and parses, such as an unexpected number of columns or a
+// zero where there should be a measurement. Usually it only warns about it
+// and returns nil, but with -strict, it returns it as an error, which the
+// benchmark should fail with, so that a parser that has fallen behind
+// changes to a tool's output format can't go unnoticed.
+func Anomalyf(format string, args ...interface{}) error {
+	if strict {
+		return fmt.Errorf("anomaly in benchmark output: "+format, args...)
+	}
+	warningf("anomaly in benchmark output: "+format, args...)
+	return nil
 }
 
 func warningf(format string, args ...interface{}) {
@@ -457,22 +672,117 @@ func (b *B) stopPerf() error {
 	return err
 }
 
+// commitPartialDiagnostics stops the diagnostics that b collects itself and
+// commits whatever data they hold so far, marked as partial.
+func (b *B) commitPartialDiagnostics() {
+	if b.diagDone {
+		return
+	}
+	trace.Stop()
+	pprof.StopCPUProfile()
+	if b.perfProcess != nil {
+		if err := b.stopPerf(); err != nil {
+			warningf("failed to stop perf: %v", err)
+		}
+	}
+	for _, df := range b.diagFiles {
+		df.Close()
+		df.Commit()
+	}
+	b.diag.commitPartial(nil)
+}
+
+// RunBenchmark runs f as the benchmark name and reports its results.
+//
+// If the -gomaxprocs flag is set and the benchmark allows it with
+// DoGOMAXPROCSSweep, RunBenchmark instead runs f once for each value,
+// with runtime.GOMAXPROCS set accordingly, and reports each run under
+// the name suffixed with the value.
 func RunBenchmark(name string, f func(*B) error, opts ...RunOption) error {
+	if len(gomaxprocsSweep) == 0 {
+		return runBenchmark(name, f, opts...)
+	}
+	b := newB(name)
+	for _, opt := range opts {
+		opt(b)
+	}
+	if !b.doSweep || b.gomaxprocs != 0 {
+		warningf("benchmark %s does not support a GOMAXPROCS sweep; running it once", name)
+		return runBenchmark(name, f, opts...)
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(-1))
+	for _, procs := range gomaxprocsSweep {
+		runtime.GOMAXPROCS(procs)
+		if err := runBenchmark(name, f, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runBenchmark(name string, f func(*B) error, opts ...RunOption) error {
 	// Create a B and populate it with options.
 	b := newB(name)
 	for _, opt := range opts {
 		opt(b)
 	}
+	if metricsSpec != "" {
+		mf, err := ParseMetricFilter(metricsSpec)
+		if err != nil {
+			return err
+		}
+		b.metricFilters = append(b.metricFilters, mf)
+	}
+
+	// If the benchmark panics, don't leave diagnostics uncommitted and
+	// perf running. Keep what they collected for debugging the crash,
+	// then carry on panicking.
+	defer func() {
+		if r := recover(); r != nil {
+			warningf("benchmark panicked; committing partial diagnostics")
+			b.commitPartialDiagnostics()
+			panic(r)
+		}
+	}()
+
+	// Sample pressure stall information if requested on the command
+	// line and the benchmark didn't ask for it explicitly.
+	if b.psiSources == nil {
+		switch psiDir {
+		case "":
+		case "system":
+			DoSystemPSI(true)(b)
+		default:
+			DoCgroupPSI(psiDir)(b)
+		}
+	}
+
+	if cpuFreq {
+		b.doCPUFreq = true
+	}
+	if schedStats {
+		DoSchedStats(true)(b)
+	}
+	if netStats {
+		DoNetStats(true)(b)
+	}
 
 	// Make sure gomaxprocs is set.
 	if b.gomaxprocs == 0 {
 		b.gomaxprocs = runtime.GOMAXPROCS(-1)
 	}
 
-	// Start the RSS sampler and start the timer.
+	// Start the RSS, PSI, CPU frequency, network and runtime metrics samplers and
+	// start the timer.
 	stop := b.startRSSSampler()
-
-	// Collect trace diagnostics regardless of the timer state.
+	stopPSI := b.startPSISampler()
+	stopCPUFreq := b.startCPUFreqSampler()
+	stopSchedStats := b.startSchedStats()
+	stopNetStats := b.startNetStatsSampler()
+	stopRuntimeMetrics := b.startRuntimeMetricsSampler()
+
+	// Collect mutex and trace diagnostics regardless of the timer state.
+	defer b.startMutexProfile()()
 	if typ := diagnostics.Trace; b.collectDiag[typ] {
 		if df, err := b.diag.Create(typ); err != nil {
 			warningf("failed to create %s diagnostics: %s", typ, err)
@@ -485,6 +795,16 @@ func RunBenchmark(name string, f func(*B) error, opts ...RunOption) error {
 		}
 	}
 
+	// Watch for the run wedging, now that there are diagnostics to dump.
+	if b.deadline == 0 {
+		b.deadline = deadline
+	}
+	if b.deadline > 0 {
+		t := time.AfterFunc(b.deadline, b.deadlineExceeded)
+		defer t.Stop()
+	}
+
+	b.runStart = time.Now()
 	b.StartTimer()
 
 	// Run the benchmark itself.
@@ -494,11 +814,31 @@ func RunBenchmark(name string, f func(*B) error, opts ...RunOption) error {
 	if b.TimerRunning() {
 		b.StopTimer()
 	}
+	b.timeline = newRunTimeline(b.fullName(""), b.runStart, time.Now(), b.dur)
+	if err := b.timeline.appendToDiagnostics(); err != nil {
+		warningf("failed to write run timeline: %v", err)
+	}
+	b.reportPhases()
 
-	// Stop the RSS sampler.
+	// Stop the RSS, PSI, CPU frequency, network and runtime metrics samplers.
 	if stop != nil {
 		stop <- struct{}{}
 	}
+	if stopPSI != nil {
+		stopPSI <- struct{}{}
+	}
+	if stopCPUFreq != nil {
+		stopCPUFreq <- struct{}{}
+	}
+	if stopSchedStats != nil {
+		stopSchedStats()
+	}
+	if stopNetStats != nil {
+		stopNetStats <- struct{}{}
+	}
+	if stopRuntimeMetrics != nil {
+		stopRuntimeMetrics <- struct{}{}
+	}
 
 	if b.doPeakRSS {
 		v, err := ReadPeakRSS(b.pid)
@@ -547,7 +887,19 @@ func RunBenchmark(name string, f func(*B) error, opts ...RunOption) error {
 		if df, err := b.diag.Create(typ); err != nil {
 			warningf("failed to create %s diagnostics: %s", typ, err)
 		} else if df != nil {
-			if err := pprof.Lookup("heap").WriteTo(df, 0); err != nil {
+			if err := b.writeMemProfile(df); err != nil {
+				return err
+			}
+			b.diagFiles[typ] = df
+		}
+	}
+
+	// Collect mutex profile.
+	if typ := diagnostics.MutexProfile; b.collectDiag[typ] {
+		if df, err := b.diag.Create(typ); err != nil {
+			warningf("failed to create %s diagnostics: %s", typ, err)
+		} else if df != nil {
+			if err := b.writeMutexProfile(df); err != nil {
 				return err
 			}
 			b.diagFiles[typ] = df
@@ -555,6 +907,7 @@ func RunBenchmark(name string, f func(*B) error, opts ...RunOption) error {
 	}
 
 	// Finalize all diagnostics.
+	b.diagDone = true
 	for typ, df := range b.diagFiles {
 		if typ == diagnostics.Trace {
 			trace.Stop()
@@ -569,6 +922,23 @@ func RunBenchmark(name string, f func(*B) error, opts ...RunOption) error {
 	return nil
 }
 
+// writeMemProfile writes the heap profile to w, less the allocations made
+// before the measured region if b took a snapshot of the heap then.
+func (b *B) writeMemProfile(w io.Writer) error {
+	if b.heapBase == nil {
+		return pprof.Lookup("heap").WriteTo(w, 0)
+	}
+	end, err := readHeapProfile()
+	if err != nil {
+		return err
+	}
+	p, err := profileDelta(b.heapBase, end)
+	if err != nil {
+		return err
+	}
+	return p.Write(w)
+}
+
 func DiagnosticEnabled(typ diagnostics.Type) bool {
 	_, ok := diag.ConfigSet.Get(typ)
 	return ok
@@ -581,3 +951,24 @@ func PerfFlags() []string {
 	}
 	return strings.Split(cfg.Flags, " ")
 }
+
+// CPULimit returns the number of CPUs to which benchmarks that build code
+// in parallel should cap themselves, as given by the -cpu-limit flag, or 0
+// if they shouldn't. Such benchmarks should also run with WithCPULimit, so
+// that the cap shows in their results.
+func CPULimit() int {
+	return cpuLimit
+}
+
+// WithCPULimit names the results of a benchmark that caps itself at
+// CPULimit for the cap, rather than for this process's GOMAXPROCS, if
+// there is a cap. Unlike a GOMAXPROCS of 1, a cap of 1 shows in the name,
+// so that capped results are never compared with uncapped ones.
+func WithCPULimit() RunOption {
+	return func(b *B) {
+		if cpuLimit > 0 {
+			b.gomaxprocs = cpuLimit
+			b.cpuLimited = true
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/google/pprof/profile"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
+)
+
+// memProfileDelta reports whether the MemProfile diagnostic should only
+// cover the measured region of the benchmark.
+func memProfileDelta() bool {
+	cfg, ok := diag.ConfigSet.Get(diagnostics.MemProfile)
+	return ok && cfg.Flags == diagnostics.MemProfileDelta
+}
+
+// readHeapProfile returns the current heap profile, after a GC so that it
+// is up to date, as 'go test -memprofile' does.
+func readHeapProfile() (*profile.Profile, error) {
+	runtime.GC()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	return profile.Parse(&buf)
+}
+
+// snapshotHeap records the heap profile at the start of the measured region,
+// if a delta memory profile is to be collected.
+func (b *B) snapshotHeap() {
+	if !b.collectDiag[diagnostics.MemProfile] || !memProfileDelta() {
+		return
+	}
+	p, err := readHeapProfile()
+	if err != nil {
+		warningf("failed to snapshot heap profile; the memory profile will include setup: %v", err)
+		return
+	}
+	b.heapBase = p
+}
+
+// profileDelta returns the profile end with the samples of base
+// subtracted, leaving what was recorded in between. For a heap profile,
+// that is the allocations made in between, and the in-use values are the
+// change in the heap over that time, so may be negative.
+func profileDelta(base, end *profile.Profile) (*profile.Profile, error) {
+	base = base.Copy()
+	base.Scale(-1)
+	p, err := profile.Merge([]*profile.Profile{end, base})
+	if err != nil {
+		return nil, err
+	}
+	p.TimeNanos = base.TimeNanos
+	p.DurationNanos = end.TimeNanos - base.TimeNanos
+	return p, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// metricsSpec is the value of the -metrics flag.
+var metricsSpec string
+
+// MetricFilter selects which metrics a benchmark reports, and under what
+// names. Patterns are metric names in which '*' matches any sequence of
+// characters, including none.
+type MetricFilter struct {
+	// Keep lists the patterns of the metrics to report. If it is empty,
+	// all metrics are reported, except those matching Drop.
+	Keep []string
+
+	// Drop lists the patterns of metrics not to report.
+	Drop []string
+
+	// Rename maps metric names to the names to report them under.
+	// Renamed metrics are reported even if they don't match Keep, and
+	// in place of any other metric already reported under the new name.
+	Rename map[string]string
+}
+
+// ParseMetricFilter parses a MetricFilter from a comma-separated list of
+// entries, each of which is a pattern of metrics to keep, a pattern of
+// metrics to drop prefixed with '-', or a rename of the form old=new,
+// as in "ns/op,*-latency-ns,p100-latency-ns=max-latency-ns". No two
+// metrics may be renamed to the same name.
+func ParseMetricFilter(s string) (MetricFilter, error) {
+	var f MetricFilter
+	renamed := make(map[string]string)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if old, new, ok := strings.Cut(e, "="); ok {
+			if old == "" || new == "" || strings.Contains(old, "*") {
+				return MetricFilter{}, fmt.Errorf("invalid metric rename %q, want old=new", e)
+			}
+			if prev, ok := renamed[new]; ok && prev != old {
+				return MetricFilter{}, fmt.Errorf("metrics %s and %s are both renamed to %s", prev, old, new)
+			}
+			renamed[new] = old
+			if f.Rename == nil {
+				f.Rename = make(map[string]string)
+			}
+			f.Rename[old] = new
+		} else if pat, ok := strings.CutPrefix(e, "-"); ok {
+			f.Drop = append(f.Drop, pat)
+		} else {
+			f.Keep = append(f.Keep, e)
+		}
+	}
+	return f, nil
+}
+
+// apply returns the name to report the metric name under, and whether to
+// report it at all.
+func (f *MetricFilter) apply(name string) (string, bool) {
+	if new, ok := f.Rename[name]; ok {
+		return new, true
+	}
+	for _, pat := range f.Drop {
+		if matchMetric(pat, name) {
+			return "", false
+		}
+	}
+	if len(f.Keep) == 0 {
+		return name, true
+	}
+	for _, pat := range f.Keep {
+		if matchMetric(pat, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// matchMetric reports whether name matches pat, in which '*' matches any
+// sequence of characters.
+func matchMetric(pat, name string) bool {
+	parts := strings.Split(pat, "*")
+	if len(parts) == 1 {
+		return pat == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, p)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(p):]
+	}
+	return strings.HasSuffix(name, last)
+}
+
+// WithMetricFilter filters and renames the metrics the benchmark reports
+// with f, before any filter given by the -metrics flag.
+func WithMetricFilter(f MetricFilter) RunOption {
+	return func(b *B) {
+		b.metricFilters = append(b.metricFilters, f)
+	}
+}
+
+// reportedStats returns the non-zero stats of b under the names they are
+// to be reported as.
+func (b *B) reportedStats() map[string]uint64 {
+	return b.filterStats(b.stats)
+}
+
+// reportedFloatStats returns the non-zero floating-point stats of b under
+// the names they are to be reported as.
+func (b *B) reportedFloatStats() map[string]float64 {
+	nonZero := make(map[string]float64, len(b.floatStats))
+	for name, value := range b.floatStats {
+		if value != 0 {
+			nonZero[name] = value
+		}
+	}
+	return filterMetrics(b, nonZero, true)
+}
+
+// filterStats returns the non-zero stats in all under the names they are
+// to be reported as, after b's metric filters.
+func (b *B) filterStats(all map[string]uint64) map[string]uint64 {
+	nonZero := make(map[string]uint64, len(all))
+	for name, value := range all {
+		if value != 0 {
+			nonZero[name] = value
+		}
+	}
+	return filterMetrics(b, nonZero, true)
+}
+
+// filterMetrics returns the metrics in all under the names they are to be
+// reported as, after b's metric filters. If several metrics end up with
+// the same name, it reports the one that was renamed to it, or the first
+// by name if all were, and if warn is set, warns about the others.
+func filterMetrics[V any](b *B, all map[string]V, warn bool) map[string]V {
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make(map[string]V, len(all))
+	from := make(map[string]string, len(all))
+	for _, name := range names {
+		as, ok := b.filterMetric(name)
+		if !ok {
+			continue
+		}
+		if prev, dup := from[as]; dup {
+			drop := name
+			if prev == as && name != as {
+				drop = prev
+			}
+			if warn {
+				warningf("metrics %s and %s are both reported as %s; dropping %s", prev, name, as, drop)
+			}
+			if drop == name {
+				continue
+			}
+		}
+		metrics[as] = all[name]
+		from[as] = name
+	}
+	return metrics
+}
+
+// filterMetric applies b's metric filters to the metric name, returning
+// the name to report it under and whether to report it at all.
+func (b *B) filterMetric(name string) (string, bool) {
+	ok := true
+	for i := range b.metricFilters {
+		if name, ok = b.metricFilters[i].apply(name); !ok {
+			break
+		}
+	}
+	return name, ok
+}
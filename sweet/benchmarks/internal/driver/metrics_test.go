@@ -0,0 +1,140 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestMatchMetric(t *testing.T) {
+	for _, tc := range []struct {
+		pat, name string
+		want      bool
+	}{
+		{"ns/op", "ns/op", true},
+		{"ns/op", "ns/ops", false},
+		{"*", "", true},
+		{"*", "ns/op", true},
+		{"*-latency-ns", "p99-latency-ns", true},
+		{"*-latency-ns", "latency-ns", false},
+		{"p*", "p50-latency-ns", true},
+		{"p*-ns", "peak-RSS-bytes", false},
+		{"p*latency*ns", "p50-apply-latency-ns", true},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "acb", false},
+		// The middle parts may not overlap the prefix or suffix.
+		{"ab*ba", "aba", false},
+		{"a*a*a", "aa", false},
+	} {
+		if got := matchMetric(tc.pat, tc.name); got != tc.want {
+			t.Errorf("matchMetric(%q, %q) = %v, want %v", tc.pat, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseMetricFilter(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    MetricFilter
+		wantErr bool
+	}{
+		{in: "", want: MetricFilter{}},
+		{
+			in: "ns/op, *-latency-ns,-p100-latency-ns,p99-latency-ns=tail-latency-ns,",
+			want: MetricFilter{
+				Keep:   []string{"ns/op", "*-latency-ns"},
+				Drop:   []string{"p100-latency-ns"},
+				Rename: map[string]string{"p99-latency-ns": "tail-latency-ns"},
+			},
+		},
+		{in: "=new", wantErr: true},
+		{in: "old=", wantErr: true},
+		{in: "p*-latency-ns=latency-ns", wantErr: true},
+		{in: "p50-latency-ns=latency-ns,p99-latency-ns=latency-ns", wantErr: true},
+	} {
+		got, err := ParseMetricFilter(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseMetricFilter(%q) = %+v, want error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMetricFilter(%q): %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseMetricFilter(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFilterStats(t *testing.T) {
+	all := map[string]uint64{
+		"ns/op":           100,
+		"peak-RSS-bytes":  1 << 20,
+		"p50-latency-ns":  10,
+		"p99-latency-ns":  50,
+		"p100-latency-ns": 90,
+		"max-latency-ns":  80,
+		"gc-cycles":       0,
+	}
+	for _, tc := range []struct {
+		spec string
+		want map[string]uint64
+	}{
+		{"", map[string]uint64{
+			"ns/op":           100,
+			"peak-RSS-bytes":  1 << 20,
+			"p50-latency-ns":  10,
+			"p99-latency-ns":  50,
+			"p100-latency-ns": 90,
+			"max-latency-ns":  80,
+		}},
+		{"ns/op,*-latency-ns,-p100-latency-ns", map[string]uint64{
+			"ns/op":          100,
+			"p50-latency-ns": 10,
+			"p99-latency-ns": 50,
+			"max-latency-ns": 80,
+		}},
+		// A renamed metric is reported without being kept.
+		{"ns/op,p99-latency-ns=tail-latency-ns", map[string]uint64{
+			"ns/op":           100,
+			"tail-latency-ns": 50,
+		}},
+		// A rename onto a reported metric replaces it.
+		{"p100-latency-ns=max-latency-ns", map[string]uint64{
+			"ns/op":          100,
+			"peak-RSS-bytes": 1 << 20,
+			"p50-latency-ns": 10,
+			"p99-latency-ns": 50,
+			"max-latency-ns": 90,
+		}},
+	} {
+		f, err := ParseMetricFilter(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := newB("Test")
+		b.metricFilters = []MetricFilter{f}
+		if got := b.filterStats(all); !maps.Equal(got, tc.want) {
+			t.Errorf("with filter %q, got %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+
+	// Renames by two filters onto the same name keep the first metric
+	// by name.
+	b := newB("Test")
+	b.metricFilters = []MetricFilter{
+		{Rename: map[string]string{"p99-latency-ns": "tail-latency-ns"}},
+		{Rename: map[string]string{"p100-latency-ns": "tail-latency-ns"}},
+	}
+	got := b.filterStats(map[string]uint64{"p99-latency-ns": 50, "p100-latency-ns": 90})
+	if want := map[string]uint64{"tail-latency-ns": 90}; !maps.Equal(got, want) {
+		t.Errorf("with two renames, got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/google/pprof/profile"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
+	sprofile "golang.org/x/benchmarks/sweet/common/profile"
+)
+
+const statMutexContention = "mutex-contention-ns"
+
+// mutexProfileFraction is the rate at which contention events are sampled
+// while a mutex profile is collected; like 'go test -mutexprofile', it
+// records every one.
+const mutexProfileFraction = 1
+
+// readMutexProfile returns the contention recorded so far.
+func readMutexProfile() (*profile.Profile, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("mutex").WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	return profile.Parse(&buf)
+}
+
+// startMutexProfile starts recording contended mutexes, if a mutex profile
+// is to be collected, and snapshots the contention recorded so far, so that
+// the profile only covers this run. It returns a function that restores the
+// earlier sampling rate.
+func (b *B) startMutexProfile() func() {
+	if !b.collectDiag[diagnostics.MutexProfile] || !DiagnosticEnabled(diagnostics.MutexProfile) {
+		return func() {}
+	}
+	old := runtime.SetMutexProfileFraction(mutexProfileFraction)
+	p, err := readMutexProfile()
+	if err != nil {
+		warningf("failed to snapshot mutex profile; it will include earlier runs: %v", err)
+	} else {
+		b.mutexBase = p
+	}
+	return func() {
+		runtime.SetMutexProfileFraction(old)
+	}
+}
+
+// writeMutexProfile writes the mutex profile to w, less the contention
+// recorded before the run if b took a snapshot then.
+func (b *B) writeMutexProfile(w io.Writer) error {
+	if b.mutexBase == nil {
+		return pprof.Lookup("mutex").WriteTo(w, 0)
+	}
+	end, err := readMutexProfile()
+	if err != nil {
+		return err
+	}
+	p, err := profileDelta(b.mutexBase, end)
+	if err != nil {
+		return err
+	}
+	return p.Write(w)
+}
+
+// mutexStats summarizes one or more mutex profiles.
+type mutexStats struct {
+	delay     uint64           // Total time goroutines spent blocked on contended locks, in ns.
+	siteDelay map[string]int64 // Delay by call site.
+}
+
+// report reports the total contention, and logs the call site with the
+// most of it, which is no metric, so that benchstat doesn't treat it as one.
+func (s *mutexStats) report(b *B) {
+	b.Report(statMutexContention, s.delay)
+	if site, delay := s.topSite(); site != "" {
+		fmt.Fprintf(os.Stderr, "%s: top contended call site: %s (%d ns of %d)\n", b.name, site, delay, s.delay)
+	}
+}
+
+// topSite returns the call site with the most delay, and its delay.
+func (s *mutexStats) topSite() (site string, delay int64) {
+	for k, d := range s.siteDelay {
+		if d > delay || (d == delay && k < site) {
+			site, delay = k, d
+		}
+	}
+	return site, delay
+}
+
+// readMutexStats computes the combined statistics of the mutex profiles
+// at paths.
+func readMutexStats(paths []string) (*mutexStats, error) {
+	stats := &mutexStats{siteDelay: make(map[string]int64)}
+	for _, path := range paths {
+		p, err := sprofile.ReadPprof(path)
+		if err != nil {
+			return nil, err
+		}
+		delayIdx := -1
+		for i, st := range p.SampleType {
+			if st.Type == "delay" {
+				delayIdx = i
+			}
+		}
+		if delayIdx < 0 {
+			return nil, fmt.Errorf("%s: no delay samples in mutex profile", path)
+		}
+		for _, s := range p.Sample {
+			d := s.Value[delayIdx]
+			if d <= 0 {
+				// Negative after subtracting earlier runs'
+				// contention, which can't have grown.
+				continue
+			}
+			stats.delay += uint64(d)
+			stats.siteDelay[mutexCallSite(s)] += d
+		}
+	}
+	return stats, nil
+}
+
+// mutexCallSite returns the function that unlocked a contended lock: the
+// innermost one in the sample's stack outside the runtime and sync
+// packages, which only implement the locking.
+func mutexCallSite(s *profile.Sample) string {
+	for _, loc := range s.Location {
+		// Inlined calls come first.
+		for _, line := range loc.Line {
+			if line.Function == nil {
+				continue
+			}
+			name := line.Function.Name
+			if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "sync.") || strings.HasPrefix(name, "internal/sync.") {
+				continue
+			}
+			return name
+		}
+	}
+	return ""
+}
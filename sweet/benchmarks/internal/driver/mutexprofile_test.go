@@ -0,0 +1,114 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	sprofile "golang.org/x/benchmarks/sweet/common/profile"
+)
+
+// mutexSample returns a mutex profile sample with delay ns of contention,
+// whose stack has the functions in funcs, innermost first, with the
+// functions of each group inlined into one location.
+func mutexSample(delay int64, funcs ...[]string) *profile.Sample {
+	s := &profile.Sample{Value: []int64{1, delay}}
+	for _, group := range funcs {
+		loc := &profile.Location{ID: uint64(len(s.Location) + 1)}
+		for _, name := range group {
+			loc.Line = append(loc.Line, profile.Line{Function: &profile.Function{Name: name}})
+		}
+		s.Location = append(s.Location, loc)
+	}
+	return s
+}
+
+func TestMutexCallSite(t *testing.T) {
+	for _, tc := range []struct {
+		funcs [][]string
+		want  string
+	}{
+		{[][]string{{"sync.(*Mutex).Unlock"}, {"main.(*cache).put"}, {"main.worker"}}, "main.(*cache).put"},
+		{[][]string{{"internal/sync.(*Mutex).Unlock", "sync.(*Mutex).Unlock"}, {"runtime.unlock"}, {"main.worker"}}, "main.worker"},
+		{[][]string{{"sync.(*RWMutex).Unlock", "main.(*cache).put"}, {"main.worker"}}, "main.(*cache).put"},
+		{[][]string{{"runtime.unlock"}, {"runtime.goexit"}}, ""},
+	} {
+		if got := mutexCallSite(mutexSample(1, tc.funcs...)); got != tc.want {
+			t.Errorf("mutexCallSite(%v) = %q, want %q", tc.funcs, got, tc.want)
+		}
+	}
+}
+
+func TestTopSite(t *testing.T) {
+	for _, tc := range []struct {
+		siteDelay map[string]int64
+		site      string
+		delay     int64
+	}{
+		{nil, "", 0},
+		{map[string]int64{"a": 10, "b": 30, "c": 20}, "b", 30},
+		{map[string]int64{"b": 30, "a": 30}, "a", 30},
+	} {
+		s := &mutexStats{siteDelay: tc.siteDelay}
+		if site, delay := s.topSite(); site != tc.site || delay != tc.delay {
+			t.Errorf("topSite of %v = %q, %d; want %q, %d", tc.siteDelay, site, delay, tc.site, tc.delay)
+		}
+	}
+}
+
+func TestReadMutexStats(t *testing.T) {
+	sampleTypes := []*profile.ValueType{{Type: "contentions", Unit: "count"}, {Type: "delay", Unit: "nanoseconds"}}
+	write := func(name string, samples ...*profile.Sample) string {
+		p := &profile.Profile{SampleType: sampleTypes, PeriodType: sampleTypes[0], Period: 1}
+		for _, s := range samples {
+			p.Sample = append(p.Sample, s)
+			for _, loc := range s.Location {
+				loc.ID = uint64(len(p.Location) + 1)
+				p.Location = append(p.Location, loc)
+				for i := range loc.Line {
+					fn := loc.Line[i].Function
+					fn.ID = uint64(len(p.Function) + 1)
+					p.Function = append(p.Function, fn)
+				}
+			}
+		}
+		path := filepath.Join(t.TempDir(), name)
+		if err := sprofile.WritePprof(path, p); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	unlock := []string{"sync.(*Mutex).Unlock"}
+	paths := []string{
+		write("a.prof",
+			mutexSample(100, unlock, []string{"main.put"}),
+			mutexSample(50, unlock, []string{"main.get"}),
+			// Less than nothing, after subtracting an earlier run.
+			mutexSample(-20, unlock, []string{"main.get"}),
+		),
+		write("b.prof", mutexSample(80, unlock, []string{"main.get"})),
+	}
+	stats, err := readMutexStats(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.delay != 230 {
+		t.Errorf("got total delay %d, want 230", stats.delay)
+	}
+	if site, delay := stats.topSite(); site != "main.get" || delay != 130 {
+		t.Errorf("got top site %q with delay %d, want main.get with 130", site, delay)
+	}
+
+	cpu := filepath.Join(t.TempDir(), "cpu.prof")
+	p := &profile.Profile{SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}}}
+	if err := sprofile.WritePprof(cpu, p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMutexStats([]string{cpu}); err == nil {
+		t.Errorf("readMutexStats of a CPU profile succeeded, want error")
+	}
+}
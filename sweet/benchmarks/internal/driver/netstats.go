@@ -0,0 +1,218 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statTCPRetransmits   = "tcp-retransmits"
+	statTCPSocketsPeak   = "tcp-sockets-peak"
+	statTCPTimeWait      = "tcp-time-wait-sockets"
+	statUDPDatagrams     = "udp-datagrams"
+	statUDPErrors        = "udp-errors"
+	statClientUserTime   = "client-user-ns"
+	statClientSystemTime = "client-sys-ns"
+)
+
+// tcpSockets counts the TCP sockets on the machine, by state.
+type tcpSockets struct {
+	inUse    uint64 // Sockets not yet closed, including listeners.
+	timeWait uint64 // Closed sockets in TIME_WAIT.
+}
+
+// netCounters are the cumulative network counters of the machine.
+type netCounters struct {
+	tcpRetransmits uint64 // TCP segments retransmitted.
+	udpDatagrams   uint64 // UDP datagrams sent and received.
+	udpErrors      uint64 // UDP datagrams dropped on receipt.
+}
+
+// DoNetStats reports, where Linux makes them available, how many TCP
+// segments were retransmitted during the run, the peak number of TCP
+// sockets in use (sampled every 100ms), how many sockets were left in
+// TIME_WAIT at the end of it, and how many UDP datagrams, which is how
+// DNS lookups go, were sent, received and dropped. These count every
+// process in the network namespace, not just the benchmark, which is the
+// point: a run whose loopback traffic was retransmitted, that ran out of
+// ports, or that was waiting on name lookups was measuring the network
+// stack rather than the server.
+func DoNetStats(v bool) RunOption {
+	return func(b *B) {
+		b.doNetStats = v
+	}
+}
+
+// DoClientCPU additionally reports, along with the network statistics,
+// the user and system CPU time this process spent during the run, for
+// benchmarks whose clients run in the driver process while the server
+// runs in its own. It tells apart a regression in the server from one in
+// the clients driving it. Benchmarks whose clients run in a process of
+// their own use ReportClientCPU instead.
+func DoClientCPU(v bool) RunOption {
+	return func(b *B) {
+		b.doClientCPU = v
+	}
+}
+
+// ReportClientCPU reports, along with the network statistics, the user and
+// system CPU time of a client that ran during the run as a process of its
+// own on this machine, which ended with ps.
+func (b *B) ReportClientCPU(ps *os.ProcessState) {
+	if !b.doNetStats || ps == nil {
+		return
+	}
+	b.setStat(statClientUserTime, uint64(ps.UserTime()))
+	b.setStat(statClientSystemTime, uint64(ps.SystemTime()))
+}
+
+// startNetStatsSampler starts sampling the TCP statistics of the machine,
+// and returns a channel to send on to stop it and report them, or nil if
+// they aren't being collected.
+func (b *B) startNetStatsSampler() chan<- struct{} {
+	if !b.doNetStats {
+		return nil
+	}
+	start, err := readNetCounters()
+	if err != nil {
+		warningf("failed to read network statistics: %v", err)
+		return nil
+	}
+	var userStart, sysStart time.Duration
+	haveClientCPU := b.doClientCPU
+	if haveClientCPU {
+		if userStart, sysStart, err = readSelfCPUTime(); err != nil {
+			warningf("failed to read client CPU time: %v", err)
+			haveClientCPU = false
+		}
+	}
+	stop := make(chan struct{})
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		var peak uint64
+		var last tcpSockets
+		sample := func() {
+			s, err := readTCPSockets()
+			if err != nil {
+				return
+			}
+			peak = max(peak, s.inUse)
+			last = s
+		}
+		sample()
+		for {
+			select {
+			case <-stop:
+				sample()
+				if end, err := readNetCounters(); err != nil {
+					warningf("failed to read network statistics: %v", err)
+				} else {
+					b.setStat(statTCPRetransmits, end.tcpRetransmits-start.tcpRetransmits)
+					b.setStat(statUDPDatagrams, end.udpDatagrams-start.udpDatagrams)
+					b.setStat(statUDPErrors, end.udpErrors-start.udpErrors)
+				}
+				b.setStat(statTCPSocketsPeak, peak)
+				b.setStat(statTCPTimeWait, last.timeWait)
+				if haveClientCPU {
+					if userEnd, sysEnd, err := readSelfCPUTime(); err != nil {
+						warningf("failed to read client CPU time: %v", err)
+					} else {
+						b.setStat(statClientUserTime, uint64(userEnd-userStart))
+						b.setStat(statClientSystemTime, uint64(sysEnd-sysStart))
+					}
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+				sample()
+			}
+		}
+	}()
+	return stop
+}
+
+// parseSNMP parses the contents of /proc/net/snmp, in which the counters
+// for each protocol are a line of names followed by a line of values, e.g.
+//
+//	Tcp: RtoAlgorithm RtoMin ... RetransSegs InErrs ...
+//	Tcp: 1 200 ... 42 0 ...
+func parseSNMP(data []byte) (netCounters, error) {
+	counters := make(map[string]uint64)
+	names := make(map[string][]string)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		proto := f[0]
+		if names[proto] == nil {
+			names[proto] = f
+			continue
+		}
+		for i, name := range names[proto] {
+			if i == 0 || i >= len(f) {
+				continue
+			}
+			if v, err := strconv.ParseUint(f[i], 10, 64); err == nil {
+				counters[proto+name] = v
+			}
+		}
+	}
+	var c netCounters
+	for _, want := range []struct {
+		name string
+		dst  *uint64
+	}{
+		{"Tcp:RetransSegs", &c.tcpRetransmits},
+		{"Udp:InDatagrams", &c.udpDatagrams},
+		{"Udp:OutDatagrams", &c.udpDatagrams},
+		{"Udp:InErrors", &c.udpErrors},
+	} {
+		v, ok := counters[want.name]
+		if !ok {
+			return c, fmt.Errorf("no %s counter in /proc/net/snmp", want.name)
+		}
+		*want.dst += v
+	}
+	return c, nil
+}
+
+// parseSockstat adds the TCP sockets counted in the contents of
+// /proc/net/sockstat or /proc/net/sockstat6 to c. sockstat counts the IPv4
+// sockets in use and all the sockets in TIME_WAIT, and sockstat6 the IPv6
+// sockets in use, e.g.
+//
+//	TCP: inuse 5 orphan 0 tw 2 alloc 7 mem 1
+//	TCP6: inuse 3
+func parseSockstat(data []byte, c *tcpSockets) {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || (f[0] != "TCP:" && f[0] != "TCP6:") {
+			continue
+		}
+		for i := 1; i+1 < len(f); i += 2 {
+			n, err := strconv.ParseUint(f[i+1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch f[i] {
+			case "inuse":
+				c.inUse += n
+			case "tw":
+				c.timeWait += n
+			}
+		}
+	}
+}
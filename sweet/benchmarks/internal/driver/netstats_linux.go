@@ -0,0 +1,47 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// readNetCounters returns the network counters of this network namespace,
+// over both IPv4 and IPv6.
+func readNetCounters() (netCounters, error) {
+	data, err := os.ReadFile("/proc/net/snmp")
+	if err != nil {
+		return netCounters{}, err
+	}
+	return parseSNMP(data)
+}
+
+// readTCPSockets counts the TCP sockets in this network namespace.
+func readTCPSockets() (tcpSockets, error) {
+	var c tcpSockets
+	for _, file := range []string{"/proc/net/sockstat", "/proc/net/sockstat6"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if file == "/proc/net/sockstat6" && os.IsNotExist(err) {
+				continue // IPv6 is disabled.
+			}
+			return c, err
+		}
+		parseSockstat(data, &c)
+	}
+	return c, nil
+}
+
+// readSelfCPUTime returns the user and system CPU time this process has
+// used so far.
+func readSelfCPUTime() (user, sys time.Duration, err error) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(ru.Utime.Nano()), time.Duration(ru.Stime.Nano()), nil
+}
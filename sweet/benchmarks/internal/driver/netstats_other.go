@@ -0,0 +1,26 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package driver
+
+import (
+	"errors"
+	"time"
+)
+
+var errNoNetStats = errors.New("network statistics are only available on Linux")
+
+func readNetCounters() (netCounters, error) {
+	return netCounters{}, errNoNetStats
+}
+
+func readTCPSockets() (tcpSockets, error) {
+	return tcpSockets{}, errNoNetStats
+}
+
+func readSelfCPUTime() (user, sys time.Duration, err error) {
+	return 0, 0, errNoNetStats
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import "testing"
+
+// snmp is /proc/net/snmp from a Linux 6.x machine.
+const snmp = `Ip: Forwarding DefaultTTL InReceives InHdrErrors InAddrErrors ForwDatagrams InUnknownProtos InDiscards InDelivers OutRequests OutDiscards OutNoRoutes ReasmTimeout ReasmReqds ReasmOKs ReasmFails FragOKs FragFails FragCreates OutTransmits
+Ip: 2 64 5126997 0 0 0 0 0 5126997 5127255 0 0 0 0 0 0 0 0 0 5127255
+Icmp: InMsgs InErrors InCsumErrors InDestUnreachs InTimeExcds InParmProbs InSrcQuenchs InRedirects InEchos InEchoReps InTimestamps InTimestampReps InAddrMasks InAddrMaskReps OutMsgs OutErrors OutRateLimitGlobal OutRateLimitHost OutDestUnreachs OutTimeExcds OutParmProbs OutSrcQuenchs OutRedirects OutEchos OutEchoReps OutTimestamps OutTimestampReps OutAddrMasks OutAddrMaskReps
+Icmp: 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 49965 49938 1 11959 2 5126921 5098053 29277 0 11925 0
+Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors IgnoredMulti MemErrors
+Udp: 76 0 3 74 0 0 0 0 0
+UdpLite: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors IgnoredMulti MemErrors
+UdpLite: 9 0 9 9 0 0 0 0 0
+`
+
+func TestParseSNMP(t *testing.T) {
+	got, err := parseSNMP([]byte(snmp))
+	if want := (netCounters{tcpRetransmits: 29277, udpDatagrams: 150, udpErrors: 3}); err != nil || got != want {
+		t.Errorf("parseSNMP = %+v, %v; want %+v", got, err, want)
+	}
+	for _, bad := range []string{
+		"",
+		"Tcp: RtoAlgorithm RetransSegs\nTcp: 1 5\n",
+		"Tcp: RtoAlgorithm RetransSegs\nTcp: 1\nUdp: InDatagrams InErrors OutDatagrams\nUdp: 1 2 3\n",
+	} {
+		if got, err := parseSNMP([]byte(bad)); err == nil {
+			t.Errorf("parseSNMP(%q) = %+v, want error", bad, got)
+		}
+	}
+}
+
+func TestParseSockstat(t *testing.T) {
+	var c tcpSockets
+	parseSockstat([]byte(`sockets: used 17
+TCP: inuse 4 orphan 0 tw 7 alloc 4 mem 8
+UDP: inuse 2 mem 0
+UDPLITE: inuse 0
+RAW: inuse 0
+FRAG: inuse 0 memory 0
+`), &c)
+	parseSockstat([]byte(`TCP6: inuse 3
+UDP6: inuse 1
+UDPLITE6: inuse 0
+RAW6: inuse 0
+FRAG6: inuse 0 memory 0
+`), &c)
+	if want := (tcpSockets{inUse: 7, timeWait: 7}); c != want {
+		t.Errorf("parseSockstat = %+v, want %+v", c, want)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// psiResources are the resources Linux reports pressure stall information for.
+var psiResources = []string{"cpu", "memory", "io"}
+
+// psiSource is a file containing pressure stall information for one resource.
+type psiSource struct {
+	resource string
+	path     string
+}
+
+// DoSystemPSI samples system-wide pressure stall information from
+// /proc/pressure while the benchmark runs. See DoCgroupPSI.
+func DoSystemPSI(v bool) RunOption {
+	return func(b *B) {
+		b.psiSources = nil
+		if !v {
+			return
+		}
+		for _, r := range psiResources {
+			b.psiSources = append(b.psiSources, psiSource{r, filepath.Join("/proc/pressure", r)})
+		}
+	}
+}
+
+// DoCgroupPSI samples pressure stall information from the cgroup v2
+// directory dir while the benchmark runs, which only accounts for stalls
+// experienced by processes in that cgroup.
+//
+// For each resource and each of the "some" and "full" stall kinds, the
+// benchmark reports the average and the peak (over 100ms windows) share
+// of wall-clock time during which tasks were stalled, in parts per million,
+// e.g. "memory-some-stall-avg-ppm". A machine under contention shows up
+// here even if the benchmark's own numbers look like a regression.
+func DoCgroupPSI(dir string) RunOption {
+	return func(b *B) {
+		b.psiSources = nil
+		for _, r := range psiResources {
+			b.psiSources = append(b.psiSources, psiSource{r, filepath.Join(dir, r+".pressure")})
+		}
+	}
+}
+
+// readPSITotals returns the cumulative stall time in microseconds for each
+// kind of stall ("some" or "full") listed in a pressure file, which looks like
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=12345
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=6789
+func readPSITotals(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]uint64)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		for _, f := range fields[1:] {
+			v, ok := strings.CutPrefix(f, "total=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s: %v", path, err)
+			}
+			totals[fields[0]] = n
+		}
+	}
+	return totals, s.Err()
+}
+
+// psiStat accumulates samples of one kind of stall for one resource.
+type psiStat struct {
+	first, last   uint64
+	firstT, lastT time.Time
+	peak          float64
+}
+
+func (s *psiStat) add(total uint64, t time.Time) {
+	if s.firstT.IsZero() {
+		s.first, s.firstT = total, t
+	} else if dt := t.Sub(s.lastT); dt > 0 {
+		if frac := float64(total-s.last) / float64(dt.Microseconds()); frac > s.peak {
+			s.peak = frac
+		}
+	}
+	s.last, s.lastT = total, t
+}
+
+func (s *psiStat) avg() float64 {
+	dt := s.lastT.Sub(s.firstT)
+	if dt <= 0 {
+		return 0
+	}
+	return float64(s.last-s.first) / float64(dt.Microseconds())
+}
+
+func (b *B) startPSISampler() chan<- struct{} {
+	if len(b.psiSources) == 0 {
+		return nil
+	}
+	stop := make(chan struct{})
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		stats := make(map[string]*psiStat)
+		var names []string
+		sample := func() {
+			now := time.Now()
+			for _, src := range b.psiSources {
+				totals, err := readPSITotals(src.path)
+				if err != nil {
+					// Warn only once per resource; PSI may be
+					// unsupported or disabled on this kernel.
+					if _, ok := stats[src.resource]; !ok {
+						warningf("failed to read pressure stall information: %v", err)
+						stats[src.resource] = nil
+					}
+					continue
+				}
+				for kind, total := range totals {
+					name := src.resource + "-" + kind
+					s := stats[name]
+					if s == nil {
+						s = new(psiStat)
+						stats[name] = s
+						names = append(names, name)
+					}
+					s.add(total, now)
+				}
+			}
+		}
+		sample()
+		for {
+			select {
+			case <-stop:
+				sample()
+				for _, name := range names {
+					s := stats[name]
+					b.setStat(name+"-stall-avg-ppm", uint64(s.avg()*1e6))
+					b.setStat(name+"-stall-peak-ppm", uint64(s.peak*1e6))
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+				sample()
+			}
+		}
+	}()
+	return stop
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// runtimeEnvVars are the environment variables that change how the Go
+// runtime behaves, and so what a benchmark measures.
+var runtimeEnvVars = []string{"GOGC", "GOMEMLIMIT", "GODEBUG", "GOMAXPROCS"}
+
+// WithEnv sets the environment of the benchmarked process, e.g. the Env of
+// the command that started a server, whose runtime settings are recorded
+// with the results. A nil env is that of this process, as for exec.Cmd.
+func WithEnv(env []string) RunOption {
+	return func(b *B) {
+		b.env = env
+	}
+}
+
+// WithConfigLine adds a benchmark configuration line, key: value, to those
+// recorded with the results, for settings of the benchmark that neither its
+// name nor its runtime settings capture.
+func WithConfigLine(key, value string) RunOption {
+	return func(b *B) {
+		b.configLines = append(b.configLines, [2]string{key, value})
+	}
+}
+
+// runtimeEnvConfig returns benchmark configuration lines recording the
+// runtime settings in env, which are the same as in this process if env is
+// nil, followed by the extra lines. Every setting gets a line, even if it's
+// empty, because a configuration line applies to all the results after it
+// in a file, until another changes it.
+//
+// GOEXPERIMENT only matters when building, so it comes from how this
+// binary was built. Any servers that benchmarks start are built with the
+// same configuration.
+func runtimeEnvConfig(env []string, extra [][2]string) string {
+	if env == nil {
+		env = os.Environ()
+	}
+	values := make(map[string]string)
+	for _, kv := range env {
+		// Later values override earlier ones, as they do for exec.Cmd.
+		k, v, _ := strings.Cut(kv, "=")
+		values[k] = v
+	}
+	var s strings.Builder
+	for _, k := range runtimeEnvVars {
+		writeConfigLine(&s, strings.ToLower(k), values[k])
+	}
+	var experiment string
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			if setting.Key == "GOEXPERIMENT" {
+				experiment = setting.Value
+			}
+		}
+	}
+	writeConfigLine(&s, "goexperiment", experiment)
+	for _, kv := range extra {
+		writeConfigLine(&s, kv[0], kv[1])
+	}
+	return s.String()
+}
+
+func writeConfigLine(w io.Writer, key, value string) {
+	if value == "" {
+		fmt.Fprintf(w, "%s:\n", key)
+		return
+	}
+	fmt.Fprintf(w, "%s: %s\n", key, value)
+}
+
+var (
+	configMu      sync.Mutex
+	configWritten = make(map[io.Writer]string) // Configuration lines last written to each results writer.
+)
+
+// writeRuntimeEnvConfig writes the configuration lines for env and extra
+// to out, unless they are the ones it last wrote there.
+func writeRuntimeEnvConfig(out io.Writer, env []string, extra [][2]string) {
+	config := runtimeEnvConfig(env, extra)
+	configMu.Lock()
+	defer configMu.Unlock()
+	if configWritten[out] == config {
+		return
+	}
+	io.WriteString(out, config)
+	configWritten[out] = config
+}
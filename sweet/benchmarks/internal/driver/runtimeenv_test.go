@@ -0,0 +1,90 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// configLines returns the lines of config other than the goexperiment
+// line, which depends on how the test binary was built, and fails t
+// unless there is exactly one of those.
+func configLines(t *testing.T, config string) []string {
+	t.Helper()
+	var lines []string
+	experiments := 0
+	for _, line := range strings.Split(strings.TrimSuffix(config, "\n"), "\n") {
+		if strings.HasPrefix(line, "goexperiment:") {
+			experiments++
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if experiments != 1 {
+		t.Errorf("got %d goexperiment lines, want 1", experiments)
+	}
+	return lines
+}
+
+func TestRuntimeEnvConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		env   []string
+		extra [][2]string
+		want  []string
+	}{
+		{
+			name: "empty",
+			env:  []string{},
+			want: []string{"gogc:", "gomemlimit:", "godebug:", "gomaxprocs:"},
+		},
+		{
+			name: "set",
+			env:  []string{"HOME=/root", "GOGC=off", "GOMEMLIMIT=1GiB", "GODEBUG=gctrace=1,madvdontneed=1", "GOMAXPROCS=4"},
+			want: []string{"gogc: off", "gomemlimit: 1GiB", "godebug: gctrace=1,madvdontneed=1", "gomaxprocs: 4"},
+		},
+		{
+			name: "later values win",
+			env:  []string{"GOGC=50", "GOGC=200"},
+			want: []string{"gogc: 200", "gomemlimit:", "godebug:", "gomaxprocs:"},
+		},
+		{
+			name:  "extra lines last",
+			env:   []string{"GOMAXPROCS=2"},
+			extra: [][2]string{{"key-dist", "zipf"}, {"load", ""}},
+			want:  []string{"gogc:", "gomemlimit:", "godebug:", "gomaxprocs: 2", "key-dist: zipf", "load:"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := configLines(t, runtimeEnvConfig(tc.env, tc.extra))
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got config lines\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(tc.want, "\n\t"))
+			}
+		})
+	}
+
+	// A nil environment is this process's.
+	t.Setenv("GOGC", "123")
+	if got := configLines(t, runtimeEnvConfig(nil, nil)); !slices.Contains(got, "gogc: 123") {
+		t.Errorf("config lines for this process are %q, want gogc: 123", got)
+	}
+}
+
+func TestWriteRuntimeEnvConfig(t *testing.T) {
+	var out strings.Builder
+	writeRuntimeEnvConfig(&out, []string{"GOGC=100"}, nil)
+	first := out.String()
+	// The same configuration isn't written twice in a row.
+	writeRuntimeEnvConfig(&out, []string{"GOGC=100"}, nil)
+	if out.String() != first {
+		t.Errorf("repeated configuration was written again:\n%s", out.String())
+	}
+	writeRuntimeEnvConfig(&out, []string{"GOGC=200"}, nil)
+	if got := strings.TrimPrefix(out.String(), first); !strings.Contains(got, "gogc: 200\n") {
+		t.Errorf("changed configuration was written as\n%s", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"os"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	statMaxGOMAXPROCS = "max-gomaxprocs"
+	statMaxGoroutines = "max-goroutines"
+	statMaxHeapGoal   = "max-heap-goal-bytes"
+)
+
+// runtimeMetricStats maps the runtime metrics the sampler reads to the
+// stats their maxima are reported as.
+var runtimeMetricStats = map[string]string{
+	"/sched/gomaxprocs:threads":    statMaxGOMAXPROCS,
+	"/sched/goroutines:goroutines": statMaxGoroutines,
+	"/gc/heap/goal:bytes":          statMaxHeapGoal,
+}
+
+// DoRuntimeMetrics samples the runtime's GOMAXPROCS, number of goroutines
+// and heap goal while an in-process benchmark runs, and reports the
+// largest value of each, to show whether a regression coincided with
+// unexpected numbers of goroutines or a drifting heap goal. Sampling is
+// cheap, so InProcessMeasurementOptions always does it. It does nothing
+// for benchmarks of other processes.
+func DoRuntimeMetrics(v bool) RunOption {
+	return func(b *B) {
+		b.doRuntimeMetrics = v
+	}
+}
+
+func (b *B) startRuntimeMetricsSampler() chan<- struct{} {
+	if !b.doRuntimeMetrics || b.pid != os.Getpid() {
+		return nil
+	}
+	samples := make([]metrics.Sample, 0, len(runtimeMetricStats))
+	for name := range runtimeMetricStats {
+		samples = append(samples, metrics.Sample{Name: name})
+	}
+	stop := make(chan struct{})
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		maxima := make([]uint64, len(samples))
+		sample := func() {
+			metrics.Read(samples)
+			for i, s := range samples {
+				if s.Value.Kind() == metrics.KindUint64 {
+					maxima[i] = max(maxima[i], s.Value.Uint64())
+				}
+			}
+		}
+		sample()
+		for {
+			select {
+			case <-stop:
+				sample()
+				for i, s := range samples {
+					if maxima[i] != 0 {
+						b.setStat(runtimeMetricStats[s.Name], maxima[i])
+					}
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+				sample()
+			}
+		}
+	}()
+	return stop
+}
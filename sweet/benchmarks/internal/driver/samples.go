@@ -0,0 +1,117 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// samplesFile is the value of the -samples-file flag.
+var samplesFile string
+
+// sample is one line of the samples file: the metrics a benchmark measured
+// over one interval of a run.
+type sample struct {
+	Name string `json:"name"`
+
+	// TimeUnixNano is the wall-clock time at the end of the interval.
+	TimeUnixNano int64 `json:"time-unix-ns"`
+
+	// ElapsedNanos is the time from the start of the run to the end of
+	// the interval, and IntervalNanos is the length of the interval.
+	ElapsedNanos  int64 `json:"elapsed-ns"`
+	IntervalNanos int64 `json:"interval-ns"`
+
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// ReportSample records metrics measured over the interval since the last
+// sample, or since the start of the run for the first one, such as the
+// throughput or latency of a server over the last 10 seconds. Unlike the
+// values passed to Report, samples don't appear in the results; they are
+// appended, as lines of JSON, to the file given by the -samples-file flag,
+// if any, to show how a long run behaved over time: how long it took to
+// warm up, and whether its throughput dipped or collapsed at some point.
+// Metric names are filtered and renamed like those of Report.
+func (b *B) ReportSample(metrics map[string]float64) {
+	if samplesFile == "" {
+		return
+	}
+	b.sampleMu.Lock()
+	defer b.sampleMu.Unlock()
+	start := b.lastSample
+	if start.IsZero() {
+		start = b.runStart
+	}
+	b.writeSample(start, time.Now(), metrics)
+}
+
+// writeSample appends the metrics measured over the interval from start to
+// end to the samples file. b.sampleMu must be held.
+func (b *B) writeSample(start, end time.Time, metrics map[string]float64) {
+	b.lastSample = end
+	s := sample{
+		Name:          b.fullName(""),
+		TimeUnixNano:  end.UnixNano(),
+		ElapsedNanos:  end.Sub(b.runStart).Nanoseconds(),
+		IntervalNanos: end.Sub(start).Nanoseconds(),
+		// Collisions are warned about once, when the results are
+		// reported, rather than in every sample.
+		Metrics: filterMetrics(b, metrics, false),
+	}
+	line, err := json.Marshal(s)
+	if err != nil {
+		warningf("failed to encode sample: %v", err)
+		return
+	}
+	f, err := os.OpenFile(samplesFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		warningf("failed to write sample: %v", err)
+	}
+}
+
+// SampleEvery calls measure every interval with the time since it was
+// last called, or since SampleEvery was, and records the metrics it
+// returns as a sample over that time, until stop is called. It does
+// nothing if there is no -samples-file to record them in.
+func (b *B) SampleEvery(interval time.Duration, measure func(since time.Duration) map[string]float64) (stop func()) {
+	if samplesFile == "" {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case now := <-ticker.C:
+				// The measurement and the sample share one clock,
+				// so that rates cover exactly the sample's interval.
+				m := measure(now.Sub(last))
+				b.sampleMu.Lock()
+				b.writeSample(last, now, m)
+				b.sampleMu.Unlock()
+				last = now
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readSamples returns the samples in file.
+func readSamples(t *testing.T, file string) []sample {
+	t.Helper()
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var samples []sample
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var smp sample
+		if err := json.Unmarshal(s.Bytes(), &smp); err != nil {
+			t.Fatalf("bad sample %q: %v", s.Text(), err)
+		}
+		samples = append(samples, smp)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func setSamplesFile(t *testing.T, file string) {
+	old := samplesFile
+	samplesFile = file
+	t.Cleanup(func() { samplesFile = old })
+}
+
+func TestReportSample(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.samples.jsonl")
+
+	// Without a samples file, nothing is recorded.
+	setSamplesFile(t, "")
+	b := newB("Test")
+	b.runStart = time.Now()
+	b.ReportSample(map[string]float64{"ops/s": 1})
+	if stop := b.SampleEvery(time.Millisecond, func(time.Duration) map[string]float64 {
+		t.Error("SampleEvery measured without a samples file")
+		return nil
+	}); stop != nil {
+		stop()
+	}
+
+	setSamplesFile(t, file)
+	b = newB("Test")
+	b.gomaxprocs = 4
+	f, err := ParseMetricFilter("ops/s=throughput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.metricFilters = []MetricFilter{f}
+	b.runStart = time.Now().Add(-time.Second)
+	b.ReportSample(map[string]float64{"ops/s": 100, "avg-latency-ns": 5})
+	b.ReportSample(map[string]float64{"ops/s": 200})
+
+	samples := readSamples(t, file)
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(samples))
+	}
+	first, second := samples[0], samples[1]
+	for _, s := range samples {
+		if s.Name != "Test-4" {
+			t.Errorf("sample is named %q, want Test-4", s.Name)
+		}
+	}
+	// The first interval starts with the run.
+	if first.IntervalNanos != first.ElapsedNanos || first.ElapsedNanos < int64(time.Second) {
+		t.Errorf("first sample covers %dns of %dns, want all of at least 1s", first.IntervalNanos, first.ElapsedNanos)
+	}
+	// Later ones start where the one before ended.
+	if got, want := second.ElapsedNanos-second.IntervalNanos, first.ElapsedNanos; got != want {
+		t.Errorf("second sample starts at %dns, want %dns", got, want)
+	}
+	if len(first.Metrics) != 2 || first.Metrics["throughput"] != 100 || first.Metrics["avg-latency-ns"] != 5 {
+		t.Errorf("first sample has metrics %v, want throughput 100 and avg-latency-ns 5", first.Metrics)
+	}
+}
+
+func TestSampleEvery(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.samples.jsonl")
+	setSamplesFile(t, file)
+	b := newB("Test")
+	b.runStart = time.Now()
+
+	var measured []time.Duration
+	ticks := make(chan struct{}, 100)
+	stop := b.SampleEvery(10*time.Millisecond, func(since time.Duration) map[string]float64 {
+		measured = append(measured, since)
+		ticks <- struct{}{}
+		return map[string]float64{"ops/s": float64(len(measured))}
+	})
+	for range 3 {
+		<-ticks
+	}
+	stop()
+
+	samples := readSamples(t, file)
+	if len(samples) != len(measured) {
+		t.Fatalf("got %d samples of %d measurements, want the same number", len(samples), len(measured))
+	}
+	for i, s := range samples {
+		// The measurement and the sample cover the same interval.
+		if got, want := time.Duration(s.IntervalNanos), measured[i]; got != want {
+			t.Errorf("sample %d covers %v, but was measured over %v", i, got, want)
+		}
+		if s.Metrics["ops/s"] != float64(i+1) {
+			t.Errorf("sample %d has metrics %v, want ops/s %d", i, s.Metrics, i+1)
+		}
+		if i > 0 && s.ElapsedNanos-s.IntervalNanos != samples[i-1].ElapsedNanos {
+			t.Errorf("sample %d doesn't start where sample %d ended", i, i-1)
+		}
+	}
+	n := len(samples)
+	time.Sleep(30 * time.Millisecond)
+	if got := len(readSamples(t, file)); got != n {
+		t.Errorf("got %d samples after stop, want %d", got, n)
+	}
+}
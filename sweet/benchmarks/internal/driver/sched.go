@@ -0,0 +1,134 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	statVoluntaryCtxSwitches   = "voluntary-ctx-switches"
+	statInvoluntaryCtxSwitches = "involuntary-ctx-switches"
+	statRunqueueWait           = "runqueue-wait-ns"
+)
+
+// schedCounts are the cumulative scheduling statistics of a process.
+type schedCounts struct {
+	voluntary   uint64 // Context switches from blocking.
+	involuntary uint64 // Context switches from preemption.
+
+	// waits is the time each of the process's live threads, by thread ID,
+	// has spent runnable, waiting for a CPU, in ns, or nil if unknown.
+	waits map[string]uint64
+
+	// children counts the context switches of the processes that this
+	// process has run to completion, which only its own counts include.
+	children uint64
+}
+
+// DoSchedStats reports how many times the threads of the benchmark process
+// were switched out during the run, voluntarily or not, and how long they
+// spent waiting for a CPU, where Linux makes them available. Changes to
+// the runtime's scheduler often show up in these first.
+func DoSchedStats(v bool) RunOption {
+	return func(b *B) {
+		b.doSchedStats = v
+	}
+}
+
+// startSchedStats reads the benchmark process's scheduling statistics, and
+// returns a function that reports how much they've changed since, or nil
+// if they aren't being collected.
+func (b *B) startSchedStats() func() {
+	if !b.doSchedStats {
+		return nil
+	}
+	start, err := readSchedCounts(b.pid)
+	if err != nil {
+		warningf("failed to read scheduling statistics: %v", err)
+		return nil
+	}
+	return func() {
+		end, err := readSchedCounts(b.pid)
+		if err != nil {
+			warningf("failed to read scheduling statistics: %v", err)
+			return
+		}
+		// The counts only go down if the process isn't the one it was.
+		if end.voluntary < start.voluntary || end.involuntary < start.involuntary {
+			warningf("scheduling statistics of process %d went backwards", b.pid)
+			return
+		}
+		b.setStat(statVoluntaryCtxSwitches, end.voluntary-start.voluntary)
+		b.setStat(statInvoluntaryCtxSwitches, end.involuntary-start.involuntary)
+		// The wait time only covers this process's own threads, so it
+		// misses the point for benchmarks that run a command, which is
+		// gone by now.
+		if wait, ok := waitDelta(start.waits, end.waits); ok && end.children == start.children {
+			b.setStat(statRunqueueWait, wait)
+		}
+	}
+}
+
+// waitDelta returns the time the threads of a process spent waiting for a
+// CPU between the per-thread waits start and end, counting threads that
+// started in between in full. Threads that exited in between are missed.
+func waitDelta(start, end map[string]uint64) (uint64, bool) {
+	if start == nil || end == nil {
+		return 0, false
+	}
+	var wait uint64
+	for tid, e := range end {
+		s := start[tid]
+		if e < s {
+			return 0, false // The thread ID was reused.
+		}
+		wait += e - s
+	}
+	return wait, true
+}
+
+// parseCtxSwitches returns the context switch counts in the contents of a
+// /proc/<pid>/status file, which for a process include those of its
+// threads that have exited.
+func parseCtxSwitches(status []byte) (voluntary, involuntary uint64, err error) {
+	var found int
+	s := bufio.NewScanner(bytes.NewReader(status))
+	for s.Scan() {
+		k, v, _ := strings.Cut(s.Text(), ":")
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "voluntary_ctxt_switches":
+			voluntary = n
+			found++
+		case "nonvoluntary_ctxt_switches":
+			involuntary = n
+			found++
+		}
+	}
+	if found != 2 {
+		return 0, 0, fmt.Errorf("no context switch counts in status")
+	}
+	return voluntary, involuntary, nil
+}
+
+// parseSchedstatWait returns the time spent waiting on a run queue, in ns,
+// from the contents of a schedstat file: its second field, if the kernel
+// keeps scheduler statistics.
+func parseSchedstatWait(schedstat []byte) (uint64, bool) {
+	f := strings.Fields(string(schedstat))
+	if len(f) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(f[1], 10, 64)
+	return n, err == nil
+}
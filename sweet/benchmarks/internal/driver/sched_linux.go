@@ -0,0 +1,63 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// readSchedCounts reads the scheduling statistics of process pid. For this
+// process, they include the processes it has run to completion.
+func readSchedCounts(pid int) (schedCounts, error) {
+	var c schedCounts
+	if pid == os.Getpid() {
+		// Unlike /proc, these count the processes this one has waited for.
+		var s, ch syscall.Rusage
+		if err := syscall.Getrusage(syscall.RUSAGE_SELF, &s); err != nil {
+			return c, err
+		}
+		if err := syscall.Getrusage(syscall.RUSAGE_CHILDREN, &ch); err != nil {
+			return c, err
+		}
+		c.voluntary = uint64(s.Nvcsw + ch.Nvcsw)
+		c.involuntary = uint64(s.Nivcsw + ch.Nivcsw)
+		c.children = uint64(ch.Nvcsw + ch.Nivcsw)
+	} else {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			return c, err
+		}
+		if c.voluntary, c.involuntary, err = parseCtxSwitches(data); err != nil {
+			return c, fmt.Errorf("process %d: %v", pid, err)
+		}
+	}
+	// There's no process-wide wait time, so it has to be read thread by
+	// thread.
+	tasks, err := filepath.Glob(fmt.Sprintf("/proc/%d/task/*", pid))
+	if err != nil {
+		return c, err
+	}
+	c.waits = make(map[string]uint64)
+	for _, task := range tasks {
+		data, err := os.ReadFile(filepath.Join(task, "schedstat"))
+		if err != nil {
+			if os.IsNotExist(err) || err == syscall.ESRCH {
+				continue // The thread has exited.
+			}
+			c.waits = nil
+			break
+		}
+		wait, ok := parseSchedstatWait(data)
+		if !ok {
+			c.waits = nil
+			break
+		}
+		c.waits[filepath.Base(task)] = wait
+	}
+	return c, nil
+}
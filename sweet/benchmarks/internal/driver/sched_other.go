@@ -0,0 +1,13 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package driver
+
+import "errors"
+
+func readSchedCounts(pid int) (schedCounts, error) {
+	return schedCounts{}, errors.New("scheduling statistics are only available on Linux")
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import "testing"
+
+func TestParseCtxSwitches(t *testing.T) {
+	status := `Name:	etcd
+Umask:	0022
+State:	S (sleeping)
+Tgid:	4242
+Pid:	4242
+Threads:	12
+voluntary_ctxt_switches:	1534
+nonvoluntary_ctxt_switches:	87
+`
+	vol, invol, err := parseCtxSwitches([]byte(status))
+	if err != nil || vol != 1534 || invol != 87 {
+		t.Errorf("parseCtxSwitches = %d, %d, %v; want 1534, 87", vol, invol, err)
+	}
+	if vol, invol, err := parseCtxSwitches([]byte("Name:\tetcd\nvoluntary_ctxt_switches:\t3\n")); err == nil {
+		t.Errorf("parseCtxSwitches without nonvoluntary_ctxt_switches = %d, %d; want error", vol, invol)
+	}
+}
+
+func TestParseSchedstatWait(t *testing.T) {
+	for _, tc := range []struct {
+		schedstat string
+		want      uint64
+		ok        bool
+	}{
+		{"123456789 98765 4321\n", 98765, true},
+		{"0 0 0\n", 0, true},
+		{"123456789\n", 0, false},
+		{"", 0, false},
+		{"1 x 2\n", 0, false},
+	} {
+		got, ok := parseSchedstatWait([]byte(tc.schedstat))
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseSchedstatWait(%q) = %d, %v; want %d, %v", tc.schedstat, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestWaitDelta(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		start, end map[string]uint64
+		want       uint64
+		ok         bool
+	}{
+		{"same threads", map[string]uint64{"1": 100, "2": 50}, map[string]uint64{"1": 150, "2": 60}, 60, true},
+		{"thread exited", map[string]uint64{"1": 100, "2": 5000}, map[string]uint64{"1": 150}, 50, true},
+		{"thread started", map[string]uint64{"1": 100}, map[string]uint64{"1": 150, "3": 20}, 70, true},
+		{"thread ID reused", map[string]uint64{"1": 100, "2": 5000}, map[string]uint64{"1": 150, "2": 20}, 0, false},
+		{"unknown", nil, map[string]uint64{"1": 150}, 0, false},
+	} {
+		got, ok := waitDelta(tc.start, tc.end)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: waitDelta = %d, %v; want %d, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import "sync"
+
+// SubResult is a named part of the results of a benchmark, such as those
+// of the reads and of the writes of a mixed workload. Each sub-result is
+// reported as a benchmark of its own, named Benchmark<name>/<sub>, after
+// the benchmark that it is part of, so that its metrics are compared with
+// those of the same sub-result of other runs only.
+type SubResult struct {
+	name string
+
+	mu    sync.Mutex
+	stats map[string]uint64
+	ops   int
+}
+
+// SubResult returns the sub-result of b named name, creating it if it
+// doesn't exist yet. Sub-results are reported in the order in which they
+// were created, and only if they have any non-zero metrics.
+func (b *B) SubResult(name string) *SubResult {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+	for _, s := range b.subResults {
+		if s.name == name {
+			return s
+		}
+	}
+	s := &SubResult{name: name, stats: make(map[string]uint64), ops: 1}
+	b.subResults = append(b.subResults, s)
+	return s
+}
+
+// Report records the value of the metric name for s.
+func (s *SubResult) Report(name string, value uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats[name] = value
+}
+
+// Ops sets the number of operations s covers, which is reported as its
+// iteration count.
+func (s *SubResult) Ops(ops int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops = ops
+}
+
+// reportedStats returns the metrics of s to report, after b's filters.
+func (s *SubResult) reportedStats(b *B) map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return b.filterStats(s.stats)
+}
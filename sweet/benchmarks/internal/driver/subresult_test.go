@@ -0,0 +1,148 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSubResult(t *testing.T) {
+	type sub struct {
+		name  string
+		ops   int
+		stats map[string]uint64
+	}
+	for _, tc := range []struct {
+		name       string
+		gomaxprocs int
+		cpuLimit   int
+		metrics    string
+		stats      map[string]uint64
+		floats     map[string]float64
+		subs       []sub
+		want       []string
+	}{
+		{
+			name:  "none",
+			stats: map[string]uint64{"ns/op": 100},
+			want:  []string{"BenchmarkTest 10 100 ns/op"},
+		},
+		{
+			name:  "in order of creation",
+			stats: map[string]uint64{"ns/op": 100},
+			subs: []sub{
+				{"op=write", 3, map[string]uint64{"ops/s": 30, "p50-latency-ns": 5}},
+				{"op=read", 7, map[string]uint64{"ops/s": 70}},
+			},
+			want: []string{
+				"BenchmarkTest 10 100 ns/op",
+				"BenchmarkTest/op=write 3 5 p50-latency-ns 30 ops/s",
+				"BenchmarkTest/op=read 7 70 ops/s",
+			},
+		},
+		{
+			name:       "GOMAXPROCS suffix last",
+			gomaxprocs: 4,
+			stats:      map[string]uint64{"ns/op": 100},
+			subs:       []sub{{"op=read", 7, map[string]uint64{"ops/s": 70}}},
+			want: []string{
+				"BenchmarkTest-4 10 100 ns/op",
+				"BenchmarkTest/op=read-4 7 70 ops/s",
+			},
+		},
+		{
+			name:       "CPU limit of 1",
+			gomaxprocs: 8,
+			cpuLimit:   1,
+			stats:      map[string]uint64{"ns/op": 100},
+			subs:       []sub{{"op=read", 7, map[string]uint64{"ops/s": 70}}},
+			want: []string{
+				"BenchmarkTest-1 10 100 ns/op",
+				"BenchmarkTest/op=read-1 7 70 ops/s",
+			},
+		},
+		{
+			name:  "only non-zero",
+			stats: map[string]uint64{"ns/op": 100},
+			subs: []sub{
+				{"op=write", 3, map[string]uint64{"ops/s": 0}},
+				{"op=read", 7, map[string]uint64{"ops/s": 70, "errors": 0}},
+			},
+			want: []string{
+				"BenchmarkTest 10 100 ns/op",
+				"BenchmarkTest/op=read 7 70 ops/s",
+			},
+		},
+		{
+			name:   "floats",
+			stats:  map[string]uint64{"ns/op": 100},
+			floats: map[string]float64{"MB/s": 12.5, "ratio": 0},
+			want:   []string{"BenchmarkTest 10 100 ns/op 12.5 MB/s"},
+		},
+		{
+			name:    "filtered",
+			metrics: "ops/s,ns/op=sec-ns/op",
+			stats:   map[string]uint64{"ns/op": 100, "peak-RSS-bytes": 1 << 20},
+			subs: []sub{
+				{"op=read", 7, map[string]uint64{"ops/s": 70, "p50-latency-ns": 5}},
+				{"op=write", 3, map[string]uint64{"p50-latency-ns": 5}},
+			},
+			want: []string{
+				"BenchmarkTest 10 100 sec-ns/op",
+				"BenchmarkTest/op=read 7 70 ops/s",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			b := newB("Test")
+			b.resultsWriter = &out
+			b.gomaxprocs = tc.gomaxprocs
+			if tc.cpuLimit != 0 {
+				old := cpuLimit
+				cpuLimit = tc.cpuLimit
+				WithCPULimit()(b)
+				cpuLimit = old
+			}
+			if tc.metrics != "" {
+				f, err := ParseMetricFilter(tc.metrics)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b.metricFilters = []MetricFilter{f}
+			}
+			b.Ops(10)
+			for name, v := range tc.stats {
+				b.Report(name, v)
+			}
+			for name, v := range tc.floats {
+				b.ReportFloat(name, v)
+			}
+			for _, s := range tc.subs {
+				r := b.SubResult(s.name)
+				r.Ops(s.ops)
+				for name, v := range s.stats {
+					r.Report(name, v)
+				}
+				if again := b.SubResult(s.name); again != r {
+					t.Errorf("SubResult(%q) returned a new sub-result the second time", s.name)
+				}
+			}
+			b.report()
+
+			var got []string
+			for _, line := range strings.Split(out.String(), "\n") {
+				if strings.HasPrefix(line, "Benchmark") {
+					got = append(got, line)
+				}
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got results\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(tc.want, "\n\t"))
+			}
+		})
+	}
+}
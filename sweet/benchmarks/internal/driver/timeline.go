@@ -0,0 +1,74 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runTimeline records when a benchmark run happened, so that its results
+// and diagnostics can be lined up against data collected outside the
+// benchmark, such as perf profiles, server logs, or machine metrics.
+type runTimeline struct {
+	Name string `json:"name"`
+
+	// Start and End are wall-clock times, for lining up with other sources.
+	StartUnixNano int64 `json:"start-unix-ns"`
+	EndUnixNano   int64 `json:"end-unix-ns"`
+
+	// WallNanos is the run's duration as measured by the monotonic clock,
+	// which unlike End-Start is unaffected by changes to the system clock.
+	WallNanos int64 `json:"wall-ns"`
+
+	// TimedNanos is the time spent with the benchmark timer running.
+	TimedNanos int64 `json:"timed-ns"`
+}
+
+func newRunTimeline(name string, start, end time.Time, timed time.Duration) *runTimeline {
+	return &runTimeline{
+		Name:          name,
+		StartUnixNano: start.UnixNano(),
+		EndUnixNano:   end.UnixNano(),
+		WallNanos:     end.Sub(start).Nanoseconds(),
+		TimedNanos:    timed.Nanoseconds(),
+	}
+}
+
+// writeComment writes t to out as a comment line, which tools that read
+// the Go benchmark format ignore.
+func (t *runTimeline) writeComment(out io.Writer) {
+	fmt.Fprintf(out, "# timeline: Benchmark%s start-unix-ns=%d end-unix-ns=%d wall-ns=%d timed-ns=%d\n",
+		t.Name, t.StartUnixNano, t.EndUnixNano, t.WallNanos, t.TimedNanos)
+}
+
+// timelineFile is the name of the file in the diagnostics results directory
+// that each run appends its timeline to, as a line of JSON.
+const timelineFile = "timeline.jsonl"
+
+// appendToDiagnostics appends t to the timeline file in the diagnostics
+// results directory, if diagnostics are being collected.
+func (t *runTimeline) appendToDiagnostics() error {
+	if diag.ResultsDir == "" {
+		return nil
+	}
+	line, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(diag.ResultsDir, timelineFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
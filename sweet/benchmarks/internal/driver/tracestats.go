@@ -0,0 +1,237 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	statTraceSTW             = "trace-stw-ns"
+	statTraceGCCycles        = "trace-gc-cycles"
+	statTraceMaxRunnable     = "trace-max-runnable-latency-ns"
+	statTraceProcUtilization = "trace-proc-utilization-%"
+)
+
+// traceStats summarizes one or more execution traces.
+type traceStats struct {
+	stw         uint64 // Total time the world was stopped, in ns.
+	gcCycles    uint64 // Number of GC cycles that started.
+	maxRunnable uint64 // Longest time a goroutine waited to run, in ns.
+
+	// procBusy is the total time Ps spent running goroutines, and
+	// procTotal the total time they could have, in ns.
+	procBusy, procTotal uint64
+}
+
+func (s *traceStats) add(t *traceStats) {
+	s.stw += t.stw
+	s.gcCycles += t.gcCycles
+	s.maxRunnable = max(s.maxRunnable, t.maxRunnable)
+	s.procBusy += t.procBusy
+	s.procTotal += t.procTotal
+}
+
+func (s *traceStats) report(b *B) {
+	b.Report(statTraceSTW, s.stw)
+	b.Report(statTraceGCCycles, s.gcCycles)
+	b.Report(statTraceMaxRunnable, s.maxRunnable)
+	if s.procTotal != 0 {
+		b.Report(statTraceProcUtilization, uint64(float64(s.procBusy)/float64(s.procTotal)*100+0.5))
+	}
+}
+
+// readTracesStats computes the combined statistics of the execution traces
+// at paths.
+func readTracesStats(goTool string, paths []string) (*traceStats, error) {
+	var stats traceStats
+	for _, path := range paths {
+		s, err := readTraceStats(goTool, path)
+		if err != nil {
+			return nil, err
+		}
+		stats.add(s)
+	}
+	return &stats, nil
+}
+
+// readTraceStats computes summary statistics for the execution trace at
+// path. It reads the trace with the go command's trace tool, which
+// understands the trace format of the toolchain that wrote the trace, as
+// long as goTool is that toolchain's go command; golang.org/x/exp/trace
+// only understands the formats of the toolchains released before it. The
+// tool's output isn't a stable interface, so TestReadTraceStats checks
+// that it still parses.
+func readTraceStats(goTool, path string) (*traceStats, error) {
+	if goTool == "" {
+		goTool = "go"
+	}
+	cmd := exec.Command(goTool, "tool", "trace", "-d=parsed", path)
+	// Don't let the go command switch to another toolchain, whose trace
+	// tool might not understand the trace.
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := newTraceParser()
+	s := bufio.NewScanner(out)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		p.line(s.Text())
+	}
+	if err := s.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("%s tool trace: %v: %s", goTool, err, strings.TrimSpace(stderr.String()))
+	}
+	return p.stats(), nil
+}
+
+// traceParser accumulates traceStats from the events printed by
+// "go tool trace -d=parsed", one per line, in time order, like
+//
+//	M=1 P=0 G=16 RangeBegin Time=7677850247936 Name="stop-the-world (GC sweep termination)" Scope=Goroutine(16)
+//	M=1 P=-1 G=-1 StateTransition Time=7677849966976 ProcID=0 Idle->Running Reason=""
+//	M=1 P=0 G=1 Metric Time=7677849972992 Name="/sched/gomaxprocs:threads" Value=Value{Uint64(8)}
+type traceParser struct {
+	s          traceStats
+	start, end uint64
+	gomaxprocs uint64
+
+	ranges   map[string]uint64 // Start of each active stop-the-world range, by name and scope.
+	runnable map[string]uint64 // When each runnable goroutine became runnable, by ID.
+	running  map[string]uint64 // When each running P started running, by ID.
+}
+
+func newTraceParser() *traceParser {
+	return &traceParser{
+		ranges:   make(map[string]uint64),
+		runnable: make(map[string]uint64),
+		running:  make(map[string]uint64),
+	}
+}
+
+// traceField returns the value of the key=value field in line, which may be
+// quoted.
+func traceField(line, key string) (string, bool) {
+	_, v, ok := strings.Cut(line, " "+key+"=")
+	if !ok {
+		return "", false
+	}
+	if rest, ok := strings.CutPrefix(v, `"`); ok {
+		v, _, ok = strings.Cut(rest, `"`)
+		return v, ok
+	}
+	v, _, _ = strings.Cut(v, " ")
+	return v, true
+}
+
+func (p *traceParser) line(line string) {
+	// Skip everything but events, such as stacks and attributes.
+	if !strings.HasPrefix(line, "M=") {
+		return
+	}
+	f := strings.Fields(line)
+	if len(f) < 5 {
+		return
+	}
+	ts, ok := traceField(line, "Time")
+	if !ok {
+		return
+	}
+	t, err := strconv.ParseUint(ts, 10, 64)
+	if err != nil {
+		return
+	}
+	if p.start == 0 {
+		p.start = t
+	}
+	p.end = max(p.end, t)
+
+	switch f[3] {
+	case "RangeBegin", "RangeActive", "RangeEnd":
+		name, _ := traceField(line, "Name")
+		scope, _ := traceField(line, "Scope")
+		switch {
+		case strings.HasPrefix(name, "stop-the-world"):
+			key := name + "/" + scope
+			if f[3] == "RangeEnd" {
+				if begin, ok := p.ranges[key]; ok {
+					p.s.stw += t - begin
+					delete(p.ranges, key)
+				}
+			} else if f[3] == "RangeActive" {
+				// The range started before the trace did.
+				p.ranges[key] = p.start
+			} else {
+				p.ranges[key] = t
+			}
+		case name == "GC concurrent mark phase" && f[3] == "RangeBegin":
+			p.s.gcCycles++
+		}
+	case "StateTransition":
+		if len(f) < 7 {
+			return
+		}
+		from, to, ok := strings.Cut(f[6], "->")
+		if !ok {
+			return
+		}
+		if id, ok := strings.CutPrefix(f[5], "GoID="); ok {
+			if to == "Runnable" {
+				p.runnable[id] = t
+			} else if begin, ok := p.runnable[id]; ok && from == "Runnable" {
+				if to == "Running" {
+					p.s.maxRunnable = max(p.s.maxRunnable, t-begin)
+				}
+				delete(p.runnable, id)
+			}
+		} else if id, ok := strings.CutPrefix(f[5], "ProcID="); ok {
+			if to == "Running" {
+				p.running[id] = t
+			} else if begin, ok := p.running[id]; ok && from == "Running" {
+				p.s.procBusy += t - begin
+				delete(p.running, id)
+			}
+		}
+	case "Metric":
+		if name, _ := traceField(line, "Name"); name != "/sched/gomaxprocs:threads" {
+			return
+		}
+		v, _ := traceField(line, "Value")
+		v = strings.TrimSuffix(strings.TrimPrefix(v, "Value{Uint64("), ")}")
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			p.gomaxprocs = max(p.gomaxprocs, n)
+		}
+	}
+}
+
+// stats returns the statistics of the trace, closing whatever was still
+// running or stopped when it ended.
+func (p *traceParser) stats() *traceStats {
+	s := p.s
+	for _, begin := range p.ranges {
+		s.stw += p.end - begin
+	}
+	for _, begin := range p.running {
+		s.procBusy += p.end - begin
+	}
+	s.procTotal = (p.end - p.start) * p.gomaxprocs
+	return &s
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// parsedTrace is the output of "go tool trace -d=parsed" for a short trace,
+// with its timestamps made small. It has:
+//
+//   - one stop-the-world range that ends (50ns), one that was already
+//     active when the trace started (800ns), and one still in progress
+//     when it ended (100ns);
+//   - two GC cycles;
+//   - goroutine 6, which waited 300ns to run;
+//   - P 0, running all 1000ns of the trace, and P 1, running for 300ns;
+//   - GOMAXPROCS 2.
+const parsedTrace = `M=1 P=-1 G=-1 StateTransition Time=1000 ProcID=0 Undetermined->Running Reason=""
+M=1 P=0 G=1 Metric Time=1000 Name="/sched/gomaxprocs:threads" Value=Value{Uint64(1)}
+M=1 P=0 G=-1 StateTransition Time=1000 GoID=1 Undetermined->Running Reason=""
+M=1 P=0 G=1 RangeActive Time=1000 Name="stop-the-world (GC mark termination)" Scope=Goroutine(17)
+M=1 P=0 G=1 StateTransition Time=1100 GoID=6 NotExist->Runnable Reason=""
+	main.main /tmp/main.go:16
+	runtime.main /usr/lib/go/src/runtime/proc.go:283
+M=1 P=0 G=1 RangeBegin Time=1200 Name="stop-the-world (GC sweep termination)" Scope=Goroutine(1)
+M=1 P=0 G=1 RangeEnd Time=1250 Name="stop-the-world (GC sweep termination)" Scope=Goroutine(1) Attributes=[]
+M=1 P=0 G=1 RangeBegin Time=1260 Name="GC concurrent mark phase" Scope=None
+M=1 P=0 G=1 Metric Time=1270 Name="/sched/gomaxprocs:threads" Value=Value{Uint64(2)}
+M=2 P=-1 G=-1 StateTransition Time=1300 ProcID=1 Idle->Running Reason=""
+M=2 P=1 G=-1 StateTransition Time=1400 GoID=6 Runnable->Running Reason=""
+M=2 P=1 G=6 StateTransition Time=1500 GoID=6 Running->NotExist Reason=""
+M=2 P=1 G=-1 StateTransition Time=1600 ProcID=1 Running->Idle Reason=""
+M=1 P=0 G=17 RangeEnd Time=1800 Name="stop-the-world (GC mark termination)" Scope=Goroutine(17) Attributes=[]
+M=1 P=0 G=1 RangeBegin Time=1900 Name="stop-the-world (GC sweep termination)" Scope=Goroutine(1)
+M=1 P=0 G=1 RangeBegin Time=1950 Name="GC concurrent mark phase" Scope=None
+M=1 P=0 G=1 Log Time=2000 Task=0 Category="" Message="done"
+`
+
+func TestTraceParser(t *testing.T) {
+	p := newTraceParser()
+	for _, line := range strings.Split(parsedTrace, "\n") {
+		p.line(line)
+	}
+	got := *p.stats()
+	want := traceStats{
+		stw:         50 + 800 + 100,
+		gcCycles:    2,
+		maxRunnable: 300,
+		procBusy:    1000 + 300,
+		procTotal:   1000 * 2,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTraceField(t *testing.T) {
+	line := `M=1 P=0 G=1 RangeBegin Time=1200 Name="stop-the-world (GC sweep termination)" Scope=Goroutine(1)`
+	for _, tc := range []struct {
+		key, want string
+		ok        bool
+	}{
+		{"Time", "1200", true},
+		{"Name", "stop-the-world (GC sweep termination)", true},
+		{"Scope", "Goroutine(1)", true},
+		{"Value", "", false},
+		// Only whole keys match.
+		{"ime", "", false},
+	} {
+		v, ok := traceField(line, tc.key)
+		if v != tc.want || ok != tc.ok {
+			t.Errorf("traceField(%q) = %q, %v; want %q, %v", tc.key, v, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// TestReadTraceStats checks that the parser still understands what the go
+// command's trace tool prints, for a trace of this process.
+func TestReadTraceStats(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	path := filepath.Join(t.TempDir(), "trace.out")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Start(f); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2*runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := 0
+			for j := 0; j < 1e6; j++ {
+				s += j
+			}
+			_ = s
+		}()
+	}
+	wg.Wait()
+	runtime.GC()
+	trace.Stop()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := readTraceStats(goTool, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.gcCycles == 0 || s.stw == 0 {
+		t.Errorf("found %d GC cycles and %dns stopped, want some of each: %+v", s.gcCycles, s.stw, s)
+	}
+	if s.procTotal == 0 || s.procBusy == 0 || s.procBusy > s.procTotal {
+		t.Errorf("Ps were busy for %dns of %dns, want a non-zero part: %+v", s.procBusy, s.procTotal, s)
+	}
+}
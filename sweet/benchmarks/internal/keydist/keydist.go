@@ -0,0 +1,155 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package keydist provides the distributions with which key-value
+// benchmarks pick the keys they access.
+//
+// Which keys a workload accesses matters about as much as how many: with a
+// skewed distribution, a few keys take most of the accesses, so they stay
+// in the caches, and they are contended for, while most of the rest are
+// rarely accessed and are eventually evicted or collected.
+package keydist
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Usage describes the syntax of distributions, for flag descriptions.
+const Usage = "uniform, zipf[:skew] with skew > 1 (default 1.1), or hotspot[:keys[:accesses]], " +
+	"where a fraction keys of the keys (default 0.01) takes a fraction accesses of the accesses (default 0.9)"
+
+// Kind is a kind of distribution.
+type Kind string
+
+const (
+	Uniform Kind = "uniform" // Every key is equally likely.
+	Zipf    Kind = "zipf"    // The probability of key k is proportional to 1/(k+1)^Skew.
+	Hotspot Kind = "hotspot" // A fraction of the keys, the lowest, takes a fraction of the accesses.
+)
+
+// Distribution is a distribution of keys. The zero Distribution is
+// uniform.
+//
+// A *Distribution is a flag.Value, so that benchmarks can take one as a
+// flag.
+type Distribution struct {
+	Kind Kind
+
+	// Skew is the exponent of a Zipf distribution. The greater it is, the
+	// more accesses go to the lowest keys.
+	Skew float64
+
+	// HotKeys is the fraction of keys that are hot in a hotspot
+	// distribution, and HotAccesses the fraction of accesses to them.
+	HotKeys, HotAccesses float64
+}
+
+// Parse parses a distribution, as described by Usage.
+func Parse(s string) (Distribution, error) {
+	name, params, _ := strings.Cut(s, ":")
+	var args []float64
+	if params != "" {
+		for _, p := range strings.Split(params, ":") {
+			f, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return Distribution{}, fmt.Errorf("bad parameter %q in distribution %q", p, s)
+			}
+			args = append(args, f)
+		}
+	}
+	arg := func(i int, def float64) float64 {
+		if i < len(args) {
+			return args[i]
+		}
+		return def
+	}
+
+	var d Distribution
+	maxArgs := 0
+	switch Kind(name) {
+	case Uniform:
+		d = Distribution{Kind: Uniform}
+	case Zipf:
+		d = Distribution{Kind: Zipf, Skew: arg(0, 1.1)}
+		maxArgs = 1
+		if d.Skew <= 1 {
+			return Distribution{}, fmt.Errorf("zipf skew must be greater than 1, got %v", d.Skew)
+		}
+	case Hotspot:
+		d = Distribution{Kind: Hotspot, HotKeys: arg(0, 0.01), HotAccesses: arg(1, 0.9)}
+		maxArgs = 2
+		if d.HotKeys <= 0 || d.HotKeys >= 1 || d.HotAccesses < 0 || d.HotAccesses > 1 {
+			return Distribution{}, fmt.Errorf("hotspot key fraction must be in (0, 1) and access fraction in [0, 1], got %v and %v", d.HotKeys, d.HotAccesses)
+		}
+	default:
+		return Distribution{}, fmt.Errorf("unknown distribution %q, want one of uniform, zipf, hotspot", name)
+	}
+	if len(args) > maxArgs {
+		return Distribution{}, fmt.Errorf("too many parameters for %s distribution in %q", name, s)
+	}
+	return d, nil
+}
+
+// String returns d in the syntax Parse accepts, with all its parameters.
+func (d *Distribution) String() string {
+	switch d.Kind {
+	case Zipf:
+		return fmt.Sprintf("zipf:%v", d.Skew)
+	case Hotspot:
+		return fmt.Sprintf("hotspot:%v:%v", d.HotKeys, d.HotAccesses)
+	}
+	return string(Uniform)
+}
+
+// Set implements flag.Value.
+func (d *Distribution) Set(s string) error {
+	nd, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = nd
+	return nil
+}
+
+// A Generator generates keys from a distribution.
+type Generator struct {
+	next func() uint64
+}
+
+// Next returns the next key.
+func (g *Generator) Next() uint64 {
+	return g.next()
+}
+
+// New returns a Generator of keys in [0, n) distributed as d, drawing its
+// randomness from rng. The lowest keys are the most accessed, if any are.
+// Like rng, a Generator is not safe for concurrent use.
+func (d Distribution) New(rng *rand.Rand, n uint64) *Generator {
+	if n == 0 {
+		panic("keydist: no keys")
+	}
+	switch d.Kind {
+	case Zipf:
+		if n == 1 {
+			break
+		}
+		z := rand.NewZipf(rng, d.Skew, 1, n-1)
+		return &Generator{z.Uint64}
+	case Hotspot:
+		hot := max(uint64(float64(n)*d.HotKeys), 1)
+		if hot >= n {
+			break
+		}
+		return &Generator{func() uint64 {
+			if rng.Float64() < d.HotAccesses {
+				return uint64(rng.Int63n(int64(hot)))
+			}
+			return hot + uint64(rng.Int63n(int64(n-hot)))
+		}}
+	}
+	return &Generator{func() uint64 { return uint64(rng.Int63n(int64(n))) }}
+}
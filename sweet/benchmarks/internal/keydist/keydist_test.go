@@ -0,0 +1,84 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package keydist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"uniform", "uniform"},
+		{"zipf", "zipf:1.1"},
+		{"zipf:1.5", "zipf:1.5"},
+		{"hotspot", "hotspot:0.01:0.9"},
+		{"hotspot:0.2", "hotspot:0.2:0.9"},
+		{"hotspot:0.2:0.8", "hotspot:0.2:0.8"},
+	} {
+		d, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if got := d.String(); got != tc.want {
+			t.Errorf("Parse(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"", "normal", "uniform:1", "zipf:1", "zipf:x", "zipf:1.2:3", "hotspot:1", "hotspot:0.1:2", "hotspot:0.1:0.5:1"} {
+		if d, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %s, want error", in, d.String())
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	const n, samples = 1000, 100000
+	for _, spec := range []string{"uniform", "zipf", "hotspot:0.1:0.8"} {
+		d, err := Parse(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := d.New(rand.New(rand.NewSource(1)), n)
+		var counts [n]int
+		for i := 0; i < samples; i++ {
+			k := g.Next()
+			if k >= n {
+				t.Fatalf("%s: key %d out of range", spec, k)
+			}
+			counts[k]++
+		}
+
+		// Count the accesses to the lowest tenth of the keys.
+		low := 0
+		for _, c := range counts[:n/10] {
+			low += c
+		}
+		frac := float64(low) / samples
+		var lo, hi float64
+		switch d.Kind {
+		case Uniform:
+			lo, hi = 0.08, 0.12
+		case Zipf:
+			lo, hi = 0.5, 1
+		case Hotspot:
+			lo, hi = 0.78, 0.82
+		}
+		if frac < lo || frac > hi {
+			t.Errorf("%s: lowest tenth of keys got %.3f of accesses, want between %v and %v", spec, frac, lo, hi)
+		}
+	}
+}
+
+func TestGeneratorOneKey(t *testing.T) {
+	for _, spec := range []string{"uniform", "zipf", "hotspot"} {
+		d, _ := Parse(spec)
+		if k := d.New(rand.New(rand.NewSource(1)), 1).Next(); k != 0 {
+			t.Errorf("%s: got key %d of 1", spec, k)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis returns a Dialer for the Redis protocol server at addr, whose
+// connections make each request by calling req.
+func Redis(addr string, req func(c redis.Conn, n int) error) Dialer {
+	return func() (Conn, error) {
+		c, err := redis.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return &redisConn{c, req}, nil
+	}
+}
+
+type redisConn struct {
+	redis.Conn
+	req func(c redis.Conn, n int) error
+}
+
+func (c *redisConn) Do(n int) error {
+	return c.req(c.Conn, n)
+}
+
+// HTTP returns a Dialer for HTTP servers, whose connections make each
+// request by sending the one req returns, and reading the response body
+// to the end. Responses with an error status fail the request.
+//
+// Each connection is an HTTP client with its own transport, limited to
+// one network connection, so that the load is spread over as many
+// network connections as the generator has connections, as it would be
+// over that many clients.
+func HTTP(req func(n int) (*http.Request, error)) Dialer {
+	return func() (Conn, error) {
+		t := &http.Transport{
+			MaxConnsPerHost:     1,
+			MaxIdleConnsPerHost: 1,
+		}
+		return &httpConn{client: &http.Client{Transport: t}, transport: t, req: req}, nil
+	}
+}
+
+type httpConn struct {
+	client    *http.Client
+	transport *http.Transport
+	req       func(n int) (*http.Request, error)
+}
+
+func (c *httpConn) Do(n int) error {
+	req, err := c.req(n)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return nil
+}
+
+func (c *httpConn) Close() error {
+	c.transport.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,250 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package loadgen generates load against servers for benchmarks of them.
+
+A load generator makes requests over a fixed number of connections, opened
+with a Dialer, in one of two ways. In a closed loop, each connection is a
+client that makes its next request as soon as the previous one completes,
+so the load follows the server's speed. In an open loop, requests are
+offered at a fixed average rate, with exponentially distributed gaps
+between them as for a Poisson process, whatever the server's speed, and
+latency is measured from when each request was due, so that time spent
+queued behind slow requests counts against the server, as it would for
+real users.
+
+Gaps between open-loop requests are drawn from math/rand's global source,
+so seed it for a reproducible schedule.
+*/
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/pool"
+)
+
+// A Conn makes requests to the server under load over one connection.
+// Each Conn is only used by one goroutine at a time.
+type Conn interface {
+	// Do makes request number n, which implementations may use to
+	// choose among kinds of request.
+	Do(n int) error
+
+	// Close closes the connection.
+	Close() error
+}
+
+// A Dialer opens a connection to the server under load.
+type Dialer func() (Conn, error)
+
+// Config describes a run of a load generator.
+type Config struct {
+	// Conns is the number of connections to make requests over. In a
+	// closed loop, it is the number of clients.
+	Conns int
+
+	// Requests is the number of requests to measure.
+	Requests int
+
+	// Rate is the number of requests per second to offer in an open
+	// loop. If it is zero, the requests are made in a closed loop.
+	Rate float64
+
+	// Warmup is the number of requests to make in a closed loop, over
+	// the same connections, before the measured ones. They are not
+	// measured, but fill the server's caches and connection state.
+	Warmup int
+
+	// Progress, if not nil, counts the measured requests as they
+	// complete.
+	Progress *Progress
+
+	// Start, if not nil, is called just before the first measured
+	// request, e.g. to reset a benchmark's timer.
+	Start func()
+}
+
+// Run opens cfg.Conns connections with dial, makes the requests cfg asks
+// for, closes the connections and returns a summary of the measured
+// requests.
+//
+// If ctx is cancelled, Run ramps down gracefully: it stops starting
+// requests, waits for those in flight to complete, and returns a summary
+// of those that completed, without an error. Check ctx for an error in
+// that case.
+func Run(ctx context.Context, dial Dialer, cfg Config) (*Result, error) {
+	if cfg.Conns <= 0 {
+		return nil, errors.New("loadgen: no connections")
+	}
+	conns := make([]Conn, 0, cfg.Conns)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	for i := 0; i < cfg.Conns; i++ {
+		c, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+
+	if cfg.Warmup > 0 {
+		if _, err := run(ctx, closedLoop(conns, cfg.Warmup), nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	var workers []*worker
+	if cfg.Rate > 0 {
+		workers = openLoop(conns, cfg.Rate, cfg.Requests)
+	} else {
+		workers = closedLoop(conns, cfg.Requests)
+	}
+	start := cfg.Start
+	if start == nil {
+		start = func() {}
+	}
+	return run(ctx, workers, cfg.Progress, start)
+}
+
+// run runs workers to completion, recording their requests in progress
+// and returning a summary of them if start is not nil.
+func run(ctx context.Context, workers []*worker, progress *Progress, start func()) (*Result, error) {
+	pw := make([]pool.Worker, 0, len(workers))
+	for _, w := range workers {
+		w.record = start != nil
+		w.progress = progress
+		pw = append(pw, w)
+	}
+	p := pool.New(ctx, pw)
+
+	if start != nil {
+		start()
+	}
+	t := time.Now()
+	if err := p.Run(); err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(t)
+	if start == nil {
+		return nil, nil
+	}
+
+	// Bring all latency measurements together.
+	var n int
+	for _, w := range workers {
+		n += len(w.lat)
+	}
+	latencies := make([]time.Duration, 0, n)
+	for _, w := range workers {
+		latencies = append(latencies, w.lat...)
+	}
+	return Summarize(latencies, elapsed), nil
+}
+
+// A worker makes requests over one connection, as next hands them out.
+type worker struct {
+	conn     Conn
+	next     func(ctx context.Context) (n int, due time.Time, ok bool)
+	record   bool // Whether to measure the requests.
+	progress *Progress
+	lat      []time.Duration
+}
+
+func (w *worker) Run(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return pool.Done
+	}
+	n, due, ok := w.next(ctx)
+	if !ok {
+		return pool.Done
+	}
+	if due.IsZero() {
+		due = time.Now()
+	}
+	if err := w.conn.Do(n); err != nil {
+		return err
+	}
+	if w.record {
+		lat := time.Since(due)
+		w.lat = append(w.lat, lat)
+		w.progress.add(lat)
+	}
+	return nil
+}
+
+// Close does nothing: the connections outlive the workers, which may be
+// used for a warmup and then the measured requests.
+func (w *worker) Close() error {
+	return nil
+}
+
+// closedLoop returns workers that make n requests over conns between
+// them, each as soon as its previous one completes.
+func closedLoop(conns []Conn, n int) []*worker {
+	count := int64(n) // Shared atomic variable.
+	next := func(context.Context) (int, time.Time, bool) {
+		c := atomic.AddInt64(&count, -1)
+		return int(c), time.Time{}, c >= 0
+	}
+	workers := make([]*worker, 0, len(conns))
+	for _, c := range conns {
+		workers = append(workers, &worker{conn: c, next: next, lat: make([]time.Duration, 0, n/len(conns)+1)})
+	}
+	return workers
+}
+
+// request is an open-loop request.
+type request struct {
+	due time.Time // When the request should be sent.
+	n   int
+}
+
+// openLoop returns workers that make n requests over conns, offered at
+// rate requests per second from when the first of them asks for one.
+func openLoop(conns []Conn, rate float64, n int) []*worker {
+	// Requests wait here for a free connection. The buffer is big enough
+	// that the generator never blocks, so it keeps to its schedule however
+	// far behind the server falls.
+	schedule := make(chan request, n)
+	var once atomic.Bool
+	generate := func(ctx context.Context) {
+		defer close(schedule)
+		due := time.Now()
+		for i := 0; i < n; i++ {
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+			schedule <- request{due: due, n: i}
+			due = due.Add(time.Duration(rand.ExpFloat64() / rate * float64(time.Second)))
+		}
+	}
+	next := func(ctx context.Context) (int, time.Time, bool) {
+		if once.CompareAndSwap(false, true) {
+			go generate(ctx)
+		}
+		select {
+		case req, ok := <-schedule:
+			return req.n, req.due, ok
+		case <-ctx.Done():
+			return 0, time.Time{}, false
+		}
+	}
+	workers := make([]*worker, 0, len(conns))
+	for _, c := range conns {
+		workers = append(workers, &worker{conn: c, next: next, lat: make([]time.Duration, 0, n/len(conns)+1)})
+	}
+	return workers
+}
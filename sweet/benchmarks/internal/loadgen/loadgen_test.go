@@ -0,0 +1,216 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer counts the requests made to it over its connections.
+type fakeServer struct {
+	delay    time.Duration
+	fail     int // Request number to fail, if positive.
+	requests atomic.Int64
+	open     atomic.Int64 // Connections open.
+
+	mu   sync.Mutex
+	seen map[int]bool
+}
+
+func (s *fakeServer) dial() (Conn, error) {
+	s.open.Add(1)
+	return &fakeConn{s}, nil
+}
+
+type fakeConn struct{ s *fakeServer }
+
+func (c *fakeConn) Do(n int) error {
+	c.s.requests.Add(1)
+	c.s.mu.Lock()
+	if c.s.seen == nil {
+		c.s.seen = make(map[int]bool)
+	}
+	c.s.seen[n] = true
+	c.s.mu.Unlock()
+	if c.s.fail > 0 && n == c.s.fail {
+		return errors.New("request failed")
+	}
+	time.Sleep(c.s.delay)
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.s.open.Add(-1)
+	return nil
+}
+
+func TestClosedLoop(t *testing.T) {
+	s := &fakeServer{delay: time.Millisecond}
+	var progress Progress
+	started := 0
+	res, err := Run(context.Background(), s.dial, Config{
+		Conns:    4,
+		Requests: 100,
+		Warmup:   20,
+		Progress: &progress,
+		Start:    func() { started++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 100 {
+		t.Errorf("measured %d requests, want 100", res.Requests)
+	}
+	if got := s.requests.Load(); got != 120 {
+		t.Errorf("made %d requests, want 120 with the warmup", got)
+	}
+	if got := progress.requests.Load(); got != 100 {
+		t.Errorf("progress counted %d requests, want 100", got)
+	}
+	if started != 1 {
+		t.Errorf("Start called %d times, want 1", started)
+	}
+	if got := s.open.Load(); got != 0 {
+		t.Errorf("%d connections left open", got)
+	}
+	if res.P50 < time.Millisecond || res.P50 > res.P999 || res.Latency < 100*time.Millisecond {
+		t.Errorf("implausible latencies %+v", res)
+	}
+	if ops := res.OpsPerSec(); ops <= 0 || ops > 4000 {
+		t.Errorf("%f ops/s, want at most 4 connections' worth", ops)
+	}
+}
+
+func TestOpenLoop(t *testing.T) {
+	s := &fakeServer{}
+	start := time.Now()
+	res, err := Run(context.Background(), s.dial, Config{
+		Conns:    2,
+		Requests: 50,
+		Rate:     1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 50 {
+		t.Errorf("measured %d requests, want 50", res.Requests)
+	}
+	for n := 0; n < 50; n++ {
+		if !s.seen[n] {
+			t.Errorf("request %d not made", n)
+		}
+	}
+	// 50 requests at 1000 per second should take around 50ms.
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("open loop took %v, want it to keep to its schedule", elapsed)
+	}
+}
+
+func TestRampDown(t *testing.T) {
+	s := &fakeServer{delay: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res, err := Run(ctx, s.dial, Config{Conns: 2, Requests: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests == 0 || res.Requests >= 1000 {
+		t.Errorf("measured %d requests, want some but not all before the deadline", res.Requests)
+	}
+	if got := s.requests.Load(); got != int64(res.Requests) {
+		t.Errorf("made %d requests but measured %d; in-flight requests were abandoned", got, res.Requests)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	s := &fakeServer{fail: 10}
+	if _, err := Run(context.Background(), s.dial, Config{Conns: 2, Requests: 100, Rate: 10000}); err == nil {
+		t.Error("run with a failing request succeeded")
+	}
+	if got := s.open.Load(); got != 0 {
+		t.Errorf("%d connections left open", got)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	path := "/"
+	dial := HTTP(func(n int) (*http.Request, error) {
+		return http.NewRequest("GET", srv.URL+path, nil)
+	})
+	res, err := Run(context.Background(), dial, Config{Conns: 3, Requests: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 30 || requests.Load() != 30 {
+		t.Errorf("measured %d requests and served %d, want 30", res.Requests, requests.Load())
+	}
+
+	path = "/missing"
+	if _, err := Run(context.Background(), dial, Config{Conns: 1, Requests: 1}); err == nil {
+		t.Error("request for a missing page succeeded")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var lat []time.Duration
+	for i := 1000; i > 0; i-- {
+		lat = append(lat, time.Duration(i))
+	}
+	r := Summarize(lat, time.Second)
+	if r.Requests != 1000 || r.P50 != 501 || r.P90 != 901 || r.P99 != 991 || r.P999 != 1000 || r.Latency != 500500 {
+		t.Errorf("got %+v", r)
+	}
+	if r := Summarize(nil, time.Second); r.Requests != 0 || r.P50 != 0 {
+		t.Errorf("got %+v for no requests", r)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	var p Progress
+	p.add(5) // Before sampling started.
+	sample := p.Sampler()
+	for _, tc := range []struct {
+		lats  []time.Duration
+		since time.Duration
+		want  map[string]float64
+	}{
+		{[]time.Duration{10, 30}, time.Second, map[string]float64{"ops/s": 2, "avg-latency-ns": 20}},
+		{[]time.Duration{10, 20, 30, 40}, 2 * time.Second, map[string]float64{"ops/s": 2, "avg-latency-ns": 25}},
+		{nil, time.Second, map[string]float64{"ops/s": 0}},
+	} {
+		for _, l := range tc.lats {
+			p.add(l)
+		}
+		got := sample(tc.since)
+		if len(got) != len(tc.want) {
+			t.Errorf("after %v over %v, got %v, want %v", tc.lats, tc.since, got, tc.want)
+			continue
+		}
+		for k, v := range tc.want {
+			if got[k] != v {
+				t.Errorf("after %v over %v, got %v, want %v", tc.lats, tc.since, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loadgen
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+)
+
+// Result summarizes the requests made by a load generator. Benchmarks
+// whose clients run on another host send it back as JSON.
+type Result struct {
+	Requests int           `json:"requests"`
+	Elapsed  time.Duration `json:"elapsed"`      // Time taken to make all the requests.
+	Latency  time.Duration `json:"totalLatency"` // Sum of all request latencies.
+	P50      time.Duration `json:"p50"`
+	P90      time.Duration `json:"p90"`
+	P99      time.Duration `json:"p99"`
+	P999     time.Duration `json:"p99.9"`
+}
+
+// Summarize returns a summary of the latencies in lat, which it sorts,
+// of requests that took elapsed time to make.
+func Summarize(lat []time.Duration, elapsed time.Duration) *Result {
+	r := &Result{Requests: len(lat), Elapsed: elapsed}
+	if len(lat) == 0 {
+		return r
+	}
+	slices.Sort(lat)
+	r.P50 = lat[len(lat)*50/100]
+	r.P90 = lat[len(lat)*90/100]
+	r.P99 = lat[len(lat)*99/100]
+	r.P999 = lat[len(lat)*999/1000]
+	for _, l := range lat {
+		r.Latency += l
+	}
+	return r
+}
+
+// ReportLatencies reports the latency percentiles in r.
+func (r *Result) ReportLatencies(d *driver.B) {
+	d.Report("p50-latency-ns", uint64(r.P50))
+	d.Report("p90-latency-ns", uint64(r.P90))
+	d.Report("p99-latency-ns", uint64(r.P99))
+	d.Report("p99.9-latency-ns", uint64(r.P999))
+}
+
+// OpsPerSec returns the throughput achieved.
+func (r *Result) OpsPerSec() float64 {
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// Progress counts the requests a load generator has completed, for
+// sampling while it runs.
+type Progress struct {
+	requests atomic.Int64
+	latency  atomic.Int64 // Sum of the requests' latencies, in nanoseconds.
+}
+
+func (p *Progress) add(lat time.Duration) {
+	if p == nil {
+		return
+	}
+	p.requests.Add(1)
+	p.latency.Add(int64(lat))
+}
+
+// Sampler returns a function for d.SampleEvery that measures the
+// throughput and average latency of the requests completed since it last
+// ran, or since Sampler was called, which was the given time ago.
+func (p *Progress) Sampler() func(since time.Duration) map[string]float64 {
+	lastReqs, lastLat := p.requests.Load(), p.latency.Load()
+	return func(since time.Duration) map[string]float64 {
+		reqs, lat := p.requests.Load(), p.latency.Load()
+		m := map[string]float64{"ops/s": float64(reqs-lastReqs) / since.Seconds()}
+		if reqs > lastReqs {
+			m["avg-latency-ns"] = float64(lat-lastLat) / float64(reqs-lastReqs)
+		}
+		lastReqs, lastLat = reqs, lat
+		return m
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ClientConfig describes where a server benchmark runs its clients.
+//
+// By default clients run on the same machine as the server, where they
+// compete with it for CPU and memory. In two-host mode, they instead run
+// on a separate client host, reached over SSH, and connect to the server
+// over the network.
+type ClientConfig struct {
+	// Host is the SSH destination of the client host, e.g. user@host.
+	// If empty, clients run locally.
+	Host string
+
+	// Dir is the directory on Host containing the client binaries.
+	Dir string
+
+	// ServerHost is the address of this machine as seen from Host.
+	ServerHost string
+}
+
+// SetFlags registers flags for c on f.
+func (c *ClientConfig) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.Host, "client-host", "", "SSH destination of a separate machine to run clients on (default: run them locally)")
+	f.StringVar(&c.Dir, "client-dir", "", "directory on -client-host containing the client binaries")
+	f.StringVar(&c.ServerHost, "server-host", "", "address of this machine as seen from -client-host")
+}
+
+// Remote reports whether clients run on a separate host.
+func (c *ClientConfig) Remote() bool {
+	return c.Host != ""
+}
+
+// ListenHost returns the host servers should listen on, given the one
+// they would use if clients were local. In two-host mode servers must
+// accept connections from other machines, so they listen on all
+// interfaces.
+func (c *ClientConfig) ListenHost(local string) string {
+	if c.Remote() {
+		return "0.0.0.0"
+	}
+	return local
+}
+
+// ServerAddr returns the host clients should connect to, given the one
+// they would use if they were local.
+func (c *ClientConfig) ServerAddr(local string) string {
+	if c.Remote() {
+		return c.ServerHost
+	}
+	return local
+}
+
+// Command returns a command that runs the client binary bin, given by its
+// local path, with args and the extra environment variables env. In
+// two-host mode, the command runs bin from Dir on Host over SSH, and its
+// standard output and error are relayed back.
+//
+// Killing the returned command only kills the local SSH process, which
+// leaves it up to the SSH server to hang up on the remote one.
+func (c *ClientConfig) Command(env []string, bin string, args ...string) *exec.Cmd {
+	return c.CommandContext(context.Background(), env, bin, args...)
+}
+
+// CommandContext is like Command but includes a context.
+func (c *ClientConfig) CommandContext(ctx context.Context, env []string, bin string, args ...string) *exec.Cmd {
+	if !c.Remote() {
+		cmd := exec.CommandContext(ctx, bin, args...)
+		cmd.Env = append(os.Environ(), env...)
+		return cmd
+	}
+	// ssh passes the remote shell a single command line, so quote
+	// each word.
+	words := []string{"exec", "env"}
+	for _, s := range env {
+		words = append(words, shellQuote(s))
+	}
+	words = append(words, shellQuote(path.Join(c.Dir, filepath.Base(bin))))
+	for _, s := range args {
+		words = append(words, shellQuote(s))
+	}
+	return exec.CommandContext(ctx, "ssh", "-o", "BatchMode=yes", c.Host, strings.Join(words, " "))
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
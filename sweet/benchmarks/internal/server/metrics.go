@@ -0,0 +1,73 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ScrapeMetrics fetches the Prometheus metrics served at http://host/metrics
+// and returns the value of each sample, keyed by the metric name and labels
+// as they appear in the text exposition format, for example
+// `go_gc_duration_seconds_sum` or `go_gc_duration_seconds{quantile="0.5"}`.
+func ScrapeMetrics(host string) (map[string]float64, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", host))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching metrics from %s: %s", host, resp.Status)
+	}
+	return parseMetrics(resp.Body)
+}
+
+// parseMetrics parses samples in the Prometheus text exposition format
+// from r, ignoring comments and timestamps.
+func parseMetrics(r io.Reader) (map[string]float64, error) {
+	metrics := make(map[string]float64)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		// The name ends at the first space, unless it has labels, whose
+		// quoted values may themselves contain spaces.
+		end := strings.IndexAny(line, "{ ")
+		if end >= 0 && line[end] == '{' {
+			quoted := false
+			for end++; end < len(line); end++ {
+				if c := line[end]; c == '\\' && quoted {
+					end++
+				} else if c == '"' {
+					quoted = !quoted
+				} else if c == '}' && !quoted {
+					end++
+					break
+				}
+			}
+		}
+		if end < 0 || end >= len(line) {
+			return nil, fmt.Errorf("malformed metrics line %q", line)
+		}
+		fields := strings.Fields(line[end:])
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("malformed metrics line %q", line)
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed metrics line %q: %v", line, err)
+		}
+		metrics[line[:end]] = v
+	}
+	return metrics, s.Err()
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// etcdMetrics is an excerpt of what etcd serves at /metrics.
+const etcdMetrics = `# HELP go_gc_duration_seconds A summary of the pause duration of garbage collection cycles.
+# TYPE go_gc_duration_seconds summary
+go_gc_duration_seconds{quantile="0"} 2.1731e-05
+go_gc_duration_seconds{quantile="1"} 0.000417843
+go_gc_duration_seconds_sum 0.012093254
+go_gc_duration_seconds_count 87
+# HELP go_memstats_heap_inuse_bytes Number of heap bytes that are in use.
+# TYPE go_memstats_heap_inuse_bytes gauge
+go_memstats_heap_inuse_bytes 1.4843904e+07
+
+# HELP etcd_server_version Which version is running. 1 for 'server_version' label with current version.
+# TYPE etcd_server_version gauge
+etcd_server_version{server_version="3.5.15"} 1
+grpc_server_handled_total{grpc_code="OK",grpc_method="Range",grpc_service="etcdserverpb.KV",grpc_type="unary"} 1502 1712000000000
+odd_label{path="a } b",note="say \"hi\" }"} 3
+`
+
+func TestParseMetrics(t *testing.T) {
+	got, err := parseMetrics(strings.NewReader(etcdMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		`go_gc_duration_seconds{quantile="0"}`:         2.1731e-05,
+		`go_gc_duration_seconds{quantile="1"}`:         0.000417843,
+		"go_gc_duration_seconds_sum":                   0.012093254,
+		"go_gc_duration_seconds_count":                 87,
+		"go_memstats_heap_inuse_bytes":                 1.4843904e+07,
+		`etcd_server_version{server_version="3.5.15"}`: 1,
+		`odd_label{path="a } b",note="say \"hi\" }"}`:  3,
+		`grpc_server_handled_total{grpc_code="OK",grpc_method="Range",grpc_service="etcdserverpb.KV",grpc_type="unary"}`: 1502,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestParseMetricsMalformed(t *testing.T) {
+	for _, line := range []string{
+		"no_value",
+		"no_value ",
+		`unclosed{label="x" 1`,
+		"bad_value one",
+	} {
+		if _, err := parseMetrics(strings.NewReader(line + "\n")); err == nil {
+			t.Errorf("parseMetrics(%q) succeeded, want error", line)
+		}
+	}
+}
+
+func TestScrapeMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "up 1\n")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	got, err := ScrapeMetrics(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"up": 1}; !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	srv.Config.Handler = http.NotFoundHandler()
+	if _, err := ScrapeMetrics(host); err == nil {
+		t.Error("ScrapeMetrics of a missing endpoint succeeded, want error")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// maxPortAttempts bounds how many ports Reserve tries before giving up.
+const maxPortAttempts = 1000
+
+// PortAllocator hands out free TCP ports for servers to listen on, instead
+// of fixed ports that collide with those of benchmarks running concurrently
+// and of servers left behind by earlier runs.
+//
+// A port is free if the kernel can bind it. Between finding that out and the
+// server binding it, another benchmark could pick the same port, so each
+// port is also reserved with a lock file in Dir, which all benchmarks that
+// may run concurrently should share.
+type PortAllocator struct {
+	// Dir is the directory holding lock files for reserved ports. If it is
+	// empty, ports are not reserved.
+	Dir string
+
+	locks []string
+}
+
+// SetFlags registers the allocator's flags in f.
+func (a *PortAllocator) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&a.Dir, "ports-dir", "", "directory in which to reserve server ports against concurrently running benchmarks")
+}
+
+// Reserve returns n distinct free ports, reserved until Release is called.
+func (a *PortAllocator) Reserve(n int) ([]int, error) {
+	if a.Dir != "" {
+		if err := os.MkdirAll(a.Dir, 0777); err != nil {
+			return nil, err
+		}
+	}
+	var ports []int
+	for i := 0; len(ports) < n; i++ {
+		if i == maxPortAttempts {
+			return nil, fmt.Errorf("failed to reserve %d ports after %d attempts", n, i)
+		}
+		port, err := freePort()
+		if err != nil {
+			return nil, err
+		}
+		ok, err := a.lock(port)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// Release gives up all the ports reserved by a.
+func (a *PortAllocator) Release() {
+	for _, lock := range a.locks {
+		os.Remove(lock)
+	}
+	a.locks = nil
+}
+
+// freePort returns a port that the kernel will currently let a server
+// listen on, on all interfaces.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, fmt.Errorf("finding a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// lock attempts to reserve port, reporting whether it succeeded. A
+// reservation left by a process that no longer exists is taken over.
+func (a *PortAllocator) lock(port int) (bool, error) {
+	if a.Dir == "" {
+		return true, nil
+	}
+	name := filepath.Join(a.Dir, fmt.Sprintf("%d.lock", port))
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(name)
+				return false, err
+			}
+			a.locks = append(a.locks, name)
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return false, err
+		}
+		if !staleLock(name) {
+			return false, nil
+		}
+		os.Remove(name)
+	}
+	return false, nil
+}
+
+// staleLock reports whether the process that wrote the lock file name has
+// exited.
+func staleLock(name string) bool {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		// Removed in the meantime, or still being written.
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return true
+	}
+	return !processAlive(p)
+}
+
+// processAlive reports whether p is still running.
+func processAlive(p *os.Process) bool {
+	if runtime.GOOS == "windows" {
+		// FindProcess only succeeds for running processes.
+		return true
+	}
+	err := p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPortAllocatorReserve(t *testing.T) {
+	a := PortAllocator{Dir: filepath.Join(t.TempDir(), "ports")}
+	ports, err := a.Reserve(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 3 {
+		t.Fatalf("reserved %d ports, want 3", len(ports))
+	}
+	seen := make(map[int]bool)
+	for _, p := range ports {
+		if seen[p] {
+			t.Errorf("port %d reserved twice in %v", p, ports)
+		}
+		seen[p] = true
+		data, err := os.ReadFile(filepath.Join(a.Dir, fmt.Sprintf("%d.lock", p)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(data), fmt.Sprintf("%d\n", os.Getpid()); got != want {
+			t.Errorf("lock file for port %d holds %q, want %q", p, got, want)
+		}
+	}
+
+	a.Release()
+	entries, err := os.ReadDir(a.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d lock files left after Release", len(entries))
+	}
+}
+
+func TestPortAllocatorNoDir(t *testing.T) {
+	var a PortAllocator
+	ports, err := a.Reserve(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 2 || ports[0] == ports[1] {
+		t.Errorf("got ports %v, want 2 distinct ports", ports)
+	}
+	if len(a.locks) != 0 {
+		t.Errorf("took locks %v without a directory", a.locks)
+	}
+}
+
+func TestPortAllocatorLock(t *testing.T) {
+	dir := t.TempDir()
+	a := PortAllocator{Dir: dir}
+	b := PortAllocator{Dir: dir}
+
+	if ok, err := a.lock(1234); err != nil || !ok {
+		t.Fatalf("first lock of port 1234: %v, %v", ok, err)
+	}
+	// This process is alive, so its reservation holds.
+	if ok, err := b.lock(1234); err != nil || ok {
+		t.Errorf("second lock of port 1234: %v, %v; want false", ok, err)
+	}
+	a.Release()
+	if ok, err := b.lock(1234); err != nil || !ok {
+		t.Errorf("lock of released port 1234: %v, %v; want true", ok, err)
+	}
+	b.Release()
+
+	// A reservation by a process that has exited is taken over. PIDs
+	// are bounded well below this one on every system we run on.
+	stale := filepath.Join(dir, "4321.lock")
+	if err := os.WriteFile(stale, []byte("2147483646\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.lock(4321); err != nil || !ok {
+		t.Errorf("lock of stale port 4321: %v, %v; want true", ok, err)
+	}
+	a.Release()
+
+	// A lock file that can't be attributed to a process is left alone.
+	bad := filepath.Join(dir, "5678.lock")
+	if err := os.WriteFile(bad, []byte("not a pid\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.lock(5678); err != nil || ok {
+		t.Errorf("lock of port 5678 with a malformed lock file: %v, %v; want false", ok, err)
+	}
+	if _, err := os.Stat(bad); err != nil {
+		t.Errorf("malformed lock file was removed: %v", err)
+	}
+}
@@ -18,12 +18,49 @@ import (
 	"golang.org/x/benchmarks/sweet/common/diagnostics"
 )
 
+// An Endpoint is where a server serves its HTTP endpoints, such as pprof's.
+type Endpoint struct {
+	// URL is the URL the endpoints' paths are relative to, e.g.
+	// https://localhost:8080.
+	URL string
+
+	// Client makes the requests, or http.DefaultClient if nil, e.g. to
+	// trust the certificate of a server that uses TLS.
+	Client *http.Client
+
+	// Header holds headers to add to every request, e.g. a session
+	// cookie for a server whose endpoints need a login.
+	Header http.Header
+}
+
+// Get requests path, relative to e.URL.
+func (e Endpoint) Get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", e.URL+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.Header {
+		req.Header[k] = v
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
 // FetchDiagnostic reads a profile or trace from the pprof endpoint at host. The
 // returned stop function finalizes the diagnostic file on disk and returns the
 // total size in bytes. Because of limitations of net/http/pprof, this cannot
 // actually stop collection on the server side, so stop should only be called
 // when the server is about to be shut down.
 func FetchDiagnostic(host string, diag *driver.Diagnostics, typ diagnostics.Type, name string) (stop func()) {
+	return FetchDiagnosticFrom(Endpoint{URL: "http://" + host}, diag, typ, name)
+}
+
+// FetchDiagnosticFrom is like FetchDiagnostic, but reads from the pprof
+// endpoint of ep.
+func FetchDiagnosticFrom(ep Endpoint, diag *driver.Diagnostics, typ diagnostics.Type, name string) (stop func()) {
 	if typ.HTTPEndpoint() == "" {
 		panic("diagnostic " + string(typ) + " has no endpoint")
 	}
@@ -35,7 +72,7 @@ func FetchDiagnostic(host string, diag *driver.Diagnostics, typ diagnostics.Type
 	// If this is a snapshot-type diagnostic, wait until the end to collect it.
 	if typ.IsSnapshot() {
 		return func() {
-			err := collectTo(context.Background(), host, diag, typ, name)
+			err := collectTo(context.Background(), ep, diag, typ, name)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "failed to read diagnostic %s: %v", typ, err)
 			}
@@ -61,7 +98,7 @@ func FetchDiagnostic(host string, diag *driver.Diagnostics, typ diagnostics.Type
 		}
 
 		for {
-			err := collectTo(ctx1, host, diag, typ, name)
+			err := collectTo(ctx1, ep, diag, typ, name)
 			ctx1 = ctx
 			if err != nil {
 				if !errors.Is(err, context.Canceled) {
@@ -81,29 +118,27 @@ func FetchDiagnostic(host string, diag *driver.Diagnostics, typ diagnostics.Type
 	}
 }
 
-func collectTo(ctx context.Context, host string, diag *driver.Diagnostics, typ diagnostics.Type, name string) error {
-	// Construct the endpoint URL
-	var endpoint string
-	endpoint = fmt.Sprintf("http://%s/%s", host, typ.HTTPEndpoint())
+func collectTo(ctx context.Context, ep Endpoint, diag *driver.Diagnostics, typ diagnostics.Type, name string) error {
+	// Construct the endpoint path
+	path := typ.HTTPEndpoint()
 	if typ.CanMerge() && !typ.CanTruncate() {
 		// Collect in lots of small increments because we won't be able to just
 		// stop it.
-		endpoint += "?seconds=1"
+		path += "?seconds=1"
 	} else if typ.CanTruncate() {
 		// Collect a long run that we can cut off.
-		endpoint += "?seconds=999999"
+		path += "?seconds=999999"
 	}
 
 	// Start profile collection.
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
-	if err != nil {
-		return err
-	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ep.Get(ctx, path)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s from %s: %s", typ, ep.URL, resp.Status)
+	}
 
 	// Read into a diagnostic file
 	f, err := diag.CreateNamed(typ, name)
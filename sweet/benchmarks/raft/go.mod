@@ -0,0 +1,19 @@
+module golang.org/x/benchmarks/sweet/benchmarks/raft
+
+go 1.23.0
+
+require (
+	go.etcd.io/raft/v3 v3.6.0
+	golang.org/x/benchmarks v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/golang/protobuf v1.5.4 // indirect
+	github.com/google/pprof v0.0.0-20241017200806-017d972448fc // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	google.golang.org/protobuf v1.34.1 // indirect
+)
+
+// The benchmark driver lives in the parent module.
+replace golang.org/x/benchmarks => ../../..
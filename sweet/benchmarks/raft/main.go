@@ -0,0 +1,493 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/pool"
+
+	"go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+type config struct {
+	clusterSizes []int
+	clients      int
+	proposals    int
+	payloadBytes int
+	latency      time.Duration
+	short        bool
+}
+
+var (
+	cliCfg    config
+	sizesFlag string
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&sizesFlag, "nodes", "3,5", "comma-separated list of cluster sizes")
+	flag.IntVar(&cliCfg.clients, "clients", 64, "number of concurrent clients proposing to the leader")
+	flag.IntVar(&cliCfg.proposals, "proposals", 200000, "number of proposals to commit for each cluster size")
+	flag.IntVar(&cliCfg.payloadBytes, "payload-bytes", 128, "size of each proposal in bytes")
+	flag.DurationVar(&cliCfg.latency, "latency", 200*time.Microsecond, "simulated one-way network latency between nodes")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+const (
+	// tickInterval is how often each node's logical clock ticks. With
+	// the heartbeat and election ticks below, it gives the same timeouts
+	// as etcd's defaults.
+	tickInterval  = 10 * time.Millisecond
+	heartbeatTick = 10
+	electionTick  = 100
+
+	// Each node keeps at least snapshotCatchUp entries behind its
+	// applied index in its log, and compacts it every snapshotInterval
+	// applied entries, so that memory use doesn't grow with the length
+	// of the run.
+	snapshotInterval = 10000
+	snapshotCatchUp  = 5000
+
+	// proposalTimeout bounds how long a client waits for its proposal
+	// to be applied. Proposals are only lost if leadership changes,
+	// which shouldn't happen in a healthy run.
+	proposalTimeout = 10 * time.Second
+
+	// numKeys is the number of distinct keys in each node's state machine.
+	numKeys = 1024
+)
+
+// link delivers messages from one node to another, in order, each after the
+// simulated network latency.
+type link struct {
+	to      *node
+	latency time.Duration
+	msgs    chan timedMessage
+}
+
+type timedMessage struct {
+	deliverAt time.Time
+	msg       raftpb.Message
+}
+
+func (l *link) send(ctx context.Context, m raftpb.Message) {
+	select {
+	case l.msgs <- timedMessage{time.Now().Add(l.latency), m}:
+	case <-ctx.Done():
+	}
+}
+
+func (l *link) run(ctx context.Context, from *node) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tm := <-l.msgs:
+			if d := time.Until(tm.deliverAt); d > 0 {
+				time.Sleep(d)
+			}
+			err := l.to.Step(ctx, tm.msg)
+			if tm.msg.Type == raftpb.MsgSnap {
+				status := raft.SnapshotFinish
+				if err != nil {
+					status = raft.SnapshotFailure
+				}
+				from.ReportSnapshot(tm.msg.To, status)
+			}
+		}
+	}
+}
+
+// node is a member of the cluster: a raft node, its in-memory log, and a
+// trivial key-value state machine.
+type node struct {
+	raft.Node
+	id      uint64
+	storage *raft.MemoryStorage
+	links   map[uint64]*link
+
+	confState raftpb.ConfState
+	applied   uint64
+	kv        map[uint64][]byte
+
+	// onApply, if not nil, is called with the data of every normal entry
+	// the node applies.
+	onApply func(data []byte)
+}
+
+// run is the node's main loop, which persists, sends and applies
+// everything that raft hands it, in that order.
+func (n *node) run(ctx context.Context) error {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			n.Tick()
+		case rd := <-n.Ready():
+			if !raft.IsEmptySnap(rd.Snapshot) {
+				if err := n.storage.ApplySnapshot(rd.Snapshot); err != nil {
+					return err
+				}
+				n.confState = rd.Snapshot.Metadata.ConfState
+				n.applied = rd.Snapshot.Metadata.Index
+			}
+			if !raft.IsEmptyHardState(rd.HardState) {
+				if err := n.storage.SetHardState(rd.HardState); err != nil {
+					return err
+				}
+			}
+			if err := n.storage.Append(rd.Entries); err != nil {
+				return err
+			}
+			for _, m := range rd.Messages {
+				n.links[m.To].send(ctx, m)
+			}
+			for _, e := range rd.CommittedEntries {
+				if err := n.apply(e); err != nil {
+					return err
+				}
+			}
+			if err := n.maybeCompact(); err != nil {
+				return err
+			}
+			n.Advance()
+		}
+	}
+}
+
+func (n *node) apply(e raftpb.Entry) error {
+	if e.Index <= n.applied {
+		return nil // Already covered by a snapshot.
+	}
+	n.applied = e.Index
+	switch e.Type {
+	case raftpb.EntryNormal:
+		if len(e.Data) == 0 {
+			// Empty entries are appended by new leaders.
+			return nil
+		}
+		key := binary.LittleEndian.Uint64(e.Data) % numKeys
+		n.kv[key] = append(n.kv[key][:0], e.Data[8:]...)
+		if n.onApply != nil {
+			n.onApply(e.Data)
+		}
+	case raftpb.EntryConfChange:
+		var cc raftpb.ConfChange
+		if err := cc.Unmarshal(e.Data); err != nil {
+			return err
+		}
+		n.confState = *n.ApplyConfChange(cc)
+	case raftpb.EntryConfChangeV2:
+		var cc raftpb.ConfChangeV2
+		if err := cc.Unmarshal(e.Data); err != nil {
+			return err
+		}
+		n.confState = *n.ApplyConfChange(cc)
+	}
+	return nil
+}
+
+func (n *node) maybeCompact() error {
+	first, err := n.storage.FirstIndex()
+	if err != nil {
+		return err
+	}
+	if n.applied < first+snapshotInterval+snapshotCatchUp {
+		return nil
+	}
+	// The state machine isn't needed to catch up a follower that's fallen
+	// this far behind, so leave it out of the snapshot.
+	if _, err := n.storage.CreateSnapshot(n.applied, &n.confState, nil); err != nil {
+		return err
+	}
+	return n.storage.Compact(n.applied - snapshotCatchUp)
+}
+
+// cluster is a set of nodes connected by a simulated network.
+type cluster struct {
+	nodes  []*node
+	cancel context.CancelFunc
+	errc   chan error
+	wg     sync.WaitGroup
+}
+
+// newCluster starts a cluster of size nodes, the first of which calls
+// onApply for every entry it applies.
+func newCluster(size int, latency time.Duration, onApply func(data []byte)) *cluster {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cluster{cancel: cancel, errc: make(chan error, size)}
+	peers := make([]raft.Peer, size)
+	for i := range peers {
+		peers[i] = raft.Peer{ID: uint64(i + 1)}
+	}
+	logger := &raft.DefaultLogger{Logger: log.New(io.Discard, "", 0)}
+	for i := 0; i < size; i++ {
+		storage := raft.NewMemoryStorage()
+		n := &node{
+			id:      uint64(i + 1),
+			storage: storage,
+			links:   make(map[uint64]*link),
+			kv:      make(map[uint64][]byte),
+		}
+		n.Node = raft.StartNode(&raft.Config{
+			ID:              n.id,
+			ElectionTick:    electionTick,
+			HeartbeatTick:   heartbeatTick,
+			Storage:         storage,
+			MaxSizePerMsg:   1 << 20,
+			MaxInflightMsgs: 256,
+			PreVote:         true,
+			Logger:          logger,
+		}, peers)
+		c.nodes = append(c.nodes, n)
+	}
+	c.nodes[0].onApply = onApply
+	for _, from := range c.nodes {
+		for _, to := range c.nodes {
+			if from == to {
+				continue
+			}
+			l := &link{to: to, latency: latency, msgs: make(chan timedMessage, 1024)}
+			from.links[to.id] = l
+			c.wg.Add(1)
+			go func(from *node) {
+				defer c.wg.Done()
+				l.run(ctx, from)
+			}(from)
+		}
+	}
+	for _, n := range c.nodes {
+		c.wg.Add(1)
+		go func(n *node) {
+			defer c.wg.Done()
+			if err := n.run(ctx); err != nil {
+				c.errc <- fmt.Errorf("node %d: %w", n.id, err)
+				cancel()
+			}
+		}(n)
+	}
+	return c
+}
+
+// elect makes the first node the leader, and waits until it is.
+func (c *cluster) elect() (*node, error) {
+	leader := c.nodes[0]
+	deadline := time.Now().Add(10 * time.Second)
+	for leader.Status().RaftState != raft.StateLeader {
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out waiting for a leader to be elected")
+		}
+		// Raft ignores the campaign until the node has applied the
+		// configuration it was started with, so keep trying.
+		if err := leader.Campaign(context.Background()); err != nil {
+			return nil, err
+		}
+		time.Sleep(10 * tickInterval)
+	}
+	return leader, nil
+}
+
+func (c *cluster) stop() error {
+	c.cancel()
+	for _, n := range c.nodes {
+		n.Stop()
+	}
+	c.wg.Wait()
+	select {
+	case err := <-c.errc:
+		return err
+	default:
+		return nil
+	}
+}
+
+// waiters tracks the proposals that clients are waiting to see applied.
+type waiters struct {
+	mu sync.Mutex
+	m  map[uint64]chan struct{}
+}
+
+func (w *waiters) add(id uint64) <-chan struct{} {
+	ch := make(chan struct{})
+	w.mu.Lock()
+	w.m[id] = ch
+	w.mu.Unlock()
+	return ch
+}
+
+func (w *waiters) done(data []byte) {
+	id := binary.LittleEndian.Uint64(data)
+	w.mu.Lock()
+	ch, ok := w.m[id]
+	delete(w.m, id)
+	w.mu.Unlock()
+	if ok {
+		close(ch)
+	}
+}
+
+type worker struct {
+	leader    *node
+	waiters   *waiters
+	nextID    *uint64 // Accessed atomically.
+	iterCount *int64  // Accessed atomically.
+	payload   []byte
+	lat       []time.Duration
+}
+
+func (w *worker) Run(ctx context.Context) error {
+	if atomic.AddInt64(w.iterCount, -1) < 0 {
+		return pool.Done
+	}
+	// Raft keeps hold of proposed data, so each proposal needs its own.
+	data := append([]byte(nil), w.payload...)
+	id := atomic.AddUint64(w.nextID, 1)
+	binary.LittleEndian.PutUint64(data, id)
+	applied := w.waiters.add(id)
+
+	start := time.Now()
+	if err := w.leader.Propose(ctx, data); err != nil {
+		return err
+	}
+	select {
+	case <-applied:
+	case <-time.After(proposalTimeout):
+		return fmt.Errorf("proposal %d was not applied within %s; did leadership change?", id, proposalTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	w.lat = append(w.lat, time.Since(start))
+	return nil
+}
+
+func (w *worker) Close() error {
+	return nil
+}
+
+type durSlice []time.Duration
+
+func (d durSlice) Len() int           { return len(d) }
+func (d durSlice) Less(i, j int) bool { return d[i] < d[j] }
+func (d durSlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func runBenchmark(d *driver.B, cfg *config, size int) (err error) {
+	ws := &waiters{m: make(map[uint64]chan struct{})}
+	c := newCluster(size, cfg.latency, ws.done)
+	defer func() {
+		if stopErr := c.stop(); err == nil {
+			err = stopErr
+		}
+	}()
+	leader, err := c.elect()
+	if err != nil {
+		return err
+	}
+
+	iters := cfg.proposals
+	if cfg.short {
+		iters = 1000
+	}
+	iterCount := int64(iters) // Shared atomic variable.
+	var nextID uint64         // Shared atomic variable.
+	r := rand.New(rand.NewSource(0))
+	workers := make([]pool.Worker, 0, cfg.clients)
+	for i := 0; i < cfg.clients; i++ {
+		payload := make([]byte, cfg.payloadBytes)
+		r.Read(payload)
+		workers = append(workers, &worker{
+			leader:    leader,
+			waiters:   ws,
+			nextID:    &nextID,
+			iterCount: &iterCount,
+			payload:   payload,
+			lat:       make([]time.Duration, 0, iters/cfg.clients+1),
+		})
+	}
+	p := pool.New(context.Background(), workers)
+
+	d.ResetTimer()
+	if err := p.Run(); err != nil {
+		return err
+	}
+	d.StopTimer()
+
+	latencies := make([]time.Duration, 0, iters)
+	for _, w := range workers {
+		latencies = append(latencies, w.(*worker).lat...)
+	}
+	sort.Sort(durSlice(latencies))
+
+	d.Report("p50-apply-latency-ns", uint64(latencies[len(latencies)*50/100]))
+	d.Report("p90-apply-latency-ns", uint64(latencies[len(latencies)*90/100]))
+	d.Report("p99-apply-latency-ns", uint64(latencies[len(latencies)*99/100]))
+
+	lengthS := float64(d.Elapsed()) / float64(time.Second)
+	d.Report("commits/s", uint64(float64(len(latencies))/lengthS))
+
+	d.Ops(len(latencies))
+	d.Report(driver.StatTime, uint64(int(d.Elapsed())/len(latencies)))
+	return nil
+}
+
+func run(cfg *config) error {
+	for _, size := range cfg.clusterSizes {
+		name := fmt.Sprintf("Raft/nodes=%d", size)
+		err := driver.RunBenchmark(name, func(d *driver.B) error {
+			return runBenchmark(d, cfg, size)
+		}, driver.InProcessMeasurementOptions...)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	for _, s := range strings.Split(sizesFlag, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size <= 0 {
+			fmt.Fprintf(os.Stderr, "error: invalid cluster size %q\n", s)
+			os.Exit(1)
+		}
+		cliCfg.clusterSizes = append(cliCfg.clusterSizes, size)
+	}
+	if cliCfg.clients <= 0 {
+		fmt.Fprintf(os.Stderr, "error: -clients must be positive\n")
+		os.Exit(1)
+	}
+	if cliCfg.payloadBytes < 8 {
+		fmt.Fprintf(os.Stderr, "error: -payload-bytes must be at least 8\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
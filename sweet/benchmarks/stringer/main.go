@@ -0,0 +1,181 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/cgroups"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
+)
+
+var (
+	stringerBin string
+	goTool      string
+	tmpDir      string
+	short       bool
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&stringerBin, "stringer", "", "path to stringer binary")
+	flag.StringVar(&goTool, "go", "", "path to the go command, which stringer uses to load packages")
+	flag.StringVar(&tmpDir, "tmp", "", "work directory (cleared before use)")
+	flag.BoolVar(&short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// genPackage writes a package of enum types for stringer to process into
+// dir, as a module of its own, and returns the names of the types.
+//
+// The types come in three shapes, one for each of the ways stringer
+// lays out the names it generates: a single contiguous run of values,
+// a handful of runs, and values too sparse for runs, which it puts in
+// a map.
+func genPackage(dir string, files, typesPerFile, values int) ([]string, error) {
+	gomod := "module example.com/enums\n\ngo 1.22\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+		return nil, err
+	}
+	var types []string
+	for f := 0; f < files; f++ {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "package enums\n")
+		for t := 0; t < typesPerFile; t++ {
+			n := len(types)
+			typ := fmt.Sprintf("Enum%d", n)
+			types = append(types, typ)
+			fmt.Fprintf(&buf, "\ntype %s int\n\nconst (\n", typ)
+			for v := 0; v < values; v++ {
+				var val int
+				switch n % 3 {
+				case 0:
+					val = v
+				case 1:
+					val = v + v/10*5
+				case 2:
+					val = v * v * 7
+				}
+				fmt.Fprintf(&buf, "\t%s%s%d %s = %d\n", typ, valueWords[v%len(valueWords)], v, typ, val)
+			}
+			fmt.Fprintf(&buf, ")\n")
+		}
+		name := filepath.Join(dir, fmt.Sprintf("enums%d.go", f))
+		if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return types, nil
+}
+
+// valueWords vary the lengths of the generated constant names.
+var valueWords = []string{"A", "Quick", "Brown", "Fox", "JumpsOver", "TheLazy", "Dog"}
+
+func run() error {
+	files, typesPerFile, values := 50, 8, 100
+	if short {
+		files, typesPerFile, values = 2, 3, 10
+	}
+	pkgDir := filepath.Join(tmpDir, "enums")
+	if err := os.RemoveAll(pkgDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return err
+	}
+	types, err := genPackage(pkgDir, files, typesPerFile, values)
+	if err != nil {
+		return err
+	}
+
+	const name = "Stringer"
+	statsFile := filepath.Join(tmpDir, "alloc-stats.json")
+	cmdArgs := []string{stringerBin, "-type", strings.Join(types, ","), "-output", filepath.Join(pkgDir, "enums_string.go")}
+
+	// Set up diagnostics. stringer can't collect any of its own, so
+	// only perf is supported.
+	var diagFiles []*driver.DiagnosticFile
+	diag := driver.NewDiagnostics(name)
+	if df, err := diag.Create(diagnostics.Perf); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %s diagnostics: %s\n", diagnostics.Perf, err)
+	} else if df != nil {
+		df.Close()
+		diagFiles = append(diagFiles, df)
+
+		perfArgs := []string{"perf", "record", "-o", df.Name()}
+		perfArgs = append(perfArgs, driver.PerfFlags()...)
+		perfArgs = append(perfArgs, cmdArgs...)
+		cmdArgs = perfArgs
+	}
+
+	baseCmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	baseCmd.Dir = pkgDir
+	baseCmd.Env = append(os.Environ(),
+		// stringer loads packages with the go command from PATH.
+		"PATH="+filepath.Dir(goTool)+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"GOWORK=off",
+		"SWEET_ALLOC_STATS="+statsFile,
+	)
+	baseCmd.Stdout = os.Stderr // Redirect all tool output to stderr.
+	baseCmd.Stderr = os.Stderr
+	cmd, err := cgroups.WrapCommand(baseCmd, "test.scope")
+	if err != nil {
+		return err
+	}
+	return driver.RunBenchmark(name, func(d *driver.B) error {
+		defer diag.Commit(d)
+		defer func() {
+			for _, df := range diagFiles {
+				df.Commit()
+			}
+		}()
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+		d.StopTimer()
+
+		b, err := os.ReadFile(statsFile)
+		if err != nil {
+			return fmt.Errorf("reading stringer's allocation stats: %v", err)
+		}
+		var stats struct {
+			Bytes   uint64 `json:"bytes"`
+			Objects uint64 `json:"objects"`
+		}
+		if err := json.Unmarshal(b, &stats); err != nil {
+			return fmt.Errorf("reading stringer's allocation stats: %v", err)
+		}
+		d.Report("B/op", stats.Bytes)
+		d.Report("allocs/op", stats.Objects)
+		return nil
+	}, []driver.RunOption{driver.DoTime(true), driver.DoAvgRSS(cmd.RSSFunc()), driver.WithEnv(cmd.Env)}...)
+}
+
+func main() {
+	flag.Parse()
+	if stringerBin == "" {
+		fmt.Fprintln(os.Stderr, "expected non-empty stringer flag")
+		os.Exit(1)
+	}
+	if goTool == "" {
+		fmt.Fprintln(os.Stderr, "expected non-empty go flag")
+		os.Exit(1)
+	}
+	if tmpDir == "" {
+		fmt.Fprintln(os.Stderr, "expected non-empty tmp flag")
+		os.Exit(1)
+	}
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -7,20 +7,21 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"os"
 	"os/exec"
 	"runtime"
-	"sort"
 	"strconv"
-	"sync/atomic"
+	"strings"
 	"time"
 
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
-	"golang.org/x/benchmarks/sweet/benchmarks/internal/pool"
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/loadgen"
 	"golang.org/x/benchmarks/sweet/benchmarks/internal/server"
 	"golang.org/x/benchmarks/sweet/common/diagnostics"
 
@@ -30,26 +31,48 @@ import (
 type config struct {
 	host        string
 	port        int
+	pprofPort   int
 	seed        int64
 	serverBin   string
 	dataPath    string
 	tmpDir      string
 	serverProcs int
 	gomaxprocs  int
+	loads       []float64
+	duration    time.Duration
 	short       bool
+	client      server.ClientConfig
+	ports       server.PortAllocator
+
+	// Set only for the client in two-host mode. See runClient.
+	clientRun string
+	conns     int
+	requests  int
+	rate      float64
 }
 
-var cliCfg config
+var (
+	cliCfg    config
+	loadsFlag string
+)
 
 func init() {
 	driver.SetFlags(flag.CommandLine)
 	flag.StringVar(&cliCfg.host, "host", "127.0.0.1", "hostname of tile38 server")
-	flag.IntVar(&cliCfg.port, "port", 9851, "port for tile38 server")
+	flag.IntVar(&cliCfg.port, "port", 0, "port for tile38 server (0 = any free port)")
 	flag.Int64Var(&cliCfg.seed, "seed", 0, "seed for PRNG")
 	flag.StringVar(&cliCfg.serverBin, "server", "", "path to tile38 server binary")
 	flag.StringVar(&cliCfg.dataPath, "data", "", "path to tile38 server data")
 	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to temporary directory")
+	flag.StringVar(&loadsFlag, "loads", "0.5,0.75,0.9", "comma-separated list of offered loads for open-loop runs, as fractions of the throughput at saturation (empty to skip)")
+	flag.DurationVar(&cliCfg.duration, "open-loop-duration", 10*time.Second, "duration of each open-loop run")
 	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+	cliCfg.client.SetFlags(flag.CommandLine)
+	cliCfg.ports.SetFlags(flag.CommandLine)
+	flag.StringVar(&cliCfg.clientRun, "client-run", "", "run only the load generator (\"closed\" or \"open\") and print its results as JSON; used by two-host mode")
+	flag.IntVar(&cliCfg.conns, "conns", 0, "number of connections for -client-run")
+	flag.IntVar(&cliCfg.requests, "requests", 0, "number of requests for -client-run")
+	flag.Float64Var(&cliCfg.rate, "rate", 0, "requests per second for -client-run open")
 
 	// Grab the number of procs we have and give ourselves only 1/4 of those.
 	procs := runtime.GOMAXPROCS(-1)
@@ -104,90 +127,133 @@ func randPoint() (float64, float64) {
 	return rand.Float64()*180 - 90, rand.Float64()*360 - 180
 }
 
-type worker struct {
-	redis.Conn
-	iterCount *int64 // Accessed atomically.
-	lat       []time.Duration
-}
-
-func newWorker(host string, port int, iterCount *int64) (*worker, error) {
-	conn, err := redis.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
-	if err != nil {
-		return nil, err
-	}
-	return &worker{
-		Conn:      conn,
-		iterCount: iterCount,
-		lat:       make([]time.Duration, 0, 100000),
-	}, nil
-}
-
-func (w *worker) Run(_ context.Context) error {
-	count := atomic.AddInt64(w.iterCount, -1)
-	if count < 0 {
-		return pool.Done
-	}
+// doRequest makes request n, cycling through the kinds of query.
+func doRequest(c redis.Conn, n int) error {
 	lat, lon := randPoint()
-	start := time.Now()
-	if err := requestFuncs[count%3](w.Conn, lat, lon); err != nil {
-		return err
-	}
-	dur := time.Now().Sub(start)
-	w.lat = append(w.lat, dur)
-	return nil
+	return requestFuncs[n%len(requestFuncs)](c, lat, lon)
 }
 
-func (w *worker) Close() error {
-	return w.Conn.Close()
+// sampleInterval is how often a local load generator reports the
+// throughput and latency of the requests it made since the last time.
+const sampleInterval = 10 * time.Second
+
+// generate runs a load generator against the server at host:port: a
+// closed-loop one with conns clients if rate is zero, and an open-loop
+// one offering rate requests per second otherwise. It calls start just
+// before the first request.
+func generate(host string, port, conns, n int, rate float64, progress *loadgen.Progress, start func()) (*loadgen.Result, error) {
+	dial := loadgen.Redis(fmt.Sprintf("%s:%d", host, port), doRequest)
+	return loadgen.Run(context.Background(), dial, loadgen.Config{
+		Conns:    conns,
+		Requests: n,
+		Rate:     rate,
+		Progress: progress,
+		Start:    start,
+	})
 }
 
-type durSlice []time.Duration
-
-func (d durSlice) Len() int           { return len(d) }
-func (d durSlice) Less(i, j int) bool { return d[i] < d[j] }
-func (d durSlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
-
-func runBenchmark(d *driver.B, host string, port, clients int, iters int) error {
-	workers := make([]pool.Worker, 0, clients)
-	iterCount := int64(iters) // Shared atomic variable.
-	for i := 0; i < clients; i++ {
-		w, err := newWorker(host, port, &iterCount)
-		if err != nil {
-			return err
+// generateLoad runs a closed- or open-loop load generator, as selected by
+// mode, with d's timer running. In two-host mode it runs the generator on
+// the client host and collects its results over SSH.
+func generateLoad(d *driver.B, cfg *config, mode string, conns, n int, rate float64) (*loadgen.Result, error) {
+	if !cfg.client.Remote() {
+		var progress loadgen.Progress
+		stopSampling := func() {}
+		start := func() {
+			d.ResetTimer()
+			stopSampling = d.SampleEvery(sampleInterval, progress.Sampler())
 		}
-		workers = append(workers, w)
+		if mode == "closed" {
+			rate = 0
+		}
+		res, err := generate(cfg.host, cfg.port, conns, n, rate, &progress, start)
+		d.StopTimer()
+		stopSampling()
+		return res, err
 	}
-	p := pool.New(context.Background(), workers)
 
+	cmd := cfg.client.Command(nil, os.Args[0],
+		"-client-run", mode,
+		"-host", cfg.client.ServerHost,
+		"-port", strconv.Itoa(cfg.port),
+		"-seed", strconv.FormatInt(cfg.seed, 10),
+		"-conns", strconv.Itoa(conns),
+		"-requests", strconv.Itoa(n),
+		"-rate", strconv.FormatFloat(rate, 'g', -1, 64),
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	// The timer also covers starting the client over SSH, so the
+	// results rely on the client's own measure of the elapsed time.
 	d.ResetTimer()
-	if err := p.Run(); err != nil {
-		return err
-	}
+	err := driver.RunChild(cmd)
 	d.StopTimer()
+	if err != nil {
+		return nil, fmt.Errorf("running client on %s: %v", cfg.client.Host, err)
+	}
+	res := new(loadgen.Result)
+	if err := json.Unmarshal(out.Bytes(), res); err != nil {
+		return nil, fmt.Errorf("reading client results: %v", err)
+	}
+	return res, nil
+}
 
-	// Test is done, bring all latency measurements together.
-	latencies := make([]time.Duration, 0, len(workers)*100000)
-	for _, w := range workers {
-		latencies = append(latencies, w.(*worker).lat...)
+// runClient is the entry point of the client in two-host mode: it runs
+// just the load generator against the server at cfg.host and prints its
+// results as JSON.
+func runClient(cfg *config) error {
+	rate := cfg.rate
+	switch cfg.clientRun {
+	case "closed":
+		rate = 0
+	case "open":
+	default:
+		return fmt.Errorf("unknown client run %q", cfg.clientRun)
+	}
+	res, err := generate(cfg.host, cfg.port, cfg.conns, cfg.requests, rate, nil, nil)
+	if err != nil {
+		return err
 	}
-	sort.Sort(durSlice(latencies))
+	return json.NewEncoder(os.Stdout).Encode(res)
+}
 
-	// Sort and report percentiles.
-	p50 := latencies[len(latencies)*50/100]
-	p90 := latencies[len(latencies)*90/100]
-	p99 := latencies[len(latencies)*99/100]
-	d.Report("p50-latency-ns", uint64(p50))
-	d.Report("p90-latency-ns", uint64(p90))
-	d.Report("p99-latency-ns", uint64(p99))
+// runBenchmark runs a closed-loop load test with the given number of clients
+// and returns the throughput achieved, which is the saturation point of the
+// server.
+func runBenchmark(d *driver.B, cfg *config, clients int, iters int) (float64, error) {
+	res, err := generateLoad(d, cfg, "closed", clients, iters, 0)
+	if err != nil {
+		return 0, err
+	}
+	res.ReportLatencies(d)
 
 	// Report throughput.
-	lengthS := float64(d.Elapsed()) / float64(time.Second)
-	reqsPerSec := float64(len(latencies)) / lengthS
+	reqsPerSec := res.OpsPerSec()
 	d.Report("ops/s", uint64(reqsPerSec))
 
 	// Report the average request latency.
-	d.Ops(len(latencies))
-	d.Report(driver.StatTime, uint64((int(d.Elapsed())*clients)/len(latencies)))
+	d.Ops(res.Requests)
+	d.Report(driver.StatTime, uint64((int(res.Elapsed)*clients)/res.Requests))
+	return reqsPerSec, nil
+}
+
+// runOpenLoopBenchmark offers n requests at rate requests per second, and
+// reports the resulting latencies.
+func runOpenLoopBenchmark(d *driver.B, cfg *config, conns int, rate float64, n int) error {
+	res, err := generateLoad(d, cfg, "open", conns, n, rate)
+	if err != nil {
+		return err
+	}
+	res.ReportLatencies(d)
+
+	d.Report("offered-ops/s", uint64(rate))
+	d.Report("ops/s", uint64(res.OpsPerSec()))
+
+	// For an open-loop run, time per op is the average latency.
+	d.Ops(res.Requests)
+	d.Report(driver.StatTime, uint64(res.Latency)/uint64(res.Requests))
 	return nil
 }
 
@@ -195,16 +261,16 @@ func launchServer(cfg *config, diag *driver.Diagnostics, out io.Writer) (*exec.C
 	// Set up arguments.
 	srvArgs := []string{
 		"-d", cfg.dataPath,
-		"-h", cfg.host,
+		"-h", cfg.client.ListenHost(cfg.host),
 		"-p", strconv.Itoa(cfg.port),
 		"-threads", strconv.Itoa(cfg.serverProcs),
-		"-pprofport", strconv.Itoa(pprofPort),
+		"-pprofport", strconv.Itoa(cfg.pprofPort),
 	}
 
 	// Set up diagnostics that the server can gather on its own
 	var postExit []func()
 	for _, typ := range []diagnostics.Type{diagnostics.CPUProfile, diagnostics.MemProfile} {
-		if df, err := diag.Create(typ); err != nil {
+		if df, err := diag.CreateNamed(typ, diagnostics.ServerName); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to create %s diagnostics: %s\n", typ, err)
 		} else if df != nil {
 			srvArgs = append(srvArgs, "-"+string(typ), df.Name())
@@ -221,7 +287,7 @@ func launchServer(cfg *config, diag *driver.Diagnostics, out io.Writer) (*exec.C
 	)
 	srvCmd.Stdout = out
 	srvCmd.Stderr = out
-	if err := srvCmd.Start(); err != nil {
+	if err := driver.StartChild(srvCmd); err != nil {
 		return nil, nil, fmt.Errorf("failed to start server: %v", err)
 	}
 
@@ -256,8 +322,6 @@ func launchServer(cfg *config, diag *driver.Diagnostics, out io.Writer) (*exec.C
 	return nil, nil, fmt.Errorf("timeout trying to connect to server: %v", err)
 }
 
-const pprofPort = 12345
-
 const benchName = "Tile38QueryLoad"
 
 func run(cfg *config) (err error) {
@@ -266,6 +330,17 @@ func run(cfg *config) (err error) {
 	diag := driver.NewDiagnostics("tile38")
 	defer diag.Commit(nil)
 
+	// Pick the ports the server listens on.
+	defer cfg.ports.Release()
+	ports, err := cfg.ports.Reserve(2)
+	if err != nil {
+		return err
+	}
+	if cfg.port == 0 {
+		cfg.port = ports[0]
+	}
+	cfg.pprofPort = ports[1]
+
 	// Launch the server.
 	srvCmd, postExit, err := launchServer(cfg, diag, &buf)
 	if err != nil {
@@ -309,22 +384,60 @@ func run(cfg *config) (err error) {
 		driver.DoDefaultAvgRSS(),
 		driver.DoCoreDump(true),
 		driver.BenchmarkPID(srvCmd.Process.Pid),
+		driver.WithEnv(srvCmd.Env),
 		driver.DoPerf(true),
+		driver.DoClientCPU(!cfg.client.Remote()),
 		driver.WithGOMAXPROCS(cfg.gomaxprocs),
 	}
 	iters := 40 * 50000
 	if cfg.short {
 		iters = 100
 	}
-	return driver.RunBenchmark(benchName, func(d *driver.B) error {
+
+	// First find the server's saturation point with a closed-loop run,
+	// with as many clients as the server has threads.
+	var saturation float64
+	err = driver.RunBenchmark(benchName, func(d *driver.B) error {
 		// Collect a trace only during the run. (Also, Tile38 doesn't have a
 		// flag to collect its own trace, so we couldn't collect it another way
 		// anyway.)
-		stop := server.FetchDiagnostic(fmt.Sprintf("%s:%d", cfg.host, pprofPort), diag, diagnostics.Trace, benchName)
+		stop := server.FetchDiagnostic(fmt.Sprintf("%s:%d", cfg.host, cfg.pprofPort), diag, diagnostics.Trace, benchName)
 		defer stop()
 
-		return runBenchmark(d, cfg.host, cfg.port, cfg.serverProcs, iters)
+		var err error
+		saturation, err = runBenchmark(d, cfg, cfg.serverProcs, iters)
+		return err
 	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Closed-loop clients slow down along with the server, which hides
+	// latency regressions. Offer fixed fractions of the saturation load
+	// instead, as production traffic would, and see how latency holds up.
+	for _, load := range cfg.loads {
+		rate := load * saturation
+		n := int(rate * cfg.duration.Seconds())
+		if cfg.short {
+			n = 100
+		}
+		if n == 0 {
+			continue
+		}
+		name := fmt.Sprintf("%s/offered-load=%d%%", benchName, int(math.Round(load*100)))
+		err := driver.RunBenchmark(name, func(d *driver.B) error {
+			stop := server.FetchDiagnostic(fmt.Sprintf("%s:%d", cfg.host, cfg.pprofPort), diag, diagnostics.Trace, name)
+			defer stop()
+
+			// Use plenty of connections, so that requests only queue up
+			// when the server falls behind.
+			return runOpenLoopBenchmark(d, cfg, 4*cfg.serverProcs, rate, n)
+		}, opts...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func main() {
@@ -333,6 +446,37 @@ func main() {
 		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
 		os.Exit(1)
 	}
+	if cliCfg.clientRun != "" {
+		// This is the client in two-host mode, and has the machine to
+		// itself.
+		runtime.GOMAXPROCS(cliCfg.gomaxprocs)
+		rand.Seed(cliCfg.seed)
+		if err := runClient(&cliCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cliCfg.client.Remote() {
+		if cliCfg.client.ServerHost == "" {
+			fmt.Fprintf(os.Stderr, "error: -client-host requires -server-host\n")
+			os.Exit(1)
+		}
+		// The clients run elsewhere, so the server can have the whole
+		// machine.
+		cliCfg.serverProcs = cliCfg.gomaxprocs
+	}
+	for _, f := range strings.Split(loadsFlag, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		load, err := strconv.ParseFloat(f, 64)
+		if err != nil || load <= 0 || load > 1 {
+			fmt.Fprintf(os.Stderr, "error: invalid offered load %q, want a fraction in (0, 1]\n", f)
+			os.Exit(1)
+		}
+		cliCfg.loads = append(cliCfg.loads, load)
+	}
 	if err := run(&cliCfg); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,222 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/tsdb"
+	"github.com/prometheus/tsdb/labels"
+)
+
+type config struct {
+	series         int
+	scrapes        int
+	scrapeInterval time.Duration
+	churn          float64
+	tmpDir         string
+	short          bool
+}
+
+var cliCfg config
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.IntVar(&cliCfg.series, "series", 20000, "number of active series in each scrape")
+	flag.IntVar(&cliCfg.scrapes, "scrapes", 1440, "number of scrapes to ingest")
+	flag.DurationVar(&cliCfg.scrapeInterval, "scrape-interval", 15*time.Second, "simulated time between scrapes")
+	flag.Float64Var(&cliCfg.churn, "churn", 0.01, "fraction of series replaced by new series every 10 scrapes")
+	flag.StringVar(&cliCfg.tmpDir, "tmp", "", "path to a temporary working directory")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// blockRange is the time range covered by the head block in milliseconds.
+// It is much smaller than Prometheus' default of two hours so that a run
+// of a few hours of simulated time goes through several head compactions
+// and at least one round of merging persisted blocks.
+const blockRange = int64(30 * time.Minute / time.Millisecond)
+
+// seriesSet generates the labels for a set of series that churns over time,
+// as happens when pods are rescheduled and their instance labels change.
+type seriesSet struct {
+	rng    *rand.Rand
+	labels []labels.Labels
+	refs   []uint64
+	values []float64
+	next   int // Generation number for the next new series.
+}
+
+func newSeriesSet(n int) *seriesSet {
+	s := &seriesSet{
+		rng:    rand.New(rand.NewSource(1)),
+		labels: make([]labels.Labels, n),
+		refs:   make([]uint64, n),
+		values: make([]float64, n),
+	}
+	for i := range s.labels {
+		s.replace(i)
+	}
+	return s
+}
+
+// replace replaces the i'th series with a brand new one.
+func (s *seriesSet) replace(i int) {
+	gen := s.next
+	s.next++
+	s.labels[i] = labels.FromStrings(
+		"__name__", "sweet_metric_"+strconv.Itoa(i%100),
+		"job", "job-"+strconv.Itoa(i%10),
+		"instance", "10.0."+strconv.Itoa(gen/256%256)+"."+strconv.Itoa(gen%256)+":9090",
+		"pod", "pod-"+strconv.Itoa(gen),
+	)
+	s.refs[i] = 0
+	s.values[i] = 0
+}
+
+// churn replaces a fraction of the series with new ones.
+func (s *seriesSet) churn(fraction float64) {
+	n := int(float64(len(s.labels)) * fraction)
+	for j := 0; j < n; j++ {
+		s.replace(s.rng.Intn(len(s.labels)))
+	}
+}
+
+// scrape appends one sample for every series at time t.
+func (s *seriesSet) scrape(app tsdb.Appender, t int64) error {
+	for i := range s.labels {
+		// A random walk compresses like a real gauge, unlike pure noise.
+		s.values[i] += s.rng.NormFloat64()
+		if s.refs[i] != 0 {
+			err := app.AddFast(s.refs[i], t, s.values[i])
+			if err == nil {
+				continue
+			}
+			if err != tsdb.ErrNotFound {
+				return err
+			}
+			// The series was garbage collected from the head; add it again.
+		}
+		ref, err := app.Add(s.labels[i], t, s.values[i])
+		if err != nil {
+			return err
+		}
+		s.refs[i] = ref
+	}
+	return app.Commit()
+}
+
+// compactionStats returns the number of compactions the database ran and the
+// total time they took, read from the database's metrics.
+func compactionStats(reg *prometheus.Registry) (count uint64, dur time.Duration, err error) {
+	mfs, err := reg.Gather()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "prometheus_tsdb_compaction_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			h := m.GetHistogram()
+			count += h.GetSampleCount()
+			dur += time.Duration(h.GetSampleSum() * float64(time.Second))
+		}
+	}
+	return count, dur, nil
+}
+
+func run(cfg *config) error {
+	dir, err := os.MkdirTemp(cfg.tmpDir, "tsdb")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	reg := prometheus.NewRegistry()
+	db, err := tsdb.Open(dir, nil, reg, &tsdb.Options{
+		WALSegmentSize:    0, // Default size.
+		RetentionDuration: uint64(int64(cfg.scrapes) * cfg.scrapeInterval.Milliseconds()),
+		BlockRanges:       tsdb.ExponentialBlockRanges(blockRange, 3, 3),
+		NoLockfile:        true,
+	})
+	if err != nil {
+		return err
+	}
+
+	set := newSeriesSet(cfg.series)
+	name := fmt.Sprintf("PrometheusTSDBIngest/series=%d", cfg.series)
+	err = driver.RunBenchmark(name, func(d *driver.B) error {
+		interval := cfg.scrapeInterval.Milliseconds()
+		for i := 0; i < cfg.scrapes; i++ {
+			if i%10 == 9 {
+				set.churn(cfg.churn)
+			}
+			if err := set.scrape(db.Appender(), int64(i)*interval); err != nil {
+				return err
+			}
+		}
+		// Compactions run in the background, triggered by appends. Wait
+		// for the head to be persisted so every run does the same amount
+		// of work; closing the database would abort them instead.
+		if err := waitForHeadCompaction(db.Head()); err != nil {
+			return err
+		}
+		d.StopTimer()
+
+		samples := cfg.series * cfg.scrapes
+		d.Ops(samples)
+		d.Report("samples/s", uint64(float64(samples)/d.Elapsed().Seconds()))
+
+		count, dur, err := compactionStats(reg)
+		if err != nil {
+			return err
+		}
+		d.Report("compactions", count)
+		d.Report("compaction-ns", uint64(dur.Nanoseconds()))
+		return nil
+	}, driver.InProcessMeasurementOptions...)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// waitForHeadCompaction waits until the head block holds no more data than
+// the database would leave in it after compacting.
+func waitForHeadCompaction(head *tsdb.Head) error {
+	const timeout = 5 * time.Minute
+	start := time.Now()
+	for head.MaxTime()-head.MinTime() > blockRange/2*3 {
+		if time.Since(start) > timeout {
+			return fmt.Errorf("head compaction did not finish within %s", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	if cliCfg.short {
+		cliCfg.series = 100
+		cliCfg.scrapes = 500
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
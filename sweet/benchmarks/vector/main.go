@@ -0,0 +1,283 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command vector runs kernels whose performance depends on vectorized code
+// generation and on the vector and crypto extensions of the CPU: hashing,
+// bulk copies and comparisons, bit manipulation, and ciphers from the
+// standard library.
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/sys/cpu"
+)
+
+type config struct {
+	bytes int64
+	short bool
+}
+
+var cliCfg config
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.Int64Var(&cliCfg.bytes, "bytes", 4<<30, "number of bytes each kernel processes")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// A kernel is an operation on a buffer of data, benchmarked by running it
+// over buffers of size bytes until it has processed the configured amount.
+type kernel struct {
+	name string
+	size int
+
+	// prepare returns the operation on buf, which holds size random bytes.
+	prepare func(buf []byte) func()
+}
+
+var sink uint64
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func kernels() []kernel {
+	ks := []kernel{
+		{"VectorHash/alg=crc32-ieee", 64 << 10, func(buf []byte) func() {
+			return func() { sink += uint64(crc32.ChecksumIEEE(buf)) }
+		}},
+		{"VectorHash/alg=crc32c", 64 << 10, func(buf []byte) func() {
+			return func() { sink += uint64(crc32.Checksum(buf, castagnoli)) }
+		}},
+		{"VectorHash/alg=maphash", 64 << 10, func(buf []byte) func() {
+			seed := maphash.MakeSeed()
+			return func() { sink += maphash.Bytes(seed, buf) }
+		}},
+		{"VectorHash/alg=sha256", 64 << 10, func(buf []byte) func() {
+			return func() { sum := sha256.Sum256(buf); sink += uint64(sum[0]) }
+		}},
+		{"VectorHash/alg=sha512", 64 << 10, func(buf []byte) func() {
+			return func() { sum := sha512.Sum512(buf); sink += uint64(sum[0]) }
+		}},
+	}
+
+	// Copies of a range of sizes, from those that fit in registers to
+	// those that spill out of the caches, and an overlapping one, which
+	// must copy backwards.
+	for _, size := range []int{64, 4 << 10, 1 << 20, 64 << 20} {
+		ks = append(ks, kernel{"VectorCopy/size=" + sizeName(size), size, func(buf []byte) func() {
+			dst := make([]byte, len(buf))
+			return func() { copy(dst, buf) }
+		}})
+	}
+	ks = append(ks, kernel{"VectorCopy/size=1MiB/overlap", 1 << 20, func(buf []byte) func() {
+		return func() { copy(buf[1:], buf[:len(buf)-1]) }
+	}})
+
+	ks = append(ks,
+		kernel{"VectorScan/op=equal", 64 << 10, func(buf []byte) func() {
+			other := bytes.Clone(buf)
+			return func() {
+				if bytes.Equal(buf, other) {
+					sink++
+				}
+			}
+		}},
+		kernel{"VectorScan/op=index-byte", 64 << 10, func(buf []byte) func() {
+			// Make sure the byte is only found at the end.
+			buf = bytes.ReplaceAll(buf, []byte{0}, []byte{1})
+			buf[len(buf)-1] = 0
+			return func() { sink += uint64(bytes.IndexByte(buf, 0)) }
+		}},
+		kernel{"VectorScan/op=count", 64 << 10, func(buf []byte) func() {
+			return func() { sink += uint64(bytes.Count(buf, []byte{'a'})) }
+		}},
+		kernel{"VectorBits/op=popcount", 64 << 10, func(buf []byte) func() {
+			words := toWords(buf)
+			return func() {
+				n := 0
+				for _, w := range words {
+					n += bits.OnesCount64(w)
+				}
+				sink += uint64(n)
+			}
+		}},
+		kernel{"VectorBits/op=reverse", 64 << 10, func(buf []byte) func() {
+			words := toWords(buf)
+			return func() {
+				for i, w := range words {
+					words[i] = bits.Reverse64(bits.RotateLeft64(w, 7))
+				}
+			}
+		}},
+		kernel{"VectorBits/op=mul64", 64 << 10, func(buf []byte) func() {
+			words := toWords(buf)
+			return func() {
+				var acc uint64
+				for i := 1; i < len(words); i++ {
+					hi, lo := bits.Mul64(words[i-1], words[i])
+					acc += hi ^ lo + uint64(bits.LeadingZeros64(hi))
+				}
+				sink += acc
+			}
+		}},
+		kernel{"VectorCrypto/cipher=aes-ctr", 64 << 10, func(buf []byte) func() {
+			block := newAES(buf)
+			dst := make([]byte, len(buf))
+			iv := make([]byte, block.BlockSize())
+			return func() { cipher.NewCTR(block, iv).XORKeyStream(dst, buf) }
+		}},
+		kernel{"VectorCrypto/cipher=aes-gcm", 64 << 10, func(buf []byte) func() {
+			aead, err := cipher.NewGCM(newAES(buf))
+			if err != nil {
+				panic(err)
+			}
+			nonce := make([]byte, aead.NonceSize())
+			dst := make([]byte, 0, len(buf)+aead.Overhead())
+			return func() { dst = aead.Seal(dst[:0], nonce, buf, nil) }
+		}},
+	)
+	return ks
+}
+
+func newAES(buf []byte) cipher.Block {
+	block, err := aes.NewCipher(buf[:32])
+	if err != nil {
+		panic(err)
+	}
+	return block
+}
+
+// toWords returns buf as a slice of uint64.
+func toWords(buf []byte) []uint64 {
+	words := make([]uint64, len(buf)/8)
+	for i := range words {
+		for j := 0; j < 8; j++ {
+			words[i] |= uint64(buf[8*i+j]) << (8 * j)
+		}
+	}
+	return words
+}
+
+func sizeName(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%dMiB", size>>20)
+	case size >= 1<<10:
+		return fmt.Sprintf("%dKiB", size>>10)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+func runKernel(d *driver.B, k kernel, total int64) error {
+	buf := make([]byte, k.size)
+	rand.New(rand.NewSource(1)).Read(buf)
+	op := k.prepare(buf)
+	op() // Warm up caches and any lazily built tables.
+
+	ops := max(total/int64(k.size), 1)
+	d.ResetTimer()
+	for i := int64(0); i < ops; i++ {
+		op()
+	}
+	d.StopTimer()
+
+	d.Ops(int(ops))
+	d.Report(driver.StatTime, uint64(d.Elapsed().Nanoseconds()/ops))
+	d.Report("MB/s", uint64(float64(ops*int64(k.size))/1e6/d.Elapsed().Seconds()))
+	return nil
+}
+
+// logCPU notes what the benchmark ran on in the log, since the results
+// depend on it: the GOARM64 or GOAMD64 level it was built for and the
+// relevant features of the CPU.
+func logCPU() {
+	var features []string
+	switch runtime.GOARCH {
+	case "arm64":
+		for _, f := range []struct {
+			name string
+			has  bool
+		}{
+			{"asimd", cpu.ARM64.HasASIMD}, {"asimddp", cpu.ARM64.HasASIMDDP},
+			{"sve", cpu.ARM64.HasSVE}, {"sve2", cpu.ARM64.HasSVE2},
+			{"aes", cpu.ARM64.HasAES}, {"pmull", cpu.ARM64.HasPMULL},
+			{"sha2", cpu.ARM64.HasSHA2}, {"sha512", cpu.ARM64.HasSHA512},
+			{"crc32", cpu.ARM64.HasCRC32},
+		} {
+			if f.has {
+				features = append(features, f.name)
+			}
+		}
+	case "amd64":
+		for _, f := range []struct {
+			name string
+			has  bool
+		}{
+			{"sse4.2", cpu.X86.HasSSE42}, {"avx2", cpu.X86.HasAVX2},
+			{"avx512f", cpu.X86.HasAVX512F}, {"aes", cpu.X86.HasAES},
+			{"pclmulqdq", cpu.X86.HasPCLMULQDQ}, {"popcnt", cpu.X86.HasPOPCNT},
+		} {
+			if f.has {
+				features = append(features, f.name)
+			}
+		}
+	}
+	level := "default"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "GO"+strings.ToUpper(runtime.GOARCH) {
+				level = s.Value
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "# %s level: %s, CPU features: %s\n", runtime.GOARCH, level, strings.Join(features, " "))
+}
+
+func run(cfg *config) error {
+	logCPU()
+	total := cfg.bytes
+	if cfg.short {
+		total = 16 << 20
+	}
+	for _, k := range kernels() {
+		err := driver.RunBenchmark(k.name, func(d *driver.B) error {
+			return runKernel(d, k, total)
+		}, driver.InProcessMeasurementOptions...)
+		if err != nil {
+			return fmt.Errorf("%s: %v", k.name, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	if cliCfg.bytes <= 0 {
+		fmt.Fprintf(os.Stderr, "error: -bytes must be positive\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/benchmarks/internal/driver"
+	"golang.org/x/net/websocket"
+)
+
+type config struct {
+	conns    []int
+	messages int
+	size     int
+	short    bool
+}
+
+var (
+	cliCfg    config
+	connsFlag string
+)
+
+func init() {
+	driver.SetFlags(flag.CommandLine)
+	flag.StringVar(&connsFlag, "conns", "1000,5000", "comma-separated list of numbers of concurrent connections")
+	flag.IntVar(&cliCfg.messages, "messages", 50, "number of messages to exchange over each connection")
+	flag.IntVar(&cliCfg.size, "size", 64, "size of each message in bytes (at least 8)")
+	flag.BoolVar(&cliCfg.short, "short", false, "whether to run a short version of this benchmark")
+}
+
+// idleTimeout is how long the server lets a connection go without
+// traffic. Like real servers, it resets the deadline for every message,
+// which keeps the runtime's timers busy.
+const idleTimeout = time.Minute
+
+// echo is a handler that sends every message it receives straight back.
+func echo(ws *websocket.Conn) {
+	var msg []byte
+	for {
+		ws.SetReadDeadline(time.Now().Add(idleTimeout))
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return
+		}
+		ws.SetWriteDeadline(time.Now().Add(idleTimeout))
+		if err := websocket.Message.Send(ws, msg); err != nil {
+			return
+		}
+	}
+}
+
+// A hub sends every message broadcast on it to all the connections it
+// serves, as chat and notification servers do. Each connection has a
+// goroutine writing to it from a queue of its own, so that one slow
+// connection doesn't hold up the rest.
+type hub struct {
+	mu   sync.Mutex
+	cond sync.Cond // Signaled when subs changes.
+	subs map[chan []byte]bool
+}
+
+func newHub() *hub {
+	h := &hub{subs: make(map[chan []byte]bool)}
+	h.cond.L = &h.mu
+	return h
+}
+
+func (h *hub) serve(ws *websocket.Conn) {
+	sub := make(chan []byte, 16)
+	h.mu.Lock()
+	h.subs[sub] = true
+	h.cond.Broadcast()
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.subs, sub)
+		h.cond.Broadcast()
+		h.mu.Unlock()
+	}()
+
+	// The client never sends anything, but reading is how the server
+	// notices that it has gone.
+	gone := make(chan struct{})
+	go func() {
+		var msg []byte
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		close(gone)
+	}()
+	for {
+		select {
+		case msg := <-sub:
+			ws.SetWriteDeadline(time.Now().Add(idleTimeout))
+			if err := websocket.Message.Send(ws, msg); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// wait waits until the hub serves n connections.
+func (h *hub) wait(n int) {
+	h.mu.Lock()
+	for len(h.subs) != n {
+		h.cond.Wait()
+	}
+	h.mu.Unlock()
+}
+
+func (h *hub) broadcast(msg []byte) {
+	h.mu.Lock()
+	for sub := range h.subs {
+		sub <- msg
+	}
+	h.mu.Unlock()
+}
+
+// dial opens n connections to the server at url, in parallel.
+func dial(url string, n int) ([]*websocket.Conn, error) {
+	conns := make([]*websocket.Conn, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	procs := runtime.GOMAXPROCS(-1)
+	for p := 0; p < procs; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := p; i < n; i += procs {
+				conns[i], errs[i] = websocket.Dial(url, "", "http://localhost/")
+			}
+		}(p)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		closeAll(conns)
+		// DialError doesn't unwrap to the error it holds.
+		var de *websocket.DialError
+		if errors.As(err, &de) {
+			err = de.Err
+		}
+		if errors.Is(err, syscall.EMFILE) {
+			return nil, fmt.Errorf("opening %d connections: %v (raise the limit on open files with ulimit -n)", n, err)
+		}
+		return nil, fmt.Errorf("opening %d connections: %v", n, err)
+	}
+	return conns, nil
+}
+
+func closeAll(conns []*websocket.Conn) {
+	for _, c := range conns {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
+// inUse returns the bytes of heap and stack in use, after a GC.
+func inUse() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapInuse + ms.StackInuse
+}
+
+type durSlice []time.Duration
+
+func (d durSlice) Len() int           { return len(d) }
+func (d durSlice) Less(i, j int) bool { return d[i] < d[j] }
+func (d durSlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// runBenchmark opens n connections to the server at url, runs exchange on
+// each of them, which returns the latencies of the messages it got, and
+// reports the results.
+func runBenchmark(d *driver.B, url string, n int, exchange func(conns []*websocket.Conn) ([]time.Duration, error)) error {
+	before := inUse()
+	conns, err := dial(url, n)
+	if err != nil {
+		return err
+	}
+	defer closeAll(conns)
+	// This counts both ends of each connection, since the clients are
+	// in the same process as the server.
+	perConn := int64(inUse()-before) / int64(n)
+
+	d.ResetTimer()
+	latencies, err := exchange(conns)
+	if err != nil {
+		return err
+	}
+	d.StopTimer()
+	sort.Sort(durSlice(latencies))
+
+	d.Report("p50-latency-ns", uint64(latencies[len(latencies)*50/100]))
+	d.Report("p90-latency-ns", uint64(latencies[len(latencies)*90/100]))
+	d.Report("p99-latency-ns", uint64(latencies[len(latencies)*99/100]))
+	d.Report("msgs/s", uint64(float64(len(latencies))/d.Elapsed().Seconds()))
+	if perConn > 0 {
+		d.Report("B/conn", uint64(perConn))
+	}
+
+	d.Ops(len(latencies))
+	d.Report(driver.StatTime, uint64((int(d.Elapsed())*n)/len(latencies)))
+	return nil
+}
+
+// echoExchange sends cfg.messages messages over every connection at once,
+// each after the echo of the one before, and returns their round-trip
+// times.
+func echoExchange(cfg *config) func([]*websocket.Conn) ([]time.Duration, error) {
+	return func(conns []*websocket.Conn) ([]time.Duration, error) {
+		lat := make([][]time.Duration, len(conns))
+		errs := make([]error, len(conns))
+		var wg sync.WaitGroup
+		for i, c := range conns {
+			wg.Add(1)
+			go func(i int, c *websocket.Conn) {
+				defer wg.Done()
+				msg := make([]byte, cfg.size)
+				var reply []byte
+				for j := 0; j < cfg.messages; j++ {
+					start := time.Now()
+					if err := websocket.Message.Send(c, msg); err != nil {
+						errs[i] = err
+						return
+					}
+					if err := websocket.Message.Receive(c, &reply); err != nil {
+						errs[i] = err
+						return
+					}
+					lat[i] = append(lat[i], time.Since(start))
+				}
+			}(i, c)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+		var all []time.Duration
+		for _, l := range lat {
+			all = append(all, l...)
+		}
+		return all, nil
+	}
+}
+
+// fanoutExchange broadcasts cfg.messages messages from h to every
+// connection, one after another, and returns the time each took to reach
+// each connection.
+func fanoutExchange(cfg *config, h *hub) func([]*websocket.Conn) ([]time.Duration, error) {
+	return func(conns []*websocket.Conn) ([]time.Duration, error) {
+		h.wait(len(conns))
+		lat := make([][]time.Duration, len(conns))
+		// Each connection sends nil on received for each message it gets,
+		// or an error, after which it sends nothing more. Only once every
+		// connection has got a message is the next one broadcast, so the
+		// buffer never fills, even if the connections are closed because
+		// of an error.
+		received := make(chan error, 2*len(conns))
+		for i, c := range conns {
+			go func(i int, c *websocket.Conn) {
+				var msg []byte
+				for j := 0; j < cfg.messages; j++ {
+					if err := websocket.Message.Receive(c, &msg); err != nil {
+						received <- err
+						return
+					}
+					sent := time.Unix(0, int64(binary.LittleEndian.Uint64(msg)))
+					lat[i] = append(lat[i], time.Since(sent))
+					received <- nil
+				}
+			}(i, c)
+		}
+		for j := 0; j < cfg.messages; j++ {
+			msg := make([]byte, cfg.size)
+			binary.LittleEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+			h.broadcast(msg)
+			for range conns {
+				if err := <-received; err != nil {
+					return nil, err
+				}
+			}
+		}
+		var all []time.Duration
+		for _, l := range lat {
+			all = append(all, l...)
+		}
+		return all, nil
+	}
+}
+
+func run(cfg *config) error {
+	// Start the server on loopback. The clients and the server share a
+	// process, so in-process measurements capture both sides.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	h := newHub()
+	mux := http.NewServeMux()
+	mux.Handle("/echo", websocket.Handler(echo))
+	mux.Handle("/fanout", websocket.Handler(h.serve))
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	base := "ws://" + lis.Addr().String()
+	for _, n := range cfg.conns {
+		for _, bench := range []struct {
+			mode     string
+			exchange func([]*websocket.Conn) ([]time.Duration, error)
+		}{
+			{"echo", echoExchange(cfg)},
+			{"fanout", fanoutExchange(cfg, h)},
+		} {
+			name := fmt.Sprintf("WebSocket/mode=%s/conns=%d", bench.mode, n)
+			err := driver.RunBenchmark(name, func(d *driver.B) error {
+				return runBenchmark(d, base+"/"+bench.mode, n, bench.exchange)
+			}, driver.InProcessMeasurementOptions...)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			// Let the server notice that the connections have gone,
+			// so that the next benchmark starts afresh.
+			h.wait(0)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected args\n")
+		os.Exit(1)
+	}
+	for _, s := range strings.Split(connsFlag, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "error: invalid number of connections %q\n", s)
+			os.Exit(1)
+		}
+		cliCfg.conns = append(cliCfg.conns, n)
+	}
+	if cliCfg.short {
+		cliCfg.conns = []int{100}
+		cliCfg.messages = 5
+	}
+	if cliCfg.messages <= 0 {
+		fmt.Fprintf(os.Stderr, "error: -messages must be positive\n")
+		os.Exit(1)
+	}
+	if cliCfg.size < 8 {
+		fmt.Fprintf(os.Stderr, "error: -size must be at least 8, to hold a timestamp\n")
+		os.Exit(1)
+	}
+	if err := run(&cliCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// PartialSuffix is appended to the name of an archive while it is being
+// downloaded.
+const PartialSuffix = ".partial"
+
+// ErrHashMismatch is returned by Download when the downloaded archive does
+// not have the expected hash.
+var ErrHashMismatch = errors.New("unexpected hash")
+
+// Source is a remote archive that can be read starting from any offset.
+type Source interface {
+	// Size returns the size of the archive in bytes.
+	Size() (int64, error)
+
+	// Open returns a reader for the archive from offset to the end.
+	Open(offset int64) (io.ReadCloser, error)
+}
+
+const (
+	// downloadChunkSize is how much Download reads from the source at a
+	// time, and so about how much it may have to fetch again after a
+	// failure.
+	downloadChunkSize = 4 << 20
+
+	// downloadRetries is how many times in a row Download tries to
+	// resume after failures without making any progress.
+	downloadRetries = 5
+)
+
+// retryDelay is how long Download waits to resume after its first failure
+// in a row, and how much longer it waits after each further one.
+var retryDelay = time.Second
+
+// Download downloads the archive src into path and checks that its hash
+// is want.
+//
+// The archive is downloaded into path+PartialSuffix and only renamed to
+// path once complete and verified, so path never holds a partial
+// archive. If a partial download is left over from an earlier attempt,
+// Download hashes what is there and resumes from where it left off.
+// Likewise, if reading fails part way through, Download reopens the
+// source where it stopped.
+//
+// If the downloaded archive has the wrong hash, Download deletes it and
+// returns an error wrapping ErrHashMismatch.
+func Download(path string, src Source, want string) error {
+	size, err := src.Size()
+	if err != nil {
+		return fmt.Errorf("checking size of assets archive: %w", err)
+	}
+	partial := path + PartialSuffix
+	f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Hash whatever we already have, unless it can't be part of this
+	// archive.
+	hash := Hash()
+	offset, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("reading partial download: %w", err)
+	}
+	if offset > size {
+		log.Printf("Partial download %s is larger than the archive; starting over", partial)
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		offset = 0
+		hash.Reset()
+	} else if offset > 0 {
+		log.Printf("Resuming download at %d of %d bytes", offset, size)
+	}
+
+	buf := make([]byte, downloadChunkSize)
+	for failures := 0; offset < size; {
+		n, err, werr := downloadFrom(f, hash, src, offset, buf)
+		offset += n
+		if werr != nil {
+			return fmt.Errorf("writing %s: %w", partial, werr)
+		}
+		if err == nil && offset < size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			break
+		}
+		if n > 0 {
+			failures = 0
+		}
+		failures++
+		if failures > downloadRetries {
+			return fmt.Errorf("downloading assets archive: %w (re-run to resume from byte %d)", err, offset)
+		}
+		delay := time.Duration(failures) * retryDelay
+		log.Printf("Download interrupted at %d of %d bytes: %v; retrying in %v", offset, size, err, delay)
+		time.Sleep(delay)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if got := CanonicalizeHash(hash); got != want {
+		if err := os.Remove(partial); err != nil {
+			log.Printf("Failed to remove corrupt download %s: %v", partial, err)
+		}
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, want, got)
+	}
+	return os.Rename(partial, path)
+}
+
+// downloadFrom copies src from offset into f a chunk at a time, adding
+// what it copies to hash, and returns the number of bytes copied. err is
+// nil when it reaches the end of src, and werr is any error writing to f.
+func downloadFrom(f *os.File, hash hash.Hash, src Source, offset int64, buf []byte) (copied int64, err, werr error) {
+	rc, err := src.Open(offset)
+	if err != nil {
+		return 0, err, nil
+	}
+	defer rc.Close()
+	for {
+		n, err := io.ReadFull(rc, buf)
+		if n > 0 {
+			if _, werr := f.Write(buf[:n]); werr != nil {
+				return copied, nil, werr
+			}
+			hash.Write(buf[:n])
+			copied += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return copied, nil, nil
+		} else if err != nil {
+			return copied, err, nil
+		}
+	}
+}
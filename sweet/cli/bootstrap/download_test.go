@@ -0,0 +1,166 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bootstrap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeSource is an archive whose readers fail after failAfter bytes, for
+// the first failures times they're opened.
+type fakeSource struct {
+	data      []byte
+	failAfter int
+	failures  int
+	offsets   []int64 // Offsets it was opened at.
+}
+
+func (s *fakeSource) Size() (int64, error) { return int64(len(s.data)), nil }
+
+func (s *fakeSource) Open(offset int64) (io.ReadCloser, error) {
+	s.offsets = append(s.offsets, offset)
+	var r io.Reader = bytes.NewReader(s.data[offset:])
+	if s.failures > 0 {
+		s.failures--
+		r = io.MultiReader(io.LimitReader(r, int64(s.failAfter)), errReader{})
+	}
+	return io.NopCloser(r), nil
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func hashOf(data []byte) string {
+	h := Hash()
+	h.Write(data)
+	return CanonicalizeHash(h)
+}
+
+func TestDownload(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = 0
+
+	data := make([]byte, 3*downloadChunkSize+1234)
+	rand.New(rand.NewSource(1)).Read(data)
+	for _, tc := range []struct {
+		name        string
+		partial     []byte // Left over from an earlier attempt, if not nil.
+		failAfter   int
+		failures    int
+		want        string // Expected hash; if empty, the hash of data.
+		wantErr     error
+		wantOffsets []int64
+		wantPartial int // Size of the partial download left after an error.
+	}{
+		{
+			name:        "fresh",
+			wantOffsets: []int64{0},
+		},
+		{
+			name:        "resume partial",
+			partial:     data[:1000],
+			wantOffsets: []int64{1000},
+		},
+		{
+			name:        "empty partial",
+			partial:     []byte{},
+			wantOffsets: []int64{0},
+		},
+		{
+			name:        "partial larger than archive",
+			partial:     append(data[:len(data):len(data)], 0),
+			wantOffsets: []int64{0},
+		},
+		{
+			name:        "retry after failures",
+			failAfter:   downloadChunkSize + 10,
+			failures:    2,
+			wantOffsets: []int64{0, downloadChunkSize + 10, 2 * (downloadChunkSize + 10)},
+		},
+		{
+			name:        "give up",
+			failAfter:   0,
+			failures:    downloadRetries + 1,
+			wantErr:     errAny,
+			wantOffsets: []int64{0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:        "corrupt partial",
+			partial:     make([]byte, 1000),
+			wantErr:     ErrHashMismatch,
+			wantOffsets: []int64{1000},
+			wantPartial: -1,
+		},
+		{
+			name:        "wrong hash",
+			want:        hashOf(nil),
+			wantErr:     ErrHashMismatch,
+			wantOffsets: []int64{0},
+			wantPartial: -1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "assets.zip")
+			if tc.partial != nil {
+				if err := os.WriteFile(path+PartialSuffix, tc.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			src := &fakeSource{data: data, failAfter: tc.failAfter, failures: tc.failures}
+			want := tc.want
+			if want == "" {
+				want = hashOf(data)
+			}
+			err := Download(path, src, want)
+			if !slices.Equal(src.offsets, tc.wantOffsets) {
+				t.Errorf("opened the archive at offsets %v, want %v", src.offsets, tc.wantOffsets)
+			}
+			if tc.wantErr != nil {
+				if err == nil || (tc.wantErr != errAny && !errors.Is(err, tc.wantErr)) {
+					t.Fatalf("Download returned %v, want %v", err, tc.wantErr)
+				}
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s exists after a failed download", path)
+				}
+				fi, err := os.Stat(path + PartialSuffix)
+				if tc.wantPartial < 0 {
+					if !os.IsNotExist(err) {
+						t.Errorf("corrupt partial download was kept")
+					}
+				} else if err != nil {
+					t.Errorf("partial download was removed: %v", err)
+				} else if fi.Size() != int64(tc.wantPartial) {
+					t.Errorf("partial download has %d bytes, want %d", fi.Size(), tc.wantPartial)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("downloaded %d bytes that differ from the archive's %d", len(got), len(data))
+			}
+			if _, err := os.Stat(path + PartialSuffix); !os.IsNotExist(err) {
+				t.Errorf("partial download left behind after success")
+			}
+		})
+	}
+}
+
+// errAny matches any error in TestDownload.
+var errAny = errors.New("any error")
@@ -7,6 +7,7 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"io"
 
 	"cloud.google.com/go/storage"
 
@@ -71,6 +72,24 @@ func NewStorageWriter(bucket, version string, auth AuthOption, force bool) (*sto
 }
 
 func NewStorageReader(bucket, version string, auth AuthOption) (*storage.Reader, error) {
+	o, err := readObject(bucket, version, auth)
+	if err != nil {
+		return nil, err
+	}
+	return o.NewReader(context.Background())
+}
+
+// NewStorageSource returns a Source for the assets archive for version
+// in bucket, for use with Download.
+func NewStorageSource(bucket, version string, auth AuthOption) (Source, error) {
+	o, err := readObject(bucket, version, auth)
+	if err != nil {
+		return nil, err
+	}
+	return gcsSource{o}, nil
+}
+
+func readObject(bucket, version string, auth AuthOption) (*storage.ObjectHandle, error) {
 	ctx := context.Background()
 	opts := []option.ClientOption{option.WithScopes(storage.ScopeReadOnly)}
 	switch auth {
@@ -89,5 +108,21 @@ func NewStorageReader(bucket, version string, auth AuthOption) (*storage.Reader,
 	if err != nil {
 		return nil, err
 	}
-	return client.Bucket(bucket).Object(VersionArchiveName(version)).NewReader(ctx)
+	return client.Bucket(bucket).Object(VersionArchiveName(version)), nil
+}
+
+type gcsSource struct {
+	o *storage.ObjectHandle
+}
+
+func (s gcsSource) Size() (int64, error) {
+	attrs, err := s.o.Attrs(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+func (s gcsSource) Open(offset int64) (io.ReadCloser, error) {
+	return s.o.NewRangeReader(context.Background(), offset, -1)
 }
@@ -21,3 +21,9 @@ func ValidateVersion(version string) error {
 func VersionArchiveName(version string) string {
 	return fmt.Sprintf("assets-%s.zip", version)
 }
+
+// ShortVersion returns the version under which the assets that short runs
+// use instead of those for version are published.
+func ShortVersion(version string) string {
+	return version + "-short"
+}
@@ -5,6 +5,7 @@
 package subcommands
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -67,6 +68,12 @@ type Command interface {
 	Run(args []string) error
 }
 
+// ExitCoder is implemented by errors returned from Command.Run that call
+// for an exit code other than 1.
+type ExitCoder interface {
+	ExitCode() int
+}
+
 func Register(cmd Command) {
 	f := flag.NewFlagSet(cmd.Name(), flag.ExitOnError)
 	cmd.SetFlags(f)
@@ -130,6 +137,10 @@ func Run() int {
 	chosen.flags.Parse(os.Args[2:])
 	if err := chosen.Run(chosen.flags.Args()); err != nil {
 		log.Error(err)
+		var ec ExitCoder
+		if errors.As(err, &ec) {
+			return ec.ExitCode()
+		}
 		return 1
 	}
 	return 0
@@ -0,0 +1,72 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/cli/bootstrap"
+	"golang.org/x/benchmarks/sweet/common"
+)
+
+// hashAssets returns a SHA-256 hash of the names and contents of all the
+// regular files under dir in fsys, which identifies a benchmark's assets
+// independently of how they were packaged.
+func hashAssets(fsys fs.FS, dir string) (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		rel, _ := strings.CutPrefix(p, dir+"/")
+		fmt.Fprintf(h, "%s\x00%d\x00", rel, fi.Size())
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// assetsVersion returns the version of the assets in use: the version of
+// the assets archive, which may be that of the assets for short runs, or
+// "local" if they come from -assets-dir.
+func (r *runCfg) assetsVersion() string {
+	if r.assetsDir != "" {
+		return "local"
+	}
+	if r.shortAssets {
+		return bootstrap.ShortVersion(common.Version)
+	}
+	return common.Version
+}
+
+// assetsConfigLines returns the benchmark configuration lines that
+// identify the assets used to produce b's results.
+func (r *runCfg) assetsConfigLines(b *benchmark) string {
+	s := fmt.Sprintf("assets-version: %s\n", r.assetsVersion())
+	if hash, ok := r.assetHashes[b.name]; ok {
+		s += fmt.Sprintf("assets-hash: sha256:%s\n", hash)
+	}
+	return s
+}
@@ -14,6 +14,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"golang.org/x/benchmarks/sweet/common"
 	"golang.org/x/benchmarks/sweet/common/diagnostics"
@@ -29,66 +30,208 @@ var allBenchmarks = []benchmark{
 		description: "Reports feature family groupings in pairwise alignment data",
 		harness:     harnesses.BiogoIgor(),
 		generator:   generators.BiogoIgor(),
+		diskSpace:   256 * mib,
 	},
 	{
 		name:        "biogo-krishna",
 		description: "Performs pairwise alignment of a target sequence against itself",
 		harness:     harnesses.BiogoKrishna(),
 		generator:   generators.BiogoKrishna(),
+		diskSpace:   1 * gib,
 	},
 	{
 		name:        "bleve-index",
-		description: "Indexes a subset of Wikipedia into a search index",
+		description: "Indexes a subset of Wikipedia into a search index, serially and in parallel",
 		harness:     harnesses.BleveIndex(),
 		generator:   generators.BleveIndex(),
+		diskSpace:   1 * gib,
+	},
+	{
+		name:        "cache",
+		description: "Gets and sets keys in sharded LRU and sync.Map caches from increasing numbers of goroutines",
+		harness:     harnesses.Cache(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "caddy",
+		description: "Web server serving static files and reverse proxying to a backend",
+		harness:     harnesses.Caddy{},
+		generator:   generators.None{},
+		diskSpace:   512 * mib,
+		pgoProfile:  diagnostics.ServerName,
 	},
 	{
 		name:        "cockroachdb",
 		description: "Distributed database",
 		harness:     harnesses.CockroachDB{},
 		generator:   generators.None{},
+		diskSpace:   20 * gib,
+		pgoProfile:  diagnostics.ServerName,
+		keyDists:    []string{"uniform", "zipf"},
+
+		remoteClients: true,
+	},
+	{
+		// Not in the default group, since it builds cockroachdb again
+		// for a variant of its benchmarks. Its workload needs the client
+		// certificates, which only exist on this machine, so it can't
+		// run with -client-host.
+		name:        "cockroachdb-secure",
+		description: "Distributed database, with TLS between nodes and clients",
+		harness:     harnesses.CockroachDB{Secure: true},
+		generator:   generators.None{},
+		diskSpace:   20 * gib,
+		pgoProfile:  diagnostics.ServerName,
+		keyDists:    []string{"uniform", "zipf"},
 	},
 	{
 		name:        "etcd",
 		description: "Distributed key-value store",
 		harness:     harnesses.Etcd{},
 		generator:   generators.None{},
+		diskSpace:   2 * gib,
+		pgoProfile:  diagnostics.ServerName,
+
+		remoteClients: true,
 	},
 	{
 		name:        "esbuild",
 		description: "JavaScript/Typescript bundler",
 		harness:     &harnesses.ESBuild{},
 		generator:   generators.None{},
+		diskSpace:   1 * gib,
+	},
+	{
+		name:        "fasthttp",
+		description: "Serves identical HTTP workloads from net/http and fasthttp at high request rates",
+		harness:     harnesses.FastHTTP(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "fswalk",
+		description: "Walks a generated directory tree and hashes every file into a content-addressable index",
+		harness:     harnesses.FSWalk(),
+		generator:   generators.None{},
+		diskSpace:   512 * mib,
+	},
+	{
+		name:        "generics",
+		description: "Generic B-tree maps and collection algorithms, and compiling the package that implements them",
+		harness:     harnesses.Generics(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
 	},
 	{
 		name:        "go-build",
 		description: "Go build command",
 		harness:     harnesses.GoBuild{},
 		generator:   generators.None{},
+		diskSpace:   4 * gib,
+	},
+	{
+		name:        "go-build-loaded",
+		description: "Go build command, under a background CPU and allocation load",
+		harness:     harnesses.GoBuild{Loaded: true},
+		generator:   generators.None{},
+		diskSpace:   4 * gib,
+		dir:         "go-build",
+	},
+	{
+		name:        "go-link",
+		description: "Go linker, relinking kubelet from prebuilt archives with and without -s -w and -race",
+		harness:     harnesses.GoLink{},
+		generator:   generators.None{},
+		diskSpace:   8 * gib,
 	},
 	{
 		name:        "gopher-lua",
 		description: "Runs a k-nucleotide benchmark written in Lua on a Go-based Lua VM",
 		harness:     harnesses.GopherLua(),
 		generator:   generators.GopherLua(),
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "grpc",
+		description: "Echoes protobuf messages over gRPC with a sweep of message sizes and compressors",
+		harness:     harnesses.GRPC(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
 	},
 	{
 		name:        "gvisor",
 		description: "Container runtime sandbox for Linux (requires root)",
 		harness:     harnesses.GVisor{},
 		generator:   generators.GVisor{},
+		diskSpace:   2 * gib,
+	},
+	{
+		name:        "hugo",
+		description: "Builds a static site of 10,000 pages with the Hugo site generator",
+		harness:     harnesses.Hugo{},
+		generator:   generators.Hugo{},
+		diskSpace:   2 * gib,
 	},
 	{
 		name:        "markdown",
 		description: "Renders a corpus of markdown documents to XHTML",
 		harness:     harnesses.Markdown(),
 		generator:   generators.Markdown(),
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "raft",
+		description: "Commits proposals through an in-process etcd/raft cluster over a simulated network",
+		harness:     harnesses.Raft(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "stringer",
+		description: "Generates String methods with x/tools' stringer for a large package of enum types",
+		harness:     harnesses.Stringer{},
+		generator:   generators.None{},
+		diskSpace:   512 * mib,
+	},
+	{
+		name:        "tsdb",
+		description: "Ingests a churning set of time series into a Prometheus TSDB, with compactions",
+		harness:     harnesses.TSDB(),
+		generator:   generators.None{},
+		diskSpace:   1 * gib,
 	},
 	{
 		name:        "tile38",
 		description: "Redis-like geospatial database and geofencing server",
 		harness:     harnesses.Tile38{},
 		generator:   generators.Tile38{},
+		diskSpace:   2 * gib,
+		pgoProfile:  diagnostics.ServerName,
+
+		remoteClients: true,
+		samples:       true,
+	},
+	{
+		name:        "websocket",
+		description: "Echoes and broadcasts small messages over thousands of concurrent WebSocket connections",
+		harness:     harnesses.WebSocket(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "encoding",
+		description: "Encodes and decodes object graphs with encoding/gob and packed records with encoding/binary",
+		harness:     harnesses.Encoding(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
+	},
+	{
+		name:        "vector",
+		description: "Hashing, bulk copies, bit manipulation and ciphers that depend on vectorized code and CPU extensions",
+		harness:     harnesses.Vector(),
+		generator:   generators.None{},
+		diskSpace:   64 * mib,
 	},
 }
 
@@ -119,6 +262,25 @@ var benchmarkGroups = func() map[string][]*benchmark {
 		allBenchmarksMap["tile38"],
 	)
 
+	// Workloads whose performance depends on vectorized code generation,
+	// for comparing code generation and CPU features, e.g. SVE against
+	// NEON on arm64.
+	m["vector-sensitive"] = []*benchmark{
+		allBenchmarksMap["fswalk"],
+		allBenchmarksMap["vector"],
+	}
+
+	// Quick, self-contained benchmarks for checking that the benchmarking
+	// infrastructure itself works, e.g. in presubmits. None of them starts
+	// a server or any other process, and run with -short, as they always
+	// are in this group, they finish in well under 5 minutes in total.
+	m["smoke"] = []*benchmark{
+		allBenchmarksMap["bleve-index"],
+		allBenchmarksMap["cache"],
+		allBenchmarksMap["gopher-lua"],
+		allBenchmarksMap["markdown"],
+	}
+
 	for i := range allBenchmarks {
 		m["all"] = append(m["all"], &allBenchmarks[i])
 	}
@@ -229,13 +391,159 @@ type benchmark struct {
 	description string
 	harness     common.Harness
 	generator   common.Generator
+
+	// diskSpace is a rough estimate of the disk space the benchmark
+	// needs in the work directory for each configuration, covering
+	// built binaries and any data it writes while running. It does
+	// not include assets, which are accounted for separately.
+	diskSpace uint64
+
+	// pgoProfile is the name of the diagnostics that -pgo builds its
+	// profile from, e.g. diagnostics.ServerName to use only the server's
+	// CPU profiles for a client/server benchmark. If empty, all CPU
+	// profiles are used.
+	pgoProfile string
+
+	// remoteClients indicates that the benchmark is a server benchmark
+	// whose clients can run on another machine, given by -client-host.
+	remoteClients bool
+
+	// keyDists, if not nil, are the only values of -key-dist that the
+	// benchmark supports.
+	keyDists []string
+
+	// samples indicates that the benchmark records samples of its
+	// metrics over intervals of each run, and so should be given a
+	// -samples-file.
+	samples bool
+
+	// dir is the name of the benchmark's directory in the benchmarks
+	// directory, for benchmarks that share another's code. If empty, it
+	// is the benchmark's name.
+	dir string
+}
+
+// benchDir returns the benchmark's directory in the benchmarks directory
+// root.
+func (b *benchmark) benchDir(root string) string {
+	if b.dir != "" {
+		return filepath.Join(root, b.dir)
+	}
+	return filepath.Join(root, b.name)
+}
+
+// writeBuildResults writes the time taken to build a benchmark and the total
+// size of the binaries that the build placed in binDir to file, in the Go
+// benchmark format, following the configuration lines in config. Only
+// regular files at the top level of binDir count towards the size;
+// harnesses also put symlinks to sources and copies of GOROOT there.
+func writeBuildResults(file, config, name string, buildTime time.Duration, binDir string) error {
+	des, err := os.ReadDir(binDir)
+	if err != nil {
+		return err
+	}
+	var binBytes int64
+	for _, de := range des {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		binBytes += fi.Size()
+	}
+	return os.WriteFile(file, []byte(config+fmt.Sprintf("BenchmarkBuild/%s 1 %d build-real-ns %d bin-bytes\n", name, buildTime.Nanoseconds(), binBytes)), 0644)
+}
+
+// setPGOFlag adds -pgo to GOFLAGS in cfg's build environment, using the
+// profile specified for this benchmark, if any, and otherwise explicitly
+// disabling PGO to avoid default.pgo files.
+func (b *benchmark) setPGOFlag(cfg *common.Config) {
+	pgo, ok := cfg.PGOFiles[b.name]
+	if !ok {
+		pgo = "off"
+	}
+	goflags, ok := cfg.BuildEnv.Lookup("GOFLAGS")
+	if ok {
+		goflags += " "
+	}
+	goflags += fmt.Sprintf("-pgo=%s", pgo)
+	cfg.BuildEnv.Env = cfg.BuildEnv.MustSet("GOFLAGS=" + goflags)
+}
+
+// configSrcDir returns the directory containing the source of b to build
+// for cfg. That's srcDir, shared by all configurations, unless cfg asks for
+// a version of the source of its own, which is retrieved into workDir if
+// it isn't there already. Each version gets a directory of its own, so
+// that changing the configuration's version never reuses the source of
+// another.
+func (b *benchmark) configSrcDir(cfg *common.Config, srcDir, workDir, sourceCache string, short bool) (string, error) {
+	version := cfg.Versions[b.name]
+	if version == "" {
+		return srcDir, nil
+	}
+	dir := filepath.Join(workDir, "src@"+strings.ReplaceAll(version, "/", "_"))
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return dir, err
+	}
+	log.Printf("Retrieving version %s of %s for %s, instead of %s", version, b.name, cfg.Name, b.harness.(common.VersionedHarness).DefaultVersion())
+	gcfg := &common.GetConfig{
+		SrcDir:         dir,
+		SourceCacheDir: sourceCache,
+		Version:        version,
+		Short:          short,
+	}
+	if err := b.harness.Get(gcfg); err != nil {
+		return "", fail(failAssets, fmt.Errorf("retrieving source for %s for %s: %w", b.name, cfg.Name, err))
+	}
+	return dir, nil
 }
 
 func (b *benchmark) execute(cfgs []*common.Config, r *runCfg) error {
+	r.progress.startBenchmark(b.name, r.count*len(cfgs))
+	err := b.execute1(cfgs, r)
+	r.progress.endBenchmark(err)
+	return err
+}
+
+func (b *benchmark) execute1(cfgs []*common.Config, r *runCfg) error {
+	br, err := b.setUp(cfgs, r)
+	if err != nil {
+		return err
+	}
+	defer br.close()
+	for j := 0; j < r.count; j++ {
+		// Execute the benchmark for each configuration.
+		for i := range cfgs {
+			if err := br.run(i, j+1); err != nil {
+				return err
+			}
+		}
+	}
+	br.check()
+	return nil
+}
+
+// A benchmarkRun is a benchmark that has been set up, and built, for each
+// of a set of configurations, and is ready to run.
+type benchmarkRun struct {
+	b           *benchmark
+	cfgs        []*common.Config
+	r           *runCfg
+	hasAssets   bool
+	assetsFSDir string
+	setups      []common.RunConfig
+	results     []*os.File // The results files of setups, to close.
+}
+
+// setUp retrieves the source of b, and sets it up and builds it for each
+// of cfgs.
+func (b *benchmark) setUp(cfgs []*common.Config, r *runCfg) (_ *benchmarkRun, err error) {
 	log.Printf("Setting up benchmark: %s", b.name)
 
 	// Compute top-level directories for this benchmark to work in.
-	benchDir := filepath.Join(r.benchDir, b.name)
+	benchDir := b.benchDir(r.benchDir)
 	topDir := filepath.Join(r.workDir, b.name)
 	srcDir := filepath.Join(topDir, "src")
 
@@ -246,42 +554,82 @@ func (b *benchmark) execute(cfgs []*common.Config, r *runCfg) error {
 		fi, err := f.Stat()
 		if err != nil {
 			f.Close()
-			return err
+			return nil, err
 		}
 		if !fi.IsDir() {
 			f.Close()
-			return fmt.Errorf("found assets file for %s instead of directory", b.name)
+			return nil, fail(failAssets, fmt.Errorf("found assets file for %s instead of directory", b.name))
 		}
 		f.Close()
 		hasAssets = true
 	} else if !errors.Is(err, fs.ErrNotExist) {
-		return err
+		return nil, err
 	}
 
+	// Make sure there's enough room in the work directory before we start
+	// pulling down source and staging assets. It's much better to fail now
+	// than halfway through a build.
+	if r.diskCheck {
+		need := b.diskSpace * uint64(len(cfgs))
+		if hasAssets {
+			// Assets are staged for one configuration at a time.
+			assetsSize, err := fsSize(r.assetsFS, assetsFSDir)
+			if err != nil {
+				return nil, fmt.Errorf("computing size of assets for %s: %v", b.name, err)
+			}
+			need += assetsSize
+		}
+		if err := checkDiskSpace(r.workDir, need); err != nil {
+			return nil, fmt.Errorf("preflight for %s: %v", b.name, err)
+		}
+	}
+	// Identify the assets in the results, so that results produced with
+	// different assets can't be mistaken for one another.
+	if _, ok := r.assetHashes[b.name]; hasAssets && !ok {
+		hash, err := hashAssets(r.assetsFS, assetsFSDir)
+		if err != nil {
+			return nil, fmt.Errorf("hashing assets for %s: %v", b.name, err)
+		}
+		if r.assetHashes == nil {
+			r.assetHashes = make(map[string]string)
+		}
+		r.assetHashes[b.name] = hash
+	}
+	// Every results file begins with these configuration lines.
+	configLines := r.assetsConfigLines(b) + r.labels.configLines() + r.priorityConfigLines(b)
+
+	br := &benchmarkRun{b: b, cfgs: cfgs, r: r, hasAssets: hasAssets, assetsFSDir: assetsFSDir}
+	defer func() {
+		if err != nil {
+			br.close()
+		}
+	}()
+
 	// Retrieve the benchmark's source, if needed. If execute is called
 	// multiple times, this will already be done.
-	_, err := os.Stat(srcDir)
+	_, err = os.Stat(srcDir)
 	if os.IsNotExist(err) {
 		gcfg := &common.GetConfig{
-			SrcDir: srcDir,
-			Short:  r.short,
+			SrcDir:         srcDir,
+			SourceCacheDir: r.sourceCache,
+			Short:          r.short,
 		}
 		if err := b.harness.Get(gcfg); err != nil {
-			return fmt.Errorf("retrieving source for %s: %v", b.name, err)
+			return nil, fail(failAssets, fmt.Errorf("retrieving source for %s: %w", b.name, err))
 		}
 	}
 
 	// Create the results directory for the benchmark.
 	resultsDir := r.benchmarkResultsDir(b)
 	if err := mkdirAll(resultsDir); err != nil {
-		return fmt.Errorf("creating results directory for %s: %v", b.name, err)
+		return nil, fmt.Errorf("creating results directory for %s: %v", b.name, err)
 	}
 
 	// Perform a setup step for each config for the benchmark.
-	setups := make([]common.RunConfig, 0, len(cfgs))
 	for _, pcfg := range cfgs {
 		// Local copy for per-benchmark environment adjustments.
 		cfg := pcfg.Copy()
+		r.progress.setup(cfg.Name)
 
 		// Create directory hierarchy for benchmarks.
 		workDir := filepath.Join(topDir, cfg.Name)
@@ -289,42 +637,42 @@ func (b *benchmark) execute(cfgs []*common.Config, r *runCfg) error {
 		tmpDir := filepath.Join(workDir, "tmp")
 		assetsDir := filepath.Join(workDir, "assets")
 		if err := mkdirAll(binDir); err != nil {
-			return fmt.Errorf("create %s bin for %s: %v", b.name, cfg.Name, err)
+			return nil, fmt.Errorf("create %s bin for %s: %v", b.name, cfg.Name, err)
 		}
 		if err := mkdirAll(srcDir); err != nil {
-			return fmt.Errorf("create %s src for %s: %v", b.name, cfg.Name, err)
+			return nil, fmt.Errorf("create %s src for %s: %v", b.name, cfg.Name, err)
 		}
 		if err := mkdirAll(tmpDir); err != nil {
-			return fmt.Errorf("create %s tmp for %s: %v", b.name, cfg.Name, err)
+			return nil, fmt.Errorf("create %s tmp for %s: %v", b.name, cfg.Name, err)
 		}
 		if hasAssets {
 			if err := mkdirAll(assetsDir); err != nil {
-				return fmt.Errorf("create %s assets dir for %s: %v", b.name, cfg.Name, err)
+				return nil, fmt.Errorf("create %s assets dir for %s: %v", b.name, cfg.Name, err)
 			}
 		}
 
-		// Add PGO if profile specified for this benchmark, otherwise
-		// explicitly disable it to avoid default.pgo files.
-		pgo, ok := cfg.PGOFiles[b.name]
-		if !ok {
-			pgo = "off"
-		}
-		goflags, ok := cfg.BuildEnv.Lookup("GOFLAGS")
-		if ok {
-			goflags += " "
+		cfgSrcDir, err := b.configSrcDir(cfg, srcDir, workDir, r.sourceCache, r.short)
+		if err != nil {
+			return nil, err
 		}
-		goflags += fmt.Sprintf("-pgo=%s", pgo)
-		cfg.BuildEnv.Env = cfg.BuildEnv.MustSet("GOFLAGS=" + goflags)
+
+		b.setPGOFlag(cfg)
 
 		// Build the benchmark (application and any other necessary components).
 		bcfg := common.BuildConfig{
 			BinDir:   binDir,
-			SrcDir:   srcDir,
+			SrcDir:   cfgSrcDir,
 			BenchDir: benchDir,
 			Short:    r.short,
 		}
+		buildStart := time.Now()
 		if err := b.harness.Build(cfg, &bcfg); err != nil {
-			return fmt.Errorf("build %s for %s: %v", b.name, cfg.Name, err)
+			return nil, fail(failBuild, fmt.Errorf("build %s for %s: %w", b.name, cfg.Name, err))
+		}
+		buildTime := time.Since(buildStart)
+		buildResults := filepath.Join(resultsDir, fmt.Sprintf("%s.build.txt", cfg.Name))
+		if err := writeBuildResults(buildResults, configLines, b.name, buildTime, binDir); err != nil {
+			return nil, fmt.Errorf("write build results for %s for %s: %v", b.name, cfg.Name, err)
 		}
 
 		// Generate any args to funnel through to benchmarks.
@@ -346,76 +694,158 @@ func (b *benchmark) execute(cfgs []*common.Config, r *runCfg) error {
 			// Create a directory for any profile files to live in.
 			resultsProfilesDir := r.runProfilesDir(b, cfg)
 			mkdirAll(resultsProfilesDir)
+			// Clean up after any earlier runs into these results that crashed.
+			if n, err := diagnostics.RemoveStaleTmp(resultsProfilesDir, diagnostics.StaleTmpAge, log.Printf); err != nil {
+				log.Printf("warning: removing stale diagnostics for %s for %s: %v", b.name, cfg.Name, err)
+			} else if n > 0 {
+				log.Printf("Reclaimed %d bytes from stale diagnostics in %s", n, resultsProfilesDir)
+			}
 
 			// We need to pass arguments to the benchmark binary to generate
 			// profiles. See benchmarks/internal/driver for details.
-			dc := diagnostics.DriverConfig{ResultsDir: resultsProfilesDir, ConfigSet: cfg.Diagnostics}
+			dc := diagnostics.DriverConfig{ResultsDir: resultsProfilesDir, ConfigSet: cfg.Diagnostics, GoTool: cfg.GoTool().Tool}
 			args = append(args, dc.DriverArgs()...)
 		}
+		if r.cpuFreq {
+			args = append(args, "-cpufreq")
+		}
+		if r.schedStats {
+			args = append(args, "-sched-stats")
+		}
+		if r.netStats {
+			args = append(args, "-net-stats")
+		}
+		if r.strict {
+			args = append(args, "-strict")
+		}
+		if r.cpuLimit > 0 {
+			args = append(args, "-cpu-limit", fmt.Sprint(r.cpuLimit))
+		}
+		if r.deadline > 0 {
+			args = append(args, "-deadline", r.deadline.String())
+		}
+		if r.gomaxprocs != "" {
+			args = append(args, "-gomaxprocs", r.gomaxprocs)
+		}
+		if r.metrics != "" {
+			args = append(args, "-metrics", r.metrics)
+		}
+		if n := r.nice.get(b.name); n != "" {
+			args = append(args, "-nice", n)
+		}
+		if s := r.ionice.get(b.name); s != "" {
+			args = append(args, "-ionice", s)
+		}
+		if b.samples {
+			args = append(args, "-samples-file", filepath.Join(resultsDir, fmt.Sprintf("%s.samples.jsonl", cfg.Name)))
+		}
 
 		// Create log and results file.
 		results, err := os.Create(filepath.Join(resultsDir, fmt.Sprintf("%s.results", cfg.Name)))
 		if err != nil {
-			return fmt.Errorf("create %s results file for %s: %v", b.name, cfg.Name, err)
+			return nil, fmt.Errorf("create %s results file for %s: %v", b.name, cfg.Name, err)
+		}
+		br.results = append(br.results, results)
+		if _, err := results.WriteString(configLines); err != nil {
+			return nil, fmt.Errorf("write %s results file for %s: %v", b.name, cfg.Name, err)
 		}
-		defer results.Close()
 		log, err := os.Create(filepath.Join(resultsDir, fmt.Sprintf("%s.log", cfg.Name)))
 		if err != nil {
-			return fmt.Errorf("create %s log file for %s: %v", b.name, cfg.Name, err)
-		}
-		setups = append(setups, common.RunConfig{
-			BinDir:    binDir,
-			TmpDir:    tmpDir,
-			AssetsDir: assetsDir,
-			Args:      args,
-			Results:   results,
-			Log:       log,
-			Short:     r.short,
+			return nil, fmt.Errorf("create %s log file for %s: %v", b.name, cfg.Name, err)
+		}
+		br.setups = append(br.setups, common.RunConfig{
+			BinDir:         binDir,
+			TmpDir:         tmpDir,
+			AssetsDir:      assetsDir,
+			Args:           args,
+			Results:        results,
+			Log:            log,
+			Short:          r.short,
+			ClientHost:     r.clientHost,
+			ServerHost:     r.serverHost,
+			PortsDir:       common.PortsDir(),
+			KeyDist:        r.keyDist,
+			RunscPlatforms: r.runscPlatforms,
+			ServerProcs:    r.serverProcs,
+			ClientProcs:    r.clientProcs,
 		})
 	}
 
-	for j := 0; j < r.count; j++ {
-		// Execute the benchmark for each configuration.
-		for i, setup := range setups {
-			if hasAssets {
-				// Set up assets directory for test run.
-				r.logCopyDirCommand(b.name, setup.AssetsDir)
-				if err := fileutil.CopyDir(setup.AssetsDir, assetsFSDir, r.assetsFS); err != nil {
-					return err
-				}
-			}
+	return br, nil
+}
 
-			log.Printf("Running benchmark %s for %s: run %d", b.name, cfgs[i].Name, j+1)
-			// Force a GC now because we're about to turn it off.
-			runtime.GC()
-			// Hold your breath: we're turning off GC for the duration of the
-			// run so that the suite's GC doesn't start blasting on all Ps,
-			// introducing undue noise into the experiments.
-			gogc := debug.SetGCPercent(-1)
-			if err := b.harness.Run(cfgs[i], &setup); err != nil {
-				debug.SetGCPercent(gogc)
-				// Useful error messages are often in the log. Grab the end.
-				logTail, tailErr := readFileTail(setup.Log)
-				if tailErr != nil {
-					logTail = fmt.Sprintf("error reading log tail: %s", tailErr)
-				}
-				logName := setup.Log.Name()
-				setup.Log.Close()
-				return fmt.Errorf("run benchmark %s for config %s: %v\nTail of log (%s):\n%s", b.name, cfgs[i].Name, err, logName, logTail)
-			}
-			debug.SetGCPercent(gogc)
+// run does run number run of the benchmark for configuration i.
+func (br *benchmarkRun) run(i, run int) error {
+	b, r, cfg := br.b, br.r, br.cfgs[i]
+	setup := &br.setups[i]
+	if br.hasAssets {
+		// Set up assets directory for test run.
+		r.logCopyDirCommand(b.name, setup.AssetsDir)
+		if err := fileutil.CopyDir(setup.AssetsDir, br.assetsFSDir, r.assetsFS); err != nil {
+			return fail(failAssets, err)
+		}
+	}
 
-			// Clean up tmp directory so benchmarks may assume it's empty.
-			if err := rmDirContents(setup.TmpDir); err != nil {
-				return err
-			}
-			if hasAssets {
-				// Clean up assets directory just in case any of the files were written to.
-				if err := rmDirContents(setup.AssetsDir); err != nil {
-					return err
-				}
-			}
+	// Take the baseline for the leak check just before the run, since
+	// other benchmarks may run between this one's runs.
+	scopesBefore, err := liveCgroupScopes()
+	if err != nil {
+		log.Printf("warning: failed to list cgroup scopes: %v", err)
+	}
+
+	log.Printf("Running benchmark %s for %s: run %d", b.name, cfg.Name, run)
+	r.progress.startRun(cfg.Name, run)
+	// Force a GC now because we're about to turn it off.
+	runtime.GC()
+	// Hold your breath: we're turning off GC for the duration of the
+	// run so that the suite's GC doesn't start blasting on all Ps,
+	// introducing undue noise into the experiments.
+	gogc := debug.SetGCPercent(-1)
+	if err := b.harness.Run(cfg, setup); err != nil {
+		debug.SetGCPercent(gogc)
+		// Useful error messages are often in the log. Grab the end.
+		logTail, tailErr := readFileTail(setup.Log)
+		if tailErr != nil {
+			logTail = fmt.Sprintf("error reading log tail: %s", tailErr)
 		}
+		logName := setup.Log.Name()
+		setup.Log.Close()
+		return fail(failRun, fmt.Errorf("run benchmark %s for config %s: %w\nTail of log (%s):\n%s", b.name, cfg.Name, err, logName, logTail))
 	}
+	debug.SetGCPercent(gogc)
+	r.progress.finishRun()
+
+	// Clean up tmp directory so benchmarks may assume it's empty.
+	if err := rmDirContents(setup.TmpDir); err != nil {
+		return err
+	}
+	if br.hasAssets {
+		// Clean up assets directory just in case any of the files were written to.
+		if err := rmDirContents(setup.AssetsDir); err != nil {
+			return err
+		}
+	}
+	checkScopesReclaimed(b.name, scopesBefore)
 	return nil
 }
+
+// check verifies that the benchmark didn't leave anything behind after
+// all its runs.
+func (br *benchmarkRun) check() {
+	// Stray processes in particular may keep writing to scratch
+	// directories after we've cleaned them up, so check those too.
+	for _, setup := range br.setups {
+		checkDirEmpty(setup.TmpDir)
+		if br.hasAssets {
+			checkDirEmpty(setup.AssetsDir)
+		}
+	}
+}
+
+// close closes the results files of the benchmark.
+func (br *benchmarkRun) close() {
+	for _, f := range br.results {
+		f.Close()
+	}
+	br.results = nil
+}
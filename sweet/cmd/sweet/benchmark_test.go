@@ -6,9 +6,15 @@ package main
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/common/diagnostics"
 )
 
 func TestReadFileTail(t *testing.T) {
@@ -49,3 +55,95 @@ func TestReadFileTail(t *testing.T) {
 		strings.Repeat("a", 32<<10)+"\nb\n",
 		strings.Repeat("a", 16<<10-3)+"\nb\n")
 }
+
+func TestWriteBuildResults(t *testing.T) {
+	tmpDir := t.TempDir()
+	binDir := filepath.Join(tmpDir, "bin")
+	if err := os.MkdirAll(filepath.Join(binDir, "goroot"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"foo-bench": 1000, "helper": 24, filepath.Join("goroot", "big"): 1 << 20} {
+		if err := os.WriteFile(filepath.Join(binDir, name), make([]byte, size), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(tmpDir, filepath.Join(binDir, "src")); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(tmpDir, "cfg.build.txt")
+	if err := writeBuildResults(file, "assets-version: v0.3.0\n", "foo", 1500*time.Millisecond, binDir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "assets-version: v0.3.0\nBenchmarkBuild/foo 1 1500000000 build-real-ns 1024 bin-bytes\n"; string(got) != want {
+		t.Errorf("got build results %q, want %q", got, want)
+	}
+}
+
+func TestHashAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"foo/a.txt":     {Data: []byte("hello")},
+		"foo/sub/b.txt": {Data: []byte("world")},
+		"bar/a.txt":     {Data: []byte("other")},
+	}
+	hash := func(fsys fs.FS, dir string) string {
+		t.Helper()
+		h, err := hashAssets(fsys, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	foo := hash(fsys, "foo")
+
+	// The same assets under another name hash the same.
+	moved := fstest.MapFS{
+		"baz/a.txt":     {Data: []byte("hello")},
+		"baz/sub/b.txt": {Data: []byte("world")},
+	}
+	if got := hash(moved, "baz"); got != foo {
+		t.Errorf("hash of moved assets is %s, want %s", got, foo)
+	}
+
+	// Changing the contents or the layout changes the hash.
+	for name, fsys := range map[string]fstest.MapFS{
+		"contents": {
+			"foo/a.txt":     {Data: []byte("hellO")},
+			"foo/sub/b.txt": {Data: []byte("world")},
+		},
+		"layout": {
+			"foo/a.txt":   {Data: []byte("hello")},
+			"foo/sub/b.c": {Data: []byte("world")},
+		},
+		"boundary": {
+			"foo/a.txt":     {Data: []byte("hellow")},
+			"foo/sub/b.txt": {Data: []byte("orld")},
+		},
+	} {
+		if hash(fsys, "foo") == foo {
+			t.Errorf("changing the %s of the assets didn't change their hash", name)
+		}
+	}
+}
+
+func TestBenchmarkGroups(t *testing.T) {
+	for name, grp := range benchmarkGroups {
+		if _, ok := allBenchmarksMap[name]; ok {
+			t.Errorf("group %s has the same name as a benchmark, so the benchmark can't be run on its own", name)
+		}
+		for i, b := range grp {
+			if b == nil {
+				t.Errorf("group %s: benchmark %d doesn't exist", name, i)
+			}
+		}
+	}
+	for _, b := range benchmarkGroups["smoke"] {
+		if b != nil && (b.remoteClients || b.pgoProfile == diagnostics.ServerName) {
+			t.Errorf("smoke group includes %s, which runs a server", b.name)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+const (
+	// regressionThresholdDefault is the default -regression-threshold,
+	// as a percentage.
+	regressionThresholdDefault = 5
+
+	// regressionAlphaDefault is the default -regression-alpha.
+	regressionAlphaDefault = 0.05
+)
+
+// thresholdsFlag holds a comma-separated list of percentages by which a
+// metric may get worse before it counts as a regression, each of which
+// applies either to every performance metric or, written as
+// unit=percentage, to the metric with that unit. A percentage of "off"
+// never counts a change as a regression. Diagnostic metrics, which aren't
+// performance metrics, are only compared if their unit is given.
+type thresholdsFlag struct {
+	all  string
+	unit map[string]string
+}
+
+func (t *thresholdsFlag) String() string {
+	var s []string
+	for u, v := range t.unit {
+		s = append(s, u+"="+v)
+	}
+	sort.Strings(s)
+	if t.all != "" {
+		s = append([]string{t.all}, s...)
+	}
+	return strings.Join(s, ",")
+}
+
+func (t *thresholdsFlag) Set(input string) error {
+	for _, s := range strings.Split(input, ",") {
+		u, v, ok := strings.Cut(s, "=")
+		if !ok {
+			u, v = "", s
+		} else if u == "" {
+			return fmt.Errorf("threshold %q has an empty unit", s)
+		}
+		if _, err := parseThreshold(v); err != nil {
+			return err
+		}
+		if u == "" {
+			t.all = v
+			continue
+		}
+		if t.unit == nil {
+			t.unit = make(map[string]string)
+		}
+		t.unit[u] = v
+	}
+	return nil
+}
+
+// get returns the threshold for metrics with unit, as a percentage, or
+// +Inf if regressions in them are ignored.
+func (t *thresholdsFlag) get(unit string) float64 {
+	v, ok := t.unit[unit]
+	if !ok {
+		if !performanceUnit(unit) {
+			return math.Inf(1)
+		}
+		v = t.all
+	}
+	if v == "" {
+		return regressionThresholdDefault
+	}
+	pct, _ := parseThreshold(v)
+	return pct
+}
+
+func parseThreshold(s string) (float64, error) {
+	if s == "off" {
+		return math.Inf(1), nil
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || pct < 0 || math.IsNaN(pct) || math.IsInf(pct, 0) {
+		return 0, fmt.Errorf("threshold %q is not a non-negative percentage or \"off\"", s)
+	}
+	return pct, nil
+}
+
+// A metricKey identifies a metric of one benchmark in a results file.
+type metricKey struct {
+	name string // Benchmark name, without the "Benchmark" prefix.
+	unit string
+}
+
+// readResultsFile returns the values of every metric in the Go benchmark
+// format results file, in the order they appear.
+func readResultsFile(file string) (map[metricKey][]float64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	metrics := make(map[metricKey][]float64)
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		name, ok := strings.CutPrefix(s.Text(), "Benchmark")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(name)
+		// A result line is the name, the iteration count, and any
+		// number of value-unit pairs.
+		if len(fields) < 4 || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		for i := 2; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			k := metricKey{fields[0], fields[i+1]}
+			metrics[k] = append(metrics[k], v)
+		}
+	}
+	return metrics, s.Err()
+}
+
+// latencyUnit matches the units of latency percentiles and averages, such
+// as p99-latency-ns and p50-apply-latency-ns.
+var latencyUnit = regexp.MustCompile(`^(p[0-9.]+|avg)(-[a-z]+)?-latency-ns$`)
+
+// performanceUnit reports whether metrics with unit measure performance,
+// as costs per operation, throughputs and latencies do, rather than being
+// diagnostics such as GC counts or scheduling latencies.
+func performanceUnit(unit string) bool {
+	switch {
+	case unit == "offered-ops/s":
+		// The load the benchmark was asked to apply, not what it achieved.
+		return false
+	case strings.HasSuffix(unit, "/op"), strings.HasSuffix(unit, "/s"):
+		return true
+	}
+	return latencyUnit.MatchString(unit)
+}
+
+// higherIsBetter reports whether larger values of metrics with unit are
+// improvements, as they are for throughputs.
+func higherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s") || unit == "parallel-efficiency-%"
+}
+
+// A metricChange is how one metric changed from a baseline run.
+type metricChange struct {
+	file      string // Results file, relative to the results directory.
+	key       metricKey
+	old, new  float64 // Medians.
+	delta     float64 // Change of the median as a percentage of old, with positive meaning worse.
+	p         float64 // Probability of a difference at least this large if there were none.
+	threshold float64
+}
+
+func (c *metricChange) String() string {
+	return fmt.Sprintf("%s: %s %s: %s -> %s (%.2f%% worse, p=%.3f, threshold %g%%)",
+		c.file, c.key.name, c.key.unit, strconv.FormatFloat(c.old, 'g', 4, 64),
+		strconv.FormatFloat(c.new, 'g', 4, 64), c.delta, c.p, c.threshold)
+}
+
+// compareMetrics returns the regressions in the metrics from results file
+// cur relative to those in base: the metrics whose medians got worse by
+// more than their thresholds, by a margin unlikely to be noise at
+// significance level alpha. It also returns how many metrics it compared.
+func compareMetrics(file string, base, cur map[metricKey][]float64, thresholds *thresholdsFlag, alpha float64) (regressions []*metricChange, compared int) {
+	for k, newVals := range cur {
+		oldVals, ok := base[k]
+		if !ok {
+			continue
+		}
+		threshold := thresholds.get(k.unit)
+		if math.IsInf(threshold, 1) {
+			continue
+		}
+		compared++
+		c := &metricChange{
+			file:      file,
+			key:       k,
+			old:       median(oldVals),
+			new:       median(newVals),
+			threshold: threshold,
+		}
+		if c.old == 0 {
+			continue
+		}
+		c.delta = 100 * (c.new - c.old) / math.Abs(c.old)
+		if higherIsBetter(k.unit) {
+			c.delta = -c.delta
+		}
+		if c.delta <= c.threshold {
+			continue
+		}
+		c.p = mannWhitneyU(oldVals, newVals)
+		if c.p <= alpha {
+			regressions = append(regressions, c)
+		}
+	}
+	slices.SortFunc(regressions, func(a, b *metricChange) int {
+		if a.key.name != b.key.name {
+			return strings.Compare(a.key.name, b.key.name)
+		}
+		return strings.Compare(a.key.unit, b.key.unit)
+	})
+	return regressions, compared
+}
+
+func median(vals []float64) float64 {
+	s := slices.Clone(vals)
+	slices.Sort(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test
+// of whether samples a and b come from the same distribution, using the
+// normal approximation with corrections for ties and continuity. Unlike a
+// t-test, it makes no assumptions about the shape of the distributions,
+// which for benchmarks are often skewed by outliers.
+func mannWhitneyU(a, b []float64) float64 {
+	type sample struct {
+		v     float64
+		fromA bool
+	}
+	var all []sample
+	for _, v := range a {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range b {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Rank the samples, giving tied ones the average of their ranks.
+	n := float64(len(all))
+	var rankA, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // Average of the 1-based ranks i+1 to j.
+		for _, s := range all[i:j] {
+			if s.fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	na, nb := float64(len(a)), float64(len(b))
+	u := rankA - na*(na+1)/2
+	mu := na * nb / 2
+	sigma := math.Sqrt(na * nb / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 || math.IsNaN(sigma) {
+		return 1
+	}
+	z := max(math.Abs(u-mu)-0.5, 0) / sigma
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// minRegressionCount returns the smallest number of runs of each benchmark
+// for which a change can be significant at level alpha, which is when the
+// runs before and after it don't overlap at all.
+func minRegressionCount(alpha float64) int {
+	for n := 1; ; n++ {
+		a := make([]float64, n)
+		b := make([]float64, n)
+		for i := range a {
+			a[i], b[i] = float64(i), float64(n+i)
+		}
+		if mannWhitneyU(a, b) <= alpha {
+			return n
+		}
+	}
+}
+
+// checkBaseline returns an error if dir can't be compared against.
+func checkBaseline(dir, resultsDir string) error {
+	if dir == resultsDir {
+		return fmt.Errorf("-baseline-results must be different from -results")
+	}
+	m, err := readManifest(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("baseline results directory %s has no %s", dir, manifestFileName)
+	} else if err != nil {
+		return fmt.Errorf("reading baseline results: %v", err)
+	}
+	if m.LayoutVersion != resultsLayoutVersion {
+		return fmt.Errorf("baseline results directory %s has layout version %d, but this version of sweet produces layout version %d", dir, m.LayoutVersion, resultsLayoutVersion)
+	}
+	return nil
+}
+
+// compareBaseline compares the results of every benchmark and config
+// that ran against those in the same files of the baseline results
+// directory, and returns an error listing any regressions.
+func (c *runCmd) compareBaseline(configs []*common.Config, benchmarks []*benchmark) error {
+	var regressions []*metricChange
+	compared := 0
+	for _, b := range benchmarks {
+		for _, cfg := range configs {
+			for _, suffix := range []string{".results", ".build.txt"} {
+				rel := filepath.Join(b.name, cfg.Name+suffix)
+				cur, err := readResultsFile(filepath.Join(c.resultsDir, rel))
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				} else if err != nil {
+					return err
+				}
+				base, err := readResultsFile(filepath.Join(c.baselineResults, rel))
+				if errors.Is(err, fs.ErrNotExist) {
+					if suffix == ".results" {
+						log.Printf("warning: no baseline results for %s", rel)
+					}
+					continue
+				} else if err != nil {
+					return fmt.Errorf("reading baseline results: %v", err)
+				}
+				r, n := compareMetrics(rel, base, cur, &c.regressionThresholds, c.regressionAlpha)
+				regressions = append(regressions, r...)
+				compared += n
+			}
+		}
+	}
+	log.Printf("Compared %d metrics against %s: %d regressions", compared, c.baselineResults, len(regressions))
+	if len(regressions) == 0 {
+		return nil
+	}
+	var s strings.Builder
+	fmt.Fprintf(&s, "performance regressions against %s:", c.baselineResults)
+	for _, r := range regressions {
+		fmt.Fprintf(&s, "\n\t%s", r)
+	}
+	return fail(failRegression, errors.New(s.String()))
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThresholdsFlag(t *testing.T) {
+	var f thresholdsFlag
+	if got := f.get("ns/op"); got != regressionThresholdDefault {
+		t.Errorf("default threshold %g, want %d", got, regressionThresholdDefault)
+	}
+	if err := f.Set("3%,peak-RSS-bytes=10,gc-cycles=off"); err != nil {
+		t.Fatal(err)
+	}
+	for unit, want := range map[string]float64{
+		"ns/op":          3,
+		"p99-latency-ns": 3,
+		"peak-RSS-bytes": 10,
+		"gc-cycles":      math.Inf(1),
+		// Diagnostics are only compared if their unit is given.
+		"trace-max-runnable-latency-ns": math.Inf(1),
+	} {
+		if got := f.get(unit); got != want {
+			t.Errorf("threshold for %s is %g, want %g", unit, got, want)
+		}
+	}
+	if got, want := f.String(), "3%,gc-cycles=off,peak-RSS-bytes=10"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, bad := range []string{"-1%", "five", "=3%", "ns/op=", "NaN"} {
+		if err := new(thresholdsFlag).Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestPerformanceUnit(t *testing.T) {
+	for unit, want := range map[string]bool{
+		"sec/op":                        true,
+		"ns/op":                         true,
+		"B/op":                          true,
+		"allocs/op":                     true,
+		"ops/s":                         true,
+		"MB/s":                          true,
+		"p50-latency-ns":                true,
+		"p99.9-latency-ns":              true,
+		"p99-apply-latency-ns":          true,
+		"avg-latency-ns":                true,
+		"offered-ops/s":                 false,
+		"peak-RSS-bytes":                false,
+		"gc-cycles":                     false,
+		"parallel-efficiency-%":         false,
+		"trace-max-runnable-latency-ns": false,
+	} {
+		if got := performanceUnit(unit); got != want {
+			t.Errorf("performanceUnit(%q) = %v, want %v", unit, got, want)
+		}
+	}
+}
+
+func TestMinRegressionCount(t *testing.T) {
+	for _, tc := range []struct {
+		alpha float64
+		want  int
+	}{
+		{1, 1},
+		{0.1, 3},
+		{0.05, 4},
+		{0.01, 6},
+	} {
+		if got := minRegressionCount(tc.alpha); got != tc.want {
+			t.Errorf("minRegressionCount(%g) = %d, want %d", tc.alpha, got, tc.want)
+		}
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	for _, tc := range []struct {
+		a, b []float64
+		want float64
+	}{
+		// Completely separated samples of 5 (exact p is 0.0079).
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.0122},
+		// Identical samples.
+		{[]float64{1, 1, 1}, []float64{1, 1, 1}, 1},
+		// Interleaved samples.
+		{[]float64{1, 3, 5, 7}, []float64{2, 4, 6, 8}, 0.6650},
+		// Too few samples to tell anything.
+		{[]float64{1}, []float64{2}, 1},
+	} {
+		if got := mannWhitneyU(tc.a, tc.b); math.Abs(got-tc.want) > 1e-4 {
+			t.Errorf("mannWhitneyU(%v, %v) = %.4f, want %.4f", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCompareMetrics(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) map[metricKey][]float64 {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := readResultsFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	base := write("base.results", `goos: linux
+BenchmarkA 1 100 ns/op 1000 ops/s 10 gc-cycles
+BenchmarkA 1 101 ns/op 1010 ops/s 11 gc-cycles
+BenchmarkA 1 102 ns/op 1020 ops/s 10 gc-cycles
+BenchmarkA 1 103 ns/op 1030 ops/s 11 gc-cycles
+BenchmarkA 1 104 ns/op 1040 ops/s 10 gc-cycles
+BenchmarkB 1 50 ns/op
+`)
+	cur := write("cur.results", `goos: linux
+BenchmarkA 1 120 ns/op 900 ops/s 20 gc-cycles
+BenchmarkA 1 121 ns/op 910 ops/s 21 gc-cycles
+BenchmarkA 1 122 ns/op 920 ops/s 20 gc-cycles
+BenchmarkA 1 123 ns/op 930 ops/s 21 gc-cycles
+BenchmarkA 1 124 ns/op 940 ops/s 20 gc-cycles
+BenchmarkB 1 500 ns/op
+BenchmarkC 1 1 ns/op
+`)
+	if got := len(cur[metricKey{"A", "ns/op"}]); got != 5 {
+		t.Fatalf("read %d values of A ns/op, want 5", got)
+	}
+
+	var thresholds thresholdsFlag
+	if err := thresholds.Set("ops/s=15"); err != nil {
+		t.Fatal(err)
+	}
+	regressions, compared := compareMetrics("a.results", base, cur, &thresholds, regressionAlphaDefault)
+	// B got 10 times slower, but with one run of each, that could be
+	// noise. C has no baseline, and gc-cycles is a diagnostic.
+	if compared != 3 {
+		t.Errorf("compared %d metrics, want 3", compared)
+	}
+	if len(regressions) != 1 {
+		t.Fatalf("got %d regressions, want 1: %v", len(regressions), regressions)
+	}
+	r := regressions[0]
+	if r.key != (metricKey{"A", "ns/op"}) || r.old != 102 || r.new != 122 {
+		t.Errorf("got regression %s, want A ns/op from 102 to 122", r)
+	}
+
+	// Throughput falling counts as a regression, and so do diagnostics
+	// that are opted in.
+	thresholds = thresholdsFlag{}
+	if err := thresholds.Set("gc-cycles=5"); err != nil {
+		t.Fatal(err)
+	}
+	regressions, _ = compareMetrics("a.results", base, cur, &thresholds, regressionAlphaDefault)
+	var units []string
+	for _, r := range regressions {
+		units = append(units, r.key.unit)
+	}
+	if len(units) != 3 || units[0] != "gc-cycles" || units[1] != "ns/op" || units[2] != "ops/s" {
+		t.Errorf("got regressions in %v, want gc-cycles, ns/op and ops/s", units)
+	}
+}
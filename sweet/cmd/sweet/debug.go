@@ -0,0 +1,224 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/cli/bootstrap"
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/fileutil"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+const (
+	debugLongDesc = `Execute exactly one step of a benchmark's harness, for debugging the harness.
+
+The get step retrieves the benchmark's source, the build step builds it for
+each configuration, and the run step runs it once for each configuration.
+Every command executed is printed, and the work directory is never cleaned
+up, so each step picks up where the last one left off and its results can be
+inspected, or the step re-run, as many times as necessary. The layout of the
+work directory is the same as for "run -work-dir".
+
+The run step writes results to stdout and the benchmark's log to stderr.`
+	debugUsage = `Usage: %s debug [flags] -step=<get|build|run> -bench=<benchmark> [configs...]
+`
+)
+
+type debugCmd struct {
+	runCfg
+	step  string
+	bench string
+}
+
+func (*debugCmd) Name() string     { return "debug" }
+func (*debugCmd) Synopsis() string { return "Executes a single step of a benchmark's harness." }
+func (*debugCmd) PrintUsage(w io.Writer, base string) {
+	fmt.Fprintln(w, debugLongDesc)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, debugUsage, base)
+}
+
+func (c *debugCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.step, "step", "", "the harness step to execute: get, build, or run")
+	f.StringVar(&c.bench, "bench", "", "the benchmark whose harness to execute")
+	f.StringVar(&c.runCfg.workDir, "work-dir", "./sweet-debug", "work directory for the benchmark, kept between steps")
+	f.StringVar(&c.runCfg.benchDir, "bench-dir", "./benchmarks", "the benchmarks directory in the sweet source")
+	f.StringVar(&c.runCfg.assetsDir, "assets-dir", "", "a directory containing uncompressed assets for sweet benchmarks (overrides -cache)")
+	f.StringVar(&c.runCfg.assetsCache, "cache", bootstrap.CacheDefault(), "cache location for assets")
+	f.BoolVar(&c.short, "short", false, "whether to execute the short version of the benchmark")
+}
+
+func (c *debugCmd) Run(args []string) (err error) {
+	log.SetCommandTrace(true)
+	log.SetActivityLog(true)
+
+	b, ok := allBenchmarksMap[c.bench]
+	if !ok {
+		return fmt.Errorf("unknown benchmark (-bench): %q", c.bench)
+	}
+	switch c.step {
+	case "get":
+	case "build", "run":
+		if len(args) == 0 {
+			return fmt.Errorf("at least one configuration is required for the %s step", c.step)
+		}
+	default:
+		return fmt.Errorf("unknown step (-step): %q; must be one of get, build, or run", c.step)
+	}
+
+	c.workDir, err = filepath.Abs(c.workDir)
+	if err != nil {
+		return fmt.Errorf("creating absolute path from provided work root (-work-dir): %w", err)
+	}
+	c.benchDir, err = filepath.Abs(c.benchDir)
+	if err != nil {
+		return fmt.Errorf("creating absolute path from benchmarks path (-bench-dir): %w", err)
+	}
+	if c.step == "run" {
+		closeAssets, err := c.openAssets(false)
+		if err != nil {
+			return err
+		}
+		defer closeAssets()
+	}
+	if err := checkBenchDir(c.benchDir); err != nil {
+		return err
+	}
+	configs, err := readConfigs(args)
+	if err != nil {
+		return err
+	}
+	if err := b.harness.CheckPrerequisites(); err != nil {
+		return fmt.Errorf("failed to meet prerequisites for %s: %v", b.name, err)
+	}
+
+	topDir := filepath.Join(c.workDir, b.name)
+	srcDir := filepath.Join(topDir, "src")
+	switch c.step {
+	case "get":
+		err = c.debugGet(b, srcDir)
+	case "build":
+		err = c.debugBuild(b, srcDir, topDir, configs)
+	case "run":
+		err = c.debugRun(b, topDir, configs)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Work directory for %s: %s", b.name, topDir)
+	return nil
+}
+
+// debugGet retrieves the source for b into srcDir, replacing any source
+// already there. The source cache is bypassed, so that the harness always
+// does the work.
+func (c *debugCmd) debugGet(b *benchmark, srcDir string) error {
+	log.CommandPrintf("rm -rf %s", srcDir)
+	if err := os.RemoveAll(srcDir); err != nil {
+		return err
+	}
+	gcfg := &common.GetConfig{
+		SrcDir: srcDir,
+		Short:  c.short,
+	}
+	if err := b.harness.Get(gcfg); err != nil {
+		return fmt.Errorf("retrieving source for %s: %v", b.name, err)
+	}
+	// Not every harness has source to retrieve, but the build step
+	// expects the source directory to exist, just as in a full run.
+	return mkdirAll(srcDir)
+}
+
+// debugBuild builds b from the source in srcDir for each of cfgs.
+func (c *debugCmd) debugBuild(b *benchmark, srcDir, topDir string, cfgs []*common.Config) error {
+	if _, err := os.Stat(srcDir); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no source for %s in %s: execute the get step first", b.name, srcDir)
+	}
+	for _, pcfg := range cfgs {
+		cfg := pcfg.Copy()
+		binDir := filepath.Join(topDir, cfg.Name, "bin")
+		if err := mkdirAll(binDir); err != nil {
+			return fmt.Errorf("create %s bin for %s: %v", b.name, cfg.Name, err)
+		}
+		cfgSrcDir, err := b.configSrcDir(cfg, srcDir, filepath.Join(topDir, cfg.Name), "", c.short)
+		if err != nil {
+			return err
+		}
+		b.setPGOFlag(cfg)
+		bcfg := common.BuildConfig{
+			BinDir:   binDir,
+			SrcDir:   cfgSrcDir,
+			BenchDir: b.benchDir(c.benchDir),
+			Short:    c.short,
+		}
+		log.Printf("Building benchmark %s for %s", b.name, cfg.Name)
+		if err := b.harness.Build(cfg, &bcfg); err != nil {
+			return fmt.Errorf("build %s for %s: %v", b.name, cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// debugRun runs b once for each of cfgs, with the binaries left by the
+// build step. Unlike a full run, the scratch and assets directories are
+// left as the benchmark left them, for inspection.
+func (c *debugCmd) debugRun(b *benchmark, topDir string, cfgs []*common.Config) error {
+	hasAssets := false
+	if fi, err := fs.Stat(c.assetsFS, b.name); err == nil && fi.IsDir() {
+		hasAssets = true
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, cfg := range cfgs {
+		workDir := filepath.Join(topDir, cfg.Name)
+		binDir := filepath.Join(workDir, "bin")
+		tmpDir := filepath.Join(workDir, "tmp")
+		assetsDir := filepath.Join(workDir, "assets")
+		if _, err := os.Stat(binDir); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no binaries for %s for %s in %s: execute the build step first", b.name, cfg.Name, binDir)
+		}
+
+		// Start from empty scratch and assets directories, as a full run
+		// would, clearing out anything left by the last run step.
+		for _, dir := range []string{tmpDir, assetsDir} {
+			log.CommandPrintf("rm -rf %s", dir)
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+			if err := mkdirAll(dir); err != nil {
+				return err
+			}
+		}
+		if hasAssets {
+			c.logCopyDirCommand(b.name, assetsDir)
+			if err := fileutil.CopyDir(assetsDir, b.name, c.assetsFS); err != nil {
+				return err
+			}
+		}
+
+		rcfg := common.RunConfig{
+			BinDir:    binDir,
+			TmpDir:    tmpDir,
+			AssetsDir: assetsDir,
+			Results:   os.Stdout,
+			Log:       os.Stderr,
+			Short:     c.short,
+			PortsDir:  common.PortsDir(),
+		}
+		log.Printf("Running benchmark %s for %s", b.name, cfg.Name)
+		if err := b.harness.Run(cfg, &rcfg); err != nil {
+			return fmt.Errorf("run benchmark %s for config %s: %v", b.name, cfg.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// Convenient units for expressing disk space estimates.
+const (
+	mib = 1 << 20
+	gib = 1 << 30
+)
+
+// errDiskSpaceUnsupported is returned by freeDiskSpace on platforms where
+// the available disk space cannot be queried.
+var errDiskSpaceUnsupported = errors.New("free disk space unsupported")
+
+// fsSize returns the total size in bytes of all regular files under
+// dir in fsys.
+func fsSize(fsys fs.FS, dir string) (uint64, error) {
+	var total uint64
+	err := fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += uint64(fi.Size())
+		return nil
+	})
+	return total, err
+}
+
+// formatBytes formats n in a human-readable way for error messages.
+func formatBytes(n uint64) string {
+	switch {
+	case n >= gib:
+		return fmt.Sprintf("%.1f GiB", float64(n)/gib)
+	case n >= mib:
+		return fmt.Sprintf("%.1f MiB", float64(n)/mib)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// checkDiskSpace returns an error if the file system containing dir
+// has less than need bytes available to unprivileged users.
+//
+// If the available space cannot be determined on this platform,
+// checkDiskSpace logs a warning and returns nil.
+func checkDiskSpace(dir string, need uint64) error {
+	avail, err := freeDiskSpace(dir)
+	if err == errDiskSpaceUnsupported {
+		log.Printf("warning: cannot determine free disk space on this platform; skipping preflight check")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("checking free disk space in %s: %w", dir, err)
+	}
+	if avail < need {
+		return fmt.Errorf("not enough disk space in %s: need an estimated %s, have %s (use -disk-check=false to skip this check)", dir, formatBytes(need), formatBytes(avail))
+	}
+	return nil
+}
+
+// checkDirEmpty logs a warning for each entry left behind in dir.
+// Benchmarks are expected to leave their scratch directories empty
+// once the harness has cleaned up after a run; anything left over
+// eats into the disk space available to the next benchmark.
+func checkDirEmpty(dir string) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("warning: checking %s was reclaimed: %v", dir, err)
+		return
+	}
+	for _, de := range des {
+		log.Printf("warning: leaked file in %s after cleanup: %s", dir, de.Name())
+	}
+}
+
+// checkScopesReclaimed logs a warning for each cgroup scope that still
+// has live processes and did not exist in before. A nil before means no
+// baseline was taken, and skips the check.
+func checkScopesReclaimed(name string, before map[string]bool) {
+	if before == nil {
+		return
+	}
+	after, err := liveCgroupScopes()
+	if err != nil {
+		log.Printf("warning: checking cgroup scopes were reclaimed for %s: %v", name, err)
+		return
+	}
+	var leaked []string
+	for scope := range after {
+		if !before[scope] {
+			leaked = append(leaked, scope)
+		}
+	}
+	sort.Strings(leaked)
+	for _, scope := range leaked {
+		log.Printf("warning: cgroup scope %s still has live processes after %s completed", scope, name)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func freeDiskSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// liveCgroupScopes returns the set of systemd scopes in the current user's
+// app.slice that still contain processes. This is where the cgroups package
+// used by the benchmarks places the scopes it creates. It returns nil only
+// with an error.
+func liveCgroupScopes() (map[string]bool, error) {
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	slice := filepath.Join("/sys/fs/cgroup/user.slice",
+		fmt.Sprintf("user-%s.slice/user@%s.service/app.slice", u.Uid, u.Uid))
+	des, err := os.ReadDir(slice)
+	if errors.Is(err, fs.ErrNotExist) {
+		// No systemd user session, so no scopes could have been created.
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	scopes := make(map[string]bool)
+	for _, de := range des {
+		if !de.IsDir() || !strings.HasSuffix(de.Name(), ".scope") {
+			continue
+		}
+		procs, err := os.ReadFile(filepath.Join(slice, de.Name(), "cgroup.procs"))
+		if err != nil {
+			// The scope may have been torn down underneath us.
+			continue
+		}
+		if len(strings.TrimSpace(string(procs))) != 0 {
+			scopes[de.Name()] = true
+		}
+	}
+	return scopes, nil
+}
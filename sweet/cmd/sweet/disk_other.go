@@ -0,0 +1,15 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errDiskSpaceUnsupported
+}
+
+func liveCgroupScopes() (map[string]bool, error) {
+	return nil, nil
+}
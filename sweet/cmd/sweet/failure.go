@@ -0,0 +1,142 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// A failureKind classifies why sweet run failed. Its value is the exit
+// code of sweet run, so that automation can route failures without
+// parsing logs. Any other failure exits with code 1.
+type failureKind int
+
+const (
+	failConfig       failureKind = 2 + iota // Bad flags or configuration files.
+	failPrerequisite                        // A benchmark's prerequisites are missing.
+	failAssets                              // Assets or benchmark sources are missing or couldn't be fetched.
+	failBuild                               // A benchmark failed to build.
+	failRun                                 // A benchmark failed to run.
+	failTimeout                             // An operation timed out, e.g. fetching sources, reaching a client host or a benchmark run past -deadline.
+	failRegression                          // A metric regressed from -baseline-results.
+)
+
+var failureKindNames = map[failureKind]string{
+	failConfig:       "configuration error",
+	failPrerequisite: "missing prerequisite",
+	failAssets:       "missing assets",
+	failBuild:        "build failure",
+	failRun:          "run failure",
+	failTimeout:      "timeout",
+	failRegression:   "performance regression",
+}
+
+func (k failureKind) String() string {
+	if name, ok := failureKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("failureKind(%d)", int(k))
+}
+
+// failure is an error of a known failureKind.
+type failure struct {
+	kind failureKind
+	err  error
+}
+
+func (f *failure) Error() string { return f.err.Error() }
+func (f *failure) Unwrap() error { return f.err }
+func (f *failure) ExitCode() int { return int(f.kind) }
+
+// fail classifies err, if it isn't nil, as a failure of the given kind,
+// unless it is already classified or is a timeout.
+func fail(kind failureKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	var f *failure
+	if errors.As(err, &f) {
+		return err
+	}
+	if isTimeout(err) {
+		kind = failTimeout
+	}
+	return &failure{kind, err}
+}
+
+// kindOf returns the kind of failure err is, and whether it is classified.
+func kindOf(err error) (failureKind, bool) {
+	var f *failure
+	if errors.As(err, &f) {
+		return f.kind, true
+	}
+	return 0, false
+}
+
+// deadlineExitCode is the status a benchmark exits with when a run exceeds
+// -deadline, driver.DeadlineExitCode.
+const deadlineExitCode = 124
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && ee.ExitCode() == deadlineExitCode {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// benchmarkFailures is the error sweet run returns when benchmarks fail
+// without -stop-on-error. It summarizes which benchmarks failed, by kind
+// of failure, with unclassified failures under kind 0.
+type benchmarkFailures map[failureKind][]string
+
+func (f benchmarkFailures) add(benchmark string, err error) {
+	kind, _ := kindOf(err)
+	f[kind] = append(f[kind], benchmark)
+}
+
+func (f benchmarkFailures) kinds() []failureKind {
+	kinds := make([]failureKind, 0, len(f))
+	for kind := range f {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+func (f benchmarkFailures) Error() string {
+	var b strings.Builder
+	b.WriteString("failed to execute benchmarks:")
+	for _, kind := range f.kinds() {
+		name := "other failure"
+		if kind != 0 {
+			name = kind.String()
+		}
+		fmt.Fprintf(&b, "\n\t%s: %s", name, strings.Join(f[kind], " "))
+	}
+	return b.String()
+}
+
+// ExitCode returns the exit code for the earliest kind of failure in the
+// order of failureKinds, since failures in earlier stages, like missing
+// assets, tend to be the ones to fix first. If any failure is
+// unclassified, it returns 1.
+func (f benchmarkFailures) ExitCode() int {
+	if kinds := f.kinds(); len(kinds) != 0 && kinds[0] != 0 {
+		return int(kinds[0])
+	}
+	return 1
+}
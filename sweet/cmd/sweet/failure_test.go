@@ -0,0 +1,72 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+// exitError returns the error of a command that exits with code.
+func exitError(t *testing.T, code int) error {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	if err == nil {
+		t.Fatalf("exit %d succeeded", code)
+	}
+	return err
+}
+
+func TestFail(t *testing.T) {
+	if fail(failBuild, nil) != nil {
+		t.Error("fail(failBuild, nil) != nil")
+	}
+	for _, tc := range []struct {
+		err  error
+		want failureKind
+	}{
+		{fail(failBuild, errors.New("compile error")), failBuild},
+		// Already classified failures keep their kind.
+		{fail(failRun, fmt.Errorf("wrapped: %w", fail(failAssets, errors.New("missing")))), failAssets},
+		// Timeouts are classified as such, whatever the stage.
+		{fail(failAssets, fmt.Errorf("retrieving source: %w", context.DeadlineExceeded)), failTimeout},
+		// So are benchmark runs past their deadline, but not other failed runs.
+		{fail(failRun, fmt.Errorf("run benchmark: %w", exitError(t, deadlineExitCode))), failTimeout},
+		{fail(failRun, fmt.Errorf("run benchmark: %w", exitError(t, 1))), failRun},
+	} {
+		kind, ok := kindOf(tc.err)
+		if !ok || kind != tc.want {
+			t.Errorf("kindOf(%q) = %v, %v; want %v, true", tc.err, kind, ok, tc.want)
+		}
+	}
+	if _, ok := kindOf(errors.New("other")); ok {
+		t.Error("unclassified error has a kind")
+	}
+}
+
+func TestBenchmarkFailures(t *testing.T) {
+	f := make(benchmarkFailures)
+	f.add("etcd", fail(failRun, errors.New("exit status 1")))
+	f.add("esbuild", fail(failBuild, errors.New("exit status 2")))
+	f.add("tile38", fail(failRun, errors.New("exit status 1")))
+	if got, want := f.ExitCode(), int(failBuild); got != want {
+		t.Errorf("exit code %d, want %d", got, want)
+	}
+	want := "failed to execute benchmarks:\n\tbuild failure: esbuild\n\trun failure: etcd tile38"
+	if got := f.Error(); got != want {
+		t.Errorf("got error:\n%s\nwant:\n%s", got, want)
+	}
+
+	f.add("caddy", errors.New("disk full"))
+	if got := f.ExitCode(); got != 1 {
+		t.Errorf("exit code %d with an unclassified failure, want 1", got)
+	}
+}
@@ -28,6 +28,7 @@ type genCfg struct {
 	assetsDir       string
 	sourceAssetsDir string
 	outputDir       string
+	short           bool
 }
 
 type genCmd struct {
@@ -85,6 +86,7 @@ func (c *genCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.genCfg.assetsDir, "assets-dir", "./assets", "the directory containing existing assets for sweet benchmarks")
 	f.StringVar(&c.genCfg.sourceAssetsDir, "source-assets-dir", "./source-assets", "the directory containing source assets for some of the generators")
 	f.StringVar(&c.genCfg.outputDir, "output-dir", "./assets", "the directory into which new assets should be generated")
+	f.BoolVar(&c.genCfg.short, "short", false, "generate the subsets of the assets that short runs use (requires a separate -output-dir)")
 
 	f.BoolVar(&c.quiet, "quiet", false, "whether to suppress activity output on stderr (no effect on -shell)")
 	f.Var(&c.toGen, "gen", "benchmark group or comma-separated list of benchmarks to gen (default: all)")
@@ -113,6 +115,10 @@ func (c *genCmd) Run(args []string) error {
 		return fmt.Errorf("creating absolute path from output path: %w", err)
 	}
 
+	if c.short && c.outputDir == c.assetsDir {
+		return fmt.Errorf("-short needs an -output-dir other than the assets directory, which it would overwrite")
+	}
+
 	// Make sure the assets directory is there.
 	if info, err := os.Stat(c.assetsDir); os.IsNotExist(err) {
 		return fmt.Errorf("assets not found at %q: forgot to run `sweet get (-copy)`?", c.assetsDir)
@@ -170,6 +176,7 @@ func (c *genCmd) Run(args []string) error {
 			SourceAssetsDir: filepath.Join(c.sourceAssetsDir, b.name),
 			OutputDir:       outputDir,
 			GoTool:          &gt,
+			Short:           c.short,
 		}
 		if err := b.generator.Generate(&cfg); err != nil {
 			return err
@@ -6,6 +6,7 @@ package main
 
 import (
 	"archive/zip"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -45,6 +46,7 @@ type getCmd struct {
 	copyDir        string
 	assetsHashFile string
 	version        string
+	short          bool
 }
 
 func (*getCmd) Name() string     { return "get" }
@@ -61,6 +63,7 @@ func (c *getCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.bucket, "bucket", "go-sweet-assets", "GCS bucket to download assets from")
 	f.StringVar(&c.copyDir, "copy", "", "location to extract assets into, useful for development")
 	f.StringVar(&c.assetsHashFile, "assets-hash-file", "./assets.hash", "file to check SHA256 hash of the downloaded artifact against")
+	f.BoolVar(&c.short, "short", false, "get the much smaller assets for short runs (sweet run -short) instead")
 }
 
 func (c *getCmd) Run(_ []string) error {
@@ -68,48 +71,60 @@ func (c *getCmd) Run(_ []string) error {
 	if err := bootstrap.ValidateVersion(c.version); err != nil {
 		return err
 	}
+	if c.short {
+		c.version = bootstrap.ShortVersion(c.version)
+	}
 	if c.copyDir == "" && c.cache == "" {
 		log.Printf("No cache to populate and assets are not copied. Nothing to do.")
 		return nil
 	}
 
-	// Create a file that we'll download assets into.
+	// Figure out where the archive should be, and whether we need to
+	// download it.
 	var (
-		f     *os.File
-		fName string
-		err   error
+		fName    string
+		download bool
+		err      error
 	)
 	if c.cache == "" {
 		// There's no cache, which means we'll be extracting directly.
-		// Just create a temporary file. zip archives cannot be streamed
-		// out unfortunately (the API requires a ReaderAt).
-		f, err = os.CreateTemp("", "go-sweet-assets")
-		if err != nil {
+		// zip archives cannot be streamed out unfortunately (the API
+		// requires a ReaderAt), so download into a temporary directory
+		// first. It's always the same one, so that re-running the
+		// command resumes a partial download, and only the complete
+		// archive is deleted once it's extracted.
+		tmpDir := filepath.Join(os.TempDir(), fmt.Sprintf("go-sweet-assets-%d", os.Getuid()))
+		if err := os.MkdirAll(tmpDir, 0700); err != nil {
 			return err
 		}
-		defer f.Close()
-		fName = f.Name()
+		fName = filepath.Join(tmpDir, bootstrap.VersionArchiveName(c.version))
+		defer os.Remove(fName)
+		if c.force {
+			if err := os.Remove(fName + bootstrap.PartialSuffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		download = true
 	} else {
-		// There is a cache, so create a file in the cache if there isn't
-		// one already.
+		// There is a cache, so download into the cache if the archive
+		// isn't there already.
 		log.Printf("Checking cache: %s", c.cache)
 		fName, err = bootstrap.CachedAssets(c.cache, c.version)
-		if err == bootstrap.ErrNotInCache || (err == nil && c.force) {
-			f, err = os.Create(fName)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
+		if err == bootstrap.ErrNotInCache {
+			download = true
 		} else if err != nil {
 			return err
+		} else if c.force {
+			// Don't resume a partial download either.
+			if err := os.Remove(fName + bootstrap.PartialSuffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			download = true
 		}
 	}
 
-	// If f is not nil, then we need to download assets.
-	// Otherwise they're in a cache.
-	if f != nil {
-		// Download the compressed assets into f.
-		if err := downloadAssets(f, c.bucket, c.assetsHashFile, c.version, c.auth); err != nil {
+	if download {
+		if err := downloadAssets(fName, c.bucket, c.assetsHashFile, c.version, c.auth); err != nil {
 			return err
 		}
 	}
@@ -117,14 +132,11 @@ func (c *getCmd) Run(_ []string) error {
 	if c.copyDir == "" {
 		return nil
 	}
-	if f == nil {
-		// Since f is nil, and we'll be extracting, we need to open the file.
-		f, err := os.Open(fName)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+	f, err := os.Open(fName)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
 	// Check to make sure out destination is clear.
 	if _, err := os.Stat(c.copyDir); err == nil {
@@ -138,42 +150,44 @@ func (c *getCmd) Run(_ []string) error {
 	return extractAssets(f, c.copyDir)
 }
 
-func downloadAssets(toFile *os.File, bucket, hashfile, version string, auth bootstrap.AuthOption) error {
-	log.Printf("Downloading assets archive for version %s to %s", version, toFile.Name())
-
-	// Create storage reader for streaming.
-	rc, err := bootstrap.NewStorageReader(bucket, version, auth)
+func downloadAssets(toFile, bucket, hashfile, version string, auth bootstrap.AuthOption) error {
+	// Look up the expected hash first, so as not to download the
+	// archive only to find we can't check it.
+	want, err := assetsHash(hashfile, version)
 	if err != nil {
 		return err
 	}
-	defer rc.Close()
-
-	// Pass everything we read through a hash.
-	hash := bootstrap.Hash()
-	r := io.TeeReader(rc, hash)
 
-	// Stream the results.
-	if _, err := io.Copy(toFile, r); err != nil {
+	log.Printf("Downloading assets archive for version %s to %s", version, toFile)
+	src, err := bootstrap.NewStorageSource(bucket, version, auth)
+	if err != nil {
 		return err
 	}
-
-	// Check the hash.
-	return checkAssetsHash(bootstrap.CanonicalizeHash(hash), hashfile, version)
+	err = bootstrap.Download(toFile, src, want)
+	if errors.Is(err, bootstrap.ErrHashMismatch) {
+		return fmt.Errorf("downloaded assets archive for version %s: %w; "+
+			"the corrupt download was deleted, so re-running this command will fetch it again, "+
+			"and if the mismatch persists, check that %s is up to date with the archive in gs://%s",
+			version, err, hashfile, bucket)
+	}
+	return err
 }
 
-func checkAssetsHash(hash, hashfile, version string) error {
+// assetsHash returns the expected hash of the assets archive for version,
+// from hashfile.
+func assetsHash(hashfile, version string) (string, error) {
+	if _, err := os.Stat(hashfile); os.IsNotExist(err) {
+		return "", fmt.Errorf("assets hash file %s not found; run from the sweet directory or point -assets-hash-file at sweet's assets.hash", hashfile)
+	}
 	vals, err := bootstrap.ReadHashesFile(hashfile)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("reading %s: %w", hashfile, err)
 	}
-	check, ok := vals.Get(version)
+	hash, ok := vals.Get(version)
 	if !ok {
-		return fmt.Errorf("hash for version %s not found", version)
-	}
-	if hash != check {
-		return fmt.Errorf("downloaded artifact has unexpected hash: expected %s, got %s", hash, check)
+		return "", fmt.Errorf("hash for version %s not found in %s; check -version, or update %s to a version of sweet that has assets for it", version, hashfile, hashfile)
 	}
-	return nil
+	return hash, nil
 }
 
 func extractAssets(archive *os.File, outdir string) error {
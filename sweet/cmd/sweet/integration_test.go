@@ -207,13 +207,29 @@ func TestSweetEndToEnd(t *testing.T) {
 	for i, shard := range []shard{
 		{"tile38", 2},
 		{"go-build", 4},
+		{"go-build-loaded", 4},
+		{"go-link", 4},
 		{"cockroachdb", 1},
+		{"cockroachdb-secure", 1},
 		{"etcd", 1},
 		{"esbuild", 1},
 		{"bleve-index", 1},
+		{"caddy", 1},
 		{"gopher-lua", 1},
+		{"grpc", 1},
+		{"hugo", 1},
 		{"markdown", 1},
+		{"stringer", 1},
 		{"gvisor", 1},
+		{"tsdb", 1},
+		{"fasthttp", 1},
+		{"raft", 1},
+		{"fswalk", 1},
+		{"cache", 1},
+		{"generics", 1},
+		{"vector", 1},
+		{"websocket", 1},
+		{"encoding", 1},
 	} {
 		sema.Acquire(context.Background(), shard.weight)
 		wg.Add(1)
@@ -0,0 +1,119 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// workArchiveName is the name of the archive of a failed benchmark's work
+// directory, placed in the benchmark's results directory by -keep-failed.
+const workArchiveName = "work.tar.gz"
+
+// reclaimWorkDir removes the work directory for b. If the benchmark failed,
+// the work directory is first archived into b's results directory so that
+// the failure can be debugged later.
+func (r *runCfg) reclaimWorkDir(b *benchmark, failed bool) {
+	topDir := filepath.Join(r.workDir, b.name)
+	if failed {
+		archive := filepath.Join(r.benchmarkResultsDir(b), workArchiveName)
+		log.CommandPrintf("tar -czf %s -C %s .", archive, topDir)
+		if err := archiveDir(archive, topDir); err != nil {
+			// Don't delete the only copy of the evidence.
+			log.Printf("warning: failed to archive work directory for %s, keeping %s: %v", b.name, topDir, err)
+			return
+		}
+		log.Printf("Archived work directory for failed benchmark %s to %s", b.name, archive)
+	}
+	log.CommandPrintf("rm -rf %s", topDir)
+	if err := os.RemoveAll(topDir); err != nil {
+		log.Printf("warning: failed to remove work directory for %s: %v", b.name, err)
+	}
+}
+
+// archiveDir writes a gzip-compressed tar archive of the contents of dir
+// to the file dst.
+func archiveDir(dst, dir string) (err error) {
+	if err := mkdirAll(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	zw := gzip.NewWriter(f)
+	defer func() {
+		if cerr := zw.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("closing gzip stream: %w", cerr)
+		}
+	}()
+	tw := tar.NewWriter(zw)
+	defer func() {
+		if cerr := tw.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("closing tar archive: %w", cerr)
+		}
+	}()
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			// By the guarantees of filepath.WalkDir, this shouldn't happen.
+			panic(err)
+		}
+		if rel == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			// Sockets, pipes and devices left behind by a benchmark
+			// can't be archived usefully.
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+}
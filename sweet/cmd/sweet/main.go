@@ -15,5 +15,6 @@ func main() {
 	subcommands.Register(&putCmd{})
 	subcommands.Register(&runCmd{})
 	subcommands.Register(&genCmd{})
+	subcommands.Register(&debugCmd{})
 	os.Exit(subcommands.Run())
 }
@@ -0,0 +1,164 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/common"
+)
+
+// resultsLayoutVersion is the version of the layout of the results
+// directory produced by sweet run. It must be incremented whenever
+// the layout changes in a way that could break tools that consume
+// the results directory.
+//
+// Version 1 is the layout that predates the manifest:
+//
+//	<results>/<benchmark>/<config>.results
+//	<results>/<benchmark>/<config>.build.txt
+//	<results>/<benchmark>/<config>.samples.jsonl
+//	<results>/<benchmark>/<config>.log
+//	<results>/<benchmark>/<config>.debug/...
+//	<results>/<benchmark>/core/...
+//	<results>/<benchmark>/bin/...
+//
+// The build time and binary size results in <config>.build.txt, and the
+// samples in <config>.samples.jsonl, which only some benchmarks record,
+// were added without changing the version, since they don't change the
+// files that were already there. The build results are in the same format
+// as <config>.results but named apart from it, so that globs over
+// *.results only match the results of the runs.
+const resultsLayoutVersion = 1
+
+// manifestFileName is the name of the manifest file at the root of the
+// results directory.
+const manifestFileName = "results-manifest.json"
+
+// resultsManifest describes the contents of a results directory.
+type resultsManifest struct {
+	LayoutVersion int               `json:"layoutVersion"`
+	SweetVersion  string            `json:"sweetVersion"`
+	Configs       []string          `json:"configs"`
+	Benchmarks    []string          `json:"benchmarks"`
+	AssetsVersion string            `json:"assetsVersion"`
+	AssetHashes   map[string]string `json:"assetHashes,omitempty"` // Benchmark name to SHA-256 hash of its assets.
+	ShuffleSeed   *int64            `json:"shuffleSeed,omitempty"` // Seed the order of runs was shuffled with, if it was.
+	Labels        map[string]string `json:"labels,omitempty"`      // Labels given with -label.
+	Start         time.Time         `json:"start"`
+	End           *time.Time        `json:"end,omitempty"` // Set once the run has finished.
+	Files         []manifestFile    `json:"files,omitempty"`
+}
+
+// manifestFile is an entry in the results inventory. Path is relative to
+// the results directory and always uses forward slashes.
+type manifestFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// readManifest reads the manifest from the results directory dir.
+// It returns an error satisfying errors.Is(err, fs.ErrNotExist) if
+// there is no manifest.
+func readManifest(dir string) (*resultsManifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var m resultsManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", manifestFileName, err)
+	}
+	return &m, nil
+}
+
+// write writes m to the results directory dir, replacing any existing
+// manifest atomically.
+func (m *resultsManifest) write(dir string) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, manifestFileName+".tmp")
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, manifestFileName))
+}
+
+// takeInventory populates m.Files with every regular file in the
+// results directory dir, other than the manifest itself and the
+// progress heartbeat file.
+func (m *resultsManifest) takeInventory(dir string) error {
+	m.Files = m.Files[:0]
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == manifestFileName || rel == manifestFileName+".tmp" ||
+			rel == progressFileName || rel == progressFileName+".tmp" {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, manifestFile{Path: filepath.ToSlash(rel), Size: fi.Size()})
+		return nil
+	})
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	return err
+}
+
+// checkResultsLayout returns an error if the results directory dir
+// contains results laid out in a way that's incompatible with this
+// version of Sweet. An empty or missing directory is always compatible.
+func checkResultsLayout(dir string) error {
+	m, err := readManifest(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		des, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && len(des) == 0) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return fmt.Errorf("results directory %s is not empty and has no %s; it was likely produced by an older version of sweet, so move it out of the way or choose a different directory with -results", dir, manifestFileName)
+	} else if err != nil {
+		return err
+	}
+	if m.LayoutVersion != resultsLayoutVersion {
+		return fmt.Errorf("results directory %s has layout version %d, but this version of sweet produces layout version %d; move it out of the way or choose a different directory with -results", dir, m.LayoutVersion, resultsLayoutVersion)
+	}
+	return nil
+}
+
+func newResultsManifest(configs []*common.Config, benchmarks []*benchmark) *resultsManifest {
+	m := &resultsManifest{
+		LayoutVersion: resultsLayoutVersion,
+		SweetVersion:  common.Version,
+		Benchmarks:    benchmarkNames(benchmarks),
+		Start:         time.Now().UTC(),
+	}
+	for _, c := range configs {
+		m.Configs = append(m.Configs, c.Name)
+	}
+	return m
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckResultsLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing and empty directories are always fine.
+	if err := checkResultsLayout(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing results directory: unexpected error: %v", err)
+	}
+	if err := checkResultsLayout(dir); err != nil {
+		t.Errorf("empty results directory: unexpected error: %v", err)
+	}
+
+	// Results with no manifest are rejected.
+	if err := os.MkdirAll(filepath.Join(dir, "tile38"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tile38", "go.results"), []byte("BenchmarkTile38 1 1 ns/op\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkResultsLayout(dir); err == nil {
+		t.Errorf("results directory without manifest: expected error")
+	}
+
+	// A manifest with the current layout is accepted, and the inventory
+	// picks up everything but the manifest.
+	m := newResultsManifest(nil, nil)
+	if err := m.takeInventory(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.write(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkResultsLayout(dir); err != nil {
+		t.Errorf("results directory with current manifest: unexpected error: %v", err)
+	}
+	got, err := readManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 1 || got.Files[0].Path != "tile38/go.results" {
+		t.Errorf("unexpected inventory: %+v", got.Files)
+	}
+
+	// A manifest with any other layout is rejected.
+	m.LayoutVersion = resultsLayoutVersion + 1
+	if err := m.write(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkResultsLayout(dir); err == nil {
+		t.Errorf("results directory with future layout: expected error")
+	}
+}
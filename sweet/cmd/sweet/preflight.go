@@ -0,0 +1,79 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// canarySource is the program preflight builds and runs with each
+// config's toolchain. It reports the version of Go it was built with.
+const canarySource = `package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func main() {
+	fmt.Println(runtime.Version())
+}
+`
+
+// preflight checks that the toolchain of each config works, by building
+// a tiny program with its GoRoot and BuildEnv and running it with its
+// ExecEnv, so that a bad GOROOT or environment fails the run in seconds,
+// rather than an hour in, partway through building a benchmark.
+func preflight(configs []*common.Config) error {
+	dir, err := os.MkdirTemp("", "sweet-preflight")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module canary\n"), 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(canarySource), 0o644); err != nil {
+		return err
+	}
+	for i, cfg := range configs {
+		version, err := canary(cfg, dir, filepath.Join(dir, fmt.Sprintf("canary%d", i)))
+		if err != nil {
+			return fmt.Errorf("config %s: toolchain in %s doesn't work: %w", cfg.Name, cfg.GoRoot, err)
+		}
+		log.Printf("Config %s: %s", cfg.Name, version)
+	}
+	return nil
+}
+
+// canary builds the canary program in dir to bin using cfg's toolchain,
+// runs it, and returns the Go version it reports.
+func canary(cfg *common.Config, dir, bin string) (string, error) {
+	goTool := cfg.GoTool()
+	if _, err := os.Stat(goTool.Tool); err != nil {
+		return "", fmt.Errorf("no go command: %w", err)
+	}
+	// The canary is a module of its own, whatever workspace the
+	// environment names.
+	goTool.Env = goTool.Env.MustSet("GOWORK=off")
+	if err := goTool.Do(dir, "build", "-o", bin, "."); err != nil {
+		return "", fmt.Errorf("building a test program: %w", err)
+	}
+	cmd := exec.Command(bin)
+	cmd.Env = cfg.ExecEnv.Collapse()
+	log.TraceCommand(cmd, false)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("running a test program: %v, output:\n%s", err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
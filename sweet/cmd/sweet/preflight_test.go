@@ -0,0 +1,51 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/benchmarks/sweet/common"
+)
+
+func TestPreflight(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program with the go command")
+	}
+	goTool, err := common.SystemGoTool()
+	if err != nil {
+		t.Skip(err)
+	}
+	config := func(name, goroot string, buildEnv, execEnv []string) *common.Config {
+		cfg := &common.Config{Name: name, GoRoot: goroot}
+		cfg.BuildEnv.Env = common.NewEnvFromEnviron().MustSet(buildEnv...)
+		cfg.ExecEnv.Env = common.NewEnvFromEnviron().MustSet(execEnv...)
+		return cfg
+	}
+	good := config("good", goTool.GOROOT(), nil, nil)
+	if err := preflight([]*common.Config{good, good}); err != nil {
+		t.Fatalf("preflight of a working toolchain failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		cfg  *common.Config
+		want string
+	}{
+		{config("missing", filepath.Join(t.TempDir(), "go"), nil, nil), "no go command"},
+		{config("badarch", goTool.GOROOT(), []string{"GOARCH=bogus"}, nil), "building"},
+		{config("badlimit", goTool.GOROOT(), nil, []string{"GOMEMLIMIT=bogus"}), "running"},
+	} {
+		err := preflight([]*common.Config{good, tc.cfg})
+		if err == nil {
+			t.Errorf("preflight of config %s succeeded", tc.cfg.Name)
+			continue
+		}
+		if msg := err.Error(); !strings.Contains(msg, "config "+tc.cfg.Name+":") || !strings.Contains(msg, tc.want) {
+			t.Errorf("preflight of config %s failed with %q, want it to name the config and say %q", tc.cfg.Name, msg, tc.want)
+		}
+	}
+}
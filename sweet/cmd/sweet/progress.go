@@ -0,0 +1,249 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// progressFileName is the default name of the heartbeat file in the
+// results directory.
+const progressFileName = "progress.json"
+
+// heartbeatInterval is how often the heartbeat file is rewritten even if
+// nothing has changed, so that a stale Updated time means sweet is stuck
+// or gone.
+const heartbeatInterval = 10 * time.Second
+
+// runProgress is a snapshot of the progress of a sweet run, in the form
+// written to the heartbeat file and served over HTTP.
+type runProgress struct {
+	State     string     `json:"state"`           // "running", "done" or "failed".
+	Phase     string     `json:"phase,omitempty"` // "setup" or "run", while running.
+	Benchmark string     `json:"benchmark,omitempty"`
+	Config    string     `json:"config,omitempty"`
+	Run       int        `json:"run,omitempty"` // 1-based index of the current run of Benchmark for Config.
+	Completed int        `json:"completedRuns"`
+	Total     int        `json:"totalRuns"`
+	Failed    []string   `json:"failedBenchmarks,omitempty"`
+	Start     time.Time  `json:"start"`
+	Updated   time.Time  `json:"updated"`
+	ETA       *time.Time `json:"eta,omitempty"` // Estimated from the average time per completed run.
+	Error     string     `json:"error,omitempty"`
+}
+
+// progressReporter keeps track of a run's progress and publishes it to a
+// heartbeat file and an HTTP status endpoint, each of which is optional.
+// All methods may be called on a nil *progressReporter, and do nothing.
+type progressReporter struct {
+	file    string
+	writeMu sync.Mutex // Serializes writes to file.
+	srv     *http.Server
+
+	mu        sync.Mutex
+	p         runProgress
+	remaining map[string]int // Runs still to come for each benchmark started.
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newProgressReporter starts reporting progress to file, if not empty, and
+// serving it as JSON on addr, if not empty.
+func newProgressReporter(file, addr string) (*progressReporter, error) {
+	now := time.Now().UTC()
+	r := &progressReporter{
+		file:      file,
+		p:         runProgress{State: "running", Start: now, Updated: now},
+		remaining: make(map[string]int),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		r.srv = &http.Server{Handler: r}
+		go r.srv.Serve(ln)
+		log.Printf("Serving run progress at http://%s/", ln.Addr())
+	}
+	r.update(nil)
+	go r.heartbeat()
+	return r, nil
+}
+
+func (r *progressReporter) heartbeat() {
+	defer close(r.done)
+	t := time.NewTicker(heartbeatInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+			r.update(nil)
+		}
+	}
+}
+
+// ServeHTTP serves the current progress as JSON.
+func (r *progressReporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	b, err := json.MarshalIndent(r.snapshot(), "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(b, '\n'))
+}
+
+// snapshot returns a copy of the current progress.
+func (r *progressReporter) snapshot() runProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.p
+	p.Failed = append([]string(nil), p.Failed...)
+	return p
+}
+
+// update applies f, if not nil, to the progress and then publishes it.
+func (r *progressReporter) update(f func(p *runProgress)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	if f != nil {
+		f(&r.p)
+	}
+	r.p.Updated = time.Now().UTC()
+	r.p.ETA = nil
+	if r.p.State == "running" && r.p.Completed > 0 && r.p.Total > r.p.Completed {
+		elapsed := r.p.Updated.Sub(r.p.Start)
+		eta := r.p.Updated.Add(elapsed / time.Duration(r.p.Completed) * time.Duration(r.p.Total-r.p.Completed))
+		r.p.ETA = &eta
+	}
+	r.mu.Unlock()
+	r.writeFile()
+}
+
+// writeFile replaces the heartbeat file atomically with the current
+// progress.
+func (r *progressReporter) writeFile() {
+	if r.file == "" {
+		return
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	b, err := json.MarshalIndent(r.snapshot(), "", "\t")
+	if err == nil {
+		tmp := r.file + ".tmp"
+		if err = os.WriteFile(tmp, append(b, '\n'), 0644); err == nil {
+			err = os.Rename(tmp, r.file)
+		}
+	}
+	if err != nil {
+		log.Printf("warning: failed to write progress to %s: %v", r.file, err)
+	}
+}
+
+// plan sets the number of runs still to come.
+func (r *progressReporter) plan(runs int) {
+	r.update(func(p *runProgress) {
+		p.Total = p.Completed + runs
+	})
+}
+
+// startBenchmark notes that setup for benchmark name has begun, and that
+// it will do runs runs in total.
+func (r *progressReporter) startBenchmark(name string, runs int) {
+	if r == nil {
+		return
+	}
+	r.update(func(p *runProgress) {
+		r.remaining[name] = runs
+		p.Phase, p.Benchmark, p.Config, p.Run = "setup", name, "", 0
+	})
+}
+
+// resumeBenchmark makes name, a benchmark started earlier, the current
+// benchmark again. With -shuffle, the runs of all benchmarks are
+// interleaved after they have all been set up.
+func (r *progressReporter) resumeBenchmark(name string) {
+	r.update(func(p *runProgress) {
+		p.Phase, p.Benchmark, p.Config, p.Run = "", name, "", 0
+	})
+}
+
+// setup notes that the current benchmark is being set up for config.
+func (r *progressReporter) setup(config string) {
+	r.update(func(p *runProgress) {
+		p.Phase, p.Config, p.Run = "setup", config, 0
+	})
+}
+
+// startRun notes that run of the current benchmark for config has begun.
+func (r *progressReporter) startRun(config string, run int) {
+	r.update(func(p *runProgress) {
+		p.Phase, p.Config, p.Run = "run", config, run
+	})
+}
+
+// finishRun notes that the current run completed successfully.
+func (r *progressReporter) finishRun() {
+	if r == nil {
+		return
+	}
+	r.update(func(p *runProgress) {
+		p.Completed++
+		r.remaining[p.Benchmark]--
+	})
+}
+
+// endBenchmark notes that the current benchmark finished with err. If it
+// failed, the runs it didn't get to are dropped from the total.
+func (r *progressReporter) endBenchmark(err error) {
+	if r == nil {
+		return
+	}
+	r.update(func(p *runProgress) {
+		if err != nil {
+			p.Failed = append(p.Failed, p.Benchmark)
+			if skipped := r.remaining[p.Benchmark]; skipped > 0 {
+				p.Total -= skipped
+			}
+		}
+		delete(r.remaining, p.Benchmark)
+		p.Phase, p.Benchmark, p.Config, p.Run = "", "", "", 0
+	})
+}
+
+// finish records that the run is over, with the overall error err, and
+// stops reporting.
+func (r *progressReporter) finish(err error) {
+	if r == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.update(func(p *runProgress) {
+		p.State = "done"
+		if err != nil {
+			p.State, p.Error = "failed", err.Error()
+		}
+		p.Phase, p.Benchmark, p.Config, p.Run = "", "", "", 0
+	})
+	if r.srv != nil {
+		r.srv.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgressReporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), progressFileName)
+	r, err := newProgressReporter(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func() runProgress {
+		t.Helper()
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p runProgress
+		if err := json.Unmarshal(b, &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// Two benchmarks of two runs each; the second fails after one run.
+	r.plan(4)
+	r.startBenchmark("tile38", 2)
+	r.setup("go")
+	r.startRun("go", 1)
+	p := read()
+	if p.State != "running" || p.Phase != "run" || p.Benchmark != "tile38" || p.Config != "go" || p.Run != 1 || p.Total != 4 {
+		t.Errorf("during first run: unexpected progress %+v", p)
+	}
+	r.finishRun()
+	r.startRun("go", 2)
+	r.finishRun()
+	r.endBenchmark(nil)
+	if p := read(); p.Completed != 2 || p.ETA == nil || p.Benchmark != "" {
+		t.Errorf("after first benchmark: unexpected progress %+v", p)
+	}
+
+	r.startBenchmark("etcd", 2)
+	r.startRun("go", 1)
+	r.finishRun()
+	r.startRun("go", 2)
+
+	// The HTTP endpoint serves the same thing as the file.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var served runProgress
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil {
+		t.Fatal(err)
+	}
+	if served.Benchmark != "etcd" || served.Run != 2 || served.Completed != 3 {
+		t.Errorf("served unexpected progress %+v", served)
+	}
+
+	r.endBenchmark(errors.New("boom"))
+	r.finish(errors.New("failed to execute benchmarks: etcd"))
+	p = read()
+	if p.State != "failed" || p.Completed != 3 || p.Total != 3 || len(p.Failed) != 1 || p.Failed[0] != "etcd" || p.ETA != nil || p.Error == "" {
+		t.Errorf("after failure: unexpected progress %+v", p)
+	}
+
+	// With -shuffle, runs of benchmarks set up together are interleaved,
+	// and a failure drops only the failed benchmark's remaining runs.
+	r, err = newProgressReporter(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.plan(4)
+	r.startBenchmark("tile38", 2)
+	r.startBenchmark("etcd", 2)
+	r.resumeBenchmark("etcd")
+	r.startRun("go", 1)
+	r.finishRun()
+	r.resumeBenchmark("tile38")
+	r.startRun("go", 1)
+	if p := read(); p.Benchmark != "tile38" || p.Completed != 1 {
+		t.Errorf("during interleaved run: unexpected progress %+v", p)
+	}
+	r.endBenchmark(errors.New("boom"))
+	r.resumeBenchmark("etcd")
+	r.startRun("go", 2)
+	r.finishRun()
+	r.endBenchmark(nil)
+	r.finish(nil)
+	if p := read(); p.Completed != 2 || p.Total != 2 || len(p.Failed) != 1 || p.Failed[0] != "tile38" {
+		t.Errorf("after interleaved runs: unexpected progress %+v", p)
+	}
+
+	// A nil reporter does nothing.
+	var nr *progressReporter
+	nr.startBenchmark("etcd", 1)
+	nr.finishRun()
+	nr.finish(nil)
+}
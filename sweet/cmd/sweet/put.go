@@ -31,6 +31,7 @@ type putCmd struct {
 	assetsDir      string
 	assetsHashFile string
 	version        string
+	short          bool
 }
 
 func (*putCmd) Name() string { return "put" }
@@ -49,6 +50,7 @@ func (c *putCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.bucket, "bucket", "go-sweet-assets", "GCS bucket to upload assets to")
 	f.StringVar(&c.assetsDir, "assets-dir", "./assets", "assets directory to zip and upload")
 	f.StringVar(&c.assetsHashFile, "assets-hash-file", "./assets.hash", "file containing assets SHA256 hashes")
+	f.BoolVar(&c.short, "short", false, "upload the assets as those for short runs, as generated by sweet gen -short")
 }
 
 func (c *putCmd) Run(_ []string) error {
@@ -57,6 +59,9 @@ func (c *putCmd) Run(_ []string) error {
 	if err := bootstrap.ValidateVersion(c.version); err != nil {
 		return err
 	}
+	if c.short {
+		c.version = bootstrap.ShortVersion(c.version)
+	}
 
 	log.Printf("Archiving, compressing, and uploading: %s", c.assetsDir)
 
@@ -0,0 +1,126 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// rotationPolicy describes which benchmarks to run on each calendar day,
+// so that a suite too expensive to run in full every day is still covered
+// over the course of a few days. It is read from a TOML file such as:
+//
+//	core = ["go-build", "biogo-igor", "gopher-lua"]
+//	rotate = ["cockroachdb", "etcd", "tile38", "gvisor", "bleve-index"]
+//	per-day = 2
+//
+// which runs go-build, biogo-igor and gopher-lua every day, and two of the
+// other five, so that each of them runs at least once every three days.
+type rotationPolicy struct {
+	// Core lists the benchmarks to run every day.
+	Core []string `toml:"core"`
+
+	// Rotate lists the benchmarks to take turns running, in order.
+	Rotate []string `toml:"rotate"`
+
+	// PerDay is the number of benchmarks from Rotate to run each day.
+	PerDay int `toml:"per-day"`
+}
+
+// readRotationPolicy reads and validates a rotationPolicy from file.
+func readRotationPolicy(file string) (*rotationPolicy, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rotation policy: %v", err)
+	}
+	var p rotationPolicy
+	md, err := toml.Decode(string(b), &p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	if len(md.Undecoded()) != 0 {
+		return nil, fmt.Errorf("unexpected keys in %q: %+v", file, md.Undecoded())
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rotation policy %q: %v", file, err)
+	}
+	return &p, nil
+}
+
+func (p *rotationPolicy) validate() error {
+	seen := make(map[string]bool)
+	var unknown, repeated []string
+	for _, name := range append(append([]string{}, p.Core...), p.Rotate...) {
+		if _, ok := allBenchmarksMap[name]; !ok {
+			unknown = append(unknown, name)
+		} else if seen[name] {
+			repeated = append(repeated, name)
+		}
+		seen[name] = true
+	}
+	if len(unknown) != 0 {
+		return fmt.Errorf("unknown benchmarks: %s", strings.Join(unknown, ", "))
+	}
+	if len(repeated) != 0 {
+		return fmt.Errorf("benchmarks listed more than once: %s", strings.Join(repeated, ", "))
+	}
+	if len(p.Core) == 0 && len(p.Rotate) == 0 {
+		return fmt.Errorf("no benchmarks")
+	}
+	if len(p.Rotate) != 0 && p.PerDay <= 0 {
+		return fmt.Errorf("per-day must be positive")
+	}
+	return nil
+}
+
+// rotationDay returns the number of the calendar day of t, counting from
+// 1970-01-01, in t's location.
+func rotationDay(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60))
+}
+
+// benchmarks returns the benchmarks to run on the given day: the core
+// benchmarks, then the next PerDay benchmarks of Rotate, continuing from
+// where the previous day left off and wrapping around at the end.
+func (p *rotationPolicy) benchmarks(day int) []*benchmark {
+	var bs []*benchmark
+	for _, name := range p.Core {
+		bs = append(bs, allBenchmarksMap[name])
+	}
+	n := len(p.Rotate)
+	for i := 0; i < min(p.PerDay, n); i++ {
+		// Keep the index non-negative even for days before 1970.
+		j := ((day*p.PerDay+i)%n + n) % n
+		bs = append(bs, allBenchmarksMap[p.Rotate[j]])
+	}
+	return bs
+}
+
+// rotatedBenchmarks returns the benchmarks to run according to the
+// -rotation policy on the -rotation-date.
+func (c *runCmd) rotatedBenchmarks() ([]*benchmark, error) {
+	p, err := readRotationPolicy(c.rotation)
+	if err != nil {
+		return nil, err
+	}
+	date := time.Now()
+	if c.rotationDate != "" {
+		date, err = time.Parse(time.DateOnly, c.rotationDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -rotation-date: %v", err)
+		}
+	}
+	bs := p.benchmarks(rotationDay(date))
+	log.Printf("Rotation for %s: %s", date.Format(time.DateOnly), strings.Join(benchmarkNames(bs[len(p.Core):]), " "))
+	return bs, nil
+}
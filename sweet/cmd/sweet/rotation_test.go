@@ -0,0 +1,69 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotationPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rotation.toml")
+	policy := `
+core = ["go-build"]
+rotate = ["cockroachdb", "etcd", "tile38", "gvisor", "bleve-index"]
+per-day = 2
+`
+	if err := os.WriteFile(file, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := readRotationPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Over any five consecutive days, every rotated benchmark runs twice.
+	start := rotationDay(time.Date(2024, 3, 30, 23, 0, 0, 0, time.Local))
+	counts := make(map[string]int)
+	for day := start; day < start+5; day++ {
+		bs := p.benchmarks(day)
+		if len(bs) != 3 || bs[0].name != "go-build" {
+			t.Fatalf("day %d: got benchmarks %v, want go-build and two others", day, benchmarkNames(bs))
+		}
+		for _, b := range bs[1:] {
+			counts[b.name]++
+		}
+		if got := benchmarkNames(p.benchmarks(day)); strings.Join(got, " ") != strings.Join(benchmarkNames(bs), " ") {
+			t.Errorf("day %d: selection is not deterministic: %v, then %v", day, benchmarkNames(bs), got)
+		}
+	}
+	for _, name := range p.Rotate {
+		if counts[name] != 2 {
+			t.Errorf("%s ran %d times in five days, want 2", name, counts[name])
+		}
+	}
+
+	if a, b := rotationDay(time.Date(2024, 3, 31, 0, 30, 0, 0, time.Local)), rotationDay(time.Date(2024, 3, 31, 23, 30, 0, 0, time.Local)); a != b {
+		t.Errorf("times on the same day have different rotation days %d and %d", a, b)
+	}
+
+	for _, bad := range []string{
+		`core = ["no-such-benchmark"]`,
+		`core = ["etcd"]` + "\n" + `rotate = ["etcd"]` + "\n" + `per-day = 1`,
+		`rotate = ["etcd", "tile38"]`,
+		`core = ["etcd"]` + "\n" + `days = 7`,
+		``,
+	} {
+		if err := os.WriteFile(file, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readRotationPolicy(file); err == nil {
+			t.Errorf("readRotationPolicy accepted %q", bad)
+		}
+	}
+}
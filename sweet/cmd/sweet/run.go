@@ -14,14 +14,19 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"golang.org/x/benchmarks/sweet/cli/bootstrap"
 	"golang.org/x/benchmarks/sweet/common"
 	"golang.org/x/benchmarks/sweet/common/diagnostics"
 	"golang.org/x/benchmarks/sweet/common/log"
+	"golang.org/x/benchmarks/sweet/common/priority"
 	sprofile "golang.org/x/benchmarks/sweet/common/profile"
 
 	"github.com/BurntSushi/toml"
@@ -39,6 +44,143 @@ func (c *csvFlag) Set(input string) error {
 	return nil
 }
 
+// labelsFlag collects the key=value pairs of repeated -label flags, in
+// order.
+type labelsFlag [][2]string
+
+func (l *labelsFlag) String() string {
+	var s []string
+	for _, kv := range *l {
+		s = append(s, kv[0]+"="+kv[1])
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *labelsFlag) Set(input string) error {
+	key, value, ok := strings.Cut(input, "=")
+	if !ok {
+		return fmt.Errorf("label %q is not of the form key=value", input)
+	}
+	// These are the rules for configuration keys and values in the Go
+	// benchmark format.
+	first, _ := utf8.DecodeRuneInString(key)
+	if !unicode.IsLower(first) || strings.IndexFunc(key, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsUpper(r) }) >= 0 {
+		return fmt.Errorf("label key %q must begin with a lower-case letter and contain no upper-case letters or spaces", key)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("label %s's value must be a single line", key)
+	}
+	for _, kv := range *l {
+		if kv[0] == key {
+			return fmt.Errorf("label %s given more than once", key)
+		}
+	}
+	*l = append(*l, [2]string{key, value})
+	return nil
+}
+
+// perBenchmarkFlag holds a comma-separated list of values, each of which
+// applies either to every benchmark or, written as benchmark=value, to one.
+type perBenchmarkFlag struct {
+	all   string
+	bench map[string]string
+	parse func(string) (string, error) // Checks a value and returns it in canonical form.
+}
+
+func (p *perBenchmarkFlag) String() string {
+	var s []string
+	for b, v := range p.bench {
+		s = append(s, b+"="+v)
+	}
+	sort.Strings(s)
+	if p.all != "" {
+		s = append([]string{p.all}, s...)
+	}
+	return strings.Join(s, ",")
+}
+
+func (p *perBenchmarkFlag) Set(input string) error {
+	for _, s := range strings.Split(input, ",") {
+		b, v, ok := strings.Cut(s, "=")
+		if !ok {
+			b, v = "", s
+		} else if _, known := allBenchmarksMap[b]; !known {
+			return fmt.Errorf("unknown benchmark %q", b)
+		}
+		v, err := p.parse(v)
+		if err != nil {
+			return err
+		}
+		if b == "" {
+			p.all = v
+			continue
+		}
+		if p.bench == nil {
+			p.bench = make(map[string]string)
+		}
+		p.bench[b] = v
+	}
+	return nil
+}
+
+// get returns the value for the benchmark named b, or "" if there is none.
+func (p *perBenchmarkFlag) get(b string) string {
+	if v, ok := p.bench[b]; ok {
+		return v
+	}
+	return p.all
+}
+
+// lowestNice returns the lowest niceness given with -nice, if there is one
+// for all benchmarks. Sweet runs at that, rather than at the one for all
+// benchmarks, so that each benchmark only ever raises its own niceness,
+// which needs no privileges.
+func (r *runCfg) lowestNice() (int, bool) {
+	if r.nice.all == "" {
+		return 0, false
+	}
+	lowest, _ := strconv.Atoi(r.nice.all)
+	for _, n := range r.nice.bench {
+		v, _ := strconv.Atoi(n)
+		lowest = min(lowest, v)
+	}
+	return lowest, true
+}
+
+// priorityConfigLines returns the benchmark configuration lines that
+// record the priorities b's processes ran at, if they were set.
+func (r *runCfg) priorityConfigLines(b *benchmark) string {
+	var s string
+	if n := r.nice.get(b.name); n != "" {
+		s += fmt.Sprintf("nice: %s\n", n)
+	}
+	if p := r.ionice.get(b.name); p != "" {
+		s += fmt.Sprintf("ionice: %s\n", p)
+	}
+	return s
+}
+
+// configLines returns the labels as benchmark configuration lines.
+func (l labelsFlag) configLines() string {
+	var s strings.Builder
+	for _, kv := range l {
+		fmt.Fprintf(&s, "%s: %s\n", kv[0], kv[1])
+	}
+	return s.String()
+}
+
+// labelsMap returns the labels as a map, or nil if there are none.
+func (l labelsFlag) labelsMap() map[string]string {
+	if len(l) == 0 {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, kv := range l {
+		m[kv[0]] = kv[1]
+	}
+	return m
+}
+
 const (
 	runLongDesc = `Execute benchmarks in the suite against GOROOTs provided in TOML configuration
 files. Note: by default, this command expects to run from /path/to/x/benchmarks/sweet.`
@@ -49,26 +191,55 @@ files. Note: by default, this command expects to run from /path/to/x/benchmarks/
 const (
 	countDefault       = 10
 	pgoCountDefaultMax = 5
+
+	// smokeDeadline is the default -deadline for runs of the smoke group.
+	smokeDeadline = time.Minute
 )
 
 type runCfg struct {
-	count       int
-	resultsDir  string
-	benchDir    string
-	assetsDir   string
-	workDir     string
-	assetsCache string
-	dumpCore    bool
-	pgo         bool
-	pgoCount    int
-	short       bool
-
-	assetsFS fs.FS
+	count          int
+	resultsDir     string
+	benchDir       string
+	assetsDir      string
+	workDir        string
+	assetsCache    string
+	dumpCore       bool
+	pgo            bool
+	pgoCount       int
+	short          bool
+	diskCheck      bool
+	keepFailed     bool
+	sourceCache    string
+	clientHost     string
+	serverHost     string
+	gomaxprocs     string
+	cpuFreq        bool
+	schedStats     bool
+	netStats       bool
+	strict         bool
+	cpuLimit       int
+	deadline       time.Duration
+	metrics        string
+	keyDist        string
+	runscPlatforms string
+	serverProcs    int
+	clientProcs    int
+	labels         labelsFlag
+	nice           perBenchmarkFlag
+	ionice         perBenchmarkFlag
+
+	assetsFS    fs.FS
+	shortAssets bool // Whether assetsFS holds the assets for short runs.
+	progress    *progressReporter
+
+	// assetHashes maps the name of each benchmark run so far that has
+	// assets to the hash of those assets (see hashAssets).
+	assetHashes map[string]string
 }
 
 func (r *runCfg) logCopyDirCommand(fromRelDir, toDir string) {
 	if r.assetsDir == "" {
-		assetsFile, _ := bootstrap.CachedAssets(r.assetsCache, common.Version)
+		assetsFile, _ := bootstrap.CachedAssets(r.assetsCache, r.assetsVersion())
 		log.CommandPrintf("unzip %s '%s/*' -d %s", assetsFile, fromRelDir, toDir)
 	} else {
 		log.CommandPrintf("cp -r %s/* %s", filepath.Join(r.assetsDir, fromRelDir), toDir)
@@ -88,7 +259,20 @@ type runCmd struct {
 	quiet       bool
 	printCmd    bool
 	stopOnError bool
+	preflight   bool
 	toRun       csvFlag
+	shuffle     string
+
+	rotation     string
+	rotationDate string
+
+	cacheSources bool
+	progressFile string
+	statusAddr   string
+
+	baselineResults      string
+	regressionThresholds thresholdsFlag
+	regressionAlpha      float64
 }
 
 func (*runCmd) Name() string     { return "run" }
@@ -155,18 +339,67 @@ func (c *runCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.printCmd, "shell", false, "whether to print the commands being executed to stdout")
 	f.BoolVar(&c.stopOnError, "stop-on-error", false, "whether to stop running benchmarks if an error occurs or a benchmark fails")
 	f.BoolVar(&c.short, "short", false, "whether to run a short version of the benchmarks for testing (changes -count to 1)")
+	f.BoolVar(&c.runCfg.diskCheck, "disk-check", true, "whether to check that the work directory has enough free disk space before setting up each benchmark")
+	f.BoolVar(&c.runCfg.keepFailed, "keep-failed", false, "whether to delete each benchmark's work directory once it completes, first archiving it into the results directory if the benchmark failed")
+	f.BoolVar(&c.preflight, "preflight", true, "whether to check that each config's toolchain can build and run a trivial program before running any benchmarks")
+	f.BoolVar(&c.cacheSources, "cache-sources", true, "whether to cache source code fetched for benchmarks in the assets cache (-cache) for reuse by later runs")
+	f.StringVar(&c.progressFile, "progress-file", "", fmt.Sprintf("file to keep updated with the progress of the run as JSON (default <results>/%s; \"off\" to disable)", progressFileName))
+	f.StringVar(&c.statusAddr, "status-addr", "", "address on which to serve the progress of the run as JSON over HTTP, e.g. localhost:8080 (default none)")
+	f.StringVar(&c.runCfg.clientHost, "client-host", "", "SSH destination (e.g. user@host) of a separate machine to run the clients of server benchmarks on (default: run them on this machine)")
+	f.StringVar(&c.runCfg.serverHost, "server-host", "", "address of this machine as seen from -client-host")
+	f.BoolVar(&c.runCfg.schedStats, "sched-stats", false, "whether to report the context switches of each benchmark's process, and the time its threads waited for a CPU, during each run (Linux only)")
+	f.BoolVar(&c.runCfg.netStats, "net-stats", false, "whether to report TCP retransmits and socket counts, UDP traffic, and the CPU time of benchmark clients on this machine, during each run (Linux only)")
+	f.BoolVar(&c.runCfg.strict, "strict", false, "whether to fail a benchmark on any anomaly in the output of the tools it parses, such as the cockroachdb and etcd workloads, rather than warn")
+	f.BoolVar(&c.runCfg.cpuFreq, "cpufreq", false, "whether to sample CPU frequencies and count thermal throttling events during each benchmark run, and report them as metrics")
+	f.IntVar(&c.runCfg.cpuLimit, "cpu-limit", 0, "number of CPUs to cap the parallelism of the build benchmarks (esbuild, go-build) at, through their cgroup's cpu.max and GOMAXPROCS, so that their results are comparable across machines; the cap appears as the -N suffix of their names (default: no cap)")
+	f.DurationVar(&c.runCfg.deadline, "deadline", 0, "wall-clock time after which a benchmark run that hasn't finished is considered hung: it dumps its goroutine stacks and partial diagnostics into the results directory and fails (default: no deadline)")
+	f.StringVar(&c.runCfg.gomaxprocs, "gomaxprocs", "", "comma-separated list of GOMAXPROCS values to run in-process benchmarks at, where N is the number of CPUs, e.g. 1,4,N (default: run them once, at the default GOMAXPROCS)")
+	f.StringVar(&c.runCfg.keyDist, "key-dist", "", "distribution of the keys that the benchmarks with key-value workloads (cache, cockroachdb) access: uniform, zipf[:skew] or hotspot[:keys[:accesses]], of which cockroachdb only supports uniform and zipf, without a skew; results for other than the default are named with a dist= key (default: each benchmark's own)")
+	f.StringVar(&c.runCfg.runscPlatforms, "runsc-platforms", "", "comma-separated list of runsc platforms to run the gvisor benchmark under, e.g. ,ptrace,kvm, where an empty element means runsc's default platform; each platform adds a full run of the benchmark, named with a platform= key (default: only runsc's default platform)")
+	f.IntVar(&c.runCfg.serverProcs, "server-procs", 0, "GOMAXPROCS of each node of the cockroachdb benchmarks (default: the CPUs not given to the clients, split evenly between the nodes)")
+	f.IntVar(&c.runCfg.clientProcs, "client-procs", 0, "GOMAXPROCS of the workload client of the cockroachdb benchmarks (default: as much as each node, or with -client-host, the client host's default)")
+	f.StringVar(&c.runCfg.metrics, "metrics", "", "comma-separated list of metrics for benchmarks to report, where * matches anything, -pattern drops metrics and old=new renames one, e.g. ns/op,*-latency-ns,p100-latency-ns=max-latency-ns (default: all)")
+	c.runCfg.nice.parse = func(s string) (string, error) {
+		n, err := priority.ParseNice(s)
+		return strconv.Itoa(n), err
+	}
+	c.runCfg.ionice.parse = func(s string) (string, error) {
+		p, err := priority.ParseIO(s)
+		return p.String(), err
+	}
+	f.Var(&c.runCfg.nice, "nice", "CPU niceness, from -20 to 19, to run the benchmarks at, and comma-separated benchmark=niceness overrides for the processes of particular benchmarks, e.g. 10,etcd=5; sweet itself runs at the lowest of them, and lowering niceness below the current one needs privileges (Linux only; default: unchanged)")
+	f.Var(&c.runCfg.ionice, "ionice", "IO priority, as realtime[:level], best-effort[:level] or idle, to run sweet and the benchmarks at, and comma-separated benchmark=priority overrides for the processes of particular benchmarks, e.g. best-effort:7,tsdb=best-effort:0 (Linux only; default: unchanged)")
+	f.Var(&c.runCfg.labels, "label", "key=value to add as a configuration line to all the results of the run, e.g. to tell apart the runs of an A/A experiment in benchstat (may be repeated)")
+	f.StringVar(&c.baselineResults, "baseline-results", "", "results directory of an earlier run to compare the results of this run against once it's done, failing if any metric regressed (default: no comparison)")
+	f.Var(&c.regressionThresholds, "regression-threshold", fmt.Sprintf("percentage by which a performance metric (per-op costs, throughputs and latency percentiles) may get worse than in -baseline-results before it counts as a regression, and comma-separated unit=percentage overrides for metrics with particular units, where \"off\" ignores them and other metrics are only compared if given, e.g. 3%%,peak-RSS-bytes=10%%,ops/s=off (default %d%%)", regressionThresholdDefault))
+	f.Float64Var(&c.regressionAlpha, "regression-alpha", regressionAlphaDefault, "significance level a change in a metric from -baseline-results must reach to count as a regression, rather than noise; -count must be large enough to reach it (at least 4 for the default)")
 	f.Var(&c.toRun, "run", "benchmark group or comma-separated list of benchmarks to run")
+	f.StringVar(&c.shuffle, "shuffle", "off", "randomize the order of the runs of every benchmark and config within each iteration, instead of running benchmarks one by one: \"on\", or an integer seed to reproduce an earlier order (the seed is logged and recorded in the results manifest)")
+	f.StringVar(&c.rotation, "rotation", "", "TOML file with a policy for running a core set of benchmarks every day and taking turns running the rest (incompatible with -run)")
+	f.StringVar(&c.rotationDate, "rotation-date", "", "the date, as YYYY-MM-DD, to choose benchmarks for with -rotation (default: today)")
 }
 
-func (c *runCmd) Run(args []string) error {
+func (c *runCmd) Run(args []string) (err error) {
 	if len(args) == 0 {
-		return fmt.Errorf("at least one configuration is required")
+		return fail(failConfig, fmt.Errorf("at least one configuration is required"))
 	}
 	checkPlatform()
 
 	log.SetCommandTrace(c.printCmd)
 	log.SetActivityLog(!c.quiet)
 
+	// The smoke group is only quick with tiny parameters, and a wedged
+	// benchmark mustn't hold up whatever is waiting on it.
+	if len(c.toRun) == 1 && c.toRun[0] == "smoke" {
+		if !c.short {
+			log.Printf("Running the smoke group implies -short")
+			c.short = true
+		}
+		if c.runCfg.deadline == 0 {
+			c.runCfg.deadline = smokeDeadline
+		}
+	}
+
 	if c.runCfg.count == 0 {
 		if c.short {
 			c.runCfg.count = 1
@@ -181,7 +414,6 @@ func (c *runCmd) Run(args []string) error {
 		}
 	}
 
-	var err error
 	if c.workDir == "" {
 		// Create a temporary work tree for running the benchmarks.
 		c.workDir, err = os.MkdirTemp("", "gosweet")
@@ -203,139 +435,68 @@ func (c *runCmd) Run(args []string) error {
 	if err != nil {
 		return fmt.Errorf("creating absolute path from results path (-results): %w", err)
 	}
-	if c.assetsDir != "" {
-		c.assetsDir, err = filepath.Abs(c.assetsDir)
-		if err != nil {
-			return fmt.Errorf("creating absolute path from assets path (-assets-dir): %w", err)
-		}
-		if info, err := os.Stat(c.assetsDir); os.IsNotExist(err) {
-			return fmt.Errorf("assets not found at %q: did you forget to run `sweet get`?", c.assetsDir)
-		} else if err != nil {
-			return fmt.Errorf("stat assets %q: %v", c.assetsDir, err)
-		} else if info.Mode()&os.ModeDir == 0 {
-			return fmt.Errorf("%q is not a directory", c.assetsDir)
-		}
-		c.assetsFS = os.DirFS(c.assetsDir)
-	} else {
-		if c.assetsCache == "" {
-			return fmt.Errorf("missing assets cache (-cache) and assets directory (-assets-dir): cannot proceed without assets")
-		}
-		c.assetsCache, err = filepath.Abs(c.assetsCache)
-		if err != nil {
-			return fmt.Errorf("creating absolute path from assets cache path (-cache): %w", err)
-		}
-		if info, err := os.Stat(c.assetsCache); os.IsNotExist(err) {
-			return fmt.Errorf("assets not found at %q (-assets-dir): did you forget to run `sweet get`?", c.assetsDir)
-		} else if err != nil {
-			return fmt.Errorf("stat assets %q: %v", c.assetsDir, err)
-		} else if info.Mode()&os.ModeDir == 0 {
-			return fmt.Errorf("%q (-assets-dir) is not a directory", c.assetsDir)
-		}
-		assetsFile, err := bootstrap.CachedAssets(c.assetsCache, common.Version)
-		if err == bootstrap.ErrNotInCache {
-			return fmt.Errorf("assets for version %q not found in %q", common.Version, c.assetsCache)
-		} else if err != nil {
-			return err
-		}
-		f, err := os.Open(assetsFile)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		fi, err := f.Stat()
-		if err != nil {
-			return err
-		}
-		c.assetsFS, err = zip.NewReader(f, fi.Size())
-		if err != nil {
-			return err
-		}
+	closeAssets, err := c.openAssets(c.cacheSources)
+	if err != nil {
+		return fail(failAssets, err)
 	}
-	// Validate c.benchDir and provide helpful error messages..
-	if fi, err := os.Stat(c.benchDir); errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("benchmarks directory (-bench-dir) does not exist; did you mean to run this command from x/benchmarks/sweet?")
-	} else if err != nil {
-		return fmt.Errorf("checking benchmarks directory (-bench-dir): %w", err)
-	} else {
-		if !fi.IsDir() {
-			return fmt.Errorf("-bench-dir is not a directory; did you mean to run this command from x/benchmarks/sweet?")
-		}
-		var missing []string
-		for _, b := range allBenchmarks {
-			fi, err := os.Stat(filepath.Join(c.benchDir, b.name))
-			if err != nil || !fi.IsDir() {
-				missing = append(missing, b.name)
-			}
-		}
-		if len(missing) != 0 {
-			return fmt.Errorf("benchmarks directory (-bench-dir) is missing benchmarks (%s); did you mean to run this command from x/benchmarks/sweet?", strings.Join(missing, ", "))
-		}
+	defer closeAssets()
+	if err := checkBenchDir(c.benchDir); err != nil {
+		return fail(failConfig, err)
 	}
 	log.Printf("Work directory: %s", c.workDir)
 
 	// Parse and validate all input TOML configs.
-	configs := make([]*common.Config, 0, len(args))
-	names := make(map[string]struct{})
-	for _, configFile := range args {
-		// Make the configuration file path absolute relative to the CWD.
-		configFile, err := filepath.Abs(configFile)
-		if err != nil {
-			return fmt.Errorf("failed to absolutize %q: %v", configFile, err)
-		}
-		configDir := filepath.Dir(configFile)
+	configs, err := readConfigs(args)
+	if err != nil {
+		return fail(failConfig, err)
+	}
 
-		// Read and parse the configuration file.
-		b, err := os.ReadFile(configFile)
-		if err != nil {
-			return fmt.Errorf("failed to read %q: %v", configFile, err)
-		}
-		var fconfigs common.ConfigFile
-		md, err := toml.Decode(string(b), &fconfigs)
+	if c.runCfg.cpuLimit < 0 {
+		return fail(failConfig, fmt.Errorf("-cpu-limit must not be negative"))
+	}
+	if c.runCfg.deadline < 0 {
+		return fail(failConfig, fmt.Errorf("-deadline must not be negative"))
+	}
+	if c.runCfg.serverProcs < 0 || c.runCfg.clientProcs < 0 {
+		return fail(failConfig, fmt.Errorf("-server-procs and -client-procs must not be negative"))
+	}
+	shuffleSeed, shuffle, err := parseShuffle(c.shuffle)
+	if err != nil {
+		return fail(failConfig, err)
+	}
+	if c.baselineResults != "" {
+		c.baselineResults, err = filepath.Abs(c.baselineResults)
 		if err != nil {
-			return fmt.Errorf("failed to parse %q: %v", configFile, err)
+			return fmt.Errorf("creating absolute path from baseline results path (-baseline-results): %w", err)
 		}
-		if len(md.Undecoded()) != 0 {
-			return fmt.Errorf("unexpected keys in %q: %+v", configFile, md.Undecoded())
+		if err := checkBaseline(c.baselineResults, c.resultsDir); err != nil {
+			return fail(failConfig, err)
 		}
-		// Validate each config and append to central list.
-		for _, config := range fconfigs.Configs {
-			if config.Name == "" {
-				return fmt.Errorf("config in %q is missing a name", configFile)
-			}
-			if _, ok := names[config.Name]; ok {
-				return fmt.Errorf("name of config in %q is not unique: %s", configFile, config.Name)
-			}
-			names[config.Name] = struct{}{}
-			if config.GoRoot == "" {
-				return fmt.Errorf("config %q in %q is missing a goroot", config.Name, configFile)
-			}
-			if strings.Contains(config.GoRoot, "~") {
-				return fmt.Errorf("path containing ~ found in config %q; feature not supported since v0.1.0", config.Name)
-			}
-			config.GoRoot = canonicalizePath(config.GoRoot, configDir)
-			if config.BuildEnv.Env == nil {
-				config.BuildEnv.Env = common.NewEnvFromEnviron()
-			}
-			if config.ExecEnv.Env == nil {
-				config.ExecEnv.Env = common.NewEnvFromEnviron()
-			}
-			if config.PGOFiles == nil {
-				config.PGOFiles = make(map[string]string)
-			}
-			for k := range config.PGOFiles {
-				if _, ok := allBenchmarksMap[k]; !ok {
-					return fmt.Errorf("config %q in %q pgofiles references unknown benchmark %q", config.Name, configFile, k)
-				}
-			}
-			configs = append(configs, config)
+	}
+	if c.regressionAlpha <= 0 || c.regressionAlpha > 1 {
+		return fail(failConfig, fmt.Errorf("-regression-alpha must be in (0, 1]"))
+	}
+	if c.baselineResults != "" {
+		if n := minRegressionCount(c.regressionAlpha); c.runCfg.count < n {
+			return fail(failConfig, fmt.Errorf("-baseline-results needs -count of at least %d for any change to be significant at -regression-alpha %g, but -count is %d", n, c.regressionAlpha, c.runCfg.count))
 		}
 	}
 
-	// Decide which benchmarks to run, based on the -run flag.
+	// Decide which benchmarks to run, based on the -rotation or -run flag.
+	if c.rotation != "" && len(c.toRun) != 0 {
+		return fail(failConfig, fmt.Errorf("-rotation and -run are mutually exclusive"))
+	}
 	var benchmarks []*benchmark
 	var unknown []string
 	switch len(c.toRun) {
 	case 0:
+		if c.rotation != "" {
+			benchmarks, err = c.rotatedBenchmarks()
+			if err != nil {
+				return fail(failConfig, err)
+			}
+			break
+		}
 		benchmarks = benchmarkGroups["default"]
 	case 1:
 		if grp, ok := benchmarkGroups[c.toRun[0]]; ok {
@@ -353,7 +514,33 @@ func (c *runCmd) Run(args []string) error {
 		}
 	}
 	if len(unknown) != 0 {
-		return fmt.Errorf("unknown benchmarks: %s", strings.Join(unknown, ", "))
+		return fail(failConfig, fmt.Errorf("unknown benchmarks: %s", strings.Join(unknown, ", ")))
+	}
+	if c.clientHost != "" {
+		if c.serverHost == "" {
+			return fail(failConfig, fmt.Errorf("-client-host requires -server-host"))
+		}
+		var local []string
+		for _, b := range benchmarks {
+			if !b.remoteClients {
+				local = append(local, b.name)
+			}
+		}
+		if len(local) != 0 {
+			return fail(failConfig, fmt.Errorf("-client-host is not supported by benchmarks: %s", strings.Join(local, ", ")))
+		}
+	}
+
+	if c.runCfg.keyDist != "" {
+		var unsupported []string
+		for _, b := range benchmarks {
+			if b.keyDists != nil && !slices.Contains(b.keyDists, c.runCfg.keyDist) {
+				unsupported = append(unsupported, fmt.Sprintf("%s (supports %s)", b.name, strings.Join(b.keyDists, ", ")))
+			}
+		}
+		if len(unsupported) != 0 {
+			return fail(failConfig, fmt.Errorf("-key-dist=%s is not supported by benchmarks: %s", c.runCfg.keyDist, strings.Join(unsupported, "; ")))
+		}
 	}
 
 	// Print an indication of how many runs will be done.
@@ -366,31 +553,140 @@ func (c *runCmd) Run(args []string) error {
 	// Check prerequisites for each benchmark.
 	for _, b := range benchmarks {
 		if err := b.harness.CheckPrerequisites(); err != nil {
-			return fmt.Errorf("failed to meet prerequisites for %s: %v", b.name, err)
+			return fail(failPrerequisite, fmt.Errorf("failed to meet prerequisites for %s: %v", b.name, err))
 		}
 	}
 
+	// Make sure each config's toolchain works before spending any time
+	// on the benchmarks.
+	if c.preflight {
+		if err := preflight(configs); err != nil {
+			return fail(failConfig, err)
+		}
+	}
+
+	// Lower our own priority as asked, which the benchmarks inherit, so
+	// that sweet itself doesn't compete with the background services
+	// the benchmarks are meant to be protected from.
+	if v, ok := c.runCfg.lowestNice(); ok {
+		if err := priority.SetNice(v); err != nil {
+			return fail(failPrerequisite, fmt.Errorf("setting niceness: %v", err))
+		}
+	}
+	if s := c.runCfg.ionice.all; s != "" {
+		p, _ := priority.ParseIO(s)
+		if err := priority.SetIO(p); err != nil {
+			return fail(failPrerequisite, fmt.Errorf("setting IO priority: %v", err))
+		}
+	}
+
+	// Make sure we're not about to mix our results in with results
+	// laid out differently, then write out a manifest so that even if
+	// we crash, downstream tools know what they're looking at.
+	if err := checkResultsLayout(c.resultsDir); err != nil {
+		return fail(failConfig, err)
+	}
+	if err := mkdirAll(c.resultsDir); err != nil {
+		return fmt.Errorf("creating results directory: %w", err)
+	}
+	manifest := newResultsManifest(configs, benchmarks)
+	manifest.AssetsVersion = c.runCfg.assetsVersion()
+	manifest.Labels = c.runCfg.labels.labelsMap()
+	if shuffle {
+		manifest.ShuffleSeed = &shuffleSeed
+	}
+	if err := manifest.write(c.resultsDir); err != nil {
+		return fmt.Errorf("writing results manifest: %w", err)
+	}
+
+	// Start reporting progress so the run can be monitored in flight.
+	progressFile := c.progressFile
+	if progressFile == "" {
+		progressFile = filepath.Join(c.resultsDir, progressFileName)
+	} else if progressFile == "off" {
+		progressFile = ""
+	}
+	if progressFile != "" || c.statusAddr != "" {
+		c.runCfg.progress, err = newProgressReporter(progressFile, c.statusAddr)
+		if err != nil {
+			return fmt.Errorf("starting progress reporting: %w", err)
+		}
+		defer func() {
+			c.runCfg.progress.finish(err)
+		}()
+		runs := c.runCfg.count * len(benchmarks) * len(configs)
+		if c.pgo {
+			runs += c.runCfg.pgoCount * len(benchmarks) * len(configs)
+			for _, cfg := range configs {
+				runs += c.runCfg.count * len(benchmarks) * max(len(cfg.PGOConfigs), 1)
+			}
+		}
+		c.runCfg.progress.plan(runs)
+	}
+	defer func() {
+		// Finalize the manifest regardless of whether we succeeded.
+		// Note that configs may have been extended by preparePGO.
+		final := newResultsManifest(configs, benchmarks)
+		final.Start = manifest.Start
+		final.AssetsVersion = manifest.AssetsVersion
+		final.ShuffleSeed = manifest.ShuffleSeed
+		final.AssetHashes = c.runCfg.assetHashes
+		final.Labels = manifest.Labels
+		end := time.Now().UTC()
+		final.End = &end
+		if err := final.takeInventory(c.resultsDir); err != nil {
+			log.Printf("warning: failed to take inventory of results: %v", err)
+		}
+		if err := final.write(c.resultsDir); err != nil {
+			log.Printf("warning: failed to write results manifest: %v", err)
+		}
+	}()
+
 	// Collect profiles from baseline runs and create new PGO'd configs.
 	if c.pgo {
-		configs, benchmarks, err = c.preparePGO(configs, benchmarks)
+		pgoConfigs, pgoBenchmarks, err := c.preparePGO(configs, benchmarks)
 		if err != nil {
 			return fmt.Errorf("error preparing PGO profiles: %w", err)
 		}
+		configs, benchmarks = pgoConfigs, pgoBenchmarks
+		c.runCfg.progress.plan(c.runCfg.count * len(benchmarks) * len(configs))
+	}
+
+	if shuffle {
+		err = c.executeShuffled(configs, benchmarks, shuffleSeed)
+	} else {
+		err = c.execute(configs, benchmarks)
+	}
+	if c.baselineResults != "" {
+		// Compare even if some benchmarks failed, so that regressions in
+		// the rest show up, but report the failures first.
+		if cerr := c.compareBaseline(configs, benchmarks); err == nil {
+			err = cerr
+		} else if cerr != nil {
+			log.Error(cerr)
+		}
 	}
+	return err
+}
 
-	// Execute each benchmark for all configs.
-	var failedBenchmarks []string
+// execute runs each benchmark for all configs, one benchmark at a time.
+func (c *runCmd) execute(configs []*common.Config, benchmarks []*benchmark) error {
+	failed := make(benchmarkFailures)
 	for _, b := range benchmarks {
-		if err := b.execute(configs, &c.runCfg); err != nil {
+		err := b.execute(configs, &c.runCfg)
+		if c.keepFailed {
+			c.reclaimWorkDir(b, err != nil)
+		}
+		if err != nil {
 			if c.stopOnError {
 				return err
 			}
-			failedBenchmarks = append(failedBenchmarks, b.name)
+			failed.add(b.name, err)
 			log.Error(err)
 		}
 	}
-	if len(failedBenchmarks) != 0 {
-		return fmt.Errorf("failed to execute benchmarks: %s", strings.Join(failedBenchmarks, " "))
+	if len(failed) != 0 {
+		return failed
 	}
 	return nil
 }
@@ -422,7 +718,7 @@ func (c *runCmd) preparePGO(configs []*common.Config, benchmarks []*benchmark) (
 		}
 	}
 	if len(successfullyExecutedBenchmarks) == 0 {
-		return nil, nil, fmt.Errorf("failed to execute any profile benchmarks, see logs for more details")
+		return nil, nil, fail(failRun, fmt.Errorf("failed to execute any profile benchmarks, see logs for more details"))
 	}
 
 	// Merge all the profiles and add new PGO configs.
@@ -437,7 +733,7 @@ func (c *runCmd) preparePGO(configs []*common.Config, benchmarks []*benchmark) (
 		noMergeError := true
 
 		for _, b := range successfullyExecutedBenchmarks {
-			p, err := mergeCPUProfiles(profileRunCfg.runProfilesDir(b, profileConfig))
+			p, err := mergeCPUProfiles(profileRunCfg.runProfilesDir(b, profileConfig), b.pgoProfile)
 			if err != nil {
 				log.Error(fmt.Errorf("error merging profiles for %s/%s: %w", b.name, profileConfig.Name, err))
 				noMergeError = false
@@ -482,9 +778,17 @@ func (c *runCmd) preparePGO(configs []*common.Config, benchmarks []*benchmark) (
 
 var cpuProfileRe = regexp.MustCompile(`-cpu\.prof$`)
 
-func mergeCPUProfiles(dir string) (string, error) {
+// mergeCPUProfiles merges the CPU profiles in dir into a single profile
+// and returns its path. If name is not empty, only the profiles created
+// with that diagnostic name are merged.
+func mergeCPUProfiles(dir, name string) (string, error) {
+	re := cpuProfileRe
+	if name != "" {
+		// The driver names these files <benchmark>-<name>-<random>-cpu.prof.
+		re = regexp.MustCompile(`-` + regexp.QuoteMeta(name) + `-[0-9]+-cpu\.prof$`)
+	}
 	profiles, err := sprofile.ReadDirPprof(dir, func(name string) bool {
-		return cpuProfileRe.FindString(name) != ""
+		return re.FindString(name) != ""
 	})
 	if err != nil {
 		return "", fmt.Errorf("error reading dir %q: %w", dir, err)
@@ -506,6 +810,172 @@ func mergeCPUProfiles(dir string) (string, error) {
 	return out, nil
 }
 
+// openAssets makes the assets for the benchmarks available as c.assetsFS,
+// from -assets-dir if it is set and otherwise from the assets cache. If
+// cacheSources is true, benchmark sources are cached alongside the assets.
+// The returned function releases the assets.
+func (c *runCfg) openAssets(cacheSources bool) (func() error, error) {
+	var err error
+	if c.assetsDir != "" {
+		c.assetsDir, err = filepath.Abs(c.assetsDir)
+		if err != nil {
+			return nil, fmt.Errorf("creating absolute path from assets path (-assets-dir): %w", err)
+		}
+		if info, err := os.Stat(c.assetsDir); os.IsNotExist(err) {
+			return nil, fmt.Errorf("assets not found at %q: did you forget to run `sweet get`?", c.assetsDir)
+		} else if err != nil {
+			return nil, fmt.Errorf("stat assets %q: %v", c.assetsDir, err)
+		} else if info.Mode()&os.ModeDir == 0 {
+			return nil, fmt.Errorf("%q is not a directory", c.assetsDir)
+		}
+		c.assetsFS = os.DirFS(c.assetsDir)
+	} else {
+		if c.assetsCache == "" {
+			return nil, fmt.Errorf("missing assets cache (-cache) and assets directory (-assets-dir): cannot proceed without assets")
+		}
+		c.assetsCache, err = filepath.Abs(c.assetsCache)
+		if err != nil {
+			return nil, fmt.Errorf("creating absolute path from assets cache path (-cache): %w", err)
+		}
+		if cacheSources {
+			c.sourceCache = filepath.Join(c.assetsCache, "src")
+		}
+		if info, err := os.Stat(c.assetsCache); os.IsNotExist(err) {
+			return nil, fmt.Errorf("assets not found at %q (-assets-dir): did you forget to run `sweet get`?", c.assetsDir)
+		} else if err != nil {
+			return nil, fmt.Errorf("stat assets %q: %v", c.assetsDir, err)
+		} else if info.Mode()&os.ModeDir == 0 {
+			return nil, fmt.Errorf("%q (-assets-dir) is not a directory", c.assetsDir)
+		}
+		// Short runs use the much smaller assets for them if they
+		// have been downloaded (with sweet get -short).
+		if c.short {
+			if _, err := bootstrap.CachedAssets(c.assetsCache, bootstrap.ShortVersion(common.Version)); err == nil {
+				log.Printf("Using the assets for short runs")
+				c.shortAssets = true
+			}
+		}
+		assetsFile, err := bootstrap.CachedAssets(c.assetsCache, c.assetsVersion())
+		if err == bootstrap.ErrNotInCache {
+			return nil, fmt.Errorf("assets for version %q not found in %q", common.Version, c.assetsCache)
+		} else if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(assetsFile)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		c.assetsFS, err = zip.NewReader(f, fi.Size())
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f.Close, nil
+	}
+	return func() error { return nil }, nil
+}
+
+// checkBenchDir validates the benchmarks directory dir (-bench-dir),
+// providing helpful error messages.
+func checkBenchDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("benchmarks directory (-bench-dir) does not exist; did you mean to run this command from x/benchmarks/sweet?")
+	} else if err != nil {
+		return fmt.Errorf("checking benchmarks directory (-bench-dir): %w", err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("-bench-dir is not a directory; did you mean to run this command from x/benchmarks/sweet?")
+	}
+	var missing []string
+	for _, b := range allBenchmarks {
+		fi, err := os.Stat(b.benchDir(dir))
+		if err != nil || !fi.IsDir() {
+			missing = append(missing, b.name)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("benchmarks directory (-bench-dir) is missing benchmarks (%s); did you mean to run this command from x/benchmarks/sweet?", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// readConfigs parses and validates the TOML configuration files named by
+// args.
+func readConfigs(args []string) ([]*common.Config, error) {
+	configs := make([]*common.Config, 0, len(args))
+	names := make(map[string]struct{})
+	for _, configFile := range args {
+		// Make the configuration file path absolute relative to the CWD.
+		configFile, err := filepath.Abs(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to absolutize %q: %v", configFile, err)
+		}
+		configDir := filepath.Dir(configFile)
+
+		// Read and parse the configuration file.
+		b, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %v", configFile, err)
+		}
+		var fconfigs common.ConfigFile
+		md, err := toml.Decode(string(b), &fconfigs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", configFile, err)
+		}
+		if len(md.Undecoded()) != 0 {
+			return nil, fmt.Errorf("unexpected keys in %q: %+v", configFile, md.Undecoded())
+		}
+		// Validate each config and append to central list.
+		for _, config := range fconfigs.Configs {
+			if config.Name == "" {
+				return nil, fmt.Errorf("config in %q is missing a name", configFile)
+			}
+			if _, ok := names[config.Name]; ok {
+				return nil, fmt.Errorf("name of config in %q is not unique: %s", configFile, config.Name)
+			}
+			names[config.Name] = struct{}{}
+			if config.GoRoot == "" {
+				return nil, fmt.Errorf("config %q in %q is missing a goroot", config.Name, configFile)
+			}
+			if strings.Contains(config.GoRoot, "~") {
+				return nil, fmt.Errorf("path containing ~ found in config %q; feature not supported since v0.1.0", config.Name)
+			}
+			config.GoRoot = canonicalizePath(config.GoRoot, configDir)
+			if config.BuildEnv.Env == nil {
+				config.BuildEnv.Env = common.NewEnvFromEnviron()
+			}
+			if config.ExecEnv.Env == nil {
+				config.ExecEnv.Env = common.NewEnvFromEnviron()
+			}
+			if config.PGOFiles == nil {
+				config.PGOFiles = make(map[string]string)
+			}
+			for k := range config.PGOFiles {
+				if _, ok := allBenchmarksMap[k]; !ok {
+					return nil, fmt.Errorf("config %q in %q pgofiles references unknown benchmark %q", config.Name, configFile, k)
+				}
+			}
+			for k := range config.Versions {
+				b, ok := allBenchmarksMap[k]
+				if !ok {
+					return nil, fmt.Errorf("config %q in %q versions references unknown benchmark %q", config.Name, configFile, k)
+				}
+				if _, ok := b.harness.(common.VersionedHarness); !ok {
+					return nil, fmt.Errorf("config %q in %q versions references benchmark %q, whose version can't be changed", config.Name, configFile, k)
+				}
+			}
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
 func canonicalizePath(path, base string) string {
 	if filepath.IsAbs(path) {
 		return path
@@ -0,0 +1,135 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+
+	sprofile "golang.org/x/benchmarks/sweet/common/profile"
+)
+
+func TestMergeCPUProfiles(t *testing.T) {
+	dir := t.TempDir()
+	for file, samples := range map[string]int64{
+		"EtcdPut-server-123-cpu.prof": 1,  // From the server.
+		"EtcdPut-456-cpu.prof":        10, // From the load generator.
+		"EtcdPut-inst0-789-trace":     100,
+	} {
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+			Sample:     []*profile.Sample{{Value: []int64{samples}}},
+		}
+		if err := sprofile.WritePprof(filepath.Join(dir, file), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		want int64
+	}{
+		{"", 11},
+		{"server", 1},
+	} {
+		out, err := mergeCPUProfiles(dir, tc.name)
+		if err != nil {
+			t.Fatalf("merging %q profiles: %v", tc.name, err)
+		}
+		p, err := sprofile.ReadPprof(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int64
+		for _, s := range p.Sample {
+			got += s.Value[0]
+		}
+		if got != tc.want {
+			t.Errorf("merging %q profiles: got %d samples, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := mergeCPUProfiles(dir, "client"); err == nil {
+		t.Errorf("merging nonexistent profiles: expected error")
+	}
+}
+
+func TestLabelsFlag(t *testing.T) {
+	var l labelsFlag
+	for _, s := range []string{"run=a", "machine=lab-2", "note=a=b c"} {
+		if err := l.Set(s); err != nil {
+			t.Fatalf("Set(%q): %v", s, err)
+		}
+	}
+	if got, want := l.configLines(), "run: a\nmachine: lab-2\nnote: a=b c\n"; got != want {
+		t.Errorf("configLines() = %q, want %q", got, want)
+	}
+	for _, s := range []string{"run", "Run=a", "my run=a", "=a", "1run=a", "note=a\nb", "run=b"} {
+		if err := l.Set(s); err == nil {
+			t.Errorf("Set(%q): expected error", s)
+		}
+	}
+	if len(l) != 3 {
+		t.Errorf("failed Sets changed the labels: %v", l.String())
+	}
+}
+
+func TestLowestNice(t *testing.T) {
+	for _, tc := range []struct {
+		nice   string
+		want   int
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"10", 10, true},
+		{"10,etcd=5", 5, true},
+		{"-5,tsdb=0", -5, true},
+		// Without a value for all benchmarks, sweet's own is unchanged.
+		{"etcd=5", 0, false},
+	} {
+		var r runCfg
+		r.nice.parse = func(s string) (string, error) { return s, nil }
+		if tc.nice != "" {
+			if err := r.nice.Set(tc.nice); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, ok := r.lowestNice(); got != tc.want || ok != tc.wantOK {
+			t.Errorf("lowestNice with -nice=%s = %d, %v; want %d, %v", tc.nice, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestPerBenchmarkFlag(t *testing.T) {
+	f := perBenchmarkFlag{parse: func(s string) (string, error) {
+		if s == "bad" {
+			return "", fmt.Errorf("bad value")
+		}
+		return strings.ToUpper(s), nil
+	}}
+	if err := f.Set("a,etcd=b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("tsdb=c"); err != nil {
+		t.Fatal(err)
+	}
+	for b, want := range map[string]string{"etcd": "B", "tsdb": "C", "cache": "A"} {
+		if got := f.get(b); got != want {
+			t.Errorf("get(%q) = %q, want %q", b, got, want)
+		}
+	}
+	if got, want := f.String(), "A,etcd=B,tsdb=C"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, s := range []string{"nosuch=a", "bad", "etcd=bad"} {
+		if err := f.Set(s); err == nil {
+			t.Errorf("Set(%q): expected error", s)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// parseShuffle parses the value of the -shuffle flag, which is "off", "on"
+// or a seed, as for go test -shuffle. It returns the seed to shuffle runs
+// with, and whether to shuffle them at all.
+func parseShuffle(s string) (seed int64, ok bool, err error) {
+	switch s {
+	case "", "off":
+		return 0, false, nil
+	case "on":
+		return time.Now().UnixNano(), true, nil
+	}
+	seed, err = strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf(`-shuffle must be "off", "on" or an integer seed, not %q`, s)
+	}
+	return seed, true, nil
+}
+
+// A runSlot is one run of a benchmark for a configuration, as indices
+// into the benchmarks and configurations of a sweet run.
+type runSlot struct {
+	bench, config int
+}
+
+// shuffledSlots returns every (benchmark, configuration) pair for one
+// iteration of a run of nbench benchmarks over nconfig configurations, in
+// an order chosen by rng.
+func shuffledSlots(rng *rand.Rand, nbench, nconfig int) []runSlot {
+	slots := make([]runSlot, 0, nbench*nconfig)
+	for b := 0; b < nbench; b++ {
+		for c := 0; c < nconfig; c++ {
+			slots = append(slots, runSlot{b, c})
+		}
+	}
+	rng.Shuffle(len(slots), func(i, j int) { slots[i], slots[j] = slots[j], slots[i] })
+	return slots
+}
+
+// executeShuffled executes benchmarks for all configs like executing each
+// in turn does, except that the runs of each iteration happen in a random
+// order, chosen by seed, rather than benchmark by benchmark. That way, any
+// drift in the machine's performance over a long run, from the time of day
+// or its temperature, doesn't line up with particular benchmarks.
+//
+// Since any benchmark may run next, all of them are set up first, and
+// their work directories kept until the end.
+func (c *runCmd) executeShuffled(configs []*common.Config, benchmarks []*benchmark, seed int64) error {
+	log.Printf("Shuffling runs with seed %d", seed)
+
+	failed := make(benchmarkFailures)
+	runs := make([]*benchmarkRun, len(benchmarks)) // nil once a benchmark fails.
+	defer func() {
+		for _, br := range runs {
+			if br != nil {
+				br.close()
+			}
+		}
+	}()
+	// finish ends benchmark i, with err if it failed.
+	finish := func(i int, err error) error {
+		b := benchmarks[i]
+		c.runCfg.progress.resumeBenchmark(b.name)
+		c.runCfg.progress.endBenchmark(err)
+		if runs[i] != nil {
+			runs[i].close()
+			runs[i] = nil
+		}
+		if c.keepFailed {
+			c.reclaimWorkDir(b, err != nil)
+		}
+		if err != nil {
+			if c.stopOnError {
+				return err
+			}
+			failed.add(b.name, err)
+			log.Error(err)
+		}
+		return nil
+	}
+
+	for i, b := range benchmarks {
+		c.runCfg.progress.startBenchmark(b.name, c.runCfg.count*len(configs))
+		br, err := b.setUp(configs, &c.runCfg)
+		if err != nil {
+			if err := finish(i, err); err != nil {
+				return err
+			}
+			continue
+		}
+		runs[i] = br
+	}
+
+	// Shuffle every slot, even those of benchmarks that have failed, so
+	// that the order depends only on the seed.
+	rng := rand.New(rand.NewSource(seed))
+	for j := 1; j <= c.runCfg.count; j++ {
+		for _, slot := range shuffledSlots(rng, len(benchmarks), len(configs)) {
+			br := runs[slot.bench]
+			if br == nil {
+				continue
+			}
+			c.runCfg.progress.resumeBenchmark(br.b.name)
+			if err := br.run(slot.config, j); err != nil {
+				if err := finish(slot.bench, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i, br := range runs {
+		if br != nil {
+			br.check()
+			finish(i, nil)
+		}
+	}
+	if len(failed) != 0 {
+		return failed
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseShuffle(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		seed int64
+		ok   bool
+		err  bool
+	}{
+		{in: "off"},
+		{in: ""},
+		{in: "42", seed: 42, ok: true},
+		{in: "-7", seed: -7, ok: true},
+		{in: "yes", err: true},
+	} {
+		seed, ok, err := parseShuffle(test.in)
+		if (err != nil) != test.err || ok != test.ok || seed != test.seed {
+			t.Errorf("parseShuffle(%q) = %d, %v, %v; want %d, %v, error %v", test.in, seed, ok, err, test.seed, test.ok, test.err)
+		}
+	}
+	if _, ok, err := parseShuffle("on"); !ok || err != nil {
+		t.Errorf("parseShuffle(\"on\") = _, %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestShuffledSlots(t *testing.T) {
+	iterations := func(seed int64) [][]runSlot {
+		rng := rand.New(rand.NewSource(seed))
+		var its [][]runSlot
+		for i := 0; i < 3; i++ {
+			its = append(its, shuffledSlots(rng, 4, 3))
+		}
+		return its
+	}
+	its := iterations(1)
+	for i, slots := range its {
+		// Every pair appears exactly once per iteration.
+		seen := make(map[runSlot]bool)
+		for _, s := range slots {
+			if s.bench < 0 || s.bench >= 4 || s.config < 0 || s.config >= 3 || seen[s] {
+				t.Fatalf("iteration %d: bad or repeated slot %v in %v", i, s, slots)
+			}
+			seen[s] = true
+		}
+		if len(seen) != 12 {
+			t.Errorf("iteration %d: got %d slots, want 12", i, len(seen))
+		}
+	}
+	if reflect.DeepEqual(its[0], its[1]) && reflect.DeepEqual(its[1], its[2]) {
+		t.Errorf("every iteration has the same order %v", its[0])
+	}
+	// The same seed gives the same order.
+	if again := iterations(1); !reflect.DeepEqual(its, again) {
+		t.Errorf("seed 1 gave %v, then %v", its, again)
+	}
+}
@@ -26,9 +26,16 @@ called 'config'. Each element of the array consists of the following fields:
                to be passed to the Go compiler for optimization (optional)
   pgoenvbuild: a list of named build environment variables to be run on based
                on the same pgo profile. They have the same format as envbuild.
+     versions: a map of benchmark names to the version of the benchmark's
+               upstream source to build, as a tag or commit hash, instead
+               of the version Sweet pins; only supported by etcd (optional)
   diagnostics: profile types to collect for each benchmark run of this
-               configuration, which may be one of: cpuprofile, memprofile,
-               perf[=flags], trace (optional)
+               configuration, which may be one of: cpuprofile,
+               memprofile[=delta], mutexprofile, perf[=flags], trace
+               (optional). With =delta, memprofile only covers the
+               allocations made while the benchmark is measured, not during
+               its setup. mutexprofile only covers code that runs in the
+               benchmark's own process, not separate servers.
 
 A simple example configuration might look like:
 
@@ -73,6 +80,7 @@ type Config struct {
 	ExecEnv     ConfigEnv             `toml:"envexec"`
 	PGOFiles    map[string]string     `toml:"pgofiles"`
 	PGOConfigs  []PGOConfig           `toml:"pgoconfig"`
+	Versions    map[string]string     `toml:"versions"`
 	Diagnostics diagnostics.ConfigSet `toml:"diagnostics"`
 }
 
@@ -97,6 +105,10 @@ func (c *Config) Copy() *Config {
 	for k, v := range c.PGOFiles {
 		cc.PGOFiles[k] = v
 	}
+	cc.Versions = make(map[string]string)
+	for k, v := range c.Versions {
+		cc.Versions[k] = v
+	}
 	cc.PGOConfigs = make([]PGOConfig, len(c.PGOConfigs))
 	for i, v := range c.PGOConfigs {
 		cc.PGOConfigs[i] = v
@@ -124,6 +136,7 @@ func ConfigFileMarshalTOML(c *ConfigFile) ([]byte, error) {
 		ExecEnv     []string          `toml:"envexec"`
 		PGOFiles    map[string]string `toml:"pgofiles"`
 		PGOConfigs  []pgoConfig       `toml:"pgoconfig"`
+		Versions    map[string]string `toml:"versions"`
 		Diagnostics []string          `toml:"diagnostics"`
 	}
 	type configFile struct {
@@ -137,6 +150,7 @@ func ConfigFileMarshalTOML(c *ConfigFile) ([]byte, error) {
 		cfg.BuildEnv = c.BuildEnv.Collapse()
 		cfg.ExecEnv = c.ExecEnv.Collapse()
 		cfg.PGOFiles = c.PGOFiles
+		cfg.Versions = c.Versions
 		cfg.Diagnostics = c.Diagnostics.Strings()
 
 		cfg.PGOConfigs = make([]pgoConfig, len(c.PGOConfigs))
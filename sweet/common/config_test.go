@@ -5,6 +5,7 @@
 package common_test
 
 import (
+	"maps"
 	"strings"
 	"testing"
 
@@ -23,6 +24,7 @@ func TestConfigMarshalTOML(t *testing.T) {
 				// from the environment.
 				BuildEnv: common.ConfigEnv{common.NewEnvFromEnviron()},
 				ExecEnv:  common.ConfigEnv{common.NewEnvFromEnviron()},
+				Versions: map[string]string{"etcd": "v3.5.17"},
 			},
 		},
 	}
@@ -47,6 +49,9 @@ func TestConfigMarshalTOML(t *testing.T) {
 		if cfgBefore.GoRoot != cfgAfter.GoRoot {
 			t.Fatalf("unexpected GOROOT: got %s, want %s", cfgAfter.GoRoot, cfgBefore.GoRoot)
 		}
+		if !maps.Equal(cfgBefore.Versions, cfgAfter.Versions) {
+			t.Fatalf("unexpected versions: got %v, want %v", cfgAfter.Versions, cfgBefore.Versions)
+		}
 		compareEnvs(t, cfgBefore.BuildEnv.Env, cfgAfter.BuildEnv.Env)
 		compareEnvs(t, cfgBefore.ExecEnv.Env, cfgAfter.ExecEnv.Env)
 	}
@@ -58,6 +58,9 @@ func (c ConfigSet) Copy() ConfigSet {
 
 // Set adds a Config to ConfigSet, overwriting any Config of the same Type.
 func (c *ConfigSet) Set(d Config) {
+	if c.cfgs == nil {
+		c.cfgs = make(map[Type]Config)
+	}
 	c.cfgs[d.Type] = d
 }
 
@@ -82,19 +85,28 @@ func (c ConfigSet) Empty() bool {
 type Type string
 
 const (
-	CPUProfile Type = "cpuprofile"
-	MemProfile Type = "memprofile"
-	Perf       Type = "perf"
-	Trace      Type = "trace"
+	CPUProfile   Type = "cpuprofile"
+	MemProfile   Type = "memprofile"
+	MutexProfile Type = "mutexprofile"
+	Perf         Type = "perf"
+	Trace        Type = "trace"
 )
 
+// MemProfileDelta is the flag for MemProfile diagnostics that asks for
+// a profile of only the allocations made in the measured region of a
+// benchmark, rather than since the process started.
+const MemProfileDelta = "delta"
+
 // IsPprof returns whether the diagnostic's data is stored in the pprof format.
 func (t Type) IsPprof() bool {
-	return t == CPUProfile || t == MemProfile
+	return t == CPUProfile || t == MemProfile || t == MutexProfile
 }
 
 // HTTPEndpoint returns the net/http/pprof endpoint for this diagnostic type as
 // a host-relative URL, or "" if there is no enpdoint.
+//
+// MutexProfile has none: servers don't sample contention unless they set a
+// mutex profile fraction themselves, so their endpoint would be empty.
 func (t Type) HTTPEndpoint() string {
 	switch t {
 	case CPUProfile:
@@ -114,6 +126,8 @@ func (t Type) FileName() string {
 		return "cpu.prof"
 	case MemProfile:
 		return "mem.prof"
+	case MutexProfile:
+		return "mutex.prof"
 	case Perf:
 		return "perf.data"
 	case Trace:
@@ -126,7 +140,7 @@ func (t Type) FileName() string {
 // should be collected at the end of a benchmark.
 func (t Type) IsSnapshot() bool {
 	switch t {
-	case MemProfile:
+	case MemProfile, MutexProfile:
 		return true
 	}
 	return false
@@ -136,7 +150,7 @@ func (t Type) IsSnapshot() bool {
 // profile.
 func (t Type) CanMerge() bool {
 	switch t {
-	case CPUProfile, MemProfile:
+	case CPUProfile, MemProfile, MutexProfile:
 		return true
 	}
 	return false
@@ -157,11 +171,18 @@ func Types() []Type {
 	return []Type{
 		CPUProfile,
 		MemProfile,
+		MutexProfile,
 		Perf,
 		Trace,
 	}
 }
 
+// ServerName is the name given to diagnostics collected from the server
+// processes of a client/server benchmark, to tell them apart from those of
+// the load generator. It appears after the benchmark name in the names of
+// the resulting files.
+const ServerName = "server"
+
 // Config is an intent to collect data for some diagnostic with some room
 // for additional configuration as to how that data is collected.
 type Config struct {
@@ -170,7 +191,8 @@ type Config struct {
 
 	// Flags is additional opaque configuration for data collection.
 	//
-	// Currently only used if Type == Perf.
+	// Currently only used if Type == Perf, or if Type == MemProfile, in
+	// which case it may be MemProfileDelta.
 	Flags string
 }
 
@@ -188,14 +210,23 @@ func (d Config) String() string {
 //
 //	<type>[=<flags>]
 //
-// where [=<flags>] is only accepted if <type> is perf.
+// where [=<flags>] is only accepted if <type> is perf, or if <type> is
+// memprofile and <flags> is MemProfileDelta.
 func ParseConfig(d string) (Config, error) {
 	comp := strings.SplitN(d, "=", 2)
 	var result Config
 	switch comp[0] {
+	case string(MemProfile):
+		if len(comp) == 2 {
+			if comp[1] != MemProfileDelta {
+				return result, fmt.Errorf("diagnostic %q only takes the flag %q", comp[0], MemProfileDelta)
+			}
+			result.Flags = comp[1]
+		}
+		result.Type = Type(comp[0])
 	case string(CPUProfile):
 		fallthrough
-	case string(MemProfile):
+	case string(MutexProfile):
 		fallthrough
 	case string(Trace):
 		if len(comp) != 1 {
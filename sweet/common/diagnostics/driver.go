@@ -7,6 +7,7 @@ package diagnostics
 import (
 	"flag"
 	"fmt"
+	"strconv"
 )
 
 // DriverConfig is a diagnostics configuration that can be passed to a benchmark
@@ -14,6 +15,10 @@ import (
 type DriverConfig struct {
 	ConfigSet
 	ResultsDir string
+
+	// GoTool is the go command of the toolchain that built the benchmark,
+	// which the driver uses to analyze the execution traces it collects.
+	GoTool string
 }
 
 // DriverArgs returns the arguments that should be passed to a Sweet benchmark
@@ -21,12 +26,20 @@ type DriverConfig struct {
 func (c *DriverConfig) DriverArgs() []string {
 	args := []string{"-results-dir", c.ResultsDir}
 	for _, c1 := range c.cfgs {
-		args = append(args, "-"+string(c1.Type))
-		if c1.Type == Perf {
+		switch {
+		case c1.Type == Perf:
 			// String flag
-			args = append(args, c1.Flags)
+			args = append(args, "-"+string(c1.Type), c1.Flags)
+		case c1.Type == MemProfile && c1.Flags != "":
+			// Boolean flag with an optional value.
+			args = append(args, "-"+string(c1.Type)+"="+c1.Flags)
+		default:
+			args = append(args, "-"+string(c1.Type))
 		}
 	}
+	if _, ok := c.Get(Trace); ok && c.GoTool != "" {
+		args = append(args, "-trace-go", c.GoTool)
+	}
 	return args
 }
 
@@ -36,14 +49,33 @@ func (c *DriverConfig) AddFlags(f *flag.FlagSet) {
 	c.ConfigSet.cfgs = make(map[Type]Config)
 
 	f.StringVar(&c.ResultsDir, "results-dir", "", "directory to write diagnostics data")
+	f.StringVar(&c.GoTool, "trace-go", "", "go command to analyze traces with (default: go in PATH)")
 	for _, t := range Types() {
 		t := t
-		if t == Perf {
+		switch t {
+		case Perf:
 			f.Func(string(t), fmt.Sprintf("enable %s diagnostics with `flags`", t), func(s string) error {
 				c.cfgs[t] = Config{Type: t, Flags: s}
 				return nil
 			})
-		} else {
+		case MemProfile:
+			f.BoolFunc(string(t), fmt.Sprintf("enable %s diagnostics, or with =%s, for the measured region only", t, MemProfileDelta), func(s string) error {
+				if s == MemProfileDelta {
+					c.cfgs[t] = Config{Type: t, Flags: s}
+					return nil
+				}
+				on, err := strconv.ParseBool(s)
+				if err != nil {
+					return fmt.Errorf("invalid value %q, want a boolean or %q", s, MemProfileDelta)
+				}
+				if on {
+					c.cfgs[t] = Config{Type: t}
+				} else {
+					delete(c.cfgs, t)
+				}
+				return nil
+			})
+		default:
 			f.BoolFunc(string(t), fmt.Sprintf("enable %s diagnostics", t), func(s string) error {
 				c.cfgs[t] = Config{Type: t}
 				return nil
@@ -0,0 +1,43 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestDriverMemProfileFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args      []string
+		want      bool
+		wantFlags string
+		wantErr   bool
+	}{
+		{args: nil},
+		{args: []string{"-memprofile"}, want: true},
+		{args: []string{"-memprofile=true"}, want: true},
+		{args: []string{"-memprofile=1"}, want: true},
+		{args: []string{"-memprofile=false"}},
+		{args: []string{"-memprofile", "-memprofile=false"}},
+		{args: []string{"-memprofile=delta"}, want: true, wantFlags: MemProfileDelta},
+		{args: []string{"-memprofile=sometimes"}, wantErr: true},
+	} {
+		var c DriverConfig
+		f := flag.NewFlagSet("driver", flag.ContinueOnError)
+		f.SetOutput(io.Discard)
+		c.AddFlags(f)
+		err := f.Parse(tc.args)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parsing %q: got error %v, want error %v", tc.args, err, tc.wantErr)
+			continue
+		}
+		got, ok := c.Get(MemProfile)
+		if ok != tc.want || got.Flags != tc.wantFlags {
+			t.Errorf("parsing %q: got memprofile %v with flags %q, want %v with flags %q", tc.args, ok, got.Flags, tc.want, tc.wantFlags)
+		}
+	}
+}
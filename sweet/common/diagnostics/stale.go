@@ -0,0 +1,75 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// StaleTmpAge is how long a directory of uncommitted diagnostics must have
+// gone without being written to for it to be taken for the leftovers of a
+// benchmark process that crashed.
+const StaleTmpAge = time.Hour
+
+// tmpDirName matches the names of the directories in which benchmark
+// drivers keep uncommitted diagnostics, <benchmark>-<random>.tmp.
+var tmpDirName = regexp.MustCompile(`-[0-9]+\.tmp$`)
+
+// RemoveStaleTmp removes the directories of uncommitted diagnostics in dir
+// that nothing in has been modified for at least age, calling logf for each
+// one, and returns the number of bytes it reclaimed.
+func RemoveStaleTmp(dir string, age time.Duration, logf func(format string, args ...any)) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var reclaimed int64
+	for _, e := range entries {
+		if !e.IsDir() || !tmpDirName.MatchString(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		size, modTime, err := dirUsage(path)
+		if err != nil {
+			return reclaimed, err
+		}
+		if time.Since(modTime) < age {
+			// A benchmark may still be writing to it.
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return reclaimed, err
+		}
+		logf("removed stale diagnostics directory %s (%d bytes, last modified %s)", path, size, modTime.Format(time.RFC3339))
+		reclaimed += size
+	}
+	return reclaimed, nil
+}
+
+// dirUsage returns the total size of the files under dir, and the last time
+// it or anything in it was modified.
+func dirUsage(dir string) (size int64, modTime time.Time, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			size += fi.Size()
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+		return nil
+	})
+	return size, modTime, err
+}
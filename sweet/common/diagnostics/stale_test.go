@@ -0,0 +1,99 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleTmp(t *testing.T) {
+	old := time.Now().Add(-2 * StaleTmpAge)
+	for _, tc := range []struct {
+		name      string
+		dir       string
+		isDir     bool
+		files     map[string]int // Sizes of files in the directory, by name.
+		freshFile string         // A file in it modified just now, if any.
+		wantGone  bool
+	}{
+		{name: "stale", dir: "Etcd-123.tmp", isDir: true, files: map[string]int{"cpu.prof": 100, "sub/trace": 50}, wantGone: true},
+		{name: "empty stale", dir: "Etcd-456.tmp", isDir: true, wantGone: true},
+		{name: "fresh file", dir: "Etcd-789.tmp", isDir: true, files: map[string]int{"cpu.prof": 100, "sub/trace": 50}, freshFile: "sub/trace"},
+		{name: "committed", dir: "Etcd-123-cpu.prof", isDir: true, files: map[string]int{"x": 10}},
+		{name: "not a number", dir: "Etcd-abc.tmp", isDir: true, files: map[string]int{"x": 10}},
+		{name: "file", dir: "Etcd-321.tmp"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tc.dir)
+			var stamp []string
+			if tc.isDir {
+				for name, size := range tc.files {
+					file := filepath.Join(path, name)
+					if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(file, make([]byte, size), 0644); err != nil {
+						t.Fatal(err)
+					}
+					stamp = append(stamp, file, filepath.Dir(file))
+				}
+				if err := os.MkdirAll(path, 0755); err != nil {
+					t.Fatal(err)
+				}
+				stamp = append(stamp, path)
+			} else {
+				if err := os.WriteFile(path, make([]byte, 10), 0644); err != nil {
+					t.Fatal(err)
+				}
+				stamp = append(stamp, path)
+			}
+			for _, p := range stamp {
+				if err := os.Chtimes(p, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.freshFile != "" {
+				now := time.Now()
+				if err := os.Chtimes(filepath.Join(path, tc.freshFile), now, now); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var logs []string
+			logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+			reclaimed, err := RemoveStaleTmp(dir, StaleTmpAge, logf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = os.Stat(path)
+			if gone := os.IsNotExist(err); gone != tc.wantGone {
+				t.Errorf("removed %s: %v, want %v", tc.dir, gone, tc.wantGone)
+			}
+			var wantReclaimed int64
+			if tc.wantGone {
+				for _, size := range tc.files {
+					wantReclaimed += int64(size)
+				}
+			}
+			if reclaimed != wantReclaimed {
+				t.Errorf("reclaimed %d bytes, want %d", reclaimed, wantReclaimed)
+			}
+			if removed := len(logs) != 0; removed != tc.wantGone || len(logs) > 1 {
+				t.Errorf("logged %q", logs)
+			}
+		})
+	}
+}
+
+func TestRemoveStaleTmpMissingDir(t *testing.T) {
+	if _, err := RemoveStaleTmp(filepath.Join(t.TempDir(), "missing"), StaleTmpAge, t.Logf); err == nil {
+		t.Error("RemoveStaleTmp of a missing directory succeeded, want error")
+	}
+}
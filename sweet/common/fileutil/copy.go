@@ -70,6 +70,17 @@ func CopyFile(dst, src string, sfinfo fs.FileInfo, srcFS fs.FS) error {
 	return err
 }
 
+// CopySymlink creates a symbolic link at dst with the same
+// target as the symbolic link at src. The target is copied
+// verbatim, so a relative target is interpreted relative to dst.
+func CopySymlink(dst, src string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, dst)
+}
+
 // CopyDir recursively copies the directory at path src to
 // a new directory at path dst. If a symlink is encountered
 // along the way, its link is copied verbatim and installed
@@ -111,7 +122,12 @@ func CopyDir(dst, src string, srcFS fs.FS) error {
 				return err
 			}
 		} else if fi.Mode()&os.ModeSymlink != 0 {
-			return fmt.Errorf("symbolic links not supported")
+			if srcFS != nil {
+				return fmt.Errorf("symbolic links not supported in fs.FS")
+			}
+			if err := CopySymlink(d, s); err != nil {
+				return err
+			}
 		} else {
 			if err := CopyFile(d, s, fi, srcFS); err != nil {
 				return err
@@ -9,6 +9,11 @@ type GenConfig struct {
 	SourceAssetsDir string
 	OutputDir       string
 	GoTool          *Go
+
+	// Short indicates whether to generate the assets for short runs,
+	// subsets of the full assets, into OutputDir, which is then
+	// distinct from AssetsDir.
+	Short bool
 }
 
 type Generator interface {
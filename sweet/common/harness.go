@@ -4,7 +4,10 @@
 
 package common
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+)
 
 type GetConfig struct {
 	// SrcDir is the path to the directory that the harness should write
@@ -15,6 +18,16 @@ type GetConfig struct {
 	// from a remote source.
 	SrcDir string
 
+	// SourceCacheDir is a directory in which the harness may cache the
+	// source it fetches across runs, keyed by where it came from and its
+	// version. If empty, sources are not cached.
+	SourceCacheDir string
+
+	// Version is the version of the upstream source to retrieve, as a
+	// configuration's versions field specifies it, instead of the one the
+	// harness pins. It is only ever set for a VersionedHarness.
+	Version string
+
 	// Short indicates whether or not to run a short version of the benchmarks
 	// for testing. Guaranteed to be the same as BuildConfig.Short and
 	// RunConfig.Short.
@@ -83,6 +96,43 @@ type RunConfig struct {
 	// for testing. Guaranteed to be the same as GetConfig.Short and
 	// BuildConfig.Short.
 	Short bool
+
+	// ClientHost is the SSH destination of a separate machine on which
+	// server benchmarks should run their clients. If empty, clients run
+	// on this machine alongside the server.
+	ClientHost string
+
+	// ServerHost is the address of this machine as seen from ClientHost.
+	// Only meaningful if ClientHost is set.
+	ServerHost string
+
+	// PortsDir is the directory in which server benchmarks reserve the
+	// ports they listen on. It is shared by all Sweet invocations on the
+	// machine, so that benchmarks running concurrently never collide.
+	PortsDir string
+
+	// KeyDist is the distribution of the keys that benchmarks with
+	// key-value workloads should access, in the syntax of their -key-dist
+	// flags, or empty for their default.
+	KeyDist string
+
+	// RunscPlatforms is the comma-separated list of runsc platforms that
+	// the gvisor benchmark should run under, in the syntax of its
+	// -platforms flag, or empty for runsc's default platform only.
+	RunscPlatforms string
+
+	// ServerProcs and ClientProcs are the GOMAXPROCS of each server
+	// instance and of the clients of server benchmarks that split the
+	// machine's CPUs between them, or 0 for their default split.
+	ServerProcs int
+	ClientProcs int
+}
+
+// PortsDir returns the directory in which server benchmarks reserve ports.
+// It is outside any work directory, because concurrent invocations of Sweet
+// usually have separate work directories.
+func PortsDir() string {
+	return filepath.Join(os.TempDir(), "sweet-ports")
 }
 
 type Harness interface {
@@ -102,3 +152,14 @@ type Harness interface {
 	// output to `results`.
 	Run(cfg *Config, r *RunConfig) error
 }
+
+// VersionedHarness is a Harness that can retrieve and build versions of its
+// upstream source other than the one it pins, so that configurations may
+// ask for them with their versions field.
+type VersionedHarness interface {
+	Harness
+
+	// DefaultVersion returns the version of the upstream source that the
+	// harness retrieves when GetConfig.Version is empty.
+	DefaultVersion() string
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package priority sets the CPU niceness and IO priority of the current
+// process, which the processes it starts inherit.
+package priority
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IOClass is an IO scheduling class.
+type IOClass int
+
+const (
+	IORealtime   IOClass = 1
+	IOBestEffort IOClass = 2
+	IOIdle       IOClass = 3
+)
+
+var ioClassNames = map[IOClass]string{
+	IORealtime:   "realtime",
+	IOBestEffort: "best-effort",
+	IOIdle:       "idle",
+}
+
+// IO is an IO priority: a scheduling class and, for the realtime and
+// best-effort classes, a level from 0 (highest) to 7 (lowest).
+type IO struct {
+	Class IOClass
+	Level int
+}
+
+// String returns p in the syntax ParseIO accepts.
+func (p IO) String() string {
+	if p.Class == IOIdle {
+		return ioClassNames[p.Class]
+	}
+	return fmt.Sprintf("%s:%d", ioClassNames[p.Class], p.Level)
+}
+
+// ParseIO parses an IO priority written as class[:level], where class is
+// realtime, best-effort or idle, and level defaults to 4.
+func ParseIO(s string) (IO, error) {
+	name, level, hasLevel := strings.Cut(s, ":")
+	p := IO{Level: 4}
+	for c, n := range ioClassNames {
+		if n == name {
+			p.Class = c
+		}
+	}
+	if p.Class == 0 {
+		return IO{}, fmt.Errorf("invalid IO priority %q: class must be realtime, best-effort or idle", s)
+	}
+	if hasLevel {
+		if p.Class == IOIdle {
+			return IO{}, fmt.Errorf("invalid IO priority %q: the idle class has no levels", s)
+		}
+		l, err := strconv.Atoi(level)
+		if err != nil || l < 0 || l > 7 {
+			return IO{}, fmt.Errorf("invalid IO priority %q: level must be 0 to 7", s)
+		}
+		p.Level = l
+	}
+	if p.Class == IOIdle {
+		p.Level = 0
+	}
+	return p, nil
+}
+
+// ParseNice parses a niceness, from -20 (highest priority) to 19 (lowest).
+func ParseNice(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -20 || n > 19 {
+		return 0, fmt.Errorf("invalid niceness %q: must be -20 to 19", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package priority
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// SetNice sets the niceness of the current process. Lowering it needs
+// CAP_SYS_NICE, or a high enough RLIMIT_NICE.
+func SetNice(n int) error {
+	return forEachThread(func(tid int) error {
+		return os.NewSyscallError("setpriority", syscall.Setpriority(syscall.PRIO_PROCESS, tid, n))
+	})
+}
+
+// SetIO sets the IO priority of the current process. Only the root user
+// may use the realtime class.
+func SetIO(p IO) error {
+	const whoProcess, classShift = 1, 13
+	return forEachThread(func(tid int) error {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, whoProcess, uintptr(tid), uintptr(int(p.Class)<<classShift|p.Level))
+		if errno != 0 {
+			return os.NewSyscallError("ioprio_set", errno)
+		}
+		return nil
+	})
+}
+
+// forEachThread calls f for every thread of the current process. On Linux
+// both niceness and IO priority belong to threads, not processes, and new
+// threads and processes inherit them from the thread that creates them, so
+// to cover everything the process will start, they must be set on every
+// thread it has. f is called again for any threads started meanwhile.
+func forEachThread(f func(tid int) error) error {
+	done := make(map[int]bool)
+	for {
+		entries, err := os.ReadDir("/proc/self/task")
+		if err != nil {
+			return err
+		}
+		more := false
+		for _, e := range entries {
+			tid, err := strconv.Atoi(e.Name())
+			if err != nil || done[tid] {
+				continue
+			}
+			// Threads that have exited since don't matter.
+			if err := f(tid); err != nil && !errors.Is(err, syscall.ESRCH) {
+				return err
+			}
+			done[tid] = true
+			more = true
+		}
+		if !more {
+			return nil
+		}
+	}
+}
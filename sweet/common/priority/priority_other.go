@@ -0,0 +1,19 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package priority
+
+import "errors"
+
+var errUnsupported = errors.New("setting process priorities is only supported on Linux")
+
+func SetNice(n int) error {
+	return errUnsupported
+}
+
+func SetIO(p IO) error {
+	return errUnsupported
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package priority
+
+import "testing"
+
+func TestParseIO(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want IO
+	}{
+		{"idle", IO{IOIdle, 0}},
+		{"best-effort", IO{IOBestEffort, 4}},
+		{"best-effort:7", IO{IOBestEffort, 7}},
+		{"realtime:0", IO{IORealtime, 0}},
+	} {
+		got, err := ParseIO(tc.in)
+		if err != nil {
+			t.Errorf("ParseIO(%q): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseIO(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+		if tc.in != "best-effort" && got.String() != tc.in {
+			t.Errorf("ParseIO(%q).String() = %q", tc.in, got.String())
+		}
+	}
+	for _, in := range []string{"", "low", "idle:3", "best-effort:8", "realtime:x"} {
+		if _, err := ParseIO(in); err == nil {
+			t.Errorf("ParseIO(%q): expected error", in)
+		}
+	}
+}
+
+func TestParseNice(t *testing.T) {
+	if n, err := ParseNice("10"); err != nil || n != 10 {
+		t.Errorf("ParseNice(\"10\") = %d, %v, want 10", n, err)
+	}
+	for _, in := range []string{"", "20", "-21", "low"} {
+		if _, err := ParseNice(in); err == nil {
+			t.Errorf("ParseNice(%q): expected error", in)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generators
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/common"
+)
+
+const (
+	// hugoPages is the number of pages in the hugo benchmark's site.
+	hugoPages = 10000
+
+	// hugoShortPages is the number of pages in the site for short runs,
+	// which the benchmark also keeps to when given the full site.
+	hugoShortPages = 100
+)
+
+// Hugo is a dynamic assets Generator for the hugo benchmark.
+type Hugo struct{}
+
+// Generate writes the site the hugo benchmark builds, its content corpus
+// included, into the site directory in the output directory. The corpus
+// is generated rather than taken from a real site, from a fixed seed, so
+// that it is the same wherever the assets are generated. For short runs,
+// the site has hugoShortPages pages.
+func (Hugo) Generate(cfg *common.GenConfig) error {
+	pages := hugoPages
+	if cfg.Short {
+		pages = hugoShortPages
+	}
+	dir := filepath.Join(cfg.OutputDir, "site")
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return genHugoSite(dir, pages)
+}
+
+// hugoSiteFiles are the configuration and layouts of the hugo benchmark's
+// site. They exercise what real sites spend their time on: templates with
+// partials, blocks and pagination, taxonomy pages, tables of contents,
+// related content and shortcodes.
+var hugoSiteFiles = map[string]string{
+	"hugo.toml": `baseURL = "https://example.org/"
+title = "Sweet"
+languageCode = "en-us"
+
+[taxonomies]
+  tag = "tags"
+  category = "categories"
+
+[pagination]
+  pagerSize = 20
+`,
+	"layouts/_default/baseof.html": `<!DOCTYPE html>
+<html lang="{{ .Site.LanguageCode }}">
+<head><title>{{ .Title }} | {{ .Site.Title }}</title></head>
+<body>
+<nav>{{ range .Site.Sections }}<a href="{{ .RelPermalink }}">{{ .Title }}</a> {{ end }}</nav>
+<main>{{ block "main" . }}{{ end }}</main>
+{{ partial "footer.html" . }}
+</body>
+</html>
+`,
+	"layouts/_default/single.html": `{{ define "main" }}
+<article>
+<h1>{{ .Title }}</h1>
+<time datetime="{{ .Date.Format "2006-01-02" }}">{{ .Date.Format "January 2, 2006" }}</time>
+{{ .TableOfContents }}
+{{ .Content }}
+<p>{{ .WordCount }} words, {{ .ReadingTime }} minutes.</p>
+<ul>{{ range .GetTerms "tags" }}<li><a href="{{ .RelPermalink }}">{{ .LinkTitle }}</a></li>{{ end }}</ul>
+{{ with .Site.RegularPages.Related . | first 5 }}
+<h2>Related</h2>
+<ul>{{ range . }}<li><a href="{{ .RelPermalink }}">{{ .Title }}</a></li>{{ end }}</ul>
+{{ end }}
+</article>
+{{ end }}
+`,
+	"layouts/_default/list.html": `{{ define "main" }}
+<h1>{{ .Title }}</h1>
+{{ .Content }}
+<ul>
+{{ range (.Paginate .Pages).Pages }}<li><a href="{{ .RelPermalink }}">{{ .Title }}</a> {{ .Summary | plainify | truncate 120 }}</li>
+{{ end }}
+</ul>
+{{ end }}
+`,
+	"layouts/_default/terms.html": `{{ define "main" }}
+<h1>{{ .Title }}</h1>
+<ul>{{ range .Data.Terms.ByCount }}<li><a href="{{ .Page.RelPermalink }}">{{ .Page.Title }}</a> ({{ .Count }})</li>{{ end }}</ul>
+{{ end }}
+`,
+	"layouts/partials/footer.html": `<footer>{{ len .Site.RegularPages }} pages, {{ len .Site.Taxonomies.tags }} tags. {{ now.Year }}</footer>
+`,
+	"layouts/shortcodes/note.html": `<aside class="note">{{ .Inner | markdownify }}</aside>
+`,
+}
+
+// hugoWords are the words page titles and text are made of.
+var hugoWords = strings.Fields(`the of and to in is that for it as was with be by on not he this are or
+his from at which but have an they you were her she there been one all we their has would when if
+so no will more can its into only other time some could them than may these new then first any
+like our very just over such also most even well made after where many before must through back
+years much your way down should because long each those people own good still know use being see
+same work right great world never another while last might us came show every around part place
+garbage collector scheduler goroutine channel compiler linker runtime allocation benchmark`)
+
+// genHugoSite writes a site of pages pages into dir, from a fixed seed.
+func genHugoSite(dir string, pages int) error {
+	for name, data := range hugoSiteFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+	const sections, tags, categories = 10, 200, 20
+	rng := rand.New(rand.NewSource(1))
+	sentence := func(n int) string {
+		s := make([]string, n)
+		for i := range s {
+			s[i] = hugoWords[rng.Intn(len(hugoWords))]
+		}
+		return strings.Join(s, " ")
+	}
+	for i := 0; i < pages; i++ {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "---\ntitle: %q\n", strings.Title(sentence(4)))
+		fmt.Fprintf(&buf, "date: 2024-%02d-%02dT12:00:00Z\n", 1+i%12, 1+i%28)
+		fmt.Fprintf(&buf, "tags: [tag%d, tag%d, tag%d]\n", rng.Intn(tags), rng.Intn(tags), rng.Intn(tags))
+		fmt.Fprintf(&buf, "categories: [category%d]\n---\n\n", rng.Intn(categories))
+		fmt.Fprintf(&buf, "%s.\n\n", sentence(30))
+		for h := 0; h < 4; h++ {
+			fmt.Fprintf(&buf, "## %s\n\n", strings.Title(sentence(3)))
+			for p := 0; p < 3; p++ {
+				fmt.Fprintf(&buf, "%s *%s* **%s** `%s`.\n\n", sentence(40), sentence(2), sentence(2), hugoWords[rng.Intn(len(hugoWords))])
+			}
+			for l := 0; l < 5; l++ {
+				fmt.Fprintf(&buf, "- %s\n", sentence(8))
+			}
+			if i > 0 {
+				other := rng.Intn(i)
+				fmt.Fprintf(&buf, "\nSee also [page %d](/section%d/page%d/).\n\n", other, other%sections, other)
+			}
+		}
+		fmt.Fprintf(&buf, "```go\nfunc f%d(x int) int {\n\treturn x * %d\n}\n```\n\n", i, i)
+		fmt.Fprintf(&buf, "{{< note >}}%s **%s**.{{< /note >}}\n", sentence(12), sentence(2))
+
+		path := filepath.Join(dir, "content", fmt.Sprintf("section%d", i%sections), fmt.Sprintf("page%d.md", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
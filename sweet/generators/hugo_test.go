@@ -0,0 +1,80 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generators
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/benchmarks/sweet/common"
+)
+
+// readTree returns the contents of the files under dir, keyed by their
+// paths relative to dir.
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestHugoShort(t *testing.T) {
+	gen := func() map[string][]byte {
+		t.Helper()
+		out := t.TempDir()
+		if err := (Hugo{}).Generate(&common.GenConfig{OutputDir: out, Short: true}); err != nil {
+			t.Fatal(err)
+		}
+		return readTree(t, filepath.Join(out, "site"))
+	}
+	site := gen()
+
+	pages := 0
+	for name := range site {
+		if strings.HasPrefix(name, "content/") {
+			pages++
+		}
+	}
+	if pages != hugoShortPages {
+		t.Errorf("got %d pages, want %d", pages, hugoShortPages)
+	}
+	for name := range hugoSiteFiles {
+		if _, ok := site[name]; !ok {
+			t.Errorf("site has no %s", name)
+		}
+	}
+
+	// The site is the same every time it's generated.
+	again := gen()
+	if len(again) != len(site) {
+		t.Fatalf("got %d files the second time, want %d", len(again), len(site))
+	}
+	for name, data := range site {
+		if !bytes.Equal(again[name], data) {
+			t.Errorf("%s differs between generations", name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generators
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Generators produce the assets for short runs, which are published as an
+// archive of their own (see bootstrap.ShortVersion), when GenConfig.Short
+// is set. Where short runs only use part of a benchmark's big assets, the
+// short assets have just that part, as a deterministic subset: the first
+// pages of bleve-index's Wikipedia dump, a sample of the markdown documents
+// and a smaller hugo site. Tile38's short runs start from an empty store,
+// so they get none of the data the store is generated from. Other assets
+// are copied whole. The helpers below produce the subsets.
+
+// sample returns n of names, evenly spaced and in their original order, or
+// all of names if there are no more than n.
+func sample(names []string, n int) []string {
+	if len(names) <= n {
+		return names[:len(names):len(names)]
+	}
+	s := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		s = append(s, names[i*len(names)/n])
+	}
+	return s
+}
+
+// copyWikiDumpHead copies the first n pages of the bzip2-compressed
+// MediaWiki dump src to dst, which it compresses with the bzip2 command,
+// since Go has no bzip2 compressor.
+func copyWikiDumpHead(dst, src string, n int) error {
+	bzip2Cmd, err := exec.LookPath("bzip2")
+	if err != nil {
+		return fmt.Errorf("shortening %s needs the bzip2 command: %v", src, err)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Keep everything up to the end of the nth page, then close the
+	// document.
+	const endPage = "</page>"
+	var head bytes.Buffer
+	s := bufio.NewScanner(bzip2.NewReader(in))
+	s.Buffer(nil, 64<<20) // Articles can be long.
+	for pages := 0; pages < n && s.Scan(); {
+		head.WriteString(s.Text())
+		head.WriteByte('\n')
+		if strings.TrimSpace(s.Text()) == endPage {
+			pages++
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("reading %s: %v", src, err)
+	}
+	head.WriteString("</mediawiki>\n")
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(bzip2Cmd, "-c")
+	cmd.Stdin = &head
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		out.Close()
+		return fmt.Errorf("compressing %s: %v", dst, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generators
+
+import (
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	for _, tc := range []struct {
+		n    int
+		want []string
+	}{
+		{3, []string{"a", "c", "e"}},
+		{4, []string{"a", "b", "d", "e"}},
+		{6, names},
+		{10, names},
+	} {
+		got := sample(names, tc.n)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("sample(%d) = %v, want %v", tc.n, got, tc.want)
+		}
+		// The sample may be appended to without changing names.
+		_ = append(got, "x")
+		if names[len(names)-1] != "f" {
+			t.Fatalf("appending to sample(%d) changed names", tc.n)
+		}
+	}
+}
+
+func TestCopyWikiDumpHead(t *testing.T) {
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		t.Skip("bzip2 command not found")
+	}
+	dir := t.TempDir()
+	var dump strings.Builder
+	dump.WriteString("<mediawiki>\n  <siteinfo>\n  </siteinfo>\n")
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(&dump, "  <page>\n    <title>Page %d</title>\n  </page>\n", i)
+	}
+	dump.WriteString("</mediawiki>\n")
+
+	src := filepath.Join(dir, "dump.xml.bz2")
+	cmd := exec.Command("bzip2", "-c")
+	cmd.Stdin = strings.NewReader(dump.String())
+	compressed, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "head.xml.bz2")
+	if err := copyWikiDumpHead(dst, src, 2); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(bzip2.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<mediawiki>\n  <siteinfo>\n  </siteinfo>\n" +
+		"  <page>\n    <title>Page 0</title>\n  </page>\n" +
+		"  <page>\n    <title>Page 1</title>\n  </page>\n" +
+		"</mediawiki>\n"
+	if string(got) != want {
+		t.Errorf("got head\n%s\nwant\n%s", got, want)
+	}
+}
@@ -43,7 +43,13 @@ type Tile38 struct{}
 //
 // This generator also copies over the static assets used to generate
 // the dynamic assets.
+//
+// For short runs, which don't load the persistent store, it generates
+// no store and copies none of the data.
 func (Tile38) Generate(cfg *common.GenConfig) error {
+	if cfg.Short {
+		return shortTile38(cfg)
+	}
 	if cfg.AssetsDir != cfg.OutputDir {
 		// Copy over the datasets which are used to generate
 		// the server's persistent data.
@@ -298,3 +304,20 @@ func storeGeoJSON(c redis.Conn, jsonFile string) error {
 	_, err = c.Do("SET", "key:bench", "id:countries", "OBJECT", string(b))
 	return err
 }
+
+// shortTile38 copies the static assets for short runs into the output
+// directory. Short runs start the server with an empty store, so they
+// need none of the data the store is generated from, and only get the
+// READMEs and licenses.
+func shortTile38(cfg *common.GenConfig) error {
+	for _, dir := range []string{"geonames", "datahub"} {
+		if err := os.MkdirAll(filepath.Join(cfg.OutputDir, "gen-data", dir), 0755); err != nil {
+			return err
+		}
+	}
+	return copyFiles(cfg.OutputDir, cfg.AssetsDir, []string{
+		"gen-data/geonames/LICENSE",
+		"gen-data/datahub/LICENSE",
+		"gen-data/README.md",
+	})
+}
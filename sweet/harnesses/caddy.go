@@ -0,0 +1,57 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harnesses
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+const caddyVersion = "v2.8.4"
+
+type Caddy struct{}
+
+func (h Caddy) CheckPrerequisites() error {
+	return nil
+}
+
+func (h Caddy) Get(gcfg *common.GetConfig) error {
+	return gitShallowClone(
+		gcfg.SourceCacheDir,
+		gcfg.SrcDir,
+		"https://github.com/caddyserver/caddy",
+		caddyVersion,
+	)
+}
+
+func (h Caddy) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
+	// Build the standard distribution of Caddy, with all of the standard
+	// modules, just as cmd/caddy's main package does.
+	if err := cfg.GoTool().BuildPath(filepath.Join(bcfg.SrcDir, "cmd", "caddy"), filepath.Join(bcfg.BinDir, "caddy")); err != nil {
+		return fmt.Errorf("error building caddy: %w", err)
+	}
+	return cfg.GoTool().BuildPath(bcfg.BenchDir, filepath.Join(bcfg.BinDir, "caddy-bench"))
+}
+
+func (h Caddy) Run(cfg *common.Config, rcfg *common.RunConfig) error {
+	args := append(rcfg.Args, []string{
+		"-caddy", filepath.Join(rcfg.BinDir, "caddy"),
+		"-ports-dir", rcfg.PortsDir,
+		"-tmp", rcfg.TmpDir,
+	}...)
+	if rcfg.Short {
+		args = append(args, "-short")
+	}
+	cmd := exec.Command(filepath.Join(rcfg.BinDir, "caddy-bench"), args...)
+	cmd.Env = cfg.ExecEnv.Collapse()
+	cmd.Stdout = rcfg.Results
+	cmd.Stderr = rcfg.Log
+	log.TraceCommand(cmd, false)
+	return cmd.Run()
+}
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,7 +18,12 @@ import (
 )
 
 // CockroachDB implements the Harness interface.
-type CockroachDB struct{}
+type CockroachDB struct {
+	// Secure is whether to run the variants of the benchmarks that secure
+	// the cluster and the workload's connections with TLS, in place of
+	// the usual ones.
+	Secure bool
+}
 
 func (h CockroachDB) CheckPrerequisites() error {
 	// Cockroachdb is only supported on arm64 and amd64 architectures.
@@ -32,6 +38,7 @@ func (h CockroachDB) Get(gcfg *common.GetConfig) error {
 	// Recursive clone the repo as we need certain submodules, i.e.
 	// PROJ, for the build to work.
 	return gitRecursiveCloneToCommit(
+		gcfg.SourceCacheDir,
 		gcfg.SrcDir,
 		"https://github.com/cockroachdb/cockroach",
 		"master",
@@ -149,13 +156,37 @@ func (h CockroachDB) Run(cfg *common.Config, rcfg *common.RunConfig) error {
 	if rcfg.Short {
 		benchmarks = []string{"kv0/nodes=3", "kv95/nodes=3"}
 	}
+	if h.Secure {
+		benchmarks = []string{"kv0/nodes=3/secure=true", "kv95/nodes=3/secure=true"}
+		if rcfg.Short {
+			benchmarks = []string{"kv95/nodes=3/secure=true"}
+		}
+	}
+
+	// The workload is built into the cockroach binary.
+	clientArgs, cleanup, err := installClients(rcfg, "cockroach")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
 	for _, bench := range benchmarks {
-		args := append(rcfg.Args, []string{
+		args := append(rcfg.Args, clientArgs...)
+		args = append(args, []string{
 			"-bench", bench,
 			"-cockroachdb-bin", filepath.Join(rcfg.BinDir, "cockroach"),
 			"-tmp", rcfg.TmpDir,
+			"-ports-dir", rcfg.PortsDir,
 		}...)
+		if rcfg.KeyDist != "" {
+			args = append(args, "-key-dist", rcfg.KeyDist)
+		}
+		if rcfg.ServerProcs != 0 {
+			args = append(args, "-server-procs", strconv.Itoa(rcfg.ServerProcs))
+		}
+		if rcfg.ClientProcs != 0 {
+			args = append(args, "-client-procs", strconv.Itoa(rcfg.ClientProcs))
+		}
 		if rcfg.Short {
 			args = append(args, "-short")
 		}
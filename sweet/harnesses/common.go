@@ -15,7 +15,13 @@ import (
 	"golang.org/x/benchmarks/sweet/common/log"
 )
 
-func gitShallowClone(dir, url, ref string) error {
+func gitShallowClone(cacheDir, dir, url, ref string) error {
+	return cachedClone(cacheDir, dir, []string{"shallow", url, ref}, func(dir string) error {
+		return gitShallowCloneUncached(dir, url, ref)
+	})
+}
+
+func gitShallowCloneUncached(dir, url, ref string) error {
 	// Git 2.46+ has a global --no-advice flag, but that's extremely recent as of this writing.
 	cmd := exec.Command("git", "-c", "advice.detachedHead=false", "clone", "--depth", "1", "-b", ref, url, dir)
 	log.TraceCommand(cmd, false)
@@ -27,7 +33,13 @@ func gitShallowClone(dir, url, ref string) error {
 	return nil
 }
 
-func gitRecursiveCloneToCommit(dir, url, branch, hash string) error {
+func gitRecursiveCloneToCommit(cacheDir, dir, url, branch, hash string) error {
+	return cachedClone(cacheDir, dir, []string{"recursive", url, hash}, func(dir string) error {
+		return gitRecursiveCloneToCommitUncached(dir, url, branch, hash)
+	})
+}
+
+func gitRecursiveCloneToCommitUncached(dir, url, branch, hash string) error {
 	cloneCmd := exec.Command("git", "clone", "--recursive", "--shallow-submodules", "-b", branch, url, dir)
 	log.TraceCommand(cloneCmd, false)
 	var buf bytes.Buffer
@@ -45,7 +57,13 @@ func gitRecursiveCloneToCommit(dir, url, branch, hash string) error {
 	return nil
 }
 
-func gitCloneToCommit(dir, url, branch, hash string) error {
+func gitCloneToCommit(cacheDir, dir, url, branch, hash string) error {
+	return cachedClone(cacheDir, dir, []string{"full", url, hash}, func(dir string) error {
+		return gitCloneToCommitUncached(dir, url, branch, hash)
+	})
+}
+
+func gitCloneToCommitUncached(dir, url, branch, hash string) error {
 	cloneCmd := exec.Command("git", "clone", "-b", branch, url, dir)
 	log.TraceCommand(cloneCmd, false)
 	var buf bytes.Buffer
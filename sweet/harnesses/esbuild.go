@@ -30,6 +30,7 @@ func (h *ESBuild) CheckPrerequisites() error {
 
 func (h *ESBuild) Get(gcfg *common.GetConfig) error {
 	err := gitShallowClone(
+		gcfg.SourceCacheDir,
 		gcfg.SrcDir,
 		"https://github.com/evanw/esbuild",
 		"v0.23.1",
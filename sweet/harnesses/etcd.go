@@ -5,32 +5,111 @@
 package harnesses
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"golang.org/x/benchmarks/sweet/common"
 	"golang.org/x/benchmarks/sweet/common/log"
 )
 
+// etcdVersion is the version of etcd the harness builds, unless a
+// configuration asks for another one.
+//
+// Because of the way etcd is released (as a binary blob), deployed copies
+// tend to be stuck with a specific Go version. Improving performance of
+// these versions doesn't really matter. Instead, try to track something
+// close to HEAD, the latest alpha.
+const etcdVersion = "v3.6.0-alpha.0"
+
+// etcdVersionFile is the file in the bin directory in which Build records
+// the version of etcd it built, as benchmark configuration lines.
+const etcdVersionFile = "etcd-version"
+
 type Etcd struct{}
 
+func (h Etcd) DefaultVersion() string {
+	return etcdVersion
+}
+
 func (h Etcd) CheckPrerequisites() error {
 	return nil
 }
 
+// isCommitHash reports whether the version ref names a commit by its
+// hash, rather than a tag or a branch.
+var isCommitHash = regexp.MustCompile(`^[0-9a-f]{7,40}$`).MatchString
+
 func (h Etcd) Get(gcfg *common.GetConfig) error {
-	// Build against the latest alpha.
-	//
-	// Because of the way etcd is released (as a binary blob),
-	// deployed copies tend to be stuck with a specific Go version.
-	// Improving performance of these versions doesn't really matter.
-	// Instead, try to track something close to HEAD.
-	return gitShallowClone(
-		gcfg.SrcDir,
-		"https://github.com/etcd-io/etcd",
-		"v3.6.0-alpha.0",
-	)
+	const url = "https://github.com/etcd-io/etcd"
+	ref := gcfg.Version
+	if ref == "" {
+		ref = etcdVersion
+	}
+	if isCommitHash(ref) {
+		// Commits can't be cloned shallowly by hash.
+		return gitCloneToCommit(gcfg.SourceCacheDir, gcfg.SrcDir, url, "main", ref)
+	}
+	return gitShallowClone(gcfg.SourceCacheDir, gcfg.SrcDir, url, ref)
+}
+
+// etcdBuiltVersion returns the version and the abbreviated commit hash
+// that the etcd binary bin reports.
+func etcdBuiltVersion(bin string) (version, commit string, err error) {
+	cmd := exec.Command(bin, "--version")
+	log.TraceCommand(cmd, false)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("running %s --version: %v", bin, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "etcd Version":
+			version = strings.TrimSpace(v)
+		case "Git SHA":
+			commit = strings.TrimSpace(v)
+		}
+	}
+	if version == "" {
+		return "", "", fmt.Errorf("no version in the output of %s --version:\n%s", bin, out)
+	}
+	return version, commit, nil
+}
+
+// verifyEtcdVersion checks that the etcd binary bin, built from the source
+// in srcDir, is the version ref of etcd, and returns benchmark
+// configuration lines identifying it.
+func verifyEtcdVersion(bin, srcDir, ref string) (string, error) {
+	version, commit, err := etcdBuiltVersion(bin)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", "-C", srcDir, "rev-parse", "HEAD")
+	log.TraceCommand(cmd, false)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("finding etcd source commit: %v", err)
+	}
+	head := strings.TrimSpace(string(out))
+
+	// A stale source directory, left over from a run with another version,
+	// would otherwise quietly change what the benchmark measures.
+	switch {
+	case isCommitHash(ref) && !strings.HasPrefix(head, ref):
+		return "", fmt.Errorf("etcd source in %s is at commit %s, not %s", srcDir, head, ref)
+	case strings.HasPrefix(ref, "v") && version != strings.TrimPrefix(ref, "v"):
+		return "", fmt.Errorf("built etcd reports version %s, not %s", version, ref)
+	case commit != "" && !strings.HasPrefix(head, commit):
+		return "", fmt.Errorf("built etcd reports commit %s, but its source is at %s", commit, head)
+	}
+	return fmt.Sprintf("etcd-version: %s\netcd-commit: %s\n", version, head), nil
 }
 
 func (h Etcd) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
@@ -56,6 +135,19 @@ func (h Etcd) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
 	if err := copyFile(filepath.Join(bcfg.BinDir, "etcd"), filepath.Join(bcfg.SrcDir, "bin", "etcd")); err != nil {
 		return err
 	}
+	// Make sure we built the version of etcd we meant to, and record which
+	// one it was in the results.
+	ref := cfg.Versions["etcd"]
+	if ref == "" {
+		ref = etcdVersion
+	}
+	versionLines, err := verifyEtcdVersion(filepath.Join(bcfg.BinDir, "etcd"), bcfg.SrcDir, ref)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(bcfg.BinDir, etcdVersionFile), []byte(versionLines), 0644); err != nil {
+		return err
+	}
 	// Build etcd's benchmarking tool. Our benchmark is just a wrapper around that.
 	benchmarkPkg := filepath.Join(bcfg.SrcDir, "tools", "benchmark")
 	if err := cfg.GoTool().BuildPath(benchmarkPkg, filepath.Join(bcfg.BinDir, "benchmark")); err != nil {
@@ -66,12 +158,26 @@ func (h Etcd) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
 }
 
 func (h Etcd) Run(cfg *common.Config, rcfg *common.RunConfig) error {
+	clientArgs, cleanup, err := installClients(rcfg, "benchmark")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	versionLines, err := os.ReadFile(filepath.Join(rcfg.BinDir, etcdVersionFile))
+	if err != nil {
+		return err
+	}
+	if _, err := rcfg.Results.Write(versionLines); err != nil {
+		return err
+	}
 	for _, bench := range []string{"put", "stm"} {
-		args := append(rcfg.Args, []string{
+		args := append(rcfg.Args, clientArgs...)
+		args = append(args, []string{
 			"-bench", bench,
 			"-etcd-bin", filepath.Join(rcfg.BinDir, "etcd"),
 			"-benchmark-bin", filepath.Join(rcfg.BinDir, "benchmark"),
 			"-tmp", rcfg.TmpDir,
+			"-ports-dir", rcfg.PortsDir,
 		}...)
 		if rcfg.Short {
 			args = append(args, "-short")
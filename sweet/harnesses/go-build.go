@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 
 	"golang.org/x/benchmarks/sweet/common"
 	"golang.org/x/benchmarks/sweet/common/fileutil"
@@ -17,7 +19,7 @@ import (
 type buildBenchmark struct {
 	name  string
 	pkg   string
-	clone func(outDir string) error
+	clone func(cacheDir, outDir string) error
 }
 
 var (
@@ -25,8 +27,9 @@ var (
 		{
 			name: "kubernetes",
 			pkg:  "cmd/kubelet",
-			clone: func(outDir string) error {
+			clone: func(cacheDir, outDir string) error {
 				return gitShallowClone(
+					cacheDir,
 					outDir,
 					"https://github.com/kubernetes/kubernetes",
 					"v1.22.1",
@@ -36,8 +39,9 @@ var (
 		{
 			name: "istio",
 			pkg:  "istioctl/cmd/istioctl",
-			clone: func(outDir string) error {
+			clone: func(cacheDir, outDir string) error {
 				return gitShallowClone(
+					cacheDir,
 					outDir,
 					"https://github.com/istio/istio",
 					"1.11.1",
@@ -47,8 +51,9 @@ var (
 		{
 			name: "pkgsite",
 			pkg:  "cmd/frontend",
-			clone: func(outDir string) error {
+			clone: func(cacheDir, outDir string) error {
 				return gitCloneToCommit(
+					cacheDir,
 					outDir,
 					"https://go.googlesource.com/pkgsite",
 					"master",
@@ -63,7 +68,11 @@ var (
 	buildBenchmarksShort = []*buildBenchmark{buildBenchmarks[2]}
 )
 
-type GoBuild struct{}
+type GoBuild struct {
+	// Loaded is whether to build under a background CPU and allocation
+	// load in the same cgroup as the build, occupying half the CPUs.
+	Loaded bool
+}
 
 func (h GoBuild) CheckPrerequisites() error {
 	return nil
@@ -72,7 +81,7 @@ func (h GoBuild) CheckPrerequisites() error {
 func (h GoBuild) Get(gcfg *common.GetConfig) error {
 	// Clone the sources that we're going to build.
 	for _, bench := range goBuildBenchmarks(gcfg.Short) {
-		if err := bench.clone(filepath.Join(gcfg.SrcDir, bench.name)); err != nil {
+		if err := bench.clone(gcfg.SourceCacheDir, filepath.Join(gcfg.SrcDir, bench.name)); err != nil {
 			return err
 		}
 	}
@@ -86,18 +95,8 @@ func (h GoBuild) Build(pcfg *common.Config, bcfg *common.BuildConfig) error {
 	// Get the benchmarks we're going to build.
 	benchmarks := goBuildBenchmarks(bcfg.Short)
 
-	// cfg.GoRoot is our source toolchain. We need to rebuild cmd/compile
-	// and cmd/link with cfg.BuildEnv to apply any configured build options
-	// (e.g., PGO).
-	//
-	// Do so by `go install`ing them into a copied GOROOT.
-	goroot := filepath.Join(bcfg.BinDir, "goroot")
-	if err := fileutil.CopyDir(goroot, cfg.GoRoot, nil); err != nil {
-		return fmt.Errorf("error copying GOROOT: %v", err)
-	}
-	cfg.GoRoot = goroot
-	if err := cfg.GoTool().Do("", "install", "cmd/compile", "cmd/link"); err != nil {
-		return fmt.Errorf("error building cmd/compile and cmd/link: %v", err)
+	if err := installToolchain(cfg, bcfg.BinDir); err != nil {
+		return err
 	}
 
 	for _, bench := range benchmarks {
@@ -138,11 +137,15 @@ func (h GoBuild) Run(pcfg *common.Config, rcfg *common.RunConfig) error {
 	cfg := pcfg.Copy()
 	cfg.GoRoot = filepath.Join(rcfg.BinDir, "goroot") // see Build, above.
 
+	args := rcfg.Args
+	if h.Loaded {
+		args = append(args, "-load", strconv.Itoa(max(1, runtime.NumCPU()/2)))
+	}
 	benchmarks := goBuildBenchmarks(rcfg.Short)
 	for _, bench := range benchmarks {
 		cmd := exec.Command(
 			filepath.Join(rcfg.BinDir, "go-build-bench"),
-			append(rcfg.Args, []string{
+			append(args, []string{
 				"-go", cfg.GoTool().Tool,
 				"-tmp", rcfg.TmpDir,
 				filepath.Join(rcfg.BinDir, bench.name, bench.pkg),
@@ -159,6 +162,22 @@ func (h GoBuild) Run(pcfg *common.Config, rcfg *common.RunConfig) error {
 	return nil
 }
 
+// installToolchain rebuilds cmd/compile and cmd/link from cfg.GoRoot, our
+// source toolchain, with cfg.BuildEnv to apply any configured build options
+// (e.g., PGO). It does so by `go install`ing them into a copy of GOROOT in
+// binDir, and updates cfg.GoRoot to point to it.
+func installToolchain(cfg *common.Config, binDir string) error {
+	goroot := filepath.Join(binDir, "goroot")
+	if err := fileutil.CopyDir(goroot, cfg.GoRoot, nil); err != nil {
+		return fmt.Errorf("error copying GOROOT: %v", err)
+	}
+	cfg.GoRoot = goroot
+	if err := cfg.GoTool().Do("", "install", "cmd/compile", "cmd/link"); err != nil {
+		return fmt.Errorf("error building cmd/compile and cmd/link: %v", err)
+	}
+	return nil
+}
+
 func goBuildBenchmarks(short bool) []*buildBenchmark {
 	if short {
 		return buildBenchmarksShort
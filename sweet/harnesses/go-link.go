@@ -0,0 +1,103 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harnesses
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// GoLink is a harness for the Go linker on its own. Where go-build
+// measures linking incidentally, once per build, it relinks a very large
+// program from archives built once, with several sets of flags.
+type GoLink struct{}
+
+func (h GoLink) CheckPrerequisites() error {
+	return nil
+}
+
+// goLinkBenchmarks returns the programs to link: kubelet, or in short mode,
+// the much smaller pkgsite frontend.
+func goLinkBenchmarks(short bool) []*buildBenchmark {
+	if short {
+		return buildBenchmarksShort
+	}
+	return buildBenchmarks[:1]
+}
+
+func (h GoLink) Get(gcfg *common.GetConfig) error {
+	for _, bench := range goLinkBenchmarks(gcfg.Short) {
+		if err := bench.clone(gcfg.SourceCacheDir, filepath.Join(gcfg.SrcDir, bench.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h GoLink) Build(pcfg *common.Config, bcfg *common.BuildConfig) error {
+	// Local copy of config for updating GOROOT.
+	cfg := pcfg.Copy()
+
+	// Link with the configuration's linker, built with its build options,
+	// as go-build does.
+	if err := installToolchain(cfg, bcfg.BinDir); err != nil {
+		return err
+	}
+
+	benchBin := filepath.Join(bcfg.BinDir, "go-link-bench")
+	if err := cfg.GoTool().BuildPath(bcfg.BenchDir, benchBin); err != nil {
+		return fmt.Errorf("error building go-link tool: %w", err)
+	}
+
+	// Build each program once, capturing its archives and its link
+	// command, to relink them in Run. Building with the race detector is
+	// slow, so skip it in short mode.
+	for _, bench := range goLinkBenchmarks(bcfg.Short) {
+		args := []string{
+			"-prepare", filepath.Join(bcfg.BinDir, bench.name),
+			"-go", cfg.GoTool().Tool,
+		}
+		if !bcfg.Short {
+			args = append(args, "-race")
+		}
+		cmd := exec.Command(benchBin, append(args, filepath.Join(bcfg.SrcDir, bench.name, bench.pkg))...)
+		// Build with ExecEnv, as go-build does, since the archives are
+		// what is linked when benchmarking.
+		cmd.Env = cfg.ExecEnv.MustSet("GOROOT=" + cfg.GoRoot).Collapse()
+		log.TraceCommand(cmd, false)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("error building %s %s: %v: %s", bench.name, bench.pkg, err, out)
+		}
+	}
+	return nil
+}
+
+func (h GoLink) Run(pcfg *common.Config, rcfg *common.RunConfig) error {
+	cfg := pcfg.Copy()
+	cfg.GoRoot = filepath.Join(rcfg.BinDir, "goroot") // see Build, above.
+
+	for _, bench := range goLinkBenchmarks(rcfg.Short) {
+		cmd := exec.Command(
+			filepath.Join(rcfg.BinDir, "go-link-bench"),
+			append(rcfg.Args, []string{
+				"-archives", filepath.Join(rcfg.BinDir, bench.name),
+				"-name", filepath.Base(bench.pkg),
+				"-tmp", rcfg.TmpDir,
+			}...)...,
+		)
+		cmd.Env = cfg.ExecEnv.MustSet("GOROOT=" + cfg.GoRoot).Collapse()
+		cmd.Stdout = rcfg.Results
+		cmd.Stderr = rcfg.Log
+		log.TraceCommand(cmd, false)
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
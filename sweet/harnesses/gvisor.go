@@ -29,6 +29,7 @@ func (h GVisor) CheckPrerequisites() error {
 
 func (h GVisor) Get(gcfg *common.GetConfig) error {
 	return gitCloneToCommit(
+		gcfg.SourceCacheDir,
 		gcfg.SrcDir,
 		"https://github.com/google/gvisor",
 		"go",
@@ -68,6 +69,9 @@ func (h GVisor) Run(cfg *common.Config, rcfg *common.RunConfig) error {
 	if rcfg.Short {
 		args = append(args, "-short")
 	}
+	if rcfg.RunscPlatforms != "" {
+		args = append(args, "-platforms", rcfg.RunscPlatforms)
+	}
 	cmd := exec.Command(
 		filepath.Join(rcfg.BinDir, "gvisor-bench"),
 		args...,
@@ -0,0 +1,57 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harnesses
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+const hugoVersion = "v0.134.3"
+
+type Hugo struct{}
+
+func (h Hugo) CheckPrerequisites() error {
+	return nil
+}
+
+func (h Hugo) Get(gcfg *common.GetConfig) error {
+	return gitShallowClone(
+		gcfg.SourceCacheDir,
+		gcfg.SrcDir,
+		"https://github.com/gohugoio/hugo",
+		hugoVersion,
+	)
+}
+
+func (h Hugo) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
+	// Build the standard edition of hugo, which unlike the extended
+	// edition needs no cgo.
+	if err := cfg.GoTool().BuildPath(bcfg.SrcDir, filepath.Join(bcfg.BinDir, "hugo")); err != nil {
+		return fmt.Errorf("error building hugo: %w", err)
+	}
+	return cfg.GoTool().BuildPath(bcfg.BenchDir, filepath.Join(bcfg.BinDir, "hugo-bench"))
+}
+
+func (h Hugo) Run(cfg *common.Config, rcfg *common.RunConfig) error {
+	args := append(rcfg.Args, []string{
+		"-hugo", filepath.Join(rcfg.BinDir, "hugo"),
+		"-site", filepath.Join(rcfg.AssetsDir, "site"),
+		"-tmp", rcfg.TmpDir,
+	}...)
+	if rcfg.Short {
+		args = append(args, "-short")
+	}
+	cmd := exec.Command(filepath.Join(rcfg.BinDir, "hugo-bench"), args...)
+	cmd.Env = cfg.ExecEnv.Collapse()
+	cmd.Stdout = rcfg.Results
+	cmd.Stderr = rcfg.Log
+	log.TraceCommand(cmd, false)
+	return cmd.Run()
+}
@@ -5,17 +5,23 @@
 package harnesses
 
 import (
+	"fmt"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/fileutil"
 	"golang.org/x/benchmarks/sweet/common/log"
 )
 
 type localBenchHarness struct {
-	binName   string
-	genArgs   func(cfg *common.Config, rcfg *common.RunConfig) []string
-	beforeRun func(cfg *common.Config, rcfg *common.RunConfig) error
+	binName    string
+	genArgs    func(cfg *common.Config, rcfg *common.RunConfig) []string
+	afterBuild func(cfg *common.Config, bcfg *common.BuildConfig) error
+	beforeRun  func(cfg *common.Config, rcfg *common.RunConfig) error
 }
 
 func (h *localBenchHarness) CheckPrerequisites() error {
@@ -27,7 +33,13 @@ func (h *localBenchHarness) Get(_ *common.GetConfig) error {
 }
 
 func (h *localBenchHarness) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
-	return cfg.GoTool().BuildPath(bcfg.BenchDir, filepath.Join(bcfg.BinDir, h.binName))
+	if err := cfg.GoTool().BuildPath(bcfg.BenchDir, filepath.Join(bcfg.BinDir, h.binName)); err != nil {
+		return err
+	}
+	if h.afterBuild != nil {
+		return h.afterBuild(cfg, bcfg)
+	}
+	return nil
 }
 
 func (h *localBenchHarness) Run(cfg *common.Config, rcfg *common.RunConfig) error {
@@ -81,11 +93,19 @@ func BleveIndex() common.Harness {
 				args = []string{
 					"-documents", "10",
 					"-batch-size", "10",
+					"-workers", "2",
 				}
 			} else {
+				// Run the parallel variant with a doubling number of
+				// workers, up to the number of CPUs.
+				var workers []string
+				for n := 1; n <= runtime.NumCPU() && n <= 64; n *= 2 {
+					workers = append(workers, strconv.Itoa(n))
+				}
 				args = []string{
 					"-documents", "1000",
 					"-batch-size", "100",
+					"-workers", strings.Join(workers, ","),
 				}
 			}
 			return append(args, filepath.Join(rcfg.AssetsDir, "enwiki-20080103-pages-articles.xml.bz2"))
@@ -93,6 +113,62 @@ func BleveIndex() common.Harness {
 	}
 }
 
+func Cache() common.Harness {
+	return &localBenchHarness{
+		binName: "cache-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			var args []string
+			if rcfg.KeyDist != "" {
+				args = append(args, "-key-dist", rcfg.KeyDist)
+			}
+			if rcfg.Short {
+				args = append(args, "-short")
+			}
+			return args
+		},
+	}
+}
+
+func FSWalk() common.Harness {
+	return &localBenchHarness{
+		binName: "fswalk-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			args := []string{"-tmp", rcfg.TmpDir}
+			if rcfg.Short {
+				args = append(args, "-short")
+			}
+			return args
+		},
+	}
+}
+
+func Generics() common.Harness {
+	return &localBenchHarness{
+		binName: "generics-bench",
+		afterBuild: func(cfg *common.Config, bcfg *common.BuildConfig) error {
+			// Keep a copy of the generic package's source next to the
+			// benchmark, which compiles it with the configuration's
+			// toolchain at run time.
+			src := filepath.Join(bcfg.BenchDir, "collections")
+			if err := fileutil.CopyDir(filepath.Join(bcfg.BinDir, "collections"), src, nil); err != nil {
+				return fmt.Errorf("copying collections source: %v", err)
+			}
+			return nil
+		},
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			args := []string{
+				"-go", cfg.GoTool().Tool,
+				"-src", filepath.Join(rcfg.BinDir, "collections"),
+				"-tmp", rcfg.TmpDir,
+			}
+			if rcfg.Short {
+				args = append(args, "-short")
+			}
+			return args
+		},
+	}
+}
+
 func GopherLua() common.Harness {
 	return &localBenchHarness{
 		binName: "gopher-lua-bench",
@@ -109,6 +185,91 @@ func GopherLua() common.Harness {
 	}
 }
 
+func GRPC() common.Harness {
+	return &localBenchHarness{
+		binName: "grpc-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			if rcfg.Short {
+				return []string{"-short"}
+			}
+			return nil
+		},
+	}
+}
+
+func FastHTTP() common.Harness {
+	return &localBenchHarness{
+		binName: "fasthttp-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			if rcfg.Short {
+				return []string{"-short"}
+			}
+			return nil
+		},
+	}
+}
+
+func WebSocket() common.Harness {
+	return &localBenchHarness{
+		binName: "websocket-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			if rcfg.Short {
+				return []string{"-short"}
+			}
+			return nil
+		},
+	}
+}
+
+func Encoding() common.Harness {
+	return &localBenchHarness{
+		binName: "encoding-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			if rcfg.Short {
+				return []string{"-short"}
+			}
+			return nil
+		},
+	}
+}
+
+func TSDB() common.Harness {
+	return &localBenchHarness{
+		binName: "tsdb-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			args := []string{"-tmp", rcfg.TmpDir}
+			if rcfg.Short {
+				args = append(args, "-short")
+			}
+			return args
+		},
+	}
+}
+
+func Raft() common.Harness {
+	return &localBenchHarness{
+		binName: "raft-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			if rcfg.Short {
+				return []string{"-short"}
+			}
+			return nil
+		},
+	}
+}
+
+func Vector() common.Harness {
+	return &localBenchHarness{
+		binName: "vector-bench",
+		genArgs: func(cfg *common.Config, rcfg *common.RunConfig) []string {
+			if rcfg.Short {
+				return []string{"-short"}
+			}
+			return nil
+		},
+	}
+}
+
 func Markdown() common.Harness {
 	return &localBenchHarness{
 		binName: "markdown-bench",
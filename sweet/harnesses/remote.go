@@ -0,0 +1,64 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harnesses
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// installClients copies the named binaries from rcfg.BinDir to a fresh
+// directory on rcfg.ClientHost, for server benchmarks running in two-host
+// mode. It returns the flags that tell the benchmark binary where its
+// clients run, and a function that removes the directory again.
+//
+// If rcfg.ClientHost is empty, clients run locally and there is nothing
+// to do.
+func installClients(rcfg *common.RunConfig, bins ...string) (args []string, cleanup func(), err error) {
+	if rcfg.ClientHost == "" {
+		return nil, func() {}, nil
+	}
+	mkdirCmd := exec.Command("ssh", "-o", "BatchMode=yes", rcfg.ClientHost, "mktemp -d -t sweet-clients.XXXXXX")
+	log.TraceCommand(mkdirCmd, false)
+	var buf bytes.Buffer
+	mkdirCmd.Stderr = &buf
+	out, err := mkdirCmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating client directory on %s: %v: stderr:\n%s", rcfg.ClientHost, err, &buf)
+	}
+	dir := strings.TrimSpace(string(out))
+	cleanup = func() {
+		rmCmd := exec.Command("ssh", "-o", "BatchMode=yes", rcfg.ClientHost, "rm -rf '"+dir+"'")
+		log.TraceCommand(rmCmd, false)
+		if err := rmCmd.Run(); err != nil {
+			log.Printf("warning: failed to remove %s on %s: %v", dir, rcfg.ClientHost, err)
+		}
+	}
+
+	scpArgs := []string{"-q", "-o", "BatchMode=yes"}
+	for _, bin := range bins {
+		scpArgs = append(scpArgs, filepath.Join(rcfg.BinDir, bin))
+	}
+	scpArgs = append(scpArgs, rcfg.ClientHost+":"+dir+"/")
+	scpCmd := exec.Command("scp", scpArgs...)
+	log.TraceCommand(scpCmd, false)
+	buf.Reset()
+	scpCmd.Stderr = &buf
+	if err := scpCmd.Run(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("copying clients to %s: %v: stderr:\n%s", rcfg.ClientHost, err, &buf)
+	}
+	return []string{
+		"-client-host", rcfg.ClientHost,
+		"-client-dir", dir,
+		"-server-host", rcfg.ServerHost,
+	}, cleanup, nil
+}
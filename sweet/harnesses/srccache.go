@@ -0,0 +1,77 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harnesses
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/benchmarks/sweet/common/fileutil"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+// errSourceCacheUnsupported is returned by lockCacheEntry on platforms where
+// the source cache can't be safely shared between concurrent Sweet processes.
+var errSourceCacheUnsupported = errors.New("source cache unsupported")
+
+// cachedClone places a copy of some source code into dir, using clone to
+// fetch it only if it's not already in cacheDir.
+//
+// The source is identified by key, which must uniquely identify the contents
+// that clone produces, such as a repository URL and a commit. Sources are
+// immutable once in the cache, so clone must not fetch anything that moves,
+// like the tip of a branch.
+//
+// If cacheDir is empty, cachedClone just calls clone(dir).
+func cachedClone(cacheDir, dir string, key []string, clone func(dir string) error) error {
+	if cacheDir == "" {
+		return clone(dir)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("creating source cache: %v", err)
+	}
+	entry := filepath.Join(cacheDir, fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(key, "\x00")))))
+
+	// Hold the lock while checking for and populating the entry, so that
+	// concurrent Sweet processes don't clone the same source twice or see
+	// a partially-populated entry.
+	unlock, err := lockCacheEntry(entry + ".lock")
+	if err == errSourceCacheUnsupported {
+		return clone(dir)
+	} else if err != nil {
+		return fmt.Errorf("locking source cache entry: %v", err)
+	}
+	defer unlock()
+
+	if _, err := os.Stat(entry); os.IsNotExist(err) {
+		// Clone into a temporary directory first and then rename it into
+		// place, so that a failed or interrupted clone never leaves an
+		// incomplete entry behind.
+		tmp := entry + ".tmp"
+		if err := os.RemoveAll(tmp); err != nil {
+			return fmt.Errorf("removing stale source cache entry: %v", err)
+		}
+		if err := clone(tmp); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, entry); err != nil {
+			return fmt.Errorf("populating source cache: %v", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("checking source cache: %v", err)
+	} else {
+		log.Printf("Using cached source for %s", strings.Join(key, " "))
+	}
+	log.CommandPrintf("cp -r %s %s", entry, dir)
+	if err := fileutil.CopyDir(dir, entry, nil); err != nil {
+		return fmt.Errorf("copying source from cache: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harnesses
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/benchmarks/sweet/common"
+	"golang.org/x/benchmarks/sweet/common/log"
+)
+
+const stringerVersion = "v0.28.0"
+
+// stringerStatsMain replaces stringer's main function, which the build
+// renames to stringerMain, so that stringer reports how much it allocated.
+// The benchmark can't measure that from the outside.
+const stringerStatsMain = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/metrics"
+)
+
+func main() {
+	stringerMain()
+
+	file := os.Getenv("SWEET_ALLOC_STATS")
+	if file == "" {
+		return
+	}
+	samples := []metrics.Sample{
+		{Name: "/gc/heap/allocs:bytes"},
+		{Name: "/gc/heap/allocs:objects"},
+	}
+	metrics.Read(samples)
+	b, _ := json.Marshal(map[string]uint64{
+		"bytes":   samples[0].Value.Uint64(),
+		"objects": samples[1].Value.Uint64(),
+	})
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+
+type Stringer struct{}
+
+func (h Stringer) CheckPrerequisites() error {
+	return nil
+}
+
+func (h Stringer) Get(gcfg *common.GetConfig) error {
+	return gitShallowClone(
+		gcfg.SourceCacheDir,
+		gcfg.SrcDir,
+		"https://go.googlesource.com/tools",
+		stringerVersion,
+	)
+}
+
+func (h Stringer) Build(cfg *common.Config, bcfg *common.BuildConfig) error {
+	// Build stringer with its main function wrapped, using an overlay
+	// so as to leave the (possibly cached) source alone.
+	pkgDir := filepath.Join(bcfg.SrcDir, "cmd", "stringer")
+	src, err := os.ReadFile(filepath.Join(pkgDir, "stringer.go"))
+	if err != nil {
+		return err
+	}
+	renamed := bytes.Replace(src, []byte("\nfunc main() {"), []byte("\nfunc stringerMain() {"), 1)
+	if bytes.Equal(renamed, src) {
+		return fmt.Errorf("no main function found in stringer %s", stringerVersion)
+	}
+	overlayDir, err := os.MkdirTemp("", "stringer-overlay")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(overlayDir)
+	overlay := make(map[string]string)
+	for name, data := range map[string][]byte{
+		"stringer.go":         renamed,
+		"sweet_stats_main.go": []byte(stringerStatsMain),
+	} {
+		path := filepath.Join(overlayDir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		overlay[filepath.Join(pkgDir, name)] = path
+	}
+	overlayJSON, err := json.Marshal(struct{ Replace map[string]string }{overlay})
+	if err != nil {
+		return err
+	}
+	overlayFile := filepath.Join(overlayDir, "overlay.json")
+	if err := os.WriteFile(overlayFile, overlayJSON, 0644); err != nil {
+		return err
+	}
+	if err := cfg.GoTool().BuildPath(pkgDir, filepath.Join(bcfg.BinDir, "stringer"), "-overlay", overlayFile); err != nil {
+		return fmt.Errorf("error building stringer: %w", err)
+	}
+	return cfg.GoTool().BuildPath(bcfg.BenchDir, filepath.Join(bcfg.BinDir, "stringer-bench"))
+}
+
+func (h Stringer) Run(cfg *common.Config, rcfg *common.RunConfig) error {
+	args := append(rcfg.Args, []string{
+		"-stringer", filepath.Join(rcfg.BinDir, "stringer"),
+		"-go", cfg.GoTool().Tool,
+		"-tmp", rcfg.TmpDir,
+	}...)
+	if rcfg.Short {
+		args = append(args, "-short")
+	}
+	cmd := exec.Command(filepath.Join(rcfg.BinDir, "stringer-bench"), args...)
+	cmd.Env = cfg.ExecEnv.Collapse()
+	cmd.Stdout = rcfg.Results
+	cmd.Stderr = rcfg.Log
+	log.TraceCommand(cmd, false)
+	return cmd.Run()
+}
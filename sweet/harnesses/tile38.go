@@ -24,6 +24,7 @@ func (h Tile38) CheckPrerequisites() error {
 
 func (h Tile38) Get(gcfg *common.GetConfig) error {
 	return gitShallowClone(
+		gcfg.SourceCacheDir,
 		gcfg.SrcDir,
 		"https://github.com/tidwall/tile38",
 		"1.29.1",
@@ -69,9 +70,15 @@ func (h Tile38) Run(cfg *common.Config, rcfg *common.RunConfig) error {
 			return err
 		}
 	}
-	args := append(rcfg.Args, []string{
+	clientArgs, cleanup, err := installClients(rcfg, "tile38-bench")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	args := append(rcfg.Args, clientArgs...)
+	args = append(args, []string{
 		"-host", "127.0.0.1",
-		"-port", "9851",
+		"-ports-dir", rcfg.PortsDir,
 		"-server", filepath.Join(rcfg.BinDir, server),
 		"-data", dataPath,
 		"-tmp", rcfg.TmpDir,